@@ -0,0 +1,159 @@
+package docker
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+
+	"gopkg.in/gomisc/containers.v1"
+	"gopkg.in/gomisc/errors.v1"
+)
+
+// attestFormat - шаблон docker buildx imagetools inspect: предикаты
+// аттестаций образа одним документом
+const attestFormat = `{"provenance":{{json .Provenance}},"sbom":{{json .SBOM}}}`
+
+// buildAttested - собирает образ через BuildKit (docker buildx build):
+// Engine API не принимает запрос аттестаций. Образ загружается в стор
+// демона, аттестации сохраняются в реестре при публикации образа.
+// Значения аргументов сборки передаются через окружение, а не командную строку
+func (cli *dockerClient) buildAttested(ctx context.Context, data *containers.ImageBuildData, labels map[string]string) error {
+	args := []string{"buildx", "build", "--load", "--progress", "plain"}
+
+	if data.Provenance {
+		args = append(args, "--attest", "type=provenance,mode=min")
+	}
+
+	if data.SBOM {
+		args = append(args, "--attest", "type=sbom")
+	}
+
+	if data.Dockerfile != "" {
+		args = append(args, "--file", filepath.Join(data.Root, data.Dockerfile))
+	}
+
+	if data.Nocache {
+		args = append(args, "--no-cache")
+	}
+
+	if data.Platform != "" {
+		args = append(args, "--platform", data.Platform)
+	}
+
+	for _, tag := range data.Tags {
+		args = append(args, "--tag", tag)
+	}
+
+	for _, k := range sortedKeys(labels) {
+		args = append(args, "--label", k+"="+labels[k])
+	}
+
+	env := cli.cliEnv()
+	buildArgs := data.BuildArgs()
+
+	keys := make([]string, 0, len(buildArgs))
+	for k := range buildArgs {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		// аргумент без значения docker берет из своего окружения
+		args = append(args, "--build-arg", k)
+
+		if v := buildArgs[k]; v != nil {
+			env = append(env, k+"="+*v)
+		}
+	}
+
+	cmd := exec.CommandContext(ctx, "docker", append(args, data.Root)...) //nolint:gosec
+	cmd.Env = env
+	cmd.Stdout = cli.stdout
+	cmd.Stderr = cli.stdout
+
+	if err := cmd.Run(); err != nil {
+		return errors.Ctx().Strings("tags", data.Tags).Wrap(err, "build image with attestations")
+	}
+
+	return nil
+}
+
+// imageAttestations - аттестации образа из реестра по ссылке с дайджестом;
+// стор демона их не хранит. nil - аттестаций нет или они недоступны
+func (cli *dockerClient) imageAttestations(ctx context.Context, ref, platform string) *containers.Attestations {
+	cmd := exec.CommandContext(ctx, "docker", "buildx", "imagetools", "inspect", "--format", attestFormat, ref) //nolint:gosec
+	cmd.Env = cli.cliEnv()
+
+	out, err := cmd.Output()
+	if err != nil {
+		return nil
+	}
+
+	var doc struct {
+		Provenance json.RawMessage `json:"provenance"`
+		SBOM       json.RawMessage `json:"sbom"`
+	}
+
+	if err = json.Unmarshal(out, &doc); err != nil {
+		return nil
+	}
+
+	attestations := &containers.Attestations{
+		Provenance: predicate(doc.Provenance, "SLSA", platform),
+		SBOM:       predicate(doc.SBOM, "SPDX", platform),
+	}
+
+	if attestations.Provenance == nil && attestations.SBOM == nil {
+		return nil
+	}
+
+	return attestations
+}
+
+// predicate - предикат аттестации из вывода imagetools: у образа одной
+// платформы он лежит под ключом kind, у многоплатформенного - под платформой
+func predicate(raw json.RawMessage, kind, platform string) json.RawMessage {
+	var single map[string]json.RawMessage
+
+	if err := json.Unmarshal(raw, &single); err != nil {
+		return nil
+	}
+
+	if p, ok := single[kind]; ok {
+		return p
+	}
+
+	var multi map[string]map[string]json.RawMessage
+
+	if err := json.Unmarshal(raw, &multi); err != nil {
+		return nil
+	}
+
+	return multi[platform][kind]
+}
+
+// cliEnv - окружение вызовов docker CLI с адресом демона клиента
+func (cli *dockerClient) cliEnv() []string {
+	env := os.Environ()
+
+	if cli.host != "" {
+		env = append(env, "DOCKER_HOST="+cli.host)
+	}
+
+	return env
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
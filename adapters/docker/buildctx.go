@@ -0,0 +1,234 @@
+package docker
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/docker/docker/pkg/archive"
+
+	"gopkg.in/gomisc/errors.v1"
+)
+
+// maxCachedContexts - число хранимых в кеше контекстов сборки
+const maxCachedContexts = 8
+
+// cachedContextWriter - поток архива контекста, параллельно пишущий его в кеш
+type cachedContextWriter struct {
+	io.ReadCloser
+	tmp      *os.File
+	path     string
+	tee      io.Reader
+	complete bool
+	failed   bool
+}
+
+// WithBuildContextCache - включает кеш архивов контекстов сборки на диске
+// в каталоге dir (пусто - каталог в пользовательском кеше). По умолчанию
+// кеш выключен: архивы больших контекстов занимают гигабайты
+func WithBuildContextCache(dir string) Option {
+	return func(o *options) {
+		o.buildCacheDir = &dir
+	}
+}
+
+func defaultBuildCacheDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+
+	return filepath.Join(dir, "gomisc-containers", "build-context")
+}
+
+// buildContext - возвращает поток tar архива контекста сборки. При включенном
+// кеше архив хранится на диске по хешу содержимого файлов каталога: при
+// повторной сборке неизмененного каталога архив читается из кеша без
+// повторной упаковки
+func (cli *dockerClient) buildContext(root string) (io.ReadCloser, error) {
+	if cli.buildCacheDir == "" {
+		return archive.TarWithOptions(root, &archive.TarOptions{})
+	}
+
+	key, err := contextHash(root)
+	if err != nil {
+		return nil, errors.Wrap(err, "hash build context")
+	}
+
+	path := filepath.Join(cli.buildCacheDir, key+".tar")
+
+	if f, openErr := os.Open(path); openErr == nil {
+		now := time.Now()
+		_ = os.Chtimes(path, now, now)
+
+		return f, nil
+	}
+
+	tarStream, err := archive.TarWithOptions(root, &archive.TarOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	if err = os.MkdirAll(cli.buildCacheDir, 0o755); err != nil {
+		return tarStream, nil
+	}
+
+	tmp, err := os.CreateTemp(cli.buildCacheDir, key+".*.tmp")
+	if err != nil {
+		return tarStream, nil
+	}
+
+	return &cachedContextWriter{
+		ReadCloser: tarStream,
+		tmp:        tmp,
+		path:       path,
+		tee:        io.TeeReader(tarStream, tmp),
+	}, nil
+}
+
+// Read - отдает поток архива, параллельно записывая его в файл кеша
+func (w *cachedContextWriter) Read(p []byte) (int, error) {
+	n, err := w.tee.Read(p)
+	if errors.Is(err, io.EOF) {
+		w.complete = true
+	} else if err != nil {
+		w.failed = true
+	}
+
+	return n, err
+}
+
+// Close - сохраняет файл кеша, если архив был прочитан полностью
+func (w *cachedContextWriter) Close() error {
+	err := w.ReadCloser.Close()
+
+	_ = w.tmp.Close()
+
+	if w.complete && !w.failed {
+		if renameErr := os.Rename(w.tmp.Name(), w.path); renameErr == nil {
+			pruneContextCache(filepath.Dir(w.path))
+
+			return err
+		}
+	}
+
+	_ = os.Remove(w.tmp.Name())
+
+	return err
+}
+
+// contextHash - хеш путей, прав и содержимого файлов каталога и целей
+// символических ссылок; время изменения не учитывается, поэтому правка
+// файла без изменения размера в ту же секунду не дает устаревший архив
+func contextHash(root string) (string, error) {
+	var entries []string
+
+	err := filepath.WalkDir(
+		root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+
+			rel, err := filepath.Rel(root, path)
+			if err != nil {
+				return err
+			}
+
+			entry := fmt.Sprintf("%s\x00%o", rel, info.Mode())
+
+			switch {
+			case info.Mode()&fs.ModeSymlink != 0:
+				target, linkErr := os.Readlink(path)
+				if linkErr != nil {
+					return linkErr
+				}
+
+				entry += "\x00" + target
+			case info.Mode().IsRegular():
+				sum, sumErr := fileHash(path)
+				if sumErr != nil {
+					return sumErr
+				}
+
+				entry += "\x00" + sum
+			}
+
+			entries = append(entries, entry)
+
+			return nil
+		},
+	)
+	if err != nil {
+		return "", err
+	}
+
+	sort.Strings(entries)
+
+	h := sha256.New()
+
+	for _, entry := range entries {
+		_, _ = io.WriteString(h, entry+"\n")
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// fileHash - sha256 содержимого файла
+func fileHash(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+
+	defer func() { _ = f.Close() }()
+
+	h := sha256.New()
+
+	if _, err = io.Copy(h, f); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// pruneContextCache - удаляет самые старые архивы сверх maxCachedContexts
+func pruneContextCache(dir string) {
+	matches, err := filepath.Glob(filepath.Join(dir, "*.tar"))
+	if err != nil || len(matches) <= maxCachedContexts {
+		return
+	}
+
+	type cached struct {
+		path    string
+		modTime time.Time
+	}
+
+	files := make([]cached, 0, len(matches))
+
+	for _, m := range matches {
+		if info, statErr := os.Stat(m); statErr == nil {
+			files = append(files, cached{path: m, modTime: info.ModTime()})
+		}
+	}
+
+	sort.Slice(
+		files, func(i, j int) bool {
+			return files[i].modTime.After(files[j].modTime)
+		},
+	)
+
+	for i := maxCachedContexts; i < len(files); i++ {
+		_ = os.Remove(files[i].path)
+	}
+}
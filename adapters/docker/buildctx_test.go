@@ -0,0 +1,56 @@
+package docker
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestContextHashTracksContent(t *testing.T) {
+	root := t.TempDir()
+	path := filepath.Join(root, "Dockerfile")
+	mtime := time.Unix(1700000000, 0)
+
+	write := func(content string) string {
+		t.Helper()
+
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatalf("WriteFile: %v", err)
+		}
+
+		if err := os.Chtimes(path, mtime, mtime); err != nil {
+			t.Fatalf("Chtimes: %v", err)
+		}
+
+		key, err := contextHash(root)
+		if err != nil {
+			t.Fatalf("contextHash: %v", err)
+		}
+
+		return key
+	}
+
+	first := write("FROM alpine:3.18\n")
+	again := write("FROM alpine:3.18\n")
+	edited := write("FROM alpine:3.19\n")
+
+	if first != again {
+		t.Fatalf("hash of unchanged context differs: %s != %s", first, again)
+	}
+
+	if first == edited {
+		t.Fatalf("hash does not change when content of the same size and mtime changes")
+	}
+}
+
+func TestBuildContextCacheIsOptIn(t *testing.T) {
+	cli, err := New()
+	if err != nil {
+		t.Skipf("docker client: %v", err)
+	}
+
+	if dir := cli.(*dockerClient).buildCacheDir; dir != "" {
+		t.Fatalf("build context cache is enabled by default: %s", dir)
+	}
+}
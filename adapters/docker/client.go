@@ -5,20 +5,25 @@ import (
 	"fmt"
 	"io"
 	"net"
+	"net/netip"
 	"os"
+	"sort"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/docker/distribution/reference"
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/api/types/filters"
 	"github.com/docker/docker/api/types/mount"
 	"github.com/docker/docker/api/types/network"
 	"github.com/docker/docker/client"
-	"github.com/docker/docker/pkg/archive"
 	"github.com/docker/docker/pkg/jsonmessage"
 	"github.com/docker/docker/pkg/stdcopy"
 	"github.com/docker/go-connections/nat"
+	specs "github.com/opencontainers/image-spec/specs-go/v1"
 
 	"gopkg.in/gomisc/containers.v1"
 	"gopkg.in/gomisc/errors.v1"
@@ -26,33 +31,128 @@ import (
 )
 
 type dockerClient struct {
-	client        client.APIClient
-	info          types.Info
-	netalloc      *ipnet.NetworksAllocator
-	stdout        io.Writer
-	stderr        io.Writer
-	isInContainer bool
+	client          client.APIClient
+	infoMu          sync.Mutex
+	info            *types.Info
+	networksMu      sync.Mutex
+	networks        map[string]*dockerNetwork
+	netalloc        *containers.SubnetAllocator
+	subnetPrefix    int
+	stdout          io.Writer
+	stderr          io.Writer
+	isInContainer   bool
+	limiter         *limiter
+	inspect         *inspectCache
+	buildCacheDir   string
+	minFreeSpace    uint64
+	pruneOnLowSpace bool
+	// diskWarnOnce - предупреждение о пропуске проверки места пишется один раз
+	diskWarnOnce sync.Once
+	// remoteHost - имя удаленного демона, на котором публикуются порты
+	remoteHost string
+	// host - адрес демона из WithHost для вызовов docker CLI, пусто - из окружения
+	host string
+	// reconnectTimeout - время ожидания демона после обрыва соединения
+	reconnectTimeout time.Duration
+	outageMu         sync.Mutex
+	outage           bool
+	// reachable - демон отвечал хотя бы на один запрос
+	reachable atomic.Bool
+	// netalloc6 - аллокатор подсетей IPv6, nil - сети создаются только с IPv4
+	netalloc6     *containers.SubnetAllocator
+	subnetPrefix6 int
+	// project - проект создаваемых клиентом объектов, пусто - вне проекта
+	project containers.Project
+	// installEmulation - регистрировать недостающую эмуляцию платформ,
+	// emulated - платформы, исполнение которых демоном подтверждено
+	installEmulation bool
+	emulated         sync.Map
 }
 
-func New() (containers.Client, error) {
-	cli, err := client.NewClientWithOpts(client.FromEnv)
+// New - конструктор docker клиента, по умолчанию настройки подключения
+// берутся из переменных окружения (DOCKER_HOST, DOCKER_CERT_PATH, etc)
+func New(opts ...Option) (containers.Client, error) {
+	o := &options{}
+
+	for _, apply := range opts {
+		apply(o)
+	}
+
+	if o.project != "" {
+		if err := o.project.Validate(); err != nil {
+			return nil, err
+		}
+	}
+
+	clientOpts, remote, err := connectionOpts(o)
+	if err != nil {
+		return nil, err
+	}
+
+	clientOpts = append(clientOpts, o.clientOpts...)
+
+	var lim *limiter
+
+	if o.maxConcurrency > 0 || o.rateLimit > 0 {
+		lim = newLimiter(o.maxConcurrency, o.rateLimit)
+		clientOpts = append(clientOpts, lim.clientOpt())
+	}
+
+	cli, err := client.NewClientWithOpts(clientOpts...)
 	if err != nil {
 		return nil, errors.Wrap(err, "create docker client")
 	}
 
 	dockerCli := &dockerClient{
-		client:        cli,
-		stdout:        os.Stdout,
-		stderr:        os.Stderr,
-		isInContainer: inContainer(),
+		limiter:          lim,
+		client:           cli,
+		stdout:           os.Stdout,
+		stderr:           os.Stderr,
+		isInContainer:    inContainer(),
+		networks:         make(map[string]*dockerNetwork),
+		minFreeSpace:     o.minFreeSpace,
+		pruneOnLowSpace:  o.pruneOnLow,
+		remoteHost:       remote,
+		host:             o.host,
+		project:          o.project,
+		installEmulation: o.installEmulation,
 	}
 
-	dockerCli.info, err = cli.Info(context.Background())
-	if err != nil {
-		return nil, errors.Wrap(err, "get docker info")
+	if o.buildCacheDir != nil {
+		dockerCli.buildCacheDir = *o.buildCacheDir
+		if dockerCli.buildCacheDir == "" {
+			dockerCli.buildCacheDir = defaultBuildCacheDir()
+		}
+	}
+
+	if o.inspectCache {
+		dockerCli.inspect = newInspectCache(cli)
+	}
+
+	dockerCli.reconnectTimeout = DefaultReconnectTimeout
+	if o.reconnectTimeout != nil {
+		dockerCli.reconnectTimeout = *o.reconnectTimeout
+	}
+
+	dockerCli.subnetPrefix = o.subnetPrefix
+	if dockerCli.subnetPrefix == 0 {
+		dockerCli.subnetPrefix = containers.DefaultSubnetPrefix
+	}
+
+	pool := o.subnetPool
+	if pool == "" {
+		pool = containers.DefaultSubnetPool
+
+		// сети проекта не пересекаются с сетями других проектов демона
+		if o.project != "" {
+			if pool, err = o.project.SubnetPool(pool, 0); err != nil {
+				return nil, errors.Wrap(err, "select project subnet pool")
+			}
+		}
 	}
 
-	dockerCli.netalloc, err = ipnet.NewNetworkAllocator(
+	dockerCli.netalloc, err = containers.NewSubnetAllocator(
+		pool,
 		dockerCli.getUsedNetworks,
 		getReservedNetworks()...,
 	)
@@ -60,6 +160,12 @@ func New() (containers.Client, error) {
 		return nil, errors.Wrap(err, "create networks allocator")
 	}
 
+	if o.ipv6 {
+		if err = dockerCli.enableIPv6(o.ipv6Pool, o.ipv6Prefix); err != nil {
+			return nil, err
+		}
+	}
+
 	return dockerCli, nil
 }
 
@@ -75,12 +181,80 @@ func (cli *dockerClient) WithStderr(w io.Writer) containers.Client {
 	return cli
 }
 
+func (cli *dockerClient) Stdout() io.Writer {
+	return cli.stdout
+}
+
+func (cli *dockerClient) Stderr() io.Writer {
+	return cli.stderr
+}
+
+// Info - возвращает сведения о демоне, запрашивая их при первом обращении;
+// неудачный запрос не кешируется
+func (cli *dockerClient) Info(ctx context.Context) (*containers.DaemonInfo, error) {
+	info, err := cli.daemonInfo(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &containers.DaemonInfo{
+		Name:            info.Name,
+		ServerVersion:   info.ServerVersion,
+		OperatingSystem: info.OperatingSystem,
+		OSType:          info.OSType,
+		Architecture:    info.Architecture,
+		KernelVersion:   info.KernelVersion,
+		NCPU:            info.NCPU,
+		MemTotal:        info.MemTotal,
+		RootDir:         info.DockerRootDir,
+		CgroupVersion:   info.CgroupVersion,
+		SecurityOptions: info.SecurityOptions,
+		CgroupDriver:    info.CgroupDriver,
+		Limits: containers.LimitsSupport{
+			Memory:    info.MemoryLimit,
+			Swap:      info.SwapLimit,
+			CPUQuota:  info.CPUCfsQuota,
+			CPUShares: info.CPUShares,
+			CPUSet:    info.CPUSet,
+			Pids:      info.PidsLimit,
+		},
+		Remote: cli.remoteHost != "",
+	}, nil
+}
+
+func (cli *dockerClient) daemonInfo(ctx context.Context) (*types.Info, error) {
+	cli.infoMu.Lock()
+	defer cli.infoMu.Unlock()
+
+	if cli.info != nil {
+		return cli.info, nil
+	}
+
+	info, err := cli.client.Info(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "get docker info")
+	}
+
+	cli.reachable.Store(true)
+	cli.info = &info
+
+	return cli.info, nil
+}
+
 func (cli *dockerClient) IsInContainer() bool {
 	return cli.isInContainer
 }
 
 func (cli *dockerClient) NetworkList(ctx context.Context) ([]*net.IPNet, error) {
-	list, err := cli.client.NetworkList(ctx, types.NetworkListOptions{})
+	var list []types.NetworkResource
+
+	err := cli.retry(
+		ctx, func(ctx context.Context) (err error) {
+			list, err = cli.client.NetworkList(ctx, types.NetworkListOptions{})
+
+			return err
+		},
+	)
 	if err != nil {
 		return nil, errors.Wrap(err, "get docker networks list")
 	}
@@ -88,26 +262,24 @@ func (cli *dockerClient) NetworkList(ctx context.Context) ([]*net.IPNet, error)
 	nwList := make([]*net.IPNet, 0, len(list))
 
 	for li := 0; li < len(list); li++ {
-		config := list[li].IPAM.Config
-		if len(config) == 0 {
-			continue
-		}
+		// у сети с двумя стеками адресов подсети IPv4 и IPv6
+		for _, config := range list[li].IPAM.Config {
+			var nw *net.IPNet
 
-		var nw *net.IPNet
+			_, nw, err = net.ParseCIDR(config.Subnet)
+			if err != nil {
+				return nil, errors.Wrap(err, "parse docker network cidr")
+			}
 
-		_, nw, err = net.ParseCIDR(config[0].Subnet)
-		if err != nil {
-			return nil, errors.Wrap(err, "parse docker network cidr")
+			nwList = append(nwList, nw)
 		}
-
-		nwList = append(nwList, nw)
 	}
 
 	return nwList, nil
 }
 
 func (cli *dockerClient) NextSubnet() (*net.IPNet, error) {
-	subnet, err := cli.netalloc.GetFreeSubnet(context.Background())
+	subnet, err := cli.netalloc.Allocate(context.Background(), cli.subnetPrefix)
 	if err != nil {
 		return nil, errors.Wrap(err, "get next free subnet")
 	}
@@ -115,104 +287,295 @@ func (cli *dockerClient) NextSubnet() (*net.IPNet, error) {
 	return subnet, nil
 }
 
+// RemoveNetwork - удаляет сеть и возвращает ее подсети в пулы аллокаторов
 func (cli *dockerClient) RemoveNetwork(id string) error {
-	return cli.client.NetworkRemove(context.Background(), id)
+	ctx := context.Background()
+
+	cli.networksMu.Lock()
+	nw, ok := cli.networks[id]
+	if ok {
+		nw.close()
+		delete(cli.networks, id)
+	}
+	cli.networksMu.Unlock()
+
+	var configs []network.IPAMConfig
+
+	if ok {
+		configs = nw.IPAM.Config
+	} else if resource, err := cli.inspectNetwork(ctx, id); err == nil {
+		configs = resource.IPAM.Config
+	}
+
+	if err := cli.client.NetworkRemove(ctx, id); err != nil {
+		return err
+	}
+
+	cli.invalidateInspect(id)
+
+	for _, cfg := range configs {
+		if _, subnet, err := net.ParseCIDR(cfg.Subnet); err == nil {
+			cli.releaseSubnet(subnet)
+		}
+	}
+
+	return nil
 }
 
-func (cli *dockerClient) ContainerCreate(ctx context.Context, data containers.Container) (string, error) {
-	conf := makeContainerConfig(data)
+// ReleaseSubnet - возвращает в пул подсеть, выданную NextSubnet и не
+// использованную для сети
+func (cli *dockerClient) ReleaseSubnet(subnet *net.IPNet) {
+	cli.releaseSubnet(subnet)
+}
+
+// releaseSubnet - возвращает подсеть в пул аллокатора ее семейства адресов
+func (cli *dockerClient) releaseSubnet(subnet *net.IPNet) {
+	switch {
+	case subnet == nil:
+	case subnet.IP.To4() != nil:
+		cli.netalloc.Release(subnet)
+	case cli.netalloc6 != nil:
+		cli.netalloc6.Release(subnet)
+	}
+}
+
+func (cli *dockerClient) ContainerCreate(ctx context.Context, c containers.Container) (string, error) {
+	data := containers.ExtendContainer(c)
+	conf := cli.containerConfig(data)
 
 	cont, err := cli.client.ContainerCreate(
 		ctx,
 		conf.Config,
 		conf.HostConfig,
 		conf.NetworkingConfig,
-		nil,
+		conf.Platform,
 		data.GetName(),
 	)
 	if err != nil {
+		if client.IsErrNotFound(err) && strings.Contains(err.Error(), "No such image") {
+			return "", errors.And(
+				errors.Ctx().Str("image", data.GetImage()).Just(containers.ErrImageNotFound),
+				err,
+			)
+		}
+
+		if ctx.Err() != nil {
+			// запрос отменен, но демон мог успеть создать контейнер
+			cli.discard(data.GetName())
+		}
+
 		return "", errors.Wrap(err, "crete docker container")
 	}
 
+	// API демона подключает при создании только одну сеть, остальные
+	// подключаются до запуска контейнера
+	for _, att := range data.GetExtraNetworks() {
+		if err = cli.NetworkConnect(ctx, cont.ID, att); err != nil {
+			cli.discard(cont.ID)
+
+			return "", err
+		}
+	}
+
 	return cont.ID, nil
 }
 
 func (cli *dockerClient) ContainerStart(ctx context.Context, id, name string) (*containers.ContainerInfo, error) {
 	if err := cli.client.ContainerStart(ctx, id, types.ContainerStartOptions{}); err != nil {
-		return nil, errors.Wrapf(err, "start container %s (%s)", name, id[:12])
+		return nil, errors.Wrapf(err, "start container %s (%s)", name, shortID(id))
 	}
 
-	list, err := cli.client.ContainerList(
-		ctx, types.ContainerListOptions{
-			All:     true,
-			Filters: filters.NewArgs(filters.Arg("name", name)),
-		},
-	)
+	cont, err := cli.inspectContainer(ctx, id)
 	if err != nil {
-		return nil, errors.Wrap(err, "search container")
+		return nil, errors.Wrap(err, "get container info")
 	}
 
-	if len(list) >= 1 {
-		var cont types.ContainerJSON
+	if cont.State != nil && !cont.State.Running {
+		return nil, errors.Ctx().
+			Str("container-name", name).
+			Str("container-id", shortID(id)).
+			Str("status", cont.State.Status).
+			Int("exit-code", cont.State.ExitCode).
+			Str("error", cont.State.Error).
+			Just(containers.ErrContainerNotRunning)
+	}
 
-		cont, err = cli.client.ContainerInspect(ctx, list[0].ID)
-		if err != nil {
-			return nil, errors.Wrap(err, "get container info")
+	info := &containers.ContainerInfo{
+		ID:          cont.ID,
+		IPAddress:   cont.NetworkSettings.IPAddress,
+		IPv6Address: cont.NetworkSettings.GlobalIPv6Address,
+		PortBinds:   make(map[containers.Port][]containers.PortBinding),
+		Networks:    make(map[string]containers.EndpointSettings),
+	}
+
+	if cont.Config != nil {
+		info.Labels = cont.Config.Labels
+	}
+
+	for port, binds := range cont.HostConfig.PortBindings {
+		for pbi := 0; pbi < len(binds); pbi++ {
+			info.PortBinds[containers.Port(port)] = append(
+				info.PortBinds[containers.Port(port)],
+				containers.PortBinding(binds[pbi]),
+			)
+		}
+	}
+
+	for k, v := range cont.NetworkSettings.Networks {
+		info.Networks[k] = containers.EndpointSettings{
+			NetworkID:   v.NetworkID,
+			IPAddress:   v.IPAddress,
+			IPv6Address: v.GlobalIPv6Address,
+		}
+	}
+
+	return info, nil
+}
+
+// ContainerInspect - запрашивает состояние контейнера у демона
+func (cli *dockerClient) ContainerInspect(ctx context.Context, id string) (*containers.InspectResult, error) {
+	cont, err := cli.inspectContainer(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &containers.InspectResult{
+		ID:        cont.ID,
+		Name:      strings.TrimPrefix(cont.Name, "/"),
+		Restarts:  cont.RestartCount,
+		PortBinds: make(containers.PortMap),
+		Networks:  make(map[string]containers.EndpointSettings),
+	}
+
+	if cont.Config != nil {
+		result.Image = cont.Config.Image
+	}
+
+	if state := cont.State; state != nil {
+		result.Status = state.Status
+		result.ExitCode = state.ExitCode
+		result.OOMKilled = state.OOMKilled
+		result.Pid = state.Pid
+		result.Error = state.Error
+		result.StartedAt = parseDockerTime(state.StartedAt)
+		result.FinishedAt = parseDockerTime(state.FinishedAt)
+
+		if state.Health != nil {
+			result.Health = state.Health.Status
 		}
+	}
 
-		info := &containers.ContainerInfo{
-			ID:        cont.ID,
-			IPAddress: cont.NetworkSettings.IPAddress,
-			PortBinds: make(map[containers.Port][]containers.PortBinding),
-			Networks:  make(map[string]containers.EndpointSettings),
+	for _, m := range cont.Mounts {
+		source := m.Source
+		if m.Type == mount.TypeVolume {
+			source = m.Name
 		}
 
-		for port, binds := range cont.HostConfig.PortBindings {
-			for pbi := 0; pbi < len(binds); pbi++ {
-				info.PortBinds[containers.Port(port)] = append(
-					info.PortBinds[containers.Port(port)],
-					containers.PortBinding(binds[pbi]),
+		result.Mounts = append(
+			result.Mounts, containers.Mount{
+				Type:        string(m.Type),
+				Source:      source,
+				Destination: m.Destination,
+				ReadOnly:    !m.RW,
+			},
+		)
+	}
+
+	// разделы HostConfig.Tmpfs демон не включает в Mounts
+	if cont.HostConfig != nil {
+		targets := make([]string, 0, len(cont.HostConfig.Tmpfs))
+
+		for target := range cont.HostConfig.Tmpfs {
+			targets = append(targets, target)
+		}
+
+		sort.Strings(targets)
+
+		for _, target := range targets {
+			result.Mounts = append(result.Mounts, containers.TmpfsMount(target, cont.HostConfig.Tmpfs[target]))
+		}
+	}
+
+	if cont.NetworkSettings != nil {
+		for port, binds := range cont.NetworkSettings.Ports {
+			for _, b := range binds {
+				result.PortBinds[containers.Port(port)] = append(
+					result.PortBinds[containers.Port(port)],
+					containers.PortBinding(b),
 				)
 			}
 		}
 
-		for k, v := range cont.NetworkSettings.Networks {
-			info.Networks[k] = containers.EndpointSettings{
-				IPAddress: v.IPAddress,
+		for name, endpoint := range cont.NetworkSettings.Networks {
+			result.Networks[name] = containers.EndpointSettings{
+				NetworkID:   endpoint.NetworkID,
+				IPAddress:   endpoint.IPAddress,
+				IPv6Address: endpoint.GlobalIPv6Address,
 			}
 		}
+	}
 
-		return info, nil
+	return result, nil
+}
+
+// parseDockerTime - разбирает время из состояния контейнера, нулевое время
+// демона ("0001-01-01T00:00:00Z") и ошибки разбора дают нулевое значение
+func parseDockerTime(s string) time.Time {
+	t, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		return time.Time{}
 	}
 
-	return nil, nil
+	return t
 }
 
 func (cli *dockerClient) ContainerWait(ctx context.Context, id string) (
 	<-chan containers.ContainerStatus,
 	<-chan error,
 ) {
-	waitCh, errCh := cli.client.ContainerWait(ctx, id, container.WaitConditionNotRunning)
-	statusCh := make(chan containers.ContainerStatus)
-
+	statusCh := make(chan containers.ContainerStatus, 1)
+	resultErrCh := make(chan error, 1)
+
+	// канал статуса docker клиента не закрывается, поэтому читаем ровно
+	// один результат и выходим; буферы позволяют не читать результат вовсе.
+	// При обрыве соединения ожидание повторяется после возвращения демона:
+	// продолжающий работу контейнер ожидается дальше, а остановленный за
+	// время перезапуска демона сразу сообщает код завершения
 	go func() {
-		for status := range waitCh {
-			statusMsg := containers.ContainerStatus{
-				StatusCode: status.StatusCode,
-			}
+		for {
+			waitCh, errCh := cli.client.ContainerWait(ctx, id, container.WaitConditionNotRunning)
 
-			if status.Error != nil {
-				statusMsg.Error = errors.Ctx().
-					Str("message", status.Error.Message).
-					Int64("error-code", status.StatusCode).
-					New("container status error")
-			}
+			select {
+			case status := <-waitCh:
+				statusMsg := containers.ContainerStatus{
+					StatusCode: status.StatusCode,
+				}
 
-			statusCh <- statusMsg
+				if status.Error != nil {
+					statusMsg.Error = errors.Ctx().
+						Str("message", status.Error.Message).
+						Int64("error-code", status.StatusCode).
+						New("container status error")
+				}
+
+				statusCh <- statusMsg
+
+				return
+			case err := <-errCh:
+				if ctx.Err() == nil && isConnectionError(err) {
+					if err = cli.awaitDaemon(ctx, err); err == nil {
+						continue
+					}
+				}
+
+				resultErrCh <- err
+
+				return
+			}
 		}
 	}()
 
-	return statusCh, errCh
+	return statusCh, resultErrCh
 }
 
 func (cli *dockerClient) ContainerStop(ctx context.Context, id string, timeout time.Duration) error {
@@ -223,6 +586,171 @@ func (cli *dockerClient) ContainerStop(ctx context.Context, id string, timeout t
 	return nil
 }
 
+func (cli *dockerClient) ContainerRestart(ctx context.Context, id string, timeout time.Duration) error {
+	if err := cli.client.ContainerRestart(ctx, id, &timeout); err != nil {
+		return errors.Wrap(err, "docker container restart")
+	}
+
+	return nil
+}
+
+func (cli *dockerClient) ContainerKill(ctx context.Context, id, signal string) error {
+	if err := cli.client.ContainerKill(ctx, id, signal); err != nil {
+		return errors.Ctx().Str("signal", signal).Wrap(err, "docker container kill")
+	}
+
+	return nil
+}
+
+func (cli *dockerClient) ContainerPause(ctx context.Context, id string) error {
+	if err := cli.client.ContainerPause(ctx, id); err != nil {
+		return errors.Wrap(err, "docker container pause")
+	}
+
+	return nil
+}
+
+func (cli *dockerClient) ContainerUnpause(ctx context.Context, id string) error {
+	if err := cli.client.ContainerUnpause(ctx, id); err != nil {
+		return errors.Wrap(err, "docker container unpause")
+	}
+
+	return nil
+}
+
+func (cli *dockerClient) ContainerExec(
+	ctx context.Context,
+	id string,
+	cmd []string,
+	stdout, stderr io.Writer,
+) (int, error) {
+	exec, err := cli.client.ContainerExecCreate(
+		ctx, id, types.ExecConfig{
+			AttachStdout: stdout != nil,
+			AttachStderr: stderr != nil,
+			Cmd:          cmd,
+		},
+	)
+	if err != nil {
+		return 0, errors.Ctx().Strings("cmd", cmd).Wrap(err, "create exec")
+	}
+
+	resp, err := cli.client.ContainerExecAttach(ctx, exec.ID, types.ExecStartCheck{})
+	if err != nil {
+		return 0, errors.Ctx().Strings("cmd", cmd).Wrap(err, "attach exec")
+	}
+
+	defer resp.Close()
+
+	if stdout == nil {
+		stdout = io.Discard
+	}
+
+	if stderr == nil {
+		stderr = io.Discard
+	}
+
+	if _, err = stdcopy.StdCopy(stdout, stderr, resp.Reader); err != nil {
+		return 0, errors.Ctx().Strings("cmd", cmd).Wrap(err, "read exec output")
+	}
+
+	inspect, err := cli.client.ContainerExecInspect(ctx, exec.ID)
+	if err != nil {
+		return 0, errors.Ctx().Strings("cmd", cmd).Wrap(err, "inspect exec")
+	}
+
+	return inspect.ExitCode, nil
+}
+
+// ContainerRemove - удаляет контейнер, отсутствие контейнера (autoremove) ошибкой не считается
+func (cli *dockerClient) ContainerRemove(ctx context.Context, id string) error {
+	if err := cli.client.ContainerRemove(
+		ctx, id, types.ContainerRemoveOptions{
+			RemoveVolumes: true,
+			Force:         true,
+		},
+	); err != nil && !client.IsErrNotFound(err) {
+		return errors.Wrap(err, "docker container remove")
+	}
+
+	return nil
+}
+
+const (
+	// discardTimeout - срок удаления контейнера незавершенного создания
+	discardTimeout = 30 * time.Second
+	// discardGrace - сколько ждать появления контейнера прерванного создания
+	discardGrace = time.Second
+)
+
+// discard - удаляет контейнер сессии, создание которого не завершено;
+// контейнеры без метки сессии клиента не трогает. Контекст отдельный,
+// так как контекст создания может быть уже отменен
+func (cli *dockerClient) discard(ref string) {
+	ctx, cancel := context.WithTimeout(context.Background(), discardTimeout)
+	defer cancel()
+
+	deadline := time.Now().Add(discardGrace)
+
+	// демон может завершить прерванное создание чуть позже отмены запроса
+	cont, err := cli.client.ContainerInspect(ctx, ref)
+	for client.IsErrNotFound(err) && time.Now().Before(deadline) {
+		time.Sleep(discardGrace / 10)
+
+		cont, err = cli.client.ContainerInspect(ctx, ref)
+	}
+
+	if err != nil || cont.Config == nil || cont.Config.Labels[SessionLabel] != sessionID {
+		return
+	}
+
+	if err = cli.ContainerRemove(ctx, cont.ID); err != nil {
+		cli.logStderr(err, "discard container %s (%s)", ref, shortID(cont.ID))
+	}
+}
+
+func (cli *dockerClient) ContainerCommit(
+	ctx context.Context, id, tag string, opts ...containers.CommitOption,
+) (string, error) {
+	o := containers.NewCommitOptions(opts...)
+
+	resp, err := cli.client.ContainerCommit(
+		ctx, id, types.ContainerCommitOptions{
+			Reference: tag,
+			Comment:   o.Message,
+			Author:    o.Author,
+			Changes:   o.Changes,
+			Pause:     !o.NoPause,
+		},
+	)
+	if err != nil {
+		return "", errors.Ctx().Str("tag", tag).Wrap(err, "docker container commit")
+	}
+
+	return resp.ID, nil
+}
+
+func (cli *dockerClient) CopyToContainer(ctx context.Context, id, dst string, content io.Reader) error {
+	if err := cli.client.CopyToContainer(
+		ctx, id, dst, content, types.CopyToContainerOptions{
+			AllowOverwriteDirWithFile: true,
+		},
+	); err != nil {
+		return errors.Ctx().Str("dst", dst).Wrap(err, "docker copy to container")
+	}
+
+	return nil
+}
+
+func (cli *dockerClient) CopyFromContainer(ctx context.Context, id, src string) (io.ReadCloser, error) {
+	content, _, err := cli.client.CopyFromContainer(ctx, id, src)
+	if err != nil {
+		return nil, errors.Ctx().Str("src", src).Wrap(err, "docker copy from container")
+	}
+
+	return content, nil
+}
+
 func (cli *dockerClient) StreamLogs(ctx context.Context, id string, stderr, stdout io.Writer, follow bool) error {
 	if stderr == nil && stdout == nil {
 		return nil
@@ -234,22 +762,39 @@ func (cli *dockerClient) StreamLogs(ctx context.Context, id string, stderr, stdo
 		Follow:     follow,
 	}
 
-	logs, err := cli.client.ContainerLogs(ctx, id, logOptions)
-	if err != nil {
-		return errors.Wrap(err, "container logs streaming")
-	}
+	// при обрыве соединения поток с follow открывается заново с момента
+	// обрыва; строки на границе могут повториться или потеряться, так как
+	// момент берется по часам клиента
+	for {
+		var logs io.ReadCloser
 
-	var n int64
+		err := cli.retry(
+			ctx, func(ctx context.Context) (err error) {
+				logs, err = cli.client.ContainerLogs(ctx, id, logOptions)
 
-	for {
-		n, err = stdcopy.StdCopy(stdout, stderr, logs)
+				return err
+			},
+		)
 		if err != nil {
-			return errors.Wrap(err, "read containers logs")
+			return errors.Wrap(err, "container logs streaming")
 		}
 
-		if n == 0 {
+		_, err = demux(stdout, stderr, logs)
+		_ = logs.Close()
+
+		if err == nil {
 			return nil
 		}
+
+		if !follow || ctx.Err() != nil || !isConnectionError(err) {
+			return errors.Wrap(err, "read containers logs")
+		}
+
+		logOptions.Since = since(time.Now())
+
+		if err = cli.awaitDaemon(ctx, err); err != nil {
+			return errors.Wrap(err, "read containers logs")
+		}
 	}
 }
 
@@ -266,12 +811,120 @@ func (cli *dockerClient) FindImageLocal(ctx context.Context, image string) (bool
 	return len(result) != 0, nil
 }
 
+// FindImagesLocal - получает список локальных образов одним запросом и
+// сопоставляет с ним ссылки в нормализованном виде (docker.io/library/name:latest)
+func (cli *dockerClient) FindImagesLocal(ctx context.Context, refs []string) (map[string]bool, error) {
+	list, err := cli.client.ImageList(ctx, types.ImageListOptions{})
+	if err != nil {
+		return nil, errors.Wrap(err, "get local images list")
+	}
+
+	local := make(map[string]struct{}, len(list))
+
+	for _, img := range list {
+		local[img.ID] = struct{}{}
+
+		for _, tag := range img.RepoTags {
+			local[normalizeRef(tag)] = struct{}{}
+		}
+
+		for _, digest := range img.RepoDigests {
+			local[normalizeRef(digest)] = struct{}{}
+		}
+	}
+
+	found := make(map[string]bool, len(refs))
+
+	for _, ref := range refs {
+		_, found[ref] = local[normalizeRef(ref)]
+	}
+
+	return found, nil
+}
+
+func (cli *dockerClient) ImageDigest(ctx context.Context, image string) (string, error) {
+	inspect, _, err := cli.client.ImageInspectWithRaw(ctx, image)
+	if err != nil {
+		return "", errors.Ctx().Str("image", image).Wrap(err, "inspect image")
+	}
+
+	if len(inspect.RepoDigests) != 0 {
+		return inspect.RepoDigests[0], nil
+	}
+
+	return inspect.ID, nil
+}
+
+// ImageInspect - сведения об образе локального стора
+func (cli *dockerClient) ImageInspect(ctx context.Context, image string) (*containers.ImageDetails, error) {
+	inspect, _, err := cli.client.ImageInspectWithRaw(ctx, image)
+	if err != nil {
+		if client.IsErrNotFound(err) {
+			return nil, errors.And(errors.Ctx().Str("image", image).Just(containers.ErrImageNotFound), err)
+		}
+
+		return nil, errors.Ctx().Str("image", image).Wrap(err, "inspect image")
+	}
+
+	details := &containers.ImageDetails{
+		ID:          inspect.ID,
+		RepoTags:    inspect.RepoTags,
+		RepoDigests: inspect.RepoDigests,
+		Created:     parseDockerTime(inspect.Created),
+		Platform:    inspect.Os + "/" + inspect.Architecture,
+		Size:        inspect.Size,
+	}
+
+	if inspect.Variant != "" {
+		details.Platform += "/" + inspect.Variant
+	}
+
+	if inspect.Config != nil {
+		details.Labels = inspect.Config.Labels
+	}
+
+	if len(inspect.RepoDigests) != 0 {
+		details.Attestations = cli.imageAttestations(ctx, inspect.RepoDigests[0], details.Platform)
+	}
+
+	return details, nil
+}
+
 func (cli *dockerClient) PullImage(image string) error {
-	pull, err := cli.client.ImagePull(context.Background(), image, types.ImagePullOptions{})
+	return cli.PullImageWith(context.Background(), containers.PullOptions{Image: image})
+}
+
+func (cli *dockerClient) PullImageWith(ctx context.Context, opts containers.PullOptions) error {
+	image := opts.Image
+
+	if err := containers.Hermetic().CheckPull(image); err != nil {
+		return err
+	}
+
+	if err := cli.guardDisk(ctx); err != nil {
+		return errors.Ctx().Str("image", image).Wrap(err, "pull docker image")
+	}
+
+	pullOpts := types.ImagePullOptions{Platform: opts.Platform}
+
+	if !opts.Auth.Anonymous() {
+		encoded, err := encodeRegistryAuth(opts.Auth)
+		if err != nil {
+			return errors.Ctx().Str("image", image).Wrap(err, "pull docker image")
+		}
+
+		pullOpts.RegistryAuth = encoded
+	}
+
+	pull, err := cli.client.ImagePull(ctx, image, pullOpts)
 	if err != nil {
-		return errors.Wrap(err, "pull docker image")
+		return errors.Ctx().Str("image", image).Str("platform", opts.Platform).Wrap(err, "pull docker image")
 	}
 
+	defer func() {
+		_ = pull.Close()
+	}()
+
 	if err = jsonmessage.DisplayJSONMessagesStream(pull, cli.stdout, 0, false, nil); err != nil {
 		return errors.Wrap(err, "pull image output")
 	}
@@ -279,6 +932,29 @@ func (cli *dockerClient) PullImage(image string) error {
 	return nil
 }
 
+func (cli *dockerClient) PushImage(ctx context.Context, tag string, auth containers.RegistryAuth) error {
+	encoded, err := encodeRegistryAuth(auth)
+	if err != nil {
+		return errors.Ctx().Str("image", tag).Wrap(err, "push docker image")
+	}
+
+	push, err := cli.client.ImagePush(ctx, tag, types.ImagePushOptions{RegistryAuth: encoded})
+	if err != nil {
+		return errors.Ctx().Str("image", tag).Wrap(err, "push docker image")
+	}
+
+	defer func() {
+		_ = push.Close()
+	}()
+
+	// ошибки публикации (в том числе отказ авторизации) приходят в потоке сообщений
+	if err = jsonmessage.DisplayJSONMessagesStream(push, cli.stdout, 0, false, nil); err != nil {
+		return errors.Ctx().Str("image", tag).Wrap(err, "push image output")
+	}
+
+	return nil
+}
+
 func (cli *dockerClient) RemoveImage(image string) {
 	result, err := cli.client.ImageList(
 		context.Background(), types.ImageListOptions{
@@ -333,19 +1009,35 @@ func (cli *dockerClient) BuildImage(data *containers.ImageBuildData) error {
 		}()
 	}
 
-	buildCtx, err := archive.TarWithOptions(data.Root, &archive.TarOptions{})
+	if err := cli.guardDisk(context.Background()); err != nil {
+		return errors.Ctx().Strings("tags", data.Tags).Wrap(err, "build image")
+	}
+
+	labels := cli.labelProject(withSessionLabels(data.Labels))
+
+	if data.Provenance || data.SBOM {
+		return cli.buildAttested(context.Background(), data, labels)
+	}
+
+	buildCtx, err := cli.buildContext(data.Root)
 	if err != nil {
 		return errors.Ctx().Strings("tags", data.Tags).Wrap(err, "create image build context")
 	}
 
+	defer func() {
+		_ = buildCtx.Close()
+	}()
+
 	resp, err := cli.client.ImageBuild(
 		context.Background(), buildCtx, types.ImageBuildOptions{
 			Context:    buildCtx,
 			Dockerfile: data.Dockerfile,
 			NoCache:    data.Nocache,
-			BuildArgs:  data.Args,
+			BuildArgs:  data.BuildArgs(),
 			Tags:       data.Tags,
+			Labels:     labels,
 			Remove:     true,
+			Platform:   data.Platform,
 		},
 	)
 	if err != nil {
@@ -363,16 +1055,23 @@ func (cli *dockerClient) CheckNetwork(nw, cidr string) (dn containers.Network, e
 	dn, err = cli.checkNetworkExist(nw)
 	if err != nil {
 		if errors.Is(err, ErrDockerNetworkNotExist) {
-			var subnet *ipnet.SubnetRange
+			var (
+				subnet      *ipnet.SubnetRange
+				v4, subnet6 *net.IPNet
+			)
+
+			if v4, subnet6, err = containers.SplitSubnets(cidr); err != nil {
+				return nil, errors.Wrap(err, "get subnet from cidr")
+			}
 
-			if cidr != "" {
-				subnet, err = createSubnetRange(cidr)
+			if v4 != nil {
+				subnet, err = createSubnetRange(v4.String())
 				if err != nil {
 					return nil, errors.Wrap(err, "get subnet from cidr")
 				}
 			}
 
-			dn, err = cli.createNetwork(nw, subnet)
+			dn, err = cli.createNetwork(nw, subnet, subnet6)
 			if err != nil {
 				return nil, errors.Wrap(err, "create network")
 			}
@@ -401,84 +1100,126 @@ func (cli *dockerClient) checkNetworkExist(name string) (*dockerNetwork, error)
 	for i := 0; i < len(list); i++ {
 		n := list[i]
 		if n.Name == name {
-			if ipamCfg := n.IPAM.Config; len(ipamCfg) != 0 {
-				subnet, err = createSubnetRange(ipamCfg[0].Subnet)
+			if cfg := ipamConfig(n.IPAM.Config, false); cfg.Subnet != "" {
+				subnet, err = createSubnetRange(cfg.Subnet)
 				if err != nil {
 					return nil, errors.Wrap(err, "get subnet from cidr")
 				}
 			}
 
-			return &dockerNetwork{
-				NetworkResource: &n,
-				client:          cli.client,
-				subnet:          subnet,
-			}, nil
+			return cli.newNetwork(&n, subnet), nil
 		}
 	}
 
 	return nil, ErrDockerNetworkNotExist
 }
 
-func (cli *dockerClient) createNetwork(name string, subnet *ipnet.SubnetRange) (*dockerNetwork, error) {
+// createNetwork - создает сеть; подсеть IPv4 без явного задания выдает демон,
+// подсеть IPv6 - аллокатор клиента, если сети создаются с двумя стеками адресов
+func (cli *dockerClient) createNetwork(
+	name string, subnet *ipnet.SubnetRange, subnet6 *net.IPNet,
+) (_ *dockerNetwork, err error) {
+	ctx := context.Background()
+
+	// выделенные здесь подсети возвращаются в пул, если сеть не создана
+	var allocated []*net.IPNet
+
+	defer func() {
+		if err != nil {
+			for _, a := range allocated {
+				cli.releaseSubnet(a)
+			}
+		}
+	}()
+
+	if subnet6 == nil && cli.netalloc6 != nil {
+		if subnet6, err = cli.netalloc6.Allocate(ctx, cli.subnetPrefix6); err != nil {
+			return nil, errors.Wrap(err, "allocate ipv6 subnet")
+		}
+
+		allocated = append(allocated, subnet6)
+	}
+
+	// подсеть сети проекта выбирается из его части пула, а не демоном
+	if subnet == nil && cli.project != "" {
+		v4, allocErr := cli.NextSubnet()
+		if allocErr != nil {
+			return nil, errors.Wrap(allocErr, "allocate project subnet")
+		}
+
+		allocated = append(allocated, v4)
+
+		if subnet, err = createSubnetRange(v4.String()); err != nil {
+			return nil, errors.Wrap(err, "create network subnet")
+		}
+	}
+
 	opts := types.NetworkCreate{
 		Driver: DefaultNetworkDriver,
+		Labels: cli.labelProject(sessionLabels()),
+		// в герметичном режиме сети создаются без выхода во внешние сети
+		Internal:   containers.Hermetic().Enabled(),
+		EnableIPv6: subnet6 != nil,
 	}
 
+	var configs []network.IPAMConfig
+
 	if subnet != nil {
-		opts.IPAM = &network.IPAM{
-			Config: []network.IPAMConfig{
-				{Subnet: subnet.Subnet()},
-			},
-		}
+		configs = append(configs, network.IPAMConfig{Subnet: subnet.Subnet()})
+	}
+
+	if subnet6 != nil {
+		configs = append(configs, network.IPAMConfig{Subnet: subnet6.String()})
 	}
 
-	resp, err := cli.client.NetworkCreate(context.Background(), name, opts)
+	if len(configs) != 0 {
+		opts.IPAM = &network.IPAM{Config: configs}
+	}
+
+	resp, err := cli.client.NetworkCreate(ctx, name, opts)
 	if err != nil {
 		return nil, errors.Wrap(err, "docker: create network")
 	}
 
-	resource, err := cli.client.NetworkInspect(context.Background(), resp.ID, types.NetworkInspectOptions{})
+	resource, err := cli.inspectNetwork(ctx, resp.ID)
 	if err != nil {
 		return nil, errors.Wrap(err, "inspect created network")
 	}
 
 	if subnet == nil {
-		subnet, err = createSubnetRange(resource.IPAM.Config[0].Subnet)
+		subnet, err = createSubnetRange(ipamConfig(resource.IPAM.Config, false).Subnet)
 		if err != nil {
 			return nil, errors.Wrap(err, "create network subnet")
 		}
 	}
 
-	return &dockerNetwork{NetworkResource: &resource, client: cli.client, subnet: subnet}, nil
+	return cli.newNetwork(&resource, subnet), nil
 }
 
-func (cli *dockerClient) getUsedNetworks(ctx context.Context) (ipnet.NetworksSet, error) {
-	set := make(ipnet.NetworksSet)
-
+func (cli *dockerClient) getUsedNetworks(ctx context.Context) ([]netip.Prefix, error) {
 	list, err := cli.client.NetworkList(ctx, types.NetworkListOptions{})
 	if err != nil {
 		return nil, errors.Wrap(err, "get docker networks list")
 	}
 
+	used := make([]netip.Prefix, 0, len(list))
+
 	for li := 0; li < len(list); li++ {
-		config := list[li].IPAM.Config
-		if len(config) == 0 {
-			continue
-		}
+		for _, config := range list[li].IPAM.Config {
+			if config.Subnet == "" {
+				continue
+			}
 
-		var nw *net.IPNet
+			prefix, parseErr := netip.ParsePrefix(config.Subnet)
+			if parseErr != nil {
+				return nil, errors.Wrap(parseErr, "parse docker network cidr")
+			}
 
-		_, nw, err = net.ParseCIDR(config[0].Subnet)
-		if err != nil {
-			return nil, errors.Wrap(err, "parse docker network cidr")
+			used = append(used, prefix.Masked())
 		}
-
-		sz, _ := nw.Mask.Size()
-
-		set[nw.String()] = sz
 	}
 
-	return set, nil
+	return used, nil
 }
 
 func (cli *dockerClient) logStdout(msg string, args ...any) {
@@ -489,7 +1230,13 @@ func (cli *dockerClient) logStderr(err error, args ...any) {
 	_, _ = fmt.Fprintln(cli.stderr, errors.Formatted(err, args...))
 }
 
+// createSubnetRange - диапазон выдаваемых адресов подсети IPv4; адреса
+// подсетей IPv6 перебором не выдаются (см. dockerNetwork.NextIP6)
 func createSubnetRange(cidr string) (*ipnet.SubnetRange, error) {
+	if prefix, err := netip.ParsePrefix(cidr); err == nil && !prefix.Addr().Is4() {
+		return nil, errors.Ctx().Str("cidr", cidr).Just(containers.ErrInvalidSubnet)
+	}
+
 	subnet, err := ipnet.NewSubnetRage(
 		cidr, func(addr net.IP) bool {
 			if lb := addr.To4()[3]; lb > 2 && lb < 254 {
@@ -506,7 +1253,32 @@ func createSubnetRange(cidr string) (*ipnet.SubnetRange, error) {
 	return subnet, nil
 }
 
-func makeContainerConfig(c containers.Container) *types.ContainerCreateConfig {
+// containerConfig - конфигурация создания контейнера по его описанию
+func (cli *dockerClient) containerConfig(c containers.ExtendedContainer) *types.ContainerCreateConfig {
+	conf := makeContainerConfig(c)
+
+	cli.labelProject(conf.Config.Labels)
+
+	for _, mnt := range conf.HostConfig.Mounts {
+		if mnt.VolumeOptions != nil {
+			cli.labelProject(mnt.VolumeOptions.Labels)
+		}
+	}
+
+	// порты удаленного демона публикуются на всех его интерфейсах: адрес
+	// хоста в спецификации относится к нему, а не к машине теста
+	if cli.remoteHost != "" {
+		for _, binds := range conf.HostConfig.PortBindings {
+			for i := range binds {
+				binds[i].HostIP = ""
+			}
+		}
+	}
+
+	return conf
+}
+
+func makeContainerConfig(c containers.ExtendedContainer) *types.ContainerCreateConfig {
 	// настраиваем контейнер (основные параметры)
 	opts := &types.ContainerCreateConfig{
 		Name: c.GetName(),
@@ -516,16 +1288,29 @@ func makeContainerConfig(c containers.Container) *types.ContainerCreateConfig {
 			Env:          c.GetEnvs(),
 			ExposedPorts: sliceToDockerPortSet(c.ContainerPorts()),
 			Volumes:      containers.SliceToSet(c.GetVolumes()),
+			Labels:       withSessionLabels(c.GetLabels()),
+			User:         c.GetUser(),
+			WorkingDir:   c.GetWorkDir(),
 		},
 		HostConfig: &container.HostConfig{
-			Mounts:       sliceToDockerMounts(c.GetMounts()),
+			Mounts:       append(specsToDockerMounts(c.GetMountSpecs()), volumeMounts(c.GetNamedVolumes())...),
+			Tmpfs:        c.GetTmpfs(),
 			NetworkMode:  "bridge",
 			PortBindings: portMapToDocker(c.PortMap()),
 			Sysctls:      c.GetSysctls(),
 			AutoRemove:   c.GetAutoremove(),
+			Runtime:      c.GetRuntime(),
+			ExtraHosts:   c.GetExtraHosts(),
+			DNS:          c.GetDNS(),
+			DNSSearch:    c.GetDNSSearch(),
 		},
+		Platform: parsePlatform(c.GetPlatform()),
 	}
 
+	// по метке сессии клиент находит и убирает свои контейнеры, метки
+	// контейнера ее не переопределяют
+	opts.Config.Labels[SessionLabel] = sessionID
+
 	if entrypoint := c.GetEntryPoint(); entrypoint != "" {
 		opts.Config.Entrypoint = strings.Split(entrypoint, " ")
 	}
@@ -534,10 +1319,24 @@ func makeContainerConfig(c containers.Container) *types.ContainerCreateConfig {
 		opts.Config.Cmd = append(opts.Config.Cmd, cmds...)
 	}
 
+	opts.HostConfig.Resources = resourcesToDocker(c.GetResources())
+	opts.HostConfig.Resources.Devices = devicesToDocker(c.GetDevices())
+	opts.HostConfig.Resources.DeviceRequests = gpusToDocker(c.GetGPUs())
+
+	if hc := c.GetHealthcheck(); hc != nil {
+		opts.Config.Healthcheck = &container.HealthConfig{
+			Test:        hc.Test,
+			Interval:    hc.Interval,
+			Timeout:     hc.Timeout,
+			StartPeriod: hc.StartPeriod,
+			Retries:     hc.Retries,
+		}
+	}
+
 	// настраиваем соединение с сетью контейнера
 	opts.NetworkingConfig = &network.NetworkingConfig{
 		EndpointsConfig: map[string]*network.EndpointSettings{
-			c.GetNetwork().Name(): {NetworkID: c.GetNetwork().ID()},
+			c.GetNetwork().Name(): {NetworkID: c.GetNetwork().ID(), Aliases: c.GetAliases()},
 		},
 	}
 
@@ -551,8 +1350,68 @@ func makeContainerConfig(c containers.Container) *types.ContainerCreateConfig {
 	return opts
 }
 
+// resourcesToDocker - ограничения ресурсов в представлении демона
+func resourcesToDocker(r containers.Resources) container.Resources {
+	res := container.Resources{
+		Memory:            r.MemoryLimit,
+		MemorySwap:        r.MemorySwap,
+		MemoryReservation: r.MemoryReservation,
+		NanoCPUs:          int64(r.CPUs * 1e9),
+	}
+
+	if r.PidsLimit != 0 {
+		pids := r.PidsLimit
+		res.PidsLimit = &pids
+	}
+
+	return res
+}
+
+func devicesToDocker(devices []containers.Device) []container.DeviceMapping {
+	if len(devices) == 0 {
+		return nil
+	}
+
+	mappings := make([]container.DeviceMapping, 0, len(devices))
+
+	for _, d := range devices {
+		mappings = append(
+			mappings,
+			container.DeviceMapping{
+				PathOnHost:        d.HostPath,
+				PathInContainer:   d.Target(),
+				CgroupPermissions: d.Perms(),
+			},
+		)
+	}
+
+	return mappings
+}
+
+func gpusToDocker(gpus []containers.GPURequest) []container.DeviceRequest {
+	if len(gpus) == 0 {
+		return nil
+	}
+
+	requests := make([]container.DeviceRequest, 0, len(gpus))
+
+	for _, r := range gpus {
+		requests = append(
+			requests,
+			container.DeviceRequest{
+				Driver:       r.Driver,
+				Count:        r.Count,
+				DeviceIDs:    r.DeviceIDs,
+				Capabilities: [][]string{r.Caps()},
+			},
+		)
+	}
+
+	return requests
+}
+
 func sliceToDockerPortSet(slice []containers.Port) nat.PortSet {
-	ports := make(nat.PortSet)
+	ports := make(nat.PortSet, len(slice))
 
 	for i := 0; i < len(slice); i++ {
 		ports[nat.Port(slice[i])] = struct{}{}
@@ -562,38 +1421,119 @@ func sliceToDockerPortSet(slice []containers.Port) nat.PortSet {
 }
 
 func portMapToDocker(in containers.PortMap) nat.PortMap {
-	pm := make(nat.PortMap)
+	pm := make(nat.PortMap, len(in))
+	binds := make([]nat.PortBinding, 0, len(in))
 
 	for port, bindings := range in {
-		pm[nat.Port(port)] = []nat.PortBinding{
-			nat.PortBinding(bindings[0]),
+		if len(bindings) == 0 {
+			continue
 		}
+
+		binds = append(binds, nat.PortBinding(bindings[0]))
+		pm[nat.Port(port)] = binds[len(binds)-1 : len(binds) : len(binds)]
 	}
 
 	return pm
 }
 
+// parsePlatform - разбирает платформу вида os/arch[/variant], пустая строка -
+// платформа демона
+func parsePlatform(platform string) *specs.Platform {
+	if platform == "" {
+		return nil
+	}
+
+	parts := strings.SplitN(platform, "/", 3)
+	p := &specs.Platform{OS: parts[0]}
+
+	if len(parts) > 1 {
+		p.Architecture = parts[1]
+	}
+
+	if len(parts) > 2 {
+		p.Variant = parts[2]
+	}
+
+	return p
+}
+
+// defaultVolumeDriver - драйвер томов демона по умолчанию
+const defaultVolumeDriver = "local"
+
 // nolint
-func sliceToDockerMounts(slice []string) []mount.Mount {
-	var mounts []mount.Mount
-	for _, m := range slice {
-		mnt := strings.Split(m, ":")
-		if len(mnt) == 2 {
-			src, dst := mnt[0], mnt[1]
-			mounts = append(
-				mounts, mount.Mount{
-					Source:   src,
-					Target:   dst,
-					Type:     mount.TypeBind,
-					ReadOnly: false,
-				},
-			)
+// specsToDockerMounts - разделы контейнера; отсутствующий именованный том
+// демон создает с меткой сессии
+func specsToDockerMounts(specs []containers.MountSpec) []mount.Mount {
+	mounts := make([]mount.Mount, 0, len(specs))
+
+	for _, m := range specs {
+		mnt := mount.Mount{
+			Type:     mount.Type(m.MountType()),
+			Source:   m.Source,
+			Target:   m.Target,
+			ReadOnly: m.ReadOnly,
 		}
+
+		switch m.MountType() {
+		case containers.MountBind:
+			if m.Propagation != "" {
+				mnt.BindOptions = &mount.BindOptions{Propagation: mount.Propagation(m.Propagation)}
+			}
+		case containers.MountVolume:
+			mnt.VolumeOptions = &mount.VolumeOptions{Labels: withSessionLabels(nil)}
+
+			// опции без драйвера относятся к драйверу демона по умолчанию
+			if m.VolumeDriver != "" || len(m.VolumeOptions) != 0 {
+				driver := m.VolumeDriver
+				if driver == "" {
+					driver = defaultVolumeDriver
+				}
+
+				mnt.VolumeOptions.DriverConfig = &mount.Driver{Name: driver, Options: m.VolumeOptions}
+			}
+		case containers.MountTmpfs:
+			if m.TmpfsSize != 0 || m.TmpfsMode != 0 {
+				mnt.TmpfsOptions = &mount.TmpfsOptions{SizeBytes: m.TmpfsSize, Mode: m.TmpfsMode}
+			}
+		}
+
+		mounts = append(mounts, mnt)
 	}
 
 	return mounts
 }
 
+// normalizeRef - приводит ссылку на образ к полной форме; в ссылке с дайджестом
+// тег отбрасывается, так как локальный стор хранит дайджесты без тегов
+func normalizeRef(ref string) string {
+	if strings.HasPrefix(ref, "sha256:") {
+		return ref
+	}
+
+	named, err := reference.ParseNormalizedNamed(ref)
+	if err != nil {
+		return ref
+	}
+
+	if canonical, ok := named.(reference.Canonical); ok {
+		if withDigest, digestErr := reference.WithDigest(reference.TrimNamed(named), canonical.Digest()); digestErr == nil {
+			return withDigest.String()
+		}
+	}
+
+	return reference.TagNameOnly(named).String()
+}
+
+func shortID(id string) string {
+	const shortLen = 12
+
+	if len(id) > shortLen {
+		return id[:shortLen]
+	}
+
+	return id
+}
+
 func inContainer() bool {
 	if _, err := os.Stat("/.dockerenv"); err == nil {
 		return true
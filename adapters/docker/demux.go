@@ -0,0 +1,97 @@
+package docker
+
+import (
+	"encoding/binary"
+	"io"
+	"sync"
+
+	"gopkg.in/gomisc/errors.v1"
+)
+
+// Формат мультиплексированного потока docker: заголовок из 8 байт
+// [поток, 0, 0, 0, размер (4 байта big endian)] и полезная нагрузка
+const (
+	frameHeaderLen = 8
+	frameSizeIndex = 4
+
+	streamStdin     = 0
+	streamStdout    = 1
+	streamStderr    = 2
+	streamSystemErr = 3
+
+	demuxBufferSize = 32 << 10
+)
+
+var demuxBuffers = sync.Pool{
+	New: func() any {
+		buf := make([]byte, demuxBufferSize)
+
+		return &buf
+	},
+}
+
+// demux - разбирает мультиплексированный поток логов в stdout и stderr,
+// используя буферы из пула без аллокаций на каждый фрейм (аналог stdcopy.StdCopy)
+func demux(stdout, stderr io.Writer, src io.Reader) (int64, error) {
+	bufPtr := demuxBuffers.Get().(*[]byte)
+	defer demuxBuffers.Put(bufPtr)
+
+	var (
+		buf     = *bufPtr
+		header  [frameHeaderLen]byte
+		written int64
+	)
+
+	for {
+		if _, err := io.ReadFull(src, header[:]); err != nil {
+			if errors.Is(err, io.EOF) {
+				return written, nil
+			}
+
+			return written, errors.Wrap(err, "read frame header")
+		}
+
+		size := int(binary.BigEndian.Uint32(header[frameSizeIndex:]))
+
+		var dst io.Writer
+
+		switch header[0] {
+		case streamStdin, streamStdout:
+			dst = stdout
+		case streamStderr:
+			dst = stderr
+		case streamSystemErr:
+			msg := make([]byte, size)
+			if _, err := io.ReadFull(src, msg); err != nil {
+				return written, errors.Wrap(err, "read system error frame")
+			}
+
+			return written, errors.New("container logs system error: " + string(msg))
+		default:
+			return written, errors.Ctx().Int("stream", int(header[0])).New("unknown log stream")
+		}
+
+		for size > 0 {
+			chunk := buf
+			if size < len(chunk) {
+				chunk = chunk[:size]
+			}
+
+			n, err := io.ReadFull(src, chunk)
+			size -= n
+
+			if dst != nil && n > 0 {
+				nw, wErr := dst.Write(chunk[:n])
+				written += int64(nw)
+
+				if wErr != nil {
+					return written, errors.Wrap(wErr, "write log frame")
+				}
+			}
+
+			if err != nil {
+				return written, errors.Wrap(err, "read frame payload")
+			}
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package docker
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/docker/docker/pkg/stdcopy"
+)
+
+func TestDemux(t *testing.T) {
+	var (
+		src              bytes.Buffer
+		stdout, stderr   bytes.Buffer
+		wantOut, wantErr bytes.Buffer
+		outWriter        = stdcopy.NewStdWriter(&src, stdcopy.Stdout)
+		errWriter        = stdcopy.NewStdWriter(&src, stdcopy.Stderr)
+		large            = bytes.Repeat([]byte("y"), 3*demuxBufferSize+1)
+		wantWritten      = int64(0)
+	)
+
+	for i := 0; i < 100; i++ {
+		line := []byte(fmt.Sprintf("line %d\n", i))
+
+		w, want := outWriter, &wantOut
+		if i%3 == 0 {
+			w, want = errWriter, &wantErr
+		}
+
+		if _, err := w.Write(line); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+
+		want.Write(line)
+		wantWritten += int64(len(line))
+	}
+
+	if _, err := outWriter.Write(large); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+
+	wantOut.Write(large)
+	wantWritten += int64(len(large))
+
+	written, err := demux(&stdout, &stderr, &src)
+	if err != nil {
+		t.Fatalf("demux: %v", err)
+	}
+
+	if written != wantWritten {
+		t.Fatalf("demux: written %d, want %d", written, wantWritten)
+	}
+
+	if !bytes.Equal(stdout.Bytes(), wantOut.Bytes()) {
+		t.Fatalf("stdout mismatch: got %d bytes, want %d", stdout.Len(), wantOut.Len())
+	}
+
+	if !bytes.Equal(stderr.Bytes(), wantErr.Bytes()) {
+		t.Fatalf("stderr mismatch: got %q, want %q", stderr.String(), wantErr.String())
+	}
+}
+
+func TestDemuxSystemError(t *testing.T) {
+	var src bytes.Buffer
+
+	if _, err := stdcopy.NewStdWriter(&src, stdcopy.Systemerr).Write([]byte("boom")); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+
+	if _, err := demux(io.Discard, io.Discard, &src); err == nil {
+		t.Fatal("demux: expected system error")
+	}
+}
+
+func BenchmarkDemux(b *testing.B) {
+	benchmarkLogs(b, func(stdout, stderr io.Writer, src io.Reader) (int64, error) {
+		return demux(stdout, stderr, src)
+	})
+}
+
+func BenchmarkStdCopy(b *testing.B) {
+	benchmarkLogs(b, stdcopy.StdCopy)
+}
+
+// benchmarkLogs - поток из коротких строк, как у fixtures с отладочным логированием
+func benchmarkLogs(b *testing.B, copyFn func(stdout, stderr io.Writer, src io.Reader) (int64, error)) {
+	var stream bytes.Buffer
+
+	outWriter := stdcopy.NewStdWriter(&stream, stdcopy.Stdout)
+	errWriter := stdcopy.NewStdWriter(&stream, stdcopy.Stderr)
+	line := []byte("[2026-10-18 02:10:51,000] DEBUG [kafka] fetch request handled partition=0 offset=42\n")
+
+	for i := 0; i < 10000; i++ {
+		w := outWriter
+		if i%10 == 0 {
+			w = errWriter
+		}
+
+		if _, err := w.Write(line); err != nil {
+			b.Fatalf("Write: %v", err)
+		}
+	}
+
+	data := stream.Bytes()
+
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if _, err := copyFn(io.Discard, io.Discard, bytes.NewReader(data)); err != nil {
+			b.Fatalf("copy: %v", err)
+		}
+	}
+}
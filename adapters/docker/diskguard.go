@@ -0,0 +1,164 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/filters"
+
+	"gopkg.in/gomisc/errors.v1"
+)
+
+const (
+	// SessionLabel - метка, которой помечаются созданные клиентом контейнеры,
+	// сети и собранные образы; значение - идентификатор сессии (процесса)
+	SessionLabel = "gomisc.containers.session"
+	// ErrInsufficientDiskSpace - ошибка "недостаточно свободного места у демона"
+	ErrInsufficientDiskSpace = errors.Const("insufficient daemon disk space")
+	// ErrDaemonRootNotVisible - каталог данных демона не виден с хоста клиента
+	ErrDaemonRootNotVisible = errors.Const("daemon data directory is not visible from the client host")
+)
+
+var sessionID = fmt.Sprintf("%d-%d", os.Getpid(), time.Now().Unix())
+
+// WithDiskGuard - перед скачиванием и сборкой образов проверяет, что в каталоге
+// данных демона свободно не меньше minFree байт. При prune недостаток места
+// сначала пытается устранить удалением неиспользуемых ресурсов текущей
+// сессии (метка SessionLabel). Проверка выполняется только для локального демона,
+// каталог данных которого виден с хоста клиента (см. localRootDir)
+func WithDiskGuard(minFree uint64, prune bool) Option {
+	return func(o *options) {
+		o.minFreeSpace = minFree
+		o.pruneOnLow = prune
+	}
+}
+
+// Session - идентификатор сессии, значение метки SessionLabel созданных клиентом объектов
+func (cli *dockerClient) Session() string {
+	return sessionID
+}
+
+func sessionLabels() map[string]string {
+	return map[string]string{SessionLabel: sessionID}
+}
+
+// withSessionLabels - метки объекта вместе с меткой сессии
+func withSessionLabels(labels map[string]string) map[string]string {
+	result := sessionLabels()
+
+	for k, v := range labels {
+		result[k] = v
+	}
+
+	return result
+}
+
+// guardDisk - проверяет свободное место в каталоге данных демона
+func (cli *dockerClient) guardDisk(ctx context.Context) error {
+	if cli.minFreeSpace == 0 || !isLocalDaemon(cli.client.DaemonHost()) {
+		return nil
+	}
+
+	info, err := cli.daemonInfo(ctx)
+	if err != nil {
+		return errors.Wrap(err, "check disk space")
+	}
+
+	root, ok := cli.localRootDir(info)
+	if !ok {
+		cli.diskWarnOnce.Do(
+			func() {
+				cli.logStderr(
+					errors.Ctx().Str("root-dir", info.DockerRootDir).Just(ErrDaemonRootNotVisible),
+					"skip disk space check",
+				)
+			},
+		)
+
+		return nil
+	}
+
+	free, err := freeSpace(root)
+	if err != nil {
+		cli.logStderr(err, "check disk space")
+
+		return nil
+	}
+
+	if free >= cli.minFreeSpace {
+		return nil
+	}
+
+	if cli.pruneOnLowSpace {
+		var reclaimed uint64
+
+		if reclaimed, err = cli.pruneSession(ctx); err != nil {
+			cli.logStderr(err, "prune session resources")
+		}
+
+		cli.logStdout("Low disk space, pruned session resources: %d MiB reclaimed", reclaimed>>20)
+
+		if free, err = freeSpace(root); err == nil && free >= cli.minFreeSpace {
+			return nil
+		}
+	}
+
+	return errors.Ctx().
+		Str("root-dir", info.DockerRootDir).
+		Uint64("free", free).
+		Uint64("required", cli.minFreeSpace).
+		Just(ErrInsufficientDiskSpace)
+}
+
+// localRootDir - каталог данных демона, если statfs на хосте клиента измеряет
+// именно его: демон локальный, клиент не запущен в контейнере (там виден диск
+// контейнера), демон не в виртуальной машине Docker Desktop и каталог существует
+func (cli *dockerClient) localRootDir(info *types.Info) (string, bool) {
+	if !isLocalDaemon(cli.client.DaemonHost()) || cli.isInContainer || isDockerDesktop(info) {
+		return "", false
+	}
+
+	if _, err := os.Stat(info.DockerRootDir); err != nil {
+		return "", false
+	}
+
+	return info.DockerRootDir, true
+}
+
+// isDockerDesktop - демон Docker Desktop, работающий в виртуальной машине
+func isDockerDesktop(info *types.Info) bool {
+	return strings.Contains(info.OperatingSystem, "Docker Desktop") || info.Name == "docker-desktop"
+}
+
+// pruneSession - удаляет остановленные контейнеры, неиспользуемые сети и образы
+// текущей сессии клиента. Кеш сборки не удаляется: записи кеша не несут меток
+// сессии, а без фильтра очистка затронула бы кеш всех пользователей демона
+func (cli *dockerClient) pruneSession(ctx context.Context) (uint64, error) {
+	label := filters.NewArgs(filters.Arg("label", SessionLabel+"="+sessionID))
+
+	var reclaimed uint64
+
+	cont, err := cli.client.ContainersPrune(ctx, label)
+	if err != nil {
+		return reclaimed, errors.Wrap(err, "prune containers")
+	}
+
+	reclaimed += cont.SpaceReclaimed
+
+	if _, err = cli.client.NetworksPrune(ctx, label); err != nil {
+		return reclaimed, errors.Wrap(err, "prune networks")
+	}
+
+	images, err := cli.client.ImagesPrune(
+		ctx, filters.NewArgs(filters.Arg("label", SessionLabel+"="+sessionID), filters.Arg("dangling", "false")),
+	)
+	if err != nil {
+		return reclaimed, errors.Wrap(err, "prune images")
+	}
+
+	return reclaimed + images.SpaceReclaimed, nil
+}
@@ -0,0 +1,11 @@
+//go:build !linux && !darwin
+
+package docker
+
+import (
+	"gopkg.in/gomisc/errors.v1"
+)
+
+func freeSpace(string) (uint64, error) {
+	return 0, errors.New("free space check is not implemented for this platform")
+}
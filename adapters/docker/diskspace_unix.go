@@ -0,0 +1,19 @@
+//go:build linux || darwin
+
+package docker
+
+import (
+	"syscall"
+
+	"gopkg.in/gomisc/errors.v1"
+)
+
+func freeSpace(path string) (uint64, error) {
+	var st syscall.Statfs_t
+
+	if err := syscall.Statfs(path, &st); err != nil {
+		return 0, errors.Ctx().Str("path", path).Wrap(err, "statfs")
+	}
+
+	return uint64(st.Bavail) * uint64(st.Bsize), nil
+}
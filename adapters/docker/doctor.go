@@ -0,0 +1,112 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/docker/docker/client"
+
+	"gopkg.in/gomisc/containers.v1"
+)
+
+const (
+	minFreeSpace   = 5 << 30
+	minAPIVersion  = "1.40"
+	rootlessOption = "name=rootless"
+)
+
+var (
+	_ containers.ExtendedClient = (*dockerClient)(nil)
+	_ containers.Diagnoser      = (*dockerClient)(nil)
+
+	_ containers.RegistryNetwork = (*dockerNetwork)(nil)
+)
+
+// Diagnose - проверяет доступность демона, версию API, свободное место
+// в каталоге данных докера и ограничения rootless режима
+func (cli *dockerClient) Diagnose(ctx context.Context) []containers.DoctorCheck {
+	ping := containers.DoctorCheck{Name: "daemon reachable", Status: containers.CheckOK}
+
+	if _, err := cli.client.Ping(ctx); err != nil {
+		ping.Status, ping.Message = containers.CheckFail, err.Error()
+
+		return []containers.DoctorCheck{ping}
+	}
+
+	ping.Message = cli.client.DaemonHost()
+
+	checks := []containers.DoctorCheck{ping}
+
+	version := containers.DoctorCheck{Name: "api version", Status: containers.CheckOK}
+
+	if v, err := cli.client.ServerVersion(ctx); err != nil {
+		version.Status, version.Message = containers.CheckWarn, err.Error()
+	} else {
+		version.Message = fmt.Sprintf("server %s, api %s", v.Version, v.APIVersion)
+
+		if versionLess(v.APIVersion, minAPIVersion) {
+			version.Status = containers.CheckWarn
+			version.Message += ", minimal supported api " + minAPIVersion
+		}
+	}
+
+	checks = append(checks, version)
+
+	info, err := cli.daemonInfo(ctx)
+	if err != nil {
+		return append(
+			checks, containers.DoctorCheck{
+				Name:    "daemon info",
+				Status:  containers.CheckFail,
+				Message: err.Error(),
+			},
+		)
+	}
+
+	disk := containers.DoctorCheck{Name: "disk space", Status: containers.CheckOK}
+
+	root, visible := cli.localRootDir(info)
+
+	if !isLocalDaemon(cli.client.DaemonHost()) {
+		disk.Status, disk.Message = containers.CheckWarn, "remote daemon, free space unknown"
+	} else if !visible {
+		disk.Status = containers.CheckWarn
+		disk.Message = info.DockerRootDir + " is not visible from the client host, free space unknown"
+	} else if free, freeErr := freeSpace(root); freeErr != nil {
+		disk.Status, disk.Message = containers.CheckWarn, freeErr.Error()
+	} else {
+		disk.Message = fmt.Sprintf("%d MiB free in %s", free>>20, root)
+
+		if free < minFreeSpace {
+			disk.Status = containers.CheckWarn
+		}
+	}
+
+	checks = append(checks, disk)
+
+	rootless := containers.DoctorCheck{Name: "rootless", Status: containers.CheckOK, Message: "no"}
+
+	for _, opt := range info.SecurityOptions {
+		if strings.Contains(opt, rootlessOption) {
+			rootless.Status = containers.CheckWarn
+			rootless.Message = "rootless daemon: sysctls and privileged ports may be unavailable"
+		}
+	}
+
+	return append(checks, rootless, containers.CheckReservedNetworks(ctx, cli, reservedNetworksVar))
+}
+
+func isLocalDaemon(host string) bool {
+	return host == "" || strings.HasPrefix(host, "unix://") || strings.HasPrefix(host, "npipe://") ||
+		host == client.DefaultDockerHost
+}
+
+func versionLess(v, than string) bool {
+	var vMajor, vMinor, tMajor, tMinor int
+
+	_, _ = fmt.Sscanf(v, "%d.%d", &vMajor, &vMinor)
+	_, _ = fmt.Sscanf(than, "%d.%d", &tMajor, &tMinor)
+
+	return vMajor < tMajor || (vMajor == tMajor && vMinor < tMinor)
+}
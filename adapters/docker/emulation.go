@@ -0,0 +1,266 @@
+package docker
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+
+	"gopkg.in/gomisc/containers.v1"
+	"gopkg.in/gomisc/errors.v1"
+)
+
+const (
+	// ErrInvalidPlatform - платформа не в форме os/arch[/variant]
+	ErrInvalidPlatform = errors.Const("invalid platform")
+
+	// binfmtMiscDir - записи эмуляторов ядра
+	binfmtMiscDir = "/proc/sys/fs/binfmt_misc"
+)
+
+// qemuArch - архитектуры GOARCH по именам эмуляторов qemu, отличающимся от них
+var qemuArch = map[string]string{
+	"aarch64":     "arm64",
+	"x86_64":      "amd64",
+	"i386":        "386",
+	"mips64el":    "mips64le",
+	"loongarch64": "loong64",
+}
+
+// platformSpec - платформа образа
+type platformSpec struct {
+	OS           string
+	Architecture string
+	Variant      string
+}
+
+// WithEmulationInstall - регистрировать недостающую эмуляцию платформ
+// привилегированным контейнером образа DefaultBinfmtImage вместо ошибки
+// ErrEmulationUnavailable. Регистрация действует на все ядро хоста демона
+func WithEmulationInstall() Option {
+	return func(o *options) {
+		o.installEmulation = true
+	}
+}
+
+// EnsureEmulation - проверяет, что демон исполняет образы платформы platform,
+// и при WithEmulationInstall регистрирует эмуляцию; подтвержденные платформы
+// запоминаются на время жизни клиента. Проверка читает binfmt_misc ядра и
+// контейнеров не запускает
+func (cli *dockerClient) EnsureEmulation(ctx context.Context, platform string) error {
+	info, err := cli.Info(ctx)
+	if err != nil {
+		return errors.Wrap(err, "check platform emulation")
+	}
+
+	spec, err := normalizePlatform(platform)
+	if err != nil {
+		return err
+	}
+
+	platform = spec.String()
+
+	if !containers.NeedsEmulation(info, platform) {
+		return nil
+	}
+
+	if _, ok := cli.emulated.Load(platform); ok {
+		return nil
+	}
+
+	supported, known := cli.emulationSupported(info, spec)
+
+	if !supported && cli.installEmulation {
+		if _, err = cli.runBinfmt(ctx, "--install", spec.Architecture); err != nil {
+			return errors.Ctx().Str("platform", platform).Wrap(err, "install platform emulation")
+		}
+
+		supported, known = cli.emulationSupported(info, spec)
+	}
+
+	// без доступа к binfmt_misc ядра демона (удаленный демон) проверка не
+	// блокирует запуск: неисполнимый образ завершится ошибкой exec format error
+	if known && !supported {
+		return errors.Ctx().
+			Str("platform", platform).
+			Str("daemon-architecture", info.Architecture).
+			Str("hint", "docker run --privileged --rm "+containers.DefaultBinfmtImage+" --install all").
+			Just(containers.ErrEmulationUnavailable)
+	}
+
+	cli.emulated.Store(platform, struct{}{})
+
+	return nil
+}
+
+// emulationSupported - ядро демона исполняет образы платформы spec через
+// binfmt_misc; known - ядро доступно для проверки (локальный демон).
+// Docker Desktop регистрирует эмуляцию сам
+func (cli *dockerClient) emulationSupported(info *containers.DaemonInfo, spec platformSpec) (supported, known bool) {
+	if strings.Contains(info.OperatingSystem, "Docker Desktop") {
+		return true, true
+	}
+
+	if cli.remoteHost != "" || runtime.GOOS != "linux" {
+		return false, false
+	}
+
+	entries, err := os.ReadDir(binfmtMiscDir)
+	if err != nil {
+		return false, false
+	}
+
+	for _, e := range entries {
+		// эмулятор архитектуры исполняет все ее варианты
+		p, ok := binfmtPlatform(e.Name())
+		if !ok || p.OS != spec.OS || p.Architecture != spec.Architecture {
+			continue
+		}
+
+		status, err := os.ReadFile(filepath.Join(binfmtMiscDir, e.Name()))
+		if err == nil && strings.HasPrefix(string(status), "enabled") {
+			return true, true
+		}
+	}
+
+	return false, true
+}
+
+// binfmtPlatform - платформа, образы которой исполняет эмулятор записи
+// binfmt_misc с именем name (qemu-aarch64, etc)
+func binfmtPlatform(name string) (platformSpec, bool) {
+	arch, ok := strings.CutPrefix(name, "qemu-")
+	if !ok {
+		return platformSpec{}, false
+	}
+
+	arch = strings.TrimSuffix(arch, "-static")
+
+	if mapped, found := qemuArch[arch]; found {
+		arch = mapped
+	}
+
+	spec, err := normalizePlatform("linux/" + arch)
+	if err != nil {
+		return platformSpec{}, false
+	}
+
+	return spec, true
+}
+
+// normalizePlatform - разбирает платформу os/arch[/variant] (arch без os -
+// платформа linux) и приводит ее к виду, принятому в реестрах образов:
+// архитектура в терминах GOARCH, arm64 без варианта v8, arm с вариантом
+// (v7 по умолчанию)
+func normalizePlatform(platform string) (platformSpec, error) {
+	parts := strings.Split(strings.ToLower(platform), "/")
+	if len(parts) == 1 {
+		parts = append([]string{"linux"}, parts...)
+	}
+
+	valid := len(parts) <= 3
+
+	for _, part := range parts {
+		valid = valid && part != ""
+	}
+
+	if !valid {
+		return platformSpec{}, errors.Ctx().Str("platform", platform).Just(ErrInvalidPlatform)
+	}
+
+	spec := platformSpec{OS: parts[0], Architecture: containers.NormalizeArch(parts[1])}
+
+	if len(parts) == 3 {
+		spec.Variant = parts[2]
+	}
+
+	switch {
+	case spec.Variant != "":
+	case parts[1] == "armv6l" || parts[1] == "armel":
+		spec.Variant = "v6"
+	case spec.Architecture == "arm":
+		spec.Variant = "v7"
+	}
+
+	if spec.Architecture == "arm64" && spec.Variant == "v8" {
+		spec.Variant = ""
+	}
+
+	return spec, nil
+}
+
+// String - платформа в форме os/arch[/variant]
+func (p platformSpec) String() string {
+	if p.Variant == "" {
+		return p.OS + "/" + p.Architecture
+	}
+
+	return p.OS + "/" + p.Architecture + "/" + p.Variant
+}
+
+// runBinfmt - выполняет привилегированный контейнер образа binfmt с
+// аргументами args и возвращает его стандартный вывод; используется только
+// для регистрации эмуляции, разрешенной WithEmulationInstall
+func (cli *dockerClient) runBinfmt(ctx context.Context, args ...string) ([]byte, error) {
+	image := containers.ResolveImage(containers.DefaultBinfmtImage)
+
+	exist, err := cli.FindImageLocal(ctx, image)
+	if err != nil {
+		return nil, err
+	}
+
+	if !exist {
+		if err = cli.PullImageWith(ctx, containers.PullOptions{Image: image}); err != nil {
+			return nil, err
+		}
+	}
+
+	resp, err := cli.client.ContainerCreate(
+		ctx,
+		&container.Config{Image: image, Cmd: args, Labels: cli.labelProject(sessionLabels())},
+		&container.HostConfig{Privileged: true},
+		nil, nil, "",
+	)
+	if err != nil {
+		return nil, errors.Ctx().Str("image", image).Wrap(err, "create binfmt container")
+	}
+
+	defer func() {
+		_ = cli.ContainerRemove(context.Background(), resp.ID)
+	}()
+
+	if err = cli.client.ContainerStart(ctx, resp.ID, types.ContainerStartOptions{}); err != nil {
+		return nil, errors.Ctx().Str("image", image).Wrap(err, "start binfmt container")
+	}
+
+	statusCh, errCh := cli.ContainerWait(ctx, resp.ID)
+
+	var stdout, stderr bytes.Buffer
+
+	select {
+	case status := <-statusCh:
+		if logsErr := cli.StreamLogs(ctx, resp.ID, &stderr, &stdout, false); logsErr != nil {
+			return nil, logsErr
+		}
+
+		if status.Error != nil {
+			return nil, errors.Wrap(status.Error, "run binfmt container")
+		}
+
+		if status.StatusCode != 0 {
+			return nil, errors.Ctx().
+				Int64("exit-code", status.StatusCode).
+				Str("output", strings.TrimSpace(stderr.String())).
+				New("binfmt container failed")
+		}
+	case err = <-errCh:
+		return nil, errors.Wrap(err, "wait binfmt container")
+	}
+
+	return stdout.Bytes(), nil
+}
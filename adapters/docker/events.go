@@ -0,0 +1,91 @@
+package docker
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/events"
+	"github.com/docker/docker/api/types/filters"
+
+	"gopkg.in/gomisc/containers.v1"
+)
+
+// Events - транслирует поток событий демона; без фильтра по типу
+// отбираются события контейнеров и сетей. При обрыве соединения поток
+// открывается заново после возвращения демона с момента последнего
+// переданного события, так что события за время перезапуска не теряются
+func (cli *dockerClient) Events(ctx context.Context, filter ...containers.EventFilter) <-chan containers.ContainerEvent {
+	args := filters.NewArgs()
+	typed := false
+
+	for _, f := range filter {
+		args.Add(f.Key, f.Value)
+		typed = typed || f.Key == "type"
+	}
+
+	if !typed {
+		args.Add("type", events.ContainerEventType)
+		args.Add("type", events.NetworkEventType)
+	}
+
+	options := types.EventsOptions{Filters: args}
+	msgCh, errCh := cli.client.Events(ctx, options)
+	eventCh := make(chan containers.ContainerEvent)
+	subscribed := time.Now()
+
+	go func() {
+		defer close(eventCh)
+
+		var last *events.Message
+
+		for {
+			select {
+			case msg := <-msgCh:
+				if sameEvent(&msg, last) {
+					continue
+				}
+
+				last = &msg
+
+				select {
+				case eventCh <- containerEvent(&msg):
+				case <-ctx.Done():
+					return
+				}
+			case err := <-errCh:
+				if ctx.Err() != nil || !isConnectionError(err) || cli.awaitDaemon(ctx, err) != nil {
+					return
+				}
+
+				options.Since = since(subscribed)
+				if last != nil {
+					options.Since = since(time.Unix(0, last.TimeNano))
+				}
+
+				msgCh, errCh = cli.client.Events(ctx, options)
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return eventCh
+}
+
+// containerEvent - разделяет составные действия демона ("health_status: healthy",
+// "exec_start: sh -c ...") на действие и уточнение
+func containerEvent(msg *events.Message) containers.ContainerEvent {
+	action, detail, _ := strings.Cut(msg.Action, ":")
+
+	return containers.ContainerEvent{
+		Type:       msg.Type,
+		Action:     action,
+		Detail:     strings.TrimSpace(detail),
+		ID:         msg.Actor.ID,
+		Name:       msg.Actor.Attributes["name"],
+		Attributes: msg.Actor.Attributes,
+		Time:       time.Unix(0, msg.TimeNano),
+	}
+}
@@ -0,0 +1,212 @@
+package docker
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/events"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/client"
+
+	"gopkg.in/gomisc/errors.v1"
+)
+
+var _ io.Closer = (*dockerClient)(nil)
+
+// inspectCache - кеш результатов NetworkInspect по идентификатору. Записи
+// сбрасываются по событиям демона, относящимся к сети, а при обрыве потока
+// событий кеш очищается целиком. Наблюдение за событиями запускается при
+// первом обращении и останавливается close, после которого кеш не используется
+type inspectCache struct {
+	client client.APIClient
+	ctx    context.Context
+	cancel context.CancelFunc
+	once   sync.Once
+
+	mu       sync.Mutex
+	networks map[string]types.NetworkResource
+	// gen - счетчик сбросов: ответ, запрошенный до сброса, в кеш не попадает
+	gen uint64
+}
+
+// WithInspectCache - кеширует результаты inspect сетей с их сбросом по событиям
+// демона. Inspect контейнеров не кешируется: все его вызовы читают состояние
+// процесса, которое меняется без событий. Кеш наблюдает за событиями демона до
+// вызова Close клиента (клиент реализует io.Closer)
+func WithInspectCache() Option {
+	return func(o *options) {
+		o.inspectCache = true
+	}
+}
+
+func newInspectCache(cli client.APIClient) *inspectCache {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &inspectCache{
+		client:   cli,
+		ctx:      ctx,
+		cancel:   cancel,
+		networks: make(map[string]types.NetworkResource),
+	}
+}
+
+// Close - останавливает фоновые наблюдатели клиента: кеш inspect и кеши
+// адресов сетей
+func (cli *dockerClient) Close() error {
+	if cli.inspect != nil {
+		cli.inspect.close()
+	}
+
+	cli.networksMu.Lock()
+	for _, nw := range cli.networks {
+		nw.close()
+	}
+	cli.networksMu.Unlock()
+
+	return nil
+}
+
+// inspectContainer - ContainerInspect с повтором при временной недоступности демона
+func (cli *dockerClient) inspectContainer(ctx context.Context, id string) (cont types.ContainerJSON, err error) {
+	err = cli.retry(
+		ctx, func(ctx context.Context) (err error) {
+			cont, err = cli.client.ContainerInspect(ctx, id)
+
+			return err
+		},
+	)
+	if err != nil {
+		return cont, errors.Ctx().Str("container-id", shortID(id)).Wrap(err, "inspect container")
+	}
+
+	return cont, nil
+}
+
+// inspectNetwork - NetworkInspect с повтором при временной недоступности
+// демона, при WithInspectCache ответ берется из кеша
+func (cli *dockerClient) inspectNetwork(ctx context.Context, id string) (resource types.NetworkResource, err error) {
+	var gen uint64
+
+	if cli.inspect != nil {
+		var ok bool
+
+		if resource, gen, ok = cli.inspect.network(id); ok {
+			return resource, nil
+		}
+	}
+
+	err = cli.retry(
+		ctx, func(ctx context.Context) (err error) {
+			resource, err = cli.client.NetworkInspect(ctx, id, types.NetworkInspectOptions{})
+
+			return err
+		},
+	)
+	if err != nil {
+		return resource, errors.Ctx().Str("network-id", id).Wrap(err, "inspect network")
+	}
+
+	if cli.inspect != nil {
+		cli.inspect.storeNetwork(id, resource, gen)
+	}
+
+	return resource, nil
+}
+
+// invalidateInspect - сбрасывает запись сети, изменение которой уже
+// известно вызывающему коду (например, после подключения контейнера)
+func (cli *dockerClient) invalidateInspect(id string) {
+	if cli.inspect != nil {
+		cli.inspect.invalidate(id)
+	}
+}
+
+// network - запись кеша сети и текущий счетчик сбросов для storeNetwork
+func (ic *inspectCache) network(id string) (types.NetworkResource, uint64, bool) {
+	open := ic.start()
+
+	ic.mu.Lock()
+	defer ic.mu.Unlock()
+
+	resource, ok := ic.networks[id]
+
+	return resource, ic.gen, ok && open
+}
+
+// storeNetwork - сохраняет ответ, если с момента network кеш не сбрасывался
+func (ic *inspectCache) storeNetwork(id string, resource types.NetworkResource, gen uint64) {
+	if !ic.start() {
+		return
+	}
+
+	ic.mu.Lock()
+	if ic.gen == gen {
+		ic.networks[id] = resource
+	}
+	ic.mu.Unlock()
+}
+
+func (ic *inspectCache) invalidate(id string) {
+	ic.mu.Lock()
+	delete(ic.networks, id)
+	ic.gen++
+	ic.mu.Unlock()
+}
+
+func (ic *inspectCache) reset() {
+	ic.mu.Lock()
+	ic.networks = make(map[string]types.NetworkResource)
+	ic.gen++
+	ic.mu.Unlock()
+}
+
+// start - запускает наблюдение за событиями, если оно еще не запущено;
+// false - кеш закрыт, записи без наблюдения могли бы устареть
+func (ic *inspectCache) start() bool {
+	ic.once.Do(func() { go ic.watch() })
+
+	return ic.ctx.Err() == nil
+}
+
+// close - останавливает наблюдение и очищает кеш
+func (ic *inspectCache) close() {
+	ic.once.Do(func() {})
+	ic.cancel()
+	ic.reset()
+}
+
+// watch - сбрасывает записи по событиям сетей до close; после
+// обрыва поток открывается заново с увеличивающейся задержкой, пока демон
+// недоступен
+func (ic *inspectCache) watch() {
+	for attempt := 0; ; attempt++ {
+		msgCh, errCh := ic.client.Events(
+			ic.ctx, types.EventsOptions{
+				Filters: filters.NewArgs(filters.Arg("type", events.NetworkEventType)),
+			},
+		)
+
+	stream:
+		for {
+			select {
+			case msg := <-msgCh:
+				attempt = 0
+
+				ic.invalidate(msg.Actor.ID)
+			case <-errCh:
+				break stream
+			}
+		}
+
+		ic.reset()
+
+		select {
+		case <-ic.ctx.Done():
+			return
+		case <-time.After(reconnectBackoff.Delay(attempt)):
+		}
+	}
+}
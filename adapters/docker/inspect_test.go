@@ -0,0 +1,103 @@
+package docker
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/events"
+	"github.com/docker/docker/client"
+	"go.uber.org/goleak"
+)
+
+// eventsClient - APIClient, отвечающий только на Events и NetworkInspect
+type eventsClient struct {
+	client.APIClient
+
+	events   chan events.Message
+	inspects atomic.Int32
+}
+
+func (c *eventsClient) Events(ctx context.Context, _ types.EventsOptions) (<-chan events.Message, <-chan error) {
+	errCh := make(chan error, 1)
+
+	go func() {
+		<-ctx.Done()
+		errCh <- ctx.Err()
+	}()
+
+	return c.events, errCh
+}
+
+func (c *eventsClient) NetworkInspect(
+	_ context.Context, id string, _ types.NetworkInspectOptions,
+) (types.NetworkResource, error) {
+	c.inspects.Add(1)
+
+	return types.NetworkResource{ID: id}, nil
+}
+
+func TestInspectCache(t *testing.T) {
+	defer goleak.VerifyNone(t, goleak.IgnoreCurrent())
+
+	api := &eventsClient{events: make(chan events.Message)}
+	cli := &dockerClient{client: api, inspect: newInspectCache(api)}
+	ctx := context.Background()
+
+	inspect := func(want int32) {
+		t.Helper()
+
+		if _, err := cli.inspectNetwork(ctx, "net"); err != nil {
+			t.Fatalf("inspectNetwork: %v", err)
+		}
+
+		if got := api.inspects.Load(); got != want {
+			t.Fatalf("NetworkInspect calls: got %d, want %d", got, want)
+		}
+	}
+
+	inspect(1)
+	inspect(1)
+
+	api.events <- events.Message{Type: events.NetworkEventType, Actor: events.Actor{ID: "net"}}
+
+	// событие обрабатывается асинхронно
+	deadline := time.Now().Add(time.Second)
+	for {
+		if _, _, ok := cli.inspect.network("net"); !ok || time.Now().After(deadline) {
+			break
+		}
+
+		time.Sleep(time.Millisecond)
+	}
+
+	inspect(2)
+
+	cli.invalidateInspect("net")
+	inspect(3)
+
+	if err := cli.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	// закрытый кеш не используется
+	inspect(4)
+	inspect(5)
+}
+
+func TestInspectCacheDisabled(t *testing.T) {
+	api := &eventsClient{events: make(chan events.Message)}
+	cli := &dockerClient{client: api}
+
+	for i := int32(1); i <= 2; i++ {
+		if _, err := cli.inspectNetwork(context.Background(), "net"); err != nil {
+			t.Fatalf("inspectNetwork: %v", err)
+		}
+
+		if got := api.inspects.Load(); got != i {
+			t.Fatalf("NetworkInspect calls: got %d, want %d", got, i)
+		}
+	}
+}
@@ -0,0 +1,224 @@
+package docker
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/client"
+
+	"gopkg.in/gomisc/containers.v1"
+)
+
+const minRateFactor = 0.1
+
+type (
+	// LimiterStats - метрики ограничителя запросов к демону для подбора его параметров
+	LimiterStats struct {
+		// Requests - число запросов, прошедших через ограничитель
+		Requests uint64
+		// Waiting - число запросов, ожидающих в очереди
+		Waiting int
+		// TotalWait - суммарное время ожидания запросов в очереди
+		TotalWait time.Duration
+		// MaxWait - максимальное время ожидания запроса в очереди
+		MaxWait time.Duration
+		// Rate - текущая допустимая частота запросов (0 - без ограничения)
+		Rate float64
+	}
+
+	// limiter - ограничитель конкурентности и адаптивный (AIMD) ограничитель
+	// частоты запросов к демону
+	limiter struct {
+		next http.RoundTripper
+		sem  chan struct{}
+
+		mu      sync.Mutex
+		maxRate float64
+		rate    float64
+		last    time.Time
+		stats   LimiterStats
+	}
+)
+
+// Stats - возвращает метрики ограничителя запросов docker клиента,
+// false если клиент создан без ограничений
+func Stats(cli containers.Client) (LimiterStats, bool) {
+	dc, ok := cli.(*dockerClient)
+	if !ok || dc.limiter == nil {
+		return LimiterStats{}, false
+	}
+
+	return dc.limiter.Stats(), true
+}
+
+func newLimiter(maxConcurrency int, rate float64) *limiter {
+	l := &limiter{maxRate: rate, rate: rate}
+
+	if maxConcurrency > 0 {
+		l.sem = make(chan struct{}, maxConcurrency)
+	}
+
+	return l
+}
+
+// clientOpt - опция docker клиента, оборачивающая его транспорт ограничителем
+func (l *limiter) clientOpt() client.Opt {
+	return func(c *client.Client) error {
+		hc := c.HTTPClient()
+
+		l.next = hc.Transport
+		if l.next == nil {
+			l.next = http.DefaultTransport
+		}
+
+		hc.Transport = l
+
+		return client.WithHTTPClient(hc)(c)
+	}
+}
+
+func (l *limiter) RoundTrip(req *http.Request) (*http.Response, error) {
+	if isStreaming(req) {
+		return l.next.RoundTrip(req)
+	}
+
+	start := time.Now()
+
+	l.mu.Lock()
+	l.stats.Waiting++
+	l.mu.Unlock()
+
+	if l.sem != nil {
+		select {
+		case l.sem <- struct{}{}:
+		case <-req.Context().Done():
+			l.done(start)
+
+			return nil, req.Context().Err()
+		}
+
+		defer func() {
+			<-l.sem
+		}()
+	}
+
+	if err := l.throttle(req); err != nil {
+		l.done(start)
+
+		return nil, err
+	}
+
+	l.done(start)
+
+	resp, err := l.next.RoundTrip(req)
+	l.adapt(resp, err)
+
+	return resp, err
+}
+
+// Stats - возвращает снимок метрик ограничителя
+func (l *limiter) Stats() LimiterStats {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	stats := l.stats
+	stats.Rate = l.rate
+
+	return stats
+}
+
+func (l *limiter) throttle(req *http.Request) error {
+	if l.maxRate <= 0 {
+		return nil
+	}
+
+	l.mu.Lock()
+	interval := time.Duration(float64(time.Second) / l.rate)
+	slot := l.last.Add(interval)
+	now := time.Now()
+
+	if slot.Before(now) {
+		slot = now
+	}
+
+	l.last = slot
+	l.mu.Unlock()
+
+	if delay := time.Until(slot); delay > 0 {
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+
+		select {
+		case <-timer.C:
+		case <-req.Context().Done():
+			return req.Context().Err()
+		}
+	}
+
+	return nil
+}
+
+func (l *limiter) done(start time.Time) {
+	wait := time.Since(start)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.stats.Waiting--
+	l.stats.Requests++
+	l.stats.TotalWait += wait
+
+	if wait > l.stats.MaxWait {
+		l.stats.MaxWait = wait
+	}
+}
+
+// adapt - мультипликативно снижает частоту при перегрузке демона
+// и аддитивно восстанавливает ее при успешных ответах
+func (l *limiter) adapt(resp *http.Response, err error) {
+	if l.maxRate <= 0 {
+		return
+	}
+
+	overloaded := err != nil ||
+		resp.StatusCode == http.StatusTooManyRequests ||
+		resp.StatusCode == http.StatusServiceUnavailable
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if overloaded {
+		l.rate /= 2
+		if floor := l.maxRate * minRateFactor; l.rate < floor {
+			l.rate = floor
+		}
+
+		return
+	}
+
+	if l.rate += l.maxRate * minRateFactor; l.rate > l.maxRate {
+		l.rate = l.maxRate
+	}
+}
+
+// isStreaming - признак долгоживущего запроса, который не должен занимать
+// слот ограничителя
+func isStreaming(req *http.Request) bool {
+	path := req.URL.Path
+
+	switch {
+	case strings.HasSuffix(path, "/events"),
+		strings.HasSuffix(path, "/wait"),
+		strings.HasSuffix(path, "/attach"),
+		strings.Contains(path, "/exec/") && strings.HasSuffix(path, "/start"):
+		return true
+	case strings.HasSuffix(path, "/logs"), strings.HasSuffix(path, "/stats"):
+		q := req.URL.Query()
+
+		return q.Get("follow") == "1" || q.Get("stream") == "1" || q.Get("stream") == "true"
+	default:
+		return false
+	}
+}
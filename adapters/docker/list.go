@@ -0,0 +1,70 @@
+package docker
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/filters"
+
+	"gopkg.in/gomisc/containers.v1"
+	"gopkg.in/gomisc/errors.v1"
+)
+
+// ContainerList - отбирает контейнеры на стороне демона; фильтр имени демона
+// ищет подстроку, поэтому префикс дополнительно проверяется на клиенте
+func (cli *dockerClient) ContainerList(ctx context.Context, filter containers.ListFilter) ([]containers.ContainerSummary, error) {
+	args := filters.NewArgs()
+
+	for k, v := range filter.Labels {
+		if v == "" {
+			args.Add("label", k)
+		} else {
+			args.Add("label", k+"="+v)
+		}
+	}
+
+	if filter.NamePrefix != "" {
+		args.Add("name", filter.NamePrefix)
+	}
+
+	for _, status := range filter.Status {
+		args.Add("status", status)
+	}
+
+	var list []types.Container
+
+	err := cli.retry(
+		ctx, func(ctx context.Context) (err error) {
+			list, err = cli.client.ContainerList(ctx, types.ContainerListOptions{All: true, Filters: args})
+
+			return err
+		},
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "docker container list")
+	}
+
+	result := make([]containers.ContainerSummary, 0, len(list))
+
+	for i := range list {
+		summary := containers.ContainerSummary{
+			ID:      list[i].ID,
+			Image:   list[i].Image,
+			Status:  list[i].State,
+			Labels:  list[i].Labels,
+			Created: time.Unix(list[i].Created, 0),
+		}
+
+		if len(list[i].Names) != 0 {
+			summary.Name = strings.TrimPrefix(list[i].Names[0], "/")
+		}
+
+		if filter.Match(&summary) {
+			result = append(result, summary)
+		}
+	}
+
+	return result, nil
+}
@@ -0,0 +1,33 @@
+package docker
+
+import (
+	"testing"
+
+	"gopkg.in/gomisc/containers.v1"
+)
+
+func TestSpecsToDockerMountsVolumeOptions(t *testing.T) {
+	mounts := specsToDockerMounts(
+		[]containers.MountSpec{
+			{
+				Type:          containers.MountVolume,
+				Source:        "data",
+				Target:        "/data",
+				VolumeOptions: map[string]string{"type": "tmpfs", "device": "tmpfs"},
+			},
+		},
+	)
+
+	if len(mounts) != 1 || mounts[0].VolumeOptions == nil {
+		t.Fatalf("volume options are missing: %+v", mounts)
+	}
+
+	driver := mounts[0].VolumeOptions.DriverConfig
+	if driver == nil {
+		t.Fatal("driver options without a driver are dropped")
+	}
+
+	if driver.Name != defaultVolumeDriver || driver.Options["type"] != "tmpfs" {
+		t.Fatalf("driver config: got %+v", driver)
+	}
+}
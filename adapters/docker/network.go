@@ -2,11 +2,17 @@ package docker
 
 import (
 	"context"
+	"net"
+	"net/netip"
 	"os"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/events"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/network"
 	"github.com/docker/docker/client"
 
 	"gopkg.in/gomisc/containers.v1"
@@ -28,9 +34,52 @@ type dockerNetwork struct {
 	*types.NetworkResource
 	client client.APIClient
 	subnet *ipnet.SubnetRange
+	// remoteHost - имя удаленного демона, используется как адрес хоста
+	remoteHost string
 
-	mu         sync.RWMutex
-	containers [maxTypeID][]*containers.OrchestratorInfo
+	registry *containers.Registry
+
+	// кеш занятых адресов сети, сбрасывается по событиям connect/disconnect
+	// и при ошибках потока событий
+	cacheMu    sync.Mutex
+	cacheValid bool
+	endpoints  map[string]struct{}
+	cancel     context.CancelFunc
+
+	// next6 - кандидат следующего IPv6 адреса сети
+	ip6Mu sync.Mutex
+	next6 netip.Addr
+}
+
+func (cli *dockerClient) newNetwork(resource *types.NetworkResource, subnet *ipnet.SubnetRange) *dockerNetwork {
+	cli.networksMu.Lock()
+	defer cli.networksMu.Unlock()
+
+	if nw, ok := cli.networks[resource.ID]; ok {
+		return nw
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	nw := &dockerNetwork{
+		NetworkResource: resource,
+		client:          cli.client,
+		subnet:          subnet,
+		remoteHost:      cli.remoteHost,
+		cancel:          cancel,
+		registry:        containers.NewRegistry(),
+	}
+
+	go nw.watch(ctx)
+
+	cli.networks[resource.ID] = nw
+
+	return nw
+}
+
+// Internal - признак сети без выхода во внешние сети
+func (nw *dockerNetwork) Internal() bool {
+	return nw.NetworkResource.Internal
 }
 
 func (nw *dockerNetwork) ID() string {
@@ -49,15 +98,49 @@ func (nw *dockerNetwork) Name() string {
 	return ""
 }
 
+// Subnet - подсеть IPv4 сети, nil - подсеть не назначена
+func (nw *dockerNetwork) Subnet() *net.IPNet {
+	if nw == nil {
+		return nil
+	}
+
+	_, subnet, err := net.ParseCIDR(ipamConfig(nw.IPAM.Config, false).Subnet)
+	if err != nil {
+		return nil
+	}
+
+	return subnet
+}
+
+// Subnet6 - подсеть IPv6 сети, nil - сеть без IPv6
+func (nw *dockerNetwork) Subnet6() *net.IPNet {
+	if nw == nil || !nw.EnableIPv6 {
+		return nil
+	}
+
+	_, subnet, err := net.ParseCIDR(ipamConfig(nw.IPAM.Config, true).Subnet)
+	if err != nil {
+		return nil
+	}
+
+	return subnet
+}
+
 func (nw *dockerNetwork) Gateway() string {
-	if nw != nil && len(nw.IPAM.Config) != 0 {
-		return nw.IPAM.Config[0].Gateway
+	if nw != nil {
+		return ipamConfig(nw.IPAM.Config, false).Gateway
 	}
 
 	return ""
 }
 
+// HostIP - адрес, на котором доступны опубликованные порты; для удаленного
+// демона - его имя
 func (nw *dockerNetwork) HostIP() string {
+	if nw.remoteHost != "" {
+		return nw.remoteHost
+	}
+
 	ip := nw.subnet.NextIP()
 
 	for ip != "" && !nw.isFreeIP(ip) {
@@ -77,32 +160,177 @@ func (nw *dockerNetwork) NextIP() string {
 	return ip
 }
 
+// NextIP6 - следующий не занятый IPv6 адрес сети после шлюза, пусто - сеть без IPv6
+func (nw *dockerNetwork) NextIP6() string {
+	subnet := nw.Subnet6()
+	if subnet == nil {
+		return ""
+	}
+
+	prefix, err := netip.ParsePrefix(subnet.String())
+	if err != nil {
+		return ""
+	}
+
+	nw.ip6Mu.Lock()
+	defer nw.ip6Mu.Unlock()
+
+	if !nw.next6.IsValid() {
+		nw.next6 = prefix.Addr().Next()
+
+		if gw, gwErr := netip.ParseAddr(ipamConfig(nw.IPAM.Config, true).Gateway); gwErr == nil {
+			nw.next6 = gw
+		}
+
+		nw.next6 = nw.next6.Next()
+	}
+
+	for prefix.Contains(nw.next6) {
+		ip := nw.next6
+		nw.next6 = ip.Next()
+
+		if nw.isFreeIP(ip.String()) {
+			return ip.String()
+		}
+	}
+
+	return ""
+}
+
 func (nw *dockerNetwork) AddContainer(info *containers.OrchestratorInfo) {
-	if info.TypeID > uint8(len(nw.containers)) {
+	if info.TypeID >= maxTypeID {
 		panic("containers types overflow")
 	}
 
-	nw.mu.Lock()
-	defer nw.mu.Unlock()
+	nw.registry.AddContainer(info)
+}
 
-	nw.containers[info.TypeID] = append(nw.containers[info.TypeID], info)
+// Registry - реестр контейнеров сети
+func (nw *dockerNetwork) Registry() *containers.Registry {
+	return nw.registry
 }
 
 func (nw *dockerNetwork) isFreeIP(ip string) bool {
-	resource, err := nw.client.NetworkInspect(context.Background(), nw.ID(), types.NetworkInspectOptions{})
-	if err != nil {
-		return false
-	}
+	nw.cacheMu.Lock()
+	defer nw.cacheMu.Unlock()
 
-	for _, endpoint := range resource.Containers {
-		if strings.Contains(endpoint.IPv4Address, ip) {
+	if !nw.cacheValid {
+		resource, err := nw.client.NetworkInspect(context.Background(), nw.ID(), types.NetworkInspectOptions{})
+		if err != nil {
 			return false
 		}
+
+		nw.endpoints = make(map[string]struct{}, len(resource.Containers))
+
+		for _, endpoint := range resource.Containers {
+			addr, _, _ := strings.Cut(endpoint.IPv4Address, "/")
+			nw.endpoints[addr] = struct{}{}
+
+			if addr, _, _ = strings.Cut(endpoint.IPv6Address, "/"); addr != "" {
+				nw.endpoints[addr] = struct{}{}
+			}
+		}
+
+		nw.cacheValid = true
 	}
 
+	if _, used := nw.endpoints[ip]; used {
+		return false
+	}
+
+	// выданный адрес считается занятым до подключения контейнера
+	nw.endpoints[ip] = struct{}{}
+
 	return true
 }
 
+func (nw *dockerNetwork) invalidate() {
+	nw.cacheMu.Lock()
+	nw.cacheValid = false
+	nw.cacheMu.Unlock()
+}
+
+// watch сбрасывает кеш адресов при подключении и отключении контейнеров
+func (nw *dockerNetwork) watch(ctx context.Context) {
+	const retryDelay = time.Second
+
+	for {
+		msgCh, errCh := nw.client.Events(
+			ctx, types.EventsOptions{
+				Filters: filters.NewArgs(
+					filters.Arg("type", events.NetworkEventType),
+					filters.Arg("network", nw.ID()),
+				),
+			},
+		)
+
+	stream:
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-msgCh:
+				nw.invalidate()
+			case <-errCh:
+				nw.invalidate()
+
+				break stream
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(retryDelay):
+		}
+	}
+}
+
+func (nw *dockerNetwork) close() {
+	if nw.cancel != nil {
+		nw.cancel()
+	}
+}
+
+// ipamConfig - настройка IPAM сети для подсети IPv4 или IPv6
+func ipamConfig(configs []network.IPAMConfig, v6 bool) network.IPAMConfig {
+	for _, cfg := range configs {
+		if prefix, err := netip.ParsePrefix(cfg.Subnet); err == nil && prefix.Addr().Is4() != v6 {
+			return cfg
+		}
+	}
+
+	return network.IPAMConfig{}
+}
+
+// endpointIPAM - настройка адреса контейнера в сети по семейству адреса
+func endpointIPAM(ip string) *network.EndpointIPAMConfig {
+	if addr, err := netip.ParseAddr(ip); err == nil && !addr.Is4() {
+		return &network.EndpointIPAMConfig{IPv6Address: ip}
+	}
+
+	return &network.EndpointIPAMConfig{IPv4Address: ip}
+}
+
+// enableIPv6 - включает создание сетей с двумя стеками адресов
+func (cli *dockerClient) enableIPv6(pool string, prefix int) (err error) {
+	if pool == "" {
+		pool = containers.DefaultSubnetPool6
+	}
+
+	cli.subnetPrefix6 = prefix
+	if cli.subnetPrefix6 == 0 {
+		cli.subnetPrefix6 = containers.DefaultSubnetPrefix6
+	}
+
+	cli.netalloc6, err = containers.NewSubnetAllocator(pool, cli.getUsedNetworks, getReservedNetworks()...)
+	if err != nil {
+		return errors.Wrap(err, "create ipv6 networks allocator")
+	}
+
+	return nil
+}
+
 func getReservedNetworks() []string {
 	if reservedStr := os.Getenv(reservedNetworksVar); reservedStr != "" {
 		return strings.Split(reservedStr, ",")
@@ -110,3 +338,37 @@ func getReservedNetworks() []string {
 
 	return nil
 }
+
+// NetworkConnect - подключает контейнер к сети, в том числе работающий
+func (cli *dockerClient) NetworkConnect(ctx context.Context, id string, att containers.NetworkAttachment) error {
+	settings := &network.EndpointSettings{Aliases: att.Aliases}
+
+	if att.IP != "" {
+		settings.IPAMConfig = endpointIPAM(att.IP)
+	}
+
+	if err := cli.client.NetworkConnect(ctx, att.Network.ID(), id, settings); err != nil {
+		return errors.Ctx().
+			Str("container-id", shortID(id)).
+			Str("network", att.Network.Name()).
+			Wrap(err, "docker network connect")
+	}
+
+	cli.invalidateInspect(att.Network.ID())
+
+	return nil
+}
+
+// NetworkDisconnect - отключает контейнер от сети
+func (cli *dockerClient) NetworkDisconnect(ctx context.Context, id string, nw containers.Network) error {
+	if err := cli.client.NetworkDisconnect(ctx, nw.ID(), id, false); err != nil {
+		return errors.Ctx().
+			Str("container-id", shortID(id)).
+			Str("network", nw.Name()).
+			Wrap(err, "docker network disconnect")
+	}
+
+	cli.invalidateInspect(nw.ID())
+
+	return nil
+}
@@ -0,0 +1,76 @@
+package docker
+
+import (
+	"crypto/tls"
+	"time"
+
+	"github.com/docker/docker/client"
+
+	"gopkg.in/gomisc/containers.v1"
+)
+
+type (
+	// Option - опция docker клиента
+	Option func(o *options)
+
+	options struct {
+		clientOpts     []client.Opt
+		maxConcurrency int
+		rateLimit      float64
+		buildCacheDir  *string
+		subnetPool     string
+		subnetPrefix   int
+		minFreeSpace   uint64
+		pruneOnLow     bool
+		host           string
+		tlsConfig      *tls.Config
+		apiVersion     *string
+
+		reconnectTimeout *time.Duration
+
+		ipv6       bool
+		ipv6Pool   string
+		ipv6Prefix int
+
+		project          containers.Project
+		installEmulation bool
+		inspectCache     bool
+	}
+)
+
+// WithMaxConcurrency - ограничивает число одновременных запросов к демону
+// (потоковые запросы: логи, события, ожидание - не учитываются)
+func WithMaxConcurrency(n int) Option {
+	return func(o *options) {
+		o.maxConcurrency = n
+	}
+}
+
+// WithRateLimit - ограничивает частоту запросов к демону (запросов в секунду).
+// Частота адаптивно снижается при перегрузке демона и восстанавливается до
+// заданного значения при успешных ответах
+func WithRateLimit(rps float64) Option {
+	return func(o *options) {
+		o.rateLimit = rps
+	}
+}
+
+// WithIPv6 - создает сети с двумя стеками адресов: сетям без явно заданной
+// подсети IPv6 она выделяется из пула pool с длиной префикса prefix (пустые
+// значения - DefaultSubnetPool6 и DefaultSubnetPrefix6)
+func WithIPv6(pool string, prefix int) Option {
+	return func(o *options) {
+		o.ipv6 = true
+		o.ipv6Pool = pool
+		o.ipv6Prefix = prefix
+	}
+}
+
+// WithSubnetPool - задает пул (CIDR), из которого выделяются подсети, и длину
+// префикса выделяемых подсетей
+func WithSubnetPool(pool string, prefix int) Option {
+	return func(o *options) {
+		o.subnetPool = pool
+		o.subnetPrefix = prefix
+	}
+}
@@ -0,0 +1,101 @@
+package docker
+
+import (
+	"context"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/filters"
+
+	"gopkg.in/gomisc/containers.v1"
+	"gopkg.in/gomisc/errors.v1"
+)
+
+// WithProject - помечает создаваемые клиентом контейнеры, сети, тома и
+// образы меткой проекта, а подсети сетей выделяет из части пула, выделенной
+// проекту (если пул не задан явно WithSubnetPool)
+func WithProject(project containers.Project) Option {
+	return func(o *options) {
+		o.project = project
+	}
+}
+
+// ListProjectResources - объекты с меткой проекта, созданные любыми сессиями
+func (cli *dockerClient) ListProjectResources(ctx context.Context, project string) (*containers.ProjectResources, error) {
+	p := containers.Project(project)
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+
+	res := &containers.ProjectResources{Project: project}
+	label := filters.NewArgs(filters.Arg("label", containers.ProjectLabel+"="+project))
+
+	var err error
+
+	if res.Containers, err = cli.ContainerList(ctx, p.Filter()); err != nil {
+		return nil, errors.Wrap(err, "list project containers")
+	}
+
+	var networks []types.NetworkResource
+
+	err = cli.retry(
+		ctx, func(ctx context.Context) (err error) {
+			networks, err = cli.client.NetworkList(ctx, types.NetworkListOptions{Filters: label})
+
+			return err
+		},
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "list project networks")
+	}
+
+	for i := range networks {
+		res.Networks = append(
+			res.Networks, containers.NetworkSummary{
+				ID:     networks[i].ID,
+				Name:   networks[i].Name,
+				Subnet: ipamConfig(networks[i].IPAM.Config, false).Subnet,
+				Labels: networks[i].Labels,
+			},
+		)
+	}
+
+	res.Volumes, err = cli.VolumeList(ctx, containers.VolumeFilter{Labels: map[string]string{containers.ProjectLabel: project}})
+	if err != nil {
+		return nil, errors.Wrap(err, "list project volumes")
+	}
+
+	var images []types.ImageSummary
+
+	err = cli.retry(
+		ctx, func(ctx context.Context) (err error) {
+			images, err = cli.client.ImageList(ctx, types.ImageListOptions{Filters: label})
+
+			return err
+		},
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "list project images")
+	}
+
+	for i := range images {
+		if len(images[i].RepoTags) == 0 {
+			res.Images = append(res.Images, images[i].ID)
+
+			continue
+		}
+
+		res.Images = append(res.Images, images[i].RepoTags...)
+	}
+
+	return res, nil
+}
+
+// labelProject - добавляет к меткам объекта метку проекта клиента; метки
+// объекта ее не переопределяют
+func (cli *dockerClient) labelProject(labels map[string]string) map[string]string {
+	if cli.project != "" {
+		labels[containers.ProjectLabel] = string(cli.project)
+	}
+
+	return labels
+}
@@ -0,0 +1,80 @@
+package docker
+
+import (
+	"context"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/filters"
+
+	"gopkg.in/gomisc/containers.v1"
+	"gopkg.in/gomisc/errors.v1"
+)
+
+// PruneImages - удаляет неиспользуемые образы; отбор по меткам и возрасту
+// выполняет демон (возраст отсчитывается по часам демона)
+func (cli *dockerClient) PruneImages(ctx context.Context, opts containers.PruneOptions) (containers.PruneReport, error) {
+	args := filters.NewArgs()
+
+	for k, v := range opts.Labels {
+		if v == "" {
+			args.Add("label", k)
+		} else {
+			args.Add("label", k+"="+v)
+		}
+	}
+
+	if opts.OlderThan > 0 {
+		args.Add("until", opts.OlderThan.String())
+	}
+
+	if opts.All {
+		args.Add("dangling", "false")
+	}
+
+	var report containers.PruneReport
+
+	pruned, err := cli.client.ImagesPrune(ctx, args)
+	if err != nil {
+		return report, errors.Wrap(err, "prune images")
+	}
+
+	for _, item := range pruned.ImagesDeleted {
+		if item.Untagged != "" {
+			report.Deleted = append(report.Deleted, item.Untagged)
+		}
+
+		if item.Deleted != "" {
+			report.Deleted = append(report.Deleted, item.Deleted)
+		}
+	}
+
+	report.SpaceReclaimed = pruned.SpaceReclaimed
+
+	return report, nil
+}
+
+// PruneBuildCache - очищает неиспользуемый кеш сборки; возраст записей
+// отбирает демон фильтром until
+func (cli *dockerClient) PruneBuildCache(
+	ctx context.Context, opts containers.BuildCachePruneOptions,
+) (containers.PruneReport, error) {
+	if err := opts.Validate(); err != nil {
+		return containers.PruneReport{}, err
+	}
+
+	args := filters.NewArgs()
+
+	if opts.OlderThan > 0 {
+		args.Add("until", opts.OlderThan.String())
+	}
+
+	pruned, err := cli.client.BuildCachePrune(ctx, types.BuildCachePruneOptions{Filters: args})
+	if err != nil {
+		return containers.PruneReport{}, errors.Wrap(err, "prune build cache")
+	}
+
+	return containers.PruneReport{
+		Deleted:        pruned.CachesDeleted,
+		SpaceReclaimed: pruned.SpaceReclaimed,
+	}, nil
+}
@@ -0,0 +1,199 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/docker/docker/api/types/events"
+	"github.com/docker/docker/client"
+
+	"gopkg.in/gomisc/containers.v1"
+	"gopkg.in/gomisc/containers.v1/wait"
+	"gopkg.in/gomisc/errors.v1"
+)
+
+const (
+	// DefaultReconnectTimeout - время, в течение которого клиент ждет
+	// возвращения демона после обрыва соединения
+	DefaultReconnectTimeout = 30 * time.Second
+	// ErrDaemonUnavailable - демон не вернулся за время ожидания переподключения
+	ErrDaemonUnavailable = errors.Const("docker daemon unavailable")
+)
+
+// reconnectBackoff - задержка между попытками переподключения: 100ms ... 5s
+var reconnectBackoff = wait.Backoff{
+	Initial: 100 * time.Millisecond,
+	Max:     5 * time.Second,
+	Factor:  2,
+	Jitter:  0.2,
+}
+
+// WithReconnectTimeout - время ожидания возвращения демона после обрыва
+// соединения (перезапуск демона, обновление Docker Desktop): списки и инспекция
+// повторяются, а потоки событий, логов и ожидания контейнеров открываются
+// заново. Значение 0 отключает переподключение
+func WithReconnectTimeout(timeout time.Duration) Option {
+	return func(o *options) {
+		o.reconnectTimeout = &timeout
+	}
+}
+
+// isConnectionError - признак обрыва соединения с демоном, а не ошибки запроса
+func isConnectionError(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	if client.IsErrConnectionFailed(err) ||
+		errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.EPIPE) ||
+		errors.Is(err, net.ErrClosed) {
+		return true
+	}
+
+	// ошибки транспорта docker клиент возвращает без цепочки причин
+	msg := err.Error()
+
+	return strings.Contains(msg, "connection refused") ||
+		strings.Contains(msg, "connection reset by peer") ||
+		strings.Contains(msg, "Is the docker daemon running?")
+}
+
+// retry - выполняет запрос, повторяя его при обрыве соединения с демоном,
+// пока не истечет время ожидания переподключения
+func (cli *dockerClient) retry(ctx context.Context, call func(ctx context.Context) error) error {
+	err := call(ctx)
+	if err == nil {
+		cli.reachable.Store(true)
+
+		return nil
+	}
+
+	if !isConnectionError(err) {
+		return err
+	}
+
+	if err = cli.awaitDaemon(ctx, err); err != nil {
+		return err
+	}
+
+	return call(ctx)
+}
+
+// awaitDaemon - ждет возвращения демона после ошибки соединения cause и
+// выполняет восстановление сессии; возвращает ошибку, если демон не вернулся.
+// Если демон ни разу не отвечал, ожидания нет: ошибка конфигурации подключения
+// не должна превращаться в задержку каждого запроса
+func (cli *dockerClient) awaitDaemon(ctx context.Context, cause error) error {
+	if cli.reconnectTimeout <= 0 || !cli.reachable.Load() {
+		return cause
+	}
+
+	cli.markOutage()
+
+	pingCtx, cancel := context.WithTimeout(ctx, cli.reconnectTimeout)
+	defer cancel()
+
+	err := wait.Poll(
+		pingCtx, reconnectBackoff, func(ctx context.Context) error {
+			_, err := cli.client.Ping(ctx)
+
+			return err
+		},
+	)
+	if err != nil {
+		if ctx.Err() != nil {
+			return errors.Wrap(ctx.Err(), "wait for docker daemon")
+		}
+
+		return errors.And(
+			errors.Ctx().Str("timeout", cli.reconnectTimeout.String()).Just(ErrDaemonUnavailable),
+			cause,
+		)
+	}
+
+	cli.restore(ctx)
+
+	return nil
+}
+
+// markOutage - отмечает потерю соединения с демоном
+func (cli *dockerClient) markOutage() {
+	cli.outageMu.Lock()
+	cli.outage = true
+	cli.outageMu.Unlock()
+}
+
+// restore - процедура восстановления после возвращения демона, выполняется
+// один раз на обрыв: сбрасывает кеши, состояние которых могло устареть, и
+// сверяет контейнеры сессии. Продолжившие работу контейнеры подхватываются
+// заново открытыми потоками ожидания и логов, а контейнеры, остановленные
+// перезапуском демона, сообщают свой код завершения через ContainerWait
+func (cli *dockerClient) restore(ctx context.Context) {
+	cli.outageMu.Lock()
+	outage := cli.outage
+	cli.outage = false
+	cli.outageMu.Unlock()
+
+	if !outage {
+		return
+	}
+
+	if cli.inspect != nil {
+		cli.inspect.reset()
+	}
+
+	cli.infoMu.Lock()
+	cli.info = nil
+	cli.infoMu.Unlock()
+
+	list, err := cli.ContainerList(
+		ctx, containers.ListFilter{Labels: map[string]string{SessionLabel: sessionID}},
+	)
+	if err != nil {
+		cli.logStderr(err, "list session containers after reconnect")
+
+		return
+	}
+
+	var running, stopped []string
+
+	for i := range list {
+		if list[i].Status == "running" {
+			running = append(running, list[i].Name)
+		} else {
+			stopped = append(stopped, list[i].Name)
+		}
+	}
+
+	cli.logStdout(
+		"docker daemon connection restored: running containers [%s], stopped containers [%s]",
+		strings.Join(running, ", "), strings.Join(stopped, ", "),
+	)
+}
+
+// since - значение параметра Since API демона для момента t
+func since(t time.Time) string {
+	return fmt.Sprintf("%d.%09d", t.Unix(), t.Nanosecond())
+}
+
+// sameEvent - событие уже передано до переподключения
+func sameEvent(msg, last *events.Message) bool {
+	if last == nil {
+		return false
+	}
+
+	if msg.TimeNano != last.TimeNano {
+		return msg.TimeNano < last.TimeNano
+	}
+
+	return msg.Actor.ID == last.Actor.ID && msg.Action == last.Action
+}
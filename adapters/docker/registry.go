@@ -0,0 +1,29 @@
+package docker
+
+import (
+	"encoding/base64"
+	"encoding/json"
+
+	"github.com/docker/docker/api/types"
+
+	"gopkg.in/gomisc/containers.v1"
+	"gopkg.in/gomisc/errors.v1"
+)
+
+// encodeRegistryAuth - учетные данные в формате заголовка X-Registry-Auth;
+// заголовок передается и для анонимного доступа: без него демон отклоняет push
+func encodeRegistryAuth(auth containers.RegistryAuth) (string, error) {
+	data, err := json.Marshal(
+		types.AuthConfig{
+			Username:      auth.Username,
+			Password:      auth.Password,
+			IdentityToken: auth.IdentityToken,
+			ServerAddress: auth.ServerAddress,
+		},
+	)
+	if err != nil {
+		return "", errors.Wrap(err, "encode registry auth")
+	}
+
+	return base64.URLEncoding.EncodeToString(data), nil
+}
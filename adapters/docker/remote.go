@@ -0,0 +1,312 @@
+package docker
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/client"
+
+	"gopkg.in/gomisc/errors.v1"
+)
+
+// ErrUnsupportedHost - адрес демона не удалось разобрать
+const ErrUnsupportedHost = errors.Const("unsupported docker host")
+
+// sshDaemonHost - условный адрес демона для клиента, соединения которого
+// устанавливаются через ssh
+const sshDaemonHost = "http://docker.example.com"
+
+// WithHost - адрес демона: unix:///var/run/docker.sock, tcp://host:2376 или
+// ssh://user@host[:port]. Для ssh на удаленной машине выполняется
+// docker system dial-stdio, поэтому нужен клиент ssh с настроенным доступом
+// по ключу. По умолчанию адрес берется из DOCKER_HOST
+func WithHost(host string) Option {
+	return func(o *options) {
+		o.host = host
+	}
+}
+
+// WithTLSConfig - настройки TLS соединения с демоном (tcp://host:2376)
+func WithTLSConfig(config *tls.Config) Option {
+	return func(o *options) {
+		o.tlsConfig = config
+	}
+}
+
+// WithAPIVersion - версия API демона, пустая строка - согласование версии
+// с демоном. По умолчанию версия берется из DOCKER_API_VERSION
+func WithAPIVersion(version string) Option {
+	return func(o *options) {
+		o.apiVersion = &version
+	}
+}
+
+// connectionOpts - опции подключения docker клиента с учетом окружения,
+// второе значение - адрес удаленного демона (пусто для локального)
+func connectionOpts(o *options) ([]client.Opt, string, error) {
+	host := o.host
+	if host == "" {
+		host = os.Getenv("DOCKER_HOST")
+	}
+
+	var opts []client.Opt
+
+	if strings.HasPrefix(host, "ssh://") {
+		target, err := parseSSHHost(host)
+		if err != nil {
+			return nil, "", err
+		}
+
+		opts = append(
+			opts,
+			client.WithHTTPClient(&http.Client{Transport: &http.Transport{}}),
+			client.WithHost(sshDaemonHost),
+			client.WithDialContext(target.dial),
+		)
+
+		if version := os.Getenv("DOCKER_API_VERSION"); version != "" {
+			opts = append(opts, client.WithVersion(version))
+		}
+	} else {
+		opts = append(opts, client.FromEnv)
+
+		if o.tlsConfig != nil {
+			opts = append(opts, withTLSConfig(o.tlsConfig))
+		}
+
+		if o.host != "" {
+			opts = append(opts, client.WithHost(o.host))
+		}
+	}
+
+	if o.apiVersion != nil {
+		if *o.apiVersion == "" {
+			opts = append(opts, client.WithAPIVersionNegotiation())
+		} else {
+			opts = append(opts, client.WithVersion(*o.apiVersion))
+		}
+	}
+
+	remote, err := remoteHost(host)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return opts, remote, nil
+}
+
+// withTLSConfig - заменяет HTTP клиент демона клиентом с настройками TLS,
+// сохраняя ранее заданный адрес
+func withTLSConfig(config *tls.Config) client.Opt {
+	return func(c *client.Client) error {
+		host := c.DaemonHost()
+
+		err := client.WithHTTPClient(
+			&http.Client{
+				Transport:     &http.Transport{TLSClientConfig: config},
+				CheckRedirect: client.CheckRedirect,
+			},
+		)(c)
+		if err != nil {
+			return err
+		}
+
+		return client.WithHost(host)(c)
+	}
+}
+
+// remoteHost - имя удаленного демона, на котором публикуются порты
+// контейнеров; для локального демона - пустая строка
+func remoteHost(host string) (string, error) {
+	if host == "" {
+		return "", nil
+	}
+
+	u, err := url.Parse(host)
+	if err != nil {
+		return "", errors.Ctx().Str("host", host).Wrap(err, "parse docker host")
+	}
+
+	switch u.Scheme {
+	case "unix", "npipe", "fd":
+		return "", nil
+	case "tcp", "http", "https", "ssh":
+	default:
+		return "", errors.Ctx().Str("host", host).Just(ErrUnsupportedHost)
+	}
+
+	switch name := u.Hostname(); name {
+	case "", "localhost", "127.0.0.1", "::1":
+		return "", nil
+	default:
+		return name, nil
+	}
+}
+
+// sshTarget - удаленная машина с демоном docker
+type sshTarget struct {
+	user string
+	host string
+	port string
+}
+
+func parseSSHHost(host string) (*sshTarget, error) {
+	u, err := url.Parse(host)
+	if err != nil {
+		return nil, errors.Ctx().Str("host", host).Wrap(err, "parse docker host")
+	}
+
+	if u.Hostname() == "" || (u.Path != "" && u.Path != "/") {
+		return nil, errors.Ctx().Str("host", host).Just(ErrUnsupportedHost)
+	}
+
+	return &sshTarget{user: u.User.Username(), host: u.Hostname(), port: u.Port()}, nil
+}
+
+// dial - соединение с демоном через stdin/stdout удаленной команды
+// docker system dial-stdio; адрес, запрошенный HTTP клиентом, не используется
+func (t *sshTarget) dial(context.Context, string, string) (net.Conn, error) {
+	// без терминала запрос пароля или подтверждения ключа хоста повесил бы
+	// соединение: ssh должен завершиться ошибкой
+	args := []string{"-o", "BatchMode=yes"}
+
+	if t.user != "" {
+		args = append(args, "-l", t.user)
+	}
+
+	if t.port != "" {
+		args = append(args, "-p", t.port)
+	}
+
+	args = append(args, "--", t.host, "docker", "system", "dial-stdio")
+
+	// соединение живет дольше контекста запроса, поэтому команда к нему не привязана
+	cmd := exec.Command("ssh", args...) //nolint:gosec
+
+	conn := &commandConn{cmd: cmd, addr: sshAddr(t.host)}
+	cmd.Stderr = &conn.stderr
+
+	var err error
+
+	if conn.stdin, err = cmd.StdinPipe(); err != nil {
+		return nil, errors.Wrap(err, "ssh stdin pipe")
+	}
+
+	if conn.stdout, err = cmd.StdoutPipe(); err != nil {
+		return nil, errors.Wrap(err, "ssh stdout pipe")
+	}
+
+	if err = cmd.Start(); err != nil {
+		return nil, errors.Ctx().Str("host", t.host).Wrap(err, "start ssh")
+	}
+
+	return conn, nil
+}
+
+// commandConn - net.Conn поверх потоков ввода-вывода процесса
+type commandConn struct {
+	cmd    *exec.Cmd
+	addr   sshAddr
+	stdin  io.WriteCloser
+	stdout io.ReadCloser
+	stderr lockedBuffer
+
+	closeOnce sync.Once
+}
+
+// lockedBuffer - буфер вывода ошибок процесса: его пишет горутина exec.Cmd,
+// а читают Read и Write соединения
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.buf.Write(p) //nolint:wrapcheck
+}
+
+func (b *lockedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.buf.String()
+}
+
+func (c *commandConn) Read(p []byte) (int, error) {
+	n, err := c.stdout.Read(p)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return n, errors.Ctx().Str("stderr", c.stderr.String()).Wrap(err, "read from ssh")
+	}
+
+	return n, err //nolint:wrapcheck
+}
+
+func (c *commandConn) Write(p []byte) (int, error) {
+	n, err := c.stdin.Write(p)
+	if err != nil {
+		return n, errors.Ctx().Str("stderr", c.stderr.String()).Wrap(err, "write to ssh")
+	}
+
+	return n, nil
+}
+
+func (c *commandConn) Close() error {
+	c.closeOnce.Do(
+		func() {
+			_ = c.stdin.Close()
+
+			if c.cmd.Process != nil {
+				_ = c.cmd.Process.Kill()
+			}
+
+			_ = c.cmd.Wait()
+		},
+	)
+
+	return nil
+}
+
+func (c *commandConn) LocalAddr() net.Addr {
+	return sshAddr("local")
+}
+
+func (c *commandConn) RemoteAddr() net.Addr {
+	return c.addr
+}
+
+// SetDeadline - сроки операций для потоков процесса не поддерживаются
+func (c *commandConn) SetDeadline(time.Time) error {
+	return nil
+}
+
+func (c *commandConn) SetReadDeadline(time.Time) error {
+	return nil
+}
+
+func (c *commandConn) SetWriteDeadline(time.Time) error {
+	return nil
+}
+
+// sshAddr - адрес соединения через ssh
+type sshAddr string
+
+func (a sshAddr) Network() string {
+	return "ssh"
+}
+
+func (a sshAddr) String() string {
+	return string(a)
+}
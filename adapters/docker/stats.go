@@ -0,0 +1,111 @@
+package docker
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types"
+
+	"gopkg.in/gomisc/containers.v1"
+	"gopkg.in/gomisc/errors.v1"
+)
+
+// ContainerStats - транслирует поток статистики демона в замеры; демон
+// присылает замер примерно раз в секунду
+func (cli *dockerClient) ContainerStats(ctx context.Context, id string) (<-chan containers.StatsSample, error) {
+	resp, err := cli.client.ContainerStats(ctx, id, true)
+	if err != nil {
+		return nil, errors.Ctx().Str("container-id", shortID(id)).Wrap(err, "docker container stats")
+	}
+
+	samples := make(chan containers.StatsSample)
+
+	go func() {
+		defer close(samples)
+
+		defer func() {
+			_ = resp.Body.Close()
+		}()
+
+		dec := json.NewDecoder(resp.Body)
+
+		for {
+			var stats types.StatsJSON
+
+			// поток завершается при остановке контейнера или отмене ctx
+			if dec.Decode(&stats) != nil {
+				return
+			}
+
+			select {
+			case samples <- statsSample(&stats):
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return samples, nil
+}
+
+func statsSample(stats *types.StatsJSON) containers.StatsSample {
+	sample := containers.StatsSample{
+		Time:        stats.Read,
+		CPUPercent:  cpuPercent(stats),
+		CPUTime:     time.Duration(stats.CPUStats.CPUUsage.TotalUsage),
+		MemoryUsage: memoryUsage(&stats.MemoryStats),
+		MemoryLimit: stats.MemoryStats.Limit,
+		Pids:        stats.PidsStats.Current,
+	}
+
+	for _, nw := range stats.Networks {
+		sample.NetRxBytes += nw.RxBytes
+		sample.NetTxBytes += nw.TxBytes
+	}
+
+	// cgroup v1 называет операции "Read"/"Write", v2 - "read"/"write"
+	for _, entry := range stats.BlkioStats.IoServiceBytesRecursive {
+		switch strings.ToLower(entry.Op) {
+		case "read":
+			sample.BlockRead += entry.Value
+		case "write":
+			sample.BlockWrite += entry.Value
+		}
+	}
+
+	return sample
+}
+
+// cpuPercent - загрузка процессора между предыдущим и текущим замером,
+// как ее считает docker stats
+func cpuPercent(stats *types.StatsJSON) float64 {
+	cpuDelta := float64(stats.CPUStats.CPUUsage.TotalUsage) - float64(stats.PreCPUStats.CPUUsage.TotalUsage)
+	systemDelta := float64(stats.CPUStats.SystemUsage) - float64(stats.PreCPUStats.SystemUsage)
+
+	if cpuDelta <= 0 || systemDelta <= 0 {
+		return 0
+	}
+
+	cpus := float64(stats.CPUStats.OnlineCPUs)
+	if cpus == 0 {
+		cpus = float64(len(stats.CPUStats.CPUUsage.PercpuUsage))
+	}
+
+	return cpuDelta / systemDelta * cpus * 100
+}
+
+// memoryUsage - память без неактивного страничного кеша, как ее считает
+// docker stats: total_inactive_file в cgroup v1, inactive_file в cgroup v2
+func memoryUsage(mem *types.MemoryStats) uint64 {
+	if v, ok := mem.Stats["total_inactive_file"]; ok && v < mem.Usage {
+		return mem.Usage - v
+	}
+
+	if v := mem.Stats["inactive_file"]; v < mem.Usage {
+		return mem.Usage - v
+	}
+
+	return mem.Usage
+}
@@ -0,0 +1,130 @@
+package docker
+
+import (
+	"context"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/mount"
+	volumetypes "github.com/docker/docker/api/types/volume"
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/errdefs"
+
+	"gopkg.in/gomisc/containers.v1"
+	"gopkg.in/gomisc/errors.v1"
+)
+
+// VolumeCreate - создает том с метками spec и меткой сессии; демон
+// возвращает существующий том с тем же именем без изменений
+func (cli *dockerClient) VolumeCreate(ctx context.Context, spec containers.VolumeSpec) (containers.VolumeInfo, error) {
+	vol, err := cli.client.VolumeCreate(
+		ctx, volumetypes.VolumeCreateBody{
+			Name:   spec.Name,
+			Driver: spec.Driver,
+			Labels: cli.labelProject(withSessionLabels(spec.Labels)),
+		},
+	)
+	if err != nil {
+		return containers.VolumeInfo{}, errors.Ctx().Str("volume", spec.Name).Wrap(err, "create volume")
+	}
+
+	return volumeInfo(&vol), nil
+}
+
+func (cli *dockerClient) VolumeRemove(ctx context.Context, name string, force bool) error {
+	err := cli.client.VolumeRemove(ctx, name, force)
+
+	switch {
+	case err == nil:
+		return nil
+	case client.IsErrNotFound(err):
+		return errors.And(errors.Ctx().Str("volume", name).Just(containers.ErrVolumeNotFound), err)
+	case errdefs.IsConflict(err):
+		return errors.And(errors.Ctx().Str("volume", name).Just(containers.ErrVolumeInUse), err)
+	default:
+		return errors.Ctx().Str("volume", name).Wrap(err, "remove volume")
+	}
+}
+
+// VolumeList - отбирает тома по меткам на стороне демона, фильтр имени
+// демона ищет подстроку, поэтому префикс дополнительно проверяется на клиенте
+func (cli *dockerClient) VolumeList(ctx context.Context, filter containers.VolumeFilter) ([]containers.VolumeInfo, error) {
+	args := filters.NewArgs()
+
+	for k, v := range filter.Labels {
+		if v == "" {
+			args.Add("label", k)
+		} else {
+			args.Add("label", k+"="+v)
+		}
+	}
+
+	if filter.NamePrefix != "" {
+		args.Add("name", filter.NamePrefix)
+	}
+
+	var list volumetypes.VolumeListOKBody
+
+	err := cli.retry(
+		ctx, func(ctx context.Context) (err error) {
+			list, err = cli.client.VolumeList(ctx, args)
+
+			return err
+		},
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "docker volume list")
+	}
+
+	result := make([]containers.VolumeInfo, 0, len(list.Volumes))
+
+	for _, vol := range list.Volumes {
+		info := volumeInfo(vol)
+
+		if filter.Match(&info) {
+			result = append(result, info)
+		}
+	}
+
+	return result, nil
+}
+
+func volumeInfo(vol *types.Volume) containers.VolumeInfo {
+	info := containers.VolumeInfo{
+		Name:       vol.Name,
+		Driver:     vol.Driver,
+		Mountpoint: vol.Mountpoint,
+		Labels:     vol.Labels,
+	}
+
+	info.Created, _ = time.Parse(time.RFC3339, vol.CreatedAt)
+
+	return info
+}
+
+// volumeMounts - подключения именованных томов; отсутствующий том демон
+// создает при создании контейнера с метками и драйвером из описания
+func volumeMounts(volumes []containers.VolumeSpec) []mount.Mount {
+	mounts := make([]mount.Mount, 0, len(volumes))
+
+	for _, v := range volumes {
+		mnt := mount.Mount{
+			Type:     mount.TypeVolume,
+			Source:   v.Name,
+			Target:   v.Target,
+			ReadOnly: v.ReadOnly,
+			VolumeOptions: &mount.VolumeOptions{
+				Labels: withSessionLabels(v.Labels),
+			},
+		}
+
+		if v.Driver != "" {
+			mnt.VolumeOptions.DriverConfig = &mount.Driver{Name: v.Driver}
+		}
+
+		mounts = append(mounts, mnt)
+	}
+
+	return mounts
+}
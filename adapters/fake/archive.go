@@ -0,0 +1,93 @@
+package fake
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/gomisc/errors.v1"
+)
+
+// readArchive - содержимое обычных файлов tar-потока, распакованного в dst
+func readArchive(r io.Reader, dst string) (map[string][]byte, error) {
+	files := make(map[string][]byte)
+	tr := tar.NewReader(r)
+
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return files, nil
+		}
+
+		if err != nil {
+			return nil, errors.Wrap(err, "read tar header")
+		}
+
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, errors.Ctx().Str("name", hdr.Name).Wrap(err, "read tar entry")
+		}
+
+		files[path.Join("/", dst, hdr.Name)] = data
+	}
+}
+
+// writeArchive - tar-поток с файлом или каталогом src, имена записей
+// начинаются с path.Base(src), как у docker cp
+func writeArchive(files map[string][]byte, src string) (*bytes.Buffer, error) {
+	src = path.Join("/", src)
+	base := path.Dir(src)
+
+	names := make([]string, 0, len(files))
+
+	for p := range files {
+		if p == src || strings.HasPrefix(p, strings.TrimSuffix(src, "/")+"/") {
+			names = append(names, p)
+		}
+	}
+
+	if len(names) == 0 {
+		return nil, errors.Ctx().Str("path", src).Just(ErrNoSuchPath)
+	}
+
+	sort.Strings(names)
+
+	var buf bytes.Buffer
+
+	tw := tar.NewWriter(&buf)
+	now := time.Now()
+
+	for _, p := range names {
+		name := strings.TrimPrefix(strings.TrimPrefix(p, base), "/")
+
+		hdr := &tar.Header{
+			Typeflag: tar.TypeReg,
+			Name:     name,
+			Mode:     0o644,
+			Size:     int64(len(files[p])),
+			ModTime:  now,
+		}
+
+		if err := tw.WriteHeader(hdr); err != nil {
+			return nil, errors.Wrap(err, "write tar header")
+		}
+
+		if _, err := tw.Write(files[p]); err != nil {
+			return nil, errors.Wrap(err, "write tar entry")
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return nil, errors.Wrap(err, "close tar writer")
+	}
+
+	return &buf, nil
+}
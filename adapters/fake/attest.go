@@ -0,0 +1,139 @@
+package fake
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/gomisc/containers.v1"
+	"gopkg.in/gomisc/errors.v1"
+)
+
+type (
+	// slsaProvenance - предикат provenance в объеме, который формирует фейк
+	slsaProvenance struct {
+		Builder   slsaBuilder    `json:"builder"`
+		BuildType string         `json:"buildType"`
+		Materials []slsaMaterial `json:"materials"`
+	}
+
+	slsaBuilder struct {
+		ID string `json:"id"`
+	}
+
+	slsaMaterial struct {
+		URI    string            `json:"uri"`
+		Digest map[string]string `json:"digest,omitempty"`
+	}
+
+	// spdxDocument - документ SPDX в объеме, который формирует фейк
+	spdxDocument struct {
+		SPDXVersion string                   `json:"spdxVersion"`
+		Name        string                   `json:"name"`
+		Packages    []containers.SBOMPackage `json:"packages"`
+	}
+)
+
+// attestations - аттестации сборки фейка: пакетами образа считаются базовые
+// образы Dockerfile, материалами provenance - они же с дайджестами стора
+func (cli *Client) attestations(data *containers.ImageBuildData) (*containers.Attestations, error) {
+	if !data.Provenance && !data.SBOM {
+		return nil, nil
+	}
+
+	dockerfile := data.Dockerfile
+	if dockerfile == "" {
+		dockerfile = "Dockerfile"
+	}
+
+	content, err := os.ReadFile(filepath.Join(data.Root, dockerfile))
+	if err != nil {
+		return nil, errors.Ctx().Str("dockerfile", dockerfile).Wrap(err, "read dockerfile")
+	}
+
+	bases := baseImages(content)
+	attestations := &containers.Attestations{}
+
+	if data.Provenance {
+		p := slsaProvenance{Builder: slsaBuilder{ID: "fake"}, BuildType: "fake/dockerfile"}
+
+		for _, ref := range bases {
+			m := slsaMaterial{URI: "pkg:docker/" + ref}
+
+			cli.mu.Lock()
+			digest, ok := cli.images[normalizeRef(ref)]
+			cli.mu.Unlock()
+
+			if ok {
+				m.Digest = map[string]string{"sha256": strings.TrimPrefix(digest, "sha256:")}
+			}
+
+			p.Materials = append(p.Materials, m)
+		}
+
+		if attestations.Provenance, err = json.Marshal(p); err != nil {
+			return nil, errors.Wrap(err, "encode provenance")
+		}
+	}
+
+	if data.SBOM {
+		doc := spdxDocument{SPDXVersion: "SPDX-2.3", Name: strings.Join(data.Tags, ",")}
+
+		for _, ref := range bases {
+			name, version := ref, ""
+			if i := strings.LastIndex(ref, ":"); i > strings.LastIndex(ref, "/") {
+				name, version = ref[:i], ref[i+1:]
+			}
+
+			doc.Packages = append(doc.Packages, containers.SBOMPackage{Name: name, Version: version})
+		}
+
+		if attestations.SBOM, err = json.Marshal(doc); err != nil {
+			return nil, errors.Wrap(err, "encode sbom")
+		}
+	}
+
+	return attestations, nil
+}
+
+// baseImages - образы инструкций FROM без ссылок на предыдущие стадии и scratch
+func baseImages(dockerfile []byte) []string {
+	stages := make(map[string]struct{})
+
+	var result []string
+
+	scanner := bufio.NewScanner(bytes.NewReader(dockerfile))
+
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 || !strings.EqualFold(fields[0], "FROM") {
+			continue
+		}
+
+		fields = fields[1:]
+
+		for len(fields) != 0 && strings.HasPrefix(fields[0], "--") {
+			fields = fields[1:]
+		}
+
+		if len(fields) == 0 {
+			continue
+		}
+
+		ref := fields[0]
+		_, stage := stages[strings.ToLower(ref)]
+
+		if len(fields) >= 3 && strings.EqualFold(fields[1], "AS") {
+			stages[strings.ToLower(fields[2])] = struct{}{}
+		}
+
+		if !stage && ref != "scratch" {
+			result = append(result, ref)
+		}
+	}
+
+	return result
+}
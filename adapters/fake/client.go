@@ -0,0 +1,1431 @@
+// Package fake - реализация containers.Client в памяти для модульных тестов
+// кода оркестрации без демона docker: фейковые сети с детерминированными
+// подсетями, сценарии жизненного цикла контейнеров и внедрение логов
+package fake
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net"
+	"os"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"gopkg.in/gomisc/containers.v1"
+	"gopkg.in/gomisc/errors.v1"
+)
+
+// Ошибки фейкового клиента
+const (
+	ErrNoSuchContainer = errors.Const("no such container")
+	ErrNoSuchNetwork   = errors.Const("no such network")
+	ErrNoSuchPath      = errors.Const("no such path in container")
+	ErrNameConflict    = errors.Const("container name already in use")
+	ErrAmbiguousID     = errors.Const("multiple containers match id prefix")
+	// ErrAlreadyConnected, ErrNotConnected - ошибки подключения контейнера к сети
+	ErrAlreadyConnected = errors.Const("container already connected to network")
+	ErrNotConnected     = errors.Const("container is not connected to network")
+)
+
+// Состояния контейнера
+const (
+	StatusCreated = containers.StateCreated
+	StatusRunning = containers.StateRunning
+	StatusExited  = containers.StateExited
+	StatusPaused  = containers.StatePaused
+)
+
+const (
+	// firstHostPort - первый порт, назначаемый без слушающих сокетов
+	firstHostPort = 32768
+	defaultPool   = containers.DefaultSubnetPool
+	defaultPrefix = containers.DefaultSubnetPrefix
+	// statsInterval - период замеров ContainerStats
+	statsInterval = 100 * time.Millisecond
+)
+
+var (
+	_ containers.ExtendedClient = (*Client)(nil)
+	_ containers.HooksRuntime   = (*Client)(nil)
+	_ containers.SessionClient  = (*Client)(nil)
+	_ containers.SubnetReleaser = (*Client)(nil)
+
+	_ containers.RegistryNetwork = (*Network)(nil)
+)
+
+// terminatingSignals - сигналы, завершающие фейковый процесс, и их номера
+var terminatingSignals = map[string]int64{
+	"INT": 2, "2": 2,
+	"QUIT": 3, "3": 3,
+	"KILL": 9, "9": 9,
+	"TERM": 15, "15": 15,
+}
+
+// sessions - счетчик сессий фейковых клиентов процесса
+var sessions atomic.Int64
+
+// imageMeta - сведения об образе для отбора PruneImages
+type imageMeta struct {
+	created      time.Time
+	labels       map[string]string
+	attestations *containers.Attestations
+}
+
+// Client - фейковый клиент среды исполнения контейнеров
+type Client struct {
+	session string
+	opts    options
+	stdout  io.Writer
+	stderr  io.Writer
+	subnets *containers.SubnetAllocator
+	// subnets6 - аллокатор подсетей IPv6, nil - сети только с IPv4
+	subnets6 *containers.SubnetAllocator
+	events   eventBus
+
+	mu         sync.Mutex
+	seq        int
+	hostPort   int
+	images     map[string]string
+	imageFiles map[string]map[string][]byte
+	imageMeta  map[string]imageMeta
+	commits    map[string]containers.CommitOptions
+	pushes     map[string]containers.RegistryAuth
+	pulls      map[string]containers.PullOptions
+	pullErrors map[string]error
+	networks   map[string]*Network
+	volumes    map[string]containers.VolumeInfo
+	containers map[string]*container
+	names      map[string]string
+	scripts    map[string]Script
+}
+
+// New - конструктор фейкового клиента
+func New(opts ...Option) (*Client, error) {
+	o := options{
+		subnetPool:   defaultPool,
+		subnetPrefix: defaultPrefix,
+	}
+
+	for _, apply := range opts {
+		apply(&o)
+	}
+
+	if o.project != "" {
+		if err := o.project.Validate(); err != nil {
+			return nil, err
+		}
+
+		// сети проекта не пересекаются с сетями других проектов
+		if o.subnetPool == defaultPool {
+			pool, err := o.project.SubnetPool(o.subnetPool, 0)
+			if err != nil {
+				return nil, errors.Wrap(err, "select project subnet pool")
+			}
+
+			o.subnetPool = pool
+		}
+	}
+
+	subnets, err := containers.NewSubnetAllocator(o.subnetPool, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "create subnet allocator")
+	}
+
+	var subnets6 *containers.SubnetAllocator
+
+	if o.ipv6 {
+		if o.ipv6Pool == "" {
+			o.ipv6Pool = containers.DefaultSubnetPool6
+		}
+
+		if o.ipv6Prefix == 0 {
+			o.ipv6Prefix = containers.DefaultSubnetPrefix6
+		}
+
+		if subnets6, err = containers.NewSubnetAllocator(o.ipv6Pool, nil); err != nil {
+			return nil, errors.Wrap(err, "create ipv6 subnet allocator")
+		}
+	}
+
+	cli := &Client{
+		session:    "fake-" + strconv.FormatInt(sessions.Add(1), 10),
+		opts:       o,
+		stdout:     o.stdout,
+		stderr:     o.stderr,
+		subnets:    subnets,
+		subnets6:   subnets6,
+		hostPort:   firstHostPort,
+		images:     make(map[string]string),
+		imageFiles: make(map[string]map[string][]byte),
+		imageMeta:  make(map[string]imageMeta),
+		commits:    make(map[string]containers.CommitOptions),
+		pushes:     make(map[string]containers.RegistryAuth),
+		pulls:      make(map[string]containers.PullOptions),
+		pullErrors: make(map[string]error),
+		networks:   make(map[string]*Network),
+		volumes:    make(map[string]containers.VolumeInfo),
+		containers: make(map[string]*container),
+		names:      make(map[string]string),
+		scripts:    make(map[string]Script),
+	}
+
+	if cli.stdout == nil {
+		cli.stdout = io.Discard
+	}
+
+	if cli.stderr == nil {
+		cli.stderr = io.Discard
+	}
+
+	for _, ref := range o.images {
+		cli.addImage(ref)
+	}
+
+	return cli, nil
+}
+
+// Script - задает сценарий контейнеров с именем name, пустое имя - сценарий
+// по умолчанию для всех контейнеров без собственного сценария
+func (cli *Client) Script(name string, s Script) {
+	cli.mu.Lock()
+	defer cli.mu.Unlock()
+
+	cli.scripts[name] = s
+}
+
+// SetPullError - PullImage образа ref будет завершаться ошибкой err (nil - снимает ошибку)
+func (cli *Client) SetPullError(ref string, err error) {
+	cli.mu.Lock()
+	defer cli.mu.Unlock()
+
+	if err == nil {
+		delete(cli.pullErrors, normalizeRef(ref))
+
+		return
+	}
+
+	cli.pullErrors[normalizeRef(ref)] = err
+}
+
+// Log - добавляет строку в лог контейнера (по имени или идентификатору)
+func (cli *Client) Log(nameOrID string, stream Stream, text string) error {
+	c, err := cli.lookup(nameOrID)
+	if err != nil {
+		return err
+	}
+
+	c.log(LogLine{Stream: stream, Text: text})
+
+	return nil
+}
+
+// Exit - завершает запущенный контейнер с кодом code, имитируя падение процесса
+func (cli *Client) Exit(nameOrID string, code int64) error {
+	c, err := cli.lookup(nameOrID)
+	if err != nil {
+		return err
+	}
+
+	cli.exit(c, code)
+
+	return nil
+}
+
+// Container - снимок состояния контейнера по имени или идентификатору
+func (cli *Client) Container(nameOrID string) (State, bool) {
+	c, err := cli.lookup(nameOrID)
+	if err != nil {
+		return State{}, false
+	}
+
+	return c.state(), true
+}
+
+// Containers - снимки состояния всех контейнеров
+func (cli *Client) Containers() []State {
+	cli.mu.Lock()
+	list := make([]*container, 0, len(cli.containers))
+
+	for _, c := range cli.containers {
+		list = append(list, c)
+	}
+	cli.mu.Unlock()
+
+	states := make([]State, 0, len(list))
+
+	for _, c := range list {
+		states = append(states, c.state())
+	}
+
+	sortStates(states)
+
+	return states
+}
+
+// Commit - параметры, с которыми образ ref был сохранен из контейнера
+func (cli *Client) Commit(ref string) (containers.CommitOptions, bool) {
+	cli.mu.Lock()
+	defer cli.mu.Unlock()
+
+	o, ok := cli.commits[normalizeRef(ref)]
+
+	return o, ok
+}
+
+// Pulled - параметры (учетные данные, платформа), с которыми образ ref был скачан
+func (cli *Client) Pulled(ref string) (containers.PullOptions, bool) {
+	cli.mu.Lock()
+	defer cli.mu.Unlock()
+
+	opts, ok := cli.pulls[normalizeRef(ref)]
+
+	return opts, ok
+}
+
+// Pushed - учетные данные, с которыми образ ref был опубликован PushImage
+func (cli *Client) Pushed(ref string) (containers.RegistryAuth, bool) {
+	cli.mu.Lock()
+	defer cli.mu.Unlock()
+
+	auth, ok := cli.pushes[normalizeRef(ref)]
+
+	return auth, ok
+}
+
+// Session - идентификатор сессии клиента
+func (cli *Client) Session() string {
+	return cli.session
+}
+
+// Images - ссылки образов локального стора
+func (cli *Client) Images() []string {
+	cli.mu.Lock()
+	defer cli.mu.Unlock()
+
+	refs := make([]string, 0, len(cli.images))
+
+	for ref := range cli.images {
+		refs = append(refs, ref)
+	}
+
+	sort.Strings(refs)
+
+	return refs
+}
+
+func (cli *Client) WithStdout(w io.Writer) containers.Client {
+	cli.stdout = w
+
+	return cli
+}
+
+func (cli *Client) WithStderr(w io.Writer) containers.Client {
+	cli.stderr = w
+
+	return cli
+}
+
+func (cli *Client) Stdout() io.Writer {
+	return cli.stdout
+}
+
+func (cli *Client) Stderr() io.Writer {
+	return cli.stderr
+}
+
+func (cli *Client) Info(context.Context) (*containers.DaemonInfo, error) {
+	if cli.opts.info != nil {
+		info := *cli.opts.info
+
+		return &info, nil
+	}
+
+	return &containers.DaemonInfo{
+		Name:            "fake",
+		ServerVersion:   "fake",
+		OperatingSystem: "fake",
+		OSType:          "linux",
+		Architecture:    runtime.GOARCH,
+		NCPU:            runtime.NumCPU(),
+		CgroupVersion:   "2",
+		CgroupDriver:    "fake",
+		Limits: containers.LimitsSupport{
+			Memory:    true,
+			Swap:      true,
+			CPUQuota:  true,
+			CPUShares: true,
+			CPUSet:    true,
+			Pids:      true,
+		},
+	}, nil
+}
+
+func (cli *Client) IsInContainer() bool {
+	return false
+}
+
+func (cli *Client) NetworkList(context.Context) ([]*net.IPNet, error) {
+	cli.mu.Lock()
+	defer cli.mu.Unlock()
+
+	list := make([]*net.IPNet, 0, len(cli.networks))
+
+	for _, nw := range cli.networks {
+		list = append(list, nw.subnet)
+
+		if nw.subnet6 != nil {
+			list = append(list, nw.subnet6)
+		}
+	}
+
+	return list, nil
+}
+
+func (cli *Client) NextSubnet() (*net.IPNet, error) {
+	subnet, err := cli.subnets.Allocate(context.Background(), cli.opts.subnetPrefix)
+	if err != nil {
+		return nil, errors.Wrap(err, "allocate subnet")
+	}
+
+	return subnet, nil
+}
+
+// ReleaseSubnet - возвращает в пул подсеть, выданную NextSubnet
+func (cli *Client) ReleaseSubnet(subnet *net.IPNet) {
+	switch {
+	case subnet == nil:
+	case subnet.IP.To4() != nil:
+		cli.subnets.Release(subnet)
+	case cli.subnets6 != nil:
+		cli.subnets6.Release(subnet)
+	}
+}
+
+func (cli *Client) RemoveNetwork(id string) error {
+	cli.mu.Lock()
+	defer cli.mu.Unlock()
+
+	for name, nw := range cli.networks {
+		if nw.id == id || nw.name == id {
+			delete(cli.networks, name)
+			cli.subnets.Release(nw.subnet)
+
+			if cli.subnets6 != nil {
+				cli.subnets6.Release(nw.subnet6)
+			}
+
+			return nil
+		}
+	}
+
+	return errors.Ctx().Str("network", id).Just(ErrNoSuchNetwork)
+}
+
+func (cli *Client) CheckNetwork(name, cidr string) (containers.Network, error) {
+	cli.mu.Lock()
+	nw, ok := cli.networks[name]
+	cli.mu.Unlock()
+
+	if ok {
+		return nw, nil
+	}
+
+	subnet, subnet6, err := containers.SplitSubnets(cidr)
+	if err != nil {
+		return nil, errors.Wrap(err, "parse network cidr")
+	}
+
+	if subnet == nil {
+		if subnet, err = cli.NextSubnet(); err != nil {
+			return nil, errors.Wrap(err, "create network")
+		}
+	}
+
+	if subnet6 == nil && cli.subnets6 != nil {
+		if subnet6, err = cli.subnets6.Allocate(context.Background(), cli.opts.ipv6Prefix); err != nil {
+			cli.ReleaseSubnet(subnet)
+
+			return nil, errors.Wrap(err, "create network")
+		}
+	}
+
+	cli.mu.Lock()
+	defer cli.mu.Unlock()
+
+	// сеть создана параллельным вызовом, выделенные подсети не нужны
+	if nw, ok = cli.networks[name]; ok {
+		cli.ReleaseSubnet(subnet)
+
+		cli.ReleaseSubnet(subnet6)
+
+		return nw, nil
+	}
+
+	nw = newNetwork(cli.nextID("network"), name, subnet)
+	nw.internal = containers.Hermetic().Enabled()
+	nw.labels = cli.labelProject(nil)
+	nw.enableIPv6(subnet6)
+	cli.networks[name] = nw
+
+	return nw, nil
+}
+
+func (cli *Client) ContainerList(_ context.Context, filter containers.ListFilter) ([]containers.ContainerSummary, error) {
+	cli.mu.Lock()
+	list := make([]*container, 0, len(cli.containers))
+
+	for _, c := range cli.containers {
+		list = append(list, c)
+	}
+	cli.mu.Unlock()
+
+	result := make([]containers.ContainerSummary, 0, len(list))
+
+	for _, c := range list {
+		c.mu.Lock()
+		summary := containers.ContainerSummary{
+			ID:      c.id,
+			Name:    c.name,
+			Image:   c.image,
+			Status:  c.status,
+			Created: c.created,
+			Labels:  copyLabels(c.labels),
+		}
+		c.mu.Unlock()
+
+		if filter.Match(&summary) {
+			result = append(result, summary)
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+
+	return result, nil
+}
+
+func (cli *Client) ContainerCreate(ctx context.Context, spec containers.Container) (string, error) {
+	data := containers.ExtendContainer(spec)
+
+	if err := ctx.Err(); err != nil {
+		return "", errors.Ctx().Str("name", data.GetName()).Wrap(err, "create container")
+	}
+
+	cli.mu.Lock()
+	defer cli.mu.Unlock()
+
+	script := cli.scriptFor(data.GetName())
+	if script.CreateErr != nil {
+		return "", errors.Ctx().Str("name", data.GetName()).Wrap(script.CreateErr, "create container")
+	}
+
+	if _, ok := cli.images[normalizeRef(data.GetImage())]; !ok {
+		return "", errors.Ctx().Str("image", data.GetImage()).Just(containers.ErrImageNotFound)
+	}
+
+	if _, ok := cli.names[data.GetName()]; ok {
+		return "", errors.Ctx().Str("name", data.GetName()).Just(ErrNameConflict)
+	}
+
+	c := &container{
+		id:         cli.nextID(data.GetName()),
+		name:       data.GetName(),
+		image:      data.GetImage(),
+		envs:       append([]string(nil), data.GetEnvs()...),
+		cmd:        append([]string(nil), data.GetCmd()...),
+		ip:         data.GetContainerIP(),
+		autoremove: data.GetAutoremove(),
+		mounts:     append([]containers.MountSpec(nil), data.GetMountSpecs()...),
+		hooks:      data.GetHooks(),
+		extraHosts: append([]string(nil), data.GetExtraHosts()...),
+		volumes:    append([]containers.VolumeSpec(nil), data.GetNamedVolumes()...),
+		tmpfs:      copyLabels(data.GetTmpfs()),
+		created:    time.Now(),
+		ports:      copyPortMap(data.PortMap()),
+		status:     StatusCreated,
+		files:      copyFiles(cli.imageFiles[normalizeRef(data.GetImage())]),
+		changed:    make(chan struct{}),
+		script:     script,
+		resources:  data.GetResources(),
+		dns:        append([]string(nil), data.GetDNS()...),
+		dnsSearch:  append([]string(nil), data.GetDNSSearch()...),
+		devices:    append([]containers.Device(nil), data.GetDevices()...),
+		gpus:       append([]containers.GPURequest(nil), data.GetGPUs()...),
+		user:       data.GetUser(),
+		workDir:    data.GetWorkDir(),
+		labels:     cli.labelProject(copyLabels(data.GetLabels())),
+	}
+
+	if hc := data.GetHealthcheck(); hc != nil {
+		healthcheck := *hc
+		healthcheck.Test = append([]string(nil), hc.Test...)
+		c.healthcheck = &healthcheck
+	}
+
+	if nw, ok := data.GetNetwork().(*Network); ok && nw != nil {
+		c.network = nw
+	}
+
+	for _, att := range data.GetExtraNetworks() {
+		if nw, ok := att.Network.(*Network); ok && nw != nil {
+			c.extraNets = append(c.extraNets, newAttachment(nw, att.IP, att.Aliases))
+		}
+	}
+
+	// отсутствующие тома создаются вместе с контейнером, как это делает демон
+	for _, v := range c.volumes {
+		cli.ensureVolume(v)
+	}
+
+	cli.containers[c.id] = c
+	cli.names[c.name] = c.id
+
+	cli.emit(c, containers.EventCreate, nil)
+
+	return c.id, nil
+}
+
+func (cli *Client) ContainerStart(ctx context.Context, id, name string) (*containers.ContainerInfo, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, errors.Ctx().Str("name", name).Wrap(err, "start container")
+	}
+
+	c, err := cli.lookup(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.script.StartErr != nil {
+		return nil, errors.Ctx().Str("name", name).Wrap(c.script.StartErr, "start container")
+	}
+
+	if err = cli.start(c); err != nil {
+		return nil, errors.Ctx().Str("name", name).Wrap(err, "start container")
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	info := &containers.ContainerInfo{
+		ID:          c.id,
+		IPAddress:   c.ip,
+		IPv6Address: c.ip6,
+		PortBinds:   copyPortMap(c.binds),
+		Networks:    make(map[string]containers.EndpointSettings),
+		Labels:      copyLabels(c.labels),
+	}
+
+	c.endpoints(info.Networks)
+
+	return info, nil
+}
+
+func (cli *Client) ContainerInspect(_ context.Context, id string) (*containers.InspectResult, error) {
+	c, err := cli.lookup(id)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.inspect(), nil
+}
+
+func (cli *Client) ContainerWait(ctx context.Context, id string) (<-chan containers.ContainerStatus, <-chan error) {
+	statusCh := make(chan containers.ContainerStatus, 1)
+	errCh := make(chan error, 1)
+
+	c, err := cli.lookup(id)
+	if err != nil {
+		errCh <- err
+
+		return statusCh, errCh
+	}
+
+	c.mu.Lock()
+	exit := c.exit
+	c.mu.Unlock()
+
+	go func() {
+		select {
+		case <-exit:
+			c.mu.Lock()
+			statusCh <- containers.ContainerStatus{StatusCode: c.exitCode}
+			c.mu.Unlock()
+		case <-ctx.Done():
+			errCh <- ctx.Err()
+		}
+	}()
+
+	return statusCh, errCh
+}
+
+func (cli *Client) ContainerStop(_ context.Context, id string, _ time.Duration) error {
+	c, err := cli.lookup(id)
+	if err != nil {
+		return err
+	}
+
+	cli.exit(c, c.script.StopCode)
+
+	return nil
+}
+
+func (cli *Client) ContainerRestart(_ context.Context, id string, _ time.Duration) error {
+	c, err := cli.lookup(id)
+	if err != nil {
+		return err
+	}
+
+	cli.exit(c, c.script.StopCode)
+
+	c.mu.Lock()
+	c.restarts++
+	c.mu.Unlock()
+
+	return cli.start(c)
+}
+
+// NativeHooks - фейковая среда принимает OCI хуки при создании и сохраняет
+// их в State, поэтому BaseContainer их не эмулирует
+func (cli *Client) NativeHooks() bool {
+	return true
+}
+
+// ContainerKill - записывает сигнал; завершающие сигналы останавливают
+// контейнер с кодом 128+номер сигнала, остальные процесс "обрабатывает"
+func (cli *Client) ContainerKill(_ context.Context, id, signal string) error {
+	c, err := cli.lookup(id)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	running := c.status == StatusRunning || c.status == StatusPaused
+	c.signals = append(c.signals, signal)
+	c.mu.Unlock()
+
+	if !running {
+		return errors.Ctx().Str("name", c.name).Just(containers.ErrContainerNotRunning)
+	}
+
+	cli.emit(c, containers.EventKill, map[string]string{"signal": signal})
+
+	if code, ok := terminatingSignals[strings.TrimPrefix(strings.ToUpper(signal), "SIG")]; ok {
+		cli.exit(c, 128+code)
+	}
+
+	return nil
+}
+
+// ContainerStats - выдает замеры сценария с периодом statsInterval, пока
+// контейнер работает
+func (cli *Client) ContainerStats(ctx context.Context, id string) (<-chan containers.StatsSample, error) {
+	c, err := cli.lookup(id)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	exit, running := c.exit, c.status == StatusRunning || c.status == StatusPaused
+	c.mu.Unlock()
+
+	if !running {
+		return nil, errors.Ctx().Str("name", c.name).Just(containers.ErrContainerNotRunning)
+	}
+
+	scripted := c.script.Stats
+	if len(scripted) == 0 {
+		scripted = []containers.StatsSample{{Pids: 1}}
+	}
+
+	memoryLimit := uint64(c.resources.MemoryLimit)
+
+	samples := make(chan containers.StatsSample)
+
+	go func() {
+		defer close(samples)
+
+		ticker := time.NewTicker(statsInterval)
+		defer ticker.Stop()
+
+		for i := 0; ; i++ {
+			sample := scripted[len(scripted)-1]
+			if i < len(scripted) {
+				sample = scripted[i]
+			}
+
+			sample.Time = time.Now()
+
+			if sample.MemoryLimit == 0 {
+				sample.MemoryLimit = memoryLimit
+			}
+
+			select {
+			case samples <- sample:
+			case <-exit:
+				return
+			case <-ctx.Done():
+				return
+			}
+
+			select {
+			case <-ticker.C:
+			case <-exit:
+				return
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return samples, nil
+}
+
+func (cli *Client) ContainerPause(_ context.Context, id string) error {
+	return cli.setPaused(id, StatusRunning, StatusPaused)
+}
+
+func (cli *Client) ContainerUnpause(_ context.Context, id string) error {
+	return cli.setPaused(id, StatusPaused, StatusRunning)
+}
+
+// setPaused - переводит контейнер из состояния from в to; сокеты опубликованных
+// портов остаются открытыми, как у замороженного процесса
+func (cli *Client) setPaused(id, from, to string) error {
+	c, err := cli.lookup(id)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.status != from {
+		return errors.Ctx().Str("name", c.name).Str("status", c.status).Just(containers.ErrContainerNotRunning)
+	}
+
+	c.status = to
+	c.notify()
+
+	action := containers.EventPause
+	if to == StatusRunning {
+		action = containers.EventUnpause
+	}
+
+	cli.emit(c, action, nil)
+
+	return nil
+}
+
+func (cli *Client) ContainerExec(_ context.Context, id string, cmd []string, stdout, stderr io.Writer) (int, error) {
+	c, err := cli.lookup(id)
+	if err != nil {
+		return 0, err
+	}
+
+	c.mu.Lock()
+	running := c.status == StatusRunning
+	c.execs = append(c.execs, append([]string(nil), cmd...))
+	c.mu.Unlock()
+
+	if !running {
+		return 0, errors.Ctx().Str("name", c.name).Just(containers.ErrContainerNotRunning)
+	}
+
+	if c.script.Exec == nil {
+		return 0, nil
+	}
+
+	if stdout == nil {
+		stdout = io.Discard
+	}
+
+	if stderr == nil {
+		stderr = io.Discard
+	}
+
+	return c.script.Exec(cmd, stdout, stderr), nil
+}
+
+func (cli *Client) ContainerRemove(_ context.Context, id string) error {
+	c, err := cli.lookup(id)
+	if err != nil {
+		// как и docker адаптер, отсутствие контейнера ошибкой не считаем
+		return nil //nolint:nilerr
+	}
+
+	cli.exit(c, c.script.StopCode)
+	cli.remove(c)
+
+	return nil
+}
+
+// ContainerCommit - регистрирует образ; файлы, скопированные в контейнер,
+// попадают в образ и достаются контейнерам, созданным из него. Как и у
+// демона, содержимое томов в образ не попадает
+func (cli *Client) ContainerCommit(
+	_ context.Context, id, tag string, opts ...containers.CommitOption,
+) (string, error) {
+	c, err := cli.lookup(id)
+	if err != nil {
+		return "", err
+	}
+
+	digest := imageDigest(c.id + "/" + tag)
+
+	c.mu.Lock()
+	files := copyFiles(c.files)
+
+	for _, m := range c.inspectMounts() {
+		if m.Type == containers.MountVolume {
+			removeTree(files, m.Destination)
+		}
+	}
+	c.mu.Unlock()
+
+	ref := normalizeRef(tag)
+
+	cli.mu.Lock()
+	cli.images[ref] = digest
+	cli.imageFiles[ref] = files
+	cli.imageMeta[ref] = imageMeta{created: time.Now()}
+	cli.commits[ref] = containers.NewCommitOptions(opts...)
+	cli.mu.Unlock()
+
+	return digest, nil
+}
+
+func (cli *Client) CopyToContainer(_ context.Context, id, dst string, content io.Reader) error {
+	c, err := cli.lookup(id)
+	if err != nil {
+		return err
+	}
+
+	files, err := readArchive(content, dst)
+	if err != nil {
+		return errors.Ctx().Str("dst", dst).Wrap(err, "copy to container")
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for p, data := range files {
+		c.files[p] = data
+	}
+
+	return nil
+}
+
+func (cli *Client) CopyFromContainer(_ context.Context, id, src string) (io.ReadCloser, error) {
+	c, err := cli.lookup(id)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	archive, err := writeArchive(c.files, src)
+	if err != nil {
+		return nil, errors.Ctx().Str("src", src).Wrap(err, "copy from container")
+	}
+
+	return io.NopCloser(archive), nil
+}
+
+func (cli *Client) StreamLogs(ctx context.Context, id string, stderr, stdout io.Writer, follow bool) error {
+	if stderr == nil && stdout == nil {
+		return nil
+	}
+
+	c, err := cli.lookup(id)
+	if err != nil {
+		return err
+	}
+
+	var next int
+
+	for {
+		c.mu.Lock()
+		lines := c.logs[next:]
+		next = len(c.logs)
+		changed := c.changed
+		running := c.status == StatusRunning
+		c.mu.Unlock()
+
+		for _, line := range lines {
+			w := stdout
+			if line.Stream == Stderr {
+				w = stderr
+			}
+
+			if w == nil {
+				continue
+			}
+
+			if _, err = io.WriteString(w, line.Text+"\n"); err != nil {
+				return errors.Wrap(err, "write container logs")
+			}
+		}
+
+		if !follow || !running {
+			return nil
+		}
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func (cli *Client) FindImageLocal(_ context.Context, image string) (bool, error) {
+	cli.mu.Lock()
+	defer cli.mu.Unlock()
+
+	_, ok := cli.images[normalizeRef(image)]
+
+	return ok, nil
+}
+
+func (cli *Client) FindImagesLocal(_ context.Context, refs []string) (map[string]bool, error) {
+	cli.mu.Lock()
+	defer cli.mu.Unlock()
+
+	found := make(map[string]bool, len(refs))
+
+	for _, ref := range refs {
+		_, found[ref] = cli.images[normalizeRef(ref)]
+	}
+
+	return found, nil
+}
+
+func (cli *Client) ImageDigest(_ context.Context, image string) (string, error) {
+	cli.mu.Lock()
+	defer cli.mu.Unlock()
+
+	digest, ok := cli.images[normalizeRef(image)]
+	if !ok {
+		return "", errors.Ctx().Str("image", image).Just(containers.ErrImageNotFound)
+	}
+
+	return digest, nil
+}
+
+// ImageInspect - сведения об образе стора фейка, платформа - платформа хоста
+func (cli *Client) ImageInspect(_ context.Context, image string) (*containers.ImageDetails, error) {
+	cli.mu.Lock()
+	defer cli.mu.Unlock()
+
+	ref := normalizeRef(image)
+
+	digest, ok := cli.images[ref]
+	if !ok {
+		return nil, errors.Ctx().Str("image", image).Just(containers.ErrImageNotFound)
+	}
+
+	meta := cli.imageMeta[ref]
+	details := &containers.ImageDetails{
+		ID:           digest,
+		RepoTags:     []string{ref},
+		Created:      meta.created,
+		Platform:     runtime.GOOS + "/" + runtime.GOARCH,
+		Labels:       make(map[string]string, len(meta.labels)),
+		Attestations: meta.attestations,
+	}
+
+	for k, v := range meta.labels {
+		details.Labels[k] = v
+	}
+
+	return details, nil
+}
+
+func (cli *Client) PullImage(image string) error {
+	return cli.PullImageWith(context.Background(), containers.PullOptions{Image: image})
+}
+
+func (cli *Client) PullImageWith(_ context.Context, opts containers.PullOptions) error {
+	image := opts.Image
+
+	if err := containers.Hermetic().CheckPull(image); err != nil {
+		return err
+	}
+
+	cli.mu.Lock()
+	err := cli.pullErrors[normalizeRef(image)]
+	cli.mu.Unlock()
+
+	if err != nil {
+		return errors.Ctx().Str("image", image).Wrap(err, "pull image")
+	}
+
+	cli.mu.Lock()
+	cli.pulls[normalizeRef(image)] = opts
+	cli.mu.Unlock()
+
+	cli.addImage(image)
+
+	return nil
+}
+
+func (cli *Client) PushImage(_ context.Context, tag string, auth containers.RegistryAuth) error {
+	cli.mu.Lock()
+	defer cli.mu.Unlock()
+
+	ref := normalizeRef(tag)
+
+	if _, ok := cli.images[ref]; !ok {
+		return errors.Ctx().Str("image", tag).Just(containers.ErrImageNotFound)
+	}
+
+	cli.pushes[ref] = auth
+
+	return nil
+}
+
+func (cli *Client) RemoveImage(image string) {
+	cli.mu.Lock()
+	defer cli.mu.Unlock()
+
+	ref := normalizeRef(image)
+
+	delete(cli.images, ref)
+	delete(cli.imageFiles, ref)
+	delete(cli.imageMeta, ref)
+	delete(cli.commits, ref)
+}
+
+func (cli *Client) BuildImage(data *containers.ImageBuildData) error {
+	if data.ClearRoot {
+		defer func() {
+			_ = os.RemoveAll(data.Root)
+		}()
+	}
+
+	attestations, err := cli.attestations(data)
+	if err != nil {
+		return errors.Ctx().Strings("tags", data.Tags).Wrap(err, "attest image build")
+	}
+
+	labels := make(map[string]string, len(data.Labels))
+
+	for k, v := range data.Labels {
+		labels[k] = v
+	}
+
+	labels = cli.labelProject(labels)
+
+	for _, tag := range data.Tags {
+		cli.addImage(tag)
+
+		cli.mu.Lock()
+		cli.imageMeta[normalizeRef(tag)] = imageMeta{created: time.Now(), labels: labels, attestations: attestations}
+		cli.mu.Unlock()
+	}
+
+	if data.Output != nil {
+		_, _ = io.WriteString(data.Output, "fake build: "+strings.Join(data.Tags, ", ")+"\n")
+	}
+
+	return nil
+}
+
+// PruneImages - удаляет образы, на которые не ссылаются контейнеры; висячих
+// образов в сторе фейка не бывает, поэтому без opts.All ничего не удаляется
+func (cli *Client) PruneImages(_ context.Context, opts containers.PruneOptions) (containers.PruneReport, error) {
+	var report containers.PruneReport
+
+	if !opts.All {
+		return report, nil
+	}
+
+	cli.mu.Lock()
+	defer cli.mu.Unlock()
+
+	used := make(map[string]bool, len(cli.containers))
+
+	for _, c := range cli.containers {
+		used[normalizeRef(c.image)] = true
+	}
+
+	now := time.Now()
+
+	for ref := range cli.images {
+		meta := cli.imageMeta[ref]
+		if used[ref] || !opts.Match(meta.labels, meta.created, now) {
+			continue
+		}
+
+		for _, content := range cli.imageFiles[ref] {
+			report.SpaceReclaimed += uint64(len(content))
+		}
+
+		report.Deleted = append(report.Deleted, ref)
+
+		delete(cli.images, ref)
+		delete(cli.imageFiles, ref)
+		delete(cli.imageMeta, ref)
+		delete(cli.commits, ref)
+	}
+
+	sort.Strings(report.Deleted)
+
+	return report, nil
+}
+
+// PruneBuildCache - у фейка нет кеша сборки, проверяются только условия
+func (cli *Client) PruneBuildCache(
+	_ context.Context, opts containers.BuildCachePruneOptions,
+) (containers.PruneReport, error) {
+	if err := opts.Validate(); err != nil {
+		return containers.PruneReport{}, err
+	}
+
+	return containers.PruneReport{}, nil
+}
+
+// start - запускает контейнер: выдает адрес в сети, публикует порты и
+// выводит сценарные логи
+func (cli *Client) start(c *container) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.status == StatusRunning {
+		return nil
+	}
+
+	if c.network != nil {
+		if c.ip == "" {
+			c.ip = c.network.NextIP()
+		}
+
+		if c.ip6 == "" {
+			c.ip6 = c.network.NextIP6()
+		}
+	}
+
+	for i := range c.extraNets {
+		c.extraNets[i].assign()
+	}
+
+	if err := cli.publish(c); err != nil {
+		return err
+	}
+
+	c.status = StatusRunning
+	c.exit = make(chan struct{})
+	c.exitCode = 0
+	c.started = time.Now()
+
+	cli.watchHealth(c)
+	cli.emit(c, containers.EventStart, nil)
+
+	c.logs = append(c.logs, c.script.Logs...)
+
+	c.notify()
+
+	if c.script.ExitAfter > 0 {
+		exit, code := c.exit, c.script.ExitCode
+
+		time.AfterFunc(
+			c.script.ExitAfter, func() {
+				select {
+				case <-exit:
+				default:
+					cli.exit(c, code)
+				}
+			},
+		)
+	}
+
+	return nil
+}
+
+// publish - назначает порты хоста; без WithoutListeners на каждом порту
+// открывается слушающий сокет, принимающий и сразу закрывающий соединения
+func (cli *Client) publish(c *container) error {
+	c.binds = make(containers.PortMap, len(c.ports))
+
+	for port, binds := range c.ports {
+		for _, bind := range binds {
+			hostPort := bind.HostPort
+
+			if cli.opts.noListeners {
+				if hostPort == "" || hostPort == "0" {
+					cli.mu.Lock()
+					hostPort = strconv.Itoa(cli.hostPort)
+					cli.hostPort++
+					cli.mu.Unlock()
+				}
+			} else {
+				l, err := net.Listen("tcp", net.JoinHostPort(hostIP, hostPort))
+				if err != nil {
+					c.closeListeners()
+
+					return errors.Ctx().Str("port", string(port)).Wrap(err, "publish port")
+				}
+
+				go accept(l)
+
+				c.listeners = append(c.listeners, l)
+				_, hostPort, _ = net.SplitHostPort(l.Addr().String())
+			}
+
+			c.binds[port] = append(c.binds[port], containers.PortBinding{HostIP: bind.HostIP, HostPort: hostPort})
+		}
+	}
+
+	return nil
+}
+
+// exit - переводит запущенный контейнер в состояние exited
+func (cli *Client) exit(c *container, code int64) {
+	c.mu.Lock()
+
+	if c.status != StatusRunning && c.status != StatusPaused {
+		c.mu.Unlock()
+
+		return
+	}
+
+	c.status = StatusExited
+	c.exitCode = code
+	c.finished = time.Now()
+	c.closeListeners()
+	close(c.exit)
+	c.notify()
+
+	autoremove := c.autoremove
+	c.mu.Unlock()
+
+	cli.emitDie(c, code)
+
+	if autoremove {
+		cli.remove(c)
+	}
+}
+
+func (cli *Client) remove(c *container) {
+	cli.mu.Lock()
+	defer cli.mu.Unlock()
+
+	delete(cli.containers, c.id)
+
+	if cli.names[c.name] == c.id {
+		delete(cli.names, c.name)
+	}
+
+	cli.emit(c, containers.EventDestroy, nil)
+}
+
+func (cli *Client) lookup(nameOrID string) (*container, error) {
+	cli.mu.Lock()
+	defer cli.mu.Unlock()
+
+	if c, ok := cli.containers[nameOrID]; ok {
+		return c, nil
+	}
+
+	if id, ok := cli.names[nameOrID]; ok {
+		return cli.containers[id], nil
+	}
+
+	// как и демон, принимаем однозначный префикс идентификатора
+	var found *container
+
+	for id, c := range cli.containers {
+		if !strings.HasPrefix(id, nameOrID) {
+			continue
+		}
+
+		if found != nil {
+			return nil, errors.Ctx().Str("container", nameOrID).Just(ErrAmbiguousID)
+		}
+
+		found = c
+	}
+
+	if found != nil && nameOrID != "" {
+		return found, nil
+	}
+
+	return nil, errors.Ctx().Str("container", nameOrID).Just(ErrNoSuchContainer)
+}
+
+// scriptFor - сценарий контейнера, вызывается под cli.mu
+func (cli *Client) scriptFor(name string) Script {
+	if s, ok := cli.scripts[name]; ok {
+		return s
+	}
+
+	return cli.scripts[""]
+}
+
+// nextID - детерминированный идентификатор, вызывается под cli.mu
+func (cli *Client) nextID(seed string) string {
+	cli.seq++
+
+	sum := sha256.Sum256([]byte(strconv.Itoa(cli.seq) + "/" + seed))
+
+	return hex.EncodeToString(sum[:])
+}
+
+func (cli *Client) addImage(ref string) {
+	cli.mu.Lock()
+	defer cli.mu.Unlock()
+
+	cli.images[normalizeRef(ref)] = imageDigest(ref)
+	cli.imageMeta[normalizeRef(ref)] = imageMeta{created: time.Now()}
+}
+
+func imageDigest(seed string) string {
+	sum := sha256.Sum256([]byte(seed))
+
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// normalizeRef - приводит ссылку на образ к полной форме без реестра по
+// умолчанию: nginx, docker.io/library/nginx:latest - nginx:latest
+func normalizeRef(ref string) string {
+	ref = strings.TrimPrefix(ref, "docker.io/")
+	ref = strings.TrimPrefix(ref, "library/")
+
+	if strings.HasPrefix(ref, "sha256:") || strings.Contains(ref, "@") {
+		return ref
+	}
+
+	if !strings.Contains(ref[strings.LastIndex(ref, "/")+1:], ":") {
+		ref += ":latest"
+	}
+
+	return ref
+}
+
+func accept(l net.Listener) {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+
+		_ = conn.Close()
+	}
+}
+
+func copyPortMap(pm containers.PortMap) containers.PortMap {
+	cp := make(containers.PortMap, len(pm))
+
+	for port, binds := range pm {
+		cp[port] = append([]containers.PortBinding(nil), binds...)
+	}
+
+	return cp
+}
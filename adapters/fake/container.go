@@ -0,0 +1,303 @@
+package fake
+
+import (
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/gomisc/containers.v1"
+)
+
+type (
+	// State - снимок состояния фейкового контейнера
+	State struct {
+		ID       string
+		Name     string
+		Image    string
+		Network  string
+		IP       string
+		IP6      string
+		Envs     []string
+		Cmd      []string
+		Status   string
+		ExitCode int64
+		Restarts int
+		// Ports - опубликованные порты (после запуска - с назначенными портами хоста)
+		Ports containers.PortMap
+		// Execs - команды, выполненные через ContainerExec
+		Execs [][]string
+		// Signals - сигналы, отправленные через ContainerKill
+		Signals []string
+		// ExtraHosts - дополнительные записи /etc/hosts
+		ExtraHosts []string
+		// DNS, DNSSearch - DNS серверы и домены поиска контейнера
+		DNS       []string
+		DNSSearch []string
+		// Hooks - OCI хуки, переданные при создании; фейк их не выполняет
+		Hooks containers.Hooks
+		// ExtraNetworks - имена сетей контейнера помимо основной
+		ExtraNetworks []string
+		// Volumes - подключенные именованные тома; содержимое томов фейк
+		// между контейнерами не разделяет
+		Volumes []containers.VolumeSpec
+		// Tmpfs - разделы в памяти с опциями монтирования
+		Tmpfs map[string]string
+		// Logs - строки лога контейнера
+		Logs []LogLine
+		// Files - файлы, скопированные в контейнер, по абсолютным путям
+		Files map[string][]byte
+		// Health - состояние проверки здоровья (containers.Health*), пусто - проверка не задана
+		Health string
+		// Resources - ограничения ресурсов, переданные при создании
+		Resources containers.Resources
+		// Devices, GPUs - устройства и запросы GPU; фейк их не проверяет
+		Devices []containers.Device
+		GPUs    []containers.GPURequest
+		// User, WorkDir, Labels - пользователь, рабочий каталог и метки,
+		// переданные при создании
+		User    string
+		WorkDir string
+		Labels  map[string]string
+	}
+
+	container struct {
+		mu sync.Mutex
+
+		id         string
+		name       string
+		image      string
+		network    *Network
+		ip         string
+		ip6        string
+		envs       []string
+		cmd        []string
+		autoremove bool
+		mounts     []containers.MountSpec
+		hooks      containers.Hooks
+		extraHosts []string
+		volumes    []containers.VolumeSpec
+		tmpfs      map[string]string
+		extraNets  []attachment
+		ports      containers.PortMap
+		binds      containers.PortMap
+		script     Script
+
+		status    string
+		exitCode  int64
+		restarts  int
+		created   time.Time
+		started   time.Time
+		finished  time.Time
+		exit      chan struct{}
+		execs     [][]string
+		signals   []string
+		logs      []LogLine
+		files     map[string][]byte
+		listeners []net.Listener
+		// changed - закрывается и пересоздается при изменении логов или состояния
+		changed chan struct{}
+		// healthcheck - проверка здоровья, health и healthFailures - ее состояние
+		healthcheck    *containers.Healthcheck
+		health         string
+		healthFailures int
+		// resources - ограничения ресурсов; фейк учитывает только предел
+		// памяти в замерах ContainerStats
+		resources containers.Resources
+		// dns, dnsSearch - DNS серверы и домены поиска
+		dns       []string
+		dnsSearch []string
+		// devices, gpus - устройства и запросы GPU
+		devices []containers.Device
+		gpus    []containers.GPURequest
+		// user, workDir, labels - параметры процесса и метки, заданные при создании
+		user    string
+		workDir string
+		labels  map[string]string
+	}
+)
+
+func (c *container) log(line LogLine) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.logs = append(c.logs, line)
+	c.notify()
+}
+
+// notify - будит ожидающих изменений, вызывается под c.mu
+func (c *container) notify() {
+	close(c.changed)
+	c.changed = make(chan struct{})
+}
+
+// closeListeners - закрывает сокеты опубликованных портов, вызывается под c.mu
+func (c *container) closeListeners() {
+	for _, l := range c.listeners {
+		_ = l.Close()
+	}
+
+	c.listeners = nil
+}
+
+func (c *container) state() State {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	s := State{
+		ID:         c.id,
+		Name:       c.name,
+		Image:      c.image,
+		IP:         c.ip,
+		IP6:        c.ip6,
+		Envs:       append([]string(nil), c.envs...),
+		Cmd:        append([]string(nil), c.cmd...),
+		Status:     c.status,
+		ExitCode:   c.exitCode,
+		Restarts:   c.restarts,
+		Ports:      copyPortMap(c.ports),
+		Execs:      append([][]string(nil), c.execs...),
+		Signals:    append([]string(nil), c.signals...),
+		Hooks:      c.hooks,
+		ExtraHosts: append([]string(nil), c.extraHosts...),
+		Volumes:    append([]containers.VolumeSpec(nil), c.volumes...),
+		Tmpfs:      copyLabels(c.tmpfs),
+		Logs:       append([]LogLine(nil), c.logs...),
+		Files:      copyFiles(c.files),
+		Health:     c.health,
+		Resources:  c.resources,
+		DNS:        append([]string(nil), c.dns...),
+		DNSSearch:  append([]string(nil), c.dnsSearch...),
+		Devices:    append([]containers.Device(nil), c.devices...),
+		GPUs:       append([]containers.GPURequest(nil), c.gpus...),
+		User:       c.user,
+		WorkDir:    c.workDir,
+		Labels:     copyLabels(c.labels),
+	}
+
+	if c.binds != nil {
+		s.Ports = copyPortMap(c.binds)
+	}
+
+	if c.network != nil {
+		s.Network = c.network.name
+	}
+
+	for _, att := range c.extraNets {
+		s.ExtraNetworks = append(s.ExtraNetworks, att.network.name)
+	}
+
+	return s
+}
+
+// copyFiles - глубокая копия файлов контейнера
+func copyLabels(labels map[string]string) map[string]string {
+	if labels == nil {
+		return nil
+	}
+
+	cp := make(map[string]string, len(labels))
+
+	for k, v := range labels {
+		cp[k] = v
+	}
+
+	return cp
+}
+
+func copyFiles(files map[string][]byte) map[string][]byte {
+	out := make(map[string][]byte, len(files))
+
+	for p, data := range files {
+		out[p] = append([]byte(nil), data...)
+	}
+
+	return out
+}
+
+// inspect - состояние контейнера в представлении ContainerInspect
+// removeTree - удаляет из files путь dir и все пути под ним
+func removeTree(files map[string][]byte, dir string) {
+	prefix := strings.TrimSuffix(dir, "/") + "/"
+
+	for p := range files {
+		if p == dir || strings.HasPrefix(p, prefix) {
+			delete(files, p)
+		}
+	}
+}
+
+func (c *container) inspect() *containers.InspectResult {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	result := &containers.InspectResult{
+		ID:         c.id,
+		Name:       c.name,
+		Image:      c.image,
+		Status:     c.status,
+		ExitCode:   int(c.exitCode),
+		Restarts:   c.restarts,
+		Health:     c.health,
+		StartedAt:  c.started,
+		FinishedAt: c.finished,
+		PortBinds:  copyPortMap(c.binds),
+		Networks:   make(map[string]containers.EndpointSettings),
+	}
+
+	c.endpoints(result.Networks)
+
+	result.Mounts = c.inspectMounts()
+
+	return result
+}
+
+// inspectMounts - разделы контейнера в форме InspectResult, вызывается под c.mu
+func (c *container) inspectMounts() []containers.Mount {
+	var mounts []containers.Mount
+
+	for _, m := range c.mounts {
+		mounts = append(
+			mounts, containers.Mount{
+				Type:        m.MountType(),
+				Source:      m.Source,
+				Destination: m.Target,
+				ReadOnly:    m.ReadOnly,
+			},
+		)
+	}
+
+	for _, v := range c.volumes {
+		mounts = append(
+			mounts, containers.Mount{
+				Type:        containers.MountVolume,
+				Source:      v.Name,
+				Destination: v.Target,
+				ReadOnly:    v.ReadOnly,
+			},
+		)
+	}
+
+	targets := make([]string, 0, len(c.tmpfs))
+
+	for target := range c.tmpfs {
+		targets = append(targets, target)
+	}
+
+	sort.Strings(targets)
+
+	for _, target := range targets {
+		mounts = append(mounts, containers.TmpfsMount(target, c.tmpfs[target]))
+	}
+
+	return mounts
+}
+
+func sortStates(states []State) {
+	sort.Slice(
+		states, func(i, j int) bool {
+			return states[i].Name < states[j].Name
+		},
+	)
+}
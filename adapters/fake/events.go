@@ -0,0 +1,169 @@
+package fake
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/gomisc/containers.v1"
+)
+
+// eventBuffer - размер очереди событий подписчика, при переполнении
+// события для него отбрасываются
+const eventBuffer = 256
+
+type (
+	// eventBus - рассылка событий фейкового клиента подписчикам Events
+	eventBus struct {
+		mu   sync.Mutex
+		subs map[*subscriber]struct{}
+	}
+
+	subscriber struct {
+		ch      chan containers.ContainerEvent
+		filters map[string][]string
+	}
+)
+
+// Events - подписка на события фейкового клиента, фильтры поддерживают
+// ключи type, event, container, network и label
+func (cli *Client) Events(ctx context.Context, filters ...containers.EventFilter) <-chan containers.ContainerEvent {
+	sub := &subscriber{
+		ch:      make(chan containers.ContainerEvent, eventBuffer),
+		filters: make(map[string][]string),
+	}
+
+	for _, f := range filters {
+		sub.filters[f.Key] = append(sub.filters[f.Key], f.Value)
+	}
+
+	cli.events.mu.Lock()
+	if cli.events.subs == nil {
+		cli.events.subs = make(map[*subscriber]struct{})
+	}
+
+	cli.events.subs[sub] = struct{}{}
+	cli.events.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+
+		cli.events.mu.Lock()
+		delete(cli.events.subs, sub)
+		close(sub.ch)
+		cli.events.mu.Unlock()
+	}()
+
+	return sub.ch
+}
+
+// Emit - публикует событие подписчикам, позволяет сценарию теста
+// имитировать oom, health_status и прочие события демона
+func (cli *Client) Emit(ev containers.ContainerEvent) {
+	if ev.Time.IsZero() {
+		ev.Time = time.Now()
+	}
+
+	if ev.Type == "" {
+		ev.Type = containers.EventTypeContainer
+	}
+
+	cli.events.mu.Lock()
+	defer cli.events.mu.Unlock()
+
+	for sub := range cli.events.subs {
+		if !sub.match(&ev) {
+			continue
+		}
+
+		select {
+		case sub.ch <- ev:
+		default:
+		}
+	}
+}
+
+// emit - публикует событие контейнера с его именем и атрибутами
+func (cli *Client) emit(c *container, action string, attrs map[string]string) {
+	if attrs == nil {
+		attrs = make(map[string]string)
+	}
+
+	// как и демон, фейк передает в атрибутах метки контейнера, они неизменны
+	for k, v := range c.labels {
+		attrs[k] = v
+	}
+
+	attrs["name"] = c.name
+	attrs["image"] = c.image
+
+	cli.Emit(
+		containers.ContainerEvent{
+			Type:       containers.EventTypeContainer,
+			Action:     action,
+			ID:         c.id,
+			Name:       c.name,
+			Attributes: attrs,
+		},
+	)
+}
+
+// emitNetwork - событие подключения контейнера c к сети nw или отключения от нее
+func (cli *Client) emitNetwork(nw *Network, c *container, action string) {
+	cli.Emit(
+		containers.ContainerEvent{
+			Type:       containers.EventTypeNetwork,
+			Action:     action,
+			ID:         nw.id,
+			Name:       nw.name,
+			Attributes: map[string]string{"name": nw.name, "container": c.id},
+		},
+	)
+}
+
+// emitDie - событие завершения процесса с кодом
+func (cli *Client) emitDie(c *container, code int64) {
+	cli.emit(c, containers.EventDie, map[string]string{"exitCode": strconv.FormatInt(code, 10)})
+}
+
+func (s *subscriber) match(ev *containers.ContainerEvent) bool {
+	for key, values := range s.filters {
+		if !matchAny(values, func(v string) bool { return matchFilter(ev, key, v) }) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func matchFilter(ev *containers.ContainerEvent, key, value string) bool {
+	switch key {
+	case "type":
+		return ev.Type == value
+	case "event":
+		return ev.Action == value
+	case "container":
+		return ev.Type == containers.EventTypeContainer && (ev.ID == value || ev.Name == value)
+	case "network":
+		return ev.Type == containers.EventTypeNetwork && (ev.ID == value || ev.Name == value)
+	case "label":
+		k, v, withValue := strings.Cut(value, "=")
+		actual, ok := ev.Attributes[k]
+
+		return ok && (!withValue || actual == v)
+	default:
+		return false
+	}
+}
+
+func matchAny(values []string, match func(v string) bool) bool {
+	for _, v := range values {
+		if match(v) {
+			return true
+		}
+	}
+
+	return false
+}
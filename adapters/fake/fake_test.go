@@ -0,0 +1,32 @@
+package fake_test
+
+import (
+	"testing"
+
+	"gopkg.in/gomisc/containers.v1"
+	"gopkg.in/gomisc/containers.v1/adapters/fake"
+	"gopkg.in/gomisc/containers.v1/adaptertest"
+)
+
+func TestConformance(t *testing.T) {
+	adaptertest.Run(
+		t, func(t *testing.T) containers.Client {
+			cli, err := fake.New(fake.WithImages(adaptertest.DefaultImage))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// фейк не исполняет DefaultCmd: вывод маркеров задается сценарием
+			cli.Script(
+				"", fake.Script{
+					Logs: []fake.LogLine{
+						{Stream: fake.Stdout, Text: adaptertest.StdoutMarker},
+						{Stream: fake.Stderr, Text: adaptertest.StderrMarker},
+					},
+				},
+			)
+
+			return cli
+		},
+	)
+}
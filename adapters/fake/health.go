@@ -0,0 +1,123 @@
+package fake
+
+import (
+	"io"
+	"time"
+
+	"gopkg.in/gomisc/containers.v1"
+)
+
+// Параметры проверки здоровья по умолчанию, как у демона
+const (
+	defaultHealthInterval = 30 * time.Second
+	defaultHealthRetries  = 3
+)
+
+// watchHealth - запускает проверку здоровья контейнера, заданную при создании:
+// команда проверки выполняется обработчиком Script.Exec (по умолчанию - с кодом 0)
+// каждые Interval до завершения процесса. Вызывается под c.mu после запуска
+func (cli *Client) watchHealth(c *container) {
+	hc := c.healthcheck
+	if hc.Disabled() {
+		c.health = ""
+
+		return
+	}
+
+	c.health = containers.HealthStarting
+	c.healthFailures = 0
+
+	interval := hc.Interval
+	if interval <= 0 {
+		interval = defaultHealthInterval
+	}
+
+	go func(exit <-chan struct{}) {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-exit:
+				return
+			case <-ticker.C:
+				cli.probeHealth(c, exit)
+			}
+		}
+	}(c.exit)
+}
+
+// probeHealth - однократная проверка здоровья, смена состояния публикуется
+// событием health_status
+func (cli *Client) probeHealth(c *container, exit <-chan struct{}) {
+	c.mu.Lock()
+	if c.exit != exit || c.status != StatusRunning {
+		c.mu.Unlock()
+
+		return
+	}
+
+	hc, handler, started := c.healthcheck, c.script.Exec, c.started
+	c.mu.Unlock()
+
+	code := 0
+	if handler != nil {
+		code = handler(healthCmd(hc.Test), io.Discard, io.Discard)
+	}
+
+	retries := hc.Retries
+	if retries <= 0 {
+		retries = defaultHealthRetries
+	}
+
+	c.mu.Lock()
+
+	prev := c.health
+
+	switch {
+	case c.exit != exit:
+	case code == 0:
+		c.health = containers.HealthHealthy
+		c.healthFailures = 0
+	case time.Since(started) < hc.StartPeriod:
+		// неудачи периода запуска не учитываются
+	default:
+		c.healthFailures++
+
+		if c.healthFailures >= retries {
+			c.health = containers.HealthUnhealthy
+		}
+	}
+
+	health := c.health
+	if health != prev {
+		c.notify()
+	}
+
+	c.mu.Unlock()
+
+	if health != prev {
+		cli.Emit(
+			containers.ContainerEvent{
+				Action:     containers.EventHealthStatus,
+				Detail:     health,
+				ID:         c.id,
+				Name:       c.name,
+				Attributes: map[string]string{"name": c.name, "image": c.image},
+			},
+		)
+	}
+}
+
+// healthCmd - команда проверки без префикса, команда оболочки - через /bin/sh -c
+func healthCmd(test []string) []string {
+	if test[0] == containers.HealthcheckShell {
+		return append([]string{"/bin/sh", "-c"}, test[1:]...)
+	}
+
+	if test[0] == containers.HealthcheckCmd {
+		return test[1:]
+	}
+
+	return test
+}
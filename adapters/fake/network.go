@@ -0,0 +1,289 @@
+package fake
+
+import (
+	"context"
+	"net"
+	"net/netip"
+	"sync"
+
+	"gopkg.in/gomisc/containers.v1"
+	"gopkg.in/gomisc/errors.v1"
+)
+
+// hostIP - адрес хоста фейковой сети: опубликованные порты слушаются на
+// loopback, поэтому проверки готовности тестового процесса проходят
+const hostIP = "127.0.0.1"
+
+// Network - фейковая сеть: адреса выдаются последовательно из подсети,
+// начиная с адреса после шлюза
+type Network struct {
+	id       string
+	name     string
+	subnet   *net.IPNet
+	internal bool
+	// subnet6 - подсеть IPv6 сети с двумя стеками адресов
+	subnet6 *net.IPNet
+	// labels - метки сети
+	labels map[string]string
+
+	mu       sync.Mutex
+	gateway  netip.Addr
+	next     netip.Addr
+	next6    netip.Addr
+	registry *containers.Registry
+}
+
+func newNetwork(id, name string, subnet *net.IPNet) *Network {
+	prefix, _ := netip.ParsePrefix(subnet.String())
+	gateway := prefix.Masked().Addr().Next()
+
+	return &Network{
+		id:       id,
+		name:     name,
+		subnet:   subnet,
+		gateway:  gateway,
+		next:     gateway.Next(),
+		registry: containers.NewRegistry(),
+	}
+}
+
+// enableIPv6 - добавляет сети подсеть IPv6, адреса выдаются после шлюза
+func (nw *Network) enableIPv6(subnet6 *net.IPNet) {
+	if subnet6 == nil {
+		return
+	}
+
+	prefix, err := netip.ParsePrefix(subnet6.String())
+	if err != nil {
+		return
+	}
+
+	nw.subnet6 = subnet6
+	nw.next6 = prefix.Masked().Addr().Next().Next()
+}
+
+// ID - идентификатор сети
+func (nw *Network) ID() string {
+	return nw.id
+}
+
+// Name - имя сети
+func (nw *Network) Name() string {
+	return nw.name
+}
+
+// Subnet - подсеть сети
+func (nw *Network) Subnet() *net.IPNet {
+	return nw.subnet
+}
+
+// Internal - признак сети без выхода во внешние сети, такие сети фейк
+// создает в герметичном режиме
+func (nw *Network) Internal() bool {
+	return nw.internal
+}
+
+// Gateway - первый адрес подсети
+func (nw *Network) Gateway() string {
+	return nw.gateway.String()
+}
+
+// HostIP - адрес, на котором опубликованы порты контейнеров
+func (nw *Network) HostIP() string {
+	return hostIP
+}
+
+// NextIP - следующий свободный адрес подсети, пустая строка - подсеть исчерпана
+func (nw *Network) NextIP() string {
+	nw.mu.Lock()
+	defer nw.mu.Unlock()
+
+	return nw.allocate()
+}
+
+// Subnet6 - подсеть IPv6, nil - сеть без IPv6
+func (nw *Network) Subnet6() *net.IPNet {
+	return nw.subnet6
+}
+
+// NextIP6 - следующий свободный IPv6 адрес, пустая строка - сеть без IPv6
+// или подсеть исчерпана
+func (nw *Network) NextIP6() string {
+	nw.mu.Lock()
+	defer nw.mu.Unlock()
+
+	if nw.subnet6 == nil || !nw.subnet6.Contains(nw.next6.AsSlice()) {
+		return ""
+	}
+
+	ip := nw.next6
+	nw.next6 = ip.Next()
+
+	return ip.String()
+}
+
+// AddContainer - регистрирует данные контейнера
+func (nw *Network) AddContainer(info *containers.OrchestratorInfo) {
+	nw.registry.AddContainer(info)
+}
+
+// Registry - реестр контейнеров сети
+func (nw *Network) Registry() *containers.Registry {
+	return nw.registry
+}
+
+// Containers - зарегистрированные данные контейнеров
+func (nw *Network) Containers() []*containers.OrchestratorInfo {
+	infos := nw.registry.All()
+	list := make([]*containers.OrchestratorInfo, len(infos))
+
+	for i := range infos {
+		list[i] = &infos[i]
+	}
+
+	return list
+}
+
+func (nw *Network) allocate() string {
+	if !nw.subnet.Contains(nw.next.AsSlice()) {
+		return ""
+	}
+
+	ip := nw.next
+	nw.next = ip.Next()
+
+	return ip.String()
+}
+
+// attachment - подключение контейнера к сети помимо основной
+type attachment struct {
+	network *Network
+	ip      string
+	ip6     string
+	aliases []string
+}
+
+// newAttachment - подключение к сети nw; заданный адрес ip (IPv4 или IPv6)
+// закрепляется за контейнером, остальные выдаются при запуске
+func newAttachment(nw *Network, ip string, aliases []string) attachment {
+	att := attachment{network: nw, aliases: aliases}
+
+	if addr, err := netip.ParseAddr(ip); err == nil && !addr.Is4() {
+		att.ip6 = ip
+	} else {
+		att.ip = ip
+	}
+
+	return att
+}
+
+// assign - выдает незакрепленные адреса подключения
+func (att *attachment) assign() {
+	if att.ip == "" {
+		att.ip = att.network.NextIP()
+	}
+
+	if att.ip6 == "" {
+		att.ip6 = att.network.NextIP6()
+	}
+}
+
+// NetworkConnect - подключает контейнер к сети; адрес работающему
+// контейнеру выдается сразу, остальным - при запуске
+func (cli *Client) NetworkConnect(_ context.Context, id string, att containers.NetworkAttachment) error {
+	c, err := cli.lookup(id)
+	if err != nil {
+		return err
+	}
+
+	nw, ok := att.Network.(*Network)
+	if !ok || nw == nil {
+		return errors.Ctx().Str("container-id", id).Just(ErrNoSuchNetwork)
+	}
+
+	c.mu.Lock()
+
+	if c.connected(nw) >= 0 {
+		c.mu.Unlock()
+
+		return errors.Ctx().Str("container", c.name).Str("network", nw.name).Just(ErrAlreadyConnected)
+	}
+
+	a := newAttachment(nw, att.IP, att.Aliases)
+	if c.status == StatusRunning {
+		a.assign()
+	}
+
+	c.extraNets = append(c.extraNets, a)
+	c.notify()
+	c.mu.Unlock()
+
+	cli.emitNetwork(nw, c, containers.EventConnect)
+
+	return nil
+}
+
+// NetworkDisconnect - отключает контейнер от сети, в том числе от основной
+func (cli *Client) NetworkDisconnect(_ context.Context, id string, nw containers.Network) error {
+	c, err := cli.lookup(id)
+	if err != nil {
+		return err
+	}
+
+	fnw, _ := nw.(*Network)
+
+	c.mu.Lock()
+
+	switch i := c.connected(fnw); {
+	case fnw == nil || i < 0:
+		c.mu.Unlock()
+
+		return errors.Ctx().Str("container", c.name).Str("network", nw.Name()).Just(ErrNotConnected)
+	case i == 0:
+		c.network, c.ip, c.ip6 = nil, "", ""
+	default:
+		c.extraNets = append(c.extraNets[:i-1:i-1], c.extraNets[i:]...)
+	}
+
+	c.notify()
+	c.mu.Unlock()
+
+	cli.emitNetwork(fnw, c, containers.EventDisconnect)
+
+	return nil
+}
+
+// connected - 0 для основной сети, i+1 для сети extraNets[i], -1 если
+// контейнер к сети не подключен; вызывается под c.mu
+func (c *container) connected(nw *Network) int {
+	if nw == nil {
+		return -1
+	}
+
+	if c.network == nw {
+		return 0
+	}
+
+	for i := range c.extraNets {
+		if c.extraNets[i].network == nw {
+			return i + 1
+		}
+	}
+
+	return -1
+}
+
+// endpoints - заполняет адреса контейнера во всех его сетях, вызывается под c.mu
+func (c *container) endpoints(dst map[string]containers.EndpointSettings) {
+	if c.network != nil {
+		dst[c.network.name] = containers.EndpointSettings{
+			NetworkID: c.network.id, IPAddress: c.ip, IPv6Address: c.ip6,
+		}
+	}
+
+	for _, att := range c.extraNets {
+		dst[att.network.name] = containers.EndpointSettings{
+			NetworkID: att.network.id, IPAddress: att.ip, IPv6Address: att.ip6,
+		}
+	}
+}
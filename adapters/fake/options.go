@@ -0,0 +1,75 @@
+package fake
+
+import (
+	"io"
+
+	"gopkg.in/gomisc/containers.v1"
+)
+
+type (
+	// Option - опция фейкового клиента
+	Option func(o *options)
+
+	options struct {
+		images       []string
+		subnetPool   string
+		subnetPrefix int
+		ipv6         bool
+		ipv6Pool     string
+		ipv6Prefix   int
+		info         *containers.DaemonInfo
+		stdout       io.Writer
+		stderr       io.Writer
+		noListeners  bool
+		project      containers.Project
+	}
+)
+
+// WithImages - образы, изначально присутствующие в локальном сторе
+func WithImages(refs ...string) Option {
+	return func(o *options) {
+		o.images = append(o.images, refs...)
+	}
+}
+
+// WithSubnetPool - пул (CIDR) и длина префикса подсетей фейковых сетей
+func WithSubnetPool(pool string, prefix int) Option {
+	return func(o *options) {
+		o.subnetPool = pool
+		o.subnetPrefix = prefix
+	}
+}
+
+// WithIPv6 - фейковые сети создаются с двумя стеками адресов, подсети IPv6
+// выделяются из пула pool с длиной префикса prefix (пустые значения -
+// DefaultSubnetPool6 и DefaultSubnetPrefix6)
+func WithIPv6(pool string, prefix int) Option {
+	return func(o *options) {
+		o.ipv6 = true
+		o.ipv6Pool = pool
+		o.ipv6Prefix = prefix
+	}
+}
+
+// WithInfo - сведения о демоне, возвращаемые Info
+func WithInfo(info *containers.DaemonInfo) Option {
+	return func(o *options) {
+		o.info = info
+	}
+}
+
+// WithOutput - потоки служебного вывода клиента (по умолчанию отбрасываются)
+func WithOutput(stdout, stderr io.Writer) Option {
+	return func(o *options) {
+		o.stdout = stdout
+		o.stderr = stderr
+	}
+}
+
+// WithoutListeners - не открывать на хосте слушающие сокеты для
+// опубликованных портов; проверки готовности по портам при этом не проходят
+func WithoutListeners() Option {
+	return func(o *options) {
+		o.noListeners = true
+	}
+}
@@ -0,0 +1,75 @@
+package fake
+
+import (
+	"context"
+	"sort"
+
+	"gopkg.in/gomisc/containers.v1"
+)
+
+// WithProject - помечает создаваемые клиентом контейнеры, сети, тома и
+// образы меткой проекта, а подсети выделяет из части пула, выделенной проекту
+// (если пул не задан явно WithSubnetPool)
+func WithProject(project containers.Project) Option {
+	return func(o *options) {
+		o.project = project
+	}
+}
+
+// ListProjectResources - объекты с меткой проекта
+func (cli *Client) ListProjectResources(ctx context.Context, project string) (*containers.ProjectResources, error) {
+	p := containers.Project(project)
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+
+	res := &containers.ProjectResources{Project: project}
+
+	var err error
+
+	if res.Containers, err = cli.ContainerList(ctx, p.Filter()); err != nil {
+		return nil, err
+	}
+
+	filter := containers.VolumeFilter{Labels: map[string]string{containers.ProjectLabel: project}}
+
+	if res.Volumes, err = cli.VolumeList(ctx, filter); err != nil {
+		return nil, err
+	}
+
+	cli.mu.Lock()
+	defer cli.mu.Unlock()
+
+	for _, nw := range cli.networks {
+		if nw.labels[containers.ProjectLabel] == project {
+			res.Networks = append(
+				res.Networks, containers.NetworkSummary{
+					ID:     nw.id,
+					Name:   nw.name,
+					Subnet: nw.subnet.String(),
+					Labels: copyLabels(nw.labels),
+				},
+			)
+		}
+	}
+
+	for ref, meta := range cli.imageMeta {
+		if meta.labels[containers.ProjectLabel] == project {
+			res.Images = append(res.Images, ref)
+		}
+	}
+
+	sort.Slice(res.Networks, func(i, j int) bool { return res.Networks[i].Name < res.Networks[j].Name })
+	sort.Strings(res.Images)
+
+	return res, nil
+}
+
+// labelProject - метки объекта с меткой проекта клиента
+func (cli *Client) labelProject(labels map[string]string) map[string]string {
+	if cli.opts.project == "" {
+		return labels
+	}
+
+	return cli.opts.project.Labels(labels)
+}
@@ -0,0 +1,49 @@
+package fake
+
+import (
+	"io"
+	"time"
+
+	"gopkg.in/gomisc/containers.v1"
+)
+
+// Потоки вывода контейнера
+const (
+	Stdout Stream = iota
+	Stderr
+)
+
+type (
+	// Stream - поток вывода контейнера
+	Stream int
+
+	// LogLine - строка лога контейнера
+	LogLine struct {
+		Stream Stream
+		Text   string
+	}
+
+	// ExecFunc - обработчик команды, выполняемой в контейнере, возвращает код завершения
+	ExecFunc func(cmd []string, stdout, stderr io.Writer) int
+
+	// Script - сценарий жизненного цикла контейнера
+	Script struct {
+		// CreateErr, StartErr - ошибки, возвращаемые при создании и запуске
+		CreateErr error
+		StartErr  error
+		// Logs - строки, выводимые контейнером сразу после запуска
+		Logs []LogLine
+		// ExitAfter - контейнер завершается сам через заданное время после
+		// запуска с кодом ExitCode (0 - работает до остановки)
+		ExitAfter time.Duration
+		ExitCode  int64
+		// StopCode - код завершения при остановке
+		StopCode int64
+		// Exec - обработчик команд ContainerExec, по умолчанию команды
+		// завершаются с кодом 0
+		Exec ExecFunc
+		// Stats - замеры ContainerStats по порядку, последний повторяется;
+		// по умолчанию - нулевое потребление одним процессом
+		Stats []containers.StatsSample
+	}
+)
@@ -0,0 +1,84 @@
+package fake
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"gopkg.in/gomisc/containers.v1"
+	"gopkg.in/gomisc/errors.v1"
+)
+
+func (cli *Client) VolumeCreate(_ context.Context, spec containers.VolumeSpec) (containers.VolumeInfo, error) {
+	cli.mu.Lock()
+	defer cli.mu.Unlock()
+
+	return cli.ensureVolume(spec), nil
+}
+
+// VolumeRemove - удаляет том; том, на который ссылается существующий
+// контейнер, удаляется только с force
+func (cli *Client) VolumeRemove(_ context.Context, name string, force bool) error {
+	cli.mu.Lock()
+	defer cli.mu.Unlock()
+
+	if _, ok := cli.volumes[name]; !ok {
+		return errors.Ctx().Str("volume", name).Just(containers.ErrVolumeNotFound)
+	}
+
+	if !force {
+		for _, c := range cli.containers {
+			for _, v := range c.volumes {
+				if v.Name == name {
+					return errors.Ctx().Str("volume", name).Str("container", c.name).Just(containers.ErrVolumeInUse)
+				}
+			}
+		}
+	}
+
+	delete(cli.volumes, name)
+
+	return nil
+}
+
+func (cli *Client) VolumeList(_ context.Context, filter containers.VolumeFilter) ([]containers.VolumeInfo, error) {
+	cli.mu.Lock()
+	defer cli.mu.Unlock()
+
+	result := make([]containers.VolumeInfo, 0, len(cli.volumes))
+
+	for name := range cli.volumes {
+		info := cli.volumes[name]
+
+		if filter.Match(&info) {
+			result = append(result, info)
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+
+	return result, nil
+}
+
+// ensureVolume - возвращает том, создавая его при отсутствии, вызывается под cli.mu
+func (cli *Client) ensureVolume(spec containers.VolumeSpec) containers.VolumeInfo {
+	if info, ok := cli.volumes[spec.Name]; ok {
+		return info
+	}
+
+	info := containers.VolumeInfo{
+		Name:       spec.Name,
+		Driver:     spec.Driver,
+		Mountpoint: "/var/lib/fake/volumes/" + spec.Name,
+		Labels:     cli.labelProject(spec.Labels),
+		Created:    time.Now(),
+	}
+
+	if info.Driver == "" {
+		info.Driver = "local"
+	}
+
+	cli.volumes[spec.Name] = info
+
+	return info
+}
@@ -0,0 +1,1141 @@
+// Package adaptertest - набор проверок контракта containers.Client, общий для
+// всех адаптеров, в том числе сторонних: жизненный цикл контейнера, логи,
+// сети, образы, тома и семантика ошибок. Адаптер проверяется вызовом Run из
+// обычного теста:
+//
+//	func TestConformance(t *testing.T) {
+//		adaptertest.Run(t, func(t *testing.T) containers.Client {
+//			cli, err := docker.New()
+//			if err != nil {
+//				t.Fatal(err)
+//			}
+//
+//			return cli
+//		})
+//	}
+//
+// Проверочный контейнер запускается из образа DefaultImage командой DefaultCmd:
+// выводит StdoutMarker и StderrMarker в соответствующие потоки и работает до
+// остановки. Адаптеры, не исполняющие процессы (например adapters/fake),
+// должны воспроизвести этот вывод сценарием по умолчанию, иначе проверки
+// логов не дождутся маркеров:
+//
+//	cli, err := fake.New(fake.WithImages(adaptertest.DefaultImage))
+//	if err != nil {
+//		t.Fatal(err)
+//	}
+//
+//	cli.Script("", fake.Script{Logs: []fake.LogLine{
+//		{Stream: fake.Stdout, Text: adaptertest.StdoutMarker},
+//		{Stream: fake.Stderr, Text: adaptertest.StderrMarker},
+//	}})
+package adaptertest
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"io"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"time"
+
+	"gopkg.in/gomisc/containers.v1"
+	"gopkg.in/gomisc/containers.v1/wait"
+	"gopkg.in/gomisc/errors.v1"
+)
+
+const (
+	// DefaultImage - образ проверочного контейнера, должен содержать sh
+	DefaultImage = "busybox:1.36"
+
+	// StdoutMarker, StderrMarker - строки, которые проверочный контейнер
+	// выводит в stdout и stderr сразу после запуска
+	StdoutMarker = "adaptertest stdout"
+	StderrMarker = "adaptertest stderr"
+
+	// namePrefix - префикс имен контейнеров и сетей набора
+	namePrefix = "adaptertest-"
+	// missingImage - ссылка на заведомо отсутствующий образ
+	missingImage = "adaptertest.invalid/missing:none"
+	// copyFile, copyContent - файл проверки копирования
+	copyFile    = "adaptertest.txt"
+	copyContent = "adaptertest payload"
+	// volumeLabel - метка томов набора
+	volumeLabel = "adaptertest.volume"
+	// roleLabel - метка контейнеров набора
+	roleLabel = "adaptertest.role"
+	// killExitCode - код завершения процесса по SIGKILL
+	killExitCode = 137
+	// cancelRounds - число запусков, прерываемых в случайный момент
+	cancelRounds = 10
+	// memoryLimit - предел памяти проверочного контейнера
+	memoryLimit = 64 << 20
+
+	errNotHealthy = errors.Const("container is not healthy yet")
+)
+
+// DefaultCmd - команда проверочного контейнера
+var DefaultCmd = []string{
+	"sh", "-c", "echo '" + StdoutMarker + "'; echo '" + StderrMarker + "' >&2; exec sleep 3600",
+}
+
+type (
+	// Factory - создает проверяемого клиента; ресурсы клиента освобождаются
+	// через t.Cleanup
+	Factory func(t *testing.T) containers.Client
+
+	// Config - параметры набора проверок
+	Config struct {
+		// Image, Cmd - образ и команда проверочного контейнера, команда
+		// должна выводить маркеры так же, как DefaultCmd
+		Image string
+		Cmd   []string
+		// Timeout - срок каждой проверки
+		Timeout time.Duration
+	}
+
+	// Option - опция набора проверок
+	Option func(c *Config)
+
+	suite struct {
+		t   *testing.T
+		cfg Config
+		cli containers.ExtendedClient
+		ctx context.Context
+	}
+)
+
+// WithImage - образ и команда проверочного контейнера вместо DefaultImage и DefaultCmd
+func WithImage(image string, cmd ...string) Option {
+	return func(c *Config) {
+		c.Image = image
+		c.Cmd = cmd
+	}
+}
+
+// WithTimeout - срок каждой проверки (по умолчанию 2 минуты)
+func WithTimeout(timeout time.Duration) Option {
+	return func(c *Config) {
+		c.Timeout = timeout
+	}
+}
+
+// Run - выполняет проверки контракта, каждая - отдельным подтестом
+// с собственным клиентом из factory
+func Run(t *testing.T, factory Factory, opts ...Option) {
+	t.Helper()
+
+	cfg := Config{Image: DefaultImage, Cmd: DefaultCmd, Timeout: 2 * time.Minute}
+
+	for _, apply := range opts {
+		apply(&cfg)
+	}
+
+	for _, tc := range []struct {
+		name string
+		run  func(s *suite)
+	}{
+		{"Info", (*suite).info},
+		{"Network", (*suite).network},
+		{"NetworkConnect", (*suite).networkConnect},
+		{"DualStack", (*suite).dualStack},
+		{"Images", (*suite).images},
+		{"Attestations", (*suite).attestations},
+		{"CreateMissingImage", (*suite).createMissingImage},
+		{"NameConflict", (*suite).nameConflict},
+		{"Handle", (*suite).handle},
+		{"Adopt", (*suite).adopt},
+		{"Lifecycle", (*suite).lifecycle},
+		{"Healthcheck", (*suite).healthcheck},
+		{"Resources", (*suite).resources},
+		{"Stop", (*suite).stop},
+		{"Registry", (*suite).registry},
+		{"Project", (*suite).project},
+		{"Events", (*suite).events},
+		{"Copy", (*suite).copy},
+		{"Volumes", (*suite).volumes},
+		{"CancelledStartup", (*suite).cancelledStartup},
+		{"UnknownContainer", (*suite).unknownContainer},
+	} {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), cfg.Timeout)
+			t.Cleanup(cancel)
+
+			tc.run(&suite{t: t, cfg: cfg, cli: containers.ExtendClient(factory(t)), ctx: ctx})
+		})
+	}
+}
+
+func (s *suite) info() {
+	info, err := s.cli.Info(s.ctx)
+	if err != nil {
+		s.t.Fatalf("Info: %v", err)
+	}
+
+	if info.OSType == "" || info.Architecture == "" {
+		s.t.Errorf("Info: empty os type or architecture: %+v", info)
+	}
+}
+
+func (s *suite) network() {
+	name := uniqueName()
+
+	nw, err := s.cli.CheckNetwork(name, "")
+	if err != nil {
+		s.t.Fatalf("CheckNetwork: %v", err)
+	}
+
+	s.t.Cleanup(func() {
+		if err := s.cli.RemoveNetwork(nw.ID()); err != nil {
+			s.t.Errorf("RemoveNetwork: %v", err)
+		}
+	})
+
+	if nw.ID() == "" || nw.Name() != name {
+		s.t.Errorf("CheckNetwork: id %q, name %q, want name %q", nw.ID(), nw.Name(), name)
+	}
+
+	again, err := s.cli.CheckNetwork(name, "")
+	if err != nil {
+		s.t.Fatalf("CheckNetwork existing: %v", err)
+	}
+
+	if again.ID() != nw.ID() {
+		s.t.Errorf("CheckNetwork existing: id %q, want %q", again.ID(), nw.ID())
+	}
+}
+
+func (s *suite) networkConnect() {
+	s.ensureImage()
+
+	primary, extra, runtime := s.newNetwork(), s.newNetwork(), s.newNetwork()
+
+	c := s.container(primary)
+	c.ExtraNetworks = []containers.NetworkAttachment{{Network: extra}}
+	id := s.start(c)
+
+	s.attached(id, primary, extra)
+
+	if err := s.cli.NetworkConnect(s.ctx, id, containers.NetworkAttachment{Network: runtime}); err != nil {
+		s.t.Fatalf("NetworkConnect: %v", err)
+	}
+
+	s.attached(id, primary, extra, runtime)
+
+	if err := s.cli.NetworkDisconnect(s.ctx, id, primary); err != nil {
+		s.t.Fatalf("NetworkDisconnect: %v", err)
+	}
+
+	s.attached(id, extra, runtime)
+}
+
+// attached - проверяет, что контейнер подключен ровно к сетям want
+func (s *suite) attached(id string, want ...containers.Network) {
+	networks := s.inspect(id).Networks
+
+	for _, nw := range want {
+		if _, ok := networks[nw.Name()]; !ok {
+			s.t.Errorf("ContainerInspect: container is not attached to network %s", nw.Name())
+		}
+	}
+
+	if len(networks) != len(want) {
+		s.t.Errorf("ContainerInspect: networks %v, want %d", networks, len(want))
+	}
+}
+
+// dualStack - сеть с подсетью IPv6 выдает контейнеру адреса обоих семейств;
+// адаптеры без поддержки IPv6 пропускают проверку
+func (s *suite) dualStack() {
+	s.ensureImage()
+
+	b := make([]byte, 4)
+	_, _ = rand.Read(b)
+
+	_, want, _ := net.ParseCIDR("fd" + hex.EncodeToString(b[:1]) + ":" + hex.EncodeToString(b[1:3]) + "::/64")
+	cidr := want.String()
+
+	nw, err := s.cli.CheckNetwork(uniqueName(), cidr)
+	if err != nil {
+		s.t.Fatalf("CheckNetwork %s: %v", cidr, err)
+	}
+
+	s.t.Cleanup(func() {
+		_ = s.cli.RemoveNetwork(nw.ID())
+	})
+
+	ds, ok := nw.(containers.DualStackNetwork)
+	if !ok {
+		s.t.Skip("adapter networks do not support IPv6")
+	}
+
+	subnet := ds.Subnet6()
+	if subnet == nil || subnet.String() != cidr {
+		s.t.Fatalf("Subnet6: %v, want %s", subnet, cidr)
+	}
+
+	id := s.start(s.container(nw))
+
+	endpoint := s.inspect(id).Networks[nw.Name()]
+
+	if ip := net.ParseIP(endpoint.IPv6Address); ip == nil || !subnet.Contains(ip) {
+		s.t.Errorf("ContainerInspect: ipv6 address %q, want address in %s", endpoint.IPv6Address, cidr)
+	}
+
+	if net.ParseIP(endpoint.IPAddress).To4() == nil {
+		s.t.Errorf("ContainerInspect: ipv4 address %q, want dual-stack endpoint", endpoint.IPAddress)
+	}
+}
+
+func (s *suite) images() {
+	exist, err := s.cli.FindImageLocal(s.ctx, missingImage)
+	if err != nil || exist {
+		s.t.Errorf("FindImageLocal missing image: %v, %v", exist, err)
+	}
+
+	s.ensureImage()
+
+	found, err := s.cli.FindImagesLocal(s.ctx, []string{s.cfg.Image, missingImage})
+	if err != nil {
+		s.t.Fatalf("FindImagesLocal: %v", err)
+	}
+
+	if !found[s.cfg.Image] || found[missingImage] {
+		s.t.Errorf("FindImagesLocal: %v", found)
+	}
+}
+
+// attestations - аттестации сборки образа доступны через ImageInspect; адаптер,
+// которому аттестации локальных образов недоступны, проверку пропускает
+func (s *suite) attestations() {
+	s.ensureImage()
+
+	if _, err := s.cli.ImageInspect(s.ctx, missingImage); !errors.Is(err, containers.ErrImageNotFound) {
+		s.t.Errorf("ImageInspect missing image: %v, want ErrImageNotFound", err)
+	}
+
+	root := s.t.TempDir()
+	dockerfile := "FROM " + s.cfg.Image + "\nLABEL adaptertest=attestations\n"
+
+	if err := os.WriteFile(filepath.Join(root, "Dockerfile"), []byte(dockerfile), 0o600); err != nil {
+		s.t.Fatalf("write Dockerfile: %v", err)
+	}
+
+	tag := uniqueName() + ":latest"
+
+	err := s.cli.BuildImage(
+		&containers.ImageBuildData{Tags: []string{tag}, Root: root, Output: io.Discard, Provenance: true, SBOM: true},
+	)
+	if err != nil {
+		s.t.Fatalf("BuildImage: %v", err)
+	}
+
+	s.t.Cleanup(func() { s.cli.RemoveImage(tag) })
+
+	details, err := s.cli.ImageInspect(s.ctx, tag)
+	if err != nil {
+		s.t.Fatalf("ImageInspect: %v", err)
+	}
+
+	if details.Attestations == nil {
+		s.t.Skip("adapter exposes attestations of registry images only")
+	}
+
+	materials, err := details.Attestations.Materials()
+	if err != nil || len(materials) == 0 {
+		s.t.Fatalf("Attestations.Materials: %v, %v", materials, err)
+	}
+
+	packages, err := details.Attestations.Packages()
+	if err != nil || len(packages) == 0 {
+		s.t.Errorf("Attestations.Packages: %v, %v", packages, err)
+	}
+}
+
+func (s *suite) createMissingImage() {
+	c := s.container(s.newNetwork())
+	c.Image = missingImage
+
+	id, err := s.cli.ContainerCreate(s.ctx, c)
+	if err == nil {
+		s.remove(id)
+	}
+
+	if !errors.Is(err, containers.ErrImageNotFound) {
+		s.t.Errorf("ContainerCreate missing image: %v, want ErrImageNotFound", err)
+	}
+}
+
+func (s *suite) nameConflict() {
+	s.ensureImage()
+
+	c := s.container(s.newNetwork())
+	s.create(c)
+
+	if id, err := s.cli.ContainerCreate(s.ctx, c); err == nil {
+		s.remove(id)
+		s.t.Errorf("ContainerCreate duplicate name %q succeeded", c.Name)
+	}
+}
+
+func (s *suite) handle() {
+	s.ensureImage()
+
+	c := s.container(s.newNetwork())
+
+	h, err := containers.CreateContainer(s.ctx, s.cli, c)
+	if err != nil {
+		s.t.Fatalf("CreateContainer: %v", err)
+	}
+
+	s.t.Cleanup(func() { s.remove(h.ID()) })
+
+	if h.Name() != c.Name || h.Client() != s.cli {
+		s.t.Errorf("CreateContainer: handle %s, want name %s", h, c.Name)
+	}
+
+	for _, ref := range []string{h.ID(), h.ID()[:12], c.Name} {
+		found, lookupErr := containers.LookupContainer(s.ctx, s.cli, ref)
+		if lookupErr != nil {
+			s.t.Errorf("LookupContainer %s: %v", ref, lookupErr)
+
+			continue
+		}
+
+		if found.ID() != h.ID() || found.Name() != h.Name() {
+			s.t.Errorf("LookupContainer %s: handle %s (%s), want %s (%s)", ref, found, found.ID(), h, h.ID())
+		}
+	}
+
+	if _, err = (containers.Handle{}).Start(s.ctx); !errors.Is(err, containers.ErrInvalidHandle) {
+		s.t.Errorf("zero Handle Start: %v, want %v", err, containers.ErrInvalidHandle)
+	}
+}
+
+// adopt - контейнер, запущенный в обход BaseContainer, оборачивается
+// Adopt; без владения Stop его не останавливает, с владением - удаляет
+func (s *suite) adopt() {
+	s.ensureImage()
+
+	nw := s.newNetwork()
+	c := s.container(nw)
+	id := s.start(c)
+
+	a, err := containers.Adopt(s.ctx, s.cli, c.Name, containers.WithAdoptedNetwork(nw))
+	if err != nil {
+		s.t.Fatalf("Adopt: %v", err)
+	}
+
+	if a.GetID() != id || a.GetName() != c.Name {
+		s.t.Errorf("Adopt: %s (%s), want %s (%s)", a.GetName(), a.GetID(), c.Name, id)
+	}
+
+	if ip := s.inspect(id).Networks[nw.Name()].IPAddress; a.GetContainerIP() != ip {
+		s.t.Errorf("Adopt: container ip %q, want %q", a.GetContainerIP(), ip)
+	}
+
+	s.adoptedRun(a)
+
+	if state := s.inspect(id); !state.Running() {
+		s.t.Errorf("Stop of not owned container: status %s, want running", state.Status)
+	}
+
+	owned, err := containers.Adopt(s.ctx, s.cli, id, containers.WithOwnership(true))
+	if err != nil {
+		s.t.Fatalf("Adopt with ownership: %v", err)
+	}
+
+	s.adoptedRun(owned)
+
+	if _, err = s.cli.ContainerInspect(s.ctx, id); err == nil {
+		s.t.Errorf("Stop of owned container: container %s not removed", id)
+	}
+}
+
+// adoptedRun - дожидается готовности контейнера в StartContainer и
+// останавливает его
+func (s *suite) adoptedRun(a *containers.Adopted) {
+	ready := make(chan struct{})
+	exit := make(chan error, 1)
+
+	go func() {
+		exit <- a.StartContainer(nil, ready)
+	}()
+
+	select {
+	case <-ready:
+	case err := <-exit:
+		s.t.Fatalf("StartContainer adopted: %v", err)
+	case <-s.ctx.Done():
+		s.t.Fatalf("StartContainer adopted: %v", s.ctx.Err())
+	}
+
+	if err := a.Stop(); err != nil {
+		s.t.Errorf("Stop adopted: %v", err)
+	}
+
+	select {
+	case err := <-exit:
+		if err != nil {
+			s.t.Errorf("StartContainer adopted after Stop: %v", err)
+		}
+	case <-s.ctx.Done():
+		s.t.Fatalf("StartContainer adopted after Stop: %v", s.ctx.Err())
+	}
+}
+
+func (s *suite) lifecycle() {
+	s.ensureImage()
+
+	c := s.container(s.newNetwork())
+	id := s.create(c)
+
+	if state := s.inspect(id); state.Status != containers.StateCreated {
+		s.t.Errorf("ContainerInspect created: status %q", state.Status)
+	}
+
+	info, err := s.cli.ContainerStart(s.ctx, id, c.Name)
+	if err != nil {
+		s.t.Fatalf("ContainerStart: %v", err)
+	}
+
+	if info.ID != id {
+		s.t.Errorf("ContainerStart: id %q, want %q", info.ID, id)
+	}
+
+	if state := s.inspect(id); !state.Running() {
+		s.t.Errorf("ContainerInspect started: status %q", state.Status)
+	}
+
+	s.logs(id)
+
+	if code, execErr := s.cli.ContainerExec(s.ctx, id, []string{"true"}, nil, nil); execErr != nil || code != 0 {
+		s.t.Errorf("ContainerExec true: %d, %v", code, execErr)
+	}
+
+	list, err := s.cli.ContainerList(s.ctx, containers.ListFilter{NamePrefix: c.Name})
+	if err != nil {
+		s.t.Fatalf("ContainerList: %v", err)
+	}
+
+	if len(list) != 1 || list[0].ID != id || list[0].Status != containers.StateRunning {
+		s.t.Errorf("ContainerList: %+v", list)
+	}
+
+	waitCh, errCh := s.cli.ContainerWait(s.ctx, id)
+
+	if err = s.cli.ContainerKill(s.ctx, id, "KILL"); err != nil {
+		s.t.Fatalf("ContainerKill: %v", err)
+	}
+
+	select {
+	case status := <-waitCh:
+		if status.StatusCode != killExitCode {
+			s.t.Errorf("ContainerWait: exit code %d, want %d", status.StatusCode, killExitCode)
+		}
+	case err = <-errCh:
+		s.t.Fatalf("ContainerWait: %v", err)
+	case <-s.ctx.Done():
+		s.t.Fatalf("ContainerWait: %v", s.ctx.Err())
+	}
+
+	if state := s.inspect(id); state.Status != containers.StateExited || state.ExitCode != killExitCode {
+		s.t.Errorf("ContainerInspect killed: status %q, exit code %d", state.Status, state.ExitCode)
+	}
+
+	if err = s.cli.ContainerRemove(s.ctx, id); err != nil {
+		s.t.Fatalf("ContainerRemove: %v", err)
+	}
+
+	if _, err = s.cli.ContainerInspect(s.ctx, id); err == nil {
+		s.t.Errorf("ContainerInspect removed container succeeded")
+	}
+}
+
+// healthcheck - проверка здоровья, заданная при создании, доводит состояние
+// контейнера в ContainerInspect до healthy
+func (s *suite) healthcheck() {
+	s.ensureImage()
+
+	c := s.container(s.newNetwork())
+	c.Healthcheck = containers.HealthCmd("true")
+	c.Healthcheck.Interval = 200 * time.Millisecond
+	c.Healthcheck.Retries = 1
+
+	id := s.start(c)
+
+	var health string
+
+	err := wait.Poll(
+		s.ctx, wait.DefaultBackoff, func(ctx context.Context) error {
+			health = s.inspect(id).Health
+			if health != containers.HealthHealthy {
+				return errNotHealthy
+			}
+
+			return nil
+		},
+	)
+	if err != nil {
+		s.t.Errorf("ContainerInspect: health %q, want %q: %v", health, containers.HealthHealthy, err)
+	}
+}
+
+// resources - предел памяти контейнера виден в замерах ContainerStats;
+// хосты без поддержки лимита памяти пропускают проверку
+func (s *suite) resources() {
+	s.ensureImage()
+
+	info, err := s.cli.Info(s.ctx)
+	if err != nil {
+		s.t.Fatalf("Info: %v", err)
+	}
+
+	if !info.Limits.Memory {
+		s.t.Skip("host does not support memory limits")
+	}
+
+	c := s.container(s.newNetwork())
+	c.Resources = containers.Resources{MemoryLimit: memoryLimit}
+
+	id := s.start(c)
+
+	ctx, cancel := context.WithCancel(s.ctx)
+	defer cancel()
+
+	samples, err := s.cli.ContainerStats(ctx, id)
+	if err != nil {
+		s.t.Fatalf("ContainerStats: %v", err)
+	}
+
+	sample, ok := <-samples
+	if !ok {
+		s.t.Fatalf("ContainerStats: stream closed without samples")
+	}
+
+	if sample.MemoryLimit != memoryLimit {
+		s.t.Errorf("ContainerStats: memory limit %d, want %d", sample.MemoryLimit, memoryLimit)
+	}
+}
+
+// registry - запущенный контейнер попадает в реестр сети с образом, метками,
+// сетью и состоянием, Stop переводит его в StateExited
+func (s *suite) registry() {
+	s.ensureImage()
+
+	nw := s.newNetwork()
+	c := s.container(nw)
+	c.Labels = map[string]string{roleLabel: c.Name}
+
+	if err := c.Run(s.ctx); err != nil {
+		s.t.Fatalf("Run: %v", err)
+	}
+
+	s.t.Cleanup(func() { s.remove(c.GetID()) })
+
+	reg := containers.NetworkRegistry(nw)
+
+	info, ok := reg.Get(c.Name)
+	if !ok {
+		s.t.Fatalf("Registry.Get %s: not found in %+v", c.Name, reg.All())
+	}
+
+	if info.ID != c.GetID() || info.State != containers.StateRunning {
+		s.t.Errorf("Registry.Get: id %q, state %q, want %q, %q", info.ID, info.State, c.GetID(), containers.StateRunning)
+	}
+
+	if endpoint := info.Networks[nw.Name()]; endpoint.NetworkID != nw.ID() || endpoint.IPAddress == "" {
+		s.t.Errorf("Registry.Get: network %s endpoint %+v, want id %s", nw.Name(), endpoint, nw.ID())
+	}
+
+	if found := reg.ByImage(s.cfg.Image); len(found) != 1 || found[0].ID != c.GetID() {
+		s.t.Errorf("Registry.ByImage %s: %+v", s.cfg.Image, found)
+	}
+
+	if found := reg.ByLabel(roleLabel, c.Name); len(found) != 1 || found[0].ID != c.GetID() {
+		s.t.Errorf("Registry.ByLabel %s=%s: %+v", roleLabel, c.Name, found)
+	}
+
+	list, err := s.cli.ContainerList(s.ctx, containers.ListFilter{Labels: map[string]string{roleLabel: c.Name}})
+	if err != nil {
+		s.t.Fatalf("ContainerList: %v", err)
+	}
+
+	if len(list) != 1 || list[0].ID != c.GetID() || list[0].Labels[roleLabel] != c.Name {
+		s.t.Errorf("ContainerList by label %s=%s: %+v", roleLabel, c.Name, list)
+	}
+
+	if healthy := reg.Healthy(); len(healthy) != 1 {
+		s.t.Errorf("Registry.Healthy: %+v", healthy)
+	}
+
+	if err := c.Stop(); err != nil {
+		s.t.Fatalf("Stop: %v", err)
+	}
+
+	if info, _ = reg.Get(c.Name); info.State != containers.StateExited {
+		s.t.Errorf("Registry.Get stopped: state %q, want %q", info.State, containers.StateExited)
+	}
+
+	if healthy := reg.Healthy(); len(healthy) != 0 {
+		s.t.Errorf("Registry.Healthy after Stop: %+v", healthy)
+	}
+
+	report, ok := c.RunReport()
+	if !ok {
+		s.t.Fatalf("RunReport after Stop: no report")
+	}
+
+	if report.ID != c.GetID() || report.Image != s.cfg.Image || report.Digest == "" || report.Duration <= 0 {
+		s.t.Errorf("RunReport: %+v", report)
+	}
+}
+
+func (s *suite) project() {
+	s.ensureImage()
+
+	project := containers.Project(uniqueName())
+	c := s.container(s.newNetwork())
+	name := c.Name
+	c.NamedVolumes = []containers.VolumeSpec{{Name: "data", Target: "/data"}}
+	project.Container(c)
+
+	if c.Name != project.Name(name) || len(c.Aliases) == 0 || c.Aliases[len(c.Aliases)-1] != name {
+		s.t.Errorf("Project.Container: name %q, aliases %v", c.Name, c.Aliases)
+	}
+
+	s.t.Cleanup(func() {
+		_ = s.cli.VolumeRemove(context.Background(), c.NamedVolumes[0].Name, true)
+	})
+
+	id := s.create(c)
+
+	res, err := s.cli.ListProjectResources(s.ctx, string(project))
+	if err != nil {
+		s.t.Fatalf("ListProjectResources: %v", err)
+	}
+
+	if len(res.Containers) != 1 || res.Containers[0].ID != id {
+		s.t.Errorf("ListProjectResources: containers %+v, want %s", res.Containers, id)
+	}
+
+	if len(res.Volumes) != 1 || res.Volumes[0].Name != project.Name("data") {
+		s.t.Errorf("ListProjectResources: volumes %+v, want %s", res.Volumes, project.Name("data"))
+	}
+
+	if _, err = containers.TeardownProject(s.ctx, s.cli, project); err != nil {
+		s.t.Fatalf("TeardownProject: %v", err)
+	}
+
+	if res, err = s.cli.ListProjectResources(s.ctx, string(project)); err != nil || !res.Empty() {
+		s.t.Errorf("ListProjectResources after teardown: %+v, %v", res, err)
+	}
+}
+
+func (s *suite) stop() {
+	s.ensureImage()
+
+	c := s.container(s.newNetwork())
+	id := s.start(c)
+
+	waitCh, errCh := s.cli.ContainerWait(s.ctx, id)
+
+	if err := s.cli.ContainerStop(s.ctx, id, 0); err != nil {
+		s.t.Fatalf("ContainerStop: %v", err)
+	}
+
+	select {
+	case <-waitCh:
+	case err := <-errCh:
+		s.t.Fatalf("ContainerWait: %v", err)
+	case <-s.ctx.Done():
+		s.t.Fatalf("ContainerWait: %v", s.ctx.Err())
+	}
+
+	if state := s.inspect(id); state.Running() {
+		s.t.Errorf("ContainerInspect stopped: status %q", state.Status)
+	}
+}
+
+func (s *suite) events() {
+	s.ensureImage()
+
+	c := s.container(s.newNetwork())
+	id := s.create(c)
+
+	ctx, cancel := context.WithCancel(s.ctx)
+	defer cancel()
+
+	events := s.cli.Events(ctx, containers.FilterContainer(id))
+
+	if _, err := s.cli.ContainerStart(s.ctx, id, c.Name); err != nil {
+		s.t.Fatalf("ContainerStart: %v", err)
+	}
+
+	if err := s.cli.ContainerKill(s.ctx, id, "KILL"); err != nil {
+		s.t.Fatalf("ContainerKill: %v", err)
+	}
+
+	var started bool
+
+	for {
+		select {
+		case e, ok := <-events:
+			if !ok {
+				s.t.Fatalf("Events: stream closed, start seen %v", started)
+			}
+
+			switch e.Action {
+			case containers.EventStart:
+				started = true
+			case containers.EventDie:
+				if !started {
+					s.t.Errorf("Events: die before start")
+				}
+
+				if code, ok := e.ExitCode(); !ok || code != killExitCode {
+					s.t.Errorf("Events: die exit code %d (%v), want %d", code, ok, killExitCode)
+				}
+
+				return
+			}
+		case <-s.ctx.Done():
+			s.t.Fatalf("Events: %v, start seen %v", s.ctx.Err(), started)
+		}
+	}
+}
+
+func (s *suite) copy() {
+	s.ensureImage()
+
+	id := s.start(s.container(s.newNetwork()))
+
+	var buf bytes.Buffer
+
+	tw := tar.NewWriter(&buf)
+
+	if err := tw.WriteHeader(
+		&tar.Header{Name: copyFile, Mode: 0o644, Size: int64(len(copyContent)), ModTime: time.Now()},
+	); err != nil {
+		s.t.Fatalf("write tar header: %v", err)
+	}
+
+	_, _ = tw.Write([]byte(copyContent))
+	_ = tw.Close()
+
+	if err := s.cli.CopyToContainer(s.ctx, id, "/tmp", &buf); err != nil {
+		s.t.Fatalf("CopyToContainer: %v", err)
+	}
+
+	rc, err := s.cli.CopyFromContainer(s.ctx, id, "/tmp/"+copyFile)
+	if err != nil {
+		s.t.Fatalf("CopyFromContainer: %v", err)
+	}
+
+	defer func() {
+		_ = rc.Close()
+	}()
+
+	tr := tar.NewReader(rc)
+
+	hdr, err := tr.Next()
+	if err != nil {
+		s.t.Fatalf("CopyFromContainer: read tar: %v", err)
+	}
+
+	content, err := io.ReadAll(tr)
+	if err != nil {
+		s.t.Fatalf("CopyFromContainer: read entry: %v", err)
+	}
+
+	if hdr.Name != copyFile || string(content) != copyContent {
+		s.t.Errorf("CopyFromContainer: entry %q with %q", hdr.Name, content)
+	}
+}
+
+func (s *suite) volumes() {
+	s.ensureImage()
+
+	name := uniqueName()
+	spec := containers.VolumeSpec{Name: name, Target: "/data", Labels: map[string]string{volumeLabel: name}}
+
+	info, err := s.cli.VolumeCreate(s.ctx, spec)
+	if err != nil {
+		s.t.Fatalf("VolumeCreate: %v", err)
+	}
+
+	s.t.Cleanup(func() {
+		_ = s.cli.VolumeRemove(context.Background(), name, true)
+	})
+
+	if info.Name != name {
+		s.t.Errorf("VolumeCreate: name %q, want %q", info.Name, name)
+	}
+
+	if _, err = s.cli.VolumeCreate(s.ctx, spec); err != nil {
+		s.t.Errorf("VolumeCreate existing volume: %v, want nil", err)
+	}
+
+	list, err := s.cli.VolumeList(s.ctx, containers.VolumeFilter{Labels: map[string]string{volumeLabel: name}})
+	if err != nil {
+		s.t.Fatalf("VolumeList: %v", err)
+	}
+
+	if len(list) != 1 || list[0].Name != name {
+		s.t.Errorf("VolumeList by label: %+v, want single %s", list, name)
+	}
+
+	c := s.container(s.newNetwork())
+	c.NamedVolumes = []containers.VolumeSpec{spec}
+	c.Tmpfs = map[string]string{"/scratch": "size=16m"}
+	c.MountSpecs = []containers.MountSpec{{Type: containers.MountTmpfs, Target: "/cache", TmpfsSize: 1 << 20}}
+	id := s.create(c)
+
+	mounted, tmpfs, cache := false, false, false
+
+	for _, m := range s.inspect(id).Mounts {
+		mounted = mounted || (m.Type == "volume" && m.Source == name && m.Destination == spec.Target)
+		tmpfs = tmpfs || (m.Type == "tmpfs" && m.Destination == "/scratch")
+		cache = cache || (m.Type == containers.MountTmpfs && m.Destination == "/cache")
+	}
+
+	if !cache {
+		s.t.Errorf("ContainerInspect: tmpfs mount spec is not mounted to /cache")
+	}
+
+	if !mounted {
+		s.t.Errorf("ContainerInspect: volume %s is not mounted to %s", name, spec.Target)
+	}
+
+	if !tmpfs {
+		s.t.Errorf("ContainerInspect: tmpfs is not mounted to /scratch")
+	}
+
+	if err = s.cli.VolumeRemove(s.ctx, name, false); !errors.Is(err, containers.ErrVolumeInUse) {
+		s.t.Errorf("VolumeRemove volume in use: %v, want %v", err, containers.ErrVolumeInUse)
+	}
+
+	s.remove(id)
+
+	if err = s.cli.VolumeRemove(s.ctx, name, false); err != nil {
+		s.t.Errorf("VolumeRemove: %v", err)
+	}
+
+	if err = s.cli.VolumeRemove(s.ctx, name, false); !errors.Is(err, containers.ErrVolumeNotFound) {
+		s.t.Errorf("VolumeRemove removed volume: %v, want %v", err, containers.ErrVolumeNotFound)
+	}
+}
+
+// cancelledStartup - запуск, прерванный отменой контекста в случайный момент,
+// не оставляет ни контейнера, ни созданного для него тома. Моменты отмены
+// выбираются в пределах длительности полного запуска
+func (s *suite) cancelledStartup() {
+	s.ensureImage()
+
+	nw := s.newNetwork()
+
+	begin := time.Now()
+
+	c := s.volumeContainer(nw)
+	if err := c.Run(s.ctx); err != nil {
+		s.t.Fatalf("Run: %v", err)
+	}
+
+	window := time.Since(begin)
+
+	s.discard(c)
+
+	for i := 0; i < cancelRounds; i++ {
+		delay, _ := rand.Int(rand.Reader, big.NewInt(int64(window)+1))
+
+		ctx, cancel := context.WithTimeout(s.ctx, time.Duration(delay.Int64()))
+
+		c = s.volumeContainer(nw)
+		err := c.Run(ctx)
+
+		cancel()
+
+		if err == nil {
+			// запуск успел завершиться до отмены
+			s.discard(c)
+
+			continue
+		}
+
+		list, err := s.cli.ContainerList(s.ctx, containers.ListFilter{NamePrefix: c.Name})
+		if err != nil {
+			s.t.Fatalf("ContainerList: %v", err)
+		}
+
+		if len(list) != 0 {
+			s.t.Errorf("Run cancelled after %s: container %s left: %+v", time.Duration(delay.Int64()), c.Name, list)
+		}
+
+		volumes, err := s.cli.VolumeList(s.ctx, containers.VolumeFilter{NamePrefix: c.NamedVolumes[0].Name})
+		if err != nil {
+			s.t.Fatalf("VolumeList: %v", err)
+		}
+
+		if len(volumes) != 0 {
+			s.t.Errorf("Run cancelled after %s: volume %s left", time.Duration(delay.Int64()), c.NamedVolumes[0].Name)
+		}
+
+		s.discard(c)
+	}
+}
+
+// volumeContainer - проверочный контейнер с собственным именованным томом
+func (s *suite) volumeContainer(nw containers.Network) *containers.BaseContainer {
+	c := s.container(nw)
+	c.NamedVolumes = []containers.VolumeSpec{{Name: uniqueName(), Target: "/data"}}
+
+	return c
+}
+
+// discard - удаляет контейнер и том проверки отмены
+func (s *suite) discard(c *containers.BaseContainer) {
+	if id := c.GetID(); id != "" {
+		s.remove(id)
+	}
+
+	_ = s.cli.VolumeRemove(context.Background(), c.NamedVolumes[0].Name, true)
+}
+
+func (s *suite) unknownContainer() {
+	id := uniqueName()
+
+	if err := s.cli.ContainerRemove(s.ctx, id); err != nil {
+		s.t.Errorf("ContainerRemove unknown container: %v, want nil", err)
+	}
+
+	if _, err := s.cli.ContainerInspect(s.ctx, id); err == nil {
+		s.t.Errorf("ContainerInspect unknown container succeeded")
+	}
+
+	if _, err := s.cli.ContainerStart(s.ctx, id, id); err == nil {
+		s.t.Errorf("ContainerStart unknown container succeeded")
+	}
+}
+
+// logs - дожидается маркеров проверочного контейнера в своих потоках
+func (s *suite) logs(id string) {
+	logs := containers.NewLogs()
+	ctx, cancel := context.WithCancel(s.ctx)
+	done := make(chan error, 1)
+
+	go func() {
+		done <- s.cli.StreamLogs(ctx, id, logs.Stderr(), logs.Stdout(), true)
+	}()
+
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	for _, want := range []struct{ stream, marker string }{
+		{containers.LogStdout, StdoutMarker},
+		{containers.LogStderr, StderrMarker},
+	} {
+		lines, err := logs.InOrder(s.cfg.Timeout, regexp.QuoteMeta(want.marker))
+		if err != nil {
+			s.t.Errorf("StreamLogs: %v; logs %q", err, logs.String())
+
+			continue
+		}
+
+		if lines[0].Stream != want.stream {
+			s.t.Errorf("StreamLogs: %q in %s, want %s", want.marker, lines[0].Stream, want.stream)
+		}
+	}
+}
+
+// newNetwork - сеть проверки, удаляется по завершении подтеста
+func (s *suite) newNetwork() containers.Network {
+	nw, err := s.cli.CheckNetwork(uniqueName(), "")
+	if err != nil {
+		s.t.Fatalf("CheckNetwork: %v", err)
+	}
+
+	s.t.Cleanup(func() {
+		_ = s.cli.RemoveNetwork(nw.ID())
+	})
+
+	return nw
+}
+
+// container - описание проверочного контейнера с уникальным именем
+func (s *suite) container(nw containers.Network) *containers.BaseContainer {
+	c := containers.NewBaseContainer(s.cli, nw, nil)
+	c.Name = uniqueName()
+	c.Image = s.cfg.Image
+	c.Cmd = append([]string(nil), s.cfg.Cmd...)
+	c.Sysctls = nil
+
+	return c
+}
+
+func (s *suite) create(c *containers.BaseContainer) string {
+	id, err := s.cli.ContainerCreate(s.ctx, c)
+	if err != nil {
+		s.t.Fatalf("ContainerCreate: %v", err)
+	}
+
+	s.t.Cleanup(func() { s.remove(id) })
+
+	return id
+}
+
+func (s *suite) start(c *containers.BaseContainer) string {
+	id := s.create(c)
+
+	if _, err := s.cli.ContainerStart(s.ctx, id, c.Name); err != nil {
+		s.t.Fatalf("ContainerStart: %v", err)
+	}
+
+	return id
+}
+
+func (s *suite) inspect(id string) *containers.InspectResult {
+	state, err := s.cli.ContainerInspect(s.ctx, id)
+	if err != nil {
+		s.t.Fatalf("ContainerInspect: %v", err)
+	}
+
+	return state
+}
+
+func (s *suite) remove(id string) {
+	_ = s.cli.ContainerRemove(context.Background(), id)
+}
+
+// ensureImage - скачивает образ проверочного контейнера при его отсутствии
+func (s *suite) ensureImage() {
+	exist, err := s.cli.FindImageLocal(s.ctx, s.cfg.Image)
+	if err != nil {
+		s.t.Fatalf("FindImageLocal: %v", err)
+	}
+
+	if exist {
+		return
+	}
+
+	if err = s.cli.PullImageWith(s.ctx, containers.PullOptions{Image: s.cfg.Image}); err != nil {
+		s.t.Fatalf("PullImageWith: %v", err)
+	}
+}
+
+func uniqueName() string {
+	b := make([]byte, 6)
+	_, _ = rand.Read(b)
+
+	return namePrefix + hex.EncodeToString(b)
+}
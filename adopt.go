@@ -0,0 +1,544 @@
+package containers
+
+import (
+	"context"
+	"io"
+	"net"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"gopkg.in/gomisc/errors.v1"
+	"gopkg.in/gomisc/network.v1/ports"
+)
+
+var _ Container = (*Adopted)(nil)
+
+const (
+	// adoptedHostIP - адрес хоста для портов, опубликованных на всех интерфейсах
+	adoptedHostIP = "127.0.0.1"
+	// adoptedStopTimeout - время на штатное завершение контейнера во владении
+	adoptedStopTimeout = 10 * time.Second
+)
+
+type (
+	// Adopted - контейнер, созданный вне пакета (docker compose, другим
+	// инструментом), в виде участника окружения только для чтения: адреса
+	// и порты берутся из состояния контейнера в среде исполнения, а
+	// CreateContainer и StartContainer его не создают и не запускают. Без
+	// владения (WithOwnership) Stop контейнер не останавливает
+	Adopted struct {
+		handle Handle
+		// portNames - имена портов контейнера, неименованные порты
+		// называются номером порта
+		portNames map[Port]ports.PortName
+		network   Network
+		readiness ReadinessFunc
+		timeout   time.Duration
+		owned     bool
+		remove    bool
+		stdout    io.Writer
+		stderr    io.Writer
+
+		mu             sync.RWMutex
+		info           *InspectResult
+		containerIP    string
+		hostAddrs      AddrsMap
+		containerAddrs AddrsMap
+		stopped        chan struct{}
+		stopOnce       sync.Once
+		stopErr        error
+	}
+
+	// AdoptOption - опция контейнера, созданного вне пакета
+	AdoptOption func(a *Adopted)
+)
+
+// WithAdoptedPorts - имена портов контейнера для HostAddrs и ContainerAddrs
+func WithAdoptedPorts(names map[Port]ports.PortName) AdoptOption {
+	return func(a *Adopted) {
+		for port, name := range names {
+			a.portNames[port] = name
+		}
+	}
+}
+
+// WithAdoptedNetwork - сеть, адрес в которой возвращают ContainerAddrs;
+// по умолчанию - первая по имени сеть контейнера
+func WithAdoptedNetwork(nw Network) AdoptOption {
+	return func(a *Adopted) {
+		a.network = nw
+	}
+}
+
+// WithAdoptedReadiness - проверка готовности для StartContainer, по
+// умолчанию работающий контейнер считается готовым
+func WithAdoptedReadiness(readiness ReadinessFunc, timeout time.Duration) AdoptOption {
+	return func(a *Adopted) {
+		a.readiness = readiness
+		a.timeout = timeout
+	}
+}
+
+// WithOwnership - передает пакету владение контейнером: Stop останавливает
+// контейнер, а с remove и удаляет его
+func WithOwnership(remove bool) AdoptOption {
+	return func(a *Adopted) {
+		a.owned = true
+		a.remove = remove
+	}
+}
+
+// WithAdoptedOutput - потоки служебных сообщений контейнера
+func WithAdoptedOutput(stdout, stderr io.Writer) AdoptOption {
+	return func(a *Adopted) {
+		a.stdout = stdout
+		a.stderr = stderr
+	}
+}
+
+// Adopt - оборачивает существующий контейнер с именем или идентификатором
+// nameOrID, адреса и порты определяются по его текущему состоянию
+func Adopt(ctx context.Context, cli Client, nameOrID string, opts ...AdoptOption) (*Adopted, error) {
+	h, err := LookupContainer(ctx, cli, nameOrID)
+	if err != nil {
+		return nil, errors.Ctx().Str("container", nameOrID).Wrap(err, "adopt container")
+	}
+
+	a := &Adopted{
+		handle:    h,
+		portNames: make(map[Port]ports.PortName),
+		stopped:   make(chan struct{}),
+	}
+
+	for _, apply := range opts {
+		apply(a)
+	}
+
+	if err = a.Refresh(ctx); err != nil {
+		return nil, err
+	}
+
+	return a, nil
+}
+
+// Handle - ссылка на контейнер для операций с ним
+func (a *Adopted) Handle() Handle {
+	return a.handle
+}
+
+// Owned - признак владения контейнером (см. WithOwnership)
+func (a *Adopted) Owned() bool {
+	return a.owned
+}
+
+// Info - состояние контейнера на момент последнего Refresh
+func (a *Adopted) Info() InspectResult {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	return *a.info
+}
+
+// Refresh - перечитывает состояние контейнера и его адреса
+func (a *Adopted) Refresh(ctx context.Context) error {
+	info, err := a.handle.Inspect(ctx)
+	if err != nil {
+		return errors.Ctx().Str("container-name", a.GetName()).Wrap(err, "inspect adopted container")
+	}
+
+	hostAddrs := make(AddrsMap, len(info.PortBinds))
+
+	for port, binds := range info.PortBinds {
+		if len(binds) == 0 || binds[0].HostPort == "" {
+			continue
+		}
+
+		hostIP := binds[0].HostIP
+		if ip := net.ParseIP(hostIP); ip == nil || ip.IsUnspecified() {
+			hostIP = adoptedHostIP
+		}
+
+		hostAddrs[a.portName(port)] = net.JoinHostPort(hostIP, binds[0].HostPort)
+	}
+
+	containerIP := a.endpoint(info).IPAddress
+	containerAddrs := make(AddrsMap, len(info.PortBinds))
+
+	if containerIP != "" {
+		for port := range info.PortBinds {
+			containerAddrs[a.portName(port)] = net.JoinHostPort(containerIP, port.Port())
+		}
+	}
+
+	a.mu.Lock()
+	a.info = info
+	a.containerIP = containerIP
+	a.hostAddrs = hostAddrs
+	a.containerAddrs = containerAddrs
+	a.mu.Unlock()
+
+	return nil
+}
+
+// Wait - ожидает завершения процесса контейнера
+func (a *Adopted) Wait(ctx context.Context) (ContainerStatus, error) {
+	statusCh, errCh := a.handle.Wait(ctx)
+
+	select {
+	case status := <-statusCh:
+		return status, status.Error
+	case err := <-errCh:
+		return ContainerStatus{}, errors.Ctx().Str("container-name", a.GetName()).Wrap(err, "wait adopted container")
+	}
+}
+
+// GetID - полный идентификатор контейнера
+func (a *Adopted) GetID() string {
+	return a.handle.ID()
+}
+
+// GetClient - клиент среды исполнения, в которой найден контейнер
+func (a *Adopted) GetClient() Client {
+	return a.handle.Client()
+}
+
+func (a *Adopted) GetName() string {
+	return a.handle.Name()
+}
+
+// GetImage - пустой образ исключает участника из подготовки образов и экспорта
+func (a *Adopted) GetImage() string {
+	return ""
+}
+
+func (a *Adopted) GetSysctls() map[string]string {
+	return nil
+}
+
+func (a *Adopted) GetContainerIP() string {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	return a.containerIP
+}
+
+// ContainerPorts - опубликованные порты контейнера
+func (a *Adopted) ContainerPorts() []Port {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	result := make([]Port, 0, len(a.info.PortBinds))
+	for port := range a.info.PortBinds {
+		result = append(result, port)
+	}
+
+	sort.Slice(
+		result, func(i, j int) bool {
+			return result[i] < result[j]
+		},
+	)
+
+	return result
+}
+
+// PortMap - фактические привязки портов контейнера на хосте
+func (a *Adopted) PortMap() PortMap {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	pm := make(PortMap, len(a.info.PortBinds))
+	for port, binds := range a.info.PortBinds {
+		pm[port] = append([]PortBinding(nil), binds...)
+	}
+
+	return pm
+}
+
+func (a *Adopted) GetEnvs() []string {
+	return nil
+}
+
+func (a *Adopted) GetEntryPoint() string {
+	return ""
+}
+
+func (a *Adopted) GetCmd() []string {
+	return nil
+}
+
+func (a *Adopted) GetVolumes() []string {
+	return nil
+}
+
+func (a *Adopted) GetMounts() []string {
+	return nil
+}
+
+func (a *Adopted) GetNamedVolumes() []VolumeSpec {
+	return nil
+}
+
+func (a *Adopted) GetTmpfs() map[string]string {
+	return nil
+}
+
+func (a *Adopted) GetMountSpecs() []MountSpec {
+	return nil
+}
+
+func (a *Adopted) GetAutoremove() bool {
+	return false
+}
+
+func (a *Adopted) GetNetwork() Network {
+	return a.network
+}
+
+func (a *Adopted) GetExtraNetworks() []NetworkAttachment {
+	return nil
+}
+
+func (a *Adopted) GetAliases() []string {
+	return nil
+}
+
+func (a *Adopted) GetExtraHosts() []string {
+	return nil
+}
+
+func (a *Adopted) GetDNS() []string {
+	return nil
+}
+
+func (a *Adopted) GetDNSSearch() []string {
+	return nil
+}
+
+func (a *Adopted) GetRuntime() string {
+	return ""
+}
+
+func (a *Adopted) GetPlatform() string {
+	return ""
+}
+
+func (a *Adopted) GetHooks() Hooks {
+	return Hooks{}
+}
+
+func (a *Adopted) GetHealthcheck() *Healthcheck {
+	return nil
+}
+
+func (a *Adopted) GetResources() Resources {
+	return Resources{}
+}
+
+func (a *Adopted) GetDevices() []Device {
+	return nil
+}
+
+func (a *Adopted) GetGPUs() []GPURequest {
+	return nil
+}
+
+func (a *Adopted) GetUser() string {
+	return ""
+}
+
+func (a *Adopted) GetWorkDir() string {
+	return ""
+}
+
+func (a *Adopted) GetLabels() map[string]string {
+	return nil
+}
+
+// HostAddrs - адреса опубликованных портов контейнера на хосте
+func (a *Adopted) HostAddrs() AddrsMap {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	return a.hostAddrs.Copy()
+}
+
+// ContainerAddrs - адреса портов контейнера в его сети
+func (a *Adopted) ContainerAddrs() AddrsMap {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	return a.containerAddrs.Copy()
+}
+
+// CreateContainer - контейнер уже создан, проверяется только его наличие
+func (a *Adopted) CreateContainer() error {
+	return a.Refresh(context.Background())
+}
+
+// StartContainer - не запускает контейнер, а дожидается готовности уже
+// работающего; затем, как и BaseContainer, ожидает завершения процесса,
+// сигнала sigCh или Stop
+func (a *Adopted) StartContainer(sigCh <-chan os.Signal, ready chan<- struct{}) error {
+	if err := a.Refresh(context.Background()); err != nil {
+		return err
+	}
+
+	if info := a.Info(); !info.Running() {
+		return errors.Ctx().
+			Str("container-name", a.GetName()).
+			Str("status", info.Status).
+			Wrap(ErrContainerNotRunning, "start adopted container")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	exit := make(chan error, 1)
+
+	go func() {
+		_, err := a.Wait(ctx)
+		exit <- err
+	}()
+
+	if err := a.awaitReady(exit); err != nil {
+		return err
+	}
+
+	a.LogStdout(a.GetName() + " adopted container ready")
+
+	if ready != nil {
+		close(ready)
+	}
+
+	select {
+	case err := <-exit:
+		return err
+	case <-sigCh:
+		return a.Stop()
+	case <-a.stopped:
+		return nil
+	}
+}
+
+// Stop - останавливает (и с WithOwnership(true) удаляет) контейнер, которым
+// владеет пакет; чужой контейнер продолжает работать. В обоих случаях
+// StartContainer возвращает управление
+func (a *Adopted) Stop() error {
+	a.stopOnce.Do(
+		func() {
+			defer close(a.stopped)
+
+			if a.owned {
+				a.stopErr = a.stop()
+			}
+		},
+	)
+
+	return a.stopErr
+}
+
+// LogStdout пишет сообщение в поток стандартного вывода
+func (a *Adopted) LogStdout(format string, args ...any) bool {
+	return logTo(a.output(), format, args...)
+}
+
+// LogStderr пишет сообщение в поток вывода ошибок
+func (a *Adopted) LogStderr(format string, args ...any) bool {
+	return logTo(a.errorOutput(), format, args...)
+}
+
+// LogError пишет ошибку в поток вывода ошибок
+func (a *Adopted) LogError(err error, args ...any) bool {
+	return a.LogStderr("\x1b[91mERROR:\x1b[0m " + errors.Formatted(err, args...).Error())
+}
+
+func (a *Adopted) awaitReady(exit <-chan error) error {
+	if a.readiness == nil {
+		return nil
+	}
+
+	timeout := a.timeout
+	if timeout == 0 {
+		timeout = DefaultStartTimeout
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	select {
+	case err := <-exit:
+		return errors.And(errors.Ctx().Str("container-name", a.GetName()).Just(ErrContainerExitedBeforeReady), err)
+	case <-a.stopped:
+		return errors.Ctx().Str("container-name", a.GetName()).Just(ErrContainerDidntStart)
+	case err := <-a.readiness(ctx):
+		if err != nil {
+			return errors.And(errors.Ctx().Str("container-name", a.GetName()).Just(ErrContainerNotReady), err)
+		}
+	}
+
+	return nil
+}
+
+func (a *Adopted) stop() error {
+	ctx := context.Background()
+
+	if err := a.handle.Stop(ctx, adoptedStopTimeout); err != nil {
+		return errors.Ctx().Str("container-name", a.GetName()).Wrap(err, "stop adopted container")
+	}
+
+	if !a.remove {
+		return nil
+	}
+
+	if err := a.handle.Remove(ctx); err != nil {
+		return errors.Ctx().Str("container-name", a.GetName()).Wrap(err, "remove adopted container")
+	}
+
+	return nil
+}
+
+// endpoint - подключение контейнера к сети network, без нее - к первой по имени сети
+func (a *Adopted) endpoint(info *InspectResult) EndpointSettings {
+	if a.network != nil {
+		return info.Networks[a.network.Name()]
+	}
+
+	names := make([]string, 0, len(info.Networks))
+	for name := range info.Networks {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	if len(names) == 0 {
+		return EndpointSettings{}
+	}
+
+	return info.Networks[names[0]]
+}
+
+func (a *Adopted) portName(port Port) ports.PortName {
+	if name, ok := a.portNames[port]; ok {
+		return name
+	}
+
+	return ports.PortName(port.Port())
+}
+
+func (a *Adopted) output() io.Writer {
+	if a.stdout != nil {
+		return a.stdout
+	}
+
+	return os.Stdout
+}
+
+func (a *Adopted) errorOutput() io.Writer {
+	if a.stderr != nil {
+		return a.stderr
+	}
+
+	return os.Stderr
+}
@@ -0,0 +1,306 @@
+package containers
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+
+	"gopkg.in/gomisc/errors.v1"
+)
+
+const (
+	// DefaultAppBaseImage - базовый образ приложения: в отличие от
+	// DefaultCoverageImage содержит libc, нужную тестовому бинарю, собранному с cgo
+	DefaultAppBaseImage = "gcr.io/distroless/base-debian11"
+	// DefaultAppTagVersion - версия тега образа приложения по умолчанию
+	DefaultAppTagVersion = "local"
+	// AppBinary - путь бинаря приложения в образе, точка входа образа
+	AppBinary = "/app"
+
+	// ErrAppPlatform - текущий исполняемый файл не запускается в linux-контейнере
+	ErrAppPlatform = errors.Const("current executable is not a linux binary")
+	// ErrNoGoModule - каталог сборки приложения не определен: текущий каталог вне go-модуля
+	ErrNoGoModule = errors.Const("current directory is not inside a go module")
+)
+
+// appNameCleaner - символы, недопустимые в имени репозитория образа
+var appNameCleaner = regexp.MustCompile(`[^a-z0-9._-]+`)
+
+// AppImage - образ тестируемого приложения: бинарь, собранный из пакета
+// текущего репозитория, или текущий исполняемый файл (тестовый бинарь),
+// поверх базового образа. Бинарь собирается на хосте и доставляется в
+// контекст сборки, поэтому образ собирается и удаленным демоном:
+//
+//	app, err := containers.Run(ctx, cli).
+//		App(containers.AppImage{Package: "./cmd/server"}).
+//		Network("e2e").
+//		Port("http", 8080).
+//		Start()
+type AppImage struct {
+	// Package - go-пакет приложения, путь относительно Dir или путь импорта;
+	// пусто - образ с текущим исполняемым файлом, команда контейнера
+	// передается ему аргументами (например -test.run=^TestHelperProcess$)
+	Package string
+	// Dir - каталог сборки Package, по умолчанию корень модуля текущего каталога
+	Dir string
+	// Base - базовый образ, по умолчанию DefaultAppBaseImage; ссылка проходит
+	// через ResolveImage
+	Base string
+	// Tag - тег образа, по умолчанию имя пакета или исполняемого файла
+	// с версией DefaultAppTagVersion
+	Tag string
+	// Platform - платформа образа в форме linux/arch, по умолчанию архитектура
+	// хоста; текущий исполняемый файл собран только под нее
+	Platform string
+	// BuildFlags - дополнительные флаги go build
+	BuildFlags []string
+	// Delve - путь к linux-сборке delve на хосте: она копируется в образ по
+	// DefaultDelvePath, а Package собирается без оптимизаций для DelveDebug
+	Delve string
+	// Labels - метки образа
+	Labels map[string]string
+	// Output - вывод сборки образа
+	Output io.Writer
+}
+
+// BuildApp - собирает образ приложения и возвращает его тег; образ
+// пересобирается при каждом вызове, так как исходники могли измениться
+func BuildApp(cli Client, app AppImage) (string, error) {
+	data, err := app.Prepare()
+	if err != nil {
+		return "", err
+	}
+
+	if err = cli.BuildImage(data); err != nil {
+		return "", errors.Ctx().Strings("tags", data.Tags).Wrap(err, "build app image")
+	}
+
+	return data.Tags[0], nil
+}
+
+// Prepare - готовит во временном каталоге контекст сборки образа приложения,
+// каталог удаляется адаптером после сборки (ClearRoot)
+func (a AppImage) Prepare() (*ImageBuildData, error) {
+	tag, err := a.tag()
+	if err != nil {
+		return nil, err
+	}
+
+	platform := a.Platform
+	if platform == "" {
+		platform = "linux/" + runtime.GOARCH
+	}
+
+	root, err := os.MkdirTemp("", "containers-app-")
+	if err != nil {
+		return nil, errors.Wrap(err, "create app build root")
+	}
+
+	if err = a.populate(root, platform); err != nil {
+		_ = os.RemoveAll(root)
+
+		return nil, errors.Ctx().Str("tag", tag).Wrap(err, "prepare app image")
+	}
+
+	return &ImageBuildData{
+		Tags:      []string{tag},
+		Root:      root,
+		ClearRoot: true,
+		Output:    a.Output,
+		Platform:  platform,
+		Labels:    a.Labels,
+	}, nil
+}
+
+// populate - бинарь приложения, delve и Dockerfile в каталоге root
+func (a AppImage) populate(root, platform string) error {
+	binary := filepath.Join(root, path.Base(AppBinary))
+
+	if a.Package == "" {
+		if err := copyExecutable(binary, platform); err != nil {
+			return err
+		}
+	} else if err := a.build(binary, platform); err != nil {
+		return err
+	}
+
+	base := a.Base
+	if base == "" {
+		base = DefaultAppBaseImage
+	}
+
+	var dockerfile bytes.Buffer
+
+	dockerfile.WriteString("FROM " + ResolveImage(base) + "\n")
+	dockerfile.WriteString("COPY " + path.Base(AppBinary) + " " + AppBinary + "\n")
+
+	if a.Delve != "" {
+		if err := copyBinary(a.Delve, filepath.Join(root, path.Base(DefaultDelvePath))); err != nil {
+			return errors.Ctx().Str("delve", a.Delve).Wrap(err, "copy delve")
+		}
+
+		dockerfile.WriteString("COPY " + path.Base(DefaultDelvePath) + " " + DefaultDelvePath + "\n")
+	}
+
+	dockerfile.WriteString(`ENTRYPOINT ["` + AppBinary + `"]` + "\n")
+
+	if err := os.WriteFile(filepath.Join(root, defaultDockerfile), dockerfile.Bytes(), 0o644); err != nil {
+		return errors.Wrap(err, "write app dockerfile")
+	}
+
+	return nil
+}
+
+// build - собирает Package статическим бинарем под платформу образа
+func (a AppImage) build(binary, platform string) error {
+	dir := a.Dir
+	if dir == "" {
+		var err error
+
+		if dir, err = moduleRoot(); err != nil {
+			return err
+		}
+	}
+
+	goos, goarch, variant := splitPlatform(platform)
+
+	args := []string{"build", "-o", binary}
+	if a.Delve != "" {
+		args = append(args, "-gcflags=all=-N -l")
+	}
+
+	args = append(args, a.BuildFlags...)
+	args = append(args, a.Package)
+
+	var output bytes.Buffer
+
+	cmd := exec.Command("go", args...) //nolint:gosec
+	cmd.Dir = dir
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	cmd.Env = append(os.Environ(), "CGO_ENABLED=0", "GOOS="+goos, "GOARCH="+goarch)
+
+	if variant != "" && goarch == "arm" {
+		cmd.Env = append(cmd.Env, "GOARM="+strings.TrimPrefix(variant, "v"))
+	}
+
+	if err := cmd.Run(); err != nil {
+		return errors.Ctx().
+			Str("package", a.Package).
+			Str("platform", platform).
+			Str("output", strings.TrimSpace(output.String())).
+			Wrap(err, "go build app")
+	}
+
+	return nil
+}
+
+// tag - тег образа, заданный или сформированный из имени приложения
+func (a AppImage) tag() (string, error) {
+	if a.Tag != "" {
+		return a.Tag, nil
+	}
+
+	name := strings.TrimRight(a.Package, "/.")
+	if a.Package == "" {
+		exe, err := os.Executable()
+		if err != nil {
+			return "", errors.Wrap(err, "get current executable")
+		}
+
+		name = filepath.Base(exe)
+	}
+
+	name = appNameCleaner.ReplaceAllString(strings.ToLower(path.Base(filepath.ToSlash(name))), "-")
+	name = strings.Trim(name, "._-")
+
+	if name == "" {
+		name = "app"
+	}
+
+	return name + ":" + DefaultAppTagVersion, nil
+}
+
+// copyExecutable - копирует текущий исполняемый файл, если он запускается на платформе
+func copyExecutable(dst, platform string) error {
+	goos, goarch, _ := splitPlatform(platform)
+	if runtime.GOOS != "linux" || goos != "linux" || goarch != runtime.GOARCH {
+		return errors.Ctx().
+			Str("host", runtime.GOOS+"/"+runtime.GOARCH).
+			Str("platform", platform).
+			Just(ErrAppPlatform)
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		return errors.Wrap(err, "get current executable")
+	}
+
+	if err = copyBinary(exe, dst); err != nil {
+		return errors.Ctx().Str("executable", exe).Wrap(err, "copy current executable")
+	}
+
+	return nil
+}
+
+// copyBinary - копирует исполняемый файл src в dst
+func copyBinary(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		_ = in.Close()
+	}()
+
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o755)
+	if err != nil {
+		return err
+	}
+
+	if _, err = io.Copy(out, in); err != nil {
+		_ = out.Close()
+
+		return err
+	}
+
+	return out.Close()
+}
+
+// moduleRoot - корень go-модуля текущего каталога
+func moduleRoot() (string, error) {
+	out, err := exec.Command("go", "env", "GOMOD").Output()
+	if err != nil {
+		return "", errors.Wrap(err, "locate go module")
+	}
+
+	gomod := strings.TrimSpace(string(out))
+	if gomod == "" || gomod == os.DevNull {
+		return "", ErrNoGoModule
+	}
+
+	return filepath.Dir(gomod), nil
+}
+
+// splitPlatform - части платформы os/arch[/variant]
+func splitPlatform(platform string) (goos, goarch, variant string) {
+	parts := strings.SplitN(platform, "/", 3)
+	goos = parts[0]
+
+	if len(parts) > 1 {
+		goarch = parts[1]
+	}
+
+	if len(parts) > 2 {
+		variant = parts[2]
+	}
+
+	return goos, goarch, variant
+}
@@ -0,0 +1,202 @@
+package containers
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"gopkg.in/gomisc/errors.v1"
+)
+
+// Настройки каталогов артефактов тестов
+const (
+	// ArtifactsDirEnvar - корневой каталог артефактов, по умолчанию во временном каталоге
+	ArtifactsDirEnvar = "CONTAINERS_ARTIFACTS_DIR"
+	// ArtifactsRetentionEnvar - политика хранения артефактов: on-failure, always, never
+	ArtifactsRetentionEnvar = "CONTAINERS_ARTIFACTS_RETENTION"
+	// ArtifactsContainerPath - путь, по которому каталог артефактов монтируется в контейнер
+	ArtifactsContainerPath = "/artifacts"
+	// ArtifactsPathEnvar - переменная окружения контейнера с путем к каталогу артефактов
+	ArtifactsPathEnvar = "ARTIFACTS_DIR"
+)
+
+// Политики хранения артефактов
+const (
+	RetainOnFailure RetentionPolicy = "on-failure"
+	RetainAlways    RetentionPolicy = "always"
+	RetainNever     RetentionPolicy = "never"
+)
+
+type (
+	// RetentionPolicy - политика хранения артефактов после завершения теста
+	RetentionPolicy string
+
+	// TB - часть testing.TB, которой пользуются помощники артефактов; пакет
+	// не импортирует testing, чтобы не добавлять флаги тестов в бинарники
+	// потребителей. *testing.T и *testing.B ей удовлетворяют
+	TB interface {
+		Helper()
+		Name() string
+		Fatalf(format string, args ...any)
+		Logf(format string, args ...any)
+		Cleanup(f func())
+		Failed() bool
+	}
+)
+
+var artifactDirs sync.Map
+
+// ArtifactDir - возвращает каталог артефактов теста, создавая его при первом
+// обращении. После завершения теста каталог удаляется или сохраняется в
+// соответствии с политикой из CONTAINERS_ARTIFACTS_RETENTION
+func ArtifactDir(t TB) string {
+	t.Helper()
+
+	if dir, ok := artifactDirs.Load(t.Name()); ok {
+		return dir.(string)
+	}
+
+	root := os.Getenv(ArtifactsDirEnvar)
+	if root == "" {
+		root = filepath.Join(os.TempDir(), "containers-artifacts")
+	}
+
+	dir := filepath.Join(root, sanitizeTestName(t.Name()))
+
+	if err := os.MkdirAll(dir, 0o777); err != nil {
+		t.Fatalf("create artifacts dir %s: %v", dir, err)
+	}
+
+	artifactDirs.Store(t.Name(), dir)
+
+	t.Cleanup(
+		func() {
+			artifactDirs.Delete(t.Name())
+
+			if t.Failed() {
+				if err := uploadArtifacts(dir, sanitizeTestName(t.Name())); err != nil {
+					t.Logf("upload artifacts from %s: %v", dir, err)
+				}
+			}
+
+			if retain(t.Failed()) {
+				t.Logf("test artifacts kept in %s", dir)
+
+				return
+			}
+
+			if err := os.RemoveAll(dir); err != nil {
+				t.Logf("remove artifacts dir %s: %v", dir, err)
+			}
+		},
+	)
+
+	return dir
+}
+
+// WithArtifacts - монтирует каталог артефактов теста в контейнер по пути
+// ArtifactsContainerPath и сохраняет туда логи контейнера при падении теста.
+// Процесс в контейнере может писать туда core и heap дампы
+func (c *BaseContainer) WithArtifacts(t TB) *BaseContainer {
+	t.Helper()
+
+	dir := filepath.Join(ArtifactDir(t), sanitizeTestName(c.GetName()))
+
+	if err := os.MkdirAll(dir, 0o777); err != nil {
+		t.Fatalf("create container artifacts dir %s: %v", dir, err)
+	}
+
+	// процесс в контейнере может работать от другого пользователя
+	_ = os.Chmod(dir, 0o777)
+
+	c.Mounts = append(c.Mounts, dir+":"+ArtifactsContainerPath)
+	c.Envs = append(c.Envs, ArtifactsPathEnvar+"="+ArtifactsContainerPath)
+
+	// срок теста ограничивает паузу отладки упавшего контейнера
+	if d, ok := t.(deadliner); ok {
+		c.test = d
+	}
+
+	t.Cleanup(
+		func() {
+			if !t.Failed() {
+				return
+			}
+
+			if err := c.collectLogs(filepath.Join(dir, "container.log")); err != nil {
+				t.Logf("collect %s logs: %v", c.GetName(), err)
+			}
+		},
+	)
+
+	return c
+}
+
+// collectLogs - сохраняет логи контейнера в файл со скрытыми значениями
+// секретов; если логи уже недоступны (например, контейнер удален
+// Autoremove), сохраняется хвост транслированного вывода
+func (c *BaseContainer) collectLogs(path string) error {
+	c.mutex.Lock()
+	tail := c.tail
+	c.mutex.Unlock()
+
+	if c.containerID == "" && tail == nil {
+		return nil
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return errors.Wrap(err, "create log file")
+	}
+
+	defer func() {
+		_ = f.Close()
+	}()
+
+	if c.containerID != "" {
+		out := c.redact(f)
+
+		if err = c.client.StreamLogs(context.Background(), c.containerID, out, out, false); err == nil || tail == nil {
+			return err
+		}
+	}
+
+	data := tail.Bytes()
+
+	if _, err = fmt.Fprintf(f, "--- container logs are unavailable, last %d bytes of output:\n", len(data)); err == nil {
+		_, err = f.Write(data)
+	}
+
+	if err != nil {
+		return errors.Wrap(err, "write log tail")
+	}
+
+	return nil
+}
+
+func retain(failed bool) bool {
+	switch RetentionPolicy(os.Getenv(ArtifactsRetentionEnvar)) {
+	case RetainAlways:
+		return true
+	case RetainNever:
+		return false
+	default:
+		return failed
+	}
+}
+
+func sanitizeTestName(name string) string {
+	return strings.Map(
+		func(r rune) rune {
+			switch r {
+			case '/', '\\', ':', '*', '?', '"', '<', '>', '|', ' ':
+				return '_'
+			default:
+				return r
+			}
+		}, name,
+	)
+}
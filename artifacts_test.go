@@ -0,0 +1,25 @@
+package containers_test
+
+import (
+	"os"
+	"testing"
+
+	"gopkg.in/gomisc/containers.v1"
+)
+
+var _ containers.TB = testing.TB(nil)
+
+func TestArtifactDir(t *testing.T) {
+	t.Setenv(containers.ArtifactsDirEnvar, t.TempDir())
+	t.Setenv(containers.ArtifactsRetentionEnvar, string(containers.RetainNever))
+
+	dir := containers.ArtifactDir(t)
+
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		t.Fatalf("artifacts dir %s is not created: %v", dir, err)
+	}
+
+	if again := containers.ArtifactDir(t); again != dir {
+		t.Fatalf("ArtifactDir: got %s, want %s", again, dir)
+	}
+}
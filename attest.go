@@ -0,0 +1,82 @@
+package containers
+
+import (
+	"encoding/json"
+	"time"
+
+	"gopkg.in/gomisc/errors.v1"
+)
+
+type (
+	// ImageDetails - сведения об образе локального стора
+	ImageDetails struct {
+		ID          string            `json:"id" yaml:"id"`
+		RepoTags    []string          `json:"repo_tags,omitempty" yaml:"repo_tags,omitempty"`
+		RepoDigests []string          `json:"repo_digests,omitempty" yaml:"repo_digests,omitempty"`
+		Created     time.Time         `json:"created" yaml:"created"`
+		Platform    string            `json:"platform,omitempty" yaml:"platform,omitempty"`
+		Size        int64             `json:"size" yaml:"size"`
+		Labels      map[string]string `json:"labels,omitempty" yaml:"labels,omitempty"`
+		// Attestations - аттестации сборки, nil - образ собран без них или
+		// адаптеру они недоступны (docker читает их из реестра по RepoDigests)
+		Attestations *Attestations `json:"attestations,omitempty" yaml:"-"`
+	}
+
+	// Attestations - аттестации BuildKit, запрошенные ImageBuildData.Provenance
+	// и ImageBuildData.SBOM: предикаты in-toto в исходном виде
+	Attestations struct {
+		// Provenance - предикат SLSA provenance, nil - аттестации нет
+		Provenance json.RawMessage `json:"provenance,omitempty"`
+		// SBOM - документ SPDX с пакетами образа, nil - аттестации нет
+		SBOM json.RawMessage `json:"sbom,omitempty"`
+	}
+
+	// SBOMPackage - пакет образа из SBOM
+	SBOMPackage struct {
+		Name    string `json:"name" yaml:"name"`
+		Version string `json:"versionInfo,omitempty" yaml:"version,omitempty"`
+	}
+)
+
+// Packages - пакеты образа, перечисленные в SBOM
+func (a *Attestations) Packages() ([]SBOMPackage, error) {
+	if a == nil || len(a.SBOM) == 0 {
+		return nil, nil
+	}
+
+	var doc struct {
+		Packages []SBOMPackage `json:"packages"`
+	}
+
+	if err := json.Unmarshal(a.SBOM, &doc); err != nil {
+		return nil, errors.Wrap(err, "decode spdx document")
+	}
+
+	return doc.Packages, nil
+}
+
+// Materials - входные материалы сборки из provenance: базовые образы и
+// источники с дайджестами в форме uri
+func (a *Attestations) Materials() ([]string, error) {
+	if a == nil || len(a.Provenance) == 0 {
+		return nil, nil
+	}
+
+	var predicate struct {
+		Materials []struct {
+			URI string `json:"uri"`
+		} `json:"materials"`
+	}
+
+	if err := json.Unmarshal(a.Provenance, &predicate); err != nil {
+		return nil, errors.Wrap(err, "decode slsa provenance")
+	}
+
+	materials := make([]string, 0, len(predicate.Materials))
+
+	for _, m := range predicate.Materials {
+		materials = append(materials, m.URI)
+	}
+
+	return materials, nil
+}
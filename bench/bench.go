@@ -0,0 +1,295 @@
+// Package bench - воспроизводимые замеры производительности слоя оркестрации
+// на реальном демоне: холодный старт контейнера, запуск топологии, пропускная
+// способность логов и выделение подсетей под конкурентной нагрузкой
+package bench
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/netip"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"gopkg.in/gomisc/containers.v1"
+	"gopkg.in/gomisc/errors.v1"
+	"gopkg.in/gomisc/errors.v1/errgroup"
+)
+
+// Параметры замеров по умолчанию
+const (
+	DefaultImage        = "alpine:3.18"
+	DefaultNetwork      = "containers-bench"
+	DefaultIterations   = 5
+	DefaultTopologySize = 10
+	DefaultLogBytes     = 64 << 20
+	DefaultAllocations  = 1000
+	DefaultConcurrency  = 16
+)
+
+type (
+	// Config - параметры набора замеров
+	Config struct {
+		Client       containers.Client
+		Image        string
+		Network      string
+		Iterations   int
+		TopologySize int
+		LogBytes     int64
+		Allocations  int
+		Concurrency  int
+	}
+
+	// Result - результат замера
+	Result struct {
+		Name    string        `json:"name"`
+		Ops     int           `json:"ops"`
+		Elapsed time.Duration `json:"elapsed"`
+		Bytes   int64         `json:"bytes,omitempty"`
+	}
+)
+
+// PerOp - среднее время операции
+func (r Result) PerOp() time.Duration {
+	if r.Ops == 0 {
+		return 0
+	}
+
+	return r.Elapsed / time.Duration(r.Ops)
+}
+
+// String - строка результата в формате, близком к go test -bench
+func (r Result) String() string {
+	s := fmt.Sprintf("%-24s %8d %14s/op", r.Name, r.Ops, r.PerOp())
+
+	if r.Bytes != 0 && r.Elapsed > 0 {
+		s += fmt.Sprintf(" %10.2f MB/s", float64(r.Bytes)/r.Elapsed.Seconds()/(1<<20))
+	}
+
+	return s
+}
+
+func (cfg *Config) withDefaults() {
+	if cfg.Image == "" {
+		cfg.Image = DefaultImage
+	}
+
+	if cfg.Network == "" {
+		cfg.Network = DefaultNetwork
+	}
+
+	if cfg.Iterations <= 0 {
+		cfg.Iterations = DefaultIterations
+	}
+
+	if cfg.TopologySize <= 0 {
+		cfg.TopologySize = DefaultTopologySize
+	}
+
+	if cfg.LogBytes <= 0 {
+		cfg.LogBytes = DefaultLogBytes
+	}
+
+	if cfg.Allocations <= 0 {
+		cfg.Allocations = DefaultAllocations
+	}
+
+	if cfg.Concurrency <= 0 {
+		cfg.Concurrency = DefaultConcurrency
+	}
+}
+
+// Run - выполняет все замеры; образ скачивается заранее и в замеры не входит
+func Run(ctx context.Context, cfg Config) ([]Result, error) {
+	cfg.withDefaults()
+
+	if err := containers.CheckImages(cfg.Client, containers.WithPullImage(cfg.Image)); err != nil {
+		return nil, errors.Wrap(err, "prepare bench image")
+	}
+
+	nw, err := cfg.Client.CheckNetwork(cfg.Network, "")
+	if err != nil {
+		return nil, errors.Ctx().Str("network", cfg.Network).Wrap(err, "check bench network")
+	}
+
+	benches := []func() (Result, error){
+		func() (Result, error) { return ColdStart(ctx, cfg.Client, nw, cfg.Image, cfg.Iterations) },
+		func() (Result, error) { return Topology(ctx, cfg.Client, nw, cfg.Image, cfg.TopologySize) },
+		func() (Result, error) { return LogThroughput(ctx, cfg.Client, nw, cfg.Image, cfg.LogBytes) },
+		func() (Result, error) { return SubnetAllocation(ctx, cfg.Allocations, cfg.Concurrency) },
+	}
+
+	results := make([]Result, 0, len(benches))
+
+	for _, bench := range benches {
+		res, benchErr := bench()
+		if benchErr != nil {
+			return results, benchErr
+		}
+
+		results = append(results, res)
+	}
+
+	return results, nil
+}
+
+// ColdStart - создание, запуск и остановка одиночного контейнера
+func ColdStart(ctx context.Context, cli containers.Client, nw containers.Network, image string, n int) (Result, error) {
+	res := Result{Name: "cold-start", Ops: n}
+
+	for i := 0; i < n; i++ {
+		c := sleeper(cli, nw, image, "cold", i)
+
+		started := time.Now()
+
+		if err := c.Run(ctx); err != nil {
+			return res, errors.Wrap(err, "cold start")
+		}
+
+		res.Elapsed += time.Since(started)
+
+		if err := c.Stop(); err != nil {
+			return res, errors.Wrap(err, "stop cold started container")
+		}
+	}
+
+	return res, nil
+}
+
+// Topology - запуск n однотипных контейнеров через Orchestrator.Scale
+func Topology(ctx context.Context, cli containers.Client, nw containers.Network, image string, n int) (Result, error) {
+	res := Result{Name: "topology-" + strconv.Itoa(n), Ops: 1}
+	orch := containers.NewOrchestrator(cli)
+
+	started := time.Now()
+
+	_, err := orch.Scale(
+		ctx, func(i int) (containers.Container, error) {
+			return sleeper(cli, nw, image, "topology", i), nil
+		}, n,
+	)
+	if err != nil {
+		return res, errors.Wrap(err, "scale topology")
+	}
+
+	res.Elapsed = time.Since(started)
+
+	if err = orch.Down(); err != nil {
+		return res, errors.Wrap(err, "stop topology")
+	}
+
+	return res, nil
+}
+
+// LogThroughput - чтение size байт вывода завершившегося контейнера
+func LogThroughput(ctx context.Context, cli containers.Client, nw containers.Network, image string, size int64) (Result, error) {
+	res := Result{Name: "log-throughput", Ops: 1, Bytes: size}
+
+	c := containers.NewBaseContainer(cli, nw, nil)
+	c.Name = benchName("logs", 0)
+	c.Image = image
+	// пауза перед выводом нужна, чтобы контейнер застали запущенным при старте
+	c.Cmd = []string{"sh", "-c", fmt.Sprintf("sleep 1 && head -c %d /dev/zero | tr '\\0' x", size)}
+
+	if err := c.Run(ctx); err != nil {
+		return res, errors.Wrap(err, "run log producer")
+	}
+
+	defer func() {
+		_ = c.Stop()
+		_ = containers.ExtendClient(cli).ContainerRemove(context.Background(), c.GetID())
+	}()
+
+	statusCh, errCh := cli.ContainerWait(ctx, c.GetID())
+
+	select {
+	case <-statusCh:
+	case err := <-errCh:
+		return res, errors.Wrap(err, "wait log producer")
+	}
+
+	var counter countingWriter
+
+	started := time.Now()
+
+	if err := cli.StreamLogs(ctx, c.GetID(), io.Discard, &counter, false); err != nil {
+		return res, errors.Wrap(err, "read producer logs")
+	}
+
+	res.Elapsed = time.Since(started)
+	res.Bytes = counter.n.Load()
+
+	return res, nil
+}
+
+// SubnetAllocation - выделение и освобождение подсетей конкурентными
+// горутинами, демон не используется
+func SubnetAllocation(ctx context.Context, n, concurrency int) (Result, error) {
+	res := Result{Name: "subnet-allocation", Ops: n}
+
+	used := func(context.Context) ([]netip.Prefix, error) {
+		return nil, nil
+	}
+
+	alloc, err := containers.NewSubnetAllocator(containers.DefaultSubnetPool, used)
+	if err != nil {
+		return res, errors.Wrap(err, "create allocator")
+	}
+
+	var next atomic.Int64
+
+	eg := errgroup.WithCancelOnErr(ctx).WithMaxConcurrency(concurrency)
+	started := time.Now()
+
+	for w := 0; w < concurrency; w++ {
+		eg.Go(
+			func() error {
+				for next.Add(1) <= int64(n) {
+					subnet, allocErr := alloc.Allocate(ctx, containers.DefaultSubnetPrefix)
+					if allocErr != nil {
+						return allocErr
+					}
+
+					alloc.Release(subnet)
+				}
+
+				return nil
+			},
+		)
+	}
+
+	if err = eg.Wait(); err != nil {
+		return res, errors.Wrap(err, "allocate subnets")
+	}
+
+	res.Elapsed = time.Since(started)
+
+	return res, nil
+}
+
+func sleeper(cli containers.Client, nw containers.Network, image, kind string, i int) *containers.BaseContainer {
+	c := containers.NewBaseContainer(cli, nw, nil)
+	c.Name = benchName(kind, i)
+	c.Image = image
+	c.Cmd = []string{"sleep", "600"}
+	c.Autoremove = true
+
+	return c
+}
+
+var benchSeq atomic.Int64
+
+func benchName(kind string, i int) string {
+	return fmt.Sprintf("bench-%s-%d-%d-%d", kind, time.Now().Unix(), benchSeq.Add(1), i)
+}
+
+type countingWriter struct {
+	n atomic.Int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.n.Add(int64(len(p)))
+
+	return len(p), nil
+}
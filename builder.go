@@ -0,0 +1,251 @@
+package containers
+
+import (
+	"context"
+	"net"
+	"strings"
+	"time"
+
+	"gopkg.in/gomisc/containers.v1/wait"
+	"gopkg.in/gomisc/errors.v1"
+	"gopkg.in/gomisc/network.v1/ports"
+)
+
+const (
+	// DefaultFixtureNetwork - сеть, в которой запускаются контейнеры билдера по умолчанию
+	DefaultFixtureNetwork = "containers-fixtures"
+
+	ErrFixtureImageRequired = errors.Const("fixture image is required")
+)
+
+type (
+	// Runner - билдер для быстрого запуска одиночного контейнера, например:
+	//
+	//	redis, err := containers.Run(ctx, cli).
+	//		Image("redis:7").
+	//		Port("redis", 6379).
+	//		WaitForLog("Ready to accept").
+	//		Start()
+	Runner struct {
+		ctx     context.Context
+		cli     Client
+		network string
+		name    string
+		image   string
+		app     *AppImage
+		envs    []string
+		cmd     []string
+		ports   PortBinds
+		waitLog string
+		timeout time.Duration
+		err     error
+	}
+
+	// Fixture - запущенный билдером контейнер
+	Fixture struct {
+		*BaseContainer
+		logs *Logs
+	}
+)
+
+// Run - создает билдер контейнера
+func Run(ctx context.Context, cli Client) *Runner {
+	return &Runner{
+		ctx:     ctx,
+		cli:     cli,
+		network: DefaultFixtureNetwork,
+	}
+}
+
+// Image - задает образ контейнера
+func (r *Runner) Image(image string) *Runner {
+	r.image = image
+
+	return r
+}
+
+// App - запускает образ тестируемого приложения, собранный BuildApp
+// при вызове Start, вместо Image
+func (r *Runner) App(app AppImage) *Runner {
+	r.app = &app
+
+	return r
+}
+
+// Name - задает имя контейнера, по умолчанию формируется из имени образа
+func (r *Runner) Name(name string) *Runner {
+	r.name = name
+
+	return r
+}
+
+// Network - задает имя сети контейнера
+func (r *Runner) Network(name string) *Runner {
+	r.network = name
+
+	return r
+}
+
+// Env - добавляет переменную окружения
+func (r *Runner) Env(key, value string) *Runner {
+	r.envs = append(r.envs, key+"="+value)
+
+	return r
+}
+
+// Cmd - задает команду контейнера
+func (r *Runner) Cmd(cmd ...string) *Runner {
+	r.cmd = cmd
+
+	return r
+}
+
+// Port - публикует tcp порт контейнера на свободный порт хоста
+func (r *Runner) Port(name ports.PortName, port uint16) *Runner {
+	hostPort, err := FreeHostPort()
+	if err != nil {
+		r.err = errors.And(r.err, errors.Ctx().Str("port-name", string(name)).Wrap(err, "get free host port"))
+
+		return r
+	}
+
+	r.ports = append(
+		r.ports, PortBind{
+			Name:      name,
+			Container: NewPort(port, "tcp"),
+			Host:      hostPort,
+		},
+	)
+
+	return r
+}
+
+// WaitForLog - контейнер считается готовым после появления подстроки в его логах
+func (r *Runner) WaitForLog(substr string) *Runner {
+	r.waitLog = substr
+
+	return r
+}
+
+// StartTimeout - задает время ожидания готовности контейнера
+func (r *Runner) StartTimeout(timeout time.Duration) *Runner {
+	r.timeout = timeout
+
+	return r
+}
+
+// Start - скачивает образ при необходимости, создает и запускает контейнер
+// в фоновом режиме, дожидаясь его готовности
+func (r *Runner) Start() (*Fixture, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	if err := r.checkImage(); err != nil {
+		return nil, err
+	}
+
+	nw, err := r.cli.CheckNetwork(r.network, "")
+	if err != nil {
+		return nil, errors.Ctx().Str("network", r.network).Wrap(err, "check fixture network")
+	}
+
+	logs := NewLogs()
+
+	cont := NewBaseContainer(r.cli, nw, nil)
+	cont.Ctx = r.ctx
+	cont.Name = r.name
+	cont.Image = r.image
+	cont.Envs = r.envs
+	cont.Cmd = r.cmd
+	cont.Ports = r.ports
+	cont.StartTimeout = r.timeout
+	cont.OutputStream = logs.Stdout()
+	cont.ErrorStream = logs.Stderr()
+	cont.Background = true
+
+	if cont.Name == "" {
+		cont.Name = fixtureName(r.image)
+	}
+
+	if r.waitLog != "" {
+		cont.Readiness = wait.ForLog(logs.String, r.waitLog)
+	}
+
+	if err = cont.CreateContainer(); err != nil {
+		return nil, errors.Wrap(err, "create fixture")
+	}
+
+	if err = cont.StartContainer(nil, nil); err != nil {
+		return nil, errors.Wrap(err, "start fixture")
+	}
+
+	return &Fixture{BaseContainer: cont, logs: logs}, nil
+}
+
+// checkImage - собирает образ приложения или скачивает образ контейнера
+func (r *Runner) checkImage() error {
+	if r.app != nil {
+		image, err := BuildApp(r.cli, *r.app)
+		if err != nil {
+			return errors.Wrap(err, "build fixture app")
+		}
+
+		r.image = image
+
+		return nil
+	}
+
+	if r.image == "" {
+		return ErrFixtureImageRequired
+	}
+
+	if err := CheckImages(r.cli, WithPullImage(r.image)); err != nil {
+		return errors.Ctx().Str("image", r.image).Wrap(err, "check fixture image")
+	}
+
+	return nil
+}
+
+// Addr - возвращает адрес порта контейнера на хосте
+func (f *Fixture) Addr(name ports.PortName) string {
+	return f.HostAddrs()[name]
+}
+
+// Logs - возвращает накопленный вывод контейнера
+func (f *Fixture) Logs() string {
+	return f.logs.String()
+}
+
+// Captured - построчный захват вывода контейнера для проверок логов
+func (f *Fixture) Captured() *Logs {
+	return f.logs
+}
+
+func fixtureName(image string) string {
+	name := image
+
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		name = name[i+1:]
+	}
+
+	if i := strings.IndexAny(name, ":@"); i >= 0 {
+		name = name[:i]
+	}
+
+	return name + "-" + strings.ReplaceAll(time.Now().Format("150405.000000"), ".", "")
+}
+
+// FreeHostPort - возвращает свободный tcp порт хоста
+func FreeHostPort() (uint16, error) {
+	l, err := net.Listen("tcp", ":0")
+	if err != nil {
+		return 0, errors.Wrap(err, "listen free port")
+	}
+
+	defer func() {
+		_ = l.Close()
+	}()
+
+	return uint16(l.Addr().(*net.TCPAddr).Port), nil
+}
@@ -0,0 +1,144 @@
+package containers
+
+import (
+	"context"
+	"io"
+	"time"
+)
+
+var _ ClientV2 = (*clientV2)(nil)
+
+type (
+	// ClientV2 - интерфейс клиента среды исполнения, в котором каждая операция
+	// принимает структуру опций: новые возможности добавляются полями опций
+	// без изменения сигнатур и поломки существующих адаптеров
+	ClientV2 interface {
+		// Create создает контейнер
+		Create(ctx context.Context, opts CreateOptions) (string, error)
+		// Start запускает контейнер
+		Start(ctx context.Context, opts StartOptions) (*ContainerInfo, error)
+		// Stop останавливает контейнер
+		Stop(ctx context.Context, opts StopOptions) error
+		// Remove удаляет контейнер
+		Remove(ctx context.Context, opts RemoveOptions) error
+		// Exec выполняет команду в контейнере и возвращает код ее завершения
+		Exec(ctx context.Context, opts ExecOptions) (int, error)
+		// Logs транслирует логи контейнера
+		Logs(ctx context.Context, opts LogsOptions) error
+		// Pull скачивает образ
+		Pull(ctx context.Context, opts PullOptions) error
+		// Build собирает образ
+		Build(ctx context.Context, opts BuildOptions) error
+		// Network проверяет существование сети и создает ее в случае отсутствия
+		Network(ctx context.Context, opts NetworkOptions) (Network, error)
+	}
+
+	// CreateOptions - опции создания контейнера
+	CreateOptions struct {
+		Container Container
+	}
+
+	// StartOptions - опции запуска контейнера
+	StartOptions struct {
+		ID   string
+		Name string
+	}
+
+	// StopOptions - опции остановки контейнера
+	StopOptions struct {
+		ID      string
+		Timeout time.Duration
+	}
+
+	// RemoveOptions - опции удаления контейнера
+	RemoveOptions struct {
+		ID string
+	}
+
+	// ExecOptions - опции выполнения команды в контейнере
+	ExecOptions struct {
+		ID     string
+		Cmd    []string
+		Stdout io.Writer
+		Stderr io.Writer
+	}
+
+	// LogsOptions - опции трансляции логов контейнера
+	LogsOptions struct {
+		ID     string
+		Stdout io.Writer
+		Stderr io.Writer
+		Follow bool
+	}
+
+	// PullOptions - опции скачивания образа
+	PullOptions struct {
+		Image string
+		Auth  RegistryAuth
+		// Platform - платформа образа в форме os/arch[/variant], пусто - платформа демона
+		Platform string
+	}
+
+	// BuildOptions - опции сборки образа
+	BuildOptions struct {
+		Data *ImageBuildData
+	}
+
+	// NetworkOptions - опции проверки сети
+	NetworkOptions struct {
+		Name string
+		// CIDR - подсеть сети, подсети IPv4 и IPv6 сети с двумя стеками
+		// адресов перечисляются через запятую
+		CIDR string
+	}
+
+	clientV2 struct {
+		cli Client
+	}
+)
+
+// V2 - возвращает клиента с интерфейсом ClientV2 поверх клиента Client.
+// Если адаптер сам реализует ClientV2, он возвращается как есть
+func V2(cli Client) ClientV2 {
+	if v2, ok := cli.(ClientV2); ok {
+		return v2
+	}
+
+	return &clientV2{cli: cli}
+}
+
+func (c *clientV2) Create(ctx context.Context, opts CreateOptions) (string, error) {
+	return c.cli.ContainerCreate(ctx, opts.Container)
+}
+
+func (c *clientV2) Start(ctx context.Context, opts StartOptions) (*ContainerInfo, error) {
+	return c.cli.ContainerStart(ctx, opts.ID, opts.Name)
+}
+
+func (c *clientV2) Stop(ctx context.Context, opts StopOptions) error {
+	return c.cli.ContainerStop(ctx, opts.ID, opts.Timeout)
+}
+
+func (c *clientV2) Remove(ctx context.Context, opts RemoveOptions) error {
+	return ExtendClient(c.cli).ContainerRemove(ctx, opts.ID)
+}
+
+func (c *clientV2) Exec(ctx context.Context, opts ExecOptions) (int, error) {
+	return ExtendClient(c.cli).ContainerExec(ctx, opts.ID, opts.Cmd, opts.Stdout, opts.Stderr)
+}
+
+func (c *clientV2) Logs(ctx context.Context, opts LogsOptions) error {
+	return c.cli.StreamLogs(ctx, opts.ID, opts.Stderr, opts.Stdout, opts.Follow)
+}
+
+func (c *clientV2) Pull(ctx context.Context, opts PullOptions) error {
+	return ExtendClient(c.cli).PullImageWith(ctx, opts)
+}
+
+func (c *clientV2) Build(_ context.Context, opts BuildOptions) error {
+	return c.cli.BuildImage(opts.Data)
+}
+
+func (c *clientV2) Network(_ context.Context, opts NetworkOptions) (Network, error) {
+	return c.cli.CheckNetwork(opts.Name, opts.CIDR)
+}
@@ -0,0 +1,240 @@
+package containers
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"gopkg.in/gomisc/errors.v1"
+)
+
+const (
+	// FaketimeLibEnvar - путь к libfaketime.so.1 на хосте, если FakeClock.Library не задан
+	FaketimeLibEnvar = "CONTAINERS_FAKETIME_LIB"
+
+	// FaketimeDir - каталог контейнера с библиотекой и файлом смещения часов
+	FaketimeDir = "/run/faketime"
+
+	faketimeLib  = "libfaketime.so.1"
+	faketimeFile = "offset"
+
+	ErrClockNotFaked    = errors.Const("container clock is not faked")
+	ErrFaketimeNotFound = errors.Const("libfaketime library not found")
+)
+
+// faketimeSearchPath - типичные пути libfaketime в дистрибутивах
+var faketimeSearchPath = []string{
+	"/usr/lib/x86_64-linux-gnu/faketime/libfaketime.so.1",
+	"/usr/lib/aarch64-linux-gnu/faketime/libfaketime.so.1",
+	"/usr/lib/faketime/libfaketime.so.1",
+	"/usr/lib64/faketime/libfaketime.so.1",
+	"/usr/local/lib/faketime/libfaketime.so.1",
+}
+
+// FakeClock - подмена часов процессов контейнера через libfaketime: библиотека
+// хоста подгружается через LD_PRELOAD и читает смещение из файла, который
+// меняет ShiftClock. Библиотека должна быть собрана под libc образа (glibc
+// образы с glibc хоста, для musl нужна своя сборка)
+type FakeClock struct {
+	// Library - путь к libfaketime.so.1 на хосте, пусто - FaketimeLibEnvar
+	// или поиск в типичных путях
+	Library string `json:"library,omitempty" yaml:"library,omitempty"`
+	// Offset - начальное смещение часов
+	Offset time.Duration `json:"offset,omitempty" yaml:"offset,omitempty"`
+
+	mu     sync.Mutex
+	dir    string
+	offset time.Duration
+}
+
+// ShiftClock - сдвигает часы процессов контейнера на d (отрицательное - назад).
+// Сдвиг применяется без перезапуска: libfaketime перечитывает файл смещения
+// при каждом запросе времени
+func (c *BaseContainer) ShiftClock(d time.Duration) error {
+	if c.FakeClock == nil || c.FakeClock.directory() == "" {
+		return errors.Ctx().Str("container-name", c.GetName()).Just(ErrClockNotFaked)
+	}
+
+	return c.FakeClock.shift(d)
+}
+
+// ClockOffset - текущее смещение часов контейнера
+func (c *BaseContainer) ClockOffset() time.Duration {
+	if c.FakeClock == nil {
+		return 0
+	}
+
+	c.FakeClock.mu.Lock()
+	defer c.FakeClock.mu.Unlock()
+
+	return c.FakeClock.offset
+}
+
+// mountClock - готовит каталог с библиотекой и файлом смещения и подключает
+// его к контейнеру вместе с переменными окружения libfaketime
+func (c *BaseContainer) mountClock() error {
+	if c.FakeClock == nil || c.FakeClock.directory() != "" {
+		return nil
+	}
+
+	lib, err := c.FakeClock.library()
+	if err != nil {
+		return errors.Ctx().Str("container-name", c.GetName()).Wrap(err, "find libfaketime")
+	}
+
+	dir, err := os.MkdirTemp("", "containers-clock-")
+	if err != nil {
+		return errors.Ctx().Str("container-name", c.GetName()).Wrap(err, "create clock dir")
+	}
+
+	// каталог читается процессом контейнера под произвольным пользователем
+	if err = os.Chmod(dir, 0o755); err != nil { //nolint:gosec
+		_ = os.RemoveAll(dir)
+
+		return errors.Ctx().Str("dir", dir).Wrap(err, "chmod clock dir")
+	}
+
+	if err = copyLibrary(lib, filepath.Join(dir, faketimeLib)); err != nil {
+		_ = os.RemoveAll(dir)
+
+		return errors.Ctx().Str("library", lib).Wrap(err, "copy libfaketime")
+	}
+
+	c.FakeClock.mu.Lock()
+	c.FakeClock.dir = dir
+	c.FakeClock.offset = 0
+	c.FakeClock.mu.Unlock()
+
+	if err = c.FakeClock.shift(c.FakeClock.Offset); err != nil {
+		c.removeClock()
+
+		return err
+	}
+
+	c.Mounts = append(c.Mounts, dir+":"+FaketimeDir+":ro")
+	c.Envs = append(c.Envs, clockEnvs()...)
+
+	return nil
+}
+
+// removeClock - удаляет каталог часов с хоста
+func (c *BaseContainer) removeClock() {
+	if c.FakeClock == nil {
+		return
+	}
+
+	c.FakeClock.mu.Lock()
+	dir := c.FakeClock.dir
+	c.FakeClock.dir = ""
+	c.FakeClock.mu.Unlock()
+
+	if dir == "" {
+		return
+	}
+
+	c.Mounts = removeString(c.Mounts, dir+":"+FaketimeDir+":ro")
+
+	for _, env := range clockEnvs() {
+		c.Envs = removeString(c.Envs, env)
+	}
+
+	if err := os.RemoveAll(dir); err != nil {
+		c.LogError(err, "remove clock dir")
+	}
+}
+
+func clockEnvs() []string {
+	return []string{
+		"LD_PRELOAD=" + FaketimeDir + "/" + faketimeLib,
+		"FAKETIME_TIMESTAMP_FILE=" + FaketimeDir + "/" + faketimeFile,
+		"FAKETIME_NO_CACHE=1",
+	}
+}
+
+func copyLibrary(src, dst string) error {
+	f, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o755) //nolint:gosec
+	if err != nil {
+		return errors.Wrap(err, "create library file")
+	}
+
+	err = copyFile(f, src)
+	if closeErr := f.Close(); err == nil && closeErr != nil {
+		err = errors.Wrap(closeErr, "close library file")
+	}
+
+	return err
+}
+
+func (f *FakeClock) directory() string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.dir
+}
+
+func (f *FakeClock) library() (string, error) {
+	candidates := faketimeSearchPath
+
+	if f.Library != "" {
+		candidates = []string{f.Library}
+	} else if lib := os.Getenv(FaketimeLibEnvar); lib != "" {
+		candidates = []string{lib}
+	}
+
+	for _, path := range candidates {
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			return path, nil
+		}
+	}
+
+	return "", errors.Ctx().Strings("paths", candidates).Just(ErrFaketimeNotFound)
+}
+
+// shift - увеличивает смещение и атомарно перезаписывает файл смещения,
+// чтобы libfaketime не прочитал его частично
+func (f *FakeClock) shift(d time.Duration) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	offset := f.offset + d
+
+	tmp, err := os.CreateTemp(f.dir, faketimeFile+"-")
+	if err != nil {
+		return errors.Wrap(err, "create clock offset file")
+	}
+
+	_, err = tmp.WriteString(faketimeOffset(offset) + "\n")
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err == nil {
+		err = os.Chmod(tmp.Name(), 0o644) //nolint:gosec
+	}
+
+	if err == nil {
+		err = os.Rename(tmp.Name(), filepath.Join(f.dir, faketimeFile))
+	}
+
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+
+		return errors.Wrap(err, "write clock offset")
+	}
+
+	f.offset = offset
+
+	return nil
+}
+
+// faketimeOffset - относительное смещение в формате libfaketime ("+90", "-1.5")
+func faketimeOffset(d time.Duration) string {
+	s := strconv.FormatFloat(d.Seconds(), 'f', -1, 64)
+	if d >= 0 {
+		s = "+" + s
+	}
+
+	return s
+}
@@ -0,0 +1,57 @@
+// containers-bench - выполняет замеры производительности оркестрации на
+// реальном демоне и выводит результаты (-json для машиночитаемого вывода)
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"gopkg.in/gomisc/containers.v1/adapters/docker"
+	"gopkg.in/gomisc/containers.v1/bench"
+)
+
+func main() {
+	asJSON := flag.Bool("json", false, "print results as JSON")
+	timeout := flag.Duration("timeout", 10*time.Minute, "benchmarks timeout")
+	image := flag.String("image", bench.DefaultImage, "benchmark container image")
+	iterations := flag.Int("n", bench.DefaultIterations, "cold start iterations")
+	topology := flag.Int("topology", bench.DefaultTopologySize, "topology size")
+	flag.Parse()
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	cli, err := docker.New()
+	if err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	results, err := bench.Run(
+		ctx, bench.Config{
+			Client:       cli,
+			Image:        *image,
+			Iterations:   *iterations,
+			TopologySize: *topology,
+		},
+	)
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		_ = enc.Encode(results)
+	} else {
+		for _, res := range results {
+			_, _ = fmt.Fprintln(os.Stdout, res)
+		}
+	}
+
+	if err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,50 @@
+// containers-doctor - проверяет окружение на типичные проблемы запуска
+// интеграционных тестов и выводит отчет (-json для машиночитаемого вывода)
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"os"
+	"time"
+
+	"gopkg.in/gomisc/containers.v1"
+	"gopkg.in/gomisc/containers.v1/adapters/docker"
+)
+
+func main() {
+	asJSON := flag.Bool("json", false, "print report as JSON")
+	timeout := flag.Duration("timeout", 30*time.Second, "checks timeout")
+	flag.Parse()
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	report := &containers.DoctorReport{}
+
+	cli, err := docker.New()
+	if err != nil {
+		report.Checks = append(
+			report.Checks, containers.DoctorCheck{
+				Name:    "daemon reachable",
+				Status:  containers.CheckFail,
+				Message: err.Error(),
+			},
+		)
+	} else {
+		report = containers.Doctor(ctx, cli)
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		_ = enc.Encode(report)
+	} else {
+		report.Print(os.Stdout)
+	}
+
+	if !report.OK() {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,81 @@
+package containers
+
+import (
+	"context"
+
+	"gopkg.in/gomisc/errors.v1"
+)
+
+type (
+	// CommitOption - опция сохранения контейнера в образ
+	CommitOption func(o *CommitOptions)
+
+	// CommitOptions - параметры сохранения контейнера в образ
+	CommitOptions struct {
+		// Message - комментарий образа
+		Message string
+		// Author - автор образа
+		Author string
+		// Changes - инструкции Dockerfile (CMD, ENV, LABEL, etc), применяемые
+		// к конфигурации образа
+		Changes []string
+		// NoPause - не приостанавливать контейнер на время сохранения
+		NoPause bool
+	}
+)
+
+// WithCommitMessage - задает комментарий образа
+func WithCommitMessage(msg string) CommitOption {
+	return func(o *CommitOptions) {
+		o.Message = msg
+	}
+}
+
+// WithCommitAuthor - задает автора образа
+func WithCommitAuthor(author string) CommitOption {
+	return func(o *CommitOptions) {
+		o.Author = author
+	}
+}
+
+// WithCommitChanges - добавляет инструкции Dockerfile, например "ENV SEEDED=1"
+func WithCommitChanges(changes ...string) CommitOption {
+	return func(o *CommitOptions) {
+		o.Changes = append(o.Changes, changes...)
+	}
+}
+
+// WithoutCommitPause - сохраняет контейнер без приостановки процессов; быстрее,
+// но запись в файловую систему во время сохранения может попасть в образ частично
+func WithoutCommitPause() CommitOption {
+	return func(o *CommitOptions) {
+		o.NoPause = true
+	}
+}
+
+// NewCommitOptions - собирает параметры сохранения из опций, используется адаптерами
+func NewCommitOptions(opts ...CommitOption) CommitOptions {
+	var o CommitOptions
+
+	for _, apply := range opts {
+		apply(&o)
+	}
+
+	return o
+}
+
+// Commit - сохраняет текущее состояние файловой системы контейнера в образ
+// с тегом tag, возвращает идентификатор образа. Данные разделов (VOLUME)
+// в образ не попадают
+func (c *BaseContainer) Commit(ctx context.Context, tag string, opts ...CommitOption) (string, error) {
+	if c.containerID == "" {
+		return "", errors.Ctx().Str("container-name", c.GetName()).Just(ErrContainerNotRunning)
+	}
+
+	id, err := c.runtime().ContainerCommit(ctx, c.containerID, tag, opts...)
+	if err != nil {
+		return "", errors.Ctx().Str("container-name", c.GetName()).Wrap(err, "commit container")
+	}
+
+	return id, nil
+}
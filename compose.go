@@ -0,0 +1,391 @@
+package containers
+
+import (
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/gomisc/errors.v1"
+)
+
+// composeVersion - версия формата docker-compose файла
+const composeVersion = "3.8"
+
+// ExportCompose - записывает топологию оркестратора в формате docker-compose v3:
+// образы, команды, переменные окружения, порты, разделы, тома, sysctls,
+// проверки здоровья, ограничения ресурсов и сети.
+// Проверки готовности задаются в Go кодом и в файл не переносятся
+func (o *Orchestrator) ExportCompose(w io.Writer) error {
+	y := newYAMLWriter(w)
+	networks := make(map[string]Network)
+	// addressed - сети с фиксированными адресами контейнеров: compose
+	// принимает ipv4_address только в сети с заданной подсетью
+	addressed := make(map[string]struct{})
+	volumes := make(map[string]VolumeSpec)
+
+	y.value(0, "version", composeVersion)
+	y.key(0, "services")
+
+	for _, m := range o.Members() {
+		c := ExtendContainer(m.Container)
+
+		// процессы хоста в compose не переносятся
+		if c.GetImage() == "" {
+			continue
+		}
+
+		y.key(1, serviceName(c.GetName()))
+		y.value(2, "image", c.GetImage())
+		y.value(2, "container_name", c.GetName())
+
+		if platform := c.GetPlatform(); platform != "" {
+			y.value(2, "platform", platform)
+		}
+
+		if runtime := c.GetRuntime(); runtime != "" {
+			y.value(2, "runtime", runtime)
+		}
+
+		if entrypoint := c.GetEntryPoint(); entrypoint != "" {
+			y.list(2, "entrypoint", strings.Fields(entrypoint))
+		}
+
+		if user := c.GetUser(); user != "" {
+			y.value(2, "user", user)
+		}
+
+		if workDir := c.GetWorkDir(); workDir != "" {
+			y.value(2, "working_dir", workDir)
+		}
+
+		y.list(2, "profiles", m.Profiles)
+		y.list(2, "command", c.GetCmd())
+		y.list(2, "environment", c.GetEnvs())
+		y.list(2, "ports", composePorts(c.PortMap()))
+		mounts, tmpfs := []string(nil), composeTmpfs(c.GetTmpfs())
+
+		for _, m := range c.GetMountSpecs() {
+			if m.MountType() == MountTmpfs {
+				tmpfs = append(tmpfs, m.String())
+
+				continue
+			}
+
+			mounts = append(mounts, m.String())
+
+			if _, ok := volumes[m.Source]; !ok && m.MountType() == MountVolume && m.Source != "" {
+				volumes[m.Source] = VolumeSpec{Name: m.Source, Driver: m.VolumeDriver}
+			}
+		}
+
+		for _, v := range c.GetNamedVolumes() {
+			mounts = append(mounts, v.String())
+
+			if _, ok := volumes[v.Name]; !ok {
+				volumes[v.Name] = v
+			}
+		}
+
+		y.list(2, "volumes", mounts)
+		y.list(2, "tmpfs", tmpfs)
+		y.list(2, "extra_hosts", c.GetExtraHosts())
+		y.list(2, "dns", c.GetDNS())
+		y.list(2, "dns_search", c.GetDNSSearch())
+		y.list(2, "devices", composeDevices(c.GetDevices()))
+
+		if sysctls := c.GetSysctls(); len(sysctls) != 0 {
+			y.key(2, "sysctls")
+
+			for _, k := range sortedKeys(sysctls) {
+				y.value(3, k, sysctls[k])
+			}
+		}
+
+		if labels := c.GetLabels(); len(labels) != 0 {
+			y.key(2, "labels")
+
+			for _, k := range sortedKeys(labels) {
+				y.value(3, k, labels[k])
+			}
+		}
+
+		composeHealthcheck(y, c.GetHealthcheck())
+		composeResources(y, c.GetResources(), c.GetGPUs())
+
+		var attachments []NetworkAttachment
+
+		if nw := c.GetNetwork(); nw != nil && nw.Name() != "" {
+			attachments = append(
+				attachments, NetworkAttachment{Network: nw, Aliases: c.GetAliases(), IP: c.GetContainerIP()},
+			)
+		}
+
+		for _, att := range c.GetExtraNetworks() {
+			if att.Network != nil && att.Network.Name() != "" {
+				attachments = append(attachments, att)
+			}
+		}
+
+		if len(attachments) != 0 {
+			y.key(2, "networks")
+		}
+
+		// короткая форма списка возможна, только если ни в одной сети
+		// не заданы адрес и имена
+		short := true
+
+		for _, att := range attachments {
+			networks[att.Network.Name()] = att.Network
+			short = short && att.IP == "" && len(att.Aliases) == 0
+
+			if att.IP != "" {
+				addressed[att.Network.Name()] = struct{}{}
+			}
+		}
+
+		for _, att := range attachments {
+			if short {
+				y.line(3, "- "+quote(att.Network.Name()))
+
+				continue
+			}
+
+			y.key(3, att.Network.Name())
+
+			if att.IP != "" {
+				y.value(4, "ipv4_address", att.IP)
+			}
+
+			y.list(4, "aliases", att.Aliases)
+		}
+	}
+
+	if len(networks) != 0 {
+		y.key(0, "networks")
+
+		for _, name := range sortedKeys(networks) {
+			y.key(1, name)
+			y.value(2, "name", name)
+
+			if _, ok := addressed[name]; !ok {
+				continue
+			}
+
+			if nw, ok := networks[name].(subnetNetwork); ok && nw.Subnet() != nil {
+				y.key(2, "ipam")
+				y.key(3, "config")
+				y.line(4, "- subnet: "+quote(nw.Subnet().String()))
+			}
+		}
+	}
+
+	if len(volumes) != 0 {
+		y.key(0, "volumes")
+
+		for _, name := range sortedKeys(volumes) {
+			v := volumes[name]
+
+			y.key(1, name)
+			y.value(2, "name", name)
+
+			if v.Driver != "" {
+				y.value(2, "driver", v.Driver)
+			}
+
+			if len(v.Labels) != 0 {
+				y.key(2, "labels")
+
+				for _, k := range sortedKeys(v.Labels) {
+					y.value(3, k, v.Labels[k])
+				}
+			}
+		}
+	}
+
+	if err := y.flush(); err != nil {
+		return errors.Wrap(err, "write compose file")
+	}
+
+	return nil
+}
+
+// composeTmpfs - разделы в памяти в форме path[:options]
+func composeTmpfs(tmpfs map[string]string) []string {
+	result := make([]string, 0, len(tmpfs))
+
+	for _, target := range sortedKeys(tmpfs) {
+		if options := tmpfs[target]; options != "" {
+			target += ":" + options
+		}
+
+		result = append(result, target)
+	}
+
+	return result
+}
+
+func composePorts(pm PortMap) []string {
+	result := make([]string, 0, len(pm))
+
+	for port, binds := range pm {
+		target := port.Port() + "/" + port.Proto()
+
+		if len(binds) == 0 || binds[0].HostPort == "" || binds[0].HostPort == "0" {
+			result = append(result, target)
+
+			continue
+		}
+
+		result = append(result, binds[0].HostPort+":"+target)
+	}
+
+	sort.Strings(result)
+
+	return result
+}
+
+// serviceName - имя сервиса в допустимом для compose виде
+func serviceName(name string) string {
+	return strings.Map(
+		func(r rune) rune {
+			switch {
+			case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '-', r == '_':
+				return r
+			case r >= 'A' && r <= 'Z':
+				return r + 'a' - 'A'
+			default:
+				return '-'
+			}
+		}, name,
+	)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+
+	for k := range m {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
+
+// composeHealthcheck - секция healthcheck сервиса, nil - проверка образа
+func composeHealthcheck(y *yamlWriter, hc *Healthcheck) {
+	if hc == nil {
+		return
+	}
+
+	y.key(2, "healthcheck")
+
+	if hc.Disabled() {
+		y.line(3, "disable: true")
+
+		return
+	}
+
+	y.list(3, "test", hc.Test)
+
+	for _, d := range []struct {
+		key   string
+		value time.Duration
+	}{
+		{"interval", hc.Interval},
+		{"timeout", hc.Timeout},
+		{"start_period", hc.StartPeriod},
+	} {
+		if d.value > 0 {
+			y.value(3, d.key, d.value.String())
+		}
+	}
+
+	if hc.Retries > 0 {
+		y.line(3, "retries: "+strconv.Itoa(hc.Retries))
+	}
+}
+
+// composeResources - ограничения ресурсов сервиса: лимиты в deploy.resources,
+// swap и число процессов - ключами сервиса
+func composeResources(y *yamlWriter, r Resources, gpus []GPURequest) {
+	if r.IsZero() && len(gpus) == 0 {
+		return
+	}
+
+	if r.MemorySwap != 0 {
+		y.value(2, "memswap_limit", strconv.FormatInt(r.MemorySwap, 10))
+	}
+
+	if r.PidsLimit != 0 {
+		y.line(2, "pids_limit: "+strconv.FormatInt(r.PidsLimit, 10))
+	}
+
+	if r.MemoryLimit == 0 && r.CPUs == 0 && r.MemoryReservation == 0 && len(gpus) == 0 {
+		return
+	}
+
+	y.key(2, "deploy")
+	y.key(3, "resources")
+
+	if r.MemoryLimit != 0 || r.CPUs != 0 {
+		y.key(4, "limits")
+
+		if r.CPUs != 0 {
+			y.value(5, "cpus", strconv.FormatFloat(r.CPUs, 'f', -1, 64))
+		}
+
+		if r.MemoryLimit != 0 {
+			y.value(5, "memory", strconv.FormatInt(r.MemoryLimit, 10))
+		}
+	}
+
+	if r.MemoryReservation == 0 && len(gpus) == 0 {
+		return
+	}
+
+	y.key(4, "reservations")
+
+	if r.MemoryReservation != 0 {
+		y.value(5, "memory", strconv.FormatInt(r.MemoryReservation, 10))
+	}
+
+	if len(gpus) != 0 {
+		y.key(5, "devices")
+	}
+
+	for _, gpu := range gpus {
+		// capabilities обязательны, поэтому открывают элемент списка
+		y.line(6, "- capabilities:")
+
+		for _, capability := range gpu.Caps() {
+			y.line(8, "- "+quote(capability))
+		}
+
+		if gpu.Driver != "" {
+			y.value(7, "driver", gpu.Driver)
+		}
+
+		switch {
+		case gpu.Count == AllGPUs:
+			y.line(7, "count: all")
+		case gpu.Count != 0:
+			y.line(7, "count: "+strconv.Itoa(gpu.Count))
+		}
+
+		y.list(7, "device_ids", gpu.DeviceIDs)
+	}
+}
+
+// composeDevices - устройства в форме host:container:permissions
+func composeDevices(devices []Device) []string {
+	result := make([]string, 0, len(devices))
+
+	for _, d := range devices {
+		result = append(result, d.String())
+	}
+
+	return result
+}
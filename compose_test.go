@@ -0,0 +1,59 @@
+package containers_test
+
+import (
+	"bytes"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+
+	"gopkg.in/gomisc/containers.v1"
+)
+
+func TestExportComposeKeys(t *testing.T) {
+	cli, c := newTestContainer(t, "compose-keys")
+
+	labels := map[string]string{
+		"com.example/team": "storage",
+		"a:b":              "colon",
+		"#comment":         "hash",
+		"-dash":            "dash",
+		"&anchor":          "anchor",
+		"true":             "bool",
+		"with space":       "space",
+	}
+	c.Labels = labels
+
+	o := containers.NewOrchestrator(cli)
+
+	if err := o.Add(c); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+
+	var buf bytes.Buffer
+
+	if err := o.ExportCompose(&buf); err != nil {
+		t.Fatalf("ExportCompose: %v", err)
+	}
+
+	var doc struct {
+		Services map[string]struct {
+			Labels map[string]string `yaml:"labels"`
+		} `yaml:"services"`
+	}
+
+	if err := yaml.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatalf("Unmarshal: %v\n%s", err, buf.String())
+	}
+
+	if len(doc.Services) != 1 {
+		t.Fatalf("services: got %d, want 1\n%s", len(doc.Services), buf.String())
+	}
+
+	for _, svc := range doc.Services {
+		for k, v := range labels {
+			if svc.Labels[k] != v {
+				t.Fatalf("label %q: got %q, want %q\n%s", k, svc.Labels[k], v, buf.String())
+			}
+		}
+	}
+}
@@ -0,0 +1,210 @@
+package containers
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"os"
+	"path"
+	"text/template"
+	"time"
+
+	envs "gopkg.in/gomisc/envs.v1"
+	"gopkg.in/gomisc/errors.v1"
+)
+
+// ErrConfigPath - путь файла конфигурации в контейнере не абсолютный
+const ErrConfigPath = errors.Const("config path must be absolute")
+
+// ConfigProvider - источник конфигурации контейнера. Prepare вызывается до
+// создания контейнера и может дополнить его спецификацию (переменные окружения,
+// монтирования), Deliver - после создания и до запуска, когда адреса уже
+// запущенных зависимостей известны и файлы можно записать в контейнер
+type ConfigProvider interface {
+	Prepare(ctx context.Context, c *BaseContainer) error
+	Deliver(ctx context.Context, c *BaseContainer) error
+}
+
+// ConfigData - функция данных для шаблонов конфигурации, вызывается
+// непосредственно перед отрисовкой; по умолчанию данными служит сам контейнер
+type ConfigData func(c *BaseContainer) any
+
+type (
+	// FileConfig - файл конфигурации Path в контейнере, отрисованный
+	// из шаблона text/template; каталог файла должен существовать в образе
+	FileConfig struct {
+		Path     string
+		Template string
+		Mode     os.FileMode
+		Data     ConfigData
+	}
+
+	// EnvConfig - переменные окружения контейнера, значения которых - шаблоны
+	// text/template
+	EnvConfig struct {
+		Vars map[string]string
+		Data ConfigData
+	}
+
+	// configs - последовательное применение нескольких источников
+	configs []ConfigProvider
+
+	// envsController - адаптер контроллера конфигурации envs
+	envsController struct {
+		ctl    envs.Controller
+		prefix string
+	}
+)
+
+// Configs - объединяет источники конфигурации, они применяются по порядку
+func Configs(providers ...ConfigProvider) ConfigProvider {
+	return configs(providers)
+}
+
+// EnvsController - источник конфигурации, передающий контейнеру переменные
+// окружения контроллера envs (для непустого prefix - переменные префикса)
+func EnvsController(ctl envs.Controller, prefix string) ConfigProvider {
+	return &envsController{ctl: ctl, prefix: prefix}
+}
+
+// Prepare - файл записывается после создания контейнера
+func (f *FileConfig) Prepare(context.Context, *BaseContainer) error {
+	if !path.IsAbs(f.Path) {
+		return errors.Ctx().Str("path", f.Path).Just(ErrConfigPath)
+	}
+
+	return nil
+}
+
+// Deliver - отрисовывает шаблон и копирует файл в контейнер
+func (f *FileConfig) Deliver(ctx context.Context, c *BaseContainer) error {
+	content, err := render(f.Path, f.Template, f.Data, c)
+	if err != nil {
+		return err
+	}
+
+	mode := f.Mode
+	if mode == 0 {
+		mode = 0o644
+	}
+
+	dir, name := path.Split(path.Clean(f.Path))
+
+	archive, err := fileArchive(name, content, mode)
+	if err != nil {
+		return err
+	}
+
+	if err = c.runtime().CopyToContainer(ctx, c.GetID(), dir, archive); err != nil {
+		return errors.Ctx().Str("name", c.GetName()).Str("path", f.Path).Wrap(err, "deliver config file")
+	}
+
+	return nil
+}
+
+// Prepare - отрисовывает значения и добавляет переменные в окружение контейнера
+func (e *EnvConfig) Prepare(_ context.Context, c *BaseContainer) error {
+	for _, key := range sortedKeys(e.Vars) {
+		value, err := render(key, e.Vars[key], e.Data, c)
+		if err != nil {
+			return err
+		}
+
+		c.Envs = append(c.Envs, key+"="+string(value))
+	}
+
+	return nil
+}
+
+// Deliver - окружение задается при создании контейнера
+func (e *EnvConfig) Deliver(context.Context, *BaseContainer) error {
+	return nil
+}
+
+func (cs configs) Prepare(ctx context.Context, c *BaseContainer) error {
+	for _, p := range cs {
+		if err := p.Prepare(ctx, c); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (cs configs) Deliver(ctx context.Context, c *BaseContainer) error {
+	for _, p := range cs {
+		if err := p.Deliver(ctx, c); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (e *envsController) Prepare(_ context.Context, c *BaseContainer) error {
+	if e.prefix != "" {
+		c.Envs = append(c.Envs, e.ctl.DumpEnvFor(e.prefix)...)
+	} else {
+		c.Envs = append(c.Envs, e.ctl.DumpEnv()...)
+	}
+
+	return nil
+}
+
+func (e *envsController) Deliver(context.Context, *BaseContainer) error {
+	return nil
+}
+
+// render - отрисовывает шаблон name с данными data (по умолчанию - контейнер);
+// в шаблоне доступна функция env для чтения окружения тестового процесса
+func render(name, text string, data ConfigData, c *BaseContainer) ([]byte, error) {
+	tmpl, err := template.New(name).
+		Option("missingkey=error").
+		Funcs(template.FuncMap{"env": os.Getenv}).
+		Parse(text)
+	if err != nil {
+		return nil, errors.Ctx().Str("template", name).Wrap(err, "parse config template")
+	}
+
+	var value any = c
+	if data != nil {
+		value = data(c)
+	}
+
+	var buf bytes.Buffer
+
+	if err = tmpl.Execute(&buf, value); err != nil {
+		return nil, errors.Ctx().Str("template", name).Wrap(err, "render config template")
+	}
+
+	return buf.Bytes(), nil
+}
+
+// fileArchive - tar-архив из одного файла name
+func fileArchive(name string, content []byte, mode os.FileMode) (*bytes.Buffer, error) {
+	var buf bytes.Buffer
+
+	tw := tar.NewWriter(&buf)
+
+	hdr := &tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     name,
+		Mode:     int64(mode.Perm()),
+		Size:     int64(len(content)),
+		ModTime:  time.Now(),
+	}
+
+	if err := tw.WriteHeader(hdr); err != nil {
+		return nil, errors.Wrap(err, "write tar header")
+	}
+
+	if _, err := tw.Write(content); err != nil {
+		return nil, errors.Wrap(err, "write file to archive")
+	}
+
+	if err := tw.Close(); err != nil {
+		return nil, errors.Wrap(err, "close tar writer")
+	}
+
+	return &buf, nil
+}
@@ -1,6 +1,7 @@
 package containers
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"io"
@@ -10,6 +11,7 @@ import (
 	"sync"
 	"time"
 
+	"gopkg.in/gomisc/containers.v1/wait"
 	envs "gopkg.in/gomisc/envs.v1"
 	"gopkg.in/gomisc/errors.v1"
 	"gopkg.in/gomisc/errors.v1/errgroup"
@@ -21,16 +23,43 @@ const (
 	ErrContainerExitedBeforeReady = errors.Const("container exited before ready")
 	ErrContainerAlreadyStoped     = errors.Const("container already stopped")
 	ErrContainerDidntStart        = errors.Const("container did not start")
+	ErrInvalidStartTimeout        = errors.Const("invalid container start timeout")
+	ErrContainerNotReady          = errors.Const("container readiness check failed")
+	ErrContainerNotRunning        = errors.Const("container is not running")
+	ErrContainerOOMKilled         = errors.Const("container killed by out of memory")
+	ErrImageNotFound              = errors.Const("image not found")
 	StartTimeoutFactorEnvar       = "DEBUG_START_TIMEOUT_FACTOR"
+
+	ipForwardSysctl = "net.ipv4.ip_forward"
+
+	// execNotExecutable, execNotFound - коды завершения exec, если команда
+	// не исполняется или не найдена в образе
+	execNotExecutable = 126
+	execNotFound      = 127
+
+	// DefaultStartTimeout - таймаут готовности контейнера, если StartTimeout не задан
+	DefaultStartTimeout = time.Minute
+
+	// WasmEdgeRuntime - wasm shim containerd на базе WasmEdge
+	WasmEdgeRuntime = "io.containerd.wasmedge.v1"
+	// WasmPlatform - платформа wasm образов
+	WasmPlatform = "wasi/wasm"
+
+	// HostGateway - адрес ExtraHosts, который демон заменяет адресом хоста
+	HostGateway = "host-gateway"
 )
 
-var _ Container = (*BaseContainer)(nil)
+var _ ExtendedContainer = (*BaseContainer)(nil)
 
 type ContainerInfo struct {
 	ID        string
 	IPAddress string
 	PortBinds PortMap
 	Networks  map[string]EndpointSettings
+	// Labels - метки запущенного контейнера
+	Labels map[string]string
+	// IPv6Address - IPv6 адрес контейнера в основной сети, если сеть его выдает
+	IPv6Address string
 }
 
 type ContainerStatus struct {
@@ -41,43 +70,169 @@ type ContainerStatus struct {
 // BaseContainer - базовый тип обертки над нативным docker container
 // nolint:maligned
 type BaseContainer struct {
-	Ctx          context.Context
-	Ready        ReadyFunc
-	OutputStream io.Writer
-	ErrorStream  io.Writer
-
-	Name        string
-	TypeID      uint8
-	Image       string
-	EntryPoint  string
+	Ctx   context.Context `json:"-" yaml:"-"`
+	Ready ReadyFunc       `json:"-" yaml:"-"`
+	// Readiness - проверка готовности с причиной неудачи, приоритетнее Ready
+	// По умолчанию контейнер готов, когда принимают подключения все его порты
+	Readiness    ReadinessFunc `json:"-" yaml:"-"`
+	OutputStream io.Writer     `json:"-" yaml:"-"`
+	ErrorStream  io.Writer     `json:"-" yaml:"-"`
+
+	Name        string `json:"name" yaml:"name"`
+	TypeID      uint8  `json:"type_id,omitempty" yaml:"type_id,omitempty"`
+	Image       string `json:"image" yaml:"image"`
+	EntryPoint  string `json:"entry_point,omitempty" yaml:"entry_point,omitempty"`
 	client      Client
 	network     Network
 	containerID string
 
 	hostIP      string
-	ContainerIP string
-
-	Cmd       []string
-	Mounts    []string
-	Envs      []string
-	Volumes   []string
-	Sysctls   map[string]string
-	DebugPort ports.DebugPort
-	Ports     PortBinds
+	ContainerIP string `json:"container_ip,omitempty" yaml:"container_ip,omitempty"`
+	// ContainerIP6 - IPv6 адрес контейнера в основной сети с двумя стеками адресов
+	ContainerIP6 string `json:"container_ip6,omitempty" yaml:"container_ip6,omitempty"`
+	// Aliases - дополнительные DNS имена контейнера в его сети
+	Aliases []string `json:"aliases,omitempty" yaml:"aliases,omitempty"`
+	// ExtraHosts - дополнительные записи /etc/hosts в форме name:ip,
+	// адрес HostGateway разрешается демоном в адрес хоста
+	ExtraHosts []string `json:"extra_hosts,omitempty" yaml:"extra_hosts,omitempty"`
+	// DNS - адреса DNS серверов контейнера вместо серверов демона
+	DNS []string `json:"dns,omitempty" yaml:"dns,omitempty"`
+	// DNSSearch - домены поиска для коротких имен
+	DNSSearch []string `json:"dns_search,omitempty" yaml:"dns_search,omitempty"`
+
+	Cmd       []string          `json:"cmd,omitempty" yaml:"cmd,omitempty"`
+	Mounts    []string          `json:"mounts,omitempty" yaml:"mounts,omitempty"`
+	Envs      []string          `json:"envs,omitempty" yaml:"envs,omitempty"`
+	Volumes   []string          `json:"volumes,omitempty" yaml:"volumes,omitempty"`
+	Sysctls   map[string]string `json:"sysctls,omitempty" yaml:"sysctls,omitempty"`
+	DebugPort ports.DebugPort   `json:"debug_port,omitempty" yaml:"debug_port,omitempty"`
+	// Debug - стратегия запуска процесса под отладчиком, команда контейнера
+	// изменяется только если стратегия задана явно (или включен DebugPort)
+	Debug     DebugStrategy `json:"-" yaml:"-"`
+	Ports     PortBinds     `json:"ports,omitempty" yaml:"ports,omitempty"`
 	portnames map[string]ports.PortName
-
-	StartTimeout time.Duration
-	Autoremove   bool
-	NotBindPorts bool
-	Background   bool
-
+	// Secrets - секреты, доступные процессу файлами SecretsDir/<Name>;
+	// их значения вырезаются из логов контейнера и служебных сообщений
+	Secrets []Secret `json:"secrets,omitempty" yaml:"secrets,omitempty"`
+	// NamedVolumes - именованные тома, в отличие от анонимных Volumes
+	// переживают контейнер и могут подключаться к нескольким контейнерам
+	NamedVolumes []VolumeSpec `json:"named_volumes,omitempty" yaml:"named_volumes,omitempty"`
+	// Tmpfs - разделы в памяти по пути в контейнере с опциями монтирования
+	// (например "size=512m,mode=1777", пусто - опции демона); данные баз
+	// на tmpfs не пишутся на диск, что заметно ускоряет тесты в CI
+	Tmpfs map[string]string `json:"tmpfs,omitempty" yaml:"tmpfs,omitempty"`
+	// MountSpecs - разделы контейнера в структурированной форме, дополняют Mounts
+	MountSpecs []MountSpec `json:"mount_specs,omitempty" yaml:"mount_specs,omitempty"`
+	// ExtraNetworks - сети, к которым контейнер подключается помимо основной
+	ExtraNetworks []NetworkAttachment `json:"-" yaml:"-"`
+	// Runtime - OCI runtime контейнера (пусто - runtime демона по умолчанию),
+	// например WasmEdgeRuntime для wasm-нагрузок
+	Runtime string `json:"runtime,omitempty" yaml:"runtime,omitempty"`
+	// Platform - платформа образа в форме os/arch[/variant], например WasmPlatform
+	Platform string `json:"platform,omitempty" yaml:"platform,omitempty"`
+	// FakeClock - подмена часов процессов контейнера, управляется ShiftClock
+	FakeClock *FakeClock `json:"fake_clock,omitempty" yaml:"fake_clock,omitempty"`
+	// Hooks - OCI хуки, выполняемые на хосте; если адаптер не выполняет их
+	// сам (HooksRuntime), Poststart и Poststop эмулируются BaseContainer
+	Hooks Hooks `json:"hooks,omitempty" yaml:"hooks,omitempty"`
+	// Healthcheck - проверка здоровья контейнера вместо проверки образа,
+	// nil - проверка образа (см. ForHealthy)
+	Healthcheck *Healthcheck `json:"healthcheck,omitempty" yaml:"healthcheck,omitempty"`
+	// Resources - ограничения памяти, процессора и числа процессов контейнера,
+	// проверяются на поддержку демоном при создании
+	Resources Resources `json:"resources" yaml:"resources"`
+	// Devices - устройства хоста, пробрасываемые в контейнер
+	Devices []Device `json:"devices,omitempty" yaml:"devices,omitempty"`
+	// GPUs - запросы GPU у драйвера устройств демона, см. WithGPUs
+	GPUs []GPURequest `json:"gpus,omitempty" yaml:"gpus,omitempty"`
+	// User - пользователь процесса в форме user[:group] или uid[:gid],
+	// пусто - пользователь образа
+	User string `json:"user,omitempty" yaml:"user,omitempty"`
+	// WorkDir - рабочий каталог процесса, пусто - каталог образа
+	WorkDir string `json:"work_dir,omitempty" yaml:"work_dir,omitempty"`
+	// Labels - метки контейнера, по которым его находят внешние инструменты;
+	// метку сессии адаптер добавляет сам
+	Labels map[string]string `json:"labels,omitempty" yaml:"labels,omitempty"`
+
+	// StartTimeout - время ожидания готовности контейнера, отсчитывается
+	// после его запуска (скачивание образа в бюджет не входит)
+	StartTimeout time.Duration `json:"start_timeout,omitempty" yaml:"start_timeout,omitempty"`
+	// StopTimeout - время на штатное завершение процесса при Stop, по истечении
+	// которого процесс завершается принудительно (0 - сразу)
+	StopTimeout time.Duration `json:"stop_timeout,omitempty" yaml:"stop_timeout,omitempty"`
+	// NoProxyEnv - не передавать контейнеру переменные прокси окружения процесса
+	NoProxyEnv bool `json:"no_proxy_env,omitempty" yaml:"no_proxy_env,omitempty"`
+	// NoProxy - дополнительные исключения NO_PROXY; имена участников окружения
+	// добавляет оркестратор
+	NoProxy []string `json:"no_proxy,omitempty" yaml:"no_proxy,omitempty"`
+	// NoIPForward - отключает системную настройку net.ipv4.ip_forward,
+	// выставляемую конструктором по умолчанию
+	NoIPForward  bool `json:"no_ip_forward,omitempty" yaml:"no_ip_forward,omitempty"`
+	Autoremove   bool `json:"autoremove,omitempty" yaml:"autoremove,omitempty"`
+	NotBindPorts bool `json:"not_bind_ports,omitempty" yaml:"not_bind_ports,omitempty"`
+	Background   bool `json:"background,omitempty" yaml:"background,omitempty"`
+	// AttachLogs - транслировать вывод контейнера с момента запуска; без него
+	// поток логов открывается, только если явно заданы OutputStream/ErrorStream
+	// или вызван FollowLogs
+	AttachLogs bool `json:"attach_logs,omitempty" yaml:"attach_logs,omitempty"`
+	// TailBytes - размер буфера хвоста вывода для LogTail (0 - DefaultTailBytes,
+	// отрицательный - хвост не сохраняется и не читается)
+	TailBytes int `json:"tail_bytes,omitempty" yaml:"tail_bytes,omitempty"`
+	// Budgets - сроки фаз запуска для Run
+	Budgets PhaseBudgets `json:"budgets" yaml:"budgets"`
+
+	// addrMu защищает адреса контейнера, заполняемые при старте
+	addrMu           sync.RWMutex
 	containerAddress AddrsMap
 	hostAddress      AddrsMap
 
-	ConfController envs.Controller
+	ConfController envs.Controller `json:"-" yaml:"-"`
+	// Config - источник конфигурации контейнера, применяется при создании
+	// (см. ConfigProvider, EnvsController)
+	Config ConfigProvider `json:"-" yaml:"-"`
+
+	mutex        sync.Mutex
+	debugApplied bool
+	cancelLogs   context.CancelFunc
+	cancelWait   context.CancelFunc
+	proxies      map[ports.PortName]*Proxy
+	stopOnce     sync.Once
+	stopErr      error
+	// secretsDir - каталог хоста с файлами секретов, secretValues - их значения
+	secretsDir   string
+	secretValues []string
+	// configPrepared - спецификация уже дополнена Config
+	configPrepared bool
+	// coverage - бинарник с покрытием, см. WithCoverage
+	coverage *coverage
+	// test - тест, переданный WithArtifacts: его срок ограничивает паузу отладки
+	test deadliner
+	// restarts - номер последнего вызова Restart, restarted закрывается
+	// по его завершении
+	restarts  uint64
+	restarted chan struct{}
+	// createdVolumes - именованные тома, созданные вместе с контейнером;
+	// удаляются при откате прерванного запуска
+	createdVolumes []string
+	// tail - хвост транслируемого вывода, см. LogTail
+	tail *LogRing
+	// fanout - раздача вывода потребителям AddLogConsumer
+	fanout *LogFanout
+	// cancelStats, startedAt, peakMemory - замеры запуска для RunReport,
+	// report - отчет о последнем завершенном запуске
+	cancelStats context.CancelFunc
+	startedAt   time.Time
+	peakMemory  uint64
+	report      *RunReport
+}
+
+// NewBaseContainerWithConfig - конструктор базового контейнера с источником
+// конфигурации cfg
+func NewBaseContainerWithConfig(cli Client, nw Network, cfg ConfigProvider) *BaseContainer {
+	cont := NewBaseContainer(cli, nw, nil)
+	cont.Config = cfg
 
-	mutex   sync.Mutex
-	stopped bool
+	return cont
 }
 
 // NewBaseContainer - конструктор базового контейнера
@@ -86,7 +241,7 @@ func NewBaseContainer(cli Client, nw Network, confCtl envs.Controller) *BaseCont
 		client:         cli,
 		ConfController: confCtl,
 		Sysctls: map[string]string{
-			"net.ipv4.ip_forward": "1",
+			ipForwardSysctl: "1",
 		},
 		network:          nw,
 		portnames:        make(map[string]ports.PortName),
@@ -110,12 +265,27 @@ func (c *BaseContainer) HostIP() string {
 
 func (c *BaseContainer) GetContainerIP() string {
 	if c != nil {
+		c.addrMu.RLock()
+		defer c.addrMu.RUnlock()
+
 		return c.ContainerIP
 	}
 
 	return ""
 }
 
+// GetContainerIP6 - IPv6 адрес контейнера в основной сети, пусто - сеть без IPv6
+func (c *BaseContainer) GetContainerIP6() string {
+	if c != nil {
+		c.addrMu.RLock()
+		defer c.addrMu.RUnlock()
+
+		return c.ContainerIP6
+	}
+
+	return ""
+}
+
 // HostPort - возвращает фактический порт по имени порта
 func (c *BaseContainer) HostPort(name ports.PortName) string {
 	for _, b := range c.Ports {
@@ -132,6 +302,11 @@ func (c *BaseContainer) GetClient() Client {
 	return c.client
 }
 
+// runtime - клиент среды исполнения с необязательными возможностями
+func (c *BaseContainer) runtime() ExtendedClient {
+	return ExtendClient(c.client)
+}
+
 func (c *BaseContainer) ContainerPorts() []Port {
 	ports := make([]Port, len(c.Ports))
 
@@ -142,17 +317,22 @@ func (c *BaseContainer) ContainerPorts() []Port {
 	return ports
 }
 
+// PortMap - привязки портов контейнера к хосту; каждый вызов строит новую
+// мапу, вызывающий может ее изменять. Привязки всех портов размещаются в
+// одном массиве
 func (c *BaseContainer) PortMap() PortMap {
-	pm := make(PortMap)
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	pm := make(PortMap, len(c.Ports))
+	binds := make([]PortBinding, len(c.Ports))
 
 	for i := 0; i < len(c.Ports); i++ {
-		p := c.Ports[i].Container
-		pm[p] = []PortBinding{
-			{
-				HostIP:   c.hostIP,
-				HostPort: strconv.Itoa(int(c.Ports[i].Host)),
-			},
+		binds[i] = PortBinding{
+			HostIP:   c.hostIP,
+			HostPort: strconv.Itoa(int(c.Ports[i].Host)),
 		}
+		pm[c.Ports[i].Container] = binds[i : i+1 : i+1]
 	}
 
 	return pm
@@ -199,14 +379,51 @@ func (c *BaseContainer) GetMounts() []string {
 	return nil
 }
 
+// GetAutoremove - признак авто удаления контейнера; контейнер с покрытием
+// удаляется после извлечения данных (см. WithCoverage)
 func (c *BaseContainer) GetAutoremove() bool {
 	if c != nil {
-		return c.Autoremove
+		return c.Autoremove && c.coverage == nil
 	}
 
 	return false
 }
 
+// GetAliases - возвращает дополнительные DNS имена контейнера
+func (c *BaseContainer) GetAliases() []string {
+	return c.Aliases
+}
+
+// GetExtraHosts - возвращает дополнительные записи /etc/hosts контейнера
+func (c *BaseContainer) GetExtraHosts() []string {
+	return c.ExtraHosts
+}
+
+// GetUser - возвращает пользователя процесса контейнера
+func (c *BaseContainer) GetUser() string {
+	return c.User
+}
+
+// GetWorkDir - возвращает рабочий каталог процесса контейнера
+func (c *BaseContainer) GetWorkDir() string {
+	return c.WorkDir
+}
+
+// GetLabels - возвращает метки контейнера
+func (c *BaseContainer) GetLabels() map[string]string {
+	return c.Labels
+}
+
+// GetRuntime - возвращает OCI runtime контейнера
+func (c *BaseContainer) GetRuntime() string {
+	return c.Runtime
+}
+
+// GetPlatform - возвращает платформу образа контейнера
+func (c *BaseContainer) GetPlatform() string {
+	return c.Platform
+}
+
 func (c *BaseContainer) GetNetwork() Network {
 	if c != nil {
 		return c.network
@@ -215,14 +432,20 @@ func (c *BaseContainer) GetNetwork() Network {
 	return nil
 }
 
-// HostAddrs возвращает список эндпоинтов хоста
+// HostAddrs возвращает копию списка эндпоинтов хоста
 func (c *BaseContainer) HostAddrs() AddrsMap {
-	return c.hostAddress
+	c.addrMu.RLock()
+	defer c.addrMu.RUnlock()
+
+	return c.hostAddress.Copy()
 }
 
-// ContainerAddrs возвращает список эндпоинтов контейнера
+// ContainerAddrs возвращает копию списка эндпоинтов контейнера
 func (c *BaseContainer) ContainerAddrs() AddrsMap {
-	return c.containerAddress
+	c.addrMu.RLock()
+	defer c.addrMu.RUnlock()
+
+	return c.containerAddress.Copy()
 }
 
 // CreateContainer конфигурирует и создает контейнер
@@ -231,110 +454,301 @@ func (c *BaseContainer) CreateContainer() error {
 		c.Ctx = context.Background()
 	}
 
-	if c.Ready == nil {
-		c.Ready = c.ready
+	return c.create(c.Ctx)
+}
+
+// create - конфигурирует и создает контейнер; при ошибке или панике
+// частично созданный контейнер и файлы хоста удаляются
+func (c *BaseContainer) create(ctx context.Context) (err error) {
+	defer c.undoStartup(&err, nil)
+
+	if c.Readiness == nil && c.Ready != nil {
+		c.Readiness = c.Ready.Readiness()
 	}
 
-	c.portnames = c.Ports.Names()
+	if c.Readiness == nil {
+		c.Readiness = wait.ForListeningSockets(c.endpoints, c.sockets, c.procNet)
+	}
+
+	if err = c.setupStartTimeout(); err != nil {
+		return err
+	}
+
+	c.Image = ResolveImage(c.Image)
 
 	// включение отладки
 	c.setupDebug()
 
-	id, err := c.client.ContainerCreate(c.Ctx, c)
+	c.portnames = c.Ports.Names()
+
+	if c.NoIPForward {
+		delete(c.Sysctls, ipForwardSysctl)
+	}
+
+	c.setupProxyEnv()
+
+	if err = c.checkHooks(); err != nil {
+		return err
+	}
+
+	if err = c.checkMounts(); err != nil {
+		return err
+	}
+
+	if err = c.checkVolumes(); err != nil {
+		return err
+	}
+
+	if err = c.checkHermetic(); err != nil {
+		return err
+	}
+
+	if err = c.checkDNS(); err != nil {
+		return err
+	}
+
+	if err = c.checkDevices(); err != nil {
+		return err
+	}
+
+	if err = c.checkResources(ctx); err != nil {
+		return err
+	}
+
+	if err = c.checkPlatform(ctx); err != nil {
+		return err
+	}
+
+	if err = c.mountSecrets(); err != nil {
+		return err
+	}
+
+	if err = c.mountClock(); err != nil {
+		return err
+	}
+
+	// спецификация дополняется один раз: create повторяется после скачивания
+	// образа и при Recreate
+	if c.Config != nil && !c.configPrepared {
+		if err = c.Config.Prepare(ctx, c); err != nil {
+			return errors.Ctx().Str("container-name", c.GetName()).Wrap(err, "prepare config")
+		}
+
+		c.configPrepared = true
+	}
+
+	volumes, err := c.missingVolumes(ctx)
+	if err != nil {
+		return err
+	}
+
+	// тома могут появиться, даже если ответа на прерванный запрос не будет
+	c.mutex.Lock()
+	c.createdVolumes = append(c.createdVolumes, volumes...)
+	c.mutex.Unlock()
+
+	id, err := c.client.ContainerCreate(ctx, c)
 	if err != nil {
 		return errors.Wrap(err, "create container")
 	}
 
+	c.mutex.Lock()
 	c.containerID = id
+	c.mutex.Unlock()
 
-	return nil
+	if c.Config != nil {
+		if err = c.Config.Deliver(ctx, c); err != nil {
+			return errors.Ctx().Str("container-name", c.GetName()).Wrap(err, "deliver config")
+		}
+	}
+
+	return c.injectBinary(ctx)
+}
+
+// Run - создает и запускает контейнер в фоновом режиме. Наличие образа
+// заранее не проверяется: образ скачивается, только если создание контейнера
+// завершилось ошибкой ErrImageNotFound, что экономит обращение к демону
+// в основном сценарии. Каждая фаза ограничена сроком из Budgets и дедлайном
+// ctx, превышение срока возвращается как *PhaseTimeoutError
+func (c *BaseContainer) Run(ctx context.Context) (err error) {
+	defer c.undoStartup(&err, ctx)
+
+	c.Ctx = ctx
+	c.Background = true
+
+	budgets := c.Budgets.withDefaults()
+
+	err = runPhase(ctx, PhaseCreate, budgets.Create, c.create)
+	if errors.Is(err, ErrImageNotFound) {
+		err = runPhase(
+			ctx, PhasePull, budgets.Pull, func(ctx context.Context) error {
+				return pullImage(ctx, c.client, c.Image, c.Platform)
+			},
+		)
+		if err != nil {
+			return err
+		}
+
+		err = runPhase(ctx, PhaseCreate, budgets.Create, c.create)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if budgets.Readiness == 0 {
+		budgets.Readiness = c.StartTimeout
+	}
+
+	// готовность отсчитывается от запуска контейнера, поэтому ее срок
+	// задается через StartTimeout, а не контекстом фазы
+	readiness := phaseBudget(ctx, budgets.Readiness)
+	if readiness <= 0 {
+		return &PhaseTimeoutError{Phase: PhaseReadiness, Budget: readiness, Err: ctx.Err()}
+	}
+
+	c.StartTimeout = readiness
+
+	startBudget := phaseBudget(ctx, budgets.Start)
+
+	startCtx, cancel := phaseContext(ctx, startBudget)
+	defer cancel()
+
+	err = c.start(startCtx, nil, nil)
+
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, ErrContainerDidntStart):
+		return &PhaseTimeoutError{Phase: PhaseReadiness, Budget: readiness, Err: err}
+	case errors.Is(err, ErrContainerNotReady), errors.Is(err, ErrContainerExitedBeforeReady):
+		return err
+	case errors.Is(startCtx.Err(), context.DeadlineExceeded):
+		return &PhaseTimeoutError{Phase: PhaseStart, Budget: startBudget, Err: err}
+	default:
+		return err
+	}
 }
 
 // StartContainer непосредственно запускает контейнер
 func (c *BaseContainer) StartContainer(sigCh <-chan os.Signal, ready chan<- struct{}) error {
-	if c.DebugPort.Enabled() {
-		c.LogStdout("\n!!! RUNNING IN DEBUG MODE!!! PORT: %s\n\n", c.DebugPort)
+	return c.start(c.context(), sigCh, ready)
+}
+
+// start - запускает контейнер, ctx ограничивает только запрос запуска к демону.
+// Запуск, прерванный отменой ctx или контекста контейнера, откатывается
+func (c *BaseContainer) start(ctx context.Context, sigCh <-chan os.Signal, ready chan<- struct{}) (err error) {
+	defer c.undoStartup(&err, c.context())
+
+	if c.Debug != nil {
+		c.LogStdout("\n!!! RUNNING IN DEBUG MODE!!! PORT: %d\n\n", c.Debug.Port())
 	}
 
-	info, err := c.client.ContainerStart(c.Ctx, c.containerID, c.Name)
+	info, err := c.client.ContainerStart(ctx, c.containerID, c.Name)
 	if err != nil {
+		if cause := ctx.Err(); cause != nil {
+			// демон мог запустить контейнер, ответ которого уже не дождаться
+			err = c.cancelled(errors.And(cause, err))
+			c.rollback(err)
+
+			return err
+		}
+
 		return errors.Wrapf(err, "start container")
 	}
 
+	if err = c.runHooks(ctx, c.Hooks.Poststart, ociStatusRunning); err != nil {
+		return err
+	}
+
 	// заполняем хостовые эндпоинты контейнера
+	hostAddress := make(AddrsMap, len(info.PortBinds))
+
 	for _, bind := range info.PortBinds {
 		if len(bind) > 0 {
 			b := bind[0]
-			c.hostAddress[c.portnames[b.HostPort]] = net.JoinHostPort(c.hostIP, b.HostPort)
+			hostAddress[c.portnames[b.HostPort]] = net.JoinHostPort(c.hostIP, b.HostPort)
 		}
 	}
 
 	// заполняем внутренние эндпоинты контейнера (которые в сети докера)
-	containerIP := info.IPAddress
+	containerIP, containerIP6 := info.IPAddress, info.IPv6Address
 
-	if containerIP == "" {
-		if endpoint, ok := info.Networks[c.network.Name()]; ok {
+	if endpoint, ok := info.Networks[c.network.Name()]; ok {
+		if containerIP == "" {
 			containerIP = endpoint.IPAddress
 		}
+
+		if containerIP6 == "" {
+			containerIP6 = endpoint.IPv6Address
+		}
 	}
 
-	c.ContainerIP = containerIP
+	containerAddress := make(AddrsMap, len(c.Ports))
 
 	for _, p := range c.Ports {
-		c.containerAddress[p.Name] = net.JoinHostPort(containerIP, p.Container.Port())
+		containerAddress[p.Name] = net.JoinHostPort(containerIP, p.Container.Port())
 	}
 
-	logContext, cancelLogs := context.WithCancel(context.Background())
-	defer cancelLogs()
+	c.addrMu.Lock()
+	c.ContainerIP = containerIP
+	c.ContainerIP6 = containerIP6
+	c.hostAddress = hostAddress
+	c.containerAddress = containerAddress
+	c.addrMu.Unlock()
 
-	leg := errgroup.New()
-	leg.Go(
-		func() error {
-			return c.client.StreamLogs(
-				logContext,
-				c.containerID,
-				c.ErrorStream,
-				c.OutputStream,
-				true,
-			)
-		},
-	)
+	c.sampleStats()
 
-	go func() {
-		if errList := errors.AsChain(leg.Wait()); len(errList) != 0 {
-			for i := 0; i < len(errList); i++ {
-				c.LogError(err)
-			}
+	c.mutex.Lock()
+	consumers := c.fanout != nil
+	c.mutex.Unlock()
+
+	if c.AttachLogs || c.OutputStream != nil || c.ErrorStream != nil || consumers {
+		c.attachLogs()
+	}
+
+	// в фоновом режиме логи транслируются до вызова Stop
+	defer func() {
+		if !c.Background || err != nil {
+			c.detachLogs()
 		}
 	}()
 
-	c.network.AddContainer(
-		&OrchestratorInfo{
-			ID:                info.ID,
-			TypeID:            c.TypeID,
-			ContainerEnpoints: c.containerAddress,
-			HostEnpoints:      c.hostAddress,
-		},
-	)
+	c.network.AddContainer(c.orchestratorInfo(info, containerAddress, hostAddress))
 
 	containerExit := c.wait()
-	ctx, cancel := context.WithTimeout(context.Background(), c.StartTimeout)
+
+	// бюджет готовности начинает отсчитываться только после запуска
+	// контейнера, отмена контекста контейнера прерывает ожидание
+	ctx, cancel := context.WithTimeout(c.context(), c.StartTimeout)
 
 	defer cancel()
 
 	select {
 	case <-ctx.Done():
-		if stopErr := c.Stop(); err != nil {
-			c.LogError(stopErr, "stop container")
+		if cause := c.context().Err(); cause != nil {
+			return c.cancelled(cause)
 		}
 
+		return c.notReady(ErrContainerDidntStart, nil)
+	case <-containerExit:
 		return errors.Ctx().
 			Str("container-name", c.GetName()).
-			Str("container-id", c.containerID[:12]).
-			Just(ErrContainerDidntStart)
-	case <-containerExit:
-		return ErrContainerExitedBeforeReady
-	case <-c.Ready(ctx):
+			Strings("log-tail", c.LogTail(DefaultTailLines)).
+			Just(ErrContainerExitedBeforeReady)
+	case err = <-c.Readiness(ctx):
+		if err != nil {
+			if cause := c.context().Err(); cause != nil {
+				return c.cancelled(errors.And(cause, err))
+			}
+
+			if ctx.Err() != nil {
+				err = errors.And(ErrContainerDidntStart, err)
+			}
+
+			return c.notReady(ErrContainerNotReady, err)
+		}
+
 		if !c.LogStdout(c.GetName() + " component ready") {
 			_, _ = fmt.Fprintln(os.Stdout, c.GetName()+" component ready")
 		}
@@ -358,31 +772,210 @@ func (c *BaseContainer) StartContainer(sigCh <-chan os.Signal, ready chan<- stru
 	return nil
 }
 
-// Stop останавливает контейнер
+// Stop останавливает контейнер, прекращает трансляцию его логов и дожидается
+// фактического завершения процесса. Вызов до создания контейнера безопасен
+// и не мешает остановить его после старта; повторные и конкурентные вызовы
+// возвращают результат первой остановки
 func (c *BaseContainer) Stop() error {
 	c.mutex.Lock()
-	if c.stopped {
-		c.mutex.Unlock()
+	created := c.containerID != ""
+	c.mutex.Unlock()
 
-		return errors.Ctx().
-			Str("container-id", c.containerID).
-			Str("container-name", c.GetName()).
-			Just(ErrContainerAlreadyStoped)
+	if !created {
+		// файлы секретов и часов могли остаться от неудачного создания
+		c.removeSecrets()
+		c.removeClock()
+
+		return nil
+	}
+
+	c.stopOnce.Do(
+		func() {
+			c.stopErr = c.stop()
+		},
+	)
+
+	return c.stopErr
+}
+
+// Recreate - удаляет контейнер и создает его заново из указанного образа
+// (пустое значение - из текущего), для запуска используется StartContainer
+func (c *BaseContainer) Recreate(image string) error {
+	return c.recreate(image, nil)
+}
+
+// recreate - Recreate с вызовом prepare (если задан) между удалением
+// старого контейнера и созданием нового
+func (c *BaseContainer) recreate(image string, prepare func(ctx context.Context) error) error {
+	if err := c.Stop(); err != nil {
+		c.LogError(err, "stop container before recreate")
+	}
+
+	if c.containerID != "" {
+		if err := c.runtime().ContainerRemove(c.context(), c.containerID); err != nil {
+			return errors.Ctx().
+				Str("container-name", c.GetName()).
+				Wrap(err, "remove container before recreate")
+		}
 	}
 
-	c.stopped = true
+	c.mutex.Lock()
+	c.containerID = ""
+	c.cancelLogs = nil
+	c.cancelWait = nil
+	c.stopOnce = sync.Once{}
+	c.stopErr = nil
+	c.startedAt = time.Time{}
+	c.peakMemory = 0
 	c.mutex.Unlock()
 
-	return c.client.ContainerStop(c.Ctx, c.containerID, time.Duration(0))
+	if image != "" {
+		c.Image = image
+	}
+
+	if prepare != nil {
+		if err := prepare(c.context()); err != nil {
+			return errors.Ctx().Str("container-name", c.GetName()).Wrap(err, "prepare recreate")
+		}
+	}
+
+	return c.CreateContainer()
+}
+
+// Proxy - возвращает прокси порта name, созданный ProxyManager.Route, или nil
+func (c *BaseContainer) Proxy(name ports.PortName) *Proxy {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.proxies[name]
+}
+
+func (c *BaseContainer) setProxy(name ports.PortName, p *Proxy) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.proxies == nil {
+		c.proxies = make(map[ports.PortName]*Proxy)
+	}
+
+	c.proxies[name] = p
+}
+
+// FollowLogs - подключает трансляцию логов запущенного контейнера в заданные
+// потоки (nil - поток по умолчанию), если она еще не подключена
+func (c *BaseContainer) FollowLogs(stdout, stderr io.Writer) error {
+	if c.containerID == "" {
+		return errors.Ctx().Str("container-name", c.GetName()).Just(ErrContainerNotRunning)
+	}
+
+	c.mutex.Lock()
+	if stdout != nil {
+		c.OutputStream = stdout
+	}
+
+	if stderr != nil {
+		c.ErrorStream = stderr
+	}
+	c.mutex.Unlock()
+
+	c.attachLogs()
+
+	return nil
+}
+
+// attachLogs - открывает поток логов контейнера, повторный вызов ничего не делает
+func (c *BaseContainer) attachLogs() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.cancelLogs != nil {
+		return
+	}
+
+	logContext, cancelLogs := context.WithCancel(context.Background())
+	c.cancelLogs = cancelLogs
+	stderr, stdout := c.teeTail(c.ErrorStream), c.teeTail(c.OutputStream)
+
+	// раздача пишется первой: ошибка записи в OutputStream/ErrorStream
+	// прерывает io.MultiWriter
+	fanout := c.logFanout()
+	stderr = joinWriters(fanout.Stderr(), stderr)
+	stdout = joinWriters(fanout.Stdout(), stdout)
+
+	leg := errgroup.New()
+	leg.Go(
+		func() error {
+			return c.client.StreamLogs(
+				logContext,
+				c.containerID,
+				c.redact(stderr),
+				c.redact(stdout),
+				true,
+			)
+		},
+	)
+
+	go func() {
+		if errList := errors.AsChain(leg.Wait()); len(errList) != 0 {
+			for i := 0; i < len(errList); i++ {
+				if errors.Is(errList[i], context.Canceled) {
+					continue
+				}
+
+				c.LogError(errList[i], "stream container logs")
+			}
+		}
+	}()
+}
+
+func (c *BaseContainer) detachLogs() {
+	c.mutex.Lock()
+	cancelLogs := c.cancelLogs
+	c.cancelLogs = nil
+	c.mutex.Unlock()
+
+	if cancelLogs != nil {
+		cancelLogs()
+	}
+}
+
+// WithWasm - запускает контейнер через wasm shim containerd (пустой runtime -
+// WasmEdgeRuntime) с платформой WasmPlatform. Sysctls в wasm-песочнице
+// не применяются, поэтому net.ipv4.ip_forward отключается
+func (c *BaseContainer) WithWasm(runtime string) *BaseContainer {
+	if runtime == "" {
+		runtime = WasmEdgeRuntime
+	}
+
+	c.Runtime = runtime
+	c.Platform = WasmPlatform
+	c.NoIPForward = true
+
+	return c
+}
+
+// WithOutput - устанавливает поток вывода контейнера
+func (c *BaseContainer) WithOutput(w io.Writer) *BaseContainer {
+	c.OutputStream = w
+
+	return c
+}
+
+// WithErrorOutput - устанавливает поток вывода ошибок контейнера
+func (c *BaseContainer) WithErrorOutput(w io.Writer) *BaseContainer {
+	c.ErrorStream = w
+
+	return c
 }
 
 // LogStdout пишет сообщение во writer потока стандартного вывода контейнера
 func (c *BaseContainer) LogStdout(format string, args ...any) bool {
-	if c.OutputStream == nil {
+	out := c.redact(c.output())
+	if out == nil {
 		return false
 	}
 
-	if _, err := fmt.Fprintf(c.OutputStream, format+"\n", args...); err != nil {
+	if _, err := fmt.Fprintf(out, format+"\n", args...); err != nil {
 		return false
 	}
 
@@ -391,11 +984,12 @@ func (c *BaseContainer) LogStdout(format string, args ...any) bool {
 
 // LogStderr пишет сообщение во writer потока стандартного вывода ошибок контейнера
 func (c *BaseContainer) LogStderr(format string, args ...any) bool {
-	if c.ErrorStream == nil {
+	out := c.redact(c.errorOutput())
+	if out == nil {
 		return false
 	}
 
-	if _, err := fmt.Fprintf(c.ErrorStream, format+"\n", args...); err != nil {
+	if _, err := fmt.Fprintf(out, format+"\n", args...); err != nil {
 		return false
 	}
 
@@ -407,66 +1001,343 @@ func (c *BaseContainer) LogError(err error, args ...any) bool {
 	return c.LogStderr("\x1b[91mERROR:\x1b[0m " + errors.Formatted(err, args...).Error())
 }
 
-func (c *BaseContainer) ready(ctx context.Context) <-chan struct{} {
-	readyCh := make(chan struct{})
+// output возвращает поток для служебных сообщений, по умолчанию stdout клиента
+func (c *BaseContainer) output() io.Writer {
+	if c.OutputStream != nil {
+		return c.OutputStream
+	}
 
-	go func() {
-		select {
-		case <-ctx.Done():
-			return
-		case <-time.After(time.Second * 5):
-			close(readyCh)
-			return
+	if c.client != nil {
+		return c.runtime().Stdout()
+	}
+
+	return nil
+}
+
+// errorOutput возвращает поток для служебных ошибок, по умолчанию stderr клиента
+func (c *BaseContainer) errorOutput() io.Writer {
+	if c.ErrorStream != nil {
+		return c.ErrorStream
+	}
+
+	if c.client != nil {
+		return c.runtime().Stderr()
+	}
+
+	return nil
+}
+
+// notReady останавливает не готовый контейнер и возвращает цепочку из
+// причины неготовности и ошибки проверки (если она есть)
+func (c *BaseContainer) notReady(reason, cause error) error {
+	// хвост вывода читается до Stop: Autoremove удалит контейнер вместе с логами
+	tail := c.LogTail(DefaultTailLines)
+
+	c.holdForDebug(errors.And(reason, cause), tail)
+
+	if stopErr := c.Stop(); stopErr != nil {
+		c.LogError(stopErr, "stop container")
+	}
+
+	return errors.And(
+		errors.Ctx().
+			Str("container-name", c.GetName()).
+			Str("container-id", shortID(c.containerID)).
+			Strings("log-tail", tail).
+			Just(reason),
+		cause,
+	)
+}
+
+// orchestratorInfo - сведения о запущенном контейнере для реестра сети
+func (c *BaseContainer) orchestratorInfo(info *ContainerInfo, containerAddress, hostAddress AddrsMap) *OrchestratorInfo {
+	result := &OrchestratorInfo{
+		ID:                info.ID,
+		TypeID:            c.TypeID,
+		ContainerEnpoints: containerAddress.Copy(),
+		HostEnpoints:      hostAddress.Copy(),
+		Name:              c.GetName(),
+		Image:             c.Image,
+		Labels:            info.Labels,
+		Networks:          make(map[string]EndpointSettings, len(info.Networks)),
+		State:             StateRunning,
+	}
+
+	for name, endpoint := range info.Networks {
+		result.Networks[name] = endpoint
+	}
+
+	if !c.Healthcheck.Disabled() {
+		result.Health = HealthStarting
+	}
+
+	return result
+}
+
+// endpoints - адреса tcp портов контейнера без повторов: udp порты
+// подключением не проверить, их проверяет sockets
+func (c *BaseContainer) endpoints() []string {
+	addrs := make([]string, 0, len(c.Ports))
+	seen := make(map[string]struct{}, len(c.Ports))
+
+	for _, b := range c.Ports {
+		if b.Container.Proto() != "tcp" {
+			continue
 		}
-	}()
 
-	return readyCh
+		addr := c.Endpoint(b.Name)
+		if _, ok := seen[addr]; ok || addr == "" {
+			continue
+		}
+
+		seen[addr] = struct{}{}
+		addrs = append(addrs, addr)
+	}
+
+	return addrs
 }
 
-func (c *BaseContainer) setupDebug() {
-	if c.DebugPort.Enabled() {
-		c.Ports = append(
-			c.Ports,
-			PortBind{
-				Name:      ports.DebugPortName,
-				Container: Port(fmt.Sprintf("%d/tcp", c.DebugPort.Port())),
-				Host:      ports.BaseDebugPort,
-			},
-		)
+// sockets - порты контейнера, сокеты которых проверяются в его сетевом пространстве
+func (c *BaseContainer) sockets() []wait.Socket {
+	sockets := make([]wait.Socket, 0, len(c.Ports))
+	seen := make(map[wait.Socket]struct{}, len(c.Ports))
 
-		if strFactor := os.Getenv(StartTimeoutFactorEnvar); strFactor != "" {
-			factor, err := strconv.Atoi(strFactor)
-			if err != nil {
-				c.LogStderr("wrong %s value", StartTimeoutFactorEnvar)
-			} else {
-				c.StartTimeout = c.StartTimeout * time.Duration(factor)
-			}
+	for _, b := range c.Ports {
+		port, err := strconv.ParseUint(b.Container.Port(), 10, 16)
+		if err != nil {
+			continue
 		}
 
-		c.Cmd = []string{
-			"/bin/dlv",
-			fmt.Sprintf("--listen=:%d", ports.BaseDebugPort),
-			"--headless=true",
-			"--api-version=2",
-			"--accept-multiclient",
-			"exec",
-			c.DebugPort.Command(),
+		s := wait.Socket{Proto: b.Container.Proto(), Port: uint16(port)}
+		if _, ok := seen[s]; ok {
+			continue
 		}
+
+		seen[s] = struct{}{}
+		sockets = append(sockets, s)
 	}
+
+	return sockets
 }
 
+// procNet - таблицы сокетов протокола proto в сетевом пространстве
+// контейнера; пусто - прочитать их нельзя (в образе нет cat)
+func (c *BaseContainer) procNet(ctx context.Context, proto string) (string, error) {
+	var out bytes.Buffer
+
+	// отсутствие таблицы proto6 при выключенном IPv6 дает код 1 и не мешает разбору
+	code, err := c.runtime().ContainerExec(
+		ctx, c.containerID, []string{"cat", "/proc/net/" + proto, "/proc/net/" + proto + "6"}, &out, io.Discard,
+	)
+	if err != nil {
+		return "", errors.Ctx().Str("container-name", c.GetName()).Wrap(err, "read socket table")
+	}
+
+	if code == execNotFound || code == execNotExecutable {
+		return "", nil
+	}
+
+	return out.String(), nil
+}
+
+func (c *BaseContainer) setupStartTimeout() error {
+	if c.StartTimeout < 0 {
+		return errors.Ctx().
+			Str("container-name", c.GetName()).
+			Str("start-timeout", c.StartTimeout.String()).
+			Just(ErrInvalidStartTimeout)
+	}
+
+	if c.StartTimeout == 0 {
+		c.StartTimeout = DefaultStartTimeout
+	}
+
+	return nil
+}
+
+func (c *BaseContainer) setupDebug() {
+	if c.Debug == nil && c.DebugPort.Enabled() {
+		c.Debug = &DelveDebug{Binary: c.DebugPort.Command()}
+	}
+
+	if c.Debug == nil || c.debugApplied {
+		return
+	}
+
+	c.debugApplied = true
+
+	port := c.Debug.Port()
+
+	c.Ports = append(
+		c.Ports,
+		PortBind{
+			Name:      ports.DebugPortName,
+			Container: NewPort(port, "tcp"),
+			Host:      port,
+		},
+	)
+
+	if strFactor := os.Getenv(StartTimeoutFactorEnvar); strFactor != "" {
+		factor, err := strconv.Atoi(strFactor)
+		if err != nil {
+			c.LogStderr("wrong %s value", StartTimeoutFactorEnvar)
+		} else {
+			c.StartTimeout = c.StartTimeout * time.Duration(factor)
+		}
+	}
+
+	c.Cmd = c.Debug.Command(c.Cmd)
+}
+
+func (c *BaseContainer) stop() error {
+	c.mutex.Lock()
+	cancelLogs, cancelWait := c.cancelLogs, c.cancelWait
+	c.mutex.Unlock()
+
+	// потребители вывода завершаются после закрытия трансляции
+	defer c.closeLogConsumers()
+
+	if cancelLogs != nil {
+		// логи закрываем после остановки, чтобы не потерять вывод завершения
+		defer cancelLogs()
+	}
+
+	if cancelWait != nil {
+		defer cancelWait()
+	}
+
+	// файлы секретов и часов удаляются после остановки процесса, который их читает
+	defer c.removeSecrets()
+	defer c.removeClock()
+
+	if c.containerID == "" {
+		return nil
+	}
+
+	ctx := c.context()
+
+	if err := c.client.ContainerStop(ctx, c.containerID, c.StopTimeout); err != nil {
+		return errors.Ctx().
+			Str("container-name", c.GetName()).
+			Wrap(err, "stop container")
+	}
+
+	waitCh, errCh := c.client.ContainerWait(ctx, c.containerID)
+
+	var status ContainerStatus
+
+	// ошибка ожидания означает, что контейнер уже удален (autoremove)
+	select {
+	case status = <-waitCh:
+	case <-errCh:
+	}
+
+	NetworkRegistry(c.network).SetState(c.containerID, StateExited, "")
+	c.makeReport(ctx, status)
+
+	// ошибка хуков не прерывает остановку: покрытие извлекается в любом случае
+	result := c.runHooks(ctx, c.Hooks.Poststop, ociStatusStopped)
+
+	if err := c.extractCoverage(ctx); err != nil {
+		result = errors.And(result, errors.Ctx().Str("container-name", c.GetName()).Wrap(err, "extract coverage"))
+	}
+
+	return result
+}
+
+func (c *BaseContainer) context() context.Context {
+	if c.Ctx != nil {
+		return c.Ctx
+	}
+
+	return context.Background()
+}
+
+// wait ожидает завершения контейнера. Канал буферизован, поэтому горутина
+// завершается, даже если результат никто не читает (фоновый режим), а Stop
+// отменяет ожидание. Помимо ContainerWait завершение отслеживается по событиям
+// среды исполнения: они же сообщают об OOM и обновляют состояние и здоровье
+// контейнера в реестре сети
 func (c *BaseContainer) wait() <-chan error {
-	exitCh := make(chan error)
+	exitCh := make(chan error, 1)
+	ctx, cancel := context.WithCancel(context.Background())
+
+	c.mutex.Lock()
+	c.cancelWait = cancel
+	id := c.containerID
+	c.mutex.Unlock()
+
+	registry := NetworkRegistry(c.network)
 
 	go func() {
-		waitCh, errCh := c.client.ContainerWait(context.Background(), c.containerID)
+		defer cancel()
+
+		events := c.runtime().Events(
+			ctx, FilterContainer(id), FilterAction(EventDie), FilterAction(EventOOM),
+			FilterAction(EventStart), FilterAction(EventHealthStatus),
+		)
+
+		for {
+			generation := c.restartGeneration()
+			waitCtx, stopWait := context.WithCancel(ctx)
+			waitCh, errCh := c.client.ContainerWait(waitCtx, id)
+
+			var (
+				status ContainerStatus
+				exited bool
+			)
+
+			for !exited {
+				select {
+				case ev, ok := <-events:
+					if ok {
+						registry.Apply(ev)
+					}
+
+					switch {
+					case !ok:
+						// поток событий оборвался, остается ContainerWait
+						events = nil
+					case ev.Action == EventOOM:
+						c.LogStderr("%s: process killed by out of memory", c.GetName())
+					case ev.Action == EventDie:
+						status, exited = c.exitedByEvent(ctx, id, ev)
+					}
+				case err := <-errCh:
+					stopWait()
+
+					if ctx.Err() != nil {
+						exitCh <- nil
+
+						return
+					}
+
+					exitCh <- errors.Ctx().
+						Str("container-name", c.GetName()).
+						Wrap(err, "container process exited with error")
+
+					return
+				case status = <-waitCh:
+					exited = true
+				}
+			}
+
+			stopWait()
+
+			// остановка процесса при Restart не считается завершением контейнера
+			if restarted := c.restartedSince(generation); restarted != nil {
+				select {
+				case <-restarted:
+					continue
+				case <-ctx.Done():
+					exitCh <- nil
+
+					return
+				}
+			}
+
+			registry.SetState(id, StateExited, "")
 
-		select {
-		case err := <-errCh:
-			exitCh <- errors.Ctx().
-				Str("container-name", c.GetName()).
-				Wrap(err, "container process exited with error")
-		case status := <-waitCh:
 			exitMsg := fmt.Sprintf("container exited with status: %d", status.StatusCode)
 			if status.Error != nil {
 				c.LogError(status.Error)
@@ -475,8 +1346,37 @@ func (c *BaseContainer) wait() <-chan error {
 			}
 
 			exitCh <- nil
+
+			return
 		}
 	}()
 
 	return exitCh
 }
+
+// exitedByEvent - проверяет по состоянию контейнера, что событие die относится
+// к фактическому завершению, а не к остановке при перезапуске
+func (c *BaseContainer) exitedByEvent(ctx context.Context, id string, ev ContainerEvent) (ContainerStatus, bool) {
+	info, err := c.runtime().ContainerInspect(ctx, id)
+	if err != nil {
+		// контейнер уже удален (autoremove)
+		code, _ := ev.ExitCode()
+
+		return ContainerStatus{StatusCode: int64(code)}, true
+	}
+
+	if info.Running() || info.Status == StateRestarting {
+		return ContainerStatus{}, false
+	}
+
+	status := ContainerStatus{StatusCode: int64(info.ExitCode)}
+
+	if info.OOMKilled {
+		status.Error = errors.Ctx().
+			Str("container-name", c.GetName()).
+			Int("exit-code", info.ExitCode).
+			Just(ErrContainerOOMKilled)
+	}
+
+	return status, true
+}
@@ -0,0 +1,174 @@
+package containers_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gopkg.in/gomisc/errors.v1"
+
+	"gopkg.in/gomisc/containers.v1"
+	"gopkg.in/gomisc/containers.v1/adapters/fake"
+	"gopkg.in/gomisc/containers.v1/wait"
+)
+
+const testImage = "busybox:1.36"
+
+func newTestContainer(t *testing.T, name string) (*fake.Client, *containers.BaseContainer) {
+	t.Helper()
+
+	cli, err := fake.New(fake.WithImages(testImage))
+	if err != nil {
+		t.Fatalf("fake.New: %v", err)
+	}
+
+	nw, err := cli.CheckNetwork(name+"-net", "")
+	if err != nil {
+		t.Fatalf("CheckNetwork: %v", err)
+	}
+
+	c := containers.NewBaseContainer(cli, nw, nil)
+	c.Name = name
+	c.Image = testImage
+	c.Background = true
+
+	return cli, c
+}
+
+func TestStopBeforeStart(t *testing.T) {
+	cli, c := newTestContainer(t, "stop-before-start")
+
+	if err := c.Stop(); err != nil {
+		t.Fatalf("Stop before start: %v", err)
+	}
+
+	if err := c.Run(context.Background()); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	if err := c.Stop(); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+
+	state, err := cli.ContainerInspect(context.Background(), c.GetID())
+	if err != nil {
+		t.Fatalf("ContainerInspect: %v", err)
+	}
+
+	if state.Running() {
+		t.Errorf("container is still running after Stop: status %q", state.Status)
+	}
+}
+
+func TestRegistryFollowsExit(t *testing.T) {
+	cli, c := newTestContainer(t, "registry-exit")
+	c.Readiness = wait.Immediately()
+
+	cli.Script(c.Name, fake.Script{ExitAfter: 50 * time.Millisecond, ExitCode: 3})
+
+	if err := c.Run(context.Background()); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	t.Cleanup(func() { _ = c.Stop() })
+
+	registry := containers.NetworkRegistry(c.GetNetwork())
+	deadline := time.Now().Add(5 * time.Second)
+
+	for {
+		info, ok := registry.Get(c.GetID())
+		if !ok {
+			t.Fatalf("container %s is not registered", c.GetID())
+		}
+
+		if info.State == containers.StateExited {
+			return
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatalf("registry state %q, want %q", info.State, containers.StateExited)
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestForHealthyWithoutHealthcheck(t *testing.T) {
+	_, c := newTestContainer(t, "no-healthcheck")
+	c.Readiness = c.ForHealthy()
+	c.StartTimeout = time.Minute
+
+	started := time.Now()
+
+	err := c.Run(context.Background())
+	if !errors.Is(err, containers.ErrNoHealthcheck) {
+		t.Fatalf("Run: got %v, want %v", err, containers.ErrNoHealthcheck)
+	}
+
+	if elapsed := time.Since(started); elapsed > 10*time.Second {
+		t.Fatalf("readiness waited %s for a missing healthcheck", elapsed)
+	}
+}
+
+// emulatedHooks - фейк, хуки которого выполняет BaseContainer
+type emulatedHooks struct {
+	*fake.Client
+}
+
+func (emulatedHooks) NativeHooks() bool {
+	return false
+}
+
+func TestPoststopHookErrorKeepsStopping(t *testing.T) {
+	cli, spec := newTestContainer(t, "poststop-error")
+
+	c := containers.NewBaseContainer(emulatedHooks{Client: cli}, spec.GetNetwork(), nil)
+	c.Name, c.Image, c.Background = spec.Name, spec.Image, true
+	c.Readiness = wait.Immediately()
+
+	marker := filepath.Join(t.TempDir(), "second-hook")
+	c.Hooks.Poststop = []containers.Hook{
+		{Path: "/bin/sh", Args: []string{"sh", "-c", "exit 1"}},
+		{Path: "/bin/sh", Args: []string{"sh", "-c", "touch " + marker}},
+	}
+
+	if err := c.Run(context.Background()); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	if err := c.Stop(); err == nil {
+		t.Fatal("Stop: expected poststop hook error")
+	}
+
+	if _, err := os.Stat(marker); err != nil {
+		t.Fatalf("poststop hook after the failed one did not run: %v", err)
+	}
+}
+
+func TestNewBaseContainerWithConfig(t *testing.T) {
+	cli, spec := newTestContainer(t, "with-config")
+
+	c := containers.NewBaseContainerWithConfig(
+		cli, spec.GetNetwork(), &containers.EnvConfig{Vars: map[string]string{"NAME": "{{ .Name }}"}},
+	)
+	c.Name, c.Image, c.Background = spec.Name, spec.Image, true
+	c.Readiness = wait.Immediately()
+
+	if err := c.Run(context.Background()); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	t.Cleanup(func() { _ = c.Stop() })
+
+	found := false
+
+	for _, env := range c.Envs {
+		found = found || env == "NAME="+c.Name
+	}
+
+	if !found {
+		t.Fatalf("config is not applied: envs %v", c.Envs)
+	}
+}
@@ -0,0 +1,350 @@
+package containers
+
+import (
+	"archive/tar"
+	"context"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/gomisc/errors.v1"
+)
+
+const (
+	// ErrUnsafeArchivePath - ошибка "путь в архиве выходит за пределы каталога назначения"
+	ErrUnsafeArchivePath = errors.Const("unsafe archive path")
+
+	// maxArchiveLinks - предел символических ссылок при разрешении пути архива
+	maxArchiveLinks = 255
+)
+
+// CopyToContainer - копирует файл или каталог src хоста в каталог dst контейнера
+// (как docker cp: в dst появляется элемент с именем filepath.Base(src)).
+// Архив формируется потоково, без временных файлов; символические ссылки
+// копируются как ссылки, права и время модификации сохраняются
+func CopyToContainer(ctx context.Context, c Container, src, dst string) error {
+	pr, pw := io.Pipe()
+
+	go func() {
+		pw.CloseWithError(writeTar(pw, src))
+	}()
+
+	err := ExtendClient(c.GetClient()).CopyToContainer(ctx, c.GetID(), dst, pr)
+
+	// прерываем запись архива, если клиент завершился, не дочитав поток
+	_ = pr.CloseWithError(io.ErrClosedPipe)
+
+	if err != nil {
+		return errors.Ctx().Str("name", c.GetName()).Str("src", src).Wrap(err, "copy to container")
+	}
+
+	return nil
+}
+
+// CopyFromContainer - копирует файл или каталог src контейнера в каталог dst хоста,
+// распаковывая tar-поток демона на лету
+func CopyFromContainer(ctx context.Context, c Container, src, dst string) error {
+	content, err := ExtendClient(c.GetClient()).CopyFromContainer(ctx, c.GetID(), src)
+	if err != nil {
+		return errors.Ctx().Str("name", c.GetName()).Str("src", src).Wrap(err, "copy from container")
+	}
+
+	defer func() {
+		_ = content.Close()
+	}()
+
+	if err = extractTar(content, dst); err != nil {
+		return errors.Ctx().Str("name", c.GetName()).Str("src", src).Wrap(err, "extract container archive")
+	}
+
+	return nil
+}
+
+func writeTar(w io.Writer, src string) error {
+	tw := tar.NewWriter(w)
+	base := filepath.Dir(filepath.Clean(src))
+
+	err := filepath.Walk(
+		src, func(path string, fi os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+
+			var link string
+
+			if fi.Mode()&os.ModeSymlink != 0 {
+				if link, err = os.Readlink(path); err != nil {
+					return errors.Ctx().Str("path", path).Wrap(err, "read symlink")
+				}
+			}
+
+			hdr, err := tar.FileInfoHeader(fi, link)
+			if err != nil {
+				return errors.Ctx().Str("path", path).Wrap(err, "make tar header")
+			}
+
+			rel, err := filepath.Rel(base, path)
+			if err != nil {
+				return errors.Ctx().Str("path", path).Wrap(err, "make archive path")
+			}
+
+			hdr.Name = filepath.ToSlash(rel)
+			hdr.Format = tar.FormatPAX
+
+			if fi.IsDir() {
+				hdr.Name += "/"
+			}
+
+			if err = tw.WriteHeader(hdr); err != nil {
+				return errors.Ctx().Str("path", path).Wrap(err, "write tar header")
+			}
+
+			if !fi.Mode().IsRegular() {
+				return nil
+			}
+
+			return copyFile(tw, path)
+		},
+	)
+	if err != nil {
+		return err
+	}
+
+	if err = tw.Close(); err != nil {
+		return errors.Wrap(err, "close tar writer")
+	}
+
+	return nil
+}
+
+func copyFile(w io.Writer, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return errors.Ctx().Str("path", path).Wrap(err, "open file")
+	}
+
+	defer func() {
+		_ = f.Close()
+	}()
+
+	if _, err = io.Copy(w, f); err != nil {
+		return errors.Ctx().Str("path", path).Wrap(err, "write file to archive")
+	}
+
+	return nil
+}
+
+func extractTar(r io.Reader, dst string) error {
+	tr := tar.NewReader(r)
+	root := filepath.Clean(dst)
+
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+
+		if err != nil {
+			return errors.Wrap(err, "read tar header")
+		}
+
+		name := path.Clean("/" + hdr.Name)[1:]
+		if name == "" {
+			name = "."
+		}
+
+		// родительский каталог разрешается через ссылки, созданные архивом
+		// или существовавшие в dst: запись через ссылку не выходит за root
+		dir, err := resolveInRoot(root, path.Dir(name))
+		if err != nil {
+			return errors.Ctx().Str("name", hdr.Name).Wrap(err, "resolve archive path")
+		}
+
+		target := filepath.Join(dir, path.Base(name))
+		if name == "." {
+			target = root
+		}
+
+		mode := os.FileMode(hdr.Mode).Perm()
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if target, err = resolveInRoot(root, name); err != nil {
+				return errors.Ctx().Str("name", hdr.Name).Wrap(err, "resolve archive path")
+			}
+
+			if err = os.MkdirAll(target, mode|0o700); err != nil {
+				return errors.Ctx().Str("path", target).Wrap(err, "create directory")
+			}
+		case tar.TypeReg:
+			// существующая ссылка заменяется файлом, а не перезаписывается ее цель
+			if err = removeLink(target); err != nil {
+				return err
+			}
+
+			if err = extractFile(tr, target, mode); err != nil {
+				return err
+			}
+		case tar.TypeSymlink:
+			// цель проверяется от разрешенного каталога ссылки, а не от пути в архиве
+			rel, err := filepath.Rel(root, target)
+			if err != nil {
+				return errors.Ctx().Str("path", target).Wrap(err, "make archive path")
+			}
+
+			if err = checkLinkname(filepath.ToSlash(rel), hdr.Linkname); err != nil {
+				return err
+			}
+
+			_ = os.Remove(target)
+
+			if err = os.Symlink(hdr.Linkname, target); err != nil {
+				return errors.Ctx().Str("path", target).Wrap(err, "create symlink")
+			}
+
+			continue
+		case tar.TypeLink:
+			linkDir, err := resolveInRoot(root, path.Dir(path.Clean("/" + hdr.Linkname)[1:]))
+			if err != nil {
+				return errors.Ctx().Str("name", hdr.Linkname).Wrap(err, "resolve hard link target")
+			}
+
+			_ = os.Remove(target)
+
+			if err = os.Link(filepath.Join(linkDir, path.Base(hdr.Linkname)), target); err != nil {
+				return errors.Ctx().Str("path", target).Wrap(err, "create hard link")
+			}
+
+			continue
+		default:
+			continue
+		}
+
+		if err = os.Chtimes(target, hdr.ModTime, hdr.ModTime); err != nil {
+			return errors.Ctx().Str("path", target).Wrap(err, "set modification time")
+		}
+	}
+}
+
+// checkLinkname - проверяет цель символической ссылки name архива: она должна
+// быть относительной и не выходить за каталог назначения
+func checkLinkname(name, linkname string) error {
+	if path.IsAbs(linkname) || filepath.IsAbs(linkname) {
+		return errors.Ctx().Str("name", name).Str("link", linkname).Just(ErrUnsafeArchivePath)
+	}
+
+	if resolved := path.Join(path.Dir(name), linkname); resolved == ".." || strings.HasPrefix(resolved, "../") {
+		return errors.Ctx().Str("name", name).Str("link", linkname).Just(ErrUnsafeArchivePath)
+	}
+
+	return nil
+}
+
+// resolveInRoot - путь name внутри root, в котором разрешены символические
+// ссылки; ссылка или "..", выводящие за root, - ErrUnsafeArchivePath.
+// Несуществующие элементы пути остаются как есть
+func resolveInRoot(root, name string) (string, error) {
+	var (
+		resolved string
+		links    int
+	)
+
+	pending := strings.Split(name, "/")
+
+	for len(pending) != 0 {
+		part := pending[0]
+		pending = pending[1:]
+
+		switch part {
+		case "", ".":
+			continue
+		case "..":
+			if resolved == "" {
+				return "", errors.Ctx().Str("path", name).Just(ErrUnsafeArchivePath)
+			}
+
+			if resolved = path.Dir(resolved); resolved == "." {
+				resolved = ""
+			}
+
+			continue
+		}
+
+		next := path.Join(resolved, part)
+
+		fi, err := os.Lstat(filepath.Join(root, filepath.FromSlash(next)))
+
+		switch {
+		case os.IsNotExist(err):
+			resolved = next
+
+			continue
+		case err != nil:
+			return "", errors.Ctx().Str("path", next).Wrap(err, "stat archive path")
+		case fi.Mode()&os.ModeSymlink == 0:
+			resolved = next
+
+			continue
+		}
+
+		if links++; links > maxArchiveLinks {
+			return "", errors.Ctx().Str("path", name).Just(ErrUnsafeArchivePath)
+		}
+
+		link, err := os.Readlink(filepath.Join(root, filepath.FromSlash(next)))
+		if err != nil {
+			return "", errors.Ctx().Str("path", next).Wrap(err, "read symlink")
+		}
+
+		if path.IsAbs(link) || filepath.IsAbs(link) {
+			return "", errors.Ctx().Str("path", next).Str("link", link).Just(ErrUnsafeArchivePath)
+		}
+
+		pending = append(strings.Split(filepath.ToSlash(link), "/"), pending...)
+	}
+
+	return filepath.Join(root, filepath.FromSlash(resolved)), nil
+}
+
+// removeLink - удаляет символическую ссылку target, если она есть
+func removeLink(target string) error {
+	fi, err := os.Lstat(target)
+	if err != nil || fi.Mode()&os.ModeSymlink == 0 {
+		return nil
+	}
+
+	if err = os.Remove(target); err != nil {
+		return errors.Ctx().Str("path", target).Wrap(err, "remove symlink")
+	}
+
+	return nil
+}
+
+func extractFile(r io.Reader, path string, mode os.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return errors.Ctx().Str("path", path).Wrap(err, "create parent directory")
+	}
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, mode)
+	if err != nil {
+		return errors.Ctx().Str("path", path).Wrap(err, "create file")
+	}
+
+	if _, err = io.Copy(f, r); err != nil {
+		_ = f.Close()
+
+		return errors.Ctx().Str("path", path).Wrap(err, "extract file")
+	}
+
+	if err = f.Close(); err != nil {
+		return errors.Ctx().Str("path", path).Wrap(err, "close file")
+	}
+
+	if err = os.Chmod(path, mode); err != nil {
+		return errors.Ctx().Str("path", path).Wrap(err, "set file mode")
+	}
+
+	return nil
+}
@@ -0,0 +1,131 @@
+package containers
+
+import (
+	"archive/tar"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gopkg.in/gomisc/errors.v1"
+)
+
+func TestExtractTarRejectsSymlinkEscape(t *testing.T) {
+	cases := map[string][]tar.Header{
+		"absolute link": {
+			{Name: "out", Typeflag: tar.TypeSymlink, Linkname: "/etc"},
+		},
+		"escaping link": {
+			{Name: "dir/out", Typeflag: tar.TypeSymlink, Linkname: "../../etc"},
+		},
+		"write through link": {
+			{Name: "self", Typeflag: tar.TypeSymlink, Linkname: "."},
+			{Name: "self/up", Typeflag: tar.TypeSymlink, Linkname: "../x"},
+		},
+	}
+
+	for name, headers := range cases {
+		t.Run(
+			name, func(t *testing.T) {
+				dst := t.TempDir()
+
+				err := extractTar(archive(t, headers...), dst)
+				if !errors.Is(err, ErrUnsafeArchivePath) {
+					t.Fatalf("extractTar: got %v, want %v", err, ErrUnsafeArchivePath)
+				}
+			},
+		)
+	}
+}
+
+func TestExtractTarThroughExistingLink(t *testing.T) {
+	outside := t.TempDir()
+	dst := t.TempDir()
+
+	if err := os.Symlink(outside, filepath.Join(dst, "out")); err != nil {
+		t.Fatalf("Symlink: %v", err)
+	}
+
+	err := extractTar(archive(t, tar.Header{Name: "out/file", Typeflag: tar.TypeReg, Mode: 0o644}), dst)
+	if !errors.Is(err, ErrUnsafeArchivePath) {
+		t.Fatalf("extractTar: got %v, want %v", err, ErrUnsafeArchivePath)
+	}
+
+	if _, err = os.Stat(filepath.Join(outside, "file")); !os.IsNotExist(err) {
+		t.Fatalf("file is written outside the destination: %v", err)
+	}
+}
+
+func TestExtractTarInnerLink(t *testing.T) {
+	dst := t.TempDir()
+
+	err := extractTar(
+		archive(
+			t,
+			tar.Header{Name: "a/", Typeflag: tar.TypeDir, Mode: 0o755},
+			tar.Header{Name: "a/b", Typeflag: tar.TypeSymlink, Linkname: "."},
+			tar.Header{Name: "a/b/f", Typeflag: tar.TypeReg, Mode: 0o644},
+		), dst,
+	)
+	if err != nil {
+		t.Fatalf("extractTar: %v", err)
+	}
+
+	if _, err = os.Stat(filepath.Join(dst, "a", "f")); err != nil {
+		t.Fatalf("file is not extracted through the link: %v", err)
+	}
+}
+
+func BenchmarkCopyTar(b *testing.B) {
+	src := filepath.Join(b.TempDir(), "src")
+	payload := bytes.Repeat([]byte("x"), 64<<10)
+
+	for i := 0; i < 64; i++ {
+		dir := filepath.Join(src, fmt.Sprintf("dir-%d", i%8))
+
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			b.Fatalf("MkdirAll: %v", err)
+		}
+
+		if err := os.WriteFile(filepath.Join(dir, fmt.Sprintf("file-%d", i)), payload, 0o644); err != nil {
+			b.Fatalf("WriteFile: %v", err)
+		}
+	}
+
+	b.SetBytes(int64(64 * len(payload)))
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		pr, pw := io.Pipe()
+
+		go func() {
+			pw.CloseWithError(writeTar(pw, src))
+		}()
+
+		if err := extractTar(pr, b.TempDir()); err != nil {
+			b.Fatalf("extractTar: %v", err)
+		}
+	}
+}
+
+func archive(t *testing.T, headers ...tar.Header) io.Reader {
+	t.Helper()
+
+	var buf bytes.Buffer
+
+	tw := tar.NewWriter(&buf)
+
+	for i := range headers {
+		if err := tw.WriteHeader(&headers[i]); err != nil {
+			t.Fatalf("WriteHeader: %v", err)
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	return &buf
+}
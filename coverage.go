@@ -0,0 +1,159 @@
+package containers
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+
+	"gopkg.in/gomisc/errors.v1"
+)
+
+const (
+	// DefaultCoverageImage - минимальный базовый образ для статически собранного бинарника
+	DefaultCoverageImage = "gcr.io/distroless/static-debian11"
+	// DefaultCoverageStopTimeout - время на штатное завершение процесса,
+	// за которое он успевает записать счетчики покрытия
+	DefaultCoverageStopTimeout = 10 * time.Second
+
+	// CoverageRoot - каталог контейнера с бинарником (bin) и данными покрытия (data)
+	CoverageRoot = "/cover"
+
+	coverageBinDir  = CoverageRoot + "/bin"
+	coverageDataDir = CoverageRoot + "/data"
+)
+
+// coverage - бинарник, собранный с -cover, и каталог хоста для данных покрытия
+type coverage struct {
+	binary string
+	outDir string
+}
+
+// WithCoverage - запускает в контейнере локально собранный бинарник binary
+// (go build -cover, CGO_ENABLED=0) с GOCOVERDIR внутри контейнера; при Stop
+// данные покрытия копируются в каталог хоста outDir, откуда их читает
+// go tool covdata. Без заданного образа используется DefaultCoverageImage.
+// Бинарник доставляется копированием, поэтому работает и с удаленным демоном.
+// Счетчики записываются при штатном завершении процесса, поэтому Stop
+// ожидает его не меньше DefaultCoverageStopTimeout. Данные забираются из
+// остановленного контейнера, поэтому при Autoremove он удаляется после них
+func (c *BaseContainer) WithCoverage(binary, outDir string) *BaseContainer {
+	c.coverage = &coverage{binary: binary, outDir: outDir}
+
+	if c.Image == "" {
+		c.Image = DefaultCoverageImage
+	}
+
+	c.EntryPoint = path.Join(coverageBinDir, filepath.Base(binary))
+	c.Envs = append(c.Envs, "GOCOVERDIR="+coverageDataDir)
+
+	if c.StopTimeout < DefaultCoverageStopTimeout {
+		c.StopTimeout = DefaultCoverageStopTimeout
+	}
+
+	return c
+}
+
+// injectBinary - копирует бинарник и пустой каталог данных покрытия в созданный контейнер
+func (c *BaseContainer) injectBinary(ctx context.Context) error {
+	if c.coverage == nil {
+		return nil
+	}
+
+	data, err := os.ReadFile(c.coverage.binary)
+	if err != nil {
+		return errors.Ctx().Str("binary", c.coverage.binary).Wrap(err, "read coverage binary")
+	}
+
+	var buf bytes.Buffer
+
+	tw := tar.NewWriter(&buf)
+	now := time.Now()
+
+	for _, dir := range []string{"cover/", "cover/bin/", "cover/data/"} {
+		// процесс может работать под непривилегированным пользователем образа
+		hdr := &tar.Header{Typeflag: tar.TypeDir, Name: dir, Mode: 0o777, ModTime: now}
+		if err = tw.WriteHeader(hdr); err != nil {
+			return errors.Wrap(err, "write coverage archive")
+		}
+	}
+
+	hdr := &tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     "cover/bin/" + filepath.Base(c.coverage.binary),
+		Mode:     0o755,
+		Size:     int64(len(data)),
+		ModTime:  now,
+	}
+
+	if err = tw.WriteHeader(hdr); err == nil {
+		_, err = tw.Write(data)
+	}
+
+	if err == nil {
+		err = tw.Close()
+	}
+
+	if err != nil {
+		return errors.Wrap(err, "write coverage archive")
+	}
+
+	if err = c.runtime().CopyToContainer(ctx, c.containerID, "/", &buf); err != nil {
+		return errors.Ctx().Str("container-name", c.GetName()).Wrap(err, "inject coverage binary")
+	}
+
+	return nil
+}
+
+// extractCoverage - копирует данные покрытия остановленного контейнера в каталог
+// хоста и удаляет контейнер, если задан Autoremove; файлы счетчиков уникальны,
+// поэтому данные нескольких контейнеров и запусков накапливаются в одном каталоге
+func (c *BaseContainer) extractCoverage(ctx context.Context) error {
+	if c.coverage == nil || c.containerID == "" {
+		return nil
+	}
+
+	if c.Autoremove {
+		defer func() {
+			if err := c.runtime().ContainerRemove(context.Background(), c.containerID); err != nil {
+				c.LogError(err, "remove coverage container")
+			}
+		}()
+	}
+
+	if err := os.MkdirAll(c.coverage.outDir, 0o755); err != nil {
+		return errors.Ctx().Str("dir", c.coverage.outDir).Wrap(err, "create coverage dir")
+	}
+
+	// временный каталог в outDir: файлы переносятся в пределах одной файловой системы
+	tmp, err := os.MkdirTemp(c.coverage.outDir, ".containers-cover-")
+	if err != nil {
+		return errors.Wrap(err, "create coverage temp dir")
+	}
+
+	defer func() {
+		_ = os.RemoveAll(tmp)
+	}()
+
+	if err = CopyFromContainer(ctx, c, coverageDataDir, tmp); err != nil {
+		return err
+	}
+
+	src := filepath.Join(tmp, path.Base(coverageDataDir))
+
+	entries, err := os.ReadDir(src)
+	if err != nil {
+		return errors.Ctx().Str("dir", src).Wrap(err, "read coverage data")
+	}
+
+	for _, e := range entries {
+		if err = os.Rename(filepath.Join(src, e.Name()), filepath.Join(c.coverage.outDir, e.Name())); err != nil {
+			return errors.Ctx().Str("file", e.Name()).Wrap(err, "move coverage file")
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,82 @@
+package containers
+
+import (
+	"fmt"
+
+	"gopkg.in/gomisc/network.v1/ports"
+)
+
+// DefaultDelvePath - путь к delve внутри контейнера по умолчанию
+const DefaultDelvePath = "/bin/dlv"
+
+var _ DebugStrategy = (*DelveDebug)(nil)
+
+type (
+	// DebugStrategy - стратегия запуска процесса контейнера под отладчиком
+	DebugStrategy interface {
+		// Port возвращает порт отладчика, публикуемый на хост
+		Port() uint16
+		// Command возвращает команду контейнера, обернутую в вызов отладчика
+		Command(cmd []string) []string
+	}
+
+	// DelveDebug - запуск go-процесса под headless delve
+	DelveDebug struct {
+		// Path - путь к delve в контейнере, по умолчанию DefaultDelvePath
+		Path string
+		// Binary - отлаживаемый бинарь, по умолчанию первый элемент команды контейнера
+		Binary string
+		// ListenPort - порт delve, по умолчанию ports.BaseDebugPort
+		ListenPort uint16
+		// Flags - дополнительные флаги delve
+		Flags []string
+	}
+)
+
+// Port - возвращает порт delve
+func (d *DelveDebug) Port() uint16 {
+	if d.ListenPort != 0 {
+		return d.ListenPort
+	}
+
+	return ports.BaseDebugPort
+}
+
+// Command - оборачивает команду контейнера в `dlv exec`. Если Binary задан,
+// он заменяет команду целиком, иначе отлаживается сама команда контейнера;
+// при пустых Binary и команде она возвращается без изменений
+func (d *DelveDebug) Command(cmd []string) []string {
+	binary, args := d.Binary, []string(nil)
+
+	if binary == "" {
+		if len(cmd) == 0 {
+			return cmd
+		}
+
+		binary, args = cmd[0], cmd[1:]
+	}
+
+	path := d.Path
+	if path == "" {
+		path = DefaultDelvePath
+	}
+
+	debugCmd := make([]string, 0, 7+len(d.Flags)+len(args)+1)
+	debugCmd = append(
+		debugCmd,
+		path,
+		fmt.Sprintf("--listen=:%d", d.Port()),
+		"--headless=true",
+		"--api-version=2",
+		"--accept-multiclient",
+	)
+	debugCmd = append(debugCmd, d.Flags...)
+	debugCmd = append(debugCmd, "exec", binary)
+
+	if len(args) != 0 {
+		debugCmd = append(debugCmd, "--")
+		debugCmd = append(debugCmd, args...)
+	}
+
+	return debugCmd
+}
@@ -0,0 +1,179 @@
+package containers
+
+import (
+	"path"
+	"strings"
+
+	"gopkg.in/gomisc/errors.v1"
+)
+
+const (
+	// AllGPUs - значение GPURequest.Count: контейнеру доступны все GPU хоста
+	AllGPUs = -1
+	// GPUCapability - возможность драйвера по умолчанию, как у docker run --gpus
+	GPUCapability = "gpu"
+	// DefaultDevicePermissions - права cgroup на устройство по умолчанию
+	DefaultDevicePermissions = "rwm"
+
+	// ErrInvalidDevice - описание устройства или запроса GPU противоречиво
+	ErrInvalidDevice = errors.Const("invalid device spec")
+)
+
+type (
+	// Device - устройство хоста, пробрасываемое в контейнер (docker run --device)
+	Device struct {
+		// HostPath - путь устройства на хосте
+		HostPath string `json:"host_path" yaml:"host_path"`
+		// ContainerPath - путь в контейнере, пусто - HostPath
+		ContainerPath string `json:"container_path,omitempty" yaml:"container_path,omitempty"`
+		// Permissions - права cgroup из r, w, m; пусто - DefaultDevicePermissions
+		Permissions string `json:"permissions,omitempty" yaml:"permissions,omitempty"`
+	}
+
+	// GPURequest - запрос GPU у драйвера устройств демона (docker run --gpus);
+	// на хосте демона должен быть установлен драйвер, например NVIDIA Container Toolkit
+	GPURequest struct {
+		// Driver - драйвер устройств, пусто - выбирает демон
+		Driver string `json:"driver,omitempty" yaml:"driver,omitempty"`
+		// Count - число GPU, AllGPUs - все; не задается вместе с DeviceIDs
+		Count int `json:"count,omitempty" yaml:"count,omitempty"`
+		// DeviceIDs - индексы или UUID конкретных GPU
+		DeviceIDs []string `json:"device_ids,omitempty" yaml:"device_ids,omitempty"`
+		// Capabilities - требуемые возможности драйвера (compute, utility,
+		// video, etc), пусто - GPUCapability
+		Capabilities []string `json:"capabilities,omitempty" yaml:"capabilities,omitempty"`
+	}
+)
+
+// ParseDevice - разбирает устройство в форме host[:container[:permissions]],
+// как в docker run --device
+func ParseDevice(s string) (Device, error) {
+	parts := strings.Split(s, ":")
+	if len(parts) > 3 {
+		return Device{}, errors.Ctx().Str("device", s).Just(ErrInvalidDevice)
+	}
+
+	d := Device{HostPath: parts[0]}
+
+	if len(parts) > 1 {
+		d.ContainerPath = parts[1]
+	}
+
+	if len(parts) > 2 {
+		d.Permissions = parts[2]
+	}
+
+	if err := d.Validate(); err != nil {
+		return Device{}, err
+	}
+
+	return d, nil
+}
+
+// Validate - проверяет пути и права устройства
+func (d Device) Validate() error {
+	ctx := errors.Ctx().Str("host-path", d.HostPath).Str("container-path", d.ContainerPath)
+
+	switch {
+	case !path.IsAbs(d.HostPath):
+		return ctx.Str("reason", "host path is not absolute").Just(ErrInvalidDevice)
+	case d.ContainerPath != "" && !path.IsAbs(d.ContainerPath):
+		return ctx.Str("reason", "container path is not absolute").Just(ErrInvalidDevice)
+	}
+
+	for _, p := range d.Permissions {
+		if !strings.ContainsRune(DefaultDevicePermissions, p) || strings.Count(d.Permissions, string(p)) > 1 {
+			return ctx.Str("permissions", d.Permissions).Just(ErrInvalidDevice)
+		}
+	}
+
+	return nil
+}
+
+// Target - путь устройства в контейнере
+func (d Device) Target() string {
+	if d.ContainerPath == "" {
+		return d.HostPath
+	}
+
+	return d.ContainerPath
+}
+
+// Perms - права cgroup на устройство с учетом значения по умолчанию
+func (d Device) Perms() string {
+	if d.Permissions == "" {
+		return DefaultDevicePermissions
+	}
+
+	return d.Permissions
+}
+
+// String - устройство в форме host:container:permissions
+func (d Device) String() string {
+	return d.HostPath + ":" + d.Target() + ":" + d.Perms()
+}
+
+// Validate - проверяет запрос GPU
+func (r GPURequest) Validate() error {
+	ctx := errors.Ctx().Str("driver", r.Driver).Int("count", r.Count).Strings("device-ids", r.DeviceIDs)
+
+	switch {
+	case r.Count < AllGPUs:
+		return ctx.Str("reason", "negative count").Just(ErrInvalidDevice)
+	case r.Count != 0 && len(r.DeviceIDs) != 0:
+		return ctx.Str("reason", "count and device ids are mutually exclusive").Just(ErrInvalidDevice)
+	case r.Count == 0 && len(r.DeviceIDs) == 0:
+		return ctx.Str("reason", "neither count nor device ids are set").Just(ErrInvalidDevice)
+	}
+
+	return nil
+}
+
+// Caps - требуемые возможности драйвера с учетом значения по умолчанию
+func (r GPURequest) Caps() []string {
+	if len(r.Capabilities) == 0 {
+		return []string{GPUCapability}
+	}
+
+	return r.Capabilities
+}
+
+// GetDevices - возвращает устройства хоста, пробрасываемые в контейнер
+func (c *BaseContainer) GetDevices() []Device {
+	return c.Devices
+}
+
+// GetGPUs - возвращает запросы GPU контейнера
+func (c *BaseContainer) GetGPUs() []GPURequest {
+	return c.GPUs
+}
+
+// WithGPUs - запрашивает для контейнера GPU с идентификаторами ids, без
+// идентификаторов - все GPU хоста (docker run --gpus all)
+func (c *BaseContainer) WithGPUs(ids ...string) *BaseContainer {
+	req := GPURequest{DeviceIDs: ids}
+	if len(ids) == 0 {
+		req.Count = AllGPUs
+	}
+
+	c.GPUs = append(c.GPUs, req)
+
+	return c
+}
+
+// checkDevices - проверяет устройства и запросы GPU до создания контейнера
+func (c *BaseContainer) checkDevices() error {
+	for _, d := range c.Devices {
+		if err := d.Validate(); err != nil {
+			return errors.Ctx().Str("container-name", c.GetName()).Wrap(err, "check devices")
+		}
+	}
+
+	for _, r := range c.GPUs {
+		if err := r.Validate(); err != nil {
+			return errors.Ctx().Str("container-name", c.GetName()).Wrap(err, "check gpu requests")
+		}
+	}
+
+	return nil
+}
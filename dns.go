@@ -0,0 +1,63 @@
+package containers
+
+import (
+	"net"
+	"strings"
+
+	"gopkg.in/gomisc/errors.v1"
+)
+
+const (
+	// HostGatewayName - имя хоста в контейнере, под которым WithHostGateway
+	// делает доступными сервисы хоста
+	HostGatewayName = "host.docker.internal"
+
+	// ErrInvalidDNS - адрес DNS сервера не является IP адресом
+	ErrInvalidDNS = errors.Const("invalid dns server address")
+	// ErrInvalidExtraHost - запись ExtraHosts не в форме name:ip
+	ErrInvalidExtraHost = errors.Const("invalid extra host entry")
+)
+
+// GetDNS - возвращает адреса DNS серверов контейнера
+func (c *BaseContainer) GetDNS() []string {
+	return c.DNS
+}
+
+// GetDNSSearch - возвращает домены поиска DNS контейнера
+func (c *BaseContainer) GetDNSSearch() []string {
+	return c.DNSSearch
+}
+
+// WithHostGateway - добавляет записи /etc/hosts, разрешающие names (по
+// умолчанию HostGatewayName) в адрес хоста; так контейнер обращается
+// к сервисам, запущенным на хосте, без скриптов в точке входа
+func (c *BaseContainer) WithHostGateway(names ...string) *BaseContainer {
+	if len(names) == 0 {
+		names = []string{HostGatewayName}
+	}
+
+	for _, name := range names {
+		c.ExtraHosts = append(c.ExtraHosts, name+":"+HostGateway)
+	}
+
+	return c
+}
+
+// checkDNS - проверяет адреса DNS серверов и записи ExtraHosts до создания
+// контейнера: демон сообщает о них невнятной ошибкой создания
+func (c *BaseContainer) checkDNS() error {
+	for _, server := range c.DNS {
+		if net.ParseIP(server) == nil {
+			return errors.Ctx().Str("container-name", c.GetName()).Str("dns", server).Just(ErrInvalidDNS)
+		}
+	}
+
+	for _, entry := range c.ExtraHosts {
+		name, ip, ok := strings.Cut(entry, ":")
+		if !ok || name == "" || (ip != HostGateway && net.ParseIP(ip) == nil) {
+			return errors.Ctx().Str("container-name", c.GetName()).Str("extra-host", entry).Just(ErrInvalidExtraHost)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,151 @@
+package containers
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+)
+
+// Статусы проверок окружения
+const (
+	CheckOK   CheckStatus = "ok"
+	CheckWarn CheckStatus = "warn"
+	CheckFail CheckStatus = "fail"
+)
+
+type (
+	// CheckStatus - результат проверки окружения
+	CheckStatus string
+
+	// DoctorCheck - результат отдельной проверки окружения
+	DoctorCheck struct {
+		Name    string      `json:"name"`
+		Status  CheckStatus `json:"status"`
+		Message string      `json:"message,omitempty"`
+	}
+
+	// DoctorReport - отчет о проверке окружения
+	DoctorReport struct {
+		Checks []DoctorCheck `json:"checks"`
+	}
+
+	// Diagnoser - адаптер, умеющий проверять специфичные для своей среды
+	// исполнения проблемы (доступность демона, версия API, место на диске, etc)
+	Diagnoser interface {
+		Diagnose(ctx context.Context) []DoctorCheck
+	}
+)
+
+// Doctor - проверяет типичные проблемы окружения: доступность и версию демона,
+// свободное место, исчерпание пула подсетей, конфликты зарезервированных сетей,
+// поддержку ограничений ресурсов
+func Doctor(ctx context.Context, cli Client) *DoctorReport {
+	report := &DoctorReport{}
+
+	if d, ok := cli.(Diagnoser); ok {
+		report.Checks = append(report.Checks, d.Diagnose(ctx)...)
+	}
+
+	report.Checks = append(
+		report.Checks,
+		CheckReservedNetworks(ctx, cli, reservedNetworksVar),
+		checkSubnetPool(cli),
+		checkCgroup(ctx, cli),
+	)
+
+	return report
+}
+
+// OK - признак отсутствия проваленных проверок
+func (r *DoctorReport) OK() bool {
+	for _, check := range r.Checks {
+		if check.Status == CheckFail {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Print - выводит отчет в человекочитаемом виде
+func (r *DoctorReport) Print(w io.Writer) {
+	for _, check := range r.Checks {
+		_, _ = fmt.Fprintf(w, "[%-4s] %s", check.Status, check.Name)
+
+		if check.Message != "" {
+			_, _ = fmt.Fprintf(w, ": %s", check.Message)
+		}
+
+		_, _ = fmt.Fprintln(w)
+	}
+}
+
+func checkSubnetPool(cli Client) DoctorCheck {
+	check := DoctorCheck{Name: "subnet pool", Status: CheckOK}
+
+	subnet, err := cli.NextSubnet()
+
+	switch {
+	case err != nil:
+		check.Status, check.Message = CheckFail, err.Error()
+	case subnet == nil:
+		check.Status, check.Message = CheckFail, "subnet pool exhausted"
+	default:
+		check.Message = "next free subnet " + subnet.String()
+
+		if r, ok := cli.(SubnetReleaser); ok {
+			r.ReleaseSubnet(subnet)
+		}
+	}
+
+	return check
+}
+
+// CheckReservedNetworks - проверяет корректность списка зарезервированных сетей
+// из переменной окружения envar и их пересечения с существующими сетями
+func CheckReservedNetworks(ctx context.Context, cli Client, envar string) DoctorCheck {
+	check := DoctorCheck{Name: "reserved networks " + envar, Status: CheckOK}
+
+	reserved := os.Getenv(envar)
+	if reserved == "" {
+		check.Message = envar + " not set"
+
+		return check
+	}
+
+	used, err := cli.NetworkList(ctx)
+	if err != nil {
+		check.Status, check.Message = CheckWarn, "list networks: "+err.Error()
+
+		return check
+	}
+
+	var problems []string
+
+	for _, cidr := range strings.Split(reserved, ",") {
+		_, nw, parseErr := net.ParseCIDR(strings.TrimSpace(cidr))
+		if parseErr != nil {
+			check.Status = CheckFail
+			problems = append(problems, "invalid cidr "+cidr)
+
+			continue
+		}
+
+		for _, u := range used {
+			if nw.Contains(u.IP) || u.Contains(nw.IP) {
+				if check.Status == CheckOK {
+					check.Status = CheckWarn
+				}
+
+				problems = append(problems, nw.String()+" overlaps existing network "+u.String())
+			}
+		}
+	}
+
+	check.Message = strings.Join(problems, "; ")
+
+	return check
+}
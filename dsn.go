@@ -0,0 +1,74 @@
+package containers
+
+import (
+	"net/url"
+	"strings"
+
+	"gopkg.in/gomisc/network.v1/ports"
+)
+
+// Endpoint - возвращает адрес порта контейнера с учетом расположения
+// вызывающего процесса: внутри контейнера - адрес в сети докера, иначе - на хосте
+func (c *BaseContainer) Endpoint(name ports.PortName) string {
+	return endpointOf(c, name)
+}
+
+// Env - возвращает значение переменной окружения контейнера
+func (c *BaseContainer) Env(key string) string {
+	for _, env := range c.Envs {
+		if k, v, ok := strings.Cut(env, "="); ok && k == key {
+			return v
+		}
+	}
+
+	return ""
+}
+
+// PostgresDSN - строка подключения к postgres, учетные данные берутся из
+// переменных POSTGRES_USER, POSTGRES_PASSWORD и POSTGRES_DB контейнера
+func (c *BaseContainer) PostgresDSN(port ports.PortName) string {
+	user := c.envOr("POSTGRES_USER", "postgres")
+
+	dsn := url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(user, c.Env("POSTGRES_PASSWORD")),
+		Host:     c.Endpoint(port),
+		Path:     "/" + c.envOr("POSTGRES_DB", user),
+		RawQuery: "sslmode=disable",
+	}
+
+	return dsn.String()
+}
+
+// AMQPURL - адрес подключения к rabbitmq, учетные данные берутся из переменных
+// RABBITMQ_DEFAULT_USER, RABBITMQ_DEFAULT_PASS и RABBITMQ_DEFAULT_VHOST контейнера
+func (c *BaseContainer) AMQPURL(port ports.PortName) string {
+	// vhost - один сегмент пути: vhost по умолчанию "/" кодируется как %2F
+	vhost := c.envOr("RABBITMQ_DEFAULT_VHOST", "/")
+
+	dsn := url.URL{
+		Scheme: "amqp",
+		User: url.UserPassword(
+			c.envOr("RABBITMQ_DEFAULT_USER", "guest"),
+			c.envOr("RABBITMQ_DEFAULT_PASS", "guest"),
+		),
+		Host:    c.Endpoint(port),
+		Path:    "/" + vhost,
+		RawPath: "/" + url.PathEscape(vhost),
+	}
+
+	return dsn.String()
+}
+
+// RedisAddr - адрес redis в формате host:port
+func (c *BaseContainer) RedisAddr(port ports.PortName) string {
+	return c.Endpoint(port)
+}
+
+func (c *BaseContainer) envOr(key, def string) string {
+	if v := c.Env(key); v != "" {
+		return v
+	}
+
+	return def
+}
@@ -0,0 +1,74 @@
+package containers
+
+import (
+	"context"
+	"strings"
+
+	"gopkg.in/gomisc/errors.v1"
+)
+
+const (
+	// DefaultBinfmtImage - образ, который проверяет и регистрирует эмуляторы
+	// qemu в binfmt_misc ядра демона; запускается привилегированным
+	DefaultBinfmtImage = "tonistiigi/binfmt:qemu-v7.0.0"
+
+	// ErrEmulationUnavailable - платформа образа отличается от архитектуры
+	// демона, а эмуляция этой платформы (binfmt) не зарегистрирована
+	ErrEmulationUnavailable = errors.Const("platform emulation is not available")
+)
+
+// Emulator - адаптер, умеющий проверять (и регистрировать) эмуляцию
+// платформ, отличных от архитектуры демона. Без эмуляции контейнер чужой
+// платформы падает уже после старта с невнятным "exec format error"
+type Emulator interface {
+	// EnsureEmulation - проверяет, что демон исполняет образы платформы
+	// platform (os/arch[/variant]); возвращает ErrEmulationUnavailable, если нет
+	EnsureEmulation(ctx context.Context, platform string) error
+}
+
+// NeedsEmulation - образы платформы platform исполняются демоном только
+// под эмуляцией: ОС linux, архитектура отличается от архитектуры демона
+func NeedsEmulation(info *DaemonInfo, platform string) bool {
+	parts := strings.SplitN(platform, "/", 3)
+	if len(parts) < 2 || parts[0] != "linux" || info == nil || info.Architecture == "" {
+		return false
+	}
+
+	return NormalizeArch(parts[1]) != NormalizeArch(info.Architecture)
+}
+
+// NormalizeArch - имя архитектуры в терминах GOARCH (uname -m демона
+// сообщает x86_64, aarch64, etc)
+func NormalizeArch(arch string) string {
+	switch arch {
+	case "x86_64", "x86-64":
+		return "amd64"
+	case "aarch64", "armv8", "armv8l":
+		return "arm64"
+	case "armv7l", "armv7", "armv6l", "armhf":
+		return "arm"
+	case "i386", "i686":
+		return "386"
+	default:
+		return arch
+	}
+}
+
+// checkPlatform - проверяет до создания контейнера, что демон исполняет
+// образы его платформы, если адаптер умеет это проверить
+func (c *BaseContainer) checkPlatform(ctx context.Context) error {
+	if c.Platform == "" {
+		return nil
+	}
+
+	emulator, ok := c.client.(Emulator)
+	if !ok {
+		return nil
+	}
+
+	if err := emulator.EnsureEmulation(ctx, c.Platform); err != nil {
+		return errors.Ctx().Str("container-name", c.GetName()).Wrap(err, "check platform")
+	}
+
+	return nil
+}
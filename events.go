@@ -0,0 +1,85 @@
+package containers
+
+import (
+	"strconv"
+	"time"
+)
+
+// Типы объектов событий среды исполнения
+const (
+	EventTypeContainer = "container"
+	EventTypeNetwork   = "network"
+)
+
+// Действия событий среды исполнения
+const (
+	EventCreate       = "create"
+	EventStart        = "start"
+	EventDie          = "die"
+	EventOOM          = "oom"
+	EventKill         = "kill"
+	EventStop         = "stop"
+	EventPause        = "pause"
+	EventUnpause      = "unpause"
+	EventDestroy      = "destroy"
+	EventHealthStatus = "health_status"
+	EventConnect      = "connect"
+	EventDisconnect   = "disconnect"
+)
+
+type (
+	// ContainerEvent - событие среды исполнения, относящееся к контейнеру или сети
+	ContainerEvent struct {
+		// Type - тип объекта (EventTypeContainer, EventTypeNetwork)
+		Type string `json:"type" yaml:"type"`
+		// Action - действие без уточнения (EventDie, EventHealthStatus, etc)
+		Action string `json:"action" yaml:"action"`
+		// Detail - уточнение действия, например статус для EventHealthStatus
+		Detail string `json:"detail,omitempty" yaml:"detail,omitempty"`
+		// ID - идентификатор объекта, Name - его имя
+		ID   string `json:"id" yaml:"id"`
+		Name string `json:"name,omitempty" yaml:"name,omitempty"`
+		// Attributes - атрибуты события (метки контейнера, exitCode, container для сетей)
+		Attributes map[string]string `json:"attributes,omitempty" yaml:"attributes,omitempty"`
+		Time       time.Time         `json:"time" yaml:"time"`
+	}
+
+	// EventFilter - условие отбора событий: условия с одним ключом объединяются
+	// по ИЛИ, с разными - по И
+	EventFilter struct {
+		Key   string
+		Value string
+	}
+)
+
+// FilterType - отбор событий по типу объекта
+func FilterType(t string) EventFilter {
+	return EventFilter{Key: "type", Value: t}
+}
+
+// FilterAction - отбор событий по действию
+func FilterAction(action string) EventFilter {
+	return EventFilter{Key: "event", Value: action}
+}
+
+// FilterContainer - отбор событий контейнера по идентификатору или имени
+func FilterContainer(idOrName string) EventFilter {
+	return EventFilter{Key: "container", Value: idOrName}
+}
+
+// FilterNetwork - отбор событий сети по идентификатору или имени
+func FilterNetwork(idOrName string) EventFilter {
+	return EventFilter{Key: "network", Value: idOrName}
+}
+
+// FilterLabel - отбор событий по метке "key" или "key=value"
+func FilterLabel(label string) EventFilter {
+	return EventFilter{Key: "label", Value: label}
+}
+
+// ExitCode - код завершения из события EventDie
+func (e ContainerEvent) ExitCode() (int, bool) {
+	code, err := strconv.Atoi(e.Attributes["exitCode"])
+
+	return code, err == nil
+}
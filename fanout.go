@@ -0,0 +1,417 @@
+package containers
+
+import (
+	"context"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"gopkg.in/gomisc/errors.v1"
+)
+
+// Политики потребителя, не успевающего за выводом контейнера
+const (
+	// SlowConsumerDrop - фрагменты вывода, не поместившиеся в очередь
+	// потребителя, отбрасываются (по умолчанию)
+	SlowConsumerDrop SlowConsumerPolicy = "drop"
+	// SlowConsumerBlock - трансляция ждет освобождения очереди; задерживает
+	// вывод всех потребителей контейнера, подходит для проверок, которым
+	// нельзя терять строки
+	SlowConsumerBlock SlowConsumerPolicy = "block"
+	// SlowConsumerDisconnect - потребитель с переполненной очередью
+	// отключается с ошибкой ErrSlowLogConsumer
+	SlowConsumerDisconnect SlowConsumerPolicy = "disconnect"
+)
+
+const (
+	// DefaultLogConsumerBuffer - длина очереди потребителя во фрагментах вывода
+	DefaultLogConsumerBuffer = 256
+
+	// ErrSlowLogConsumer - потребитель отключен из-за переполнения очереди
+	ErrSlowLogConsumer = errors.Const("log consumer is too slow")
+	// ErrLogConsumerDetached - потребитель отключен до конца вывода
+	ErrLogConsumerDetached = errors.Const("log consumer detached")
+
+	// logFlushTimeout - срок записи очередей потребителей при остановке контейнера
+	logFlushTimeout = 5 * time.Second
+)
+
+type (
+	// SlowConsumerPolicy - поведение трансляции при переполненной очереди потребителя
+	SlowConsumerPolicy string
+
+	// LogConsumer - получатель вывода контейнера со своей очередью: медленный
+	// получатель не задерживает остальных (кроме политики SlowConsumerBlock)
+	LogConsumer struct {
+		// Stdout, Stderr - получатели потоков вывода, nil - поток не нужен
+		Stdout io.Writer
+		Stderr io.Writer
+		// Buffer - длина очереди во фрагментах вывода (0 - DefaultLogConsumerBuffer)
+		Buffer int
+		// Policy - поведение при переполненной очереди (пусто - SlowConsumerDrop)
+		Policy SlowConsumerPolicy
+	}
+
+	// LogFanout - раздает один поток вывода контейнера нескольким
+	// потребителям: проверке готовности, файлу и проверкам теста достаточно
+	// одного подключения к демону. Stdout и Stderr передаются StreamLogs
+	LogFanout struct {
+		// mu - запись держит блокировку чтения, изменения состава - записи
+		mu        sync.RWMutex
+		consumers map[*LogSubscription]struct{}
+		closed    bool
+		// abort закрывается, если очереди не записаны за срок Close:
+		// снимает ожидание потребителей SlowConsumerBlock
+		abortMu   sync.Mutex
+		abort     chan struct{}
+		abortOnce sync.Once
+	}
+
+	// LogSubscription - подключение потребителя к LogFanout
+	LogSubscription struct {
+		consumer LogConsumer
+		queue    chan logChunk
+		// stop закрывается при отключении, done - по завершении записи
+		stop     chan struct{}
+		done     chan struct{}
+		stopOnce sync.Once
+		err      error
+		dropped  atomic.Int64
+	}
+
+	logChunk struct {
+		stderr bool
+		data   []byte
+	}
+
+	fanoutStream struct {
+		fanout *LogFanout
+		stderr bool
+	}
+)
+
+// NewLogFanout - создает раздачу вывода без потребителей
+func NewLogFanout() *LogFanout {
+	f := &LogFanout{}
+	f.init()
+
+	return f
+}
+
+// Stdout - поток записи стандартного вывода
+func (f *LogFanout) Stdout() io.Writer {
+	return &fanoutStream{fanout: f}
+}
+
+// Stderr - поток записи вывода ошибок
+func (f *LogFanout) Stderr() io.Writer {
+	return &fanoutStream{fanout: f, stderr: true}
+}
+
+// Attach - подключает потребителя; он получает вывод, записанный после
+// подключения. Потребитель закрытой раздачи сразу завершен
+func (f *LogFanout) Attach(consumer LogConsumer) *LogSubscription {
+	if consumer.Buffer <= 0 {
+		consumer.Buffer = DefaultLogConsumerBuffer
+	}
+
+	if consumer.Policy == "" {
+		consumer.Policy = SlowConsumerDrop
+	}
+
+	s := &LogSubscription{
+		consumer: consumer,
+		queue:    make(chan logChunk, consumer.Buffer),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.init()
+
+	if f.closed {
+		close(s.queue)
+	} else {
+		f.consumers[s] = struct{}{}
+	}
+
+	go s.run()
+
+	return s
+}
+
+// Detach - отключает потребителя без записи его очереди
+func (f *LogFanout) Detach(s *LogSubscription) {
+	// остановка снимает блокировку записи в очередь SlowConsumerBlock,
+	// поэтому выполняется до захвата f.mu
+	s.cancel(ErrLogConsumerDetached)
+
+	f.mu.Lock()
+	delete(f.consumers, s)
+	f.mu.Unlock()
+}
+
+// Close - завершает раздачу и ожидает записи очередей потребителей не
+// дольше ctx; последующий вывод отбрасывается
+func (f *LogFanout) Close(ctx context.Context) error {
+	flushed := make(chan struct{})
+	defer close(flushed)
+
+	// запись, ожидающая очереди SlowConsumerBlock, держит f.mu
+	go func() {
+		select {
+		case <-ctx.Done():
+			f.abortOnce.Do(func() { close(f.aborted()) })
+		case <-flushed:
+		}
+	}()
+
+	f.mu.Lock()
+	f.init()
+
+	if f.closed {
+		f.mu.Unlock()
+
+		return nil
+	}
+
+	f.closed = true
+	subs := make([]*LogSubscription, 0, len(f.consumers))
+
+	for s := range f.consumers {
+		close(s.queue)
+		subs = append(subs, s)
+	}
+
+	f.consumers = nil
+	f.mu.Unlock()
+
+	for _, s := range subs {
+		select {
+		case <-s.done:
+		case <-ctx.Done():
+			return errors.Wrap(ctx.Err(), "flush log consumers")
+		}
+	}
+
+	return nil
+}
+
+func (f *LogFanout) write(stderr bool, p []byte) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	if f.closed || len(f.consumers) == 0 {
+		return
+	}
+
+	// вызывающий может переиспользовать p, а очереди читаются позже
+	chunk := logChunk{stderr: stderr, data: append([]byte(nil), p...)}
+	abort := f.aborted()
+
+	for s := range f.consumers {
+		if s.wants(stderr) {
+			s.deliver(chunk, abort)
+		}
+	}
+}
+
+// init - подготавливает LogFanout, созданный без NewLogFanout. Вызывается под f.mu
+func (f *LogFanout) init() {
+	if f.consumers == nil && !f.closed {
+		f.consumers = make(map[*LogSubscription]struct{})
+	}
+}
+
+// aborted - канал, закрываемый по истечении срока Close; f.mu для него не
+// нужна, ее может держать ожидающая запись
+func (f *LogFanout) aborted() chan struct{} {
+	f.abortMu.Lock()
+	defer f.abortMu.Unlock()
+
+	if f.abort == nil {
+		f.abort = make(chan struct{})
+	}
+
+	return f.abort
+}
+
+func (s *fanoutStream) Write(p []byte) (int, error) {
+	s.fanout.write(s.stderr, p)
+
+	return len(p), nil
+}
+
+// Done - закрывается, когда потребитель получил весь вывод или отключен
+func (s *LogSubscription) Done() <-chan struct{} {
+	return s.done
+}
+
+// Err - причина отключения потребителя: ErrSlowLogConsumer,
+// ErrLogConsumerDetached или ошибка записи; nil - вывод получен полностью
+func (s *LogSubscription) Err() error {
+	select {
+	case <-s.stop:
+		return s.err
+	default:
+		return nil
+	}
+}
+
+// Dropped - число байт вывода, отброшенных политикой SlowConsumerDrop или
+// при истечении срока Close
+func (s *LogSubscription) Dropped() int64 {
+	return s.dropped.Load()
+}
+
+// wants - у потребителя есть получатель потока
+func (s *LogSubscription) wants(stderr bool) bool {
+	if stderr {
+		return s.consumer.Stderr != nil
+	}
+
+	return s.consumer.Stdout != nil
+}
+
+// deliver - помещает фрагмент в очередь по политике потребителя; abort
+// прерывает ожидание очереди SlowConsumerBlock. Вызывается под f.mu.RLock
+func (s *LogSubscription) deliver(chunk logChunk, abort <-chan struct{}) {
+	select {
+	case <-s.stop:
+		return
+	default:
+	}
+
+	if s.consumer.Policy == SlowConsumerBlock {
+		select {
+		case s.queue <- chunk:
+		case <-s.stop:
+		case <-abort:
+			s.dropped.Add(int64(len(chunk.data)))
+		}
+
+		return
+	}
+
+	select {
+	case s.queue <- chunk:
+	default:
+		if s.consumer.Policy == SlowConsumerDisconnect {
+			s.cancel(
+				errors.Ctx().Int("buffer", s.consumer.Buffer).Just(ErrSlowLogConsumer),
+			)
+
+			return
+		}
+
+		s.dropped.Add(int64(len(chunk.data)))
+	}
+}
+
+// run - пишет очередь потребителя до ее закрытия или отключения
+func (s *LogSubscription) run() {
+	defer close(s.done)
+
+	for {
+		select {
+		case chunk, ok := <-s.queue:
+			if !ok {
+				return
+			}
+
+			w := s.consumer.Stdout
+			if chunk.stderr {
+				w = s.consumer.Stderr
+			}
+
+			if _, err := w.Write(chunk.data); err != nil {
+				s.cancel(errors.Wrap(err, "write log consumer"))
+
+				return
+			}
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+// cancel - отключает потребителя с причиной err, повторный вызов ничего не делает
+func (s *LogSubscription) cancel(err error) {
+	s.stopOnce.Do(
+		func() {
+			s.err = err
+			close(s.stop)
+		},
+	)
+}
+
+// AddLogConsumer - подключает потребителя к трансляции вывода контейнера;
+// все потребители делят одно подключение к демону. Потребитель получает
+// вывод с момента подключения до Stop
+func (c *BaseContainer) AddLogConsumer(consumer LogConsumer) *LogSubscription {
+	c.mutex.Lock()
+	s := c.logFanout().Attach(consumer)
+	running := c.containerID != ""
+	c.mutex.Unlock()
+
+	if running {
+		c.attachLogs()
+	}
+
+	return s
+}
+
+// RemoveLogConsumer - отключает потребителя от трансляции вывода контейнера
+func (c *BaseContainer) RemoveLogConsumer(s *LogSubscription) {
+	c.mutex.Lock()
+	fanout := c.fanout
+	c.mutex.Unlock()
+
+	if fanout != nil {
+		fanout.Detach(s)
+	} else {
+		s.cancel(ErrLogConsumerDetached)
+	}
+}
+
+// joinWriters - поток записи в оба потока, nil поток пропускается
+func joinWriters(first, second io.Writer) io.Writer {
+	if second == nil {
+		return first
+	}
+
+	return io.MultiWriter(first, second)
+}
+
+// logFanout - раздача вывода контейнера, создается при первом обращении.
+// Вызывается под c.mutex
+func (c *BaseContainer) logFanout() *LogFanout {
+	if c.fanout == nil {
+		c.fanout = NewLogFanout()
+	}
+
+	return c.fanout
+}
+
+// closeLogConsumers - завершает потребителей вывода после остановки,
+// дождавшись записи их очередей; следующий запуск получает новую раздачу
+func (c *BaseContainer) closeLogConsumers() {
+	c.mutex.Lock()
+	fanout := c.fanout
+	c.fanout = nil
+	c.mutex.Unlock()
+
+	if fanout == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), logFlushTimeout)
+	defer cancel()
+
+	if err := fanout.Close(ctx); err != nil {
+		c.LogError(err, "close log consumers")
+	}
+}
@@ -3,9 +3,11 @@ module gopkg.in/gomisc/containers.v1
 go 1.20
 
 require (
+	go.uber.org/goleak v1.2.1
 	gopkg.in/gomisc/envs.v1 v1.2.1
 	gopkg.in/gomisc/errors.v1 v1.3.2
 	gopkg.in/gomisc/network.v1 v1.2.1
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
@@ -44,7 +46,7 @@ require (
 
 require (
 	github.com/Microsoft/go-winio v0.5.2 // indirect
-	github.com/docker/distribution v2.8.1+incompatible // indirect
+	github.com/docker/distribution v2.8.1+incompatible
 	github.com/docker/docker v20.10.17+incompatible
 	github.com/docker/go-connections v0.4.0
 	github.com/docker/go-units v0.4.0 // indirect
@@ -52,7 +54,7 @@ require (
 	github.com/moby/term v0.0.0-20210619224110-3f7ff695adc6 // indirect
 	github.com/morikuni/aec v1.0.0 // indirect
 	github.com/opencontainers/go-digest v1.0.0 // indirect
-	github.com/opencontainers/image-spec v1.0.3-0.20211202183452-c5a74bcca799 // indirect
+	github.com/opencontainers/image-spec v1.0.3-0.20211202183452-c5a74bcca799
 	github.com/sirupsen/logrus v1.9.0 // indirect
 	golang.org/x/time v0.0.0-20220722155302-e5dcc9cfc0b9 // indirect
 	gotest.tools/v3 v3.3.0 // indirect
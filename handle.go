@@ -0,0 +1,272 @@
+package containers
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"gopkg.in/gomisc/errors.v1"
+)
+
+// ErrInvalidHandle - пустая ссылка на контейнер (контейнер не создан)
+const ErrInvalidHandle = errors.Const("invalid container handle")
+
+type (
+	// Handle - ссылка на созданный контейнер: полный идентификатор вместе
+	// с именем, сессией и клиентом, которым контейнер создан. Ссылку
+	// возвращают только CreateContainer, LookupContainer и
+	// BaseContainer.Handle, поэтому в операции не попадает усеченный или
+	// устаревший идентификатор, а операция всегда выполняется клиентом,
+	// который знает контейнер
+	Handle struct {
+		id      string
+		name    string
+		session string
+		client  ExtendedClient
+	}
+
+	// SessionClient - клиент, сообщающий идентификатор своей сессии,
+	// которым помечаются созданные им объекты
+	SessionClient interface {
+		Session() string
+	}
+)
+
+// CreateContainer - создает контейнер и возвращает ссылку на него
+func CreateContainer(ctx context.Context, cli Client, c Container) (Handle, error) {
+	id, err := cli.ContainerCreate(ctx, c)
+	if err != nil {
+		return Handle{}, err
+	}
+
+	return newHandle(cli, id, c.GetName()), nil
+}
+
+// LookupContainer - ссылка на существующий контейнер по имени или
+// идентификатору (в том числе усеченному), идентификатор и имя берутся из
+// ответа среды исполнения
+func LookupContainer(ctx context.Context, cli Client, ref string) (Handle, error) {
+	result, err := ExtendClient(cli).ContainerInspect(ctx, ref)
+	if err != nil {
+		return Handle{}, errors.Ctx().Str("container", ref).Wrap(err, "lookup container")
+	}
+
+	return newHandle(cli, result.ID, result.Name), nil
+}
+
+// Handle - ссылка на контейнер после его создания, до создания - пустая ссылка
+func (c *BaseContainer) Handle() Handle {
+	if c.containerID == "" {
+		return Handle{}
+	}
+
+	return newHandle(c.client, c.containerID, c.GetName())
+}
+
+func newHandle(cli Client, id, name string) Handle {
+	h := Handle{id: id, name: name, client: ExtendClient(cli)}
+
+	if sc, ok := cli.(SessionClient); ok {
+		h.session = sc.Session()
+	}
+
+	return h
+}
+
+// ID - полный идентификатор контейнера
+func (h Handle) ID() string {
+	return h.id
+}
+
+// Name - имя контейнера
+func (h Handle) Name() string {
+	return h.name
+}
+
+// Session - сессия клиента, создавшего контейнер (пусто, если клиент ее не сообщает)
+func (h Handle) Session() string {
+	return h.session
+}
+
+// Client - клиент, которым создан контейнер
+func (h Handle) Client() Client {
+	return h.client
+}
+
+// IsZero - признак пустой ссылки
+func (h Handle) IsZero() bool {
+	return h.id == "" || h.client == nil
+}
+
+// String - имя и короткий идентификатор контейнера
+func (h Handle) String() string {
+	if h.IsZero() {
+		return "<invalid handle>"
+	}
+
+	return h.name + " (" + shortID(h.id) + ")"
+}
+
+// Start - запускает контейнер
+func (h Handle) Start(ctx context.Context) (*ContainerInfo, error) {
+	if err := h.check(); err != nil {
+		return nil, err
+	}
+
+	return h.client.ContainerStart(ctx, h.id, h.name)
+}
+
+// Inspect - запрашивает состояние контейнера
+func (h Handle) Inspect(ctx context.Context) (*InspectResult, error) {
+	if err := h.check(); err != nil {
+		return nil, err
+	}
+
+	return h.client.ContainerInspect(ctx, h.id)
+}
+
+// Wait - ожидает завершения процесса контейнера
+func (h Handle) Wait(ctx context.Context) (<-chan ContainerStatus, <-chan error) {
+	if err := h.check(); err != nil {
+		errCh := make(chan error, 1)
+		errCh <- err
+
+		return make(chan ContainerStatus), errCh
+	}
+
+	return h.client.ContainerWait(ctx, h.id)
+}
+
+// Stop - останавливает контейнер, по истечении timeout процесс завершается принудительно
+func (h Handle) Stop(ctx context.Context, timeout time.Duration) error {
+	if err := h.check(); err != nil {
+		return err
+	}
+
+	return h.client.ContainerStop(ctx, h.id, timeout)
+}
+
+// Restart - перезапускает контейнер
+func (h Handle) Restart(ctx context.Context, timeout time.Duration) error {
+	if err := h.check(); err != nil {
+		return err
+	}
+
+	return h.client.ContainerRestart(ctx, h.id, timeout)
+}
+
+// Kill - отправляет сигнал основному процессу контейнера
+func (h Handle) Kill(ctx context.Context, signal string) error {
+	if err := h.check(); err != nil {
+		return err
+	}
+
+	return h.client.ContainerKill(ctx, h.id, signal)
+}
+
+// Stats - поток замеров потребления ресурсов контейнера
+func (h Handle) Stats(ctx context.Context) (<-chan StatsSample, error) {
+	if err := h.check(); err != nil {
+		return nil, err
+	}
+
+	return h.client.ContainerStats(ctx, h.id)
+}
+
+// Pause - замораживает процессы контейнера
+func (h Handle) Pause(ctx context.Context) error {
+	if err := h.check(); err != nil {
+		return err
+	}
+
+	return h.client.ContainerPause(ctx, h.id)
+}
+
+// Unpause - возобновляет замороженные процессы контейнера
+func (h Handle) Unpause(ctx context.Context) error {
+	if err := h.check(); err != nil {
+		return err
+	}
+
+	return h.client.ContainerUnpause(ctx, h.id)
+}
+
+// Exec - выполняет команду в контейнере и возвращает код ее завершения
+func (h Handle) Exec(ctx context.Context, cmd []string, stdout, stderr io.Writer) (int, error) {
+	if err := h.check(); err != nil {
+		return 0, err
+	}
+
+	return h.client.ContainerExec(ctx, h.id, cmd, stdout, stderr)
+}
+
+// Remove - удаляет контейнер
+func (h Handle) Remove(ctx context.Context) error {
+	if err := h.check(); err != nil {
+		return err
+	}
+
+	return h.client.ContainerRemove(ctx, h.id)
+}
+
+// Commit - сохраняет файловую систему контейнера в образ tag
+func (h Handle) Commit(ctx context.Context, tag string, opts ...CommitOption) (string, error) {
+	if err := h.check(); err != nil {
+		return "", err
+	}
+
+	return h.client.ContainerCommit(ctx, h.id, tag, opts...)
+}
+
+// CopyTo - распаковывает tar архив content в каталог dst контейнера
+func (h Handle) CopyTo(ctx context.Context, dst string, content io.Reader) error {
+	if err := h.check(); err != nil {
+		return err
+	}
+
+	return h.client.CopyToContainer(ctx, h.id, dst, content)
+}
+
+// CopyFrom - tar архив пути src контейнера
+func (h Handle) CopyFrom(ctx context.Context, src string) (io.ReadCloser, error) {
+	if err := h.check(); err != nil {
+		return nil, err
+	}
+
+	return h.client.CopyFromContainer(ctx, h.id, src)
+}
+
+// Logs - транслирует логи контейнера, с follow - до завершения контейнера
+func (h Handle) Logs(ctx context.Context, stdout, stderr io.Writer, follow bool) error {
+	if err := h.check(); err != nil {
+		return err
+	}
+
+	return h.client.StreamLogs(ctx, h.id, stderr, stdout, follow)
+}
+
+// Connect - подключает контейнер к сети att.Network
+func (h Handle) Connect(ctx context.Context, att NetworkAttachment) error {
+	if err := h.check(); err != nil {
+		return err
+	}
+
+	return h.client.NetworkConnect(ctx, h.id, att)
+}
+
+// Disconnect - отключает контейнер от сети nw
+func (h Handle) Disconnect(ctx context.Context, nw Network) error {
+	if err := h.check(); err != nil {
+		return err
+	}
+
+	return h.client.NetworkDisconnect(ctx, h.id, nw)
+}
+
+func (h Handle) check() error {
+	if h.IsZero() {
+		return errors.Ctx().Str("container-name", h.name).Just(ErrInvalidHandle)
+	}
+
+	return nil
+}
@@ -0,0 +1,129 @@
+package containers
+
+import (
+	"context"
+	"time"
+
+	"gopkg.in/gomisc/errors.v1"
+
+	"gopkg.in/gomisc/containers.v1/wait"
+)
+
+const (
+	// ErrContainerUnhealthy - проверка здоровья контейнера не пройдена
+	ErrContainerUnhealthy = errors.Const("container is unhealthy")
+	// ErrNoHealthcheck - у контейнера нет проверки здоровья: ее не задают
+	// ни Healthcheck, ни образ
+	ErrNoHealthcheck = errors.Const("container has no healthcheck")
+)
+
+// Префиксы команды проверки здоровья Healthcheck.Test
+const (
+	// HealthcheckNone - отключает проверку, заданную в образе
+	HealthcheckNone = "NONE"
+	// HealthcheckCmd - команда выполняется напрямую
+	HealthcheckCmd = "CMD"
+	// HealthcheckShell - команда выполняется оболочкой образа
+	HealthcheckShell = "CMD-SHELL"
+)
+
+// healthMaxDelay - предельный интервал опроса состояния здоровья
+const healthMaxDelay = time.Second
+
+// Healthcheck - проверка здоровья контейнера (HEALTHCHECK), заменяет
+// проверку образа; нулевые интервалы и Retries - значения среды исполнения
+type Healthcheck struct {
+	// Test - команда проверки, первый элемент - HealthcheckCmd,
+	// HealthcheckShell или HealthcheckNone
+	Test []string `json:"test,omitempty" yaml:"test,omitempty"`
+	// Interval - период проверок
+	Interval time.Duration `json:"interval,omitempty" yaml:"interval,omitempty"`
+	// Timeout - время выполнения одной проверки
+	Timeout time.Duration `json:"timeout,omitempty" yaml:"timeout,omitempty"`
+	// StartPeriod - время после запуска, неудачные проверки в котором не
+	// учитываются в Retries
+	StartPeriod time.Duration `json:"start_period,omitempty" yaml:"start_period,omitempty"`
+	// Retries - число неудачных проверок подряд, после которого контейнер
+	// считается нездоровым
+	Retries int `json:"retries,omitempty" yaml:"retries,omitempty"`
+}
+
+// HealthCmd - проверка здоровья командой cmd, выполняемой напрямую
+func HealthCmd(cmd ...string) *Healthcheck {
+	return &Healthcheck{Test: append([]string{HealthcheckCmd}, cmd...)}
+}
+
+// HealthShell - проверка здоровья командой оболочки образа
+func HealthShell(cmd string) *Healthcheck {
+	return &Healthcheck{Test: []string{HealthcheckShell, cmd}}
+}
+
+// NoHealthcheck - отключает проверку здоровья, заданную в образе
+func NoHealthcheck() *Healthcheck {
+	return &Healthcheck{Test: []string{HealthcheckNone}}
+}
+
+// Disabled - признак отключенной проверки
+func (h *Healthcheck) Disabled() bool {
+	return h == nil || len(h.Test) == 0 || h.Test[0] == HealthcheckNone
+}
+
+// GetHealthcheck - возвращает проверку здоровья контейнера
+func (c *BaseContainer) GetHealthcheck() *Healthcheck {
+	if c != nil {
+		return c.Healthcheck
+	}
+
+	return nil
+}
+
+// ForHealthy - готовность по состоянию HealthHealthy проверки здоровья
+// контейнера (заданной Healthcheck или образом); HealthUnhealthy и
+// отсутствие состояния здоровья (ErrNoHealthcheck) - провал готовности
+// без ожидания таймаута
+func (c *BaseContainer) ForHealthy() ReadinessFunc {
+	return func(ctx context.Context) <-chan error {
+		readyCh := make(chan error, 1)
+
+		go func() {
+			defer close(readyCh)
+
+			backoff := wait.DefaultBackoff.WithMax(healthMaxDelay)
+
+			for attempt := 0; ; attempt++ {
+				state, err := c.Inspect(ctx)
+
+				switch {
+				case err != nil:
+				case state.Health == HealthHealthy:
+					return
+				case state.Health == HealthUnhealthy:
+					readyCh <- errors.Ctx().Str("container-name", c.GetName()).Just(ErrContainerUnhealthy)
+
+					return
+				case state.Health == "":
+					// среда исполнения выставляет состояние starting при запуске,
+					// без проверки здоровья состояния нет и оно не появится
+					readyCh <- errors.Ctx().Str("container-name", c.GetName()).Just(ErrNoHealthcheck)
+
+					return
+				default:
+					err = errors.Ctx().Str("health", state.Health).New("container is not healthy yet")
+				}
+
+				timer := time.NewTimer(backoff.Delay(attempt))
+
+				select {
+				case <-ctx.Done():
+					timer.Stop()
+					readyCh <- errors.And(errors.Wrap(ctx.Err(), "wait for health"), err)
+
+					return
+				case <-timer.C:
+				}
+			}
+		}()
+
+		return readyCh
+	}
+}
@@ -8,3 +8,13 @@ func SliceToSet(slice []string) map[string]struct{} {
 
 	return set
 }
+
+func shortID(id string) string {
+	const shortLen = 12
+
+	if len(id) > shortLen {
+		return id[:shortLen]
+	}
+
+	return id
+}
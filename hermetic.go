@@ -0,0 +1,224 @@
+package containers
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"gopkg.in/gomisc/errors.v1"
+)
+
+const (
+	// HermeticEnvar - переменная окружения, включающая герметичный режим
+	// процесса (непустое значение, кроме "0" и "false")
+	HermeticEnvar = "CONTAINERS_HERMETIC"
+
+	// ErrHermeticViolation - операция запрещена герметичным режимом
+	ErrHermeticViolation = errors.Const("hermetic policy violation")
+
+	// ViolationUnpinnedImage - образ контейнера задан без дайджеста
+	ViolationUnpinnedImage = "unpinned-image"
+	// ViolationImagePull - попытка скачать образ во время работы
+	ViolationImagePull = "image-pull"
+	// ViolationNetworkEgress - сеть контейнера имеет выход во внешние сети
+	ViolationNetworkEgress = "network-egress"
+)
+
+type (
+	// HermeticPolicy - политика герметичного окружения для воспроизводимых
+	// и изолированных от сети прогонов: контейнеры запускаются только из
+	// образов, закрепленных дайджестом, образы не скачиваются (должны быть
+	// подготовлены заранее), а сети создаются внутренними - без выхода во
+	// внешние сети. Нарушения накапливаются в отчете. Порты контейнеров во
+	// внутренних сетях на хосте не публикуются, поэтому проверки готовности
+	// и тесты обращаются к адресам контейнеров в сети
+	HermeticPolicy struct {
+		// AuditOnly - нарушения только записываются в отчет, операции не запрещаются
+		AuditOnly bool
+		// AllowUnpinned - шаблоны path.Match ссылок на образы, которым разрешено
+		// отсутствие дайджеста, например собираемым локально фикстурам
+		AllowUnpinned []string
+
+		mu         sync.Mutex
+		violations []HermeticViolation
+	}
+
+	// HermeticViolation - нарушение герметичности
+	HermeticViolation struct {
+		// Kind - вид нарушения (Violation*)
+		Kind string `json:"kind" yaml:"kind"`
+		// Subject - образ, сеть или контейнер, к которому относится нарушение
+		Subject string `json:"subject" yaml:"subject"`
+		// Detail - пояснение
+		Detail string    `json:"detail,omitempty" yaml:"detail,omitempty"`
+		Time   time.Time `json:"time" yaml:"time"`
+	}
+
+	// InternalNetwork - сеть, сообщающая об отсутствии выхода во внешние сети
+	InternalNetwork interface {
+		Internal() bool
+	}
+)
+
+var (
+	hermeticPolicy atomic.Pointer[HermeticPolicy]
+	hermeticEnv    sync.Once
+)
+
+// SetHermeticPolicy - включает герметичный режим процесса с политикой p,
+// nil выключает режим
+func SetHermeticPolicy(p *HermeticPolicy) {
+	hermeticEnv.Do(func() {})
+	hermeticPolicy.Store(p)
+}
+
+// Hermetic - политика герметичного режима процесса, nil - режим выключен.
+// Без явной политики режим включается переменной окружения HermeticEnvar
+func Hermetic() *HermeticPolicy {
+	hermeticEnv.Do(
+		func() {
+			switch strings.ToLower(os.Getenv(HermeticEnvar)) {
+			case "", "0", "false":
+			default:
+				hermeticPolicy.Store(&HermeticPolicy{})
+			}
+		},
+	)
+
+	return hermeticPolicy.Load()
+}
+
+// Enabled - признак включенного режима
+func (p *HermeticPolicy) Enabled() bool {
+	return p != nil
+}
+
+// CheckImage - проверяет, что образ контейнера закреплен дайджестом
+func (p *HermeticPolicy) CheckImage(ref string) error {
+	if p == nil || ref == "" || pinnedImage(ref) {
+		return nil
+	}
+
+	for _, pattern := range p.AllowUnpinned {
+		if ok, _ := path.Match(pattern, ref); ok {
+			return nil
+		}
+	}
+
+	return p.violate(ViolationUnpinnedImage, ref, "image reference is not pinned by digest")
+}
+
+// CheckPull - скачивание образов в герметичном режиме запрещено. Проверку
+// выполняют адаптеры в PullImage и PullImageWith, поэтому ее не обходят
+// ни прямые вызовы клиента, ни скачивания внутри адаптера
+func (p *HermeticPolicy) CheckPull(ref string) error {
+	if p == nil {
+		return nil
+	}
+
+	return p.violate(ViolationImagePull, ref, "image must be provisioned before the run")
+}
+
+// CheckNetwork - проверяет, что сеть не имеет выхода во внешние сети;
+// сеть адаптера, не сообщающего об этом, считается нарушением
+func (p *HermeticPolicy) CheckNetwork(nw Network) error {
+	if p == nil || nw == nil {
+		return nil
+	}
+
+	if in, ok := nw.(InternalNetwork); ok && in.Internal() {
+		return nil
+	}
+
+	return p.violate(ViolationNetworkEgress, nw.Name(), "network is not internal")
+}
+
+// Violations - накопленные нарушения по порядку
+func (p *HermeticPolicy) Violations() []HermeticViolation {
+	if p == nil {
+		return nil
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return append([]HermeticViolation(nil), p.violations...)
+}
+
+// WriteReport - записывает отчет о нарушениях, по одному в строке
+func (p *HermeticPolicy) WriteReport(w io.Writer) error {
+	violations := p.Violations()
+
+	if _, err := fmt.Fprintf(w, "hermetic policy: %d violation(s)\n", len(violations)); err != nil {
+		return errors.Wrap(err, "write hermetic report")
+	}
+
+	for _, v := range violations {
+		if _, err := fmt.Fprintf(w, "%s\t%s\t%s\n", v.Kind, v.Subject, v.Detail); err != nil {
+			return errors.Wrap(err, "write hermetic report")
+		}
+	}
+
+	return nil
+}
+
+// violate - записывает нарушение (повторное для того же объекта - один раз)
+// и возвращает ошибку, если режим не AuditOnly
+func (p *HermeticPolicy) violate(kind, subject, detail string) error {
+	p.mu.Lock()
+
+	known := false
+
+	for i := range p.violations {
+		known = known || (p.violations[i].Kind == kind && p.violations[i].Subject == subject)
+	}
+
+	if !known {
+		p.violations = append(
+			p.violations, HermeticViolation{Kind: kind, Subject: subject, Detail: detail, Time: time.Now()},
+		)
+	}
+
+	p.mu.Unlock()
+
+	if p.AuditOnly {
+		return nil
+	}
+
+	return errors.Ctx().Str("kind", kind).Str("subject", subject).Str("detail", detail).Just(ErrHermeticViolation)
+}
+
+// checkHermetic - проверяет образ и сети контейнера перед созданием
+func (c *BaseContainer) checkHermetic() error {
+	policy := Hermetic()
+	if policy == nil {
+		return nil
+	}
+
+	err := policy.CheckImage(c.Image)
+
+	if c.network != nil {
+		err = errors.And(err, policy.CheckNetwork(c.network))
+	}
+
+	for _, att := range c.GetExtraNetworks() {
+		err = errors.And(err, policy.CheckNetwork(att.Network))
+	}
+
+	if err != nil {
+		return errors.Ctx().Str("container-name", c.GetName()).Wrap(err, "check hermetic policy")
+	}
+
+	return nil
+}
+
+// pinnedImage - ссылка закреплена дайджестом (name@sha256:...) или является
+// идентификатором образа
+func pinnedImage(ref string) bool {
+	return strings.Contains(ref, "@sha256:") || strings.HasPrefix(ref, "sha256:")
+}
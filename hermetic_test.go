@@ -0,0 +1,41 @@
+package containers_test
+
+import (
+	"context"
+	"testing"
+
+	"gopkg.in/gomisc/errors.v1"
+
+	"gopkg.in/gomisc/containers.v1"
+	"gopkg.in/gomisc/containers.v1/adapters/fake"
+)
+
+func TestHermeticPullAtAdapter(t *testing.T) {
+	cli, err := fake.New()
+	if err != nil {
+		t.Fatalf("fake.New: %v", err)
+	}
+
+	policy := &containers.HermeticPolicy{}
+
+	containers.SetHermeticPolicy(policy)
+	t.Cleanup(func() { containers.SetHermeticPolicy(nil) })
+
+	if err = cli.PullImage(testImage); !errors.Is(err, containers.ErrHermeticViolation) {
+		t.Fatalf("PullImage: got %v, want %v", err, containers.ErrHermeticViolation)
+	}
+
+	err = cli.PullImageWith(context.Background(), containers.PullOptions{Image: testImage})
+	if !errors.Is(err, containers.ErrHermeticViolation) {
+		t.Fatalf("PullImageWith: got %v, want %v", err, containers.ErrHermeticViolation)
+	}
+
+	if exist, _ := cli.FindImageLocal(context.Background(), testImage); exist {
+		t.Fatal("image is pulled in hermetic mode")
+	}
+
+	// повторное нарушение для того же образа записывается один раз
+	if n := len(policy.Violations()); n != 1 {
+		t.Fatalf("violations: got %d, want 1", n)
+	}
+}
@@ -0,0 +1,175 @@
+package containers
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Переменные окружения отладки упавших контейнеров
+const (
+	// DebugOnFailureEnvar - при непустом значении контейнер, не прошедший
+	// проверку готовности, не останавливается, пока разработчик не закончит
+	// отладку (docker exec) и не удалит файл-маркер
+	DebugOnFailureEnvar = "CONTAINERS_DEBUG_ON_FAILURE"
+	// DebugOnFailureTimeoutEnvar - предельное время ожидания отладки, по умолчанию 30m
+	DebugOnFailureTimeoutEnvar = "CONTAINERS_DEBUG_TIMEOUT"
+
+	defaultDebugHoldTimeout = 30 * time.Minute
+	debugHoldPollInterval   = 500 * time.Millisecond
+	// debugHoldMargin - запас до срока теста на остановку окружения, чтобы
+	// go test не прервал процесс паникой по таймауту
+	debugHoldMargin = 30 * time.Second
+)
+
+// processStart - время запуска процесса, от него отсчитывается -test.timeout
+var processStart = time.Now()
+
+// deadliner - источник срока: контекст или тест (testing.T)
+type deadliner interface {
+	Deadline() (time.Time, bool)
+}
+
+// holdForDebug - если задан CONTAINERS_DEBUG_ON_FAILURE, выводит инструкции
+// подключения к упавшему контейнеру и откладывает его остановку, пока не будет
+// удален файл-маркер, не истечет CONTAINERS_DEBUG_TIMEOUT или срок теста (с
+// запасом debugHoldMargin) либо не будет отменен контекст контейнера; tail -
+// хвост вывода контейнера, выводимый вместе с инструкциями
+func (c *BaseContainer) holdForDebug(cause error, tail []string) {
+	if os.Getenv(DebugOnFailureEnvar) == "" || c.containerID == "" {
+		return
+	}
+
+	timeout := defaultDebugHoldTimeout
+
+	if value := os.Getenv(DebugOnFailureTimeoutEnvar); value != "" {
+		if parsed, err := time.ParseDuration(value); err == nil {
+			timeout = parsed
+		}
+	}
+
+	ctx := c.context()
+	deadline := time.Now().Add(timeout)
+
+	for _, d := range []deadliner{ctx, c.testDeadline()} {
+		if limit, ok := d.Deadline(); ok && limit.Add(-debugHoldMargin).Before(deadline) {
+			deadline = limit.Add(-debugHoldMargin)
+		}
+	}
+
+	if !time.Now().Before(deadline) {
+		return
+	}
+
+	timeout = time.Until(deadline).Round(time.Second)
+
+	marker, err := os.CreateTemp("", "containers-debug-"+sanitizeTestName(c.GetName())+"-")
+	if err != nil {
+		c.LogError(err, "create debug marker")
+
+		return
+	}
+
+	_ = marker.Close()
+
+	defer func() {
+		_ = os.Remove(marker.Name())
+	}()
+
+	out, closeOut := debugTerminal()
+	defer closeOut()
+
+	docker := "docker"
+	if host := os.Getenv("DOCKER_HOST"); host != "" {
+		docker = "DOCKER_HOST=" + strconv.Quote(host) + " docker"
+	}
+
+	_, _ = fmt.Fprintf(
+		out,
+		"\n!!! container %s (%s) is not ready: %v\n"+
+			"    shell:  %s exec -it %s sh\n"+
+			"    logs:   %s logs %s\n"+
+			"    teardown is paused for up to %s, resume with: rm %s\n\n",
+		c.GetName(), shortID(c.containerID), cause,
+		docker, shortID(c.containerID),
+		docker, shortID(c.containerID),
+		timeout, marker.Name(),
+	)
+
+	if len(tail) != 0 {
+		_, _ = fmt.Fprintf(out, "    last %d lines of output:\n", len(tail))
+
+		for _, line := range tail {
+			_, _ = fmt.Fprintln(out, "    | "+line)
+		}
+
+		_, _ = fmt.Fprintln(out)
+	}
+
+	ticker := time.NewTicker(debugHoldPollInterval)
+	defer ticker.Stop()
+
+	expired := time.NewTimer(time.Until(deadline))
+	defer expired.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if _, err = os.Stat(marker.Name()); os.IsNotExist(err) {
+				return
+			}
+		case <-expired.C:
+			return
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// testDeadline - срок теста: из теста, переданного WithArtifacts, иначе из
+// флага -test.timeout, отсчитанного от запуска процесса
+func (c *BaseContainer) testDeadline() deadliner {
+	if c.test != nil {
+		return c.test
+	}
+
+	return flagDeadline{}
+}
+
+// flagDeadline - срок процесса тестов по флагу -test.timeout
+type flagDeadline struct{}
+
+func (flagDeadline) Deadline() (time.Time, bool) {
+	f := flag.Lookup("test.timeout")
+	if f == nil {
+		return time.Time{}, false
+	}
+
+	getter, ok := f.Value.(flag.Getter)
+	if !ok {
+		return time.Time{}, false
+	}
+
+	timeout, ok := getter.Get().(time.Duration)
+	if !ok || timeout <= 0 {
+		return time.Time{}, false
+	}
+
+	return processStart.Add(timeout), true
+}
+
+// debugTerminal - поток для инструкций отладки: терминал процесса, так как
+// go test буферизует вывод тестов пакета до их завершения
+func debugTerminal() (io.Writer, func()) {
+	tty, err := os.OpenFile("/dev/tty", os.O_WRONLY, 0)
+	if err != nil {
+		return os.Stderr, func() {}
+	}
+
+	return tty, func() {
+		_ = tty.Close()
+	}
+}
@@ -0,0 +1,160 @@
+package containers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os/exec"
+	"time"
+
+	"gopkg.in/gomisc/errors.v1"
+)
+
+const (
+	// DefaultHookTimeout - время выполнения хука, если Timeout не задан
+	DefaultHookTimeout = 30 * time.Second
+
+	// ociVersion - версия спецификации состояния, передаваемого хукам
+	ociVersion = "1.0.2"
+
+	// статусы контейнера в терминах OCI runtime
+	ociStatusRunning = "running"
+	ociStatusStopped = "stopped"
+
+	ErrHookUnsupported = errors.Const("oci hook is not supported by runtime")
+	ErrHookFailed      = errors.Const("oci hook failed")
+)
+
+type (
+	// Hook - OCI хук: программа хоста, получающая состояние контейнера на stdin
+	Hook struct {
+		// Path - абсолютный путь к программе на хосте
+		Path string `json:"path" yaml:"path"`
+		// Args - argv по спецификации OCI, включая нулевой аргумент
+		Args []string `json:"args,omitempty" yaml:"args,omitempty"`
+		// Env - окружение программы в форме KEY=VALUE
+		Env []string `json:"env,omitempty" yaml:"env,omitempty"`
+		// Timeout - ограничение времени выполнения (0 - DefaultHookTimeout)
+		Timeout time.Duration `json:"timeout,omitempty" yaml:"timeout,omitempty"`
+	}
+
+	// Hooks - OCI хуки жизненного цикла контейнера
+	Hooks struct {
+		// Prestart - после создания пространств имен, до запуска процесса
+		Prestart []Hook `json:"prestart,omitempty" yaml:"prestart,omitempty"`
+		// Poststart - после запуска процесса
+		Poststart []Hook `json:"poststart,omitempty" yaml:"poststart,omitempty"`
+		// Poststop - после остановки контейнера
+		Poststop []Hook `json:"poststop,omitempty" yaml:"poststop,omitempty"`
+	}
+
+	// HooksRuntime - адаптер, среда исполнения которого выполняет хуки сама
+	// (получая их в ContainerCreate через GetHooks). Для остальных адаптеров
+	// Poststart и Poststop выполняет BaseContainer на хосте вызывающего
+	// процесса, а Prestart не поддерживается: его нельзя воспроизвести вне runtime
+	HooksRuntime interface {
+		NativeHooks() bool
+	}
+
+	// ociState - состояние контейнера по спецификации OCI runtime
+	ociState struct {
+		OCIVersion string `json:"ociVersion"`
+		ID         string `json:"id"`
+		Status     string `json:"status"`
+		Pid        int    `json:"pid,omitempty"`
+		Bundle     string `json:"bundle"`
+	}
+)
+
+// Empty - признак отсутствия хуков
+func (h Hooks) Empty() bool {
+	return len(h.Prestart) == 0 && len(h.Poststart) == 0 && len(h.Poststop) == 0
+}
+
+// GetHooks - возвращает OCI хуки контейнера
+func (c *BaseContainer) GetHooks() Hooks {
+	return c.Hooks
+}
+
+// nativeHooks - признак выполнения хуков самой средой исполнения
+func (c *BaseContainer) nativeHooks() bool {
+	r, ok := c.client.(HooksRuntime)
+
+	return ok && r.NativeHooks()
+}
+
+// checkHooks - отклоняет хуки, которые нельзя выполнить без поддержки runtime
+func (c *BaseContainer) checkHooks() error {
+	if len(c.Hooks.Prestart) == 0 || c.nativeHooks() {
+		return nil
+	}
+
+	return errors.Ctx().Str("container-name", c.GetName()).Str("hook", "prestart").Just(ErrHookUnsupported)
+}
+
+// runHooks - выполняет эмулируемые хуки по порядку, первая ошибка прерывает
+// выполнение; ошибки poststop хуков собираются, а хуки выполняются все
+func (c *BaseContainer) runHooks(ctx context.Context, hooks []Hook, status string) error {
+	if len(hooks) == 0 || c.nativeHooks() {
+		return nil
+	}
+
+	state := ociState{OCIVersion: ociVersion, ID: c.containerID, Status: status}
+
+	if info, err := c.runtime().ContainerInspect(ctx, c.containerID); err == nil {
+		state.Pid = info.Pid
+	}
+
+	stdin, err := json.Marshal(state)
+	if err != nil {
+		return errors.Wrap(err, "encode oci state")
+	}
+
+	var result error
+
+	for _, hook := range hooks {
+		if err = runHook(ctx, hook, stdin); err != nil {
+			err = errors.Ctx().Str("container-name", c.GetName()).Str("status", status).Wrap(err, "run hook")
+
+			// по спецификации OCI ошибка poststop хука не прерывает остальные
+			if status != ociStatusStopped {
+				return err
+			}
+
+			result = errors.And(result, err)
+		}
+	}
+
+	return result
+}
+
+func runHook(ctx context.Context, hook Hook, stdin []byte) error {
+	timeout := hook.Timeout
+	if timeout <= 0 {
+		timeout = DefaultHookTimeout
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var out bytes.Buffer
+
+	cmd := exec.CommandContext(ctx, hook.Path) //nolint:gosec
+	if len(hook.Args) > 0 {
+		cmd.Args = hook.Args
+	}
+
+	cmd.Env = hook.Env
+	cmd.Stdin = bytes.NewReader(stdin)
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+
+	if err := cmd.Run(); err != nil {
+		return errors.And(
+			errors.Ctx().Str("path", hook.Path).Str("output", out.String()).Just(ErrHookFailed),
+			err,
+		)
+	}
+
+	return nil
+}
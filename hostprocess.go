@@ -0,0 +1,485 @@
+package containers
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"sort"
+	"sync"
+	"syscall"
+	"time"
+
+	"gopkg.in/gomisc/errors.v1"
+	"gopkg.in/gomisc/network.v1/ports"
+
+	"gopkg.in/gomisc/containers.v1/wait"
+)
+
+var _ Container = (*HostProcess)(nil)
+
+// DefaultHostProcessStopTimeout - время на корректное завершение процесса
+// хоста после SIGINT, по истечении процесс завершается SIGKILL
+const DefaultHostProcessStopTimeout = 10 * time.Second
+
+// ErrHostProcessExited - процесс хоста завершился до готовности
+const ErrHostProcessExited = errors.Const("host process exited before ready")
+
+// HostProcess - участник окружения, запускаемый на хосте как обычный процесс.
+// Позволяет запускать тестируемый сервис нативно (например, под отладчиком),
+// а его зависимости - в контейнерах того же оркестратора. Переменные EnvFrom
+// вычисляются непосредственно перед запуском, когда адреса контейнеров,
+// добавленных в оркестратор раньше, уже известны. При пустом Path процесс не
+// запускается: участник только ожидает готовности процесса, запущенного извне
+// (из IDE), по адресам Addrs
+type HostProcess struct {
+	Name string
+	Path string
+	Args []string
+	Dir  string
+	Envs []string
+	// EnvFrom - переменные окружения, значения которых вычисляются при запуске
+	EnvFrom map[string]func() string
+	// Addrs - адреса, которые слушает процесс на хосте
+	Addrs AddrsMap
+	// Network - сеть, из которой к процессу обращаются контейнеры (по адресу
+	// хоста в этой сети), может быть не задана
+	Network Network
+	// Readiness - проверка готовности, по умолчанию - прослушивание Addrs
+	Readiness ReadinessFunc
+	// StartTimeout - время ожидания готовности
+	StartTimeout time.Duration
+	// StopTimeout - время на корректное завершение после SIGINT
+	StopTimeout time.Duration
+	// Background - StartContainer возвращает управление после готовности,
+	// не дожидаясь завершения процесса
+	Background bool
+
+	OutputStream io.Writer
+	ErrorStream  io.Writer
+
+	mu       sync.Mutex
+	cmd      *exec.Cmd
+	exit     chan struct{}
+	exitErr  error
+	stopOnce sync.Once
+	stopErr  error
+}
+
+// HostAddr - значение для EnvFrom: адрес порта контейнера c на хосте
+func HostAddr(c Container, port ports.PortName) func() string {
+	return func() string {
+		return c.HostAddrs()[port]
+	}
+}
+
+// ContainerAddr - значение для EnvFrom: адрес порта контейнера c в его сети
+func ContainerAddr(c Container, port ports.PortName) func() string {
+	return func() string {
+		return c.ContainerAddrs()[port]
+	}
+}
+
+// Pid - идентификатор запущенного процесса, 0 до запуска или для внешнего процесса
+func (p *HostProcess) Pid() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.cmd == nil || p.cmd.Process == nil {
+		return 0
+	}
+
+	return p.cmd.Process.Pid
+}
+
+// GetID - у процесса хоста нет идентификатора контейнера
+func (p *HostProcess) GetID() string {
+	return ""
+}
+
+// GetClient - процесс хоста не управляется средой исполнения контейнеров
+func (p *HostProcess) GetClient() Client {
+	return nil
+}
+
+func (p *HostProcess) GetName() string {
+	return p.Name
+}
+
+// GetImage - пустой образ исключает участника из подготовки образов и экспорта
+func (p *HostProcess) GetImage() string {
+	return ""
+}
+
+func (p *HostProcess) GetSysctls() map[string]string {
+	return nil
+}
+
+func (p *HostProcess) GetContainerIP() string {
+	if p.Network != nil {
+		return p.Network.HostIP()
+	}
+
+	return ""
+}
+
+func (p *HostProcess) ContainerPorts() []Port {
+	return nil
+}
+
+func (p *HostProcess) PortMap() PortMap {
+	return nil
+}
+
+func (p *HostProcess) GetEnvs() []string {
+	return p.Envs
+}
+
+func (p *HostProcess) GetEntryPoint() string {
+	return p.Path
+}
+
+func (p *HostProcess) GetCmd() []string {
+	return p.Args
+}
+
+func (p *HostProcess) GetVolumes() []string {
+	return nil
+}
+
+func (p *HostProcess) GetMounts() []string {
+	return nil
+}
+
+func (p *HostProcess) GetNamedVolumes() []VolumeSpec {
+	return nil
+}
+
+func (p *HostProcess) GetTmpfs() map[string]string {
+	return nil
+}
+
+func (p *HostProcess) GetMountSpecs() []MountSpec {
+	return nil
+}
+
+func (p *HostProcess) GetAutoremove() bool {
+	return false
+}
+
+func (p *HostProcess) GetNetwork() Network {
+	return p.Network
+}
+
+func (p *HostProcess) GetExtraNetworks() []NetworkAttachment {
+	return nil
+}
+
+func (p *HostProcess) GetAliases() []string {
+	return nil
+}
+
+func (p *HostProcess) GetExtraHosts() []string {
+	return nil
+}
+
+func (p *HostProcess) GetDNS() []string {
+	return nil
+}
+
+func (p *HostProcess) GetDNSSearch() []string {
+	return nil
+}
+
+func (p *HostProcess) GetRuntime() string {
+	return ""
+}
+
+func (p *HostProcess) GetPlatform() string {
+	return ""
+}
+
+func (p *HostProcess) GetHooks() Hooks {
+	return Hooks{}
+}
+
+func (p *HostProcess) GetHealthcheck() *Healthcheck {
+	return nil
+}
+
+func (p *HostProcess) GetResources() Resources {
+	return Resources{}
+}
+
+func (p *HostProcess) GetDevices() []Device {
+	return nil
+}
+
+func (p *HostProcess) GetGPUs() []GPURequest {
+	return nil
+}
+
+func (p *HostProcess) GetUser() string {
+	return ""
+}
+
+func (p *HostProcess) GetWorkDir() string {
+	return p.Dir
+}
+
+func (p *HostProcess) GetLabels() map[string]string {
+	return nil
+}
+
+// HostAddrs - адреса процесса на хосте
+func (p *HostProcess) HostAddrs() AddrsMap {
+	return p.Addrs.Copy()
+}
+
+// ContainerAddrs - адреса процесса, доступные контейнерам сети Network;
+// без сети совпадают с адресами на хосте
+func (p *HostProcess) ContainerAddrs() AddrsMap {
+	addrs := p.Addrs.Copy()
+	if p.Network == nil {
+		return addrs
+	}
+
+	for name, addr := range addrs {
+		if _, port, err := net.SplitHostPort(addr); err == nil {
+			addrs[name] = net.JoinHostPort(p.Network.HostIP(), port)
+		}
+	}
+
+	return addrs
+}
+
+// CreateContainer - проверяет наличие исполняемого файла
+func (p *HostProcess) CreateContainer() error {
+	if p.Path == "" {
+		return nil
+	}
+
+	if _, err := exec.LookPath(p.Path); err != nil {
+		return errors.Ctx().Str("name", p.Name).Str("path", p.Path).Wrap(err, "find host process binary")
+	}
+
+	return nil
+}
+
+// StartContainer - запускает процесс и дожидается его готовности
+func (p *HostProcess) StartContainer(sigCh <-chan os.Signal, ready chan<- struct{}) error {
+	if err := p.start(); err != nil {
+		return err
+	}
+
+	timeout := p.StartTimeout
+	if timeout == 0 {
+		timeout = DefaultStartTimeout
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	select {
+	case <-p.exited():
+		return errors.And(errors.Ctx().Str("name", p.Name).Just(ErrHostProcessExited), p.exitErr)
+	case err := <-p.readiness()(ctx):
+		if err != nil {
+			if stopErr := p.Stop(); stopErr != nil {
+				p.LogError(stopErr, "stop host process")
+			}
+
+			return errors.And(errors.Ctx().Str("name", p.Name).Just(ErrContainerNotReady), err)
+		}
+	}
+
+	p.LogStdout(p.Name + " host process ready")
+
+	if ready != nil {
+		close(ready)
+	}
+
+	if !p.Background {
+		select {
+		case <-p.exited():
+			return p.exitErr
+		case <-sigCh:
+			return p.Stop()
+		}
+	}
+
+	return nil
+}
+
+// Stop - завершает процесс: SIGINT, затем SIGKILL по истечении StopTimeout.
+// Внешний процесс не останавливается
+func (p *HostProcess) Stop() error {
+	p.mu.Lock()
+	started := p.cmd != nil
+	p.mu.Unlock()
+
+	// вызов до старта не должен помешать остановить процесс после него
+	if !started {
+		return nil
+	}
+
+	p.stopOnce.Do(
+		func() {
+			p.stopErr = p.stop()
+		},
+	)
+
+	return p.stopErr
+}
+
+// LogStdout пишет сообщение в поток стандартного вывода процесса
+func (p *HostProcess) LogStdout(format string, args ...any) bool {
+	return logTo(p.stdout(), format, args...)
+}
+
+// LogStderr пишет сообщение в поток вывода ошибок процесса
+func (p *HostProcess) LogStderr(format string, args ...any) bool {
+	return logTo(p.stderr(), format, args...)
+}
+
+// LogError пишет ошибку в поток вывода ошибок процесса
+func (p *HostProcess) LogError(err error, args ...any) bool {
+	return p.LogStderr("\x1b[91mERROR:\x1b[0m " + errors.Formatted(err, args...).Error())
+}
+
+func (p *HostProcess) start() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.exit = make(chan struct{})
+
+	if p.Path == "" {
+		return nil
+	}
+
+	cmd := exec.Command(p.Path, p.Args...) //nolint:gosec
+	cmd.Dir = p.Dir
+	cmd.Stdout = p.stdout()
+	cmd.Stderr = p.stderr()
+	cmd.Env = append(os.Environ(), p.Envs...)
+
+	keys := make([]string, 0, len(p.EnvFrom))
+	for key := range p.EnvFrom {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		cmd.Env = append(cmd.Env, key+"="+p.EnvFrom[key]())
+	}
+
+	if err := cmd.Start(); err != nil {
+		return errors.Ctx().Str("name", p.Name).Str("path", p.Path).Wrap(err, "start host process")
+	}
+
+	p.cmd = cmd
+
+	go func(exit chan<- struct{}) {
+		err := cmd.Wait()
+		if err != nil {
+			err = errors.Ctx().Str("name", p.Name).Wrap(err, "host process exited with error")
+		}
+
+		p.mu.Lock()
+		p.exitErr = err
+		p.mu.Unlock()
+
+		close(exit)
+	}(p.exit)
+
+	return nil
+}
+
+func (p *HostProcess) stop() error {
+	p.mu.Lock()
+	cmd, exit := p.cmd, p.exit
+	p.mu.Unlock()
+
+	if cmd == nil || cmd.Process == nil {
+		return nil
+	}
+
+	timeout := p.StopTimeout
+	if timeout == 0 {
+		timeout = DefaultHostProcessStopTimeout
+	}
+
+	if err := cmd.Process.Signal(syscall.SIGINT); err != nil {
+		// процесс уже завершен
+		return nil //nolint:nilerr
+	}
+
+	select {
+	case <-exit:
+		return nil
+	case <-time.After(timeout):
+	}
+
+	if err := cmd.Process.Kill(); err != nil {
+		return errors.Ctx().Str("name", p.Name).Wrap(err, "kill host process")
+	}
+
+	<-exit
+
+	return nil
+}
+
+// exited - канал, закрываемый при завершении процесса; для внешнего
+// процесса никогда не закрывается
+func (p *HostProcess) exited() <-chan struct{} {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.cmd == nil {
+		return nil
+	}
+
+	return p.exit
+}
+
+func (p *HostProcess) readiness() ReadinessFunc {
+	if p.Readiness != nil {
+		return p.Readiness
+	}
+
+	return wait.ForListeningPorts(
+		func() []string {
+			addrs := make([]string, 0, len(p.Addrs))
+			for _, addr := range p.Addrs {
+				addrs = append(addrs, addr)
+			}
+
+			return addrs
+		},
+	)
+}
+
+func (p *HostProcess) stdout() io.Writer {
+	if p.OutputStream != nil {
+		return p.OutputStream
+	}
+
+	return os.Stdout
+}
+
+func (p *HostProcess) stderr() io.Writer {
+	if p.ErrorStream != nil {
+		return p.ErrorStream
+	}
+
+	return os.Stderr
+}
+
+func logTo(w io.Writer, format string, args ...any) bool {
+	_, err := fmt.Fprintf(w, format+"\n", args...)
+
+	return err == nil
+}
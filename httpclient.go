@@ -0,0 +1,150 @@
+package containers
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"gopkg.in/gomisc/network.v1/ports"
+)
+
+// DefaultHTTPTimeout - таймаут запроса клиента HTTPClientFor по умолчанию
+const DefaultHTTPTimeout = 30 * time.Second
+
+type (
+	// HTTPOption - опция клиента HTTPClientFor
+	HTTPOption func(o *httpOptions)
+
+	httpOptions struct {
+		timeout    time.Duration
+		rootCAs    *x509.CertPool
+		serverName string
+		https      bool
+	}
+
+	// baseTransport - дополняет относительные запросы адресом порта контейнера
+	baseTransport struct {
+		scheme string
+		host   string
+		next   http.RoundTripper
+	}
+)
+
+// WithHTTPTimeout - таймаут запроса (0 - без ограничения)
+func WithHTTPTimeout(timeout time.Duration) HTTPOption {
+	return func(o *httpOptions) {
+		o.timeout = timeout
+	}
+}
+
+// WithRootCAs - включает https с доверием к сертификатам пула, например
+// тестового удостоверяющего центра tlsgen
+func WithRootCAs(pool *x509.CertPool) HTTPOption {
+	return func(o *httpOptions) {
+		o.rootCAs = pool
+		o.https = true
+	}
+}
+
+// WithServerName - имя сервера для проверки сертификата, по умолчанию имя контейнера
+func WithServerName(name string) HTTPOption {
+	return func(o *httpOptions) {
+		o.serverName = name
+	}
+}
+
+// HTTPClientFor - HTTP клиент к порту port контейнера. Относительные запросы
+// (client.Get("/health")) адресуются контейнеру, а соединения запросов к имени
+// или псевдониму контейнера устанавливаются с адресом порта, доступным тестовому
+// процессу: адресом в сети контейнеров, если процесс сам запущен в контейнере,
+// иначе - на хосте. Адрес определяется при каждом соединении, поэтому клиент
+// переживает перезапуск. Запросы к остальным адресам выполняются как обычно
+func HTTPClientFor(c Container, port ports.PortName, opts ...HTTPOption) *http.Client {
+	o := httpOptions{timeout: DefaultHTTPTimeout, serverName: c.GetName()}
+
+	for _, apply := range opts {
+		apply(&o)
+	}
+
+	dialer := &net.Dialer{Timeout: 10 * time.Second, KeepAlive: 30 * time.Second}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = func(req *http.Request) (*url.URL, error) {
+		if isContainerHost(c, req.URL.Hostname()) {
+			return nil, nil
+		}
+
+		return http.ProxyFromEnvironment(req)
+	}
+	transport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		if host, _, err := net.SplitHostPort(addr); err == nil && isContainerHost(c, host) {
+			addr = endpointOf(c, port)
+		}
+
+		return dialer.DialContext(ctx, network, addr)
+	}
+
+	scheme := "http"
+
+	if o.https {
+		scheme = "https"
+		transport.TLSClientConfig = &tls.Config{
+			RootCAs:    o.rootCAs,
+			ServerName: o.serverName,
+			MinVersion: tls.VersionTLS12,
+		}
+	}
+
+	return &http.Client{
+		Timeout: o.timeout,
+		Transport: &baseTransport{
+			scheme: scheme,
+			host:   c.GetName(),
+			next:   transport,
+		},
+	}
+}
+
+func (t *baseTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Host != "" {
+		return t.next.RoundTrip(req)
+	}
+
+	r := req.Clone(req.Context())
+	r.URL.Scheme, r.URL.Host = t.scheme, t.host
+
+	if r.Host == "" {
+		r.Host = t.host
+	}
+
+	return t.next.RoundTrip(r)
+}
+
+// isContainerHost - host - имя или псевдоним контейнера
+func isContainerHost(c Container, host string) bool {
+	if strings.EqualFold(host, c.GetName()) {
+		return true
+	}
+
+	for _, alias := range ExtendContainer(c).GetAliases() {
+		if strings.EqualFold(host, alias) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// endpointOf - адрес порта контейнера, доступный вызывающему процессу
+func endpointOf(c Container, port ports.PortName) string {
+	if callerInContainer(c) {
+		return c.ContainerAddrs()[port]
+	}
+
+	return c.HostAddrs()[port]
+}
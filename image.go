@@ -8,6 +8,9 @@ import (
 	"gopkg.in/gomisc/errors.v1"
 )
 
+// defaultDockerfile - имя Dockerfile, если ImageBuildData.Dockerfile не задан
+const defaultDockerfile = "Dockerfile"
+
 // Images пакет имен образов и опций их подготовки (скачивание, сборка, etc)
 type (
 	// ImageOption - опция действия при отсутствии указанного образа
@@ -28,6 +31,17 @@ type (
 		Nocache    bool
 		ClearRoot  bool
 		Output     io.Writer
+		// Platform - целевая платформа сборки в форме os/arch[/variant]
+		Platform string
+		// NoProxyEnv - не передавать сборке переменные прокси окружения процесса
+		NoProxyEnv bool
+		// Labels - метки собираемого образа, например для отбора PruneImages
+		Labels map[string]string
+		// Provenance, SBOM - запросить у BuildKit аттестации сборки: происхождение
+		// (SLSA, без значений аргументов сборки) и перечень пакетов образа (SPDX),
+		// см. ImageDetails.Attestations
+		Provenance bool
+		SBOM       bool
 	}
 
 	// ImageOptions опционал действий при отсутствии указанного докер образа
@@ -37,17 +51,38 @@ type (
 		Err        error
 		ForceBuild bool
 		Pull       bool
+		// Platform - платформа скачиваемого образа в форме os/arch[/variant]
+		Platform string
+		// Auth - источники учетных данных реестра для скачивания
+		Auth []RegistryAuthProvider
 	}
 )
 
-// WithPullImage - опция скачивания образа при его отсутствии
-func WithPullImage(tag string) ImageOption {
+// WithPullImage - опция скачивания образа при его отсутствии,
+// ссылка на образ проходит через ResolveImage. Учетные данные берутся
+// из первого источника auth, вернувшего их, без источников - анонимно
+func WithPullImage(tag string, auth ...RegistryAuthProvider) ImageOption {
 	return func(o *ImageOptions) {
-		o.Tags = append(o.Tags, tag)
+		o.Tags = append(o.Tags, ResolveImage(tag))
+		o.Auth = append(o.Auth, auth...)
 		o.Pull = true
 	}
 }
 
+// WithPlatform - оборачивает опцию образа, задавая платформу скачивания
+// или сборки, например linux/amd64 для запуска amd64 образов на arm64 хосте
+func WithPlatform(platform string, opt ImageOption) ImageOption {
+	return func(o *ImageOptions) {
+		opt(o)
+
+		o.Platform = platform
+
+		if o.Data != nil {
+			o.Data.Platform = platform
+		}
+	}
+}
+
 // WithBuildImage - опция сборки образа при его отсутствии
 func WithBuildImage(preparer ImageBuildPreparer, forceBuild bool) ImageOption {
 	return func(o *ImageOptions) {
@@ -56,53 +91,77 @@ func WithBuildImage(preparer ImageBuildPreparer, forceBuild bool) ImageOption {
 	}
 }
 
+// CheckImages - скачивает или собирает отсутствующие образы, наличие всех
+// образов проверяется одним запросом к локальному стору
 func CheckImages(cli Client, opts ...ImageOption) error {
 	actions := processImageOptions(opts...)
+	refs := make([]string, 0, len(actions))
 
 	for i := 0; i < len(actions); i++ {
-		action := actions[i]
-
-		if len(action.Tags) == 0 {
+		if len(actions[i].Tags) == 0 {
 			continue
 		}
 
-		if action.Err != nil {
-			return errors.Ctx().Strings("tags", action.Tags).
-				Wrap(action.Err, "process image")
+		if actions[i].Err != nil {
+			return errors.Ctx().Strings("tags", actions[i].Tags).
+				Wrap(actions[i].Err, "process image")
 		}
 
-		exist, err := cli.FindImageLocal(context.Background(), action.Tags[0])
-		if err != nil {
-			return errors.Ctx().Str("tag", action.Tags[0]).Wrap(err, "find image in local cache")
+		refs = append(refs, actions[i].Tags[0])
+	}
+
+	if len(refs) == 0 {
+		return nil
+	}
+
+	exist, err := ExtendClient(cli).FindImagesLocal(context.Background(), refs)
+	if err != nil {
+		return errors.Ctx().Strings("tags", refs).Wrap(err, "find images in local cache")
+	}
+
+	for i := 0; i < len(actions); i++ {
+		action := actions[i]
+
+		if len(action.Tags) == 0 || (exist[action.Tags[0]] && !action.ForceBuild) {
+			continue
 		}
 
-		if !exist || action.ForceBuild {
-			if action.Pull {
-				return cli.PullImage(action.Tags[0])
+		if action.Pull {
+			auth, authErr := resolveAuth(action.Tags[0], action.Auth)
+			if authErr != nil {
+				return authErr
 			}
 
-			if action.Data != nil {
-				var prevLatest string
+			err = ExtendClient(cli).PullImageWith(
+				context.Background(), PullOptions{Image: action.Tags[0], Auth: auth, Platform: action.Platform},
+			)
+			if err != nil {
+				return errors.Ctx().Str("tag", action.Tags[0]).Wrap(err, "pull image")
+			}
 
-				for i := 0; i < len(action.Data.Tags); i++ {
-					if strings.Contains(action.Data.Tags[i], ":latest") {
-						prevLatest = action.Data.Tags[i]
-						break
-					}
-				}
+			continue
+		}
 
-				if prevLatest != "" {
-					cli.RemoveImage(prevLatest)
-				}
+		if action.Data == nil {
+			continue
+		}
 
-				if err = cli.BuildImage(action.Data); err != nil {
-					return errors.Wrap(err, "build image")
-				}
+		var prevLatest string
 
-				return nil
+		for i := 0; i < len(action.Data.Tags); i++ {
+			if strings.Contains(action.Data.Tags[i], ":latest") {
+				prevLatest = action.Data.Tags[i]
+				break
 			}
 		}
 
+		if prevLatest != "" {
+			cli.RemoveImage(prevLatest)
+		}
+
+		if err = cli.BuildImage(action.Data); err != nil {
+			return errors.Wrap(err, "build image")
+		}
 	}
 
 	return nil
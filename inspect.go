@@ -0,0 +1,94 @@
+package containers
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"gopkg.in/gomisc/errors.v1"
+)
+
+// Состояния контейнера в InspectResult
+const (
+	StateCreated    = "created"
+	StateRunning    = "running"
+	StatePaused     = "paused"
+	StateRestarting = "restarting"
+	StateExited     = "exited"
+	StateDead       = "dead"
+)
+
+// Состояния проверки здоровья контейнера, пусто - проверка не задана
+const (
+	HealthStarting  = "starting"
+	HealthHealthy   = "healthy"
+	HealthUnhealthy = "unhealthy"
+)
+
+type (
+	// InspectResult - текущее состояние контейнера в среде исполнения
+	InspectResult struct {
+		ID    string `json:"id" yaml:"id"`
+		Name  string `json:"name" yaml:"name"`
+		Image string `json:"image" yaml:"image"`
+		// Status - одно из состояний State*
+		Status    string `json:"status" yaml:"status"`
+		ExitCode  int    `json:"exit_code" yaml:"exit_code"`
+		OOMKilled bool   `json:"oom_killed,omitempty" yaml:"oom_killed,omitempty"`
+		// Pid - идентификатор основного процесса на хосте демона (0 - не запущен)
+		Pid int `json:"pid,omitempty" yaml:"pid,omitempty"`
+		// Error - ошибка среды исполнения при запуске процесса
+		Error string `json:"error,omitempty" yaml:"error,omitempty"`
+		// Health - одно из состояний Health*
+		Health     string    `json:"health,omitempty" yaml:"health,omitempty"`
+		Restarts   int       `json:"restarts" yaml:"restarts"`
+		StartedAt  time.Time `json:"started_at,omitempty" yaml:"started_at,omitempty"`
+		FinishedAt time.Time `json:"finished_at,omitempty" yaml:"finished_at,omitempty"`
+		Mounts     []Mount   `json:"mounts,omitempty" yaml:"mounts,omitempty"`
+		// PortBinds - фактические привязки портов на хосте (с назначенными портами)
+		PortBinds PortMap                     `json:"port_binds,omitempty" yaml:"port_binds,omitempty"`
+		Networks  map[string]EndpointSettings `json:"networks,omitempty" yaml:"networks,omitempty"`
+	}
+
+	// Mount - подключенный к контейнеру раздел
+	Mount struct {
+		// Type - тип раздела (bind, volume, tmpfs)
+		Type string `json:"type" yaml:"type"`
+		// Source - путь хоста, для тома - имя тома
+		Source      string `json:"source,omitempty" yaml:"source,omitempty"`
+		Destination string `json:"destination" yaml:"destination"`
+		ReadOnly    bool   `json:"read_only,omitempty" yaml:"read_only,omitempty"`
+	}
+)
+
+// TmpfsMount - сведения о разделе в памяти target с опциями монтирования options
+func TmpfsMount(target, options string) Mount {
+	m := Mount{Type: "tmpfs", Destination: target}
+
+	for _, opt := range strings.Split(options, ",") {
+		if opt == "ro" {
+			m.ReadOnly = true
+		}
+	}
+
+	return m
+}
+
+// Running - признак работающего (в том числе замороженного) процесса
+func (r *InspectResult) Running() bool {
+	return r.Status == StateRunning || r.Status == StatePaused
+}
+
+// Inspect - запрашивает текущее состояние контейнера
+func (c *BaseContainer) Inspect(ctx context.Context) (*InspectResult, error) {
+	if c.containerID == "" {
+		return nil, errors.Ctx().Str("container-name", c.GetName()).Just(ErrContainerNotRunning)
+	}
+
+	result, err := c.runtime().ContainerInspect(ctx, c.containerID)
+	if err != nil {
+		return nil, errors.Ctx().Str("container-name", c.GetName()).Wrap(err, "inspect container")
+	}
+
+	return result, nil
+}
@@ -85,14 +85,16 @@ type (
 		StreamLogs(ctx context.Context, id string, stderr, stdout io.Writer, follow bool) error
 		// FindImageLocal - осуществляет поиск образа в локальном сторе
 		FindImageLocal(ctx context.Context, image string) (bool, error)
-		// PullImage - скачивает образ в локальный стор
+		// PullImage - скачивает образ в локальный стор; в герметичном режиме
+		// возвращает ошибку Hermetic().CheckPull
 		PullImage(image string) error
 		// RemoveImage - удаляет образ из локального стора
 		RemoveImage(image string)
 		// BuildImage - собирает образ
 		BuildImage(data *ImageBuildData) error
 		// CheckNetwork проверяет существование сети и создает
-		// ее в случае отсутствия
+		// ее в случае отсутствия; cidr - подсеть или подсети IPv4 и IPv6
+		// через запятую, подсеть IPv6 включает в сети второй стек адресов
 		CheckNetwork(nw, cidr string) (Network, error)
 	}
 
@@ -0,0 +1,132 @@
+package containers
+
+import (
+	"io"
+	"strings"
+
+	"gopkg.in/gomisc/errors.v1"
+)
+
+// ExportKubernetes - записывает топологию оркестратора как набор манифестов
+// Kubernetes: для каждого контейнера ConfigMap с переменными окружения,
+// Deployment с одной репликой и Service с его портами. Sysctls и монтирование
+// каталогов хоста не переносятся: в кластере они требуют отдельной политики
+func (o *Orchestrator) ExportKubernetes(w io.Writer) error {
+	y := newYAMLWriter(w)
+
+	var written int
+
+	for _, m := range o.Members() {
+		// процессы хоста в кластер не переносятся
+		if m.Container.GetImage() == "" {
+			continue
+		}
+
+		if written != 0 {
+			y.separator()
+		}
+
+		writeKubernetesObjects(y, m.Container)
+		written++
+	}
+
+	if err := y.flush(); err != nil {
+		return errors.Wrap(err, "write kubernetes manifests")
+	}
+
+	return nil
+}
+
+func writeKubernetesObjects(y *yamlWriter, c Container) {
+	name := kubernetesName(c.GetName())
+	envs := c.GetEnvs()
+	containerPorts := c.ContainerPorts()
+
+	if len(envs) != 0 {
+		y.value(0, "apiVersion", "v1")
+		y.value(0, "kind", "ConfigMap")
+		y.key(0, "metadata")
+		y.value(1, "name", name+"-env")
+		y.key(0, "data")
+
+		for _, env := range envs {
+			k, v, _ := strings.Cut(env, "=")
+			y.value(1, k, v)
+		}
+
+		y.separator()
+	}
+
+	y.value(0, "apiVersion", "apps/v1")
+	y.value(0, "kind", "Deployment")
+	y.key(0, "metadata")
+	y.value(1, "name", name)
+	y.key(0, "spec")
+	y.line(1, "replicas: 1")
+	y.key(1, "selector")
+	y.key(2, "matchLabels")
+	y.value(3, "app", name)
+	y.key(1, "template")
+	y.key(2, "metadata")
+	y.key(3, "labels")
+	y.value(4, "app", name)
+	y.key(2, "spec")
+	y.key(3, "containers")
+	y.line(4, "- name: "+quote(name))
+	y.value(5, "image", c.GetImage())
+
+	if entrypoint := c.GetEntryPoint(); entrypoint != "" {
+		y.list(5, "command", strings.Fields(entrypoint))
+	}
+
+	y.list(5, "args", c.GetCmd())
+
+	if len(envs) != 0 {
+		y.key(5, "envFrom")
+		y.line(6, "- configMapRef:")
+		y.value(8, "name", name+"-env")
+	}
+
+	if len(containerPorts) != 0 {
+		y.key(5, "ports")
+
+		for _, p := range containerPorts {
+			y.line(6, "- containerPort: "+p.Port())
+			y.value(7, "protocol", strings.ToUpper(p.Proto()))
+		}
+	}
+
+	if len(containerPorts) == 0 {
+		return
+	}
+
+	y.separator()
+	y.value(0, "apiVersion", "v1")
+	y.value(0, "kind", "Service")
+	y.key(0, "metadata")
+	y.value(1, "name", name)
+	y.key(0, "spec")
+	y.key(1, "selector")
+	y.value(2, "app", name)
+	y.key(1, "ports")
+
+	for _, p := range containerPorts {
+		y.line(2, "- name: "+quote(p.Proto()+"-"+p.Port()))
+		y.line(3, "port: "+p.Port())
+		y.line(3, "targetPort: "+p.Port())
+		y.value(3, "protocol", strings.ToUpper(p.Proto()))
+	}
+}
+
+// kubernetesName - имя объекта в форме DNS-1123
+func kubernetesName(name string) string {
+	name = strings.Trim(strings.ReplaceAll(serviceName(name), "_", "-"), "-")
+
+	const maxNameLen = 63
+
+	if len(name) > maxNameLen {
+		name = strings.TrimRight(name[:maxNameLen], "-")
+	}
+
+	return name
+}
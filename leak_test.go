@@ -0,0 +1,68 @@
+package containers_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.uber.org/goleak"
+
+	"gopkg.in/gomisc/containers.v1/adapters/fake"
+	"gopkg.in/gomisc/containers.v1/wait"
+)
+
+func TestBackgroundStopLeavesNoGoroutines(t *testing.T) {
+	defer goleak.VerifyNone(t, goleak.IgnoreCurrent())
+
+	_, c := newTestContainer(t, "leak-stop")
+	c.Readiness = wait.Immediately()
+
+	if err := c.Run(context.Background()); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	if err := c.Stop(); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+}
+
+func TestBackgroundExitLeavesNoGoroutines(t *testing.T) {
+	defer goleak.VerifyNone(t, goleak.IgnoreCurrent())
+
+	cli, c := newTestContainer(t, "leak-exit")
+	c.Readiness = wait.Immediately()
+
+	cli.Script(c.Name, fake.Script{ExitAfter: 20 * time.Millisecond})
+
+	if err := c.Run(context.Background()); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	// результат ожидания никто не читает: горутина ожидания должна
+	// завершиться сама после выхода процесса и остановки контейнера
+	time.Sleep(100 * time.Millisecond)
+
+	if err := c.Stop(); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+}
+
+func TestContainerWaitCancelLeavesNoGoroutines(t *testing.T) {
+	defer goleak.VerifyNone(t, goleak.IgnoreCurrent())
+
+	cli, c := newTestContainer(t, "leak-wait")
+	c.Readiness = wait.Immediately()
+
+	if err := c.Run(context.Background()); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	defer func() { _ = c.Stop() }()
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	// каналы ContainerWait не читаются, отмена контекста освобождает горутину
+	_, _ = cli.ContainerWait(ctx, c.GetID())
+
+	cancel()
+}
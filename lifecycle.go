@@ -0,0 +1,122 @@
+package containers
+
+import (
+	"context"
+	"time"
+
+	"gopkg.in/gomisc/errors.v1"
+)
+
+// DefaultRestartTimeout - время на штатное завершение процесса при Restart,
+// по истечении которого процесс принудительно завершается
+const DefaultRestartTimeout = 10 * time.Second
+
+// Restart - перезапускает процесс контейнера и дожидается его готовности
+// (не дольше StartTimeout и дедлайна ctx). Горутина StartContainer при
+// перезапуске не завершается; трансляция логов прежнего процесса закрывается,
+// подключить ее заново можно через FollowLogs
+func (c *BaseContainer) Restart(ctx context.Context) error {
+	if c.containerID == "" {
+		return errors.Ctx().Str("container-name", c.GetName()).Just(ErrContainerNotRunning)
+	}
+
+	done := c.beginRestart()
+	defer close(done)
+
+	if err := c.runtime().ContainerRestart(ctx, c.containerID, DefaultRestartTimeout); err != nil {
+		return errors.Ctx().Str("container-name", c.GetName()).Wrap(err, "restart container")
+	}
+
+	c.detachLogs()
+	c.sampleStats()
+
+	readyCtx, cancel := context.WithTimeout(ctx, c.StartTimeout)
+	defer cancel()
+
+	if err := <-c.Readiness(readyCtx); err != nil {
+		return errors.And(
+			errors.Ctx().Str("container-name", c.GetName()).Just(ErrContainerNotReady),
+			err,
+		)
+	}
+
+	return nil
+}
+
+// Signal - отправляет сигнал основному процессу контейнера: SIGHUP для
+// перечитывания конфигурации, SIGKILL для аварийного завершения и т.п.
+// В отличие от Stop контейнер не ожидается и трансляция логов не закрывается
+func (c *BaseContainer) Signal(ctx context.Context, sig string) error {
+	if c.containerID == "" {
+		return errors.Ctx().Str("container-name", c.GetName()).Just(ErrContainerNotRunning)
+	}
+
+	if err := c.runtime().ContainerKill(ctx, c.containerID, sig); err != nil {
+		return errors.Ctx().Str("container-name", c.GetName()).Wrap(err, "signal container")
+	}
+
+	return nil
+}
+
+// Pause - замораживает все процессы контейнера, соединения с ним остаются
+// открытыми, но не обслуживаются до вызова Unpause
+func (c *BaseContainer) Pause(ctx context.Context) error {
+	if c.containerID == "" {
+		return errors.Ctx().Str("container-name", c.GetName()).Just(ErrContainerNotRunning)
+	}
+
+	if err := c.runtime().ContainerPause(ctx, c.containerID); err != nil {
+		return errors.Ctx().Str("container-name", c.GetName()).Wrap(err, "pause container")
+	}
+
+	NetworkRegistry(c.network).SetState(c.containerID, StatePaused, "")
+
+	return nil
+}
+
+// Unpause - возобновляет процессы контейнера, замороженные Pause
+func (c *BaseContainer) Unpause(ctx context.Context) error {
+	if c.containerID == "" {
+		return errors.Ctx().Str("container-name", c.GetName()).Just(ErrContainerNotRunning)
+	}
+
+	if err := c.runtime().ContainerUnpause(ctx, c.containerID); err != nil {
+		return errors.Ctx().Str("container-name", c.GetName()).Wrap(err, "unpause container")
+	}
+
+	NetworkRegistry(c.network).SetState(c.containerID, StateRunning, "")
+
+	return nil
+}
+
+// beginRestart - отмечает начало перезапуска, возвращает канал, закрываемый
+// по его завершении
+func (c *BaseContainer) beginRestart() chan struct{} {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.restarts++
+	c.restarted = make(chan struct{})
+
+	return c.restarted
+}
+
+func (c *BaseContainer) restartGeneration() uint64 {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.restarts
+}
+
+// restartedSince - канал завершения перезапуска, начатого после generation,
+// или nil, если перезапусков не было
+func (c *BaseContainer) restartedSince(generation uint64) <-chan struct{} {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.restarts == generation {
+		return nil
+	}
+
+	return c.restarted
+}
@@ -0,0 +1,55 @@
+package containers
+
+import (
+	"strings"
+	"time"
+)
+
+type (
+	// ListFilter - условия отбора контейнеров ContainerList, пустые условия
+	// не ограничивают выборку
+	ListFilter struct {
+		// Labels - метки контейнера, пустое значение - наличие метки с любым значением
+		Labels map[string]string
+		// NamePrefix - префикс имени контейнера
+		NamePrefix string
+		// Status - допустимые состояния контейнера (State*)
+		Status []string
+	}
+
+	// ContainerSummary - краткие сведения о контейнере
+	ContainerSummary struct {
+		ID      string            `json:"id" yaml:"id"`
+		Name    string            `json:"name" yaml:"name"`
+		Image   string            `json:"image" yaml:"image"`
+		Status  string            `json:"status" yaml:"status"`
+		Labels  map[string]string `json:"labels,omitempty" yaml:"labels,omitempty"`
+		Created time.Time         `json:"created" yaml:"created"`
+	}
+)
+
+// Match - проверяет сведения о контейнере на соответствие условиям
+func (f ListFilter) Match(s *ContainerSummary) bool {
+	if !strings.HasPrefix(s.Name, f.NamePrefix) {
+		return false
+	}
+
+	for k, v := range f.Labels {
+		actual, ok := s.Labels[k]
+		if !ok || (v != "" && actual != v) {
+			return false
+		}
+	}
+
+	if len(f.Status) == 0 {
+		return true
+	}
+
+	for _, status := range f.Status {
+		if s.Status == status {
+			return true
+		}
+	}
+
+	return false
+}
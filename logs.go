@@ -0,0 +1,231 @@
+package containers
+
+import (
+	"bytes"
+	"io"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/gomisc/errors.v1"
+
+	"gopkg.in/gomisc/containers.v1/wait"
+)
+
+// Потоки вывода строк Logs
+const (
+	LogStdout = "stdout"
+	LogStderr = "stderr"
+)
+
+type (
+	// Logs - захваченный вывод контейнера построчно с временем получения и
+	// потоком каждой строки. Подключается потоками Stdout и Stderr к
+	// OutputStream/ErrorStream или FollowLogs; проверки ожидают появления
+	// строк не дольше заданного времени вместо пауз с последующим поиском:
+	//
+	//	logs := containers.NewLogs()
+	//	err := cont.FollowLogs(logs.Stdout(), logs.Stderr())
+	//	...
+	//	err = logs.Contains(`listening on :\d+`, 10*time.Second)
+	Logs struct {
+		mu      sync.Mutex
+		raw     bytes.Buffer
+		lines   []LogLine
+		open    map[string]int
+		changed chan struct{}
+	}
+
+	// LogLine - строка вывода контейнера без перевода строки
+	LogLine struct {
+		// Time - время получения первого байта строки
+		Time time.Time `json:"time" yaml:"time"`
+		// Stream - LogStdout или LogStderr
+		Stream string `json:"stream" yaml:"stream"`
+		Text   string `json:"text" yaml:"text"`
+	}
+
+	logStream struct {
+		logs   *Logs
+		stream string
+	}
+)
+
+// NewLogs - создает пустой захват вывода
+func NewLogs() *Logs {
+	return &Logs{
+		open:    make(map[string]int),
+		changed: make(chan struct{}),
+	}
+}
+
+// Stdout - поток записи стандартного вывода
+func (l *Logs) Stdout() io.Writer {
+	return &logStream{logs: l, stream: LogStdout}
+}
+
+// Stderr - поток записи вывода ошибок
+func (l *Logs) Stderr() io.Writer {
+	return &logStream{logs: l, stream: LogStderr}
+}
+
+// Write - запись в стандартный вывод, Logs можно передать как io.Writer
+func (l *Logs) Write(p []byte) (int, error) {
+	return l.write(LogStdout, p)
+}
+
+// String - весь захваченный вывод в порядке записи
+func (l *Logs) String() string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.raw.String()
+}
+
+// Lines - захваченные строки в порядке получения, включая незавершенные
+func (l *Logs) Lines() []LogLine {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return append([]LogLine(nil), l.lines...)
+}
+
+// Between - строки, полученные в интервале [from, to]; нулевая граница не
+// ограничивает интервал
+func (l *Logs) Between(from, to time.Time) []LogLine {
+	var result []LogLine
+
+	for _, line := range l.Lines() {
+		if !from.IsZero() && line.Time.Before(from) {
+			continue
+		}
+
+		if !to.IsZero() && line.Time.After(to) {
+			continue
+		}
+
+		result = append(result, line)
+	}
+
+	return result
+}
+
+// Contains - ожидает строку, соответствующую регулярному выражению pattern,
+// не дольше within; строки, полученные до вызова, учитываются
+func (l *Logs) Contains(pattern string, within time.Duration) error {
+	_, err := l.InOrder(within, pattern)
+
+	return err
+}
+
+// InOrder - ожидает не дольше within строки, соответствующие patterns в
+// заданном порядке: каждая следующая строка получена после предыдущей.
+// Возвращает найденные строки; ошибка содержит первый ненайденный шаблон
+func (l *Logs) InOrder(within time.Duration, patterns ...string) ([]LogLine, error) {
+	exprs := make([]*regexp.Regexp, len(patterns))
+
+	for i, pattern := range patterns {
+		expr, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, errors.Ctx().Str("pattern", pattern).Wrap(err, "compile log pattern")
+		}
+
+		exprs[i] = expr
+	}
+
+	timer := time.NewTimer(within)
+	defer timer.Stop()
+
+	for {
+		l.mu.Lock()
+		l.init()
+		found, next := l.match(exprs)
+		changed := l.changed
+		l.mu.Unlock()
+
+		if next == len(exprs) {
+			return found, nil
+		}
+
+		select {
+		case <-changed:
+		case <-timer.C:
+			return found, errors.Ctx().
+				Str("message", patterns[next]).
+				Int("position", next).
+				Str("within", within.String()).
+				Just(wait.ErrLogMessageNotFound)
+		}
+	}
+}
+
+// match - строки, последовательно соответствующие exprs, и номер первого
+// ненайденного выражения. Вызывается под l.mu
+func (l *Logs) match(exprs []*regexp.Regexp) ([]LogLine, int) {
+	found := make([]LogLine, 0, len(exprs))
+	next := 0
+
+	for i := 0; i < len(l.lines) && next < len(exprs); i++ {
+		if exprs[next].MatchString(l.lines[i].Text) {
+			found = append(found, l.lines[i])
+			next++
+		}
+	}
+
+	return found, next
+}
+
+// write - дописывает p к незавершенной строке потока stream и будит
+// ожидающие проверки
+func (l *Logs) write(stream string, p []byte) (int, error) {
+	now, n := time.Now(), len(p)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.init()
+	l.raw.Write(p)
+
+	for len(p) != 0 {
+		chunk := p
+		end := bytes.IndexByte(p, '\n')
+
+		if end >= 0 {
+			chunk, p = p[:end], p[end+1:]
+		} else {
+			p = nil
+		}
+
+		idx, ok := l.open[stream]
+		if !ok {
+			idx = len(l.lines)
+			l.lines = append(l.lines, LogLine{Time: now, Stream: stream})
+		}
+
+		l.lines[idx].Text += strings.TrimSuffix(string(chunk), "\r")
+
+		if end >= 0 {
+			delete(l.open, stream)
+		} else {
+			l.open[stream] = idx
+		}
+	}
+
+	close(l.changed)
+	l.changed = make(chan struct{})
+
+	return n, nil
+}
+
+// init - подготавливает Logs, созданный без NewLogs. Вызывается под l.mu
+func (l *Logs) init() {
+	if l.open == nil {
+		l.open = make(map[string]int)
+		l.changed = make(chan struct{})
+	}
+}
+
+func (s *logStream) Write(p []byte) (int, error) {
+	return s.logs.write(s.stream, p)
+}
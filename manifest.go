@@ -0,0 +1,141 @@
+package containers
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"sort"
+
+	"gopkg.in/gomisc/errors.v1"
+	"gopkg.in/gomisc/network.v1/ports"
+)
+
+// ManifestFileEnvar - путь к файлу, в который оркестратор пишет манифест окружения после старта
+const ManifestFileEnvar = "CONTAINERS_MANIFEST"
+
+type (
+	// Manifest - машиночитаемое описание поднятого окружения
+	Manifest struct {
+		Containers []ManifestContainer `json:"containers"`
+	}
+
+	// ManifestContainer - описание контейнера окружения
+	ManifestContainer struct {
+		Name           string                    `json:"name"`
+		ID             string                    `json:"id"`
+		Image          string                    `json:"image"`
+		ImageDigest    string                    `json:"image_digest,omitempty"`
+		Network        string                    `json:"network,omitempty"`
+		ContainerIP    string                    `json:"container_ip,omitempty"`
+		Ports          []ManifestPort            `json:"ports,omitempty"`
+		HostAddrs      map[ports.PortName]string `json:"host_addrs,omitempty"`
+		ContainerAddrs map[ports.PortName]string `json:"container_addrs,omitempty"`
+		Labels         map[string]string         `json:"labels,omitempty"`
+	}
+
+	// ManifestPort - порт контейнера и его привязка на хосте
+	ManifestPort struct {
+		Container Port   `json:"container"`
+		HostIP    string `json:"host_ip,omitempty"`
+		HostPort  string `json:"host_port,omitempty"`
+	}
+)
+
+// Manifest - формирует манифест окружения
+func (o *Orchestrator) Manifest(ctx context.Context) (*Manifest, error) {
+	members := o.Members()
+	manifest := &Manifest{Containers: make([]ManifestContainer, 0, len(members))}
+
+	for _, m := range members {
+		// контейнеры вне профилей не запускались
+		if m.Skipped() {
+			continue
+		}
+
+		c := m.Container
+		mc := ManifestContainer{
+			Name:           c.GetName(),
+			ID:             c.GetID(),
+			Image:          c.GetImage(),
+			ContainerIP:    c.GetContainerIP(),
+			HostAddrs:      c.HostAddrs(),
+			ContainerAddrs: c.ContainerAddrs(),
+		}
+
+		if labels := ExtendContainer(c).GetLabels(); len(labels) != 0 {
+			mc.Labels = make(map[string]string, len(labels))
+
+			for k, v := range labels {
+				mc.Labels[k] = v
+			}
+		}
+
+		if nw := c.GetNetwork(); nw != nil {
+			mc.Network = nw.Name()
+		}
+
+		if c.GetImage() != "" {
+			// клиент без дайджестов образов: манифест пишется без них
+			digest, err := ExtendClient(o.cli).ImageDigest(ctx, c.GetImage())
+			if err != nil && !errors.Is(err, ErrUnsupportedOperation) {
+				return nil, errors.Ctx().Str("name", c.GetName()).Wrap(err, "get image digest")
+			}
+
+			mc.ImageDigest = digest
+		}
+
+		for port, binds := range c.PortMap() {
+			mp := ManifestPort{Container: port}
+
+			if len(binds) != 0 {
+				mp.HostIP, mp.HostPort = binds[0].HostIP, binds[0].HostPort
+			}
+
+			mc.Ports = append(mc.Ports, mp)
+		}
+
+		sort.Slice(
+			mc.Ports, func(i, j int) bool {
+				return mc.Ports[i].Container < mc.Ports[j].Container
+			},
+		)
+
+		manifest.Containers = append(manifest.Containers, mc)
+	}
+
+	return manifest, nil
+}
+
+// WriteManifest - пишет манифест окружения в формате JSON
+func (o *Orchestrator) WriteManifest(ctx context.Context, w io.Writer) error {
+	manifest, err := o.Manifest(ctx)
+	if err != nil {
+		return err
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+
+	if err = enc.Encode(manifest); err != nil {
+		return errors.Wrap(err, "encode environment manifest")
+	}
+
+	return nil
+}
+
+// WriteManifestFile - пишет манифест окружения в файл
+func (o *Orchestrator) WriteManifestFile(ctx context.Context, path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return errors.Ctx().Str("path", path).Wrap(err, "create manifest file")
+	}
+
+	if err = o.WriteManifest(ctx, f); err != nil {
+		_ = f.Close()
+
+		return err
+	}
+
+	return f.Close()
+}
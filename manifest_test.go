@@ -0,0 +1,79 @@
+package containers_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"testing"
+
+	"gopkg.in/gomisc/containers.v1"
+	"gopkg.in/gomisc/containers.v1/wait"
+)
+
+func TestManifestLabels(t *testing.T) {
+	cli, c := newTestContainer(t, "manifest-labels")
+	c.Readiness = wait.Immediately()
+	c.Labels = map[string]string{"team": "storage"}
+
+	o := containers.NewOrchestrator(cli)
+
+	if err := o.Add(c); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+
+	if err := o.Up(context.Background()); err != nil {
+		t.Fatalf("Up: %v", err)
+	}
+
+	t.Cleanup(func() { _ = o.Down() })
+
+	var buf bytes.Buffer
+
+	if err := o.WriteManifest(context.Background(), &buf); err != nil {
+		t.Fatalf("WriteManifest: %v", err)
+	}
+
+	var manifest containers.Manifest
+
+	if err := json.Unmarshal(buf.Bytes(), &manifest); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+
+	if len(manifest.Containers) != 1 {
+		t.Fatalf("manifest containers: got %d, want 1", len(manifest.Containers))
+	}
+
+	if got := manifest.Containers[0].Labels["team"]; got != "storage" {
+		t.Fatalf("manifest label team: got %q, want %q", got, "storage")
+	}
+}
+
+func TestManifestWithoutImageDigest(t *testing.T) {
+	cli, c := newTestContainer(t, "manifest-v1")
+	c.Readiness = wait.Immediately()
+
+	// клиент v1 без ImageDigest
+	o := containers.NewOrchestrator(struct{ containers.Client }{cli})
+
+	if err := o.Add(c); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+
+	t.Setenv(containers.ManifestFileEnvar, filepath.Join(t.TempDir(), "manifest.json"))
+
+	if err := o.Up(context.Background()); err != nil {
+		t.Fatalf("Up: %v", err)
+	}
+
+	t.Cleanup(func() { _ = o.Down() })
+
+	manifest, err := o.Manifest(context.Background())
+	if err != nil {
+		t.Fatalf("Manifest: %v", err)
+	}
+
+	if len(manifest.Containers) != 1 || manifest.Containers[0].ImageDigest != "" {
+		t.Fatalf("manifest containers: %+v", manifest.Containers)
+	}
+}
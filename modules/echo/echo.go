@@ -0,0 +1,269 @@
+// Package echo - утилитарный контейнер для сетевых тестов: TCP эхо, HTTP эхо
+// с API сохраненных запросов и приемник UDP датаграмм. Образ собирается без
+// доступа к сети из встроенных исходников: сервер компилируется локальным
+// go toolchain и упаковывается в образ FROM scratch через BuildImage
+package echo
+
+import (
+	"context"
+	"crypto/sha256"
+	_ "embed"
+	"encoding/hex"
+	"encoding/json"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"time"
+
+	"gopkg.in/gomisc/containers.v1"
+	"gopkg.in/gomisc/containers.v1/wait"
+	"gopkg.in/gomisc/errors.v1"
+	"gopkg.in/gomisc/network.v1/ports"
+)
+
+// Порты контейнера
+const (
+	TCPPort  = 7000
+	HTTPPort = 8080
+	UDPPort  = 9000
+
+	PortTCP  ports.PortName = "tcp"
+	PortHTTP ports.PortName = "http"
+	PortUDP  ports.PortName = "udp"
+
+	// ImageRepository - репозиторий образа, тег - хеш встроенных исходников
+	ImageRepository = "containers-echo"
+
+	// ErrUnexpectedStatus - API сохраненных данных вернуло неожиданный статус
+	ErrUnexpectedStatus = errors.Const("unexpected echo api status")
+
+	dockerfile = "FROM scratch\nCOPY echo /echo\nENTRYPOINT [\"/echo\"]\n"
+	goMod      = "module echofixture\n\ngo 1.20\n"
+)
+
+//go:embed fixture/main.go
+var source []byte
+
+type (
+	// Echo - контейнер утилитарного сервера
+	Echo struct {
+		*containers.BaseContainer
+	}
+
+	// Request - HTTP запрос, сохраненный сервером
+	Request struct {
+		Method string              `json:"method"`
+		Path   string              `json:"path"`
+		Query  string              `json:"query,omitempty"`
+		Header map[string][]string `json:"header,omitempty"`
+		Body   string              `json:"body,omitempty"`
+		Time   time.Time           `json:"time"`
+	}
+
+	// Datagram - UDP датаграмма, принятая сервером
+	Datagram struct {
+		From string    `json:"from"`
+		Data string    `json:"data"`
+		Time time.Time `json:"time"`
+	}
+)
+
+// Image - ссылка на образ для текущей версии встроенных исходников
+func Image() string {
+	sum := sha256.Sum256(source)
+
+	return ImageRepository + ":" + hex.EncodeToString(sum[:6])
+}
+
+// EnsureImage - собирает образ, если его нет в локальном сторе
+func EnsureImage(ctx context.Context, cli containers.Client) error {
+	exist, err := cli.FindImageLocal(ctx, Image())
+	if err != nil {
+		return errors.Ctx().Str("image", Image()).Wrap(err, "find echo image")
+	}
+
+	if exist {
+		return nil
+	}
+
+	data, err := BuildData(ctx, cli)
+	if err != nil {
+		return err
+	}
+
+	if err = cli.BuildImage(data); err != nil {
+		return errors.Ctx().Str("image", Image()).Wrap(err, "build echo image")
+	}
+
+	return nil
+}
+
+// BuildData - компилирует сервер под архитектуру демона и готовит контекст
+// сборки образа; каталог контекста удаляется после сборки
+func BuildData(ctx context.Context, cli containers.Client) (*containers.ImageBuildData, error) {
+	arch := runtime.GOARCH
+
+	if info, err := containers.ExtendClient(cli).Info(ctx); err == nil && info.Architecture != "" {
+		arch = goArch(info.Architecture)
+	}
+
+	root, err := os.MkdirTemp("", "containers-echo-")
+	if err != nil {
+		return nil, errors.Wrap(err, "create echo build root")
+	}
+
+	files := map[string][]byte{
+		"main.go":    source,
+		"go.mod":     []byte(goMod),
+		"Dockerfile": []byte(dockerfile),
+	}
+
+	for name, content := range files {
+		if err = os.WriteFile(filepath.Join(root, name), content, 0o644); err != nil { //nolint:gosec
+			_ = os.RemoveAll(root)
+
+			return nil, errors.Ctx().Str("file", name).Wrap(err, "write echo build file")
+		}
+	}
+
+	cmd := exec.CommandContext(ctx, "go", "build", "-trimpath", "-ldflags", "-s -w", "-o", "echo", ".")
+	cmd.Dir = root
+	cmd.Env = append(os.Environ(), "CGO_ENABLED=0", "GOOS=linux", "GOARCH="+arch, "GOWORK=off", "GOFLAGS=-mod=mod")
+
+	if out, buildErr := cmd.CombinedOutput(); buildErr != nil {
+		_ = os.RemoveAll(root)
+
+		return nil, errors.Ctx().Str("output", string(out)).Wrap(buildErr, "compile echo server")
+	}
+
+	return &containers.ImageBuildData{
+		Tags:       []string{Image()},
+		Root:       root,
+		Dockerfile: "Dockerfile",
+		ClearRoot:  true,
+	}, nil
+}
+
+// New - описание контейнера; образ должен быть собран EnsureImage,
+// alias - DNS имя сервера в сети топологии
+func New(cli containers.Client, nw containers.Network, alias string) (*Echo, error) {
+	e := &Echo{BaseContainer: containers.NewBaseContainer(cli, nw, nil)}
+	e.Name = alias
+	e.Image = Image()
+	e.Aliases = []string{alias}
+	// в образе нет оболочки и sysctl не нужен
+	e.NoIPForward = true
+
+	for _, p := range []struct {
+		name  ports.PortName
+		port  uint16
+		proto string
+	}{
+		{PortTCP, TCPPort, "tcp"},
+		{PortHTTP, HTTPPort, "tcp"},
+		{PortUDP, UDPPort, "udp"},
+	} {
+		hostPort, err := containers.FreeHostPort()
+		if err != nil {
+			return nil, errors.Wrap(err, "get echo host port")
+		}
+
+		e.Ports = append(
+			e.Ports, containers.PortBind{
+				Name:      p.name,
+				Container: containers.NewPort(p.port, p.proto),
+				Host:      hostPort,
+			},
+		)
+	}
+
+	// UDP порт соединением не проверить, готовность - по TCP портам
+	e.Readiness = wait.ForListeningPorts(
+		func() []string {
+			return []string{e.Endpoint(PortTCP), e.Endpoint(PortHTTP)}
+		},
+	)
+
+	return e, nil
+}
+
+// URL - базовый адрес HTTP эха для вызывающего процесса
+func (e *Echo) URL() string {
+	return "http://" + e.Endpoint(PortHTTP)
+}
+
+// InternalURL - базовый адрес HTTP эха для контейнеров топологии
+func (e *Echo) InternalURL() string {
+	return "http://" + net.JoinHostPort(e.Aliases[0], strconv.Itoa(HTTPPort))
+}
+
+// Requests - HTTP запросы, сохраненные сервером
+func (e *Echo) Requests(ctx context.Context) ([]Request, error) {
+	var list []Request
+
+	return list, e.api(ctx, http.MethodGet, "/__requests", &list)
+}
+
+// Datagrams - UDP датаграммы, принятые сервером
+func (e *Echo) Datagrams(ctx context.Context) ([]Datagram, error) {
+	var list []Datagram
+
+	return list, e.api(ctx, http.MethodGet, "/__datagrams", &list)
+}
+
+// Reset - очищает сохраненные запросы и датаграммы
+func (e *Echo) Reset(ctx context.Context) error {
+	if err := e.api(ctx, http.MethodDelete, "/__requests", nil); err != nil {
+		return err
+	}
+
+	return e.api(ctx, http.MethodDelete, "/__datagrams", nil)
+}
+
+func (e *Echo) api(ctx context.Context, method, path string, result any) error {
+	req, err := http.NewRequestWithContext(ctx, method, path, http.NoBody)
+	if err != nil {
+		return errors.Ctx().Str("path", path).Wrap(err, "make echo api request")
+	}
+
+	resp, err := containers.HTTPClientFor(e, PortHTTP).Do(req)
+	if err != nil {
+		return errors.Ctx().Str("path", path).Wrap(err, "call echo api")
+	}
+
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	if resp.StatusCode/100 != 2 {
+		return errors.Ctx().Str("path", path).Int("status", resp.StatusCode).Just(ErrUnexpectedStatus)
+	}
+
+	if result == nil {
+		return nil
+	}
+
+	if err = json.NewDecoder(resp.Body).Decode(result); err != nil {
+		return errors.Ctx().Str("path", path).Wrap(err, "decode echo api response")
+	}
+
+	return nil
+}
+
+// goArch - архитектура Go по архитектуре, которую сообщает демон
+func goArch(arch string) string {
+	switch arch {
+	case "x86_64":
+		return "amd64"
+	case "aarch64":
+		return "arm64"
+	case "armv7l":
+		return "arm"
+	default:
+		return arch
+	}
+}
@@ -0,0 +1,149 @@
+// Command fixture - сервер утилитарного контейнера modules/echo: TCP эхо,
+// HTTP эхо с сохранением запросов и приемник UDP датаграмм. Собирается
+// статически и упаковывается в образ FROM scratch без доступа к сети
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+type (
+	// request - сохраненный HTTP запрос
+	request struct {
+		Method string              `json:"method"`
+		Path   string              `json:"path"`
+		Query  string              `json:"query,omitempty"`
+		Header map[string][]string `json:"header,omitempty"`
+		Body   string              `json:"body,omitempty"`
+		Time   time.Time           `json:"time"`
+	}
+
+	// datagram - принятая UDP датаграмма
+	datagram struct {
+		From string    `json:"from"`
+		Data string    `json:"data"`
+		Time time.Time `json:"time"`
+	}
+
+	store struct {
+		mu        sync.Mutex
+		requests  []request
+		datagrams []datagram
+	}
+)
+
+func main() {
+	tcpAddr := flag.String("tcp", ":7000", "tcp echo address")
+	httpAddr := flag.String("http", ":8080", "http echo address")
+	udpAddr := flag.String("udp", ":9000", "udp sink address")
+	flag.Parse()
+
+	s := &store{}
+
+	go serveTCP(*tcpAddr)
+	go serveUDP(*udpAddr, s)
+
+	log.Printf("echo fixture ready: tcp %s, http %s, udp %s", *tcpAddr, *httpAddr, *udpAddr)
+
+	server := &http.Server{Addr: *httpAddr, Handler: s, ReadHeaderTimeout: 10 * time.Second}
+	log.Fatal(server.ListenAndServe())
+}
+
+func serveTCP(addr string) {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		go func() {
+			defer conn.Close()
+
+			_, _ = io.Copy(conn, conn)
+		}()
+	}
+}
+
+func serveUDP(addr string, s *store) {
+	conn, err := net.ListenPacket("udp", addr)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	buf := make([]byte, 65535)
+
+	for {
+		n, from, err := conn.ReadFrom(buf)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		s.mu.Lock()
+		s.datagrams = append(s.datagrams, datagram{From: from.String(), Data: string(buf[:n]), Time: time.Now()})
+		s.mu.Unlock()
+	}
+}
+
+// ServeHTTP - /__requests и /__datagrams отдают (GET) и очищают (DELETE)
+// сохраненные данные, остальные запросы сохраняются и возвращаются в JSON
+func (s *store) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/__requests":
+		s.serveList(w, r, func() any { return s.requests }, func() { s.requests = nil })
+
+		return
+	case "/__datagrams":
+		s.serveList(w, r, func() any { return s.datagrams }, func() { s.datagrams = nil })
+
+		return
+	}
+
+	body, _ := io.ReadAll(r.Body)
+	req := request{
+		Method: r.Method,
+		Path:   r.URL.Path,
+		Query:  r.URL.RawQuery,
+		Header: r.Header,
+		Body:   string(body),
+		Time:   time.Now(),
+	}
+
+	s.mu.Lock()
+	s.requests = append(s.requests, req)
+	s.mu.Unlock()
+
+	writeJSON(w, req)
+}
+
+func (s *store) serveList(w http.ResponseWriter, r *http.Request, list func() any, reset func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, list())
+	case http.MethodDelete:
+		reset()
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,200 @@
+// Package etcdcluster - кластер etcd из нескольких узлов с общей начальной
+// конфигурацией: узлы адресуют друг друга по DNS именам в сети топологии,
+// а готовность кластера определяется наличием кворума
+package etcdcluster
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"gopkg.in/gomisc/containers.v1"
+	"gopkg.in/gomisc/containers.v1/wait"
+	"gopkg.in/gomisc/errors.v1"
+	"gopkg.in/gomisc/network.v1/ports"
+)
+
+// Настройки кластера по умолчанию
+const (
+	DefaultImage = "quay.io/coreos/etcd:v3.5.9"
+	ClientPort   = 2379
+	PeerPort     = 2380
+
+	// PortClient - имя клиентского порта узла
+	PortClient ports.PortName = "client"
+
+	ErrInvalidSize = errors.Const("invalid etcd cluster size")
+	ErrUnhealthy   = errors.Const("etcd node is not healthy")
+)
+
+type (
+	// Cluster - узлы кластера etcd в порядке подъема
+	Cluster struct {
+		Nodes []*containers.BaseContainer
+	}
+
+	// Option - опция кластера
+	Option func(c *Cluster)
+)
+
+// WithImage - задает образ узлов
+func WithImage(image string) Option {
+	return func(c *Cluster) {
+		for _, node := range c.Nodes {
+			node.Image = image
+		}
+	}
+}
+
+// New - создает описание кластера из n узлов с именами name-0..name-<n-1>.
+// Узлы поднимаются последовательно, поэтому готовность каждого, кроме
+// последнего, - открытый клиентский порт, а готовность последнего - кворум:
+// все узлы отвечают healthy на /health
+func New(cli containers.Client, nw containers.Network, name string, n int, opts ...Option) (*Cluster, error) {
+	if n < 1 {
+		return nil, errors.Ctx().Int("size", n).Just(ErrInvalidSize)
+	}
+
+	c := &Cluster{Nodes: make([]*containers.BaseContainer, n)}
+	peers := make([]string, n)
+
+	for i := range peers {
+		peers[i] = nodeName(name, i) + "=http://" + net.JoinHostPort(nodeName(name, i), strconv.Itoa(PeerPort))
+	}
+
+	for i := 0; i < n; i++ {
+		hostPort, err := containers.FreeHostPort()
+		if err != nil {
+			return nil, errors.Wrap(err, "get etcd host port")
+		}
+
+		alias := nodeName(name, i)
+
+		node := containers.NewBaseContainer(cli, nw, nil)
+		node.Name = alias
+		node.Image = DefaultImage
+		node.Aliases = []string{alias}
+		node.Envs = []string{
+			"ETCD_NAME=" + alias,
+			"ETCD_INITIAL_CLUSTER=" + strings.Join(peers, ","),
+			"ETCD_INITIAL_CLUSTER_STATE=new",
+			"ETCD_INITIAL_CLUSTER_TOKEN=" + name,
+			"ETCD_LISTEN_PEER_URLS=http://0.0.0.0:" + strconv.Itoa(PeerPort),
+			"ETCD_LISTEN_CLIENT_URLS=http://0.0.0.0:" + strconv.Itoa(ClientPort),
+			"ETCD_INITIAL_ADVERTISE_PEER_URLS=http://" + net.JoinHostPort(alias, strconv.Itoa(PeerPort)),
+			"ETCD_ADVERTISE_CLIENT_URLS=http://" + net.JoinHostPort(alias, strconv.Itoa(ClientPort)),
+		}
+		node.Ports = containers.PortBinds{
+			{
+				Name:      PortClient,
+				Container: containers.NewPort(ClientPort, "tcp"),
+				Host:      hostPort,
+			},
+		}
+
+		c.Nodes[i] = node
+	}
+
+	for i, node := range c.Nodes {
+		node := node
+
+		node.Readiness = wait.ForListeningPorts(
+			func() []string {
+				return []string{node.Endpoint(PortClient)}
+			},
+		)
+
+		if i == n-1 {
+			node.Readiness = wait.For(wait.DefaultBackoff, c.checkQuorum)
+		}
+	}
+
+	for _, apply := range opts {
+		apply(c)
+	}
+
+	return c, nil
+}
+
+// Add - добавляет узлы кластера в оркестратор в порядке подъема
+func (c *Cluster) Add(o *containers.Orchestrator, opts ...containers.MemberOption) error {
+	for _, node := range c.Nodes {
+		if err := o.Add(node, opts...); err != nil {
+			return errors.Ctx().Str("node", node.Name).Wrap(err, "add etcd node")
+		}
+	}
+
+	return nil
+}
+
+// Endpoints - клиентские адреса узлов для вызывающего процесса
+func (c *Cluster) Endpoints() []string {
+	endpoints := make([]string, len(c.Nodes))
+
+	for i, node := range c.Nodes {
+		endpoints[i] = "http://" + node.Endpoint(PortClient)
+	}
+
+	return endpoints
+}
+
+// InternalEndpoints - клиентские адреса узлов для контейнеров топологии
+func (c *Cluster) InternalEndpoints() []string {
+	endpoints := make([]string, len(c.Nodes))
+
+	for i, node := range c.Nodes {
+		endpoints[i] = "http://" + net.JoinHostPort(node.Aliases[0], strconv.Itoa(ClientPort))
+	}
+
+	return endpoints
+}
+
+// checkQuorum - каждый узел отвечает healthy, что возможно только при
+// выбранном лидере
+func (c *Cluster) checkQuorum(ctx context.Context) error {
+	for _, node := range c.Nodes {
+		if err := checkHealth(ctx, node); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func checkHealth(ctx context.Context, node *containers.BaseContainer) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "/health", http.NoBody)
+	if err != nil {
+		return errors.Wrap(err, "make etcd health request")
+	}
+
+	resp, err := containers.HTTPClientFor(node, PortClient).Do(req)
+	if err != nil {
+		return errors.Ctx().Str("node", node.Name).Wrap(err, "request etcd health")
+	}
+
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	var health struct {
+		Health string `json:"health"`
+		Reason string `json:"reason"`
+	}
+
+	if err = json.NewDecoder(resp.Body).Decode(&health); err != nil {
+		return errors.Ctx().Str("node", node.Name).Wrap(err, "decode etcd health")
+	}
+
+	if health.Health != "true" {
+		return errors.Ctx().Str("node", node.Name).Str("reason", health.Reason).Just(ErrUnhealthy)
+	}
+
+	return nil
+}
+
+func nodeName(name string, i int) string {
+	return name + "-" + strconv.Itoa(i)
+}
@@ -0,0 +1,191 @@
+// Command fixture - ретранслятор контейнера modules/hosttunnel: принимает
+// соединения контейнеров на портах хоста и передает их вызывающему процессу.
+// В режиме -forward соединение устанавливается с указанным адресом хоста
+// напрямую, иначе - через заранее открытые вызывающим процессом обратные
+// соединения на управляющий порт. Обратное соединение начинается с байта
+// приветствия, соединения без него (например проверки готовности) сразу
+// закрываются; в свободное соединение пишется номер порта (2 байта,
+// big endian), после чего оно используется как поток данных
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"flag"
+	"io"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// idleTimeout - время ожидания свободного обратного соединения
+	idleTimeout = 10 * time.Second
+	// helloTimeout - время ожидания приветствия в новом обратном соединении
+	helloTimeout = 5 * time.Second
+	// aliveTimeout - время чтения, за которое закрытое соединение сообщает EOF
+	aliveTimeout = 10 * time.Millisecond
+	// hello - байт приветствия обратного соединения
+	hello = 0x7f
+)
+
+func main() {
+	portsFlag := flag.String("ports", "", "comma separated ports to relay")
+	control := flag.String("control", ":7999", "reverse connections address")
+	forward := flag.String("forward", "", "host to dial directly instead of reverse connections")
+	flag.Parse()
+
+	idle := make(chan net.Conn, 64)
+
+	for _, p := range strings.Split(*portsFlag, ",") {
+		port, err := strconv.ParseUint(strings.TrimSpace(p), 10, 16)
+		if err != nil {
+			log.Fatalf("invalid port %q: %v", p, err)
+		}
+
+		l, err := net.Listen("tcp", ":"+strconv.FormatUint(port, 10))
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		go serve(l, uint16(port), *forward, idle)
+	}
+
+	l, err := net.Listen("tcp", *control)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	log.Printf("hosttunnel fixture ready: ports %s, forward %q", *portsFlag, *forward)
+
+	for {
+		conn, acceptErr := l.Accept()
+		if acceptErr != nil {
+			log.Fatal(acceptErr)
+		}
+
+		if *forward != "" {
+			// в прямом режиме управляющий порт служит только для проверки готовности
+			_ = conn.Close()
+
+			continue
+		}
+
+		go register(conn, idle)
+	}
+}
+
+// register - добавляет соединение в пул свободных, если оно начинается
+// с приветствия; проверки готовности закрываются, не дожидаясь порта
+func register(conn net.Conn, idle chan<- net.Conn) {
+	buf := make([]byte, 1)
+
+	_ = conn.SetReadDeadline(time.Now().Add(helloTimeout))
+
+	if _, err := io.ReadFull(conn, buf); err != nil || buf[0] != hello {
+		_ = conn.Close()
+
+		return
+	}
+
+	_ = conn.SetReadDeadline(time.Time{})
+
+	idle <- conn
+}
+
+func serve(l net.Listener, port uint16, forward string, idle <-chan net.Conn) {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		go func() {
+			upstream, dialErr := open(port, forward, idle)
+			if dialErr != nil {
+				log.Printf("port %d: %v", port, dialErr)
+				_ = conn.Close()
+
+				return
+			}
+
+			pipe(conn, upstream)
+		}()
+	}
+}
+
+// open - соединение с вызывающим процессом для порта port
+func open(port uint16, forward string, idle <-chan net.Conn) (net.Conn, error) {
+	if forward != "" {
+		return net.Dial("tcp", net.JoinHostPort(forward, strconv.Itoa(int(port))))
+	}
+
+	timer := time.NewTimer(idleTimeout)
+	defer timer.Stop()
+
+	header := make([]byte, 2)
+	binary.BigEndian.PutUint16(header, port)
+
+	for {
+		select {
+		case conn := <-idle:
+			// обратное соединение могло быть закрыто вызывающим процессом:
+			// запись в такое соединение удается, поэтому проверяется чтение
+			if !alive(conn) {
+				_ = conn.Close()
+
+				continue
+			}
+
+			if _, err := conn.Write(header); err != nil {
+				_ = conn.Close()
+
+				continue
+			}
+
+			return conn, nil
+		case <-timer.C:
+			return nil, io.ErrNoProgress
+		}
+	}
+}
+
+// alive - свободное соединение не закрыто: вызывающий процесс ничего не пишет
+// в него до получения порта, поэтому живое соединение отвечает таймаутом чтения
+func alive(conn net.Conn) bool {
+	_ = conn.SetReadDeadline(time.Now().Add(aliveTimeout))
+	defer func() { _ = conn.SetReadDeadline(time.Time{}) }()
+
+	var netErr net.Error
+
+	_, err := conn.Read(make([]byte, 1))
+
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+func pipe(a, b net.Conn) {
+	var wg sync.WaitGroup
+
+	wg.Add(2)
+
+	copyHalf := func(dst, src net.Conn) {
+		defer wg.Done()
+
+		_, _ = io.Copy(dst, src)
+
+		if tcp, ok := dst.(*net.TCPConn); ok {
+			_ = tcp.CloseWrite()
+		}
+	}
+
+	go copyHalf(a, b)
+	go copyHalf(b, a)
+
+	wg.Wait()
+
+	_ = a.Close()
+	_ = b.Close()
+}
@@ -0,0 +1,433 @@
+// Package hosttunnel - доступ контейнеров к портам хоста вызывающего процесса
+// под постоянным DNS именем в сети топологии, например для вебхуков, которые
+// тестируемый сервис отправляет обратно тесту. Имя принадлежит контейнеру
+// ретранслятора: при локальном демоне он соединяется с хостом через запись
+// host-gateway, а при удаленном демоне (или если вызывающий процесс сам
+// запущен в контейнере) - через обратные соединения, которые вызывающий
+// процесс заранее открывает на опубликованный управляющий порт. Образ
+// собирается из встроенных исходников так же, как образ modules/echo
+package hosttunnel
+
+import (
+	"context"
+	"crypto/sha256"
+	_ "embed"
+	"encoding/binary"
+	"encoding/hex"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/gomisc/containers.v1"
+	"gopkg.in/gomisc/containers.v1/wait"
+	"gopkg.in/gomisc/errors.v1"
+	"gopkg.in/gomisc/network.v1/ports"
+)
+
+const (
+	// DefaultAlias - DNS имя хоста в сети топологии по умолчанию
+	DefaultAlias = "host.containers.internal"
+	// DefaultIdleConns - число заранее открытых обратных соединений
+	DefaultIdleConns = 4
+
+	// ControlPort - управляющий порт ретранслятора
+	ControlPort = 7999
+	// PortControl - имя управляющего порта
+	PortControl ports.PortName = "control"
+
+	// ImageRepository - репозиторий образа, тег - хеш встроенных исходников
+	ImageRepository = "containers-hosttunnel"
+
+	// ErrNoPorts - не указаны порты хоста
+	ErrNoPorts = errors.Const("no host ports to tunnel")
+
+	// gatewayName - имя хоста в /etc/hosts ретранслятора в прямом режиме
+	gatewayName = "containers-host-gateway"
+	// reverseTarget - адрес, с которым соединяются обратные соединения
+	reverseTarget = "127.0.0.1"
+	// redialDelay - пауза перед повторным открытием обратного соединения
+	redialDelay = 200 * time.Millisecond
+	// reverseHello - первый байт обратного соединения (см. fixture): без него
+	// ретранслятор не считает соединение свободным, поэтому соединения проверки
+	// готовности не попадают в пул
+	reverseHello = 0x7f
+
+	dockerfile = "FROM scratch\nCOPY relay /relay\nENTRYPOINT [\"/relay\"]\n"
+	goMod      = "module hosttunnelfixture\n\ngo 1.20\n"
+)
+
+//go:embed fixture/main.go
+var source []byte
+
+type (
+	// Tunnel - контейнер ретранслятора портов хоста
+	Tunnel struct {
+		*containers.BaseContainer
+
+		hostPorts []uint16
+		reverse   bool
+		idleConns int
+
+		mu     sync.Mutex
+		cancel context.CancelFunc
+		wg     sync.WaitGroup
+	}
+
+	// Option - опция ретранслятора
+	Option func(t *Tunnel)
+)
+
+// WithAlias - DNS имя хоста в сети топологии вместо DefaultAlias
+func WithAlias(alias string) Option {
+	return func(t *Tunnel) {
+		t.Aliases = []string{alias}
+	}
+}
+
+// WithReverse - использовать обратные соединения и при локальном демоне,
+// например если файрвол хоста не пропускает соединения из сети контейнеров
+func WithReverse() Option {
+	return func(t *Tunnel) {
+		t.reverse = true
+	}
+}
+
+// WithIdleConns - число заранее открытых обратных соединений, ограничивает
+// число одновременно устанавливаемых соединений из контейнеров
+func WithIdleConns(n int) Option {
+	return func(t *Tunnel) {
+		t.idleConns = n
+	}
+}
+
+// Image - ссылка на образ для текущей версии встроенных исходников
+func Image() string {
+	sum := sha256.Sum256(source)
+
+	return ImageRepository + ":" + hex.EncodeToString(sum[:6])
+}
+
+// EnsureImage - собирает образ, если его нет в локальном сторе
+func EnsureImage(ctx context.Context, cli containers.Client) error {
+	exist, err := cli.FindImageLocal(ctx, Image())
+	if err != nil {
+		return errors.Ctx().Str("image", Image()).Wrap(err, "find hosttunnel image")
+	}
+
+	if exist {
+		return nil
+	}
+
+	data, err := BuildData(ctx, cli)
+	if err != nil {
+		return err
+	}
+
+	if err = cli.BuildImage(data); err != nil {
+		return errors.Ctx().Str("image", Image()).Wrap(err, "build hosttunnel image")
+	}
+
+	return nil
+}
+
+// BuildData - компилирует ретранслятор под архитектуру демона и готовит
+// контекст сборки образа; каталог контекста удаляется после сборки
+func BuildData(ctx context.Context, cli containers.Client) (*containers.ImageBuildData, error) {
+	arch := runtime.GOARCH
+
+	if info, err := containers.ExtendClient(cli).Info(ctx); err == nil && info.Architecture != "" {
+		arch = goArch(info.Architecture)
+	}
+
+	root, err := os.MkdirTemp("", "containers-hosttunnel-")
+	if err != nil {
+		return nil, errors.Wrap(err, "create hosttunnel build root")
+	}
+
+	files := map[string][]byte{
+		"main.go":    source,
+		"go.mod":     []byte(goMod),
+		"Dockerfile": []byte(dockerfile),
+	}
+
+	for name, content := range files {
+		if err = os.WriteFile(filepath.Join(root, name), content, 0o644); err != nil { //nolint:gosec
+			_ = os.RemoveAll(root)
+
+			return nil, errors.Ctx().Str("file", name).Wrap(err, "write hosttunnel build file")
+		}
+	}
+
+	cmd := exec.CommandContext(ctx, "go", "build", "-trimpath", "-ldflags", "-s -w", "-o", "relay", ".")
+	cmd.Dir = root
+	cmd.Env = append(os.Environ(), "CGO_ENABLED=0", "GOOS=linux", "GOARCH="+arch, "GOWORK=off", "GOFLAGS=-mod=mod")
+
+	if out, buildErr := cmd.CombinedOutput(); buildErr != nil {
+		_ = os.RemoveAll(root)
+
+		return nil, errors.Ctx().Str("output", string(out)).Wrap(buildErr, "compile hosttunnel relay")
+	}
+
+	return &containers.ImageBuildData{
+		Tags:       []string{Image()},
+		Root:       root,
+		Dockerfile: "Dockerfile",
+		ClearRoot:  true,
+	}, nil
+}
+
+// New - описание ретранслятора портов hostPorts хоста: контейнеры сети nw
+// соединяются с alias:port, где port - порт хоста. Образ должен быть собран
+// EnsureImage. В прямом режиме сервер вызывающего процесса должен слушать
+// адрес, доступный из сети контейнеров (например 0.0.0.0), в режиме
+// обратных соединений достаточно 127.0.0.1
+func New(
+	ctx context.Context, cli containers.Client, nw containers.Network, hostPorts []uint16, opts ...Option,
+) (*Tunnel, error) {
+	if len(hostPorts) == 0 {
+		return nil, ErrNoPorts
+	}
+
+	t := &Tunnel{
+		BaseContainer: containers.NewBaseContainer(cli, nw, nil),
+		hostPorts:     append([]uint16(nil), hostPorts...),
+		idleConns:     DefaultIdleConns,
+	}
+	t.Image = Image()
+	t.Aliases = []string{DefaultAlias}
+	// в образе нет оболочки и sysctl не нужен
+	t.NoIPForward = true
+
+	for _, apply := range opts {
+		apply(t)
+	}
+
+	t.Name = strings.ReplaceAll(t.Aliases[0], ".", "-")
+
+	if !t.reverse {
+		info, err := containers.ExtendClient(cli).Info(ctx)
+		if err != nil {
+			return nil, errors.Wrap(err, "get daemon info")
+		}
+
+		// хост демона не совпадает с хостом вызывающего процесса
+		t.reverse = info.Remote || cli.IsInContainer()
+	}
+
+	list := make([]string, 0, len(hostPorts))
+	for _, p := range hostPorts {
+		list = append(list, strconv.Itoa(int(p)))
+	}
+
+	t.Cmd = []string{"-ports", strings.Join(list, ",")}
+
+	if !t.reverse {
+		t.Cmd = append(t.Cmd, "-forward", gatewayName)
+		t.ExtraHosts = append(t.ExtraHosts, gatewayName+":"+containers.HostGateway)
+	}
+
+	controlPort, err := containers.FreeHostPort()
+	if err != nil {
+		return nil, errors.Wrap(err, "get hosttunnel control port")
+	}
+
+	t.Ports = append(
+		t.Ports, containers.PortBind{
+			Name:      PortControl,
+			Container: containers.NewPort(ControlPort, "tcp"),
+			Host:      controlPort,
+		},
+	)
+
+	ready := wait.ForListeningPorts(
+		func() []string {
+			return []string{t.Endpoint(PortControl)}
+		},
+	)
+
+	// обратные соединения открываются после каждого запуска ретранслятора
+	t.Readiness = func(ctx context.Context) <-chan error {
+		out := make(chan error, 1)
+
+		go func() {
+			err := <-ready(ctx)
+			if err == nil && t.reverse {
+				t.dialBack()
+			}
+
+			out <- err
+		}()
+
+		return out
+	}
+
+	return t, nil
+}
+
+// Reverse - признак режима обратных соединений
+func (t *Tunnel) Reverse() bool {
+	return t.reverse
+}
+
+// Addr - адрес порта хоста для контейнеров топологии
+func (t *Tunnel) Addr(hostPort uint16) string {
+	return net.JoinHostPort(t.Aliases[0], strconv.Itoa(int(hostPort)))
+}
+
+// Stop - закрывает обратные соединения и останавливает ретранслятор
+func (t *Tunnel) Stop() error {
+	t.mu.Lock()
+	cancel := t.cancel
+	t.cancel = nil
+	t.mu.Unlock()
+
+	if cancel != nil {
+		cancel()
+		t.wg.Wait()
+	}
+
+	return t.BaseContainer.Stop()
+}
+
+// dialBack - запускает пул обратных соединений, если он еще не запущен
+func (t *Tunnel) dialBack() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.cancel != nil {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.cancel = cancel
+
+	for i := 0; i < t.idleConns; i++ {
+		t.wg.Add(1)
+
+		go func() {
+			defer t.wg.Done()
+
+			t.serveBack(ctx)
+		}()
+	}
+}
+
+// serveBack - держит одно свободное обратное соединение: после получения
+// номера порта соединение передается серверу хоста и открывается новое
+func (t *Tunnel) serveBack(ctx context.Context) {
+	var dialer net.Dialer
+
+	for ctx.Err() == nil {
+		conn, err := dialer.DialContext(ctx, "tcp", t.Endpoint(PortControl))
+		if err != nil {
+			sleep(ctx, redialDelay)
+
+			continue
+		}
+
+		if _, err = conn.Write([]byte{reverseHello}); err != nil {
+			_ = conn.Close()
+			sleep(ctx, redialDelay)
+
+			continue
+		}
+
+		header, err := readHeader(ctx, conn)
+		if err != nil {
+			_ = conn.Close()
+			sleep(ctx, redialDelay)
+
+			continue
+		}
+
+		port := strconv.Itoa(int(header))
+
+		go func() {
+			upstream, dialErr := dialer.DialContext(ctx, "tcp", net.JoinHostPort(reverseTarget, port))
+			if dialErr != nil {
+				_ = conn.Close()
+
+				return
+			}
+
+			pipe(conn, upstream)
+		}()
+	}
+}
+
+// readHeader - ждет номер порта в свободном обратном соединении,
+// отмена контекста закрывает соединение
+func readHeader(ctx context.Context, conn net.Conn) (uint16, error) {
+	done := make(chan struct{})
+	defer close(done)
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			_ = conn.Close()
+		case <-done:
+		}
+	}()
+
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return 0, err
+	}
+
+	return binary.BigEndian.Uint16(header), nil
+}
+
+func pipe(a, b net.Conn) {
+	var wg sync.WaitGroup
+
+	wg.Add(2)
+
+	copyHalf := func(dst, src net.Conn) {
+		defer wg.Done()
+
+		_, _ = io.Copy(dst, src)
+
+		if tcp, ok := dst.(*net.TCPConn); ok {
+			_ = tcp.CloseWrite()
+		}
+	}
+
+	go copyHalf(a, b)
+	go copyHalf(b, a)
+
+	wg.Wait()
+
+	_ = a.Close()
+	_ = b.Close()
+}
+
+func sleep(ctx context.Context, d time.Duration) {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+	case <-timer.C:
+	}
+}
+
+// goArch - архитектура Go по архитектуре, которую сообщает демон
+func goArch(arch string) string {
+	switch arch {
+	case "x86_64":
+		return "amd64"
+	case "aarch64":
+		return "arm64"
+	case "armv7l":
+		return "arm"
+	default:
+		return arch
+	}
+}
@@ -0,0 +1,196 @@
+// Package kafkacluster - кластер Kafka в режиме KRaft (без ZooKeeper): каждый
+// узел совмещает роли контроллера и брокера, брокеры общаются через
+// внутренний листенер по DNS именам сети топологии, а клиенты вызывающего
+// процесса подключаются через внешний листенер с портом хоста
+package kafkacluster
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"net"
+	"strconv"
+	"strings"
+
+	"gopkg.in/gomisc/containers.v1"
+	"gopkg.in/gomisc/containers.v1/wait"
+	"gopkg.in/gomisc/errors.v1"
+	"gopkg.in/gomisc/network.v1/ports"
+)
+
+// Настройки кластера по умолчанию
+const (
+	DefaultImage   = "bitnami/kafka:3.5"
+	InternalPort   = 9092
+	ControllerPort = 9093
+	ExternalPort   = 9094
+
+	// PortExternal - имя порта листенера для клиентов вызывающего процесса
+	PortExternal ports.PortName = "external"
+
+	ErrInvalidSize = errors.Const("invalid kafka cluster size")
+
+	// maxReplication - предельный фактор репликации служебных топиков
+	maxReplication = 3
+)
+
+type (
+	// Cluster - узлы кластера Kafka в порядке подъема
+	Cluster struct {
+		Nodes []*containers.BaseContainer
+		// ClusterID - идентификатор кластера KRaft, общий для всех узлов
+		ClusterID string
+	}
+
+	// Option - опция кластера
+	Option func(c *Cluster)
+)
+
+// WithImage - задает образ узлов (совместимый с bitnami/kafka по переменным окружения)
+func WithImage(image string) Option {
+	return func(c *Cluster) {
+		for _, node := range c.Nodes {
+			node.Image = image
+		}
+	}
+}
+
+// New - создает описание кластера из n узлов с именами name-0..name-<n-1>.
+// Брокер не открывает листенеры до появления кворума контроллеров, поэтому
+// узлы, кроме последнего, считаются готовыми сразу после запуска, а
+// готовность последнего - все n брокеров видны в метаданных кластера
+func New(cli containers.Client, nw containers.Network, name string, n int, opts ...Option) (*Cluster, error) {
+	if n < 1 {
+		return nil, errors.Ctx().Int("size", n).Just(ErrInvalidSize)
+	}
+
+	clusterID, err := newClusterID()
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Cluster{Nodes: make([]*containers.BaseContainer, n), ClusterID: clusterID}
+	voters := make([]string, n)
+
+	for i := range voters {
+		voters[i] = strconv.Itoa(i) + "@" + net.JoinHostPort(nodeName(name, i), strconv.Itoa(ControllerPort))
+	}
+
+	replication := strconv.Itoa(min(n, maxReplication))
+	minISR := strconv.Itoa(min(n, maxReplication-1))
+
+	for i := 0; i < n; i++ {
+		hostPort, portErr := containers.FreeHostPort()
+		if portErr != nil {
+			return nil, errors.Wrap(portErr, "get kafka host port")
+		}
+
+		alias := nodeName(name, i)
+
+		node := containers.NewBaseContainer(cli, nw, nil)
+		node.Name = alias
+		node.Image = DefaultImage
+		node.Aliases = []string{alias}
+		node.Ports = containers.PortBinds{
+			{
+				Name:      PortExternal,
+				Container: containers.NewPort(ExternalPort, "tcp"),
+				Host:      hostPort,
+			},
+		}
+
+		// внешний адрес известен до запуска: адрес хоста сети и выделенный порт
+		external := net.JoinHostPort(node.HostIP(), strconv.Itoa(int(hostPort)))
+
+		node.Envs = []string{
+			"ALLOW_PLAINTEXT_LISTENER=yes",
+			"KAFKA_KRAFT_CLUSTER_ID=" + clusterID,
+			"KAFKA_CFG_NODE_ID=" + strconv.Itoa(i),
+			"KAFKA_CFG_PROCESS_ROLES=controller,broker",
+			"KAFKA_CFG_CONTROLLER_QUORUM_VOTERS=" + strings.Join(voters, ","),
+			"KAFKA_CFG_LISTENERS=INTERNAL://:" + strconv.Itoa(InternalPort) +
+				",CONTROLLER://:" + strconv.Itoa(ControllerPort) +
+				",EXTERNAL://:" + strconv.Itoa(ExternalPort),
+			"KAFKA_CFG_ADVERTISED_LISTENERS=INTERNAL://" + net.JoinHostPort(alias, strconv.Itoa(InternalPort)) +
+				",EXTERNAL://" + external,
+			"KAFKA_CFG_LISTENER_SECURITY_PROTOCOL_MAP=INTERNAL:PLAINTEXT,CONTROLLER:PLAINTEXT,EXTERNAL:PLAINTEXT",
+			"KAFKA_CFG_CONTROLLER_LISTENER_NAMES=CONTROLLER",
+			"KAFKA_CFG_INTER_BROKER_LISTENER_NAME=INTERNAL",
+			"KAFKA_CFG_OFFSETS_TOPIC_REPLICATION_FACTOR=" + replication,
+			"KAFKA_CFG_TRANSACTION_STATE_LOG_REPLICATION_FACTOR=" + replication,
+			"KAFKA_CFG_TRANSACTION_STATE_LOG_MIN_ISR=" + minISR,
+			"KAFKA_CFG_DEFAULT_REPLICATION_FACTOR=" + replication,
+		}
+		node.Readiness = wait.Immediately()
+
+		c.Nodes[i] = node
+	}
+
+	first := c.Nodes[0]
+	c.Nodes[n-1].Readiness = wait.ForKafkaBrokers(
+		func() string {
+			return first.Endpoint(PortExternal)
+		}, n,
+	)
+
+	for _, apply := range opts {
+		apply(c)
+	}
+
+	return c, nil
+}
+
+// Add - добавляет узлы кластера в оркестратор в порядке подъема
+func (c *Cluster) Add(o *containers.Orchestrator, opts ...containers.MemberOption) error {
+	for _, node := range c.Nodes {
+		if err := o.Add(node, opts...); err != nil {
+			return errors.Ctx().Str("node", node.Name).Wrap(err, "add kafka node")
+		}
+	}
+
+	return nil
+}
+
+// BootstrapServers - адреса брокеров для клиентов вызывающего процесса
+func (c *Cluster) BootstrapServers() []string {
+	servers := make([]string, len(c.Nodes))
+
+	for i, node := range c.Nodes {
+		servers[i] = node.Endpoint(PortExternal)
+	}
+
+	return servers
+}
+
+// InternalBootstrapServers - адреса брокеров для контейнеров топологии
+func (c *Cluster) InternalBootstrapServers() []string {
+	servers := make([]string, len(c.Nodes))
+
+	for i, node := range c.Nodes {
+		servers[i] = net.JoinHostPort(node.Aliases[0], strconv.Itoa(InternalPort))
+	}
+
+	return servers
+}
+
+// newClusterID - случайный идентификатор кластера в формате kafka-storage random-uuid
+func newClusterID() (string, error) {
+	var id [16]byte
+
+	if _, err := rand.Read(id[:]); err != nil {
+		return "", errors.Wrap(err, "generate kafka cluster id")
+	}
+
+	return base64.RawURLEncoding.EncodeToString(id[:]), nil
+}
+
+func nodeName(name string, i int) string {
+	return name + "-" + strconv.Itoa(i)
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+
+	return b
+}
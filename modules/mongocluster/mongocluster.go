@@ -0,0 +1,183 @@
+// Package mongocluster - реплика-сет MongoDB из нескольких узлов: члены
+// реплика-сета адресуются по DNS именам сети топологии, набор
+// инициализируется после запуска всех узлов, а готовность - выбранный primary
+package mongocluster
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"gopkg.in/gomisc/containers.v1"
+	"gopkg.in/gomisc/containers.v1/wait"
+	"gopkg.in/gomisc/errors.v1"
+	"gopkg.in/gomisc/network.v1/ports"
+)
+
+// Настройки реплика-сета по умолчанию
+const (
+	DefaultImage      = "mongo:6.0"
+	DefaultReplicaSet = "rs0"
+	MongoPort         = 27017
+
+	// PortMongo - имя порта узла
+	PortMongo ports.PortName = "mongo"
+
+	ErrInvalidSize = errors.Const("invalid mongodb replica set size")
+	ErrNoPrimary   = errors.Const("mongodb replica set has no primary")
+)
+
+// initScript - инициализирует реплика-сет при первом вызове и завершается
+// с ошибкой, пока первый узел не станет primary
+const initScript = `try { rs.status() } catch (e) {
+  if (e.codeName !== 'NotYetInitialized') { throw e }
+  rs.initiate(%s)
+}
+if (!db.hello().isWritablePrimary) { quit(1) }`
+
+type (
+	// Cluster - узлы реплика-сета в порядке подъема
+	Cluster struct {
+		Nodes      []*containers.BaseContainer
+		ReplicaSet string
+	}
+
+	// Option - опция реплика-сета
+	Option func(c *Cluster)
+)
+
+// WithImage - задает образ узлов
+func WithImage(image string) Option {
+	return func(c *Cluster) {
+		for _, node := range c.Nodes {
+			node.Image = image
+		}
+	}
+}
+
+// New - создает описание реплика-сета из n узлов с именами name-0..name-<n-1>.
+// Первый узел получает повышенный приоритет и становится primary, поэтому
+// вызывающий процесс может писать в него напрямую (HostURI). Готовность
+// последнего узла - инициализированный реплика-сет с выбранным primary
+func New(cli containers.Client, nw containers.Network, name string, n int, opts ...Option) (*Cluster, error) {
+	if n < 1 {
+		return nil, errors.Ctx().Int("size", n).Just(ErrInvalidSize)
+	}
+
+	c := &Cluster{Nodes: make([]*containers.BaseContainer, n), ReplicaSet: DefaultReplicaSet}
+
+	for i := 0; i < n; i++ {
+		hostPort, err := containers.FreeHostPort()
+		if err != nil {
+			return nil, errors.Wrap(err, "get mongodb host port")
+		}
+
+		alias := nodeName(name, i)
+
+		node := containers.NewBaseContainer(cli, nw, nil)
+		node.Name = alias
+		node.Image = DefaultImage
+		node.Aliases = []string{alias}
+		node.Cmd = []string{"--replSet", c.ReplicaSet, "--bind_ip_all"}
+		node.Ports = containers.PortBinds{
+			{
+				Name:      PortMongo,
+				Container: containers.NewPort(MongoPort, "tcp"),
+				Host:      hostPort,
+			},
+		}
+		node.Readiness = wait.ForListeningPorts(
+			func() []string {
+				return []string{node.Endpoint(PortMongo)}
+			},
+		)
+
+		c.Nodes[i] = node
+	}
+
+	c.Nodes[n-1].Readiness = wait.For(wait.DefaultBackoff, c.initiate)
+
+	for _, apply := range opts {
+		apply(c)
+	}
+
+	return c, nil
+}
+
+// Add - добавляет узлы реплика-сета в оркестратор в порядке подъема
+func (c *Cluster) Add(o *containers.Orchestrator, opts ...containers.MemberOption) error {
+	for _, node := range c.Nodes {
+		if err := o.Add(node, opts...); err != nil {
+			return errors.Ctx().Str("node", node.Name).Wrap(err, "add mongodb node")
+		}
+	}
+
+	return nil
+}
+
+// URI - строка подключения к реплика-сету для контейнеров топологии
+func (c *Cluster) URI() string {
+	hosts := make([]string, len(c.Nodes))
+
+	for i, node := range c.Nodes {
+		hosts[i] = net.JoinHostPort(node.Aliases[0], strconv.Itoa(MongoPort))
+	}
+
+	return "mongodb://" + strings.Join(hosts, ",") + "/?replicaSet=" + c.ReplicaSet
+}
+
+// HostURI - строка прямого подключения к primary для вызывающего процесса:
+// имена членов реплика-сета вне сети топологии не разрешаются, поэтому
+// обнаружение топологии драйвером отключено
+func (c *Cluster) HostURI() string {
+	return "mongodb://" + c.Nodes[0].Endpoint(PortMongo) + "/?directConnection=true"
+}
+
+// config - конфигурация rs.initiate с адресами членов в сети топологии
+func (c *Cluster) config() string {
+	members := make([]string, len(c.Nodes))
+
+	for i, node := range c.Nodes {
+		priority := 1
+		if i == 0 {
+			priority = 2
+		}
+
+		members[i] = fmt.Sprintf(
+			"{_id: %d, host: '%s', priority: %d}",
+			i, net.JoinHostPort(node.Aliases[0], strconv.Itoa(MongoPort)), priority,
+		)
+	}
+
+	return fmt.Sprintf("{_id: '%s', members: [%s]}", c.ReplicaSet, strings.Join(members, ", "))
+}
+
+// initiate - инициализирует реплика-сет через mongosh первого узла и
+// проверяет, что он стал primary
+func (c *Cluster) initiate(ctx context.Context) error {
+	first := c.Nodes[0]
+
+	var out bytes.Buffer
+
+	code, err := containers.ExtendClient(first.GetClient()).ContainerExec(
+		ctx, first.GetID(),
+		[]string{"mongosh", "--quiet", "--eval", fmt.Sprintf(initScript, c.config())},
+		&out, &out,
+	)
+	if err != nil {
+		return errors.Ctx().Str("node", first.Name).Wrap(err, "exec mongosh")
+	}
+
+	if code != 0 {
+		return errors.Ctx().Str("node", first.Name).Str("output", strings.TrimSpace(out.String())).Just(ErrNoPrimary)
+	}
+
+	return nil
+}
+
+func nodeName(name string, i int) string {
+	return name + "-" + strconv.Itoa(i)
+}
@@ -0,0 +1,104 @@
+// Package openapi - контейнер HTTP заглушки, отвечающей по OpenAPI спецификации
+// (stoplight/prism в режиме mock), для подмены сторонних API внутри топологии
+package openapi
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net"
+	"path/filepath"
+	"strconv"
+
+	"gopkg.in/gomisc/containers.v1"
+	"gopkg.in/gomisc/errors.v1"
+	"gopkg.in/gomisc/network.v1/ports"
+)
+
+// Настройки заглушки по умолчанию
+const (
+	DefaultImage = "stoplight/prism:5"
+	DefaultPort  = 4010
+
+	// PortName - имя http порта заглушки
+	PortName ports.PortName = "http"
+
+	specDir = "/spec"
+)
+
+type (
+	// Stub - контейнер HTTP заглушки
+	Stub struct {
+		*containers.BaseContainer
+	}
+
+	// Option - опция заглушки
+	Option func(s *Stub)
+)
+
+// WithImage - задает образ prism
+func WithImage(image string) Option {
+	return func(s *Stub) {
+		s.Image = image
+	}
+}
+
+// WithDynamic - включает генерацию ответов по схемам вместо примеров спецификации
+func WithDynamic() Option {
+	return func(s *Stub) {
+		s.Cmd = append(s.Cmd, "--dynamic")
+	}
+}
+
+// New - создает описание заглушки для спецификации spec (файл на хосте, монтируется
+// в контейнер); alias - DNS имя заглушки в сети топологии,
+// по которому к ней обращаются другие контейнеры
+func New(cli containers.Client, nw containers.Network, spec, alias string, opts ...Option) (*Stub, error) {
+	specPath, err := filepath.Abs(spec)
+	if err != nil {
+		return nil, errors.Ctx().Str("spec", spec).Wrap(err, "resolve openapi spec path")
+	}
+
+	hostPort, err := containers.FreeHostPort()
+	if err != nil {
+		return nil, errors.Wrap(err, "get stub host port")
+	}
+
+	s := &Stub{BaseContainer: containers.NewBaseContainer(cli, nw, nil)}
+	// имя контейнера уникально: заглушки с одним alias в параллельных тестах
+	// живут в разных сетях, а имена контейнеров у демона общие
+	s.Name = alias + "-" + nameSuffix()
+	s.Image = DefaultImage
+	s.Aliases = []string{alias}
+	s.Mounts = []string{specPath + ":" + specDir + "/" + filepath.Base(specPath)}
+	s.Cmd = []string{"mock", "-h", "0.0.0.0", "-p", strconv.Itoa(DefaultPort), specDir + "/" + filepath.Base(specPath)}
+	s.Ports = containers.PortBinds{
+		{
+			Name:      PortName,
+			Container: containers.NewPort(DefaultPort, "tcp"),
+			Host:      hostPort,
+		},
+	}
+
+	for _, apply := range opts {
+		apply(s)
+	}
+
+	return s, nil
+}
+
+func nameSuffix() string {
+	b := make([]byte, 4)
+	_, _ = rand.Read(b)
+
+	return hex.EncodeToString(b)
+}
+
+// URL - базовый адрес заглушки для вызывающего процесса
+func (s *Stub) URL() string {
+	return "http://" + s.Endpoint(PortName)
+}
+
+// InternalURL - базовый адрес заглушки для контейнеров топологии
+func (s *Stub) InternalURL() string {
+	return "http://" + net.JoinHostPort(s.Aliases[0], strconv.Itoa(DefaultPort))
+}
@@ -0,0 +1,200 @@
+package containers
+
+import (
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	"gopkg.in/gomisc/errors.v1"
+)
+
+// Типы разделов MountSpec
+const (
+	MountBind   = "bind"
+	MountVolume = "volume"
+	MountTmpfs  = "tmpfs"
+)
+
+// Режимы распространения подмонтирований в разделах MountBind
+const (
+	PropagationPrivate  = "private"
+	PropagationRPrivate = "rprivate"
+	PropagationShared   = "shared"
+	PropagationRShared  = "rshared"
+	PropagationSlave    = "slave"
+	PropagationRSlave   = "rslave"
+)
+
+// ErrInvalidMount - описание раздела противоречиво или содержит неизвестные опции
+const ErrInvalidMount = errors.Const("invalid mount spec")
+
+// MountSpec - раздел контейнера. В отличие от записей Mounts в форме
+// src:dst[:opts] поддерживает тома и tmpfs, распространение подмонтирований
+// и опции драйвера тома, а ошибки описания обнаруживаются до создания
+// контейнера, а не приводят к молча пропущенному разделу
+type MountSpec struct {
+	// Type - MountBind (по умолчанию), MountVolume или MountTmpfs
+	Type string `json:"type,omitempty" yaml:"type,omitempty"`
+	// Source - абсолютный путь хоста для MountBind, имя тома для MountVolume
+	// (пусто - анонимный том); для MountTmpfs не задается
+	Source string `json:"source,omitempty" yaml:"source,omitempty"`
+	// Target - абсолютный путь в контейнере
+	Target   string `json:"target" yaml:"target"`
+	ReadOnly bool   `json:"read_only,omitempty" yaml:"read_only,omitempty"`
+	// Propagation - распространение подмонтирований MountBind (Propagation*)
+	Propagation string `json:"propagation,omitempty" yaml:"propagation,omitempty"`
+	// VolumeDriver, VolumeOptions - драйвер тома MountVolume и его опции
+	VolumeDriver  string            `json:"volume_driver,omitempty" yaml:"volume_driver,omitempty"`
+	VolumeOptions map[string]string `json:"volume_options,omitempty" yaml:"volume_options,omitempty"`
+	// TmpfsSize, TmpfsMode - размер в байтах (0 - без ограничения) и права
+	// корня раздела MountTmpfs
+	TmpfsSize int64       `json:"tmpfs_size,omitempty" yaml:"tmpfs_size,omitempty"`
+	TmpfsMode os.FileMode `json:"tmpfs_mode,omitempty" yaml:"tmpfs_mode,omitempty"`
+}
+
+// ParseMount - разбирает запись Mounts в форме src:dst[:opts], opts - ro, rw
+// и режим распространения через запятую
+func ParseMount(s string) (MountSpec, error) {
+	parts := strings.Split(s, ":")
+	if len(parts) != 2 && len(parts) != 3 {
+		return MountSpec{}, errors.Ctx().Str("mount", s).Just(ErrInvalidMount)
+	}
+
+	m := MountSpec{Type: MountBind, Source: parts[0], Target: parts[1]}
+
+	if len(parts) == 3 {
+		for _, opt := range strings.Split(parts[2], ",") {
+			switch opt {
+			case "ro":
+				m.ReadOnly = true
+			case "rw":
+			case PropagationPrivate, PropagationRPrivate, PropagationShared,
+				PropagationRShared, PropagationSlave, PropagationRSlave:
+				m.Propagation = opt
+			default:
+				return MountSpec{}, errors.Ctx().Str("mount", s).Str("option", opt).Just(ErrInvalidMount)
+			}
+		}
+	}
+
+	if err := m.Validate(); err != nil {
+		return MountSpec{}, err
+	}
+
+	return m, nil
+}
+
+// Validate - проверяет согласованность описания раздела
+func (m MountSpec) Validate() error {
+	ctx := errors.Ctx().Str("type", m.Type).Str("source", m.Source).Str("target", m.Target)
+
+	if !path.IsAbs(m.Target) {
+		return ctx.Str("reason", "target is not an absolute path").Just(ErrInvalidMount)
+	}
+
+	bind := m.Type == "" || m.Type == MountBind
+
+	switch {
+	case !bind && m.Type != MountVolume && m.Type != MountTmpfs:
+		return ctx.Str("reason", "unknown mount type").Just(ErrInvalidMount)
+	case bind && !strings.HasPrefix(m.Source, "/"):
+		return ctx.Str("reason", "bind source is not an absolute path").Just(ErrInvalidMount)
+	case m.Type == MountTmpfs && m.Source != "":
+		return ctx.Str("reason", "tmpfs has no source").Just(ErrInvalidMount)
+	case !bind && m.Propagation != "":
+		return ctx.Str("reason", "propagation applies to bind mounts only").Just(ErrInvalidMount)
+	case m.Type != MountVolume && (m.VolumeDriver != "" || len(m.VolumeOptions) != 0):
+		return ctx.Str("reason", "volume driver applies to volumes only").Just(ErrInvalidMount)
+	case m.Type != MountTmpfs && (m.TmpfsSize != 0 || m.TmpfsMode != 0):
+		return ctx.Str("reason", "tmpfs options apply to tmpfs only").Just(ErrInvalidMount)
+	case m.TmpfsSize < 0:
+		return ctx.Str("reason", "negative tmpfs size").Just(ErrInvalidMount)
+	}
+
+	switch m.Propagation {
+	case "", PropagationPrivate, PropagationRPrivate, PropagationShared,
+		PropagationRShared, PropagationSlave, PropagationRSlave:
+	default:
+		return ctx.Str("propagation", m.Propagation).Just(ErrInvalidMount)
+	}
+
+	return nil
+}
+
+// MountType - тип раздела с учетом значения по умолчанию
+func (m MountSpec) MountType() string {
+	if m.Type == "" {
+		return MountBind
+	}
+
+	return m.Type
+}
+
+// String - раздел в форме src:dst[:opts] (для tmpfs и анонимного тома -
+// dst[:opts]), как в docker run -v и --tmpfs
+func (m MountSpec) String() string {
+	var opts []string
+
+	if m.ReadOnly {
+		opts = append(opts, "ro")
+	}
+
+	if m.Propagation != "" {
+		opts = append(opts, m.Propagation)
+	}
+
+	if m.TmpfsSize != 0 {
+		opts = append(opts, "size="+strconv.FormatInt(m.TmpfsSize, 10))
+	}
+
+	if m.TmpfsMode != 0 {
+		opts = append(opts, "mode="+strconv.FormatUint(uint64(m.TmpfsMode.Perm()), 8))
+	}
+
+	s := m.Target
+	if m.MountType() != MountTmpfs && m.Source != "" {
+		s = m.Source + ":" + s
+	}
+
+	if len(opts) != 0 {
+		s += ":" + strings.Join(opts, ",")
+	}
+
+	return s
+}
+
+// GetMountSpecs - разделы контейнера: записи Mounts и MountSpecs; записи
+// Mounts с ошибками пропускаются, их отклоняет проверка при создании
+func (c *BaseContainer) GetMountSpecs() []MountSpec {
+	if c == nil {
+		return nil
+	}
+
+	specs := make([]MountSpec, 0, len(c.Mounts)+len(c.MountSpecs))
+
+	for _, s := range c.Mounts {
+		if m, err := ParseMount(s); err == nil {
+			specs = append(specs, m)
+		}
+	}
+
+	return append(specs, c.MountSpecs...)
+}
+
+// checkMounts - проверяет записи Mounts и MountSpecs перед созданием контейнера
+func (c *BaseContainer) checkMounts() error {
+	for _, s := range c.Mounts {
+		if _, err := ParseMount(s); err != nil {
+			return errors.Ctx().Str("container-name", c.GetName()).Wrap(err, "check mounts")
+		}
+	}
+
+	for _, m := range c.MountSpecs {
+		if err := m.Validate(); err != nil {
+			return errors.Ctx().Str("container-name", c.GetName()).Wrap(err, "check mounts")
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,226 @@
+package containers
+
+import (
+	"context"
+	"strings"
+	"sync"
+)
+
+// Registry - реестр контейнеров сети, запущенных BaseContainer: отвечает на
+// вопросы "какие контейнеры подняты и где" без обращений к среде исполнения.
+// Запросы возвращают копии сведений
+type Registry struct {
+	mu    sync.RWMutex
+	infos []*OrchestratorInfo
+}
+
+// NewRegistry - создает пустой реестр
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// AddContainer - регистрирует контейнер, заменяя сведения с тем же
+// идентификатором или именем (например, пересозданного контейнера)
+func (r *Registry) AddContainer(info *OrchestratorInfo) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i, known := range r.infos {
+		if known.ID == info.ID || (info.Name != "" && known.Name == info.Name) {
+			r.infos[i] = info
+
+			return
+		}
+	}
+
+	r.infos = append(r.infos, info)
+}
+
+// Remove - удаляет контейнер из реестра
+func (r *Registry) Remove(id string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i, known := range r.infos {
+		if known.ID == id {
+			r.infos = append(r.infos[:i], r.infos[i+1:]...)
+
+			return
+		}
+	}
+}
+
+// Get - сведения о контейнере по идентификатору или имени
+func (r *Registry) Get(idOrName string) (OrchestratorInfo, bool) {
+	found := r.filter(
+		func(info *OrchestratorInfo) bool {
+			return info.ID == idOrName || info.Name == idOrName
+		},
+	)
+
+	if len(found) == 0 {
+		return OrchestratorInfo{}, false
+	}
+
+	return found[0], true
+}
+
+// All - все зарегистрированные контейнеры в порядке регистрации
+func (r *Registry) All() []OrchestratorInfo {
+	return r.filter(func(*OrchestratorInfo) bool { return true })
+}
+
+// ByType - контейнеры с заданным TypeID
+func (r *Registry) ByType(typeID uint8) []OrchestratorInfo {
+	return r.filter(
+		func(info *OrchestratorInfo) bool {
+			return info.TypeID == typeID
+		},
+	)
+}
+
+// ByLabel - контейнеры с меткой key, равной value (пустое value - с любым значением)
+func (r *Registry) ByLabel(key, value string) []OrchestratorInfo {
+	return r.filter(
+		func(info *OrchestratorInfo) bool {
+			v, ok := info.Labels[key]
+
+			return ok && (value == "" || v == value)
+		},
+	)
+}
+
+// ByImage - контейнеры образа image; ссылка без тега и дайджеста совпадает
+// с любой версией образа
+func (r *Registry) ByImage(image string) []OrchestratorInfo {
+	return r.filter(
+		func(info *OrchestratorInfo) bool {
+			return info.Image == image || imageRepository(info.Image) == image
+		},
+	)
+}
+
+// Healthy - работающие контейнеры, проверка здоровья которых пройдена
+// или не задана
+func (r *Registry) Healthy() []OrchestratorInfo {
+	return r.filter(
+		func(info *OrchestratorInfo) bool {
+			return info.State == StateRunning && (info.Health == "" || info.Health == HealthHealthy)
+		},
+	)
+}
+
+// SetState - обновляет состояние контейнера, пустое health сохраняет прежнее
+func (r *Registry) SetState(id, state, health string) {
+	r.update(
+		id, func(info *OrchestratorInfo) {
+			info.State = state
+
+			if health != "" {
+				info.Health = health
+			}
+		},
+	)
+}
+
+// Apply - обновляет состояние контейнера по событию среды исполнения
+func (r *Registry) Apply(ev ContainerEvent) {
+	if ev.Type != "" && ev.Type != EventTypeContainer {
+		return
+	}
+
+	r.update(
+		ev.ID, func(info *OrchestratorInfo) {
+			switch ev.Action {
+			case EventStart, EventUnpause:
+				info.State = StateRunning
+			case EventPause:
+				info.State = StatePaused
+			case EventDie:
+				info.State = StateExited
+			case EventDestroy:
+				info.State = StateDead
+			case EventHealthStatus:
+				info.Health = ev.Detail
+			}
+		},
+	)
+}
+
+// Follow - поддерживает состояние и здоровье контейнеров реестра по событиям
+// среды исполнения до отмены ctx; возвращает управление сразу
+func (r *Registry) Follow(ctx context.Context, cli Client) {
+	events := ExtendClient(cli).Events(ctx, FilterType(EventTypeContainer))
+
+	go func() {
+		for ev := range events {
+			r.Apply(ev)
+		}
+	}()
+}
+
+func (r *Registry) update(id string, fn func(info *OrchestratorInfo)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, info := range r.infos {
+		if info.ID == id {
+			fn(info)
+
+			return
+		}
+	}
+}
+
+func (r *Registry) filter(match func(info *OrchestratorInfo) bool) []OrchestratorInfo {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var result []OrchestratorInfo
+
+	for _, info := range r.infos {
+		if match(info) {
+			result = append(result, info.clone())
+		}
+	}
+
+	return result
+}
+
+// clone - копия сведений, не разделяющая с исходными мапы
+func (info *OrchestratorInfo) clone() OrchestratorInfo {
+	cp := *info
+	cp.ContainerEnpoints = info.ContainerEnpoints.Copy()
+	cp.HostEnpoints = info.HostEnpoints.Copy()
+
+	if info.Labels != nil {
+		cp.Labels = make(map[string]string, len(info.Labels))
+
+		for k, v := range info.Labels {
+			cp.Labels[k] = v
+		}
+	}
+
+	if info.Networks != nil {
+		cp.Networks = make(map[string]EndpointSettings, len(info.Networks))
+
+		for k, v := range info.Networks {
+			cp.Networks[k] = v
+		}
+	}
+
+	return cp
+}
+
+// imageRepository - ссылка на образ без тега и дайджеста
+func imageRepository(ref string) string {
+	if i := strings.Index(ref, "@"); i >= 0 {
+		ref = ref[:i]
+	}
+
+	if i := strings.LastIndex(ref, ":"); i > strings.LastIndex(ref, "/") {
+		ref = ref[:i]
+	}
+
+	return ref
+}
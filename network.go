@@ -1,7 +1,113 @@
 package containers
 
+import (
+	"context"
+	"net"
+
+	"gopkg.in/gomisc/errors.v1"
+)
+
 const reservedNetworksVar = "RESERVED_NETWORKS"
 
-type EndpointSettings struct {
-	IPAddress string
+type (
+	EndpointSettings struct {
+		// NetworkID - идентификатор сети
+		NetworkID string
+		IPAddress string
+		// IPv6Address - IPv6 адрес контейнера в сети с двумя стеками адресов
+		IPv6Address string
+	}
+
+	// DualStackNetwork - сеть с подсетью IPv6 помимо IPv4
+	DualStackNetwork interface {
+		// Subnet6 - подсеть IPv6, nil - сеть без IPv6
+		Subnet6() *net.IPNet
+		// NextIP6 - следующий не занятый IPv6 адрес сети
+		NextIP6() string
+	}
+
+	// NetworkAttachment - подключение контейнера к сети помимо основной
+	NetworkAttachment struct {
+		Network Network
+		// Aliases - DNS имена контейнера в сети
+		Aliases []string
+		// IP - адрес контейнера в сети, пусто - выдается средой исполнения
+		IP string
+	}
+)
+
+// GetExtraNetworks - сети контейнера помимо основной
+func (c *BaseContainer) GetExtraNetworks() []NetworkAttachment {
+	if c == nil {
+		return nil
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return append([]NetworkAttachment(nil), c.ExtraNetworks...)
+}
+
+// Connect - подключает работающий контейнер к сети nw, например чтобы
+// восстановить связность после Disconnect; подключение сохраняется в
+// ExtraNetworks и при пересоздании контейнера
+func (c *BaseContainer) Connect(ctx context.Context, nw Network, aliases ...string) error {
+	if c.containerID == "" {
+		return errors.Ctx().Str("container-name", c.GetName()).Just(ErrContainerNotRunning)
+	}
+
+	att := NetworkAttachment{Network: nw, Aliases: aliases}
+
+	if err := c.runtime().NetworkConnect(ctx, c.containerID, att); err != nil {
+		return errors.Ctx().
+			Str("container-name", c.GetName()).
+			Str("network", nw.Name()).
+			Wrap(err, "connect container to network")
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.extraNetwork(nw) < 0 && (c.network == nil || c.network.ID() != nw.ID()) {
+		c.ExtraNetworks = append(c.ExtraNetworks, att)
+	}
+
+	return nil
+}
+
+// Disconnect - отключает работающий контейнер от сети nw (в том числе от
+// основной) для имитации сетевого разделения. Отключение от дополнительной
+// сети удаляет ее из ExtraNetworks, основная сеть при пересоздании
+// контейнера подключается снова
+func (c *BaseContainer) Disconnect(ctx context.Context, nw Network) error {
+	if c.containerID == "" {
+		return errors.Ctx().Str("container-name", c.GetName()).Just(ErrContainerNotRunning)
+	}
+
+	if err := c.runtime().NetworkDisconnect(ctx, c.containerID, nw); err != nil {
+		return errors.Ctx().
+			Str("container-name", c.GetName()).
+			Str("network", nw.Name()).
+			Wrap(err, "disconnect container from network")
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if i := c.extraNetwork(nw); i >= 0 {
+		c.ExtraNetworks = append(c.ExtraNetworks[:i:i], c.ExtraNetworks[i+1:]...)
+	}
+
+	return nil
+}
+
+// extraNetwork - индекс подключения к сети nw в ExtraNetworks, вызывается под c.mutex
+func (c *BaseContainer) extraNetwork(nw Network) int {
+	for i := range c.ExtraNetworks {
+		if c.ExtraNetworks[i].Network != nil && c.ExtraNetworks[i].Network.ID() == nw.ID() {
+			return i
+		}
+	}
+
+	return -1
 }
@@ -0,0 +1,659 @@
+package containers
+
+import (
+	"context"
+	"io"
+	"os"
+	"time"
+
+	"gopkg.in/gomisc/errors.v1"
+)
+
+// ErrUnsupportedOperation - клиент среды исполнения не реализует необязательный
+// интерфейс операции
+const ErrUnsupportedOperation = errors.Const("operation is not supported by the client")
+
+// Необязательные возможности контейнера: адаптеры проверяют их приведением
+// типа, контейнер, реализующий только Container, получает значения по умолчанию
+type (
+	// StorageSpec - разделы контейнера помимо GetVolumes и GetMounts
+	StorageSpec interface {
+		// GetNamedVolumes возвращает именованные тома
+		GetNamedVolumes() []VolumeSpec
+		// GetTmpfs возвращает разделы в памяти с опциями монтирования по пути в контейнере
+		GetTmpfs() map[string]string
+		// GetMountSpecs возвращает разделы контейнера: записи GetMounts и структурированные
+		GetMountSpecs() []MountSpec
+	}
+
+	// NetworkingSpec - сетевые настройки контейнера помимо основной сети
+	NetworkingSpec interface {
+		// GetExtraNetworks возвращает сети контейнера помимо основной
+		GetExtraNetworks() []NetworkAttachment
+		// GetAliases возвращает дополнительные DNS имена контейнера в его сети
+		GetAliases() []string
+		// GetExtraHosts возвращает дополнительные записи /etc/hosts в форме name:ip
+		GetExtraHosts() []string
+		// GetDNS возвращает адреса DNS серверов контейнера (пусто - серверы демона)
+		GetDNS() []string
+		// GetDNSSearch возвращает домены поиска DNS контейнера
+		GetDNSSearch() []string
+	}
+
+	// ProcessSpec - параметры процесса контейнера и его образа
+	ProcessSpec interface {
+		// GetRuntime возвращает OCI runtime контейнера (пусто - по умолчанию)
+		GetRuntime() string
+		// GetPlatform возвращает платформу образа в форме os/arch[/variant]
+		GetPlatform() string
+		// GetHooks возвращает OCI хуки контейнера
+		GetHooks() Hooks
+		// GetUser возвращает пользователя процесса контейнера (пусто - пользователь образа)
+		GetUser() string
+		// GetWorkDir возвращает рабочий каталог процесса (пусто - каталог образа)
+		GetWorkDir() string
+		// GetLabels возвращает метки контейнера
+		GetLabels() map[string]string
+	}
+
+	// ResourcesSpec - ограничения ресурсов, устройства и проверка здоровья контейнера
+	ResourcesSpec interface {
+		// GetHealthcheck возвращает проверку здоровья контейнера (nil - проверка образа)
+		GetHealthcheck() *Healthcheck
+		// GetResources возвращает ограничения ресурсов контейнера
+		GetResources() Resources
+		// GetDevices возвращает устройства хоста, пробрасываемые в контейнер
+		GetDevices() []Device
+		// GetGPUs возвращает запросы GPU контейнера
+		GetGPUs() []GPURequest
+	}
+
+	// ExtendedContainer - контейнер со всеми необязательными возможностями, см. ExtendContainer
+	ExtendedContainer interface {
+		Container
+		StorageSpec
+		NetworkingSpec
+		ProcessSpec
+		ResourcesSpec
+	}
+
+	extendedContainer struct {
+		Container
+	}
+)
+
+// Необязательные возможности клиента среды исполнения: операции проверяются
+// приведением типа, клиент, реализующий только Client, возвращает
+// ErrUnsupportedOperation
+type (
+	// OutputClient - потоки вывода клиента
+	OutputClient interface {
+		// Stdout возвращает поток стандартного вывода клиента
+		Stdout() io.Writer
+		// Stderr возвращает поток вывода ошибок клиента
+		Stderr() io.Writer
+	}
+
+	// InfoClient - сведения о демоне среды исполнения
+	InfoClient interface {
+		// Info возвращает сведения о демоне среды исполнения (запрашиваются лениво и кешируются)
+		Info(ctx context.Context) (*DaemonInfo, error)
+	}
+
+	// InspectClient - состояние контейнеров
+	InspectClient interface {
+		// ContainerList возвращает сведения о контейнерах (в том числе остановленных),
+		// отобранных filter
+		ContainerList(ctx context.Context, filter ListFilter) ([]ContainerSummary, error)
+		// ContainerInspect возвращает текущее состояние контейнера
+		ContainerInspect(ctx context.Context, id string) (*InspectResult, error)
+	}
+
+	// ControlClient - управление жизненным циклом контейнера помимо запуска и остановки
+	ControlClient interface {
+		// ContainerRestart перезапускает контейнер
+		ContainerRestart(ctx context.Context, id string, timeout time.Duration) error
+		// ContainerKill отправляет сигнал (SIGHUP, SIGKILL, 9, etc) основному процессу контейнера
+		ContainerKill(ctx context.Context, id, signal string) error
+		// ContainerPause замораживает процессы контейнера
+		ContainerPause(ctx context.Context, id string) error
+		// ContainerUnpause возобновляет замороженные процессы контейнера
+		ContainerUnpause(ctx context.Context, id string) error
+		// ContainerRemove удаляет контейнер вместе с его анонимными разделами
+		ContainerRemove(ctx context.Context, id string) error
+	}
+
+	// MonitorClient - замеры ресурсов и события контейнеров
+	MonitorClient interface {
+		// ContainerStats возвращает поток замеров потребления ресурсов контейнером,
+		// канал закрывается при отмене ctx или завершении контейнера
+		ContainerStats(ctx context.Context, id string) (<-chan StatsSample, error)
+		// Events подписывается на события контейнеров и сетей, отобранные filters;
+		// канал закрывается при отмене ctx или обрыве потока событий
+		Events(ctx context.Context, filters ...EventFilter) <-chan ContainerEvent
+	}
+
+	// ExecClient - выполнение команд и копирование файлов в запущенном контейнере
+	ExecClient interface {
+		// ContainerExec выполняет команду в запущенном контейнере и возвращает код ее завершения
+		ContainerExec(ctx context.Context, id string, cmd []string, stdout, stderr io.Writer) (int, error)
+		// CopyToContainer распаковывает tar-поток content в каталог dst контейнера
+		CopyToContainer(ctx context.Context, id, dst string, content io.Reader) error
+		// CopyFromContainer возвращает tar-поток с содержимым пути src контейнера
+		CopyFromContainer(ctx context.Context, id, src string) (io.ReadCloser, error)
+	}
+
+	// ImageClient - работа с образами помимо скачивания, сборки и удаления
+	ImageClient interface {
+		// FindImagesLocal - проверяет наличие нескольких образов в локальном сторе
+		// одним запросом, ключи результата - исходные ссылки
+		FindImagesLocal(ctx context.Context, refs []string) (map[string]bool, error)
+		// ImageDigest - возвращает дайджест (или идентификатор) образа из локального стора
+		ImageDigest(ctx context.Context, image string) (string, error)
+		// ImageInspect - возвращает сведения об образе локального стора, в том
+		// числе аттестации сборки
+		ImageInspect(ctx context.Context, image string) (*ImageDetails, error)
+		// PullImageWith - скачивает образ с параметрами opts (учетные данные,
+		// платформа), отмена ctx прерывает скачивание; в герметичном режиме
+		// возвращает ошибку Hermetic().CheckPull
+		PullImageWith(ctx context.Context, opts PullOptions) error
+		// PushImage - публикует образ tag локального стора в его реестр
+		PushImage(ctx context.Context, tag string, auth RegistryAuth) error
+		// ContainerCommit сохраняет файловую систему контейнера в образ с тегом,
+		// возвращает идентификатор образа
+		ContainerCommit(ctx context.Context, id, tag string, opts ...CommitOption) (string, error)
+		// PruneImages - удаляет неиспользуемые образы, отобранные opts
+		PruneImages(ctx context.Context, opts PruneOptions) (PruneReport, error)
+		// PruneBuildCache - очищает неиспользуемый кеш сборки по условиям opts,
+		// неограниченная очистка без AllDaemon - ErrUnscopedPrune
+		PruneBuildCache(ctx context.Context, opts BuildCachePruneOptions) (PruneReport, error)
+	}
+
+	// VolumeClient - именованные тома и объекты проекта
+	VolumeClient interface {
+		// VolumeCreate - создает именованный том; существующий том с тем же
+		// именем возвращается без изменений
+		VolumeCreate(ctx context.Context, spec VolumeSpec) (VolumeInfo, error)
+		// VolumeRemove - удаляет том, force удаляет и подключенный к контейнерам том
+		VolumeRemove(ctx context.Context, name string, force bool) error
+		// VolumeList - отбирает тома по условиям filter
+		VolumeList(ctx context.Context, filter VolumeFilter) ([]VolumeInfo, error)
+		// ListProjectResources - контейнеры, сети, тома и образы с меткой проекта
+		// project, созданные любыми сессиями
+		ListProjectResources(ctx context.Context, project string) (*ProjectResources, error)
+	}
+
+	// NetworkClient - подключение запущенного контейнера к сетям
+	NetworkClient interface {
+		// NetworkConnect - подключает контейнер id к сети att.Network
+		NetworkConnect(ctx context.Context, id string, att NetworkAttachment) error
+		// NetworkDisconnect - отключает контейнер id от сети nw
+		NetworkDisconnect(ctx context.Context, id string, nw Network) error
+	}
+
+	// ExtendedClient - клиент со всеми необязательными возможностями, см. ExtendClient
+	ExtendedClient interface {
+		Client
+		OutputClient
+		InfoClient
+		InspectClient
+		ControlClient
+		MonitorClient
+		ExecClient
+		ImageClient
+		VolumeClient
+		NetworkClient
+	}
+
+	extendedClient struct {
+		Client
+	}
+)
+
+// RegistryNetwork - необязательная возможность сети: реестр ее контейнеров
+type RegistryNetwork interface {
+	// Registry возвращает реестр контейнеров сети
+	Registry() *Registry
+}
+
+// NetworkRegistry - реестр контейнеров сети nw; сеть без реестра получает
+// пустой реестр, изменения которого никуда не сохраняются
+func NetworkRegistry(nw Network) *Registry {
+	if r, ok := nw.(RegistryNetwork); ok {
+		return r.Registry()
+	}
+
+	return NewRegistry()
+}
+
+// ExtendContainer - контейнер c с необязательными возможностями: сам c, если
+// он реализует их все, иначе обертка, в которой отсутствующие возможности
+// возвращают значения по умолчанию; nil - nil
+func ExtendContainer(c Container) ExtendedContainer {
+	if ext, ok := c.(ExtendedContainer); ok || c == nil {
+		return ext
+	}
+
+	return extendedContainer{Container: c}
+}
+
+// ExtendClient - клиент cli с необязательными возможностями: сам cli, если он
+// реализует их все, иначе обертка, в которой отсутствующие операции
+// возвращают ErrUnsupportedOperation; nil - nil
+func ExtendClient(cli Client) ExtendedClient {
+	if ext, ok := cli.(ExtendedClient); ok || cli == nil {
+		return ext
+	}
+
+	return extendedClient{Client: cli}
+}
+
+func (c extendedContainer) GetNamedVolumes() []VolumeSpec {
+	if s, ok := c.Container.(interface{ GetNamedVolumes() []VolumeSpec }); ok {
+		return s.GetNamedVolumes()
+	}
+
+	return nil
+}
+
+func (c extendedContainer) GetTmpfs() map[string]string {
+	if s, ok := c.Container.(interface{ GetTmpfs() map[string]string }); ok {
+		return s.GetTmpfs()
+	}
+
+	return nil
+}
+
+func (c extendedContainer) GetMountSpecs() []MountSpec {
+	if s, ok := c.Container.(interface{ GetMountSpecs() []MountSpec }); ok {
+		return s.GetMountSpecs()
+	}
+
+	return nil
+}
+
+func (c extendedContainer) GetExtraNetworks() []NetworkAttachment {
+	if s, ok := c.Container.(interface{ GetExtraNetworks() []NetworkAttachment }); ok {
+		return s.GetExtraNetworks()
+	}
+
+	return nil
+}
+
+func (c extendedContainer) GetAliases() []string {
+	if s, ok := c.Container.(interface{ GetAliases() []string }); ok {
+		return s.GetAliases()
+	}
+
+	return nil
+}
+
+func (c extendedContainer) GetExtraHosts() []string {
+	if s, ok := c.Container.(interface{ GetExtraHosts() []string }); ok {
+		return s.GetExtraHosts()
+	}
+
+	return nil
+}
+
+func (c extendedContainer) GetDNS() []string {
+	if s, ok := c.Container.(interface{ GetDNS() []string }); ok {
+		return s.GetDNS()
+	}
+
+	return nil
+}
+
+func (c extendedContainer) GetDNSSearch() []string {
+	if s, ok := c.Container.(interface{ GetDNSSearch() []string }); ok {
+		return s.GetDNSSearch()
+	}
+
+	return nil
+}
+
+func (c extendedContainer) GetRuntime() string {
+	if s, ok := c.Container.(interface{ GetRuntime() string }); ok {
+		return s.GetRuntime()
+	}
+
+	return ""
+}
+
+func (c extendedContainer) GetPlatform() string {
+	if s, ok := c.Container.(interface{ GetPlatform() string }); ok {
+		return s.GetPlatform()
+	}
+
+	return ""
+}
+
+func (c extendedContainer) GetHooks() Hooks {
+	if s, ok := c.Container.(interface{ GetHooks() Hooks }); ok {
+		return s.GetHooks()
+	}
+
+	return Hooks{}
+}
+
+func (c extendedContainer) GetUser() string {
+	if s, ok := c.Container.(interface{ GetUser() string }); ok {
+		return s.GetUser()
+	}
+
+	return ""
+}
+
+func (c extendedContainer) GetWorkDir() string {
+	if s, ok := c.Container.(interface{ GetWorkDir() string }); ok {
+		return s.GetWorkDir()
+	}
+
+	return ""
+}
+
+func (c extendedContainer) GetLabels() map[string]string {
+	if s, ok := c.Container.(interface{ GetLabels() map[string]string }); ok {
+		return s.GetLabels()
+	}
+
+	return nil
+}
+
+func (c extendedContainer) GetHealthcheck() *Healthcheck {
+	if s, ok := c.Container.(interface{ GetHealthcheck() *Healthcheck }); ok {
+		return s.GetHealthcheck()
+	}
+
+	return nil
+}
+
+func (c extendedContainer) GetResources() Resources {
+	if s, ok := c.Container.(interface{ GetResources() Resources }); ok {
+		return s.GetResources()
+	}
+
+	return Resources{}
+}
+
+func (c extendedContainer) GetDevices() []Device {
+	if s, ok := c.Container.(interface{ GetDevices() []Device }); ok {
+		return s.GetDevices()
+	}
+
+	return nil
+}
+
+func (c extendedContainer) GetGPUs() []GPURequest {
+	if s, ok := c.Container.(interface{ GetGPUs() []GPURequest }); ok {
+		return s.GetGPUs()
+	}
+
+	return nil
+}
+
+// unsupported - ошибка операции op, которую клиент не реализует
+func unsupported(op string) error {
+	return errors.Ctx().Str("operation", op).Just(ErrUnsupportedOperation)
+}
+
+func (c extendedClient) Stdout() io.Writer {
+	if o, ok := c.Client.(OutputClient); ok {
+		return o.Stdout()
+	}
+
+	return os.Stdout
+}
+
+func (c extendedClient) Stderr() io.Writer {
+	if o, ok := c.Client.(OutputClient); ok {
+		return o.Stderr()
+	}
+
+	return os.Stderr
+}
+
+func (c extendedClient) Info(ctx context.Context) (*DaemonInfo, error) {
+	if i, ok := c.Client.(InfoClient); ok {
+		return i.Info(ctx)
+	}
+
+	return nil, unsupported("info")
+}
+
+func (c extendedClient) ContainerList(ctx context.Context, filter ListFilter) ([]ContainerSummary, error) {
+	if i, ok := c.Client.(InspectClient); ok {
+		return i.ContainerList(ctx, filter)
+	}
+
+	return nil, unsupported("container list")
+}
+
+func (c extendedClient) ContainerInspect(ctx context.Context, id string) (*InspectResult, error) {
+	if i, ok := c.Client.(InspectClient); ok {
+		return i.ContainerInspect(ctx, id)
+	}
+
+	return nil, unsupported("container inspect")
+}
+
+func (c extendedClient) ContainerRestart(ctx context.Context, id string, timeout time.Duration) error {
+	if ctl, ok := c.Client.(ControlClient); ok {
+		return ctl.ContainerRestart(ctx, id, timeout)
+	}
+
+	return unsupported("container restart")
+}
+
+func (c extendedClient) ContainerKill(ctx context.Context, id, signal string) error {
+	if ctl, ok := c.Client.(ControlClient); ok {
+		return ctl.ContainerKill(ctx, id, signal)
+	}
+
+	return unsupported("container kill")
+}
+
+func (c extendedClient) ContainerPause(ctx context.Context, id string) error {
+	if ctl, ok := c.Client.(ControlClient); ok {
+		return ctl.ContainerPause(ctx, id)
+	}
+
+	return unsupported("container pause")
+}
+
+func (c extendedClient) ContainerUnpause(ctx context.Context, id string) error {
+	if ctl, ok := c.Client.(ControlClient); ok {
+		return ctl.ContainerUnpause(ctx, id)
+	}
+
+	return unsupported("container unpause")
+}
+
+func (c extendedClient) ContainerRemove(ctx context.Context, id string) error {
+	if ctl, ok := c.Client.(ControlClient); ok {
+		return ctl.ContainerRemove(ctx, id)
+	}
+
+	return unsupported("container remove")
+}
+
+func (c extendedClient) ContainerStats(ctx context.Context, id string) (<-chan StatsSample, error) {
+	if m, ok := c.Client.(MonitorClient); ok {
+		return m.ContainerStats(ctx, id)
+	}
+
+	return nil, unsupported("container stats")
+}
+
+// Events - без MonitorClient возвращает закрытый канал: событий не будет
+func (c extendedClient) Events(ctx context.Context, filters ...EventFilter) <-chan ContainerEvent {
+	if m, ok := c.Client.(MonitorClient); ok {
+		return m.Events(ctx, filters...)
+	}
+
+	events := make(chan ContainerEvent)
+	close(events)
+
+	return events
+}
+
+func (c extendedClient) ContainerExec(
+	ctx context.Context, id string, cmd []string, stdout, stderr io.Writer,
+) (int, error) {
+	if e, ok := c.Client.(ExecClient); ok {
+		return e.ContainerExec(ctx, id, cmd, stdout, stderr)
+	}
+
+	return 0, unsupported("container exec")
+}
+
+func (c extendedClient) CopyToContainer(ctx context.Context, id, dst string, content io.Reader) error {
+	if e, ok := c.Client.(ExecClient); ok {
+		return e.CopyToContainer(ctx, id, dst, content)
+	}
+
+	return unsupported("copy to container")
+}
+
+func (c extendedClient) CopyFromContainer(ctx context.Context, id, src string) (io.ReadCloser, error) {
+	if e, ok := c.Client.(ExecClient); ok {
+		return e.CopyFromContainer(ctx, id, src)
+	}
+
+	return nil, unsupported("copy from container")
+}
+
+// FindImagesLocal - без ImageClient проверяет образы по одному через FindImageLocal
+func (c extendedClient) FindImagesLocal(ctx context.Context, refs []string) (map[string]bool, error) {
+	if i, ok := c.Client.(ImageClient); ok {
+		return i.FindImagesLocal(ctx, refs)
+	}
+
+	found := make(map[string]bool, len(refs))
+
+	for _, ref := range refs {
+		ok, err := c.Client.FindImageLocal(ctx, ref)
+		if err != nil {
+			return nil, err
+		}
+
+		found[ref] = ok
+	}
+
+	return found, nil
+}
+
+func (c extendedClient) ImageDigest(ctx context.Context, image string) (string, error) {
+	if i, ok := c.Client.(ImageClient); ok {
+		return i.ImageDigest(ctx, image)
+	}
+
+	return "", unsupported("image digest")
+}
+
+func (c extendedClient) ImageInspect(ctx context.Context, image string) (*ImageDetails, error) {
+	if i, ok := c.Client.(ImageClient); ok {
+		return i.ImageInspect(ctx, image)
+	}
+
+	return nil, unsupported("image inspect")
+}
+
+// PullImageWith - без ImageClient скачивает образ через PullImage, если opts
+// не требуют учетных данных или платформы
+func (c extendedClient) PullImageWith(ctx context.Context, opts PullOptions) error {
+	if i, ok := c.Client.(ImageClient); ok {
+		return i.PullImageWith(ctx, opts)
+	}
+
+	if !opts.Auth.Anonymous() || opts.Platform != "" {
+		return unsupported("image pull with options")
+	}
+
+	// адаптер v1 может не знать о герметичном режиме
+	if err := Hermetic().CheckPull(opts.Image); err != nil {
+		return err
+	}
+
+	return c.Client.PullImage(opts.Image)
+}
+
+func (c extendedClient) PushImage(ctx context.Context, tag string, auth RegistryAuth) error {
+	if i, ok := c.Client.(ImageClient); ok {
+		return i.PushImage(ctx, tag, auth)
+	}
+
+	return unsupported("image push")
+}
+
+func (c extendedClient) ContainerCommit(ctx context.Context, id, tag string, opts ...CommitOption) (string, error) {
+	if i, ok := c.Client.(ImageClient); ok {
+		return i.ContainerCommit(ctx, id, tag, opts...)
+	}
+
+	return "", unsupported("container commit")
+}
+
+func (c extendedClient) PruneImages(ctx context.Context, opts PruneOptions) (PruneReport, error) {
+	if i, ok := c.Client.(ImageClient); ok {
+		return i.PruneImages(ctx, opts)
+	}
+
+	return PruneReport{}, unsupported("image prune")
+}
+
+func (c extendedClient) PruneBuildCache(ctx context.Context, opts BuildCachePruneOptions) (PruneReport, error) {
+	if i, ok := c.Client.(ImageClient); ok {
+		return i.PruneBuildCache(ctx, opts)
+	}
+
+	return PruneReport{}, unsupported("build cache prune")
+}
+
+func (c extendedClient) VolumeCreate(ctx context.Context, spec VolumeSpec) (VolumeInfo, error) {
+	if v, ok := c.Client.(VolumeClient); ok {
+		return v.VolumeCreate(ctx, spec)
+	}
+
+	return VolumeInfo{}, unsupported("volume create")
+}
+
+func (c extendedClient) VolumeRemove(ctx context.Context, name string, force bool) error {
+	if v, ok := c.Client.(VolumeClient); ok {
+		return v.VolumeRemove(ctx, name, force)
+	}
+
+	return unsupported("volume remove")
+}
+
+func (c extendedClient) VolumeList(ctx context.Context, filter VolumeFilter) ([]VolumeInfo, error) {
+	if v, ok := c.Client.(VolumeClient); ok {
+		return v.VolumeList(ctx, filter)
+	}
+
+	return nil, unsupported("volume list")
+}
+
+func (c extendedClient) ListProjectResources(ctx context.Context, project string) (*ProjectResources, error) {
+	if v, ok := c.Client.(VolumeClient); ok {
+		return v.ListProjectResources(ctx, project)
+	}
+
+	return nil, unsupported("list project resources")
+}
+
+func (c extendedClient) NetworkConnect(ctx context.Context, id string, att NetworkAttachment) error {
+	if n, ok := c.Client.(NetworkClient); ok {
+		return n.NetworkConnect(ctx, id, att)
+	}
+
+	return unsupported("network connect")
+}
+
+func (c extendedClient) NetworkDisconnect(ctx context.Context, id string, nw Network) error {
+	if n, ok := c.Client.(NetworkClient); ok {
+		return n.NetworkDisconnect(ctx, id, nw)
+	}
+
+	return unsupported("network disconnect")
+}
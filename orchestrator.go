@@ -0,0 +1,409 @@
+package containers
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"gopkg.in/gomisc/errors.v1"
+)
+
+const (
+	ErrMemberAlreadyExist = errors.Const("orchestrator member already exist")
+	ErrMemberNotFound     = errors.Const("orchestrator member not found")
+)
+
+type (
+	// Orchestrator - управляет набором контейнеров окружения как единым целым:
+	// поднимает их в порядке добавления и останавливает в обратном
+	Orchestrator struct {
+		cli    Client
+		puller *ImagePuller
+
+		mu      sync.Mutex
+		members []*Member
+		index   map[string]*Member
+	}
+
+	// Member - контейнер окружения и его параметры в оркестраторе
+	Member struct {
+		Container Container
+		// Stateful - контейнер хранит состояние и участвует в снапшотах окружения
+		Stateful bool
+		// Optional - неудачный старт контейнера не прерывает подъем окружения
+		Optional bool
+		// Err - ошибка старта необязательного контейнера
+		Err error
+		// Profiles - профили, в которых поднимается контейнер; контейнер без
+		// профилей поднимается всегда
+		Profiles []string
+
+		exit      chan error
+		available bool
+		skipped   bool
+		// owner - оркестратор участника, его mu защищает available и skipped
+		owner *Orchestrator
+	}
+
+	// MemberOption - опция участника окружения
+	MemberOption func(m *Member)
+)
+
+// WithStateful - помечает контейнер как хранящий состояние
+func WithStateful() MemberOption {
+	return func(m *Member) {
+		m.Stateful = true
+	}
+}
+
+// WithOptional - помечает контейнер как необязательный: при ошибке его старта
+// окружение продолжает подниматься, а Available возвращает false
+func WithOptional() MemberOption {
+	return func(m *Member) {
+		m.Optional = true
+	}
+}
+
+// NewOrchestrator - конструктор оркестратора
+func NewOrchestrator(cli Client) *Orchestrator {
+	return &Orchestrator{
+		cli:    cli,
+		puller: NewImagePuller(cli, DefaultPullConcurrency),
+		index:  make(map[string]*Member),
+	}
+}
+
+// Add - добавляет контейнер в окружение
+func (o *Orchestrator) Add(c Container, opts ...MemberOption) error {
+	m := &Member{Container: c}
+
+	for _, apply := range opts {
+		apply(m)
+	}
+
+	return o.add(m)
+}
+
+func (o *Orchestrator) add(m *Member) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	name := m.Container.GetName()
+
+	if _, ok := o.index[name]; ok {
+		return errors.Ctx().Str("name", name).Just(ErrMemberAlreadyExist)
+	}
+
+	m.owner = o
+	o.members = append(o.members, m)
+	o.index[name] = m
+
+	return nil
+}
+
+// Container - возвращает контейнер окружения по имени
+func (o *Orchestrator) Container(name string) Container {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if m, ok := o.index[name]; ok {
+		return m.Container
+	}
+
+	return nil
+}
+
+// Available - признак того, что контейнер окружения успешно запущен, позволяет
+// пропускать тесты, зависящие от необязательных контейнеров
+func (o *Orchestrator) Available(name string) bool {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	m, ok := o.index[name]
+
+	return ok && m.available
+}
+
+// Members - возвращает участников окружения в порядке добавления
+func (o *Orchestrator) Members() []*Member {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	members := make([]*Member, len(o.members))
+	copy(members, o.members)
+
+	return members
+}
+
+// Up - создает и запускает контейнеры окружения без профилей, дожидаясь
+// готовности каждого. Образы всех контейнеров скачиваются параллельно,
+// создание контейнера ожидает только своего образа
+func (o *Orchestrator) Up(ctx context.Context) error {
+	return o.UpProfiles(ctx)
+}
+
+// UpProfiles - как Up, но дополнительно поднимает контейнеры, входящие хотя бы
+// в один из переданных профилей. Если подъем прерван отменой ctx или паникой,
+// созданные им контейнеры удаляются в обратном порядке
+func (o *Orchestrator) UpProfiles(ctx context.Context, profiles ...string) (err error) {
+	var (
+		touched  []*Member
+		networks []Network
+	)
+
+	defer o.undoUp(ctx, &err, &touched, &networks)
+
+	members := o.selectProfiles(profiles)
+
+	if networks, err = o.ensureNetworks(members); err != nil {
+		return err
+	}
+
+	// обращения между участниками окружения не должны идти через прокси
+	hosts := make([]string, 0, len(members))
+
+	for _, m := range members {
+		hosts = append(hosts, m.Container.GetName())
+		hosts = append(hosts, ExtendContainer(m.Container).GetAliases()...)
+	}
+
+	for _, m := range members {
+		if target, ok := m.Container.(noProxyTarget); ok {
+			target.addNoProxy(hosts...)
+		}
+
+		if image := m.Container.GetImage(); image != "" {
+			o.puller.Prepare(ctx, image)
+		}
+	}
+
+	for _, m := range members {
+		var err error
+
+		// участники без образа (процессы хоста) образ не ожидают
+		if image := m.Container.GetImage(); image != "" {
+			err = o.puller.Wait(ctx, image)
+		}
+
+		if err == nil {
+			touched = append(touched, m)
+		}
+
+		if err != nil {
+			err = errors.Ctx().Str("name", m.Container.GetName()).Wrap(err, "prepare member image")
+		} else if err = m.Container.CreateContainer(); err != nil {
+			err = errors.Ctx().Str("name", m.Container.GetName()).Wrap(err, "create member")
+		} else if err = m.start(ctx); err != nil {
+			err = errors.Ctx().Str("name", m.Container.GetName()).Wrap(err, "start member")
+		}
+
+		o.mu.Lock()
+		m.available = err == nil
+		m.Err = err
+
+		if err == nil {
+			m.skipped = false
+		}
+		o.mu.Unlock()
+
+		if err == nil {
+			continue
+		}
+
+		if !m.Optional || ctx.Err() != nil {
+			if uploadErr := o.uploadFailure(err); uploadErr != nil {
+				m.Container.LogError(uploadErr, "upload environment failure artifacts")
+			}
+
+			return err
+		}
+
+		m.Container.LogError(err, "optional member unavailable")
+	}
+
+	// манифест - побочный артефакт: ошибка его записи не отменяет
+	// поднятое окружение и только сообщается в поток ошибок клиента
+	if path := os.Getenv(ManifestFileEnvar); path != "" {
+		if manifestErr := o.WriteManifestFile(ctx, path); manifestErr != nil {
+			o.logError(manifestErr, "write environment manifest")
+		}
+	}
+
+	return nil
+}
+
+// logError пишет ошибку оркестратора в поток вывода ошибок клиента
+func (o *Orchestrator) logError(err error, args ...any) {
+	_, _ = fmt.Fprintln(
+		ExtendClient(o.cli).Stderr(), "\x1b[91mERROR:\x1b[0m "+errors.Formatted(err, args...).Error(),
+	)
+}
+
+// undoUp - откатывает подъем окружения, прерванный паникой (она пробрасывается
+// дальше) или отменой ctx: удаляет участников touched, затем созданные подъемом
+// сети networks. Вызывается только через defer
+func (o *Orchestrator) undoUp(ctx context.Context, errp *error, touched *[]*Member, networks *[]Network) {
+	r := recover()
+
+	cause := *errp
+
+	switch {
+	case r != nil:
+		cause = errors.Ctx().Str("panic", fmt.Sprint(r)).New("environment startup panic")
+	case cause == nil || ctx.Err() == nil:
+		return
+	}
+
+	for i := len(*touched) - 1; i >= 0; i-- {
+		m := (*touched)[i]
+
+		o.mu.Lock()
+		m.available = false
+		o.mu.Unlock()
+
+		m.rollback(cause)
+	}
+
+	for i := len(*networks) - 1; i >= 0; i-- {
+		nw := (*networks)[i]
+
+		if err := o.cli.RemoveNetwork(nw.ID()); err != nil {
+			*errp = errors.And(
+				*errp, errors.Ctx().Str("network", nw.Name()).Wrap(err, "remove network of cancelled startup"),
+			)
+		}
+	}
+
+	if r != nil {
+		panic(r)
+	}
+}
+
+// ensureNetworks - создает сети участников members, удаленные после создания
+// участников (например, очисткой демона), с прежними подсетями; возвращает
+// созданные сети, их удаляет откат прерванного подъема
+func (o *Orchestrator) ensureNetworks(members []*Member) ([]Network, error) {
+	var created []Network
+
+	checked := make(map[string]struct{})
+
+	for _, m := range members {
+		// процессы хоста сетей контейнеров не используют
+		if m.Container.GetImage() == "" {
+			continue
+		}
+
+		nets := []Network{m.Container.GetNetwork()}
+
+		for _, att := range ExtendContainer(m.Container).GetExtraNetworks() {
+			nets = append(nets, att.Network)
+		}
+
+		for _, nw := range nets {
+			if nw == nil || nw.Name() == "" {
+				continue
+			}
+
+			if _, ok := checked[nw.Name()]; ok {
+				continue
+			}
+
+			checked[nw.Name()] = struct{}{}
+
+			var cidr string
+
+			if sn, ok := nw.(subnetNetwork); ok && sn.Subnet() != nil {
+				cidr = sn.Subnet().String()
+			}
+
+			actual, err := o.cli.CheckNetwork(nw.Name(), cidr)
+			if err != nil {
+				return created, errors.Ctx().Str("network", nw.Name()).Wrap(err, "check member network")
+			}
+
+			if actual.ID() != nw.ID() {
+				created = append(created, actual)
+			}
+		}
+	}
+
+	return created, nil
+}
+
+// Down - останавливает контейнеры окружения в обратном порядке, контейнеры
+// вне профилей последнего подъема пропускаются. Отчеты о запусках дописываются
+// в файл из CONTAINERS_RUN_REPORT
+func (o *Orchestrator) Down() error {
+	var err error
+
+	members := o.Members()
+
+	for i := len(members) - 1; i >= 0; i-- {
+		if members[i].Skipped() {
+			continue
+		}
+
+		if stopErr := members[i].Container.Stop(); stopErr != nil {
+			err = errors.And(
+				err,
+				errors.Ctx().Str("name", members[i].Container.GetName()).Wrap(stopErr, "stop member"),
+			)
+		}
+	}
+
+	if path := os.Getenv(RunReportFileEnvar); path != "" {
+		if reportErr := o.AppendRunReportFile(path); reportErr != nil {
+			err = errors.And(err, errors.Wrap(reportErr, "write run reports"))
+		}
+	}
+
+	return err
+}
+
+// start запускает контейнер и дожидается его готовности; контейнеры не в
+// фоновом режиме продолжают работать в отдельной горутине до остановки
+func (m *Member) start(ctx context.Context) error {
+	ready := make(chan struct{})
+	m.exit = make(chan error, 1)
+
+	go func(exit chan<- error) {
+		exit <- m.Container.StartContainer(nil, ready)
+	}(m.exit)
+
+	select {
+	case <-ready:
+		return nil
+	case err := <-m.exit:
+		if err == nil {
+			return ErrContainerExitedBeforeReady
+		}
+
+		return err
+	case <-ctx.Done():
+		// удаленный контейнер прерывает запуск и ожидание готовности
+		m.rollback(ctx.Err())
+
+		select {
+		case <-m.exit:
+		case <-time.After(rollbackTimeout):
+		}
+
+		return ctx.Err()
+	}
+}
+
+// rollback - удаляет контейнер прерванного запуска; участники, которые
+// не умеют откатываться (процессы хоста), только останавливаются
+func (m *Member) rollback(cause error) {
+	if rb, ok := m.Container.(rollbacker); ok {
+		rb.rollback(cause)
+
+		return
+	}
+
+	if err := m.Container.Stop(); err != nil {
+		m.Container.LogError(err, "stop cancelled member")
+	}
+}
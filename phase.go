@@ -0,0 +1,128 @@
+package containers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"gopkg.in/gomisc/errors.v1"
+)
+
+// Фазы запуска контейнера
+const (
+	PhasePull      Phase = "pull"
+	PhaseCreate    Phase = "create"
+	PhaseStart     Phase = "start"
+	PhaseReadiness Phase = "readiness"
+
+	ErrPhaseTimeout = errors.Const("phase budget exceeded")
+)
+
+type (
+	// Phase - фаза запуска контейнера
+	Phase string
+
+	// PhaseBudgets - предельная длительность каждой фазы запуска, нулевое значение -
+	// значение по умолчанию. Фактический срок фазы не превышает дедлайн контекста
+	// вызывающего
+	PhaseBudgets struct {
+		Pull      time.Duration `json:"pull,omitempty" yaml:"pull,omitempty"`
+		Create    time.Duration `json:"create,omitempty" yaml:"create,omitempty"`
+		Start     time.Duration `json:"start,omitempty" yaml:"start,omitempty"`
+		Readiness time.Duration `json:"readiness,omitempty" yaml:"readiness,omitempty"`
+	}
+
+	// PhaseTimeoutError - ошибка превышения срока фазы запуска. Причина доступна
+	// в поле Err, но не через Unwrap: иначе errors.Wrap поглощает ошибку фазы,
+	// присоединяясь к обернутой причине
+	PhaseTimeoutError struct {
+		Phase  Phase
+		Budget time.Duration
+		Err    error
+	}
+)
+
+// DefaultPhaseBudgets - сроки фаз по умолчанию, срок готовности берется из StartTimeout
+var DefaultPhaseBudgets = PhaseBudgets{
+	Pull:   10 * time.Minute,
+	Create: 30 * time.Second,
+	Start:  30 * time.Second,
+}
+
+func (e *PhaseTimeoutError) Error() string {
+	return fmt.Sprintf("%s phase exceeded budget %s: %v", e.Phase, e.Budget, e.Err)
+}
+
+// Is - позволяет проверять ошибку через errors.Is(err, ErrPhaseTimeout)
+func (e *PhaseTimeoutError) Is(target error) bool {
+	return target == ErrPhaseTimeout
+}
+
+func (b PhaseBudgets) withDefaults() PhaseBudgets {
+	if b.Pull == 0 {
+		b.Pull = DefaultPhaseBudgets.Pull
+	}
+
+	if b.Create == 0 {
+		b.Create = DefaultPhaseBudgets.Create
+	}
+
+	if b.Start == 0 {
+		b.Start = DefaultPhaseBudgets.Start
+	}
+
+	if b.Readiness == 0 {
+		b.Readiness = DefaultPhaseBudgets.Readiness
+	}
+
+	return b
+}
+
+// phaseContext - контекст фазы со сроком, не превышающим дедлайн родителя
+func phaseContext(parent context.Context, budget time.Duration) (context.Context, context.CancelFunc) {
+	if budget <= 0 {
+		return context.WithCancel(parent)
+	}
+
+	return context.WithTimeout(parent, budget)
+}
+
+// phaseBudget - срок фазы с учетом дедлайна родительского контекста
+func phaseBudget(parent context.Context, budget time.Duration) time.Duration {
+	if deadline, ok := parent.Deadline(); ok {
+		if left := time.Until(deadline); budget <= 0 || left < budget {
+			return left
+		}
+	}
+
+	return budget
+}
+
+// runPhase - выполняет фазу в отдельном контексте, истечение срока фазы
+// оборачивается в PhaseTimeoutError
+func runPhase(parent context.Context, phase Phase, budget time.Duration, fn func(ctx context.Context) error) error {
+	budget = phaseBudget(parent, budget)
+
+	ctx, cancel := phaseContext(parent, budget)
+	defer cancel()
+
+	err := fn(ctx)
+	if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return &PhaseTimeoutError{Phase: phase, Budget: budget, Err: err}
+	}
+
+	return err
+}
+
+// pullImage - скачивает образ платформы platform с ограничением по сроку
+func pullImage(ctx context.Context, cli Client, image, platform string) error {
+	if err := ExtendClient(cli).PullImageWith(ctx, PullOptions{Image: image, Platform: platform}); err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return errors.Ctx().Str("image", image).Wrap(ctxErr, "pull image")
+		}
+
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,164 @@
+package containers
+
+import (
+	"context"
+	"sync"
+
+	"gopkg.in/gomisc/errors.v1"
+)
+
+const ErrPoolClosed = errors.Const("fixtures pool closed")
+
+type (
+	// PoolFactory - создает и запускает новый экземпляр фикстуры
+	PoolFactory func(ctx context.Context) (Container, error)
+
+	// ResetFunc - возвращает фикстуру в исходное состояние (TRUNCATE, FLUSHALL, etc)
+	ResetFunc func(ctx context.Context, c Container) error
+
+	// Pool - пул прогретых фикстур: тест арендует запущенный контейнер вместо
+	// холодного старта, а при возврате контейнер сбрасывается и снова
+	// становится доступен. Неудачно сброшенные контейнеры останавливаются
+	Pool struct {
+		factory PoolFactory
+		reset   ResetFunc
+		idle    chan Container
+
+		mu     sync.Mutex
+		leased map[Container]struct{}
+		closed bool
+	}
+)
+
+// ExecReset - сбрасывает фикстуру выполнением команды внутри контейнера, например
+// ExecReset("redis-cli", "FLUSHALL")
+func ExecReset(cmd ...string) ResetFunc {
+	return func(ctx context.Context, c Container) error {
+		return ExecReload(cmd...)(ctx, c)
+	}
+}
+
+// NewPool - создает пул и прогревает в нем size фикстур
+func NewPool(ctx context.Context, size int, factory PoolFactory, reset ResetFunc) (*Pool, error) {
+	p := &Pool{
+		factory: factory,
+		reset:   reset,
+		idle:    make(chan Container, size),
+		leased:  make(map[Container]struct{}),
+	}
+
+	for i := 0; i < size; i++ {
+		c, err := factory(ctx)
+		if err != nil {
+			return nil, errors.And(errors.Wrap(err, "warm up pool fixture"), p.Close())
+		}
+
+		p.idle <- c
+	}
+
+	return p, nil
+}
+
+// Lease - арендует прогретую фикстуру, при пустом пуле создается новая
+func (p *Pool) Lease(ctx context.Context) (Container, error) {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+
+		return nil, ErrPoolClosed
+	}
+	p.mu.Unlock()
+
+	var (
+		c   Container
+		err error
+	)
+
+	select {
+	case c = <-p.idle:
+	default:
+		if c, err = p.factory(ctx); err != nil {
+			return nil, errors.Wrap(err, "create pool fixture")
+		}
+	}
+
+	// пул мог закрыться, пока создавалась фикстура: Close ее уже не увидит
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+
+		return nil, errors.And(ErrPoolClosed, c.Stop())
+	}
+
+	p.leased[c] = struct{}{}
+	p.mu.Unlock()
+
+	return c, nil
+}
+
+// Return - сбрасывает фикстуру и возвращает ее в пул
+func (p *Pool) Return(ctx context.Context, c Container) error {
+	p.mu.Lock()
+	delete(p.leased, c)
+	closed := p.closed
+	p.mu.Unlock()
+
+	if closed {
+		return c.Stop()
+	}
+
+	if p.reset != nil {
+		if err := p.reset(ctx, c); err != nil {
+			return errors.And(
+				errors.Ctx().Str("name", c.GetName()).Wrap(err, "reset pool fixture"),
+				c.Stop(),
+			)
+		}
+	}
+
+	// closed проверяется повторно под p.mu: Close мог завершиться во время
+	// сброса, и фикстура, положенная после него в idle, осталась бы запущенной
+	p.mu.Lock()
+	if !p.closed {
+		select {
+		case p.idle <- c:
+			p.mu.Unlock()
+
+			return nil
+		default:
+			// пул заполнен фикстурами, созданными сверх размера
+		}
+	}
+	p.mu.Unlock()
+
+	return c.Stop()
+}
+
+// Close - останавливает все фикстуры пула, включая арендованные
+func (p *Pool) Close() error {
+	p.mu.Lock()
+	p.closed = true
+
+	leased := make([]Container, 0, len(p.leased))
+	for c := range p.leased {
+		leased = append(leased, c)
+	}
+
+	p.leased = make(map[Container]struct{})
+	p.mu.Unlock()
+
+	var err error
+
+	for _, c := range leased {
+		err = errors.And(err, c.Stop())
+	}
+
+	for {
+		select {
+		case c := <-p.idle:
+			err = errors.And(err, c.Stop())
+		default:
+			return err
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package containers_test
+
+import (
+	"context"
+	"testing"
+
+	"gopkg.in/gomisc/errors.v1"
+
+	"gopkg.in/gomisc/containers.v1"
+	"gopkg.in/gomisc/containers.v1/wait"
+)
+
+func TestPoolLeaseAfterClose(t *testing.T) {
+	cli, c := newTestContainer(t, "pool-lease")
+	c.Readiness = wait.Immediately()
+
+	var (
+		pool     *containers.Pool
+		closeErr error
+	)
+
+	factory := func(ctx context.Context) (containers.Container, error) {
+		if err := c.Run(ctx); err != nil {
+			return nil, err
+		}
+
+		// пул закрывается, пока фикстура создается
+		closeErr = pool.Close()
+
+		return c, nil
+	}
+
+	pool, err := containers.NewPool(context.Background(), 0, factory, nil)
+	if err != nil {
+		t.Fatalf("NewPool: %v", err)
+	}
+
+	if _, err = pool.Lease(context.Background()); !errors.Is(err, containers.ErrPoolClosed) {
+		t.Fatalf("Lease: got %v, want %v", err, containers.ErrPoolClosed)
+	}
+
+	if closeErr != nil {
+		t.Fatalf("Close: %v", closeErr)
+	}
+
+	state, err := cli.ContainerInspect(context.Background(), c.GetID())
+	if err != nil {
+		t.Fatalf("ContainerInspect: %v", err)
+	}
+
+	if state.Running() {
+		t.Fatal("fixture created during Close is left running")
+	}
+}
+
+func TestPoolReturnAfterClose(t *testing.T) {
+	cli, c := newTestContainer(t, "pool-return")
+	c.Readiness = wait.Immediately()
+
+	factory := func(ctx context.Context) (containers.Container, error) {
+		return c, c.Run(ctx)
+	}
+
+	var pool *containers.Pool
+
+	// пул закрывается, пока фикстура сбрасывается
+	reset := func(ctx context.Context, _ containers.Container) error {
+		return pool.Close()
+	}
+
+	pool, err := containers.NewPool(context.Background(), 1, factory, reset)
+	if err != nil {
+		t.Fatalf("NewPool: %v", err)
+	}
+
+	leased, err := pool.Lease(context.Background())
+	if err != nil {
+		t.Fatalf("Lease: %v", err)
+	}
+
+	if err = pool.Return(context.Background(), leased); err != nil {
+		t.Fatalf("Return: %v", err)
+	}
+
+	state, err := cli.ContainerInspect(context.Background(), c.GetID())
+	if err != nil {
+		t.Fatalf("ContainerInspect: %v", err)
+	}
+
+	if state.Running() {
+		t.Fatal("fixture returned during Close is left running")
+	}
+}
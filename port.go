@@ -12,16 +12,16 @@ type (
 	Port string
 
 	PortBinding struct {
-		HostIP   string
-		HostPort string
+		HostIP   string `json:"host_ip,omitempty" yaml:"host_ip,omitempty"`
+		HostPort string `json:"host_port,omitempty" yaml:"host_port,omitempty"`
 	}
 
 	PortMap map[Port][]PortBinding
 
 	PortBind struct {
-		Name      ports.PortName
-		Container Port
-		Host      uint16
+		Name      ports.PortName `json:"name" yaml:"name"`
+		Container Port           `json:"container" yaml:"container"`
+		Host      uint16         `json:"host,omitempty" yaml:"host,omitempty"`
 	}
 
 	PortBinds []PortBind
@@ -0,0 +1,56 @@
+package containers
+
+// WithProfiles - включает контейнер в профили окружения: такой контейнер
+// поднимается только через UpProfiles с одним из его профилей
+func WithProfiles(profiles ...string) MemberOption {
+	return func(m *Member) {
+		m.Profiles = append(m.Profiles, profiles...)
+	}
+}
+
+// Skipped - признак того, что контейнер не вошел в профили последнего подъема
+// окружения и не запускался
+func (m *Member) Skipped() bool {
+	if m.owner != nil {
+		m.owner.mu.Lock()
+		defer m.owner.mu.Unlock()
+	}
+
+	return m.skipped
+}
+
+// inProfiles - контейнер без профилей входит в любой набор профилей
+func (m *Member) inProfiles(profiles []string) bool {
+	if len(m.Profiles) == 0 {
+		return true
+	}
+
+	for _, p := range m.Profiles {
+		for _, active := range profiles {
+			if p == active {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// selectProfiles - отмечает контейнеры вне профилей и возвращает
+// поднимаемые в порядке добавления
+func (o *Orchestrator) selectProfiles(profiles []string) []*Member {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	selected := make([]*Member, 0, len(o.members))
+
+	for _, m := range o.members {
+		m.skipped = !m.inProfiles(profiles)
+
+		if !m.skipped {
+			selected = append(selected, m)
+		}
+	}
+
+	return selected
+}
@@ -0,0 +1,208 @@
+package containers
+
+import (
+	"context"
+	"hash/fnv"
+	"math/big"
+	"net/netip"
+	"regexp"
+	"strings"
+
+	"gopkg.in/gomisc/errors.v1"
+)
+
+const (
+	// ProjectLabel - метка проекта на контейнерах, сетях, томах и образах;
+	// значение - имя проекта
+	ProjectLabel = "gomisc.containers.project"
+	// DefaultProjectSubnetBits - длина префикса выделенной проекту части
+	// пула подсетей, см. Project.SubnetPool
+	DefaultProjectSubnetBits = 16
+
+	// ErrInvalidProject - имя проекта пусто или содержит недопустимые символы
+	ErrInvalidProject = errors.Const("invalid project name")
+)
+
+// projectName - допустимое имя проекта: оно входит в имена объектов и значения меток
+var projectName = regexp.MustCompile(`^[a-z0-9][a-z0-9_.-]*$`)
+
+type (
+	// Project - пространство имен над сессиями клиентов: объекты проекта
+	// получают префикс имени и метку ProjectLabel, а сети - подсети из
+	// выделенной проекту части пула. Так несколько продуктов, делящих демон
+	// CI, не конфликтуют по именам и адресам и очищаются независимо:
+	//
+	//	project := containers.Project("billing")
+	//	cli, err := docker.New(docker.WithProject(project))
+	//	...
+	//	db := project.Container(&containers.BaseContainer{Name: "postgres", ...})
+	//	...
+	//	removed, err := containers.TeardownProject(ctx, cli, project)
+	Project string
+
+	// ProjectResources - объекты проекта в среде исполнения
+	ProjectResources struct {
+		Project    string             `json:"project" yaml:"project"`
+		Containers []ContainerSummary `json:"containers,omitempty" yaml:"containers,omitempty"`
+		Networks   []NetworkSummary   `json:"networks,omitempty" yaml:"networks,omitempty"`
+		Volumes    []VolumeInfo       `json:"volumes,omitempty" yaml:"volumes,omitempty"`
+		// Images - ссылки (или идентификаторы безымянных) образов проекта
+		Images []string `json:"images,omitempty" yaml:"images,omitempty"`
+	}
+
+	// NetworkSummary - краткие сведения о сети
+	NetworkSummary struct {
+		ID     string            `json:"id" yaml:"id"`
+		Name   string            `json:"name" yaml:"name"`
+		Subnet string            `json:"subnet,omitempty" yaml:"subnet,omitempty"`
+		Labels map[string]string `json:"labels,omitempty" yaml:"labels,omitempty"`
+	}
+)
+
+// Validate - проверяет имя проекта
+func (p Project) Validate() error {
+	if !projectName.MatchString(string(p)) {
+		return errors.Ctx().Str("project", string(p)).Just(ErrInvalidProject)
+	}
+
+	return nil
+}
+
+// Name - имя объекта проекта с префиксом проекта; имя с префиксом не меняется
+func (p Project) Name(name string) string {
+	prefix := string(p) + "-"
+
+	if p == "" || strings.HasPrefix(name, prefix) {
+		return name
+	}
+
+	return prefix + name
+}
+
+// Labels - копия labels с меткой проекта
+func (p Project) Labels(labels map[string]string) map[string]string {
+	result := make(map[string]string, len(labels)+1)
+
+	for k, v := range labels {
+		result[k] = v
+	}
+
+	if p != "" {
+		result[ProjectLabel] = string(p)
+	}
+
+	return result
+}
+
+// Filter - условие отбора контейнеров проекта для ContainerList
+func (p Project) Filter() ListFilter {
+	return ListFilter{Labels: map[string]string{ProjectLabel: string(p)}}
+}
+
+// Container - переносит контейнер и его именованные тома в проект: имена
+// получают префикс, прежнее имя контейнера остается сетевым псевдонимом,
+// чтобы соседи по топологии обращались к нему как раньше
+func (p Project) Container(c *BaseContainer) *BaseContainer {
+	if p == "" {
+		return c
+	}
+
+	if name := p.Name(c.Name); name != c.Name {
+		c.Aliases = append(c.Aliases, c.Name)
+		c.Name = name
+	}
+
+	c.Labels = p.Labels(c.Labels)
+
+	for i := range c.NamedVolumes {
+		c.NamedVolumes[i].Name = p.Name(c.NamedVolumes[i].Name)
+		c.NamedVolumes[i].Labels = p.Labels(c.NamedVolumes[i].Labels)
+	}
+
+	return c
+}
+
+// SubnetPool - часть пула pool с длиной префикса bits (0 -
+// DefaultProjectSubnetBits), выделенная проекту. Часть выбирается по хешу
+// имени, поэтому постоянна между запусками без общего состояния; части
+// разных проектов могут совпасть, тогда аллокатор пропускает занятые подсети
+func (p Project) SubnetPool(pool string, bits int) (string, error) {
+	prefix, err := netip.ParsePrefix(pool)
+	if err != nil {
+		return "", errors.Ctx().Str("pool", pool).Wrap(err, "parse subnet pool")
+	}
+
+	if bits == 0 {
+		bits = DefaultProjectSubnetBits
+	}
+
+	prefix = prefix.Masked()
+	addrBits := prefix.Addr().BitLen()
+
+	if bits < prefix.Bits() || bits > addrBits {
+		return "", errors.Ctx().Int("prefix", bits).Str("pool", pool).Just(ErrInvalidSubnetPrefix)
+	}
+
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(p))
+
+	slices := new(big.Int).Lsh(big.NewInt(1), uint(bits-prefix.Bits()))
+	index := new(big.Int).Mod(big.NewInt(int64(h.Sum32())), slices)
+
+	start := new(big.Int).SetBytes(prefix.Addr().AsSlice())
+	start.Add(start, index.Lsh(index, uint(addrBits-bits)))
+
+	buf := make([]byte, addrBits/8)
+	start.FillBytes(buf)
+
+	addr, _ := netip.AddrFromSlice(buf)
+
+	return netip.PrefixFrom(addr, bits).String(), nil
+}
+
+// Empty - в проекте нет объектов
+func (r *ProjectResources) Empty() bool {
+	return len(r.Containers) == 0 && len(r.Networks) == 0 && len(r.Volumes) == 0 && len(r.Images) == 0
+}
+
+// TeardownProject - удаляет все объекты проекта, созданные любыми сессиями:
+// контейнеры, затем сети, тома и образы. Ошибки удаления не прерывают
+// очистку и возвращаются вместе; возвращает найденные объекты проекта
+func TeardownProject(ctx context.Context, cli Client, p Project) (*ProjectResources, error) {
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+
+	ext := ExtendClient(cli)
+
+	res, err := ext.ListProjectResources(ctx, string(p))
+	if err != nil {
+		return nil, errors.Ctx().Str("project", string(p)).Wrap(err, "list project resources")
+	}
+
+	var result error
+
+	for _, c := range res.Containers {
+		if err = ext.ContainerRemove(ctx, c.ID); err != nil {
+			result = errors.And(result, errors.Ctx().Str("container-name", c.Name).Wrap(err, "remove container"))
+		}
+	}
+
+	for _, nw := range res.Networks {
+		if err = cli.RemoveNetwork(nw.ID); err != nil {
+			result = errors.And(result, errors.Ctx().Str("network", nw.Name).Wrap(err, "remove network"))
+		}
+	}
+
+	for _, v := range res.Volumes {
+		if err = ext.VolumeRemove(ctx, v.Name, true); err != nil {
+			result = errors.And(result, errors.Ctx().Str("volume", v.Name).Wrap(err, "remove volume"))
+		}
+	}
+
+	for _, image := range res.Images {
+		cli.RemoveImage(image)
+	}
+
+	return res, result
+}
@@ -0,0 +1,309 @@
+package containers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"gopkg.in/gomisc/containers.v1/wait"
+	"gopkg.in/gomisc/errors.v1"
+	"gopkg.in/gomisc/network.v1/ports"
+)
+
+// Настройки прокси по умолчанию
+const (
+	DefaultToxiproxyImage = "ghcr.io/shopify/toxiproxy:2.5.0"
+	DefaultProxyPorts     = 16
+
+	ErrProxyPortsExhausted = errors.Const("proxy ports exhausted")
+	ErrProxyPortNotFound   = errors.Const("container port not found")
+	ErrProxyAPI            = errors.Const("toxiproxy api error")
+
+	errToxicNotFound = errors.Const("toxic not found")
+
+	toxiproxyAPIPort       = 8474
+	toxiproxyFirstPort     = 20000
+	toxiproxyAPIName       = ports.PortName("toxiproxy-api")
+	toxicLatency           = "latency"
+	toxicBandwidth         = "bandwidth"
+	toxiproxyClientTimeout = 10 * time.Second
+)
+
+type (
+	// ProxyManager - управляемый контейнер Toxiproxy, через который можно
+	// направить порты контейнеров топологии для внесения сетевых сбоев
+	ProxyManager struct {
+		*BaseContainer
+		http *http.Client
+
+		mu    sync.Mutex
+		free  []PortBind
+		names map[string]struct{}
+	}
+
+	// Proxy - прокси порта контейнера
+	Proxy struct {
+		manager  *ProxyManager
+		name     string
+		upstream string
+		bind     PortBind
+	}
+
+	toxiproxyProxy struct {
+		Name     string `json:"name"`
+		Listen   string `json:"listen,omitempty"`
+		Upstream string `json:"upstream,omitempty"`
+		Enabled  bool   `json:"enabled"`
+	}
+
+	toxiproxyToxic struct {
+		Name       string         `json:"name"`
+		Type       string         `json:"type"`
+		Stream     string         `json:"stream"`
+		Toxicity   float64        `json:"toxicity"`
+		Attributes map[string]any `json:"attributes"`
+	}
+)
+
+// NewProxyManager - запускает контейнер Toxiproxy в сети nw с size заранее
+// опубликованными на хосте портами под прокси (0 - DefaultProxyPorts)
+func NewProxyManager(ctx context.Context, cli Client, nw Network, size int) (*ProxyManager, error) {
+	if size <= 0 {
+		size = DefaultProxyPorts
+	}
+
+	pm := &ProxyManager{
+		BaseContainer: NewBaseContainer(cli, nw, nil),
+		http:          &http.Client{Timeout: toxiproxyClientTimeout},
+		names:         make(map[string]struct{}),
+	}
+
+	pm.Name = fixtureName(DefaultToxiproxyImage)
+	pm.Image = DefaultToxiproxyImage
+
+	apiPort, err := FreeHostPort()
+	if err != nil {
+		return nil, errors.Wrap(err, "get toxiproxy api port")
+	}
+
+	pm.Ports = append(
+		pm.Ports, PortBind{
+			Name:      toxiproxyAPIName,
+			Container: NewPort(toxiproxyAPIPort, "tcp"),
+			Host:      apiPort,
+		},
+	)
+
+	for i := 0; i < size; i++ {
+		hostPort, portErr := FreeHostPort()
+		if portErr != nil {
+			return nil, errors.Wrap(portErr, "get proxy host port")
+		}
+
+		bind := PortBind{
+			Name:      ports.PortName("proxy-" + strconv.Itoa(i)),
+			Container: NewPort(uint16(toxiproxyFirstPort+i), "tcp"),
+			Host:      hostPort,
+		}
+
+		pm.Ports = append(pm.Ports, bind)
+		pm.free = append(pm.free, bind)
+	}
+
+	// порты под прокси до их создания не слушаются, готовность - по порту API
+	pm.Readiness = wait.ForListeningPorts(
+		func() []string {
+			return []string{pm.Endpoint(toxiproxyAPIName)}
+		},
+	)
+
+	if err = pm.Run(ctx); err != nil {
+		return nil, errors.Wrap(err, "start toxiproxy")
+	}
+
+	return pm, nil
+}
+
+// Route - создает прокси для порта name контейнера c: клиенты на хосте и в сети
+// подключаются к адресам прокси, а прокси передает трафик контейнеру. Контейнер
+// должен быть запущен
+func (pm *ProxyManager) Route(ctx context.Context, c *BaseContainer, name ports.PortName) (*Proxy, error) {
+	upstream := c.ContainerAddrs()[name]
+	if upstream == "" {
+		return nil, errors.Ctx().Str("container", c.GetName()).Str("port", string(name)).Just(ErrProxyPortNotFound)
+	}
+
+	pm.mu.Lock()
+
+	if len(pm.free) == 0 {
+		pm.mu.Unlock()
+
+		return nil, errors.Ctx().Str("container", c.GetName()).Just(ErrProxyPortsExhausted)
+	}
+
+	bind := pm.free[0]
+	pm.free = pm.free[1:]
+
+	proxyName := c.GetName() + "-" + string(name)
+	for i := 1; ; i++ {
+		if _, used := pm.names[proxyName]; !used {
+			break
+		}
+
+		proxyName = c.GetName() + "-" + string(name) + "-" + strconv.Itoa(i)
+	}
+
+	pm.names[proxyName] = struct{}{}
+	pm.mu.Unlock()
+
+	p := &Proxy{manager: pm, name: proxyName, upstream: upstream, bind: bind}
+
+	err := pm.call(
+		ctx, http.MethodPost, "/proxies", toxiproxyProxy{
+			Name:     proxyName,
+			Listen:   net.JoinHostPort("0.0.0.0", bind.Container.Port()),
+			Upstream: upstream,
+			Enabled:  true,
+		},
+	)
+	if err != nil {
+		pm.mu.Lock()
+		pm.free = append(pm.free, bind)
+		delete(pm.names, proxyName)
+		pm.mu.Unlock()
+
+		return nil, errors.Ctx().Str("proxy", proxyName).Wrap(err, "create proxy")
+	}
+
+	c.setProxy(name, p)
+
+	return p, nil
+}
+
+// Addr - адрес прокси для вызывающего процесса
+func (p *Proxy) Addr() string {
+	return p.manager.Endpoint(p.bind.Name)
+}
+
+// InternalAddr - адрес прокси для контейнеров сети
+func (p *Proxy) InternalAddr() string {
+	return p.manager.ContainerAddrs()[p.bind.Name]
+}
+
+// Upstream - адрес контейнера, на который прокси передает трафик
+func (p *Proxy) Upstream() string {
+	return p.upstream
+}
+
+// SetLatency - добавляет задержку ответов latency с разбросом jitter
+func (p *Proxy) SetLatency(ctx context.Context, latency, jitter time.Duration) error {
+	return p.setToxic(
+		ctx, toxicLatency, map[string]any{
+			"latency": latency.Milliseconds(),
+			"jitter":  jitter.Milliseconds(),
+		},
+	)
+}
+
+// SetBandwidth - ограничивает пропускную способность ответов (КБ/с)
+func (p *Proxy) SetBandwidth(ctx context.Context, rateKB int64) error {
+	return p.setToxic(ctx, toxicBandwidth, map[string]any{"rate": rateKB})
+}
+
+// Down - разрывает соединения и перестает принимать новые
+func (p *Proxy) Down(ctx context.Context) error {
+	return p.manager.call(ctx, http.MethodPost, "/proxies/"+p.name, toxiproxyProxy{Name: p.name, Enabled: false})
+}
+
+// Up - восстанавливает прием соединений
+func (p *Proxy) Up(ctx context.Context) error {
+	return p.manager.call(ctx, http.MethodPost, "/proxies/"+p.name, toxiproxyProxy{Name: p.name, Enabled: true})
+}
+
+// Reset - снимает задержку и ограничение пропускной способности
+func (p *Proxy) Reset(ctx context.Context) error {
+	for _, toxic := range []string{toxicLatency, toxicBandwidth} {
+		if err := p.removeToxic(ctx, toxic); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (p *Proxy) setToxic(ctx context.Context, kind string, attributes map[string]any) error {
+	if err := p.removeToxic(ctx, kind); err != nil {
+		return err
+	}
+
+	return p.manager.call(
+		ctx, http.MethodPost, "/proxies/"+p.name+"/toxics", toxiproxyToxic{
+			Name:       kind,
+			Type:       kind,
+			Stream:     "downstream",
+			Toxicity:   1,
+			Attributes: attributes,
+		},
+	)
+}
+
+func (p *Proxy) removeToxic(ctx context.Context, kind string) error {
+	err := p.manager.call(ctx, http.MethodDelete, "/proxies/"+p.name+"/toxics/"+kind, nil)
+	if err != nil && !errors.Is(err, errToxicNotFound) {
+		return err
+	}
+
+	return nil
+}
+
+func (pm *ProxyManager) call(ctx context.Context, method, path string, body any) error {
+	var reader io.Reader
+
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return errors.Wrap(err, "encode toxiproxy request")
+		}
+
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, "http://"+pm.Endpoint(toxiproxyAPIName)+path, reader)
+	if err != nil {
+		return errors.Wrap(err, "create toxiproxy request")
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := pm.http.Do(req)
+	if err != nil {
+		return errors.Ctx().Str("path", path).Wrap(err, "call toxiproxy api")
+	}
+
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	if resp.StatusCode == http.StatusNotFound && method == http.MethodDelete {
+		return errToxicNotFound
+	}
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<10))
+
+		return errors.Ctx().
+			Str("method", method).
+			Str("path", path).
+			Int("code", resp.StatusCode).
+			Str("body", string(msg)).
+			Just(ErrProxyAPI)
+	}
+
+	return nil
+}
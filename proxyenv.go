@@ -0,0 +1,167 @@
+package containers
+
+import (
+	"net"
+	"os"
+	"strings"
+)
+
+// Переменные прокси, которые передаются из окружения процесса в сборки
+// образов и контейнеры. Значения, заданные явно в Envs или Args, не
+// переопределяются
+const (
+	HTTPProxyEnvar  = "HTTP_PROXY"
+	HTTPSProxyEnvar = "HTTPS_PROXY"
+	NoProxyEnvar    = "NO_PROXY"
+)
+
+type (
+	// subnetNetwork - сеть, сообщающая свою подсеть
+	subnetNetwork interface {
+		Subnet() *net.IPNet
+	}
+
+	// noProxyTarget - контейнер, которому оркестратор сообщает имена
+	// остальных участников окружения для NO_PROXY
+	noProxyTarget interface {
+		addNoProxy(hosts ...string)
+	}
+)
+
+// HostProxyEnv - переменные прокси окружения процесса (имя в верхнем регистре -
+// значение), переменная в верхнем регистре приоритетнее нижнего
+func HostProxyEnv() map[string]string {
+	env := make(map[string]string, 3)
+
+	for _, name := range []string{HTTPProxyEnvar, HTTPSProxyEnvar, NoProxyEnvar} {
+		value := os.Getenv(name)
+		if value == "" {
+			value = os.Getenv(strings.ToLower(name))
+		}
+
+		if value != "" {
+			env[name] = value
+		}
+	}
+
+	return env
+}
+
+// BuildArgs - аргументы сборки с переменными прокси окружения процесса
+// (если NoProxyEnv не задан): docker принимает их без объявления ARG
+func (d *ImageBuildData) BuildArgs() map[string]*string {
+	if d.NoProxyEnv {
+		return d.Args
+	}
+
+	proxy := HostProxyEnv()
+	if len(proxy) == 0 {
+		return d.Args
+	}
+
+	args := make(map[string]*string, len(d.Args)+len(proxy)*2)
+
+	for k, v := range d.Args {
+		args[k] = v
+	}
+
+	for name, value := range proxy {
+		value := value
+
+		for _, key := range []string{name, strings.ToLower(name)} {
+			if _, ok := args[key]; !ok {
+				args[key] = &value
+			}
+		}
+	}
+
+	return args
+}
+
+// addNoProxy - добавляет имена хостов в исключения прокси
+func (c *BaseContainer) addNoProxy(hosts ...string) {
+	known := make(map[string]struct{}, len(c.NoProxy))
+
+	for _, host := range c.NoProxy {
+		known[host] = struct{}{}
+	}
+
+	for _, host := range hosts {
+		if _, ok := known[host]; !ok {
+			known[host] = struct{}{}
+			c.NoProxy = append(c.NoProxy, host)
+		}
+	}
+}
+
+// setupProxyEnv - передает контейнеру переменные прокси окружения процесса;
+// NO_PROXY дополняется loopback адресами, подсетью сети контейнера и именами
+// контейнеров окружения, чтобы трафик внутри топологии шел мимо прокси
+func (c *BaseContainer) setupProxyEnv() {
+	if c.NoProxyEnv {
+		return
+	}
+
+	proxy := HostProxyEnv()
+	if proxy[HTTPProxyEnvar] == "" && proxy[HTTPSProxyEnvar] == "" {
+		return
+	}
+
+	proxy[NoProxyEnvar] = c.noProxy(proxy[NoProxyEnvar])
+
+	for _, name := range []string{HTTPProxyEnvar, HTTPSProxyEnvar, NoProxyEnvar} {
+		value, ok := proxy[name]
+		if !ok {
+			continue
+		}
+
+		for _, key := range []string{name, strings.ToLower(name)} {
+			if !c.hasEnv(key) {
+				c.Envs = append(c.Envs, key+"="+value)
+			}
+		}
+	}
+}
+
+// noProxy - значение NO_PROXY контейнера на основе значения хоста
+func (c *BaseContainer) noProxy(host string) string {
+	var hosts []string
+
+	seen := make(map[string]struct{})
+
+	add := func(values ...string) {
+		for _, v := range values {
+			v = strings.TrimSpace(v)
+			if _, ok := seen[v]; v == "" || ok {
+				continue
+			}
+
+			seen[v] = struct{}{}
+			hosts = append(hosts, v)
+		}
+	}
+
+	add(strings.Split(host, ",")...)
+	add("localhost", "127.0.0.1", "::1")
+
+	if nw, ok := c.network.(subnetNetwork); ok && nw.Subnet() != nil {
+		add(nw.Subnet().String())
+	}
+
+	add(c.GetName())
+	add(c.Aliases...)
+	add(c.NoProxy...)
+
+	return strings.Join(hosts, ",")
+}
+
+// hasEnv - признак явно заданной переменной окружения
+func (c *BaseContainer) hasEnv(name string) bool {
+	for _, env := range c.Envs {
+		if strings.HasPrefix(env, name+"=") {
+			return true
+		}
+	}
+
+	return false
+}
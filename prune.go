@@ -0,0 +1,67 @@
+package containers
+
+import (
+	"time"
+
+	"gopkg.in/gomisc/errors.v1"
+)
+
+// ErrUnscopedPrune - очистка не ограничена условиями и затронула бы весь демон
+const ErrUnscopedPrune = errors.Const("prune is not scoped")
+
+type (
+	// PruneOptions - условия отбора образов PruneImages, пустые условия
+	// не ограничивают выборку; используемые контейнерами образы не удаляются
+	PruneOptions struct {
+		// Labels - метки образа, пустое значение - наличие метки с любым значением
+		Labels map[string]string
+		// OlderThan - удаляются только образы, созданные раньше, чем OlderThan назад
+		OlderThan time.Duration
+		// All - удалять и образы с тегами, без него удаляются только висячие
+		// (dangling) образы, потерявшие тег при пересборке
+		All bool
+	}
+
+	// PruneReport - результат очистки
+	PruneReport struct {
+		// Deleted - удаленные образы (ссылки снятых тегов и идентификаторы)
+		// или записи кеша сборки
+		Deleted []string `json:"deleted,omitempty" yaml:"deleted,omitempty"`
+		// SpaceReclaimed - освобожденное место в байтах
+		SpaceReclaimed uint64 `json:"space_reclaimed" yaml:"space_reclaimed"`
+	}
+
+	// BuildCachePruneOptions - условия отбора записей кеша сборки
+	// PruneBuildCache. Кеш общий для всех сборок демона и не несет меток,
+	// поэтому очистка без OlderThan требует явного AllDaemon
+	BuildCachePruneOptions struct {
+		// OlderThan - удаляются только записи, не использованные дольше OlderThan
+		OlderThan time.Duration
+		// AllDaemon - разрешает очистку всего кеша демона, в том числе записей
+		// чужих сборок
+		AllDaemon bool
+	}
+)
+
+// Validate - проверяет, что очистка ограничена условиями или явно разрешена
+// для всего демона
+func (o BuildCachePruneOptions) Validate() error {
+	if o.OlderThan <= 0 && !o.AllDaemon {
+		return errors.Ctx().Str("target", "build cache").Just(ErrUnscopedPrune)
+	}
+
+	return nil
+}
+
+// Match - проверяет метки и время создания образа на соответствие условиям
+// для адаптеров, которые отбирают образы на стороне клиента
+func (o PruneOptions) Match(labels map[string]string, created, now time.Time) bool {
+	for k, v := range o.Labels {
+		actual, ok := labels[k]
+		if !ok || (v != "" && actual != v) {
+			return false
+		}
+	}
+
+	return o.OlderThan <= 0 || created.Before(now.Add(-o.OlderThan))
+}
@@ -0,0 +1,37 @@
+package containers_test
+
+import (
+	"testing"
+	"time"
+
+	"gopkg.in/gomisc/errors.v1"
+
+	"gopkg.in/gomisc/containers.v1"
+)
+
+func TestBuildCachePruneRequiresScope(t *testing.T) {
+	cases := map[string]struct {
+		opts containers.BuildCachePruneOptions
+		want error
+	}{
+		"unscoped":   {opts: containers.BuildCachePruneOptions{}, want: containers.ErrUnscopedPrune},
+		"older than": {opts: containers.BuildCachePruneOptions{OlderThan: time.Hour}},
+		"all daemon": {opts: containers.BuildCachePruneOptions{AllDaemon: true}},
+	}
+
+	for name, tc := range cases {
+		t.Run(
+			name, func(t *testing.T) {
+				err := tc.opts.Validate()
+
+				if tc.want == nil && err != nil {
+					t.Fatalf("Validate: %v", err)
+				}
+
+				if tc.want != nil && !errors.Is(err, tc.want) {
+					t.Fatalf("Validate: got %v, want %v", err, tc.want)
+				}
+			},
+		)
+	}
+}
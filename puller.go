@@ -0,0 +1,209 @@
+package containers
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"gopkg.in/gomisc/errors.v1"
+)
+
+// DefaultPullConcurrency - число одновременных скачиваний образов по умолчанию
+const DefaultPullConcurrency = 4
+
+type (
+	// ImagePuller - конвейер подготовки образов: одинаковые ссылки скачиваются
+	// один раз, различные - параллельно с ограничением, а ожидающий блокируется
+	// только на своем образе
+	ImagePuller struct {
+		cli Client
+		sem chan struct{}
+
+		mu    sync.Mutex
+		pulls map[string]*pullJob
+		total int
+		done  int
+	}
+
+	// pullJob - общее скачивание образа: выполняется в собственном контексте,
+	// который отменяется, когда уходит последний ожидающий
+	pullJob struct {
+		done    chan struct{}
+		err     error
+		cancel  context.CancelFunc
+		waiters int
+	}
+)
+
+// NewImagePuller - конструктор конвейера подготовки образов
+func NewImagePuller(cli Client, concurrency int) *ImagePuller {
+	if concurrency <= 0 {
+		concurrency = DefaultPullConcurrency
+	}
+
+	return &ImagePuller{
+		cli:   cli,
+		sem:   make(chan struct{}, concurrency),
+		pulls: make(map[string]*pullJob),
+	}
+}
+
+// Prepare - запускает фоновую подготовку образов, отсутствующих локально;
+// наличие образов проверяется одним запросом. Подготовка продолжается до
+// готовности образа или отмены ctx, если образ больше никто не ожидает
+func (p *ImagePuller) Prepare(ctx context.Context, refs ...string) {
+	resolved := make([]string, len(refs))
+
+	for i, ref := range refs {
+		resolved[i] = ResolveImage(ref)
+	}
+
+	// при ошибке наличие каждого образа проверит его задача
+	exist, _ := ExtendClient(p.cli).FindImagesLocal(ctx, resolved)
+
+	for _, ref := range resolved {
+		if exist[ref] {
+			p.ready(ref)
+
+			continue
+		}
+
+		job := p.join(ref)
+
+		go func(ref string) {
+			select {
+			case <-job.done:
+			case <-ctx.Done():
+				p.leave(ref, job)
+			}
+		}(ref)
+	}
+}
+
+// ready - регистрирует уже имеющийся локально образ как подготовленный
+func (p *ImagePuller) ready(ref string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if _, ok := p.pulls[ref]; ok {
+		return
+	}
+
+	job := &pullJob{done: make(chan struct{}), cancel: func() {}}
+	close(job.done)
+
+	p.pulls[ref] = job
+}
+
+// Wait - дожидается готовности образа, запуская его подготовку при необходимости
+func (p *ImagePuller) Wait(ctx context.Context, ref string) error {
+	ref = ResolveImage(ref)
+	job := p.join(ref)
+
+	select {
+	case <-job.done:
+		return job.err
+	case <-ctx.Done():
+		p.leave(ref, job)
+
+		return ctx.Err()
+	}
+}
+
+// join - присоединяет ожидающего к скачиванию образа, запуская его при
+// необходимости; неудачные скачивания не запоминаются, и следующий
+// ожидающий запускает новое
+func (p *ImagePuller) join(ref string) *pullJob {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if job, ok := p.pulls[ref]; ok {
+		job.waiters++
+
+		return job
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	job := &pullJob{done: make(chan struct{}), cancel: cancel, waiters: 1}
+	p.pulls[ref] = job
+	p.total++
+
+	go func() {
+		defer close(job.done)
+		defer cancel()
+
+		err := p.pull(ctx, ref)
+
+		p.mu.Lock()
+		job.err = err
+
+		if err != nil {
+			p.evict(ref, job)
+		} else {
+			p.done++
+		}
+
+		done, total := p.done, p.total
+		p.mu.Unlock()
+
+		if err == nil {
+			_, _ = fmt.Fprintf(ExtendClient(p.cli).Stdout(), "image ready %s (%d/%d)\n", ref, done, total)
+		}
+	}()
+
+	return job
+}
+
+// leave - отсоединяет ожидающего; скачивание без ожидающих отменяется
+func (p *ImagePuller) leave(ref string, job *pullJob) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if job.waiters--; job.waiters > 0 {
+		return
+	}
+
+	select {
+	case <-job.done:
+		return
+	default:
+	}
+
+	job.cancel()
+	p.evict(ref, job)
+}
+
+// evict - забывает скачивание job, если оно еще числится за ref. Вызывается под p.mu
+func (p *ImagePuller) evict(ref string, job *pullJob) {
+	if p.pulls[ref] == job {
+		delete(p.pulls, ref)
+		p.total--
+	}
+}
+
+func (p *ImagePuller) pull(ctx context.Context, ref string) error {
+	exist, err := p.cli.FindImageLocal(ctx, ref)
+	if err != nil {
+		return errors.Ctx().Str("image", ref).Wrap(err, "find image in local cache")
+	}
+
+	if exist {
+		return nil
+	}
+
+	select {
+	case p.sem <- struct{}{}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	defer func() {
+		<-p.sem
+	}()
+
+	if err = ExtendClient(p.cli).PullImageWith(ctx, PullOptions{Image: ref}); err != nil {
+		return errors.Ctx().Str("image", ref).Wrap(err, "pull image")
+	}
+
+	return nil
+}
@@ -0,0 +1,243 @@
+package containers
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/gomisc/errors.v1"
+)
+
+const (
+	// DockerConfigEnvar - каталог конфигурации docker CLI (по умолчанию ~/.docker)
+	DockerConfigEnvar = "DOCKER_CONFIG"
+
+	// DockerHubRegistry - реестр образов без явного указания реестра в ссылке
+	DockerHubRegistry = "docker.io"
+
+	ErrCredentialHelper = errors.Const("docker credential helper failed")
+	ErrInvalidAuth      = errors.Const("invalid registry auth in docker config")
+
+	// dockerHubAuthKey - ключ Docker Hub в config.json и хранилищах учетных данных
+	dockerHubAuthKey = "https://index.docker.io/v1/"
+	// identityTokenUser - имя пользователя, под которым хелпер возвращает токен
+	identityTokenUser = "<token>"
+	// credentialsNotFound - ответ хелпера на отсутствие учетных данных
+	credentialsNotFound = "credentials not found"
+)
+
+type (
+	// RegistryAuth - учетные данные реестра образов
+	RegistryAuth struct {
+		// ServerAddress - адрес реестра, например registry.example.com:5000
+		ServerAddress string `json:"server_address,omitempty" yaml:"server_address,omitempty"`
+		Username      string `json:"username,omitempty" yaml:"username,omitempty"`
+		Password      string `json:"-" yaml:"-"`
+		// IdentityToken - токен обновления OAuth вместо пароля
+		IdentityToken string `json:"-" yaml:"-"`
+	}
+
+	// RegistryAuthProvider - источник учетных данных для реестра образа ref;
+	// анонимное значение без ошибки означает отсутствие учетных данных
+	RegistryAuthProvider func(ref string) (RegistryAuth, error)
+
+	// dockerConfig - учетные данные из config.json docker CLI
+	dockerConfig struct {
+		Auths       map[string]dockerConfigAuth `json:"auths"`
+		CredsStore  string                      `json:"credsStore"`
+		CredHelpers map[string]string           `json:"credHelpers"`
+	}
+
+	dockerConfigAuth struct {
+		Auth          string `json:"auth"`
+		Username      string `json:"username"`
+		Password      string `json:"password"`
+		IdentityToken string `json:"identitytoken"`
+	}
+
+	// helperCredentials - ответ хелпера docker-credential-* на команду get
+	helperCredentials struct {
+		Username string `json:"Username"`
+		Secret   string `json:"Secret"`
+	}
+)
+
+// Anonymous - признак отсутствия учетных данных
+func (a RegistryAuth) Anonymous() bool {
+	return a.Username == "" && a.Password == "" && a.IdentityToken == ""
+}
+
+// StaticAuth - источник с заданными учетными данными
+func StaticAuth(auth RegistryAuth) RegistryAuthProvider {
+	return func(string) (RegistryAuth, error) {
+		return auth, nil
+	}
+}
+
+// DockerConfigAuth - источник учетных данных docker CLI: хелпер из credHelpers
+// или credsStore, иначе запись auths файла config.json. Отсутствие файла
+// или записи для реестра означает анонимный доступ
+func DockerConfigAuth() RegistryAuthProvider {
+	return func(ref string) (RegistryAuth, error) {
+		dir := os.Getenv(DockerConfigEnvar)
+		if dir == "" {
+			home, err := os.UserHomeDir()
+			if err != nil {
+				return RegistryAuth{}, nil //nolint:nilerr
+			}
+
+			dir = filepath.Join(home, ".docker")
+		}
+
+		data, err := os.ReadFile(filepath.Join(dir, "config.json"))
+		if os.IsNotExist(err) {
+			return RegistryAuth{}, nil
+		}
+
+		if err != nil {
+			return RegistryAuth{}, errors.Wrap(err, "read docker config")
+		}
+
+		var cfg dockerConfig
+
+		if err = json.Unmarshal(data, &cfg); err != nil {
+			return RegistryAuth{}, errors.Wrap(err, "decode docker config")
+		}
+
+		return cfg.lookup(RegistryHost(ref))
+	}
+}
+
+// RegistryHost - реестр образа ref: первый компонент пути, если он похож
+// на имя хоста, иначе DockerHubRegistry
+func RegistryHost(ref string) string {
+	i := strings.IndexByte(ref, '/')
+	if i < 0 {
+		return DockerHubRegistry
+	}
+
+	host := ref[:i]
+	if strings.ContainsAny(host, ".:") || host == "localhost" {
+		if host == "index.docker.io" || host == "registry-1.docker.io" {
+			return DockerHubRegistry
+		}
+
+		return host
+	}
+
+	return DockerHubRegistry
+}
+
+// resolveAuth - учетные данные первого источника, вернувшего неанонимное значение
+func resolveAuth(ref string, providers []RegistryAuthProvider) (RegistryAuth, error) {
+	for _, provider := range providers {
+		auth, err := provider(ref)
+		if err != nil {
+			return RegistryAuth{}, errors.Ctx().Str("image", ref).Wrap(err, "resolve registry auth")
+		}
+
+		if !auth.Anonymous() {
+			return auth, nil
+		}
+	}
+
+	return RegistryAuth{}, nil
+}
+
+func (cfg *dockerConfig) lookup(host string) (RegistryAuth, error) {
+	key := host
+	if host == DockerHubRegistry {
+		key = dockerHubAuthKey
+	}
+
+	helper := cfg.CredHelpers[host]
+	if helper == "" {
+		helper = cfg.CredsStore
+	}
+
+	if helper != "" {
+		return credentialHelper(helper, key)
+	}
+
+	for server, entry := range cfg.Auths {
+		if server != key && normalizeServer(server) != host {
+			continue
+		}
+
+		auth := RegistryAuth{
+			ServerAddress: server,
+			Username:      entry.Username,
+			Password:      entry.Password,
+			IdentityToken: entry.IdentityToken,
+		}
+
+		if entry.Auth != "" {
+			decoded, err := base64.StdEncoding.DecodeString(entry.Auth)
+			if err != nil {
+				return RegistryAuth{}, errors.And(errors.Ctx().Str("registry", host).Just(ErrInvalidAuth), err)
+			}
+
+			user, password, ok := strings.Cut(string(decoded), ":")
+			if !ok {
+				return RegistryAuth{}, errors.Ctx().Str("registry", host).Just(ErrInvalidAuth)
+			}
+
+			auth.Username, auth.Password = user, password
+		}
+
+		return auth, nil
+	}
+
+	return RegistryAuth{}, nil
+}
+
+// credentialHelper - запрашивает учетные данные у docker-credential-<helper>
+func credentialHelper(helper, server string) (RegistryAuth, error) {
+	var stdout, stderr bytes.Buffer
+
+	cmd := exec.Command("docker-credential-"+helper, "get") //nolint:gosec
+	cmd.Stdin = strings.NewReader(server)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		if strings.Contains(stdout.String()+stderr.String(), credentialsNotFound) {
+			return RegistryAuth{}, nil
+		}
+
+		return RegistryAuth{}, errors.And(
+			errors.Ctx().Str("helper", helper).Str("output", stderr.String()).Just(ErrCredentialHelper),
+			err,
+		)
+	}
+
+	var creds helperCredentials
+
+	if err := json.Unmarshal(stdout.Bytes(), &creds); err != nil {
+		return RegistryAuth{}, errors.Ctx().Str("helper", helper).Wrap(err, "decode helper credentials")
+	}
+
+	auth := RegistryAuth{ServerAddress: server, Username: creds.Username, Password: creds.Secret}
+
+	if creds.Username == identityTokenUser {
+		auth = RegistryAuth{ServerAddress: server, IdentityToken: creds.Secret}
+	}
+
+	return auth, nil
+}
+
+// normalizeServer - имя хоста из адреса сервера в config.json
+// (https://registry.example.com/v2/ -> registry.example.com)
+func normalizeServer(server string) string {
+	server = strings.TrimPrefix(strings.TrimPrefix(server, "https://"), "http://")
+
+	if i := strings.IndexByte(server, '/'); i >= 0 {
+		server = server[:i]
+	}
+
+	return server
+}
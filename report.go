@@ -0,0 +1,210 @@
+package containers
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"gopkg.in/gomisc/errors.v1"
+)
+
+// RunReportFileEnvar - путь к файлу, в который оркестратор дописывает отчеты
+// о запусках контейнеров (JSON Lines) при остановке окружения
+const RunReportFileEnvar = "CONTAINERS_RUN_REPORT"
+
+type (
+	// RunReport - машиночитаемый итог жизненного цикла контейнера: по
+	// отчетам, накопленным за историю CI, отслеживаются нестабильные фикстуры
+	RunReport struct {
+		Name   string `json:"name" yaml:"name"`
+		ID     string `json:"id,omitempty" yaml:"id,omitempty"`
+		Image  string `json:"image" yaml:"image"`
+		Digest string `json:"digest,omitempty" yaml:"digest,omitempty"`
+		// StartedAt, FinishedAt, Duration - время работы процесса контейнера
+		StartedAt  time.Time     `json:"started_at" yaml:"started_at"`
+		FinishedAt time.Time     `json:"finished_at" yaml:"finished_at"`
+		Duration   time.Duration `json:"duration" yaml:"duration"`
+		ExitCode   int           `json:"exit_code" yaml:"exit_code"`
+		OOM        bool          `json:"oom,omitempty" yaml:"oom,omitempty"`
+		// RestartCount - перезапуски процесса политикой перезапуска
+		RestartCount int `json:"restart_count" yaml:"restart_count"`
+		// BytesLogged - объем вывода stdout и stderr контейнера
+		BytesLogged int64 `json:"bytes_logged" yaml:"bytes_logged"`
+		// PeakMemory - наибольшее потребление памяти по замерам ContainerStats,
+		// 0 - замеры недоступны
+		PeakMemory uint64 `json:"peak_memory" yaml:"peak_memory"`
+	}
+
+	// Reporter - контейнер, формирующий RunReport при остановке
+	Reporter interface {
+		// RunReport - отчет о последнем завершенном запуске; false - контейнер
+		// еще не останавливался
+		RunReport() (RunReport, bool)
+	}
+
+	// countWriter - считает записанные байты
+	countWriter struct {
+		n atomic.Int64
+	}
+)
+
+var _ Reporter = (*BaseContainer)(nil)
+
+// RunReport - отчет о последнем запуске контейнера, формируется при Stop
+func (c *BaseContainer) RunReport() (RunReport, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.report == nil {
+		return RunReport{}, false
+	}
+
+	return *c.report, true
+}
+
+// sampleStats - отслеживает пиковое потребление памяти запущенного контейнера
+// до Stop; повторный вызов (после Restart) заменяет прежний поток замеров
+func (c *BaseContainer) sampleStats() {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	c.mutex.Lock()
+	if c.cancelStats != nil {
+		c.cancelStats()
+	}
+
+	c.cancelStats = cancel
+	id := c.containerID
+
+	if c.startedAt.IsZero() {
+		c.startedAt = time.Now()
+	}
+	c.mutex.Unlock()
+
+	samples, err := c.runtime().ContainerStats(ctx, id)
+	if err != nil {
+		// без замеров отчет остается без PeakMemory
+		return
+	}
+
+	go func() {
+		for s := range samples {
+			c.mutex.Lock()
+			if s.MemoryUsage > c.peakMemory {
+				c.peakMemory = s.MemoryUsage
+			}
+			c.mutex.Unlock()
+		}
+	}()
+}
+
+// makeReport - формирует отчет остановленного контейнера с кодом завершения
+// status. Сведения, которые среда исполнения уже не отдает (контейнер удален
+// Autoremove), заменяются известными на стороне клиента
+func (c *BaseContainer) makeReport(ctx context.Context, status ContainerStatus) {
+	c.mutex.Lock()
+	if c.cancelStats != nil {
+		c.cancelStats()
+		c.cancelStats = nil
+	}
+
+	report := &RunReport{
+		Name:       c.GetName(),
+		ID:         c.containerID,
+		Image:      c.GetImage(),
+		StartedAt:  c.startedAt,
+		FinishedAt: time.Now(),
+		ExitCode:   int(status.StatusCode),
+		PeakMemory: c.peakMemory,
+	}
+	tail := c.tail
+	c.mutex.Unlock()
+
+	if info, err := c.runtime().ContainerInspect(ctx, c.containerID); err == nil {
+		report.ExitCode = info.ExitCode
+		report.OOM = info.OOMKilled
+		report.RestartCount = info.Restarts
+
+		if !info.StartedAt.IsZero() && !info.FinishedAt.IsZero() {
+			report.StartedAt, report.FinishedAt = info.StartedAt, info.FinishedAt
+		}
+	}
+
+	if !report.StartedAt.IsZero() {
+		report.Duration = report.FinishedAt.Sub(report.StartedAt)
+	}
+
+	if report.Image != "" {
+		if digest, err := c.runtime().ImageDigest(ctx, report.Image); err == nil {
+			report.Digest = digest
+		}
+	}
+
+	counter := &countWriter{}
+
+	switch err := c.client.StreamLogs(ctx, c.containerID, counter, counter, false); {
+	case err == nil:
+		report.BytesLogged = counter.n.Load()
+	case tail != nil:
+		// логи удалены вместе с контейнером, остается объем транслированного вывода
+		report.BytesLogged = tail.Written()
+	}
+
+	c.mutex.Lock()
+	c.report = report
+	c.mutex.Unlock()
+}
+
+// Reports - отчеты о запусках участников окружения в порядке добавления;
+// участники, которые не запускались или еще не остановлены, пропускаются
+func (o *Orchestrator) Reports() []RunReport {
+	var reports []RunReport
+
+	for _, m := range o.Members() {
+		if r, ok := m.Container.(Reporter); ok && !m.Skipped() {
+			if report, done := r.RunReport(); done {
+				reports = append(reports, report)
+			}
+		}
+	}
+
+	return reports
+}
+
+// WriteRunReports - пишет отчеты в формате JSON Lines, по отчету в строке
+func WriteRunReports(w io.Writer, reports []RunReport) error {
+	enc := json.NewEncoder(w)
+
+	for _, r := range reports {
+		if err := enc.Encode(r); err != nil {
+			return errors.Ctx().Str("name", r.Name).Wrap(err, "encode run report")
+		}
+	}
+
+	return nil
+}
+
+// AppendRunReportFile - дописывает отчеты окружения в файл path, файл
+// накапливает отчеты всех окружений прогона
+func (o *Orchestrator) AppendRunReportFile(path string) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return errors.Ctx().Str("path", path).Wrap(err, "open run report file")
+	}
+
+	if err = WriteRunReports(f, o.Reports()); err != nil {
+		_ = f.Close()
+
+		return err
+	}
+
+	return f.Close()
+}
+
+func (w *countWriter) Write(p []byte) (int, error) {
+	w.n.Add(int64(len(p)))
+
+	return len(p), nil
+}
@@ -0,0 +1,83 @@
+package containers
+
+import (
+	"os"
+	"strings"
+	"sync"
+)
+
+// ImageOverrideEnvarPrefix - префикс переменных окружения для подмены образов, например
+// CONTAINERS_IMAGE_OVERRIDE_postgres=registry.local/pg:15-patched
+const ImageOverrideEnvarPrefix = "CONTAINERS_IMAGE_OVERRIDE_"
+
+var imageOverrides = struct {
+	sync.RWMutex
+	refs map[string]string
+}{refs: make(map[string]string)}
+
+// SetImageOverride - задает подмену образа. Ключом служит полная ссылка на
+// образ (postgres:15) или короткое имя репозитория (postgres)
+func SetImageOverride(key, ref string) {
+	imageOverrides.Lock()
+	defer imageOverrides.Unlock()
+
+	imageOverrides.refs[key] = ref
+}
+
+// SetImageOverrides - задает набор подмен образов
+func SetImageOverrides(overrides map[string]string) {
+	imageOverrides.Lock()
+	defer imageOverrides.Unlock()
+
+	for key, ref := range overrides {
+		imageOverrides.refs[key] = ref
+	}
+}
+
+// ResolveImage - возвращает итоговую ссылку на образ с учетом подмен. Порядок
+// поиска: программная подмена по полной ссылке, по короткому имени, затем
+// переменная окружения с коротким именем (символы '-' и '.' заменяются на '_')
+func ResolveImage(ref string) string {
+	name := imageShortName(ref)
+
+	imageOverrides.RLock()
+	override, ok := imageOverrides.refs[ref]
+
+	if !ok {
+		override, ok = imageOverrides.refs[name]
+	}
+	imageOverrides.RUnlock()
+
+	if ok {
+		return override
+	}
+
+	if override = os.Getenv(ImageOverrideEnvarPrefix + envarSafe(name)); override != "" {
+		return override
+	}
+
+	return ref
+}
+
+// imageShortName - имя репозитория образа без реестра, тега и дайджеста
+func imageShortName(ref string) string {
+	name := ref
+
+	if i := strings.Index(name, "@"); i >= 0 {
+		name = name[:i]
+	}
+
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		name = name[i+1:]
+	}
+
+	if i := strings.Index(name, ":"); i >= 0 {
+		name = name[:i]
+	}
+
+	return name
+}
+
+func envarSafe(name string) string {
+	return strings.NewReplacer("-", "_", ".", "_").Replace(name)
+}
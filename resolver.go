@@ -0,0 +1,188 @@
+package containers
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/gomisc/errors.v1"
+)
+
+// ErrPortNotExposed - порт сервиса топологии недоступен вызывающему процессу
+const ErrPortNotExposed = errors.Const("port is not exposed by topology service")
+
+// Resolver - разрешение имен сервисов топологии (имен и сетевых псевдонимов
+// контейнеров) в адреса, доступные вызывающему процессу: адреса в сети
+// контейнеров, если процесс сам запущен в контейнере, иначе - опубликованные
+// на хосте. Порт в адресе - порт контейнера, на хосте он заменяется
+// опубликованным, поэтому разрешение выполняется при соединении, а не
+// подменой net.Resolver. Код под тестом продолжает обращаться по именам:
+//
+//	res := containers.NewResolver(db, api)
+//	client := &http.Client{Transport: res.Transport()}
+//	conn, err := grpc.Dial("api:9090", grpc.WithContextDialer(res.Dial), ...)
+//
+// Имена, не известные Resolver, разрешаются системой
+type Resolver struct {
+	mu       sync.RWMutex
+	services map[string]Container
+	dialer   *net.Dialer
+}
+
+// NewResolver - создает Resolver с сервисами conts под их именами и псевдонимами
+func NewResolver(conts ...Container) *Resolver {
+	r := &Resolver{
+		services: make(map[string]Container),
+		dialer:   &net.Dialer{Timeout: 10 * time.Second, KeepAlive: 30 * time.Second},
+	}
+
+	for _, c := range conts {
+		r.Add(c)
+	}
+
+	return r
+}
+
+// Add - добавляет сервис c под его именем, псевдонимами во всех сетях и
+// дополнительными именами aliases; совпадающие имена заменяются
+func (r *Resolver) Add(c Container, aliases ...string) *Resolver {
+	names := append([]string{c.GetName()}, ExtendContainer(c).GetAliases()...)
+
+	if extra, ok := c.(interface{ GetExtraNetworks() []NetworkAttachment }); ok {
+		for _, att := range extra.GetExtraNetworks() {
+			names = append(names, att.Aliases...)
+		}
+	}
+
+	names = append(names, aliases...)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, name := range names {
+		if name = hostKey(name); name != "" {
+			r.services[name] = c
+		}
+	}
+
+	return r
+}
+
+// Resolve - адрес host:port, доступный вызывающему процессу; адреса
+// с неизвестными именами возвращаются без изменений
+func (r *Resolver) Resolve(addr string) (string, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "", errors.Ctx().Str("addr", addr).Wrap(err, "split service address")
+	}
+
+	c, ok := r.service(host)
+	if !ok {
+		return addr, nil
+	}
+
+	inContainer := callerInContainer(c)
+	containerAddrs, hostAddrs := c.ContainerAddrs(), c.HostAddrs()
+
+	for name, endpoint := range containerAddrs {
+		if _, p, splitErr := net.SplitHostPort(endpoint); splitErr != nil || p != port {
+			continue
+		}
+
+		if inContainer {
+			return endpoint, nil
+		}
+
+		if published, found := hostAddrs[name]; found {
+			return published, nil
+		}
+	}
+
+	// из сети контейнеров доступны и не описанные в Ports порты
+	if inContainer {
+		for _, endpoint := range containerAddrs {
+			if ip, _, splitErr := net.SplitHostPort(endpoint); splitErr == nil {
+				return net.JoinHostPort(ip, port), nil
+			}
+		}
+	}
+
+	return "", errors.Ctx().
+		Str("service", host).
+		Str("port", port).
+		Str("container-name", c.GetName()).
+		Just(ErrPortNotExposed)
+}
+
+// LookupHost - адреса сервиса host, как net.Resolver.LookupHost: адрес
+// контейнера или хоста, на котором опубликованы его порты
+func (r *Resolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	c, ok := r.service(host)
+	if !ok {
+		return net.DefaultResolver.LookupHost(ctx, host)
+	}
+
+	addrs := c.HostAddrs()
+	if callerInContainer(c) {
+		addrs = c.ContainerAddrs()
+	}
+
+	for _, endpoint := range addrs {
+		if ip, _, err := net.SplitHostPort(endpoint); err == nil {
+			return []string{ip}, nil
+		}
+	}
+
+	return nil, &net.DNSError{Err: "no endpoints", Name: host, IsNotFound: true}
+}
+
+// DialContext - соединение с адресом addr после разрешения имени сервиса,
+// подходит для http.Transport.DialContext и net.Dialer-совместимых клиентов
+func (r *Resolver) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	resolved, err := r.Resolve(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.dialer.DialContext(ctx, network, resolved)
+}
+
+// Dial - TCP соединение с адресом addr, сигнатура grpc.WithContextDialer
+func (r *Resolver) Dial(ctx context.Context, addr string) (net.Conn, error) {
+	return r.DialContext(ctx, "tcp", addr)
+}
+
+// Transport - HTTP транспорт, соединяющийся с сервисами по их именам;
+// прокси окружения не используется
+func (r *Resolver) Transport() *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = r.DialContext
+
+	return transport
+}
+
+func (r *Resolver) service(host string) (Container, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	c, ok := r.services[hostKey(host)]
+
+	return c, ok
+}
+
+// callerInContainer - вызывающий процесс запущен в контейнере и обращается
+// к сервису c по адресам сети контейнеров
+func callerInContainer(c Container) bool {
+	cli := c.GetClient()
+
+	return cli != nil && cli.IsInContainer()
+}
+
+// hostKey - имя хоста без учета регистра и завершающей точки
+func hostKey(host string) string {
+	return strings.ToLower(strings.TrimSuffix(host, "."))
+}
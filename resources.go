@@ -0,0 +1,209 @@
+package containers
+
+import (
+	"context"
+	"strings"
+
+	"gopkg.in/gomisc/errors.v1"
+)
+
+const (
+	// MinMemoryLimit - минимальный лимит памяти, который принимает docker
+	MinMemoryLimit = 6 * 1024 * 1024
+
+	// UnlimitedSwap - значение MemorySwap, снимающее ограничение на swap
+	UnlimitedSwap = -1
+
+	ErrResourceUnsupported = errors.Const("resource limit is not supported by host")
+	ErrResourceInvalid     = errors.Const("invalid resource limit")
+)
+
+// Resources - ограничения ресурсов контейнера, нулевое значение - без ограничения
+type Resources struct {
+	// MemoryLimit - предел памяти в байтах
+	MemoryLimit int64 `json:"memory_limit,omitempty" yaml:"memory_limit,omitempty"`
+	// MemorySwap - предел памяти вместе со swap в байтах, UnlimitedSwap - без ограничения
+	MemorySwap int64 `json:"memory_swap,omitempty" yaml:"memory_swap,omitempty"`
+	// MemoryReservation - мягкий предел памяти в байтах, до которого память
+	// контейнера сокращается при нехватке памяти хоста
+	MemoryReservation int64 `json:"memory_reservation,omitempty" yaml:"memory_reservation,omitempty"`
+	// CPUs - доля процессорного времени в ядрах (0.5 - половина ядра)
+	CPUs float64 `json:"cpus,omitempty" yaml:"cpus,omitempty"`
+	// PidsLimit - предельное число процессов в контейнере
+	PidsLimit int64 `json:"pids_limit,omitempty" yaml:"pids_limit,omitempty"`
+}
+
+// ValidateResources - проверяет ограничения ресурсов на поддержку хостом. Docker
+// молча игнорирует лимиты, которые не поддерживает ядро или версия cgroup,
+// поэтому неподдерживаемый лимит возвращается как ошибка с подсказкой
+func ValidateResources(info *DaemonInfo, r Resources) error {
+	var err error
+
+	cgroup := info.CgroupVersion
+	if cgroup == "" {
+		cgroup = "1"
+	}
+
+	unsupported := func(limit, hint string) {
+		err = errors.And(
+			err,
+			errors.Ctx().Str("limit", limit).Str("cgroup", cgroup).Str("hint", hint).Just(ErrResourceUnsupported),
+		)
+	}
+
+	invalid := func(limit, reason string) {
+		err = errors.And(err, errors.Ctx().Str("limit", limit).Str("reason", reason).Just(ErrResourceInvalid))
+	}
+
+	if r.MemoryLimit != 0 {
+		switch {
+		case !info.Limits.Memory:
+			unsupported("memory_limit", memoryHint(cgroup))
+		case r.MemoryLimit < MinMemoryLimit:
+			invalid("memory_limit", "must be at least 6MiB")
+		case info.MemTotal > 0 && r.MemoryLimit > info.MemTotal:
+			invalid("memory_limit", "exceeds host memory")
+		}
+	}
+
+	if r.MemorySwap != 0 {
+		switch {
+		case !info.Limits.Swap:
+			unsupported("memory_swap", swapHint(cgroup))
+		case r.MemoryLimit == 0:
+			invalid("memory_swap", "requires memory_limit")
+		case r.MemorySwap != UnlimitedSwap && r.MemorySwap < r.MemoryLimit:
+			invalid("memory_swap", "must not be less than memory_limit")
+		}
+	}
+
+	if r.MemoryReservation != 0 {
+		switch {
+		case !info.Limits.Memory:
+			unsupported("memory_reservation", memoryHint(cgroup))
+		case r.MemoryReservation < MinMemoryLimit:
+			invalid("memory_reservation", "must be at least 6MiB")
+		case r.MemoryLimit != 0 && r.MemoryReservation > r.MemoryLimit:
+			invalid("memory_reservation", "must not exceed memory_limit")
+		}
+	}
+
+	if r.CPUs != 0 {
+		switch {
+		case !info.Limits.CPUQuota:
+			unsupported("cpus", "enable the cpu cgroup controller (CONFIG_CFS_BANDWIDTH)")
+		case r.CPUs < 0.01:
+			invalid("cpus", "must be at least 0.01")
+		case info.NCPU > 0 && r.CPUs > float64(info.NCPU):
+			invalid("cpus", "exceeds host cpu count")
+		}
+	}
+
+	if r.PidsLimit != 0 && !info.Limits.Pids {
+		unsupported("pids_limit", "enable the pids cgroup controller")
+	}
+
+	return err
+}
+
+// CheckResources - проверяет ограничения ресурсов на поддержку демоном клиента
+func CheckResources(ctx context.Context, cli Client, r Resources) error {
+	info, err := ExtendClient(cli).Info(ctx)
+	if err != nil {
+		return errors.Wrap(err, "get daemon info")
+	}
+
+	return ValidateResources(info, r)
+}
+
+// IsZero - признак отсутствия ограничений
+func (r Resources) IsZero() bool {
+	return r == Resources{}
+}
+
+// GetResources - возвращает ограничения ресурсов контейнера
+func (c *BaseContainer) GetResources() Resources {
+	if c != nil {
+		return c.Resources
+	}
+
+	return Resources{}
+}
+
+// checkResources - проверяет ограничения ресурсов контейнера на поддержку
+// демоном до создания контейнера, а не после его молчаливого запуска без них
+func (c *BaseContainer) checkResources(ctx context.Context) error {
+	if c.Resources.IsZero() {
+		return nil
+	}
+
+	if err := CheckResources(ctx, c.client, c.Resources); err != nil {
+		return errors.Ctx().Str("container-name", c.GetName()).Wrap(err, "check resources")
+	}
+
+	return nil
+}
+
+func memoryHint(cgroup string) string {
+	if cgroup == "2" {
+		return "delegate the memory controller to the docker cgroup (rootless docker needs systemd delegation)"
+	}
+
+	return "enable the memory cgroup (cgroup_enable=memory kernel parameter)"
+}
+
+func swapHint(cgroup string) string {
+	if cgroup == "2" {
+		return "enable swap accounting for the memory controller or drop memory_swap"
+	}
+
+	return "enable swap accounting (swapaccount=1 kernel parameter) or drop memory_swap"
+}
+
+// checkCgroup - сообщает версию cgroup и ограничения, которые хост не поддерживает
+func checkCgroup(ctx context.Context, cli Client) DoctorCheck {
+	check := DoctorCheck{Name: "cgroup", Status: CheckOK}
+
+	info, err := ExtendClient(cli).Info(ctx)
+	if err != nil {
+		check.Status, check.Message = CheckWarn, "get daemon info: "+err.Error()
+
+		return check
+	}
+
+	version := info.CgroupVersion
+	if version == "" {
+		version = "1"
+	}
+
+	check.Message = "v" + version
+
+	if info.CgroupDriver != "" {
+		check.Message += " (" + info.CgroupDriver + ")"
+	}
+
+	var missing []string
+
+	for _, limit := range []struct {
+		name      string
+		supported bool
+	}{
+		{"memory", info.Limits.Memory},
+		{"swap", info.Limits.Swap},
+		{"cpu quota", info.Limits.CPUQuota},
+		{"cpu shares", info.Limits.CPUShares},
+		{"cpuset", info.Limits.CPUSet},
+		{"pids", info.Limits.Pids},
+	} {
+		if !limit.supported {
+			missing = append(missing, limit.name)
+		}
+	}
+
+	if len(missing) != 0 {
+		check.Status = CheckWarn
+		check.Message += ", unsupported limits: " + strings.Join(missing, ", ")
+	}
+
+	return check
+}
@@ -0,0 +1,199 @@
+package containers
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultTailBytes - размер кольцевого буфера хвоста вывода контейнера
+	DefaultTailBytes = 64 << 10
+	// DefaultTailLines - число строк хвоста вывода в ошибках запуска и
+	// инструкциях отладки
+	DefaultTailLines = 20
+
+	// tailFetchTimeout - срок чтения хвоста логов у среды исполнения
+	tailFetchTimeout = 5 * time.Second
+)
+
+// LogRing - кольцевой буфер последних size байт вывода: память не растет
+// с объемом вывода, старые данные вытесняются новыми
+type LogRing struct {
+	mu      sync.Mutex
+	data    []byte
+	start   int
+	size    int
+	dropped int64
+}
+
+// NewLogRing - создает буфер на size байт (0 - DefaultTailBytes)
+func NewLogRing(size int) *LogRing {
+	if size <= 0 {
+		size = DefaultTailBytes
+	}
+
+	return &LogRing{data: make([]byte, size)}
+}
+
+// Write - дописывает p, вытесняя самые старые данные
+func (r *LogRing) Write(p []byte) (int, error) {
+	n := len(p)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if len(p) >= len(r.data) {
+		r.dropped += int64(r.size + len(p) - len(r.data))
+		p = p[len(p)-len(r.data):]
+		r.start, r.size = 0, 0
+	}
+
+	if over := r.size + len(p) - len(r.data); over > 0 {
+		r.dropped += int64(over)
+		r.start = (r.start + over) % len(r.data)
+		r.size -= over
+	}
+
+	end := (r.start + r.size) % len(r.data)
+	copied := copy(r.data[end:], p)
+	copy(r.data, p[copied:])
+	r.size += len(p)
+
+	return n, nil
+}
+
+// Bytes - содержимое буфера от старых данных к новым
+func (r *LogRing) Bytes() []byte {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.bytes()
+}
+
+// String - содержимое буфера строкой
+func (r *LogRing) String() string {
+	return string(r.Bytes())
+}
+
+// Dropped - число вытесненных байт
+func (r *LogRing) Dropped() int64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.dropped
+}
+
+// Written - число байт, записанных в буфер за все время
+func (r *LogRing) Written() int64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.dropped + int64(r.size)
+}
+
+// LastBytes - последние n байт вывода
+func (r *LogRing) LastBytes(n int) []byte {
+	data := r.Bytes()
+
+	if n >= 0 && n < len(data) {
+		data = data[len(data)-n:]
+	}
+
+	return data
+}
+
+// LastLines - последние n строк вывода без переводов строк; строка,
+// начало которой вытеснено, не возвращается
+func (r *LogRing) LastLines(n int) []string {
+	r.mu.Lock()
+	data, truncated := r.bytes(), r.dropped != 0
+	r.mu.Unlock()
+
+	if truncated {
+		if i := bytes.IndexByte(data, '\n'); i >= 0 {
+			data = data[i+1:]
+		} else {
+			data = nil
+		}
+	}
+
+	text := strings.TrimSuffix(string(data), "\n")
+	if text == "" || n <= 0 {
+		return nil
+	}
+
+	lines := strings.Split(text, "\n")
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+
+	return lines
+}
+
+// bytes - копия содержимого. Вызывается под r.mu
+func (r *LogRing) bytes() []byte {
+	result := make([]byte, r.size)
+	end := r.start + r.size
+
+	if end > len(r.data) {
+		end = len(r.data)
+	}
+
+	copied := copy(result, r.data[r.start:end])
+	copy(result[copied:], r.data)
+
+	return result
+}
+
+// LogTail - последние lines строк вывода контейнера (stdout и stderr вместе):
+// из буфера трансляции логов, если она открыта, иначе из логов среды
+// исполнения. Размер хвоста ограничен TailBytes
+func (c *BaseContainer) LogTail(lines int) []string {
+	c.mutex.Lock()
+	tail, id := c.tail, c.containerID
+	c.mutex.Unlock()
+
+	if tail != nil {
+		if result := tail.LastLines(lines); len(result) != 0 {
+			return result
+		}
+	}
+
+	if id == "" || c.client == nil || c.TailBytes < 0 {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), tailFetchTimeout)
+	defer cancel()
+
+	ring := NewLogRing(c.TailBytes)
+	out := c.redact(ring)
+
+	if err := c.client.StreamLogs(ctx, id, out, out, false); err != nil {
+		return nil
+	}
+
+	return ring.LastLines(lines)
+}
+
+// teeTail - дублирует поток трансляции логов в буфер хвоста, nil поток -
+// только в буфер. Вызывается под c.mutex
+func (c *BaseContainer) teeTail(w io.Writer) io.Writer {
+	if c.TailBytes < 0 {
+		return w
+	}
+
+	if c.tail == nil {
+		c.tail = NewLogRing(c.TailBytes)
+	}
+
+	if w == nil {
+		return c.tail
+	}
+
+	return io.MultiWriter(c.tail, w)
+}
@@ -0,0 +1,130 @@
+package containers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"gopkg.in/gomisc/errors.v1"
+)
+
+// ErrStartupCancelled - запуск контейнера прерван отменой контекста
+const ErrStartupCancelled = errors.Const("container startup cancelled")
+
+// rollbackTimeout - срок удаления частично созданных объектов; откат
+// выполняется с отдельным контекстом, так как контекст запуска уже отменен
+const rollbackTimeout = 30 * time.Second
+
+// rollbacker - участник окружения, умеющий откатить прерванный запуск
+type rollbacker interface {
+	rollback(cause error)
+}
+
+// undoStartup - откатывает частичный запуск контейнера при панике (панику
+// пробрасывает дальше) или ошибке *errp при отмененном ctx; nil ctx -
+// откат при любой ошибке. Вызывается только через defer
+func (c *BaseContainer) undoStartup(errp *error, ctx context.Context) {
+	if r := recover(); r != nil {
+		c.rollback(errors.Ctx().Str("panic", fmt.Sprint(r)).New("startup panic"))
+
+		panic(r)
+	}
+
+	if *errp != nil && (ctx == nil || expired(ctx)) {
+		c.rollback(*errp)
+	}
+}
+
+// expired - контекст отменен или срок его истек; таймер контекста может
+// сработать позже, чем запуск заметит нехватку времени
+func expired(ctx context.Context) bool {
+	if ctx.Err() != nil {
+		return true
+	}
+
+	deadline, ok := ctx.Deadline()
+
+	return ok && !time.Now().Before(deadline)
+}
+
+// rollback - удаляет частично созданный контейнер вместе с созданными для
+// него именованными томами и файлами секретов и часов хоста. Повторный
+// вызов, вызов до создания контейнера и вызов во время StartContainer
+// безопасны; Stop после отката ничего не делает
+func (c *BaseContainer) rollback(cause error) {
+	ctx, cancel := context.WithTimeout(context.Background(), rollbackTimeout)
+	defer cancel()
+
+	c.mutex.Lock()
+	id, volumes := c.containerID, c.createdVolumes
+	cancelLogs, cancelWait := c.cancelLogs, c.cancelWait
+	c.createdVolumes = nil
+	c.cancelLogs, c.cancelWait = nil, nil
+	c.mutex.Unlock()
+
+	if cancelWait != nil {
+		cancelWait()
+	}
+
+	if id != "" {
+		// Stop после отката не обращается к удаленному контейнеру
+		c.stopOnce.Do(func() {})
+
+		NetworkRegistry(c.network).Remove(id)
+
+		if err := c.runtime().ContainerRemove(ctx, id); err != nil {
+			c.LogError(
+				errors.And(err, cause),
+				"remove container %s (%s) of cancelled startup", c.GetName(), shortID(id),
+			)
+		}
+	}
+
+	if cancelLogs != nil {
+		cancelLogs()
+	}
+
+	for _, name := range volumes {
+		if err := c.runtime().VolumeRemove(ctx, name, false); err != nil && !errors.Is(err, ErrVolumeNotFound) {
+			c.LogError(err, "remove volume %s of cancelled startup", name)
+		}
+	}
+
+	c.removeSecrets()
+	c.removeClock()
+}
+
+// cancelled - ошибка прерванного отменой запуска
+func (c *BaseContainer) cancelled(cause error) error {
+	return errors.And(errors.Ctx().Str("container-name", c.GetName()).Just(ErrStartupCancelled), cause)
+}
+
+// missingVolumes - именованные тома контейнера, которых еще нет: их создаст
+// среда исполнения вместе с контейнером, а откат запуска удалит
+func (c *BaseContainer) missingVolumes(ctx context.Context) ([]string, error) {
+	if len(c.NamedVolumes) == 0 {
+		return nil, nil
+	}
+
+	existing, err := c.runtime().VolumeList(ctx, VolumeFilter{})
+	if err != nil {
+		return nil, errors.Ctx().Str("container-name", c.GetName()).Wrap(err, "list volumes")
+	}
+
+	known := make(map[string]struct{}, len(existing))
+
+	for _, v := range existing {
+		known[v.Name] = struct{}{}
+	}
+
+	var missing []string
+
+	for _, v := range c.NamedVolumes {
+		if _, ok := known[v.Name]; !ok {
+			missing = append(missing, v.Name)
+			known[v.Name] = struct{}{}
+		}
+	}
+
+	return missing, nil
+}
@@ -0,0 +1,222 @@
+package containers
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/gomisc/errors.v1"
+)
+
+// Переменные окружения настройки S3 загрузчика артефактов
+const (
+	// ArtifactsS3EndpointEnvar - адрес S3 совместимого хранилища (https://s3.amazonaws.com, http://minio:9000)
+	ArtifactsS3EndpointEnvar = "CONTAINERS_ARTIFACTS_S3_ENDPOINT"
+	// ArtifactsS3BucketEnvar - бакет для артефактов
+	ArtifactsS3BucketEnvar = "CONTAINERS_ARTIFACTS_S3_BUCKET"
+	// ArtifactsS3RegionEnvar - регион, по умолчанию us-east-1
+	ArtifactsS3RegionEnvar = "CONTAINERS_ARTIFACTS_S3_REGION"
+	// ArtifactsS3PrefixEnvar - префикс ключей артефактов
+	ArtifactsS3PrefixEnvar = "CONTAINERS_ARTIFACTS_S3_PREFIX"
+
+	defaultS3Region = "us-east-1"
+	s3Algorithm     = "AWS4-HMAC-SHA256"
+	s3AmzDate       = "20060102T150405Z"
+	s3ShortDate     = "20060102"
+)
+
+// ErrS3Upload - хранилище отклонило загрузку объекта
+const ErrS3Upload = errors.Const("s3 upload failed")
+
+// S3Uploader - загрузчик артефактов в S3 совместимое хранилище (AWS S3, MinIO)
+// с адресацией бакета в пути и подписью запросов AWS Signature V4
+type S3Uploader struct {
+	Endpoint     string `json:"endpoint" yaml:"endpoint"`
+	Bucket       string `json:"bucket" yaml:"bucket"`
+	Region       string `json:"region,omitempty" yaml:"region,omitempty"`
+	Prefix       string `json:"prefix,omitempty" yaml:"prefix,omitempty"`
+	AccessKey    string `json:"-" yaml:"-"`
+	SecretKey    string `json:"-" yaml:"-"`
+	SessionToken string `json:"-" yaml:"-"`
+	// Client - HTTP клиент запросов, по умолчанию http.DefaultClient
+	Client *http.Client `json:"-" yaml:"-"`
+}
+
+// S3UploaderFromEnv - загрузчик из переменных CONTAINERS_ARTIFACTS_S3_* и
+// стандартных AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY, AWS_SESSION_TOKEN;
+// без адреса или бакета возвращает nil
+func S3UploaderFromEnv() *S3Uploader {
+	endpoint, bucket := os.Getenv(ArtifactsS3EndpointEnvar), os.Getenv(ArtifactsS3BucketEnvar)
+	if endpoint == "" || bucket == "" {
+		return nil
+	}
+
+	return &S3Uploader{
+		Endpoint:     endpoint,
+		Bucket:       bucket,
+		Region:       os.Getenv(ArtifactsS3RegionEnvar),
+		Prefix:       os.Getenv(ArtifactsS3PrefixEnvar),
+		AccessKey:    os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretKey:    os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken: os.Getenv("AWS_SESSION_TOKEN"),
+	}
+}
+
+// Upload - загружает файл path хоста объектом Prefix/key
+func (u *S3Uploader) Upload(ctx context.Context, key, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return errors.Ctx().Str("path", path).Wrap(err, "open artifact")
+	}
+
+	defer func() {
+		_ = f.Close()
+	}()
+
+	hash := sha256.New()
+
+	size, err := io.Copy(hash, f)
+	if err != nil {
+		return errors.Ctx().Str("path", path).Wrap(err, "hash artifact")
+	}
+
+	if _, err = f.Seek(0, io.SeekStart); err != nil {
+		return errors.Ctx().Str("path", path).Wrap(err, "rewind artifact")
+	}
+
+	objectKey := strings.TrimPrefix(strings.TrimSuffix(u.Prefix, "/")+"/"+key, "/")
+
+	objectPath := u.objectPath(objectKey)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, strings.TrimSuffix(u.Endpoint, "/")+objectPath, f)
+	if err != nil {
+		return errors.Ctx().Str("key", objectKey).Wrap(err, "make upload request")
+	}
+
+	req.ContentLength = size
+	u.sign(req, objectPath, hex.EncodeToString(hash.Sum(nil)), time.Now().UTC())
+
+	client := u.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return errors.Ctx().Str("key", objectKey).Wrap(err, "upload artifact")
+	}
+
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	if resp.StatusCode/100 != 2 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+
+		return errors.Ctx().
+			Str("key", objectKey).
+			Int("status", resp.StatusCode).
+			Str("response", string(body)).
+			Just(ErrS3Upload)
+	}
+
+	return nil
+}
+
+// objectPath - экранированный путь объекта с бакетом
+func (u *S3Uploader) objectPath(key string) string {
+	segments := strings.Split(u.Bucket+"/"+key, "/")
+	for i := range segments {
+		segments[i] = s3Escape(segments[i])
+	}
+
+	return "/" + strings.Join(segments, "/")
+}
+
+// sign - подписывает запрос к объекту path AWS Signature V4
+func (u *S3Uploader) sign(req *http.Request, path, payloadHash string, now time.Time) {
+	region := u.Region
+	if region == "" {
+		region = defaultS3Region
+	}
+
+	amzDate := now.Format(s3AmzDate)
+	scope := now.Format(s3ShortDate) + "/" + region + "/s3/aws4_request"
+
+	req.Header.Set("x-amz-date", amzDate)
+	req.Header.Set("x-amz-content-sha256", payloadHash)
+
+	headers := []string{"host", "x-amz-content-sha256", "x-amz-date"}
+	values := map[string]string{
+		"host":                 req.URL.Host,
+		"x-amz-content-sha256": payloadHash,
+		"x-amz-date":           amzDate,
+	}
+
+	if u.SessionToken != "" {
+		req.Header.Set("x-amz-security-token", u.SessionToken)
+		headers = append(headers, "x-amz-security-token")
+		values["x-amz-security-token"] = u.SessionToken
+	}
+
+	var canonical strings.Builder
+
+	canonical.WriteString(req.Method + "\n" + path + "\n\n")
+
+	for _, h := range headers {
+		canonical.WriteString(h + ":" + values[h] + "\n")
+	}
+
+	signedHeaders := strings.Join(headers, ";")
+	canonical.WriteString("\n" + signedHeaders + "\n" + payloadHash)
+
+	canonicalHash := sha256.Sum256([]byte(canonical.String()))
+	stringToSign := s3Algorithm + "\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(canonicalHash[:])
+
+	key := hmacSHA256([]byte("AWS4"+u.SecretKey), now.Format(s3ShortDate))
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+
+	req.Header.Set(
+		"Authorization",
+		s3Algorithm+" Credential="+u.AccessKey+"/"+scope+
+			", SignedHeaders="+signedHeaders+
+			", Signature="+hex.EncodeToString(hmacSHA256(key, stringToSign)),
+	)
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	_, _ = mac.Write([]byte(data))
+
+	return mac.Sum(nil)
+}
+
+// s3Escape - кодирование сегмента пути по правилам S3: без изменений
+// остаются только незарезервированные символы
+func s3Escape(segment string) string {
+	var b strings.Builder
+
+	for i := 0; i < len(segment); i++ {
+		ch := segment[i]
+
+		if ch >= 'A' && ch <= 'Z' || ch >= 'a' && ch <= 'z' || ch >= '0' && ch <= '9' ||
+			ch == '-' || ch == '_' || ch == '.' || ch == '~' {
+			b.WriteByte(ch)
+
+			continue
+		}
+
+		b.WriteString("%" + strings.ToUpper(strconv.FormatUint(uint64(ch)|0x100, 16)[1:]))
+	}
+
+	return b.String()
+}
@@ -0,0 +1,171 @@
+package containers
+
+import (
+	"context"
+
+	"gopkg.in/gomisc/errors.v1"
+	"gopkg.in/gomisc/errors.v1/errgroup"
+)
+
+const (
+	// DefaultScaleConcurrency - число одновременно создаваемых реплик
+	DefaultScaleConcurrency = 8
+
+	ErrInvalidReplicas = errors.Const("invalid replicas count")
+)
+
+// ReplicaFactory - создает описание i-й реплики (имя должно быть уникальным)
+type ReplicaFactory func(i int) (Container, error)
+
+// Scale - создает и запускает n однотипных реплик: образ проверяется один
+// раз, контейнеры создаются пачками и запускаются параллельно с общим
+// дедлайном готовности из ctx. Имена реплик проверяются до создания контейнеров,
+// при ошибке запущенные реплики останавливаются и не остаются в окружении
+func (o *Orchestrator) Scale(ctx context.Context, template ReplicaFactory, n int, opts ...MemberOption) ([]Container, error) {
+	if n < 0 {
+		return nil, errors.Ctx().Int("replicas", n).Just(ErrInvalidReplicas)
+	}
+
+	replicas := make([]Container, n)
+
+	for i := 0; i < n; i++ {
+		c, err := template(i)
+		if err != nil {
+			return nil, errors.Ctx().Int("replica", i).Wrap(err, "make replica")
+		}
+
+		replicas[i] = c
+	}
+
+	if n == 0 {
+		return replicas, nil
+	}
+
+	members := make([]*Member, n)
+
+	for i, c := range replicas {
+		members[i] = &Member{Container: c}
+
+		for _, apply := range opts {
+			apply(members[i])
+		}
+	}
+
+	if err := o.checkNames(members); err != nil {
+		return nil, err
+	}
+
+	if err := o.puller.Wait(ctx, replicas[0].GetImage()); err != nil {
+		return nil, errors.Wrap(err, "prepare replicas image")
+	}
+
+	create := errgroup.WithCancelOnErr(ctx).WithMaxConcurrency(DefaultScaleConcurrency)
+
+	for _, c := range replicas {
+		c := c
+
+		create.Go(
+			func() error {
+				if err := c.CreateContainer(); err != nil {
+					return errors.Ctx().Str("name", c.GetName()).Wrap(err, "create replica")
+				}
+
+				return nil
+			},
+		)
+	}
+
+	if err := create.Wait(); err != nil {
+		return nil, errors.And(err, stopAll(replicas))
+	}
+
+	start := errgroup.New()
+
+	for _, m := range members {
+		m := m
+
+		start.Go(
+			func() error {
+				if err := m.start(ctx); err != nil {
+					return errors.Ctx().Str("name", m.Container.GetName()).Wrap(err, "start replica")
+				}
+
+				return nil
+			},
+		)
+	}
+
+	if err := start.Wait(); err != nil {
+		return nil, errors.And(err, stopAll(replicas))
+	}
+
+	for _, m := range members {
+		m.available = true
+	}
+
+	// имена могли занять параллельным Add, пока реплики запускались
+	if err := o.addAll(members); err != nil {
+		return nil, errors.And(err, stopAll(replicas))
+	}
+
+	return replicas, nil
+}
+
+// checkNames - проверяет, что имена участников уникальны и не заняты в окружении
+func (o *Orchestrator) checkNames(members []*Member) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	return o.checkNamesLocked(members)
+}
+
+// addAll - добавляет участников в окружение все сразу: при конфликте имен не
+// добавляется ни один
+func (o *Orchestrator) addAll(members []*Member) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if err := o.checkNamesLocked(members); err != nil {
+		return err
+	}
+
+	for _, m := range members {
+		m.owner = o
+		o.members = append(o.members, m)
+		o.index[m.Container.GetName()] = m
+	}
+
+	return nil
+}
+
+func (o *Orchestrator) checkNamesLocked(members []*Member) error {
+	seen := make(map[string]struct{}, len(members))
+
+	for _, m := range members {
+		name := m.Container.GetName()
+
+		if _, ok := o.index[name]; ok {
+			return errors.Ctx().Str("name", name).Just(ErrMemberAlreadyExist)
+		}
+
+		if _, ok := seen[name]; ok {
+			return errors.Ctx().Str("name", name).Just(ErrMemberAlreadyExist)
+		}
+
+		seen[name] = struct{}{}
+	}
+
+	return nil
+}
+
+func stopAll(cs []Container) error {
+	var err error
+
+	for _, c := range cs {
+		if stopErr := c.Stop(); stopErr != nil {
+			err = errors.And(err, stopErr)
+		}
+	}
+
+	return err
+}
@@ -0,0 +1,95 @@
+package containers_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"gopkg.in/gomisc/errors.v1"
+
+	"gopkg.in/gomisc/containers.v1"
+	"gopkg.in/gomisc/containers.v1/adapters/fake"
+	"gopkg.in/gomisc/containers.v1/wait"
+)
+
+func newReplicaFactory(t *testing.T, names ...string) (*fake.Client, containers.ReplicaFactory) {
+	t.Helper()
+
+	cli, err := fake.New(fake.WithImages(testImage))
+	if err != nil {
+		t.Fatalf("fake.New: %v", err)
+	}
+
+	nw, err := cli.CheckNetwork("scale-net", "")
+	if err != nil {
+		t.Fatalf("CheckNetwork: %v", err)
+	}
+
+	return cli, func(i int) (containers.Container, error) {
+		c := containers.NewBaseContainer(cli, nw, nil)
+		c.Name = names[i]
+		c.Image = testImage
+		c.Background = true
+		c.Readiness = wait.Immediately()
+
+		return c, nil
+	}
+}
+
+func TestScaleDuplicateNames(t *testing.T) {
+	cli, factory := newReplicaFactory(t, "replica-0", "replica-1", "replica-0")
+	o := containers.NewOrchestrator(cli)
+
+	if _, err := o.Scale(context.Background(), factory, 3); !errors.Is(err, containers.ErrMemberAlreadyExist) {
+		t.Fatalf("Scale: got %v, want %v", err, containers.ErrMemberAlreadyExist)
+	}
+
+	if members := o.Members(); len(members) != 0 {
+		t.Fatalf("members left after failed Scale: %d", len(members))
+	}
+
+	list, err := cli.ContainerList(context.Background(), containers.ListFilter{})
+	if err != nil {
+		t.Fatalf("ContainerList: %v", err)
+	}
+
+	if len(list) != 0 {
+		t.Fatalf("containers created before name validation: %d", len(list))
+	}
+}
+
+func TestScaleNegative(t *testing.T) {
+	cli, factory := newReplicaFactory(t)
+	o := containers.NewOrchestrator(cli)
+
+	if _, err := o.Scale(context.Background(), factory, -1); !errors.Is(err, containers.ErrInvalidReplicas) {
+		t.Fatalf("Scale: got %v, want %v", err, containers.ErrInvalidReplicas)
+	}
+}
+
+func TestScale(t *testing.T) {
+	names := make([]string, 3)
+	for i := range names {
+		names[i] = fmt.Sprintf("replica-%d", i)
+	}
+
+	cli, factory := newReplicaFactory(t, names...)
+	o := containers.NewOrchestrator(cli)
+
+	replicas, err := o.Scale(context.Background(), factory, len(names))
+	if err != nil {
+		t.Fatalf("Scale: %v", err)
+	}
+
+	t.Cleanup(func() { _ = o.Down() })
+
+	if len(replicas) != len(names) || len(o.Members()) != len(names) {
+		t.Fatalf("replicas: got %d, members %d, want %d", len(replicas), len(o.Members()), len(names))
+	}
+
+	for _, name := range names {
+		if !o.Available(name) {
+			t.Fatalf("replica %s is not available", name)
+		}
+	}
+}
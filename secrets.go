@@ -0,0 +1,251 @@
+package containers
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"gopkg.in/gomisc/errors.v1"
+)
+
+// SecretsDir - каталог секретов внутри контейнера
+const SecretsDir = "/run/secrets"
+
+// Redacted - замена значений секретов в логах и сообщениях
+const Redacted = "[REDACTED]"
+
+// shmDir - tmpfs хоста, в который пишутся файлы секретов
+const shmDir = "/dev/shm"
+
+// knownSecrets - значения секретов контейнеров процесса, см. rememberSecretValues
+var knownSecrets sync.Map
+
+var (
+	// ErrSecretSource - у секрета не задан или задан не один источник значения
+	ErrSecretSource = errors.Const("secret must have exactly one value source")
+	// ErrSecretEnvNotSet - переменная окружения источника секрета не задана
+	ErrSecretEnvNotSet = errors.Const("secret environment variable not set")
+	// ErrInvalidSecretName - имя секрета пусто или выходит за каталог секретов
+	ErrInvalidSecretName = errors.Const("invalid secret name")
+	// ErrSecretsTmpfsUnavailable - на хосте нет tmpfs для файлов секретов;
+	// секреты не пишутся на диск
+	ErrSecretsTmpfsUnavailable = errors.Const("tmpfs for secrets is not available")
+)
+
+// Secret - секрет, передаваемый контейнеру файлом SecretsDir/<Name> на tmpfs
+// вместо переменной окружения, видимой через docker inspect. Значение задается
+// одним из полей Value, FromEnv (переменная окружения тестового процесса)
+// или FromFile (файл хоста) и в сериализованную спецификацию не попадает
+type Secret struct {
+	Name     string `json:"name" yaml:"name"`
+	Value    string `json:"-" yaml:"-"`
+	FromEnv  string `json:"from_env,omitempty" yaml:"from_env,omitempty"`
+	FromFile string `json:"from_file,omitempty" yaml:"from_file,omitempty"`
+}
+
+// Path - путь к файлу секрета внутри контейнера
+func (s Secret) Path() string {
+	return path.Join(SecretsDir, s.Name)
+}
+
+// validateName - имя секрета - относительный путь внутри SecretsDir
+func (s Secret) validateName() error {
+	name := path.Clean(s.Name)
+
+	if s.Name == "" || name != s.Name || path.IsAbs(name) || name == ".." || strings.HasPrefix(name, "../") {
+		return errors.Ctx().Str("secret", s.Name).Just(ErrInvalidSecretName)
+	}
+
+	return nil
+}
+
+// resolve - возвращает значение секрета из его источника
+func (s Secret) resolve() ([]byte, error) {
+	var sources int
+
+	for _, src := range []string{s.Value, s.FromEnv, s.FromFile} {
+		if src != "" {
+			sources++
+		}
+	}
+
+	if sources != 1 {
+		return nil, errors.Ctx().Str("secret", s.Name).Just(ErrSecretSource)
+	}
+
+	switch {
+	case s.FromEnv != "":
+		value, ok := os.LookupEnv(s.FromEnv)
+		if !ok {
+			return nil, errors.Ctx().Str("secret", s.Name).Str("env", s.FromEnv).Just(ErrSecretEnvNotSet)
+		}
+
+		return []byte(value), nil
+	case s.FromFile != "":
+		data, err := os.ReadFile(s.FromFile)
+		if err != nil {
+			return nil, errors.Ctx().Str("secret", s.Name).Str("file", s.FromFile).Wrap(err, "read secret file")
+		}
+
+		return data, nil
+	default:
+		return []byte(s.Value), nil
+	}
+}
+
+// mountSecrets - пишет секреты контейнера в каталог на tmpfs хоста и
+// подключает его только для чтения в SecretsDir. Каталог и файлы доступны
+// только владельцу: другие пользователи хоста секреты не читают, а процесс
+// контейнера читает их от root
+func (c *BaseContainer) mountSecrets() error {
+	if len(c.Secrets) == 0 || c.secretsDir != "" {
+		return nil
+	}
+
+	for _, s := range c.Secrets {
+		if err := s.validateName(); err != nil {
+			return err
+		}
+	}
+
+	if info, err := os.Stat(shmDir); err != nil || !info.IsDir() {
+		return errors.Ctx().Str("container-name", c.GetName()).Str("dir", shmDir).Just(ErrSecretsTmpfsUnavailable)
+	}
+
+	// MkdirTemp создает каталог с правами 0o700
+	dir, err := os.MkdirTemp(shmDir, "containers-secrets-")
+	if err != nil {
+		return errors.Ctx().Str("container-name", c.GetName()).Wrap(err, "create secrets dir")
+	}
+
+	values := make([]string, 0, len(c.Secrets))
+
+	for _, s := range c.Secrets {
+		value, resolveErr := s.resolve()
+		if resolveErr != nil {
+			_ = os.RemoveAll(dir)
+
+			return resolveErr
+		}
+
+		file := filepath.Join(dir, filepath.FromSlash(s.Name))
+
+		if err = os.MkdirAll(filepath.Dir(file), 0o700); err == nil {
+			err = os.WriteFile(file, value, 0o400)
+		}
+
+		if err != nil {
+			_ = os.RemoveAll(dir)
+
+			return errors.Ctx().Str("secret", s.Name).Wrap(err, "write secret file")
+		}
+
+		if v := strings.TrimSpace(string(value)); v != "" {
+			values = append(values, v)
+		}
+	}
+
+	c.mutex.Lock()
+	c.secretsDir = dir
+	c.secretValues = values
+	c.mutex.Unlock()
+
+	rememberSecretValues(values)
+
+	c.Mounts = append(c.Mounts, dir+":"+SecretsDir+":ro")
+
+	return nil
+}
+
+// removeSecrets - удаляет файлы секретов с хоста
+func (c *BaseContainer) removeSecrets() {
+	c.mutex.Lock()
+	dir := c.secretsDir
+	c.secretsDir = ""
+	c.mutex.Unlock()
+
+	if dir == "" {
+		return
+	}
+
+	c.Mounts = removeString(c.Mounts, dir+":"+SecretsDir+":ro")
+
+	if err := os.RemoveAll(dir); err != nil {
+		c.LogError(err, "remove secrets dir")
+	}
+}
+
+// redact - оборачивает поток заменой значений секретов контейнера
+func (c *BaseContainer) redact(w io.Writer) io.Writer {
+	c.mutex.Lock()
+	values := c.secretValues
+	c.mutex.Unlock()
+
+	if w == nil || len(values) == 0 {
+		return w
+	}
+
+	return &redactWriter{w: w, values: values}
+}
+
+// rememberSecretValues - запоминает значения секретов для замены в
+// загружаемых артефактах: файлы каталога артефактов теста не привязаны к
+// контейнеру, поэтому заменяются значения всех контейнеров процесса
+func rememberSecretValues(values []string) {
+	for _, v := range values {
+		knownSecrets.Store(v, struct{}{})
+	}
+}
+
+// knownSecretValues - значения секретов всех контейнеров процесса
+func knownSecretValues() []string {
+	var values []string
+
+	knownSecrets.Range(
+		func(key, _ any) bool {
+			values = append(values, key.(string))
+
+			return true
+		},
+	)
+
+	return values
+}
+
+// redactWriter - поток, заменяющий значения секретов на Redacted; значение,
+// разорванное между двумя вызовами Write, не распознается, поэтому поток
+// рассчитан на построчную запись, как у логов демона
+type redactWriter struct {
+	w      io.Writer
+	values []string
+}
+
+func (r *redactWriter) Write(p []byte) (int, error) {
+	out := p
+
+	for _, v := range r.values {
+		out = bytes.ReplaceAll(out, []byte(v), []byte(Redacted))
+	}
+
+	if _, err := r.w.Write(out); err != nil {
+		return 0, err
+	}
+
+	return len(p), nil
+}
+
+func removeString(list []string, s string) []string {
+	result := list[:0]
+
+	for _, item := range list {
+		if item != s {
+			result = append(result, item)
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,73 @@
+package containers_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gopkg.in/gomisc/containers.v1"
+)
+
+func TestSecretsFiles(t *testing.T) {
+	if _, err := os.Stat("/dev/shm"); err != nil {
+		t.Skip("no /dev/shm on host")
+	}
+
+	cli, c := newTestContainer(t, "secrets-files")
+	c.Secrets = []containers.Secret{{Name: "db/password", Value: "s3cret"}}
+
+	if err := c.Run(context.Background()); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	t.Cleanup(func() { _ = c.Stop() })
+
+	state, err := cli.ContainerInspect(context.Background(), c.GetID())
+	if err != nil {
+		t.Fatalf("ContainerInspect: %v", err)
+	}
+
+	var dir string
+
+	for _, m := range state.Mounts {
+		if m.Destination == containers.SecretsDir {
+			dir = m.Source
+		}
+	}
+
+	if dir == "" {
+		t.Fatalf("secrets dir is not mounted: %+v", state.Mounts)
+	}
+
+	if info, statErr := os.Stat(dir); statErr != nil || info.Mode().Perm() != 0o700 {
+		t.Errorf("secrets dir %s: %v, mode %v", dir, statErr, info.Mode())
+	}
+
+	file := filepath.Join(dir, "db", "password")
+
+	info, err := os.Stat(file)
+	if err != nil {
+		t.Fatalf("secret file at Path %s: %v", c.Secrets[0].Path(), err)
+	}
+
+	if info.Mode().Perm() != 0o400 {
+		t.Errorf("secret file mode %v, want 0400", info.Mode().Perm())
+	}
+
+	if want := containers.SecretsDir + "/db/password"; c.Secrets[0].Path() != want {
+		t.Errorf("Path: %s, want %s", c.Secrets[0].Path(), want)
+	}
+}
+
+func TestSecretsInvalidName(t *testing.T) {
+	for _, name := range []string{"", "../escape", "/abs", "a/../../b"} {
+		_, c := newTestContainer(t, "secrets-invalid")
+		c.Secrets = []containers.Secret{{Name: name, Value: "x"}}
+
+		if err := c.Run(context.Background()); !errors.Is(err, containers.ErrInvalidSecretName) {
+			t.Errorf("Run with secret %q: %v, want ErrInvalidSecretName", name, err)
+		}
+	}
+}
@@ -0,0 +1,180 @@
+package containers_test
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"gopkg.in/gomisc/network.v1/ports"
+	"gopkg.in/yaml.v3"
+
+	"gopkg.in/gomisc/containers.v1"
+	"gopkg.in/gomisc/containers.v1/wait"
+)
+
+// codec - пара функций (де)сериализации одного формата
+type codec struct {
+	marshal   func(v any) ([]byte, error)
+	unmarshal func(data []byte, v any) error
+}
+
+var codecs = map[string]codec{
+	"json": {marshal: json.Marshal, unmarshal: json.Unmarshal},
+	"yaml": {marshal: yaml.Marshal, unmarshal: yaml.Unmarshal},
+}
+
+func TestSpecRoundTrip(t *testing.T) {
+	values := map[string]func() any{
+		"PortBind": func() any {
+			return &containers.PortBind{Name: "http", Container: containers.NewPort(8080, "tcp"), Host: 18080}
+		},
+		"PortBinding": func() any {
+			return &containers.PortBinding{HostIP: "127.0.0.1", HostPort: "18080"}
+		},
+		"MountSpec": func() any {
+			return &containers.MountSpec{
+				Type:          containers.MountVolume,
+				Source:        "data",
+				Target:        "/var/lib/data",
+				ReadOnly:      true,
+				VolumeDriver:  "local",
+				VolumeOptions: map[string]string{"type": "tmpfs"},
+			}
+		},
+		"PhaseBudgets": func() any {
+			return &containers.PhaseBudgets{Pull: time.Minute, Readiness: 30 * time.Second}
+		},
+		"Backoff": func() any {
+			return &wait.Backoff{Initial: 100 * time.Millisecond, Max: 5 * time.Second, Factor: 2, Jitter: 0.1}
+		},
+		"Healthcheck": func() any {
+			return &containers.Healthcheck{
+				Test:     []string{containers.HealthcheckCmd, "pg_isready"},
+				Interval: time.Second,
+				Timeout:  3 * time.Second,
+			}
+		},
+		"OrchestratorInfo": func() any {
+			return &containers.OrchestratorInfo{
+				ID:                "abc",
+				TypeID:            2,
+				ContainerEnpoints: containers.AddrsMap{"http": "10.0.0.2:8080"},
+				HostEnpoints:      containers.AddrsMap{"http": "127.0.0.1:18080"},
+				Name:              "api",
+				Labels:            map[string]string{"app": "api"},
+				State:             containers.StateRunning,
+			}
+		},
+	}
+
+	for name, value := range values {
+		for format, c := range codecs {
+			t.Run(
+				name+"/"+format, func(t *testing.T) {
+					want := value()
+
+					data, err := c.marshal(want)
+					if err != nil {
+						t.Fatalf("marshal: %v", err)
+					}
+
+					got := reflect.New(reflect.TypeOf(want).Elem()).Interface()
+
+					if err = c.unmarshal(data, got); err != nil {
+						t.Fatalf("unmarshal: %v", err)
+					}
+
+					if !reflect.DeepEqual(got, want) {
+						t.Fatalf("round trip mismatch:\n got %+v\nwant %+v\ndata %s", got, want, data)
+					}
+				},
+			)
+		}
+	}
+}
+
+func TestBaseContainerRoundTrip(t *testing.T) {
+	spec := &containers.BaseContainer{
+		Name:       "db",
+		Image:      "postgres:15",
+		Cmd:        []string{"postgres", "-c", "fsync=off"},
+		Envs:       []string{"POSTGRES_PASSWORD=secret"},
+		Aliases:    []string{"postgres"},
+		Sysctls:    map[string]string{"net.ipv4.ip_forward": "1"},
+		DebugPort:  ports.DefaultDebug,
+		Ports:      containers.PortBinds{{Name: "pg", Container: containers.NewPort(5432, "tcp")}},
+		Tmpfs:      map[string]string{"/var/lib/postgresql/data": "size=512m"},
+		MountSpecs: []containers.MountSpec{{Type: containers.MountTmpfs, Target: "/tmp", TmpfsSize: 1 << 20}},
+		Resources:  containers.Resources{MemoryLimit: 256 << 20, CPUs: 0.5},
+		Labels:     map[string]string{"team": "storage"},
+		Budgets:    containers.PhaseBudgets{Readiness: time.Minute},
+
+		StartTimeout: 30 * time.Second,
+		Background:   true,
+	}
+
+	for format, c := range codecs {
+		t.Run(
+			format, func(t *testing.T) {
+				data, err := c.marshal(spec)
+				if err != nil {
+					t.Fatalf("marshal: %v", err)
+				}
+
+				var got containers.BaseContainer
+
+				if err = c.unmarshal(data, &got); err != nil {
+					t.Fatalf("unmarshal: %v", err)
+				}
+
+				again, err := c.marshal(&got)
+				if err != nil {
+					t.Fatalf("marshal decoded: %v", err)
+				}
+
+				if string(again) != string(data) {
+					t.Fatalf("round trip mismatch:\n got %s\nwant %s", again, data)
+				}
+
+				if got.Name != spec.Name || got.StartTimeout != spec.StartTimeout ||
+					!reflect.DeepEqual(got.Ports, spec.Ports) || !reflect.DeepEqual(got.MountSpecs, spec.MountSpecs) {
+					t.Fatalf("decoded spec differs: %s", again)
+				}
+			},
+		)
+	}
+}
+
+func TestOrchestratorInfoLegacyKeys(t *testing.T) {
+	var info containers.OrchestratorInfo
+
+	data := []byte(`{"id":"abc","container_enpoints":{"http":"10.0.0.2:8080"},"host_enpoints":{"http":"127.0.0.1:18080"}}`)
+
+	if err := json.Unmarshal(data, &info); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+
+	if info.ContainerEnpoints["http"] != "10.0.0.2:8080" || info.HostEnpoints["http"] != "127.0.0.1:18080" {
+		t.Fatalf("legacy endpoints are not decoded: %+v", info)
+	}
+
+	out, err := json.Marshal(&info)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+
+	var keys map[string]json.RawMessage
+
+	if err = json.Unmarshal(out, &keys); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+
+	if _, ok := keys["container_endpoints"]; !ok {
+		t.Fatalf("container_endpoints key is missing: %s", out)
+	}
+
+	if _, ok := keys["container_enpoints"]; ok {
+		t.Fatalf("legacy key is written: %s", out)
+	}
+}
@@ -0,0 +1,290 @@
+package containers
+
+import (
+	"context"
+	"io"
+	"os"
+	"path"
+	"strings"
+
+	"gopkg.in/gomisc/errors.v1"
+)
+
+// SnapshotRepository - репозиторий образов снапшотов окружения
+const SnapshotRepository = "containers-snapshot"
+
+type (
+	// Snapshot - контрольная точка окружения: образы, закоммиченные из
+	// контейнеров, хранящих состояние, и архивы их томов (анонимных и
+	// именованных), которые в образ не попадают. Тома, подключенные только
+	// для чтения, состоянием участника не считаются и не сохраняются
+	Snapshot struct {
+		Name   string
+		Images map[string]string
+		// Dir - каталог хоста с архивами томов, удаляется RemoveSnapshot
+		Dir string
+		// Volumes - архивы томов по имени контейнера
+		Volumes map[string][]VolumeArchive
+	}
+
+	// VolumeArchive - tar-архив содержимого тома участника
+	VolumeArchive struct {
+		// Volume - имя именованного тома (пусто - анонимный том); при
+		// восстановлении именованный том пересоздается пустым
+		Volume string
+		// Target - путь тома в контейнере
+		Target string
+		// File - путь архива на хосте
+		File string
+	}
+
+	recreator interface {
+		Recreate(image string) error
+	}
+
+	// volumeRecreator - пересоздание с подготовкой томов между удалением
+	// старого контейнера и созданием нового
+	volumeRecreator interface {
+		recreate(image string, prepare func(ctx context.Context) error) error
+	}
+)
+
+// Snapshot - фиксирует состояние контейнеров, хранящих состояние, в образы
+// и архивы томов
+func (o *Orchestrator) Snapshot(ctx context.Context, name string) (snap *Snapshot, err error) {
+	snap = &Snapshot{
+		Name:    name,
+		Images:  make(map[string]string),
+		Volumes: make(map[string][]VolumeArchive),
+	}
+
+	defer func() {
+		if err != nil && snap.Dir != "" {
+			_ = os.RemoveAll(snap.Dir)
+		}
+	}()
+
+	for _, m := range o.Members() {
+		if !m.Stateful || m.Container.GetID() == "" {
+			continue
+		}
+
+		contName := m.Container.GetName()
+		tag := SnapshotRepository + "/" + strings.ToLower(contName) + ":" + name
+
+		if _, err = ExtendClient(o.cli).ContainerCommit(ctx, m.Container.GetID(), tag); err != nil {
+			return nil, errors.Ctx().
+				Str("name", contName).
+				Str("snapshot", name).
+				Wrap(err, "commit member state")
+		}
+
+		snap.Images[contName] = tag
+
+		if err = o.snapshotVolumes(ctx, snap, m.Container); err != nil {
+			return nil, errors.Ctx().
+				Str("name", contName).
+				Str("snapshot", name).
+				Wrap(err, "save member volumes")
+		}
+	}
+
+	return snap, nil
+}
+
+// snapshotVolumes - сохраняет в архивы снапшота тома контейнера, доступные
+// для записи
+func (o *Orchestrator) snapshotVolumes(ctx context.Context, snap *Snapshot, c Container) error {
+	result, err := ExtendClient(o.cli).ContainerInspect(ctx, c.GetID())
+	if err != nil {
+		return err
+	}
+
+	named := namedVolumes(c)
+
+	for _, mnt := range result.Mounts {
+		if mnt.Type != MountVolume || mnt.ReadOnly {
+			continue
+		}
+
+		if snap.Dir == "" {
+			if snap.Dir, err = os.MkdirTemp("", SnapshotRepository+"-"); err != nil {
+				return errors.Wrap(err, "create snapshot directory")
+			}
+		}
+
+		archive := VolumeArchive{Target: mnt.Destination}
+
+		if _, ok := named[mnt.Source]; ok {
+			archive.Volume = mnt.Source
+		}
+
+		if archive.File, err = saveVolume(ctx, o.cli, c.GetID(), snap.Dir, mnt.Destination); err != nil {
+			return err
+		}
+
+		snap.Volumes[c.GetName()] = append(snap.Volumes[c.GetName()], archive)
+	}
+
+	return nil
+}
+
+// namedVolumes - имена именованных томов контейнера: NamedVolumes и
+// разделы MountVolume с заданным источником
+func namedVolumes(c Container) map[string]struct{} {
+	data := ExtendContainer(c)
+	named := make(map[string]struct{})
+
+	for _, v := range data.GetNamedVolumes() {
+		named[v.Name] = struct{}{}
+	}
+
+	for _, m := range data.GetMountSpecs() {
+		if m.MountType() == MountVolume && m.Source != "" {
+			named[m.Source] = struct{}{}
+		}
+	}
+
+	return named
+}
+
+// saveVolume - сохраняет содержимое пути target контейнера в архив каталога dir
+func saveVolume(ctx context.Context, cli Client, id, dir, target string) (string, error) {
+	content, err := ExtendClient(cli).CopyFromContainer(ctx, id, target)
+	if err != nil {
+		return "", errors.Ctx().Str("target", target).Wrap(err, "copy volume from container")
+	}
+
+	defer content.Close()
+
+	f, err := os.CreateTemp(dir, "volume-*.tar")
+	if err != nil {
+		return "", errors.Wrap(err, "create volume archive")
+	}
+
+	if _, err = io.Copy(f, content); err != nil {
+		return "", errors.And(
+			errors.Ctx().Str("target", target).Wrap(err, "write volume archive"),
+			f.Close(),
+		)
+	}
+
+	if err = f.Close(); err != nil {
+		return "", errors.Ctx().Str("file", f.Name()).Wrap(err, "close volume archive")
+	}
+
+	return f.Name(), nil
+}
+
+// Restore - пересоздает контейнеры снапшота из зафиксированных образов,
+// возвращает содержимое их томов и дожидается готовности
+func (o *Orchestrator) Restore(ctx context.Context, snap *Snapshot) error {
+	for _, m := range o.Members() {
+		image, ok := snap.Images[m.Container.GetName()]
+		if !ok {
+			continue
+		}
+
+		if err := o.recreate(m.Container, image, snap.Volumes[m.Container.GetName()]); err != nil {
+			return errors.Ctx().
+				Str("name", m.Container.GetName()).
+				Str("snapshot", snap.Name).
+				Wrap(err, "recreate member")
+		}
+
+		if err := restoreVolumes(ctx, o.cli, m.Container.GetID(), snap.Volumes[m.Container.GetName()]); err != nil {
+			return errors.Ctx().
+				Str("name", m.Container.GetName()).
+				Str("snapshot", snap.Name).
+				Wrap(err, "restore member volumes")
+		}
+
+		if err := m.start(ctx); err != nil {
+			return errors.Ctx().
+				Str("name", m.Container.GetName()).
+				Str("snapshot", snap.Name).
+				Wrap(err, "start restored member")
+		}
+
+		// восстановленный участник запущен, даже если не входил в профили подъема
+		o.mu.Lock()
+		m.available, m.skipped = true, false
+		o.mu.Unlock()
+	}
+
+	return nil
+}
+
+// recreate - пересоздает контейнер из образа; именованные тома из архивов
+// удаляются после удаления старого контейнера, чтобы новый получил их пустыми
+func (o *Orchestrator) recreate(c Container, image string, archives []VolumeArchive) error {
+	var volumes []string
+
+	for _, a := range archives {
+		if a.Volume != "" {
+			volumes = append(volumes, a.Volume)
+		}
+	}
+
+	if len(volumes) == 0 {
+		if rc, ok := c.(recreator); ok {
+			return rc.Recreate(image)
+		}
+	}
+
+	rc, ok := c.(volumeRecreator)
+	if !ok {
+		return errors.New("member does not support recreation")
+	}
+
+	return rc.recreate(
+		image, func(ctx context.Context) error {
+			for _, name := range volumes {
+				err := ExtendClient(o.cli).VolumeRemove(ctx, name, false)
+				if err != nil && !errors.Is(err, ErrVolumeNotFound) {
+					return errors.Ctx().Str("volume", name).Wrap(err, "remove volume before restore")
+				}
+			}
+
+			return nil
+		},
+	)
+}
+
+// restoreVolumes - распаковывает архивы томов в созданный, но еще не
+// запущенный контейнер
+func restoreVolumes(ctx context.Context, cli Client, id string, archives []VolumeArchive) error {
+	for _, a := range archives {
+		if err := restoreVolume(ctx, cli, id, a); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func restoreVolume(ctx context.Context, cli Client, id string, archive VolumeArchive) error {
+	f, err := os.Open(archive.File)
+	if err != nil {
+		return errors.Ctx().Str("file", archive.File).Wrap(err, "open volume archive")
+	}
+
+	defer f.Close()
+
+	if err = ExtendClient(cli).CopyToContainer(ctx, id, path.Dir(archive.Target), f); err != nil {
+		return errors.Ctx().Str("target", archive.Target).Wrap(err, "copy volume to container")
+	}
+
+	return nil
+}
+
+// RemoveSnapshot - удаляет образы и архивы томов снапшота
+func (o *Orchestrator) RemoveSnapshot(snap *Snapshot) {
+	for _, image := range snap.Images {
+		o.cli.RemoveImage(image)
+	}
+
+	if snap.Dir != "" {
+		_ = os.RemoveAll(snap.Dir)
+	}
+}
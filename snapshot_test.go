@@ -0,0 +1,93 @@
+package containers_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gopkg.in/gomisc/containers.v1"
+	"gopkg.in/gomisc/containers.v1/wait"
+)
+
+func TestRestoreVolumes(t *testing.T) {
+	cli, c := newTestContainer(t, "snapshot-volumes")
+	c.Readiness = wait.Immediately()
+	c.NamedVolumes = []containers.VolumeSpec{{Name: "snapshot-data", Target: "/var/lib/data"}}
+
+	o := containers.NewOrchestrator(cli)
+
+	if err := o.Add(c, containers.WithStateful()); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+
+	ctx := context.Background()
+
+	if err := o.Up(ctx); err != nil {
+		t.Fatalf("Up: %v", err)
+	}
+
+	t.Cleanup(func() { _ = o.Down() })
+
+	src := t.TempDir()
+	writeData := func(name, content string) {
+		t.Helper()
+
+		if err := os.MkdirAll(filepath.Join(src, "data"), 0o755); err != nil {
+			t.Fatalf("MkdirAll: %v", err)
+		}
+
+		if err := os.WriteFile(filepath.Join(src, "data", name), []byte(content), 0o644); err != nil {
+			t.Fatalf("WriteFile: %v", err)
+		}
+
+		if err := containers.CopyToContainer(ctx, c, filepath.Join(src, "data"), "/var/lib"); err != nil {
+			t.Fatalf("CopyToContainer: %v", err)
+		}
+	}
+
+	writeData("db", "v1")
+
+	snap, err := o.Snapshot(ctx, "v1")
+	if err != nil {
+		t.Fatalf("Snapshot: %v", err)
+	}
+
+	t.Cleanup(func() { o.RemoveSnapshot(snap) })
+
+	if got := snap.Volumes[c.GetName()]; len(got) != 1 || got[0].Volume != "snapshot-data" {
+		t.Fatalf("Volumes: got %+v, want archive of snapshot-data", got)
+	}
+
+	writeData("db", "v2")
+	writeData("extra", "after snapshot")
+
+	if err = o.Restore(ctx, snap); err != nil {
+		t.Fatalf("Restore: %v", err)
+	}
+
+	dst := t.TempDir()
+
+	if err = containers.CopyFromContainer(ctx, c, "/var/lib/data", dst); err != nil {
+		t.Fatalf("CopyFromContainer: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dst, "data", "db"))
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+
+	if string(data) != "v1" {
+		t.Fatalf("db: got %q, want %q", data, "v1")
+	}
+
+	if _, err = os.Stat(filepath.Join(dst, "data", "extra")); !os.IsNotExist(err) {
+		t.Fatalf("file written after the snapshot is restored: %v", err)
+	}
+
+	o.RemoveSnapshot(snap)
+
+	if _, err = os.Stat(snap.Dir); !os.IsNotExist(err) {
+		t.Fatalf("snapshot directory is kept after RemoveSnapshot: %v", err)
+	}
+}
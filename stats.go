@@ -0,0 +1,50 @@
+package containers
+
+import (
+	"context"
+	"time"
+
+	"gopkg.in/gomisc/errors.v1"
+)
+
+// StatsSample - замер потребления ресурсов контейнером. Счетчики сети и
+// дисков накопительные с момента запуска контейнера
+type StatsSample struct {
+	Time time.Time `json:"time" yaml:"time"`
+	// CPUPercent - загрузка процессора с прошлого замера, 100 - одно ядро
+	CPUPercent float64 `json:"cpu_percent" yaml:"cpu_percent"`
+	// CPUTime - суммарное процессорное время процессов контейнера
+	CPUTime time.Duration `json:"cpu_time" yaml:"cpu_time"`
+	// MemoryUsage - используемая память без страничного кеша
+	MemoryUsage uint64 `json:"memory_usage" yaml:"memory_usage"`
+	MemoryLimit uint64 `json:"memory_limit,omitempty" yaml:"memory_limit,omitempty"`
+	NetRxBytes  uint64 `json:"net_rx_bytes" yaml:"net_rx_bytes"`
+	NetTxBytes  uint64 `json:"net_tx_bytes" yaml:"net_tx_bytes"`
+	BlockRead   uint64 `json:"block_read" yaml:"block_read"`
+	BlockWrite  uint64 `json:"block_write" yaml:"block_write"`
+	Pids        uint64 `json:"pids" yaml:"pids"`
+}
+
+// MemoryPercent - доля используемой памяти от лимита, 0 - лимит неизвестен
+func (s StatsSample) MemoryPercent() float64 {
+	if s.MemoryLimit == 0 {
+		return 0
+	}
+
+	return float64(s.MemoryUsage) / float64(s.MemoryLimit) * 100
+}
+
+// Stats - поток замеров потребления ресурсов контейнером; канал закрывается
+// при отмене ctx или завершении контейнера
+func (c *BaseContainer) Stats(ctx context.Context) (<-chan StatsSample, error) {
+	if c.containerID == "" {
+		return nil, errors.Ctx().Str("container-name", c.GetName()).Just(ErrContainerNotRunning)
+	}
+
+	samples, err := c.runtime().ContainerStats(ctx, c.containerID)
+	if err != nil {
+		return nil, errors.Ctx().Str("container-name", c.GetName()).Wrap(err, "stream container stats")
+	}
+
+	return samples, nil
+}
@@ -0,0 +1,231 @@
+package containers
+
+import (
+	"context"
+	"math/big"
+	"net"
+	"net/netip"
+	"strings"
+	"sync"
+
+	"gopkg.in/gomisc/errors.v1"
+)
+
+// Настройки пула подсетей по умолчанию
+const (
+	DefaultSubnetPool   = "172.16.0.0/12"
+	DefaultSubnetPrefix = 24
+	// DefaultSubnetPool6 - пул IPv6 подсетей из диапазона уникальных
+	// локальных адресов (ULA), не маршрутизируемых во внешние сети
+	DefaultSubnetPool6   = "fd00:c0de::/48"
+	DefaultSubnetPrefix6 = 64
+
+	ErrPoolExhausted       = errors.Const("subnet pool exhausted")
+	ErrInvalidSubnetPrefix = errors.Const("invalid subnet prefix")
+	// ErrInvalidSubnet - подсеть задана с ошибкой или подсетей одного
+	// семейства адресов задано несколько
+	ErrInvalidSubnet = errors.Const("invalid subnet")
+)
+
+type (
+	// UsedSubnetsFunc - возвращает подсети, занятые в среде исполнения
+	UsedSubnetsFunc func(ctx context.Context) ([]netip.Prefix, error)
+
+	// SubnetReleaser - клиент, возвращающий в пул подсеть, выданную
+	// NextSubnet и не использованную для сети
+	SubnetReleaser interface {
+		ReleaseSubnet(subnet *net.IPNet)
+	}
+
+	// SubnetAllocator - аллокатор подсетей из пула: кандидаты перебираются
+	// арифметикой CIDR с перескоком через занятые диапазоны, поддерживаются
+	// подсети разной длины префикса
+	SubnetAllocator struct {
+		pool netip.Prefix
+		used UsedSubnetsFunc
+
+		mu        sync.Mutex
+		reserved  []netip.Prefix
+		allocated []netip.Prefix
+	}
+)
+
+// NewSubnetAllocator - конструктор аллокатора подсетей из пула pool (CIDR)
+func NewSubnetAllocator(pool string, used UsedSubnetsFunc, reserved ...string) (*SubnetAllocator, error) {
+	poolPrefix, err := netip.ParsePrefix(pool)
+	if err != nil {
+		return nil, errors.Ctx().Str("pool", pool).Wrap(err, "parse subnet pool")
+	}
+
+	sa := &SubnetAllocator{pool: poolPrefix.Masked(), used: used}
+
+	for _, r := range reserved {
+		prefix, parseErr := netip.ParsePrefix(r)
+		if parseErr != nil {
+			return nil, errors.Ctx().Str("reserved", r).Wrap(parseErr, "parse reserved network")
+		}
+
+		sa.reserved = append(sa.reserved, prefix.Masked())
+	}
+
+	return sa, nil
+}
+
+// Allocate - возвращает свободную подсеть с длиной префикса bits
+func (sa *SubnetAllocator) Allocate(ctx context.Context, bits int) (*net.IPNet, error) {
+	if bits < sa.pool.Bits() || bits > sa.pool.Addr().BitLen() {
+		return nil, errors.Ctx().
+			Int("prefix", bits).
+			Str("pool", sa.pool.String()).
+			Just(ErrInvalidSubnetPrefix)
+	}
+
+	var used []netip.Prefix
+
+	if sa.used != nil {
+		var err error
+
+		if used, err = sa.used(ctx); err != nil {
+			return nil, errors.Wrap(err, "get used networks")
+		}
+	}
+
+	sa.mu.Lock()
+	defer sa.mu.Unlock()
+
+	busy := make([]netip.Prefix, 0, len(used)+len(sa.reserved)+len(sa.allocated))
+	busy = append(busy, used...)
+	busy = append(busy, sa.reserved...)
+	busy = append(busy, sa.allocated...)
+
+	candidate := netip.PrefixFrom(sa.pool.Addr(), bits)
+
+	for sa.pool.Contains(candidate.Addr()) {
+		conflict, ok := firstOverlap(candidate, busy)
+		if !ok {
+			sa.allocated = append(sa.allocated, candidate)
+
+			return prefixToIPNet(candidate), nil
+		}
+
+		// перескакиваем за конец большего из пересекающихся диапазонов
+		next, ok := nextPrefix(candidate, conflict)
+		if !ok {
+			break
+		}
+
+		candidate = next
+	}
+
+	return nil, errors.Ctx().Str("pool", sa.pool.String()).Int("prefix", bits).Just(ErrPoolExhausted)
+}
+
+// Release - возвращает подсеть в пул
+func (sa *SubnetAllocator) Release(subnet *net.IPNet) {
+	prefix, ok := ipNetToPrefix(subnet)
+	if !ok {
+		return
+	}
+
+	sa.mu.Lock()
+	defer sa.mu.Unlock()
+
+	for i, p := range sa.allocated {
+		if p == prefix {
+			sa.allocated = append(sa.allocated[:i], sa.allocated[i+1:]...)
+
+			return
+		}
+	}
+}
+
+// SplitSubnets - разбирает список подсетей сети через запятую на подсети
+// IPv4 и IPv6 (каждая может отсутствовать), например
+// "172.30.0.0/24,fd00:1::/64" для сети с двумя стеками адресов
+func SplitSubnets(cidr string) (v4, v6 *net.IPNet, err error) {
+	for _, s := range strings.Split(cidr, ",") {
+		if s = strings.TrimSpace(s); s == "" {
+			continue
+		}
+
+		_, subnet, parseErr := net.ParseCIDR(s)
+		if parseErr != nil {
+			return nil, nil, errors.And(errors.Ctx().Str("cidr", s).Just(ErrInvalidSubnet), parseErr)
+		}
+
+		dst := &v6
+		if subnet.IP.To4() != nil {
+			dst = &v4
+		}
+
+		if *dst != nil {
+			return nil, nil, errors.Ctx().Str("cidr", cidr).Just(ErrInvalidSubnet)
+		}
+
+		*dst = subnet
+	}
+
+	return v4, v6, nil
+}
+
+func firstOverlap(candidate netip.Prefix, busy []netip.Prefix) (netip.Prefix, bool) {
+	for _, b := range busy {
+		if b.Addr().Is4() == candidate.Addr().Is4() && b.Overlaps(candidate) {
+			return b, true
+		}
+	}
+
+	return netip.Prefix{}, false
+}
+
+// nextPrefix - первый выровненный кандидат той же длины после конца
+// большего из двух пересекающихся префиксов
+func nextPrefix(candidate, conflict netip.Prefix) (netip.Prefix, bool) {
+	wider := candidate
+	if conflict.Bits() < candidate.Bits() {
+		wider = conflict.Masked()
+	}
+
+	size := new(big.Int).Lsh(big.NewInt(1), uint(wider.Addr().BitLen()-wider.Bits()))
+	end := new(big.Int).Add(new(big.Int).SetBytes(wider.Addr().AsSlice()), size)
+
+	raw := end.Bytes()
+	addrLen := wider.Addr().BitLen() / 8
+
+	if len(raw) > addrLen {
+		// переполнение адресного пространства
+		return netip.Prefix{}, false
+	}
+
+	buf := make([]byte, addrLen)
+	copy(buf[addrLen-len(raw):], raw)
+
+	addr, ok := netip.AddrFromSlice(buf)
+	if !ok {
+		return netip.Prefix{}, false
+	}
+
+	return netip.PrefixFrom(addr, candidate.Bits()), true
+}
+
+func prefixToIPNet(p netip.Prefix) *net.IPNet {
+	return &net.IPNet{
+		IP:   p.Addr().AsSlice(),
+		Mask: net.CIDRMask(p.Bits(), p.Addr().BitLen()),
+	}
+}
+
+func ipNetToPrefix(n *net.IPNet) (netip.Prefix, bool) {
+	if n == nil {
+		return netip.Prefix{}, false
+	}
+
+	addr, ok := netip.AddrFromSlice(n.IP)
+	if !ok {
+		return netip.Prefix{}, false
+	}
+
+	ones, _ := n.Mask.Size()
+
+	return netip.PrefixFrom(addr.Unmap(), ones).Masked(), true
+}
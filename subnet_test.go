@@ -0,0 +1,186 @@
+package containers_test
+
+import (
+	"context"
+	"net/netip"
+	"testing"
+
+	"gopkg.in/gomisc/errors.v1"
+
+	"gopkg.in/gomisc/containers.v1"
+)
+
+func usedSubnets(cidrs ...string) containers.UsedSubnetsFunc {
+	return func(context.Context) ([]netip.Prefix, error) {
+		used := make([]netip.Prefix, 0, len(cidrs))
+
+		for _, cidr := range cidrs {
+			used = append(used, netip.MustParsePrefix(cidr))
+		}
+
+		return used, nil
+	}
+}
+
+func TestSubnetAllocatorAllocate(t *testing.T) {
+	tests := []struct {
+		name     string
+		pool     string
+		bits     int
+		used     []string
+		reserved []string
+		want     string
+		wantErr  error
+	}{
+		{
+			name: "first",
+			pool: "10.0.0.0/16",
+			bits: 24,
+			want: "10.0.0.0/24",
+		},
+		{
+			name: "busy prefix wider than candidate",
+			pool: "10.0.0.0/16",
+			bits: 24,
+			used: []string{"10.0.0.0/20"},
+			want: "10.0.16.0/24",
+		},
+		{
+			name: "busy prefix narrower than candidate",
+			pool: "10.0.0.0/16",
+			bits: 24,
+			used: []string{"10.0.0.128/25"},
+			want: "10.0.1.0/24",
+		},
+		{
+			name: "unaligned busy prefix",
+			pool: "10.0.0.0/16",
+			bits: 24,
+			used: []string{"10.0.0.7/20"},
+			want: "10.0.16.0/24",
+		},
+		{
+			name:     "reserved ranges",
+			pool:     "10.0.0.0/16",
+			bits:     24,
+			used:     []string{"10.0.2.0/24"},
+			reserved: []string{"10.0.0.0/24", "10.0.1.0/24"},
+			want:     "10.0.3.0/24",
+		},
+		{
+			name: "other address family is ignored",
+			pool: "10.0.0.0/16",
+			bits: 24,
+			used: []string{"fd00::/8"},
+			want: "10.0.0.0/24",
+		},
+		{
+			name:     "exhausted",
+			pool:     "10.0.0.0/23",
+			bits:     24,
+			used:     []string{"10.0.0.0/24"},
+			reserved: []string{"10.0.1.0/24"},
+			wantErr:  containers.ErrPoolExhausted,
+		},
+		{
+			name:    "prefix shorter than pool",
+			pool:    "10.0.0.0/16",
+			bits:    8,
+			wantErr: containers.ErrInvalidSubnetPrefix,
+		},
+		{
+			name:    "prefix longer than address",
+			pool:    "10.0.0.0/16",
+			bits:    33,
+			wantErr: containers.ErrInvalidSubnetPrefix,
+		},
+		{
+			name:    "IPv4 overflow",
+			pool:    "255.255.255.0/24",
+			bits:    25,
+			used:    []string{"255.255.255.0/24"},
+			wantErr: containers.ErrPoolExhausted,
+		},
+		{
+			name: "IPv6",
+			pool: containers.DefaultSubnetPool6,
+			bits: containers.DefaultSubnetPrefix6,
+			used: []string{"fd00:c0de::/56"},
+			want: "fd00:c0de:0:100::/64",
+		},
+		{
+			name:     "IPv6 overflow",
+			pool:     "ffff:ffff:ffff:ffff:ffff:ffff:ffff:ff00/120",
+			bits:     121,
+			used:     []string{"ffff:ffff:ffff:ffff:ffff:ffff:ffff:ff00/121"},
+			reserved: []string{"ffff:ffff:ffff:ffff:ffff:ffff:ffff:ff80/121"},
+			wantErr:  containers.ErrPoolExhausted,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				sa, err := containers.NewSubnetAllocator(tt.pool, usedSubnets(tt.used...), tt.reserved...)
+				if err != nil {
+					t.Fatalf("NewSubnetAllocator: %v", err)
+				}
+
+				subnet, err := sa.Allocate(context.Background(), tt.bits)
+				if tt.wantErr != nil {
+					if !errors.Is(err, tt.wantErr) {
+						t.Fatalf("Allocate: got %v, %v, want %v", subnet, err, tt.wantErr)
+					}
+
+					return
+				}
+
+				if err != nil {
+					t.Fatalf("Allocate: %v", err)
+				}
+
+				if subnet.String() != tt.want {
+					t.Fatalf("Allocate: got %s, want %s", subnet, tt.want)
+				}
+			},
+		)
+	}
+}
+
+func TestSubnetAllocatorRelease(t *testing.T) {
+	sa, err := containers.NewSubnetAllocator("10.0.0.0/22", nil)
+	if err != nil {
+		t.Fatalf("NewSubnetAllocator: %v", err)
+	}
+
+	allocate := func(want string) {
+		t.Helper()
+
+		subnet, allocErr := sa.Allocate(context.Background(), 24)
+		if allocErr != nil {
+			t.Fatalf("Allocate: %v", allocErr)
+		}
+
+		if subnet.String() != want {
+			t.Fatalf("Allocate: got %s, want %s", subnet, want)
+		}
+	}
+
+	allocate("10.0.0.0/24")
+	allocate("10.0.1.0/24")
+	allocate("10.0.2.0/24")
+
+	v4, _, err := containers.SplitSubnets("10.0.1.0/24")
+	if err != nil {
+		t.Fatalf("SplitSubnets: %v", err)
+	}
+
+	sa.Release(v4)
+
+	allocate("10.0.1.0/24")
+	allocate("10.0.3.0/24")
+
+	if subnet, allocErr := sa.Allocate(context.Background(), 24); !errors.Is(allocErr, containers.ErrPoolExhausted) {
+		t.Fatalf("Allocate: got %v, %v, want %v", subnet, allocErr, containers.ErrPoolExhausted)
+	}
+}
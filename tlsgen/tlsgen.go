@@ -0,0 +1,239 @@
+// Package tlsgen - генерация тестового удостоверяющего центра и серверных
+// сертификатов контейнеров для проверки TLS без хранения статичных ключей
+package tlsgen
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+
+	"gopkg.in/gomisc/containers.v1"
+	"gopkg.in/gomisc/errors.v1"
+)
+
+// Пути материалов внутри контейнера
+const (
+	CertDir  = "/etc/ssl/containers"
+	CAFile   = "ca.crt"
+	CertFile = "tls.crt"
+	KeyFile  = "tls.key"
+
+	validity = 24 * time.Hour
+)
+
+type (
+	// CA - тестовый удостоверяющий центр
+	CA struct {
+		cert    *x509.Certificate
+		key     *ecdsa.PrivateKey
+		certPEM []byte
+	}
+
+	// Cert - выпущенный сертификат и его ключ в PEM
+	Cert struct {
+		CertPEM []byte
+		KeyPEM  []byte
+	}
+)
+
+// NewCA - создает самоподписанный удостоверяющий центр
+func NewCA(commonName string) (*CA, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, errors.Wrap(err, "generate ca key")
+	}
+
+	tmpl, err := template(commonName)
+	if err != nil {
+		return nil, err
+	}
+
+	tmpl.IsCA = true
+	tmpl.BasicConstraintsValid = true
+	tmpl.KeyUsage = x509.KeyUsageCertSign | x509.KeyUsageCRLSign
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		return nil, errors.Wrap(err, "create ca certificate")
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, errors.Wrap(err, "parse ca certificate")
+	}
+
+	return &CA{
+		cert:    cert,
+		key:     key,
+		certPEM: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+	}, nil
+}
+
+// CertPEM - сертификат центра в PEM
+func (ca *CA) CertPEM() []byte {
+	return ca.certPEM
+}
+
+// Pool - пул доверенных сертификатов с этим центром
+func (ca *CA) Pool() *x509.CertPool {
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.cert)
+
+	return pool
+}
+
+// ClientConfig - настройки TLS клиента тестового процесса, доверяющего центру
+func (ca *CA) ClientConfig() *tls.Config {
+	return &tls.Config{RootCAs: ca.Pool(), MinVersion: tls.VersionTLS12}
+}
+
+// Issue - выпускает серверный сертификат, names - DNS имена и IP адреса (SAN),
+// первое имя становится CommonName
+func (ca *CA) Issue(names ...string) (*Cert, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, errors.Wrap(err, "generate server key")
+	}
+
+	var commonName string
+
+	if len(names) != 0 {
+		commonName = names[0]
+	}
+
+	tmpl, err := template(commonName)
+	if err != nil {
+		return nil, err
+	}
+
+	tmpl.KeyUsage = x509.KeyUsageDigitalSignature
+	tmpl.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}
+
+	for _, name := range names {
+		if ip := net.ParseIP(name); ip != nil {
+			tmpl.IPAddresses = append(tmpl.IPAddresses, ip)
+		} else {
+			tmpl.DNSNames = append(tmpl.DNSNames, name)
+		}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		return nil, errors.Ctx().Strings("names", names).Wrap(err, "create server certificate")
+	}
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, errors.Wrap(err, "marshal server key")
+	}
+
+	return &Cert{
+		CertPEM: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		KeyPEM:  pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}),
+	}, nil
+}
+
+// Inject - выпускает сертификат контейнера (SAN: имя, сетевые псевдонимы,
+// localhost и адреса loopback, адреса контейнера в его сетях и адрес хоста
+// опубликованных портов) и подключает каталог с ca.crt, tls.crt и tls.key
+// в CertDir контейнера. Вызывается до CreateContainer: адреса, которые иначе
+// выдала бы среда исполнения, резервируются в сетях заранее, чтобы попасть
+// в сертификат; dir - каталог хоста для материалов, обычно t.TempDir()
+func (ca *CA) Inject(c *containers.BaseContainer, dir string) error {
+	names := append([]string{c.Name}, c.Aliases...)
+	names = append(names, "localhost", "127.0.0.1", "::1")
+	names = append(names, reserveAddrs(c)...)
+
+	if ip := net.ParseIP(c.HostIP()); ip != nil && !ip.IsUnspecified() {
+		names = append(names, ip.String())
+	}
+
+	cert, err := ca.Issue(names...)
+	if err != nil {
+		return errors.Ctx().Str("container", c.Name).Wrap(err, "issue container certificate")
+	}
+
+	certDir, err := filepath.Abs(filepath.Join(dir, c.Name))
+	if err != nil {
+		return errors.Wrap(err, "resolve certificates dir")
+	}
+
+	if err = os.MkdirAll(certDir, 0o755); err != nil {
+		return errors.Ctx().Str("dir", certDir).Wrap(err, "create certificates dir")
+	}
+
+	files := map[string][]byte{
+		CAFile:   ca.certPEM,
+		CertFile: cert.CertPEM,
+		KeyFile:  cert.KeyPEM,
+	}
+
+	for name, data := range files {
+		// ключ читается процессом контейнера под произвольным пользователем
+		if err = os.WriteFile(filepath.Join(certDir, name), data, 0o644); err != nil { //nolint:gosec
+			return errors.Ctx().Str("file", name).Wrap(err, "write tls material")
+		}
+	}
+
+	c.Mounts = append(c.Mounts, certDir+":"+CertDir)
+
+	return nil
+}
+
+// reserveAddrs - адреса контейнера в основной и дополнительных сетях;
+// не заданные адреса IPv4 резервируются в сетях и закрепляются за контейнером
+// (адрес IPv6 попадает в сертификат, только если задан явно)
+func reserveAddrs(c *containers.BaseContainer) []string {
+	nw := c.GetNetwork()
+
+	if c.ContainerIP == "" && nw != nil {
+		c.ContainerIP = nw.NextIP()
+	}
+
+	addrs := []string{c.ContainerIP, c.ContainerIP6}
+
+	for i := range c.ExtraNetworks {
+		attachment := &c.ExtraNetworks[i]
+
+		if attachment.IP == "" && attachment.Network != nil {
+			attachment.IP = attachment.Network.NextIP()
+		}
+
+		addrs = append(addrs, attachment.IP)
+	}
+
+	result := addrs[:0]
+
+	for _, addr := range addrs {
+		if addr != "" {
+			result = append(result, addr)
+		}
+	}
+
+	return result
+}
+
+func template(commonName string) (*x509.Certificate, error) {
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, errors.Wrap(err, "generate serial number")
+	}
+
+	now := time.Now()
+
+	return &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: commonName, Organization: []string{"containers test"}},
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     now.Add(validity),
+	}, nil
+}
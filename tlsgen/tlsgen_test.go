@@ -0,0 +1,71 @@
+package tlsgen_test
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gopkg.in/gomisc/containers.v1"
+	"gopkg.in/gomisc/containers.v1/adapters/fake"
+	"gopkg.in/gomisc/containers.v1/tlsgen"
+)
+
+func TestInjectIPAddresses(t *testing.T) {
+	cli, err := fake.New()
+	if err != nil {
+		t.Fatalf("fake.New: %v", err)
+	}
+
+	nw, err := cli.CheckNetwork("tlsgen-net", "")
+	if err != nil {
+		t.Fatalf("CheckNetwork: %v", err)
+	}
+
+	ca, err := tlsgen.NewCA("test")
+	if err != nil {
+		t.Fatalf("NewCA: %v", err)
+	}
+
+	c := containers.NewBaseContainer(cli, nw, nil)
+	c.Name = "tls"
+
+	dir := t.TempDir()
+
+	if err = ca.Inject(c, dir); err != nil {
+		t.Fatalf("Inject: %v", err)
+	}
+
+	if c.ContainerIP == "" {
+		t.Fatal("container address is not reserved")
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, c.Name, tlsgen.CertFile))
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		t.Fatal("certificate is not PEM encoded")
+	}
+
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatalf("ParseCertificate: %v", err)
+	}
+
+	for _, want := range []string{"127.0.0.1", "::1", c.ContainerIP} {
+		if err = cert.VerifyHostname(want); err != nil {
+			t.Errorf("certificate does not cover %s: %v", want, err)
+		}
+	}
+
+	if ip := net.ParseIP(c.HostIP()); ip != nil && !ip.IsUnspecified() {
+		if err = cert.VerifyHostname(ip.String()); err != nil {
+			t.Errorf("certificate does not cover host %s: %v", ip, err)
+		}
+	}
+}
@@ -2,7 +2,9 @@ package containers
 
 import (
 	"context"
+	"encoding/json"
 
+	"gopkg.in/gomisc/errors.v1"
 	"gopkg.in/gomisc/network.v1/ports"
 )
 
@@ -13,11 +15,119 @@ type (
 	// ReadyFunc - обработчик готовности контейнера
 	ReadyFunc func(ctx context.Context) <-chan struct{}
 
-	// OrchestratorInfo - информация о контейнере в представлении оркестратора
+	// ReadinessFunc - проверка готовности контейнера с указанием причины неудачи:
+	// nil (или закрытие канала) означает готовность, ошибка - провал проверки
+	ReadinessFunc func(ctx context.Context) <-chan error
+
+	// DaemonInfo - сведения о демоне среды исполнения контейнеров
+	DaemonInfo struct {
+		Name            string   `json:"name" yaml:"name"`
+		ServerVersion   string   `json:"server_version" yaml:"server_version"`
+		OperatingSystem string   `json:"operating_system" yaml:"operating_system"`
+		OSType          string   `json:"os_type" yaml:"os_type"`
+		Architecture    string   `json:"architecture" yaml:"architecture"`
+		KernelVersion   string   `json:"kernel_version" yaml:"kernel_version"`
+		NCPU            int      `json:"ncpu" yaml:"ncpu"`
+		MemTotal        int64    `json:"mem_total" yaml:"mem_total"`
+		RootDir         string   `json:"root_dir" yaml:"root_dir"`
+		CgroupVersion   string   `json:"cgroup_version,omitempty" yaml:"cgroup_version,omitempty"`
+		SecurityOptions []string `json:"security_options,omitempty" yaml:"security_options,omitempty"`
+		// CgroupDriver - драйвер cgroup демона (cgroupfs, systemd)
+		CgroupDriver string `json:"cgroup_driver,omitempty" yaml:"cgroup_driver,omitempty"`
+		// Limits - ограничения ресурсов, которые поддерживает хост
+		Limits LimitsSupport `json:"limits" yaml:"limits"`
+		// Remote - демон работает на другой машине: порты хоста вызывающего
+		// процесса из контейнеров недоступны, а опубликованные порты - на хосте демона
+		Remote bool `json:"remote,omitempty" yaml:"remote,omitempty"`
+	}
+
+	// LimitsSupport - поддержка хостом ограничений ресурсов контейнеров
+	LimitsSupport struct {
+		Memory    bool `json:"memory" yaml:"memory"`
+		Swap      bool `json:"swap" yaml:"swap"`
+		CPUQuota  bool `json:"cpu_quota" yaml:"cpu_quota"`
+		CPUShares bool `json:"cpu_shares" yaml:"cpu_shares"`
+		CPUSet    bool `json:"cpu_set" yaml:"cpu_set"`
+		Pids      bool `json:"pids" yaml:"pids"`
+	}
+
+	// OrchestratorInfo - информация о контейнере в представлении оркестратора.
+	// Имена полей сохранены ради совместимости, ключи сериализации исправлены:
+	// при разборе принимаются и прежние ключи container_enpoints/host_enpoints
 	OrchestratorInfo struct {
-		ID                string   `json:"id"`
-		TypeID            uint8    `json:"type_id"`
-		ContainerEnpoints AddrsMap `json:"container_enpoints"`
-		HostEnpoints      AddrsMap `json:"host_enpoints"`
+		ID                string   `json:"id" yaml:"id"`
+		TypeID            uint8    `json:"type_id" yaml:"type_id"`
+		ContainerEnpoints AddrsMap `json:"container_endpoints" yaml:"container_endpoints"`
+		HostEnpoints      AddrsMap `json:"host_endpoints" yaml:"host_endpoints"`
+		// Name, Image - имя и образ контейнера
+		Name  string `json:"name,omitempty" yaml:"name,omitempty"`
+		Image string `json:"image,omitempty" yaml:"image,omitempty"`
+		// Labels - метки контейнера
+		Labels map[string]string `json:"labels,omitempty" yaml:"labels,omitempty"`
+		// Networks - сети контейнера по имени с идентификатором сети и адресами в ней
+		Networks map[string]EndpointSettings `json:"networks,omitempty" yaml:"networks,omitempty"`
+		// State - одно из состояний State*, Health - одно из состояний Health*
+		// (пусто - проверка здоровья не задана); см. Registry.Follow
+		State  string `json:"state,omitempty" yaml:"state,omitempty"`
+		Health string `json:"health,omitempty" yaml:"health,omitempty"`
 	}
 )
+
+// UnmarshalJSON - разбирает информацию о контейнере, поддерживая ключи с опечаткой
+// из прежних версий
+func (info *OrchestratorInfo) UnmarshalJSON(data []byte) error {
+	type plain OrchestratorInfo
+
+	var legacy struct {
+		plain
+		LegacyContainerEnpoints AddrsMap `json:"container_enpoints"`
+		LegacyHostEnpoints      AddrsMap `json:"host_enpoints"`
+	}
+
+	if err := json.Unmarshal(data, &legacy); err != nil {
+		return errors.Wrap(err, "decode orchestrator info")
+	}
+
+	*info = OrchestratorInfo(legacy.plain)
+
+	if info.ContainerEnpoints == nil {
+		info.ContainerEnpoints = legacy.LegacyContainerEnpoints
+	}
+
+	if info.HostEnpoints == nil {
+		info.HostEnpoints = legacy.LegacyHostEnpoints
+	}
+
+	return nil
+}
+
+// Copy - возвращает независимую копию мапы адресов
+func (m AddrsMap) Copy() AddrsMap {
+	cp := make(AddrsMap, len(m))
+
+	for name, addr := range m {
+		cp[name] = addr
+	}
+
+	return cp
+}
+
+// Readiness - приводит ReadyFunc к контракту ReadinessFunc, при истечении
+// контекста возвращается его ошибка
+func (f ReadyFunc) Readiness() ReadinessFunc {
+	return func(ctx context.Context) <-chan error {
+		errCh := make(chan error, 1)
+		readyCh := f(ctx)
+
+		go func() {
+			select {
+			case <-readyCh:
+				errCh <- nil
+			case <-ctx.Done():
+				errCh <- ctx.Err()
+			}
+		}()
+
+		return errCh
+	}
+}
@@ -0,0 +1,230 @@
+package containers
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"gopkg.in/gomisc/errors.v1"
+)
+
+const (
+	// ArtifactsUploadEnvar - принудительно включает (1) или отключает (0) загрузку
+	// артефактов; по умолчанию загрузка выполняется только в CI (задана переменная CI)
+	ArtifactsUploadEnvar = "CONTAINERS_ARTIFACTS_UPLOAD"
+	// ArtifactsRunIDEnvar - идентификатор прогона, первый сегмент ключей
+	// артефактов; по умолчанию берется идентификатор задания CI, вне CI -
+	// время запуска и pid процесса
+	ArtifactsRunIDEnvar = "CONTAINERS_ARTIFACTS_RUN_ID"
+)
+
+// runIDEnvars - переменные CI с идентификатором задания: GitHub Actions,
+// GitLab CI, Jenkins, Buildkite
+var runIDEnvars = []string{"GITHUB_RUN_ID", "CI_JOB_ID", "BUILD_TAG", "BUILDKITE_JOB_ID"}
+
+// artifactsUploadTimeout - ограничение времени загрузки артефактов одного теста
+const artifactsUploadTimeout = 5 * time.Minute
+
+// Uploader - загрузчик артефактов во внешнее хранилище, чтобы они пережили
+// удаление временного CI раннера
+type Uploader interface {
+	// Upload - загружает файл path хоста под ключом key
+	Upload(ctx context.Context, key, path string) error
+}
+
+var (
+	uploaderMu  sync.Mutex
+	uploader    Uploader
+	uploaderSet bool
+
+	runID     string
+	runIDOnce sync.Once
+)
+
+// SetUploader - задает загрузчик артефактов упавших тестов и окружений,
+// nil отключает загрузку. Без вызова используется S3UploaderFromEnv
+func SetUploader(u Uploader) {
+	uploaderMu.Lock()
+	defer uploaderMu.Unlock()
+
+	uploader, uploaderSet = u, true
+}
+
+// UploadDir - загружает все файлы каталога dir с ключами prefix/<путь в каталоге>
+func UploadDir(ctx context.Context, u Uploader, dir, prefix string) error {
+	var err error
+
+	walkErr := filepath.Walk(
+		dir, func(path string, fi os.FileInfo, walkErr error) error {
+			if walkErr != nil {
+				return walkErr
+			}
+
+			if !fi.Mode().IsRegular() {
+				return nil
+			}
+
+			rel, relErr := filepath.Rel(dir, path)
+			if relErr != nil {
+				return errors.Ctx().Str("path", path).Wrap(relErr, "make artifact key")
+			}
+
+			if uploadErr := u.Upload(ctx, prefix+"/"+filepath.ToSlash(rel), path); uploadErr != nil {
+				// остальные артефакты загружаем, даже если один не удалось
+				err = errors.And(err, uploadErr)
+			}
+
+			return ctx.Err()
+		},
+	)
+	if walkErr != nil {
+		err = errors.And(err, errors.Ctx().Str("dir", dir).Wrap(walkErr, "walk artifacts dir"))
+	}
+
+	return err
+}
+
+// activeUploader - загрузчик, если загрузка артефактов включена
+func activeUploader() Uploader {
+	switch os.Getenv(ArtifactsUploadEnvar) {
+	case "0", "false":
+		return nil
+	case "1", "true":
+	default:
+		if os.Getenv("CI") == "" {
+			return nil
+		}
+	}
+
+	uploaderMu.Lock()
+	defer uploaderMu.Unlock()
+
+	if !uploaderSet {
+		if s3 := S3UploaderFromEnv(); s3 != nil {
+			uploader = s3
+		}
+
+		uploaderSet = true
+	}
+
+	return uploader
+}
+
+// ArtifactsRunID - идентификатор прогона, которым начинаются ключи
+// артефактов: артефакты параллельных и повторных прогонов не перезаписывают
+// друг друга
+func ArtifactsRunID() string {
+	runIDOnce.Do(
+		func() {
+			for _, key := range append([]string{ArtifactsRunIDEnvar}, runIDEnvars...) {
+				if runID = sanitizeTestName(os.Getenv(key)); runID != "" {
+					return
+				}
+			}
+
+			runID = processStart.UTC().Format("20060102T150405Z") + "-" + strconv.Itoa(os.Getpid())
+		},
+	)
+
+	return runID
+}
+
+// uploadArtifacts - загружает каталог артефактов с ключами
+// <ArtifactsRunID>/prefix/..., если загрузка включена. Значения секретов
+// контейнеров процесса в загружаемых файлах заменяются на Redacted
+func uploadArtifacts(dir, prefix string) error {
+	u := activeUploader()
+	if u == nil {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), artifactsUploadTimeout)
+	defer cancel()
+
+	return UploadDir(ctx, &redactUploader{next: u}, dir, ArtifactsRunID()+"/"+prefix)
+}
+
+// uploadFailure - собирает логи участников, манифест и ошибку неудачного
+// подъема окружения и загружает их, если загрузка включена
+func (o *Orchestrator) uploadFailure(cause error) error {
+	if activeUploader() == nil {
+		return nil
+	}
+
+	dir, err := os.MkdirTemp("", "containers-failure-")
+	if err != nil {
+		return errors.Wrap(err, "create failure artifacts dir")
+	}
+
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+
+	_ = os.WriteFile(filepath.Join(dir, "error.txt"), []byte(fmt.Sprintf("%+v\n", cause)), 0o644) //nolint:gosec
+
+	// манифест пишется без контекста подъема: он может быть уже отменен
+	_ = o.WriteManifestFile(context.Background(), filepath.Join(dir, "manifest.json"))
+
+	for _, m := range o.Members() {
+		if lc, ok := m.Container.(interface{ collectLogs(path string) error }); ok {
+			_ = lc.collectLogs(filepath.Join(dir, sanitizeTestName(m.Container.GetName())+".log"))
+		}
+	}
+
+	prefix := "orchestrator/" + time.Now().UTC().Format("20060102T150405Z")
+
+	return uploadArtifacts(dir, prefix)
+}
+
+// redactUploader - загружает копии файлов, в которых значения секретов
+// контейнеров процесса заменены на Redacted
+type redactUploader struct {
+	next Uploader
+}
+
+func (u *redactUploader) Upload(ctx context.Context, key, path string) error {
+	values := knownSecretValues()
+	if len(values) == 0 {
+		return u.next.Upload(ctx, key, path)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return errors.Ctx().Str("path", path).Wrap(err, "read artifact")
+	}
+
+	redacted := data
+
+	for _, v := range values {
+		redacted = bytes.ReplaceAll(redacted, []byte(v), []byte(Redacted))
+	}
+
+	if bytes.Equal(redacted, data) {
+		return u.next.Upload(ctx, key, path)
+	}
+
+	f, err := os.CreateTemp("", "containers-redacted-")
+	if err != nil {
+		return errors.Wrap(err, "create redacted artifact")
+	}
+
+	defer func() {
+		_ = os.Remove(f.Name())
+	}()
+
+	_, err = f.Write(redacted)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		return errors.Ctx().Str("path", path).Wrap(err, "write redacted artifact")
+	}
+
+	return u.next.Upload(ctx, key, f.Name())
+}
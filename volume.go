@@ -0,0 +1,126 @@
+package containers
+
+import (
+	"strings"
+	"time"
+
+	"gopkg.in/gomisc/errors.v1"
+)
+
+const (
+	// ErrVolumeNotFound - том не найден
+	ErrVolumeNotFound = errors.Const("volume not found")
+	// ErrVolumeInUse - том подключен к контейнеру
+	ErrVolumeInUse = errors.Const("volume is in use")
+	// ErrInvalidVolume - в описании тома не задано имя или путь в контейнере
+	ErrInvalidVolume = errors.Const("invalid volume spec")
+)
+
+type (
+	// VolumeSpec - именованный том: описание для VolumeCreate и подключения
+	// к контейнеру через BaseContainer.NamedVolumes. Том переживает удаление
+	// контейнера, поэтому несколько контейнеров окружения могут делить данные
+	VolumeSpec struct {
+		// Name - имя тома
+		Name string `json:"name" yaml:"name"`
+		// Target - путь в контейнере, при VolumeCreate не используется
+		Target string `json:"target,omitempty" yaml:"target,omitempty"`
+		// ReadOnly - подключение только для чтения
+		ReadOnly bool `json:"read_only,omitempty" yaml:"read_only,omitempty"`
+		// Driver - драйвер тома (пусто - драйвер демона по умолчанию)
+		Driver string `json:"driver,omitempty" yaml:"driver,omitempty"`
+		// Labels - метки тома, по ним VolumeList отбирает тома для очистки;
+		// применяются при создании тома, у существующего тома не меняются
+		Labels map[string]string `json:"labels,omitempty" yaml:"labels,omitempty"`
+	}
+
+	// VolumeInfo - сведения о томе
+	VolumeInfo struct {
+		Name       string            `json:"name" yaml:"name"`
+		Driver     string            `json:"driver,omitempty" yaml:"driver,omitempty"`
+		Mountpoint string            `json:"mountpoint,omitempty" yaml:"mountpoint,omitempty"`
+		Labels     map[string]string `json:"labels,omitempty" yaml:"labels,omitempty"`
+		Created    time.Time         `json:"created" yaml:"created"`
+	}
+
+	// VolumeFilter - условия отбора томов VolumeList, пустые условия
+	// не ограничивают выборку
+	VolumeFilter struct {
+		// Labels - метки тома, пустое значение - наличие метки с любым значением
+		Labels map[string]string
+		// NamePrefix - префикс имени тома
+		NamePrefix string
+	}
+)
+
+// Validate - проверяет описание тома, подключаемого к контейнеру
+func (v VolumeSpec) Validate() error {
+	if v.Name == "" || !strings.HasPrefix(v.Target, "/") {
+		return errors.Ctx().Str("name", v.Name).Str("target", v.Target).Just(ErrInvalidVolume)
+	}
+
+	return nil
+}
+
+// String - запись тома в форме name:target[:ro]
+func (v VolumeSpec) String() string {
+	s := v.Name + ":" + v.Target
+
+	if v.ReadOnly {
+		s += ":ro"
+	}
+
+	return s
+}
+
+// Match - проверяет сведения о томе на соответствие условиям
+func (f VolumeFilter) Match(v *VolumeInfo) bool {
+	if !strings.HasPrefix(v.Name, f.NamePrefix) {
+		return false
+	}
+
+	for k, value := range f.Labels {
+		actual, ok := v.Labels[k]
+		if !ok || (value != "" && actual != value) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// GetNamedVolumes - именованные тома контейнера
+func (c *BaseContainer) GetNamedVolumes() []VolumeSpec {
+	if c != nil {
+		return c.NamedVolumes
+	}
+
+	return nil
+}
+
+// GetTmpfs - разделы в памяти контейнера
+func (c *BaseContainer) GetTmpfs() map[string]string {
+	if c != nil {
+		return c.Tmpfs
+	}
+
+	return nil
+}
+
+// checkVolumes - проверяет описания именованных томов и разделов в памяти
+// перед созданием контейнера
+func (c *BaseContainer) checkVolumes() error {
+	for _, v := range c.NamedVolumes {
+		if err := v.Validate(); err != nil {
+			return errors.Ctx().Str("container-name", c.GetName()).Wrap(err, "check named volumes")
+		}
+	}
+
+	for target := range c.Tmpfs {
+		if !strings.HasPrefix(target, "/") {
+			return errors.Ctx().Str("container-name", c.GetName()).Str("tmpfs", target).Just(ErrInvalidVolume)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,98 @@
+// Package wait - стратегии ожидания готовности контейнеров
+package wait
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"time"
+
+	"gopkg.in/gomisc/errors.v1"
+)
+
+type (
+	// Backoff - параметры экспоненциальной задержки между попытками проверки
+	Backoff struct {
+		// Initial - задержка перед второй попыткой
+		Initial time.Duration `json:"initial" yaml:"initial"`
+		// Max - предельная задержка между попытками
+		Max time.Duration `json:"max" yaml:"max"`
+		// Factor - множитель задержки для каждой следующей попытки
+		Factor float64 `json:"factor" yaml:"factor"`
+		// Jitter - доля случайного отклонения задержки (0..1)
+		Jitter float64 `json:"jitter,omitempty" yaml:"jitter,omitempty"`
+	}
+
+	// CheckFunc - однократная проверка готовности, nil - готово
+	CheckFunc func(ctx context.Context) error
+)
+
+// DefaultBackoff - задержка по умолчанию: 50ms, 100ms, 200ms ... 2s с отклонением 20%
+var DefaultBackoff = Backoff{
+	Initial: 50 * time.Millisecond,
+	Max:     2 * time.Second,
+	Factor:  2,
+	Jitter:  0.2,
+}
+
+// WithMax - возвращает копию параметров с другой предельной задержкой
+func (b Backoff) WithMax(max time.Duration) Backoff {
+	b.Max = max
+
+	return b
+}
+
+// Delay - задержка после попытки с номером attempt (начиная с 0)
+func (b Backoff) Delay(attempt int) time.Duration {
+	delay := float64(b.Initial) * math.Pow(b.Factor, float64(attempt))
+
+	if b.Max > 0 && delay > float64(b.Max) {
+		delay = float64(b.Max)
+	}
+
+	if b.Jitter > 0 {
+		delay += delay * b.Jitter * (2*rand.Float64() - 1) //nolint:gosec
+	}
+
+	return time.Duration(delay)
+}
+
+// Poll - выполняет проверку до первого успеха с увеличивающейся задержкой
+// между попытками; по истечении контекста возвращает последнюю ошибку проверки
+func Poll(ctx context.Context, b Backoff, check CheckFunc) error {
+	for attempt := 0; ; attempt++ {
+		err := check(ctx)
+		if err == nil {
+			return nil
+		}
+
+		timer := time.NewTimer(b.Delay(attempt))
+
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+
+			return errors.And(errors.Wrap(ctx.Err(), "wait for readiness"), err)
+		case <-timer.C:
+		}
+	}
+}
+
+// For - приводит проверку к контракту containers.ReadinessFunc
+func For(b Backoff, check CheckFunc) func(ctx context.Context) <-chan error {
+	return func(ctx context.Context) <-chan error {
+		readyCh := make(chan error, 1)
+
+		go func() {
+			if err := Poll(ctx, b, check); err != nil {
+				readyCh <- err
+
+				return
+			}
+
+			close(readyCh)
+		}()
+
+		return readyCh
+	}
+}
@@ -0,0 +1,325 @@
+package wait
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"time"
+
+	"gopkg.in/gomisc/errors.v1"
+)
+
+// Ошибки протокольных проверок брокеров
+const (
+	ErrUnexpectedAMQPFrame   = errors.Const("unexpected amqp frame")
+	ErrKafkaNoBrokers        = errors.Const("kafka metadata has no brokers")
+	ErrKafkaTopicUnavailable = errors.Const("kafka topic is not available")
+	ErrKafkaBrokersMissing   = errors.Const("kafka cluster has fewer brokers than expected")
+
+	brokerMaxDelay = time.Second
+	brokerTimeout  = 5 * time.Second
+
+	kafkaMetadataKey      = 3
+	kafkaClientID         = "containers-wait"
+	kafkaMaxResponseBytes = 16 << 20
+)
+
+var amqpProtocolHeader = []byte{'A', 'M', 'Q', 'P', 0, 0, 9, 1}
+
+// ForAMQP - готовность AMQP 0-9-1 брокера (RabbitMQ): сервер должен ответить
+// на заголовок протокола методом Connection.Start, открытого порта недостаточно
+func ForAMQP(addr func() string) func(ctx context.Context) <-chan error {
+	return For(
+		DefaultBackoff.WithMax(brokerMaxDelay), func(ctx context.Context) error {
+			conn, err := dialBroker(ctx, addr())
+			if err != nil {
+				return err
+			}
+
+			defer func() {
+				_ = conn.Close()
+			}()
+
+			if _, err = conn.Write(amqpProtocolHeader); err != nil {
+				return errors.Wrap(err, "write amqp protocol header")
+			}
+
+			// frame: type(1) channel(2) size(4) class-id(2) method-id(2)
+			var frame [11]byte
+
+			if _, err = io.ReadFull(conn, frame[:]); err != nil {
+				return errors.Wrap(err, "read amqp frame")
+			}
+
+			const (
+				methodFrame     = 1
+				connectionClass = 10
+				startMethod     = 10
+			)
+
+			if frame[0] != methodFrame ||
+				binary.BigEndian.Uint16(frame[7:9]) != connectionClass ||
+				binary.BigEndian.Uint16(frame[9:11]) != startMethod {
+				return ErrUnexpectedAMQPFrame
+			}
+
+			return nil
+		},
+	)
+}
+
+// ForKafka - готовность Kafka брокера по ответу на запрос метаданных: в кластере
+// есть брокеры, а у каждой из topics (если заданы) нет ошибки и назначены
+// лидеры всех партиций
+func ForKafka(addr func() string, topics ...string) func(ctx context.Context) <-chan error {
+	return For(
+		DefaultBackoff.WithMax(brokerMaxDelay), func(ctx context.Context) error {
+			body, err := fetchKafkaMetadata(ctx, addr(), topics)
+			if err != nil {
+				return err
+			}
+
+			return checkKafkaMetadata(body, topics)
+		},
+	)
+}
+
+// ForKafkaBrokers - готовность Kafka кластера: метаданные, полученные через
+// addr, содержат не меньше n брокеров
+func ForKafkaBrokers(addr func() string, n int) func(ctx context.Context) <-chan error {
+	return For(
+		DefaultBackoff.WithMax(brokerMaxDelay), func(ctx context.Context) error {
+			body, err := fetchKafkaMetadata(ctx, addr(), nil)
+			if err != nil {
+				return err
+			}
+
+			brokers, err := kafkaBrokerCount(body)
+			if err != nil {
+				return err
+			}
+
+			if brokers < n {
+				return errors.Ctx().Int("brokers", brokers).Int("expected", n).Just(ErrKafkaBrokersMissing)
+			}
+
+			return nil
+		},
+	)
+}
+
+// fetchKafkaMetadata - выполняет запрос метаданных и возвращает тело ответа
+func fetchKafkaMetadata(ctx context.Context, addr string, topics []string) ([]byte, error) {
+	conn, err := dialBroker(ctx, addr)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		_ = conn.Close()
+	}()
+
+	if _, err = conn.Write(kafkaMetadataRequest(topics)); err != nil {
+		return nil, errors.Wrap(err, "write kafka metadata request")
+	}
+
+	var size int32
+
+	if err = binary.Read(conn, binary.BigEndian, &size); err != nil {
+		return nil, errors.Wrap(err, "read kafka response size")
+	}
+
+	if size < 0 || size > kafkaMaxResponseBytes {
+		return nil, errors.Ctx().Int("size", int(size)).New("invalid kafka response size")
+	}
+
+	body := make([]byte, size)
+
+	if _, err = io.ReadFull(conn, body); err != nil {
+		return nil, errors.Wrap(err, "read kafka response")
+	}
+
+	return body, nil
+}
+
+func dialBroker(ctx context.Context, addr string) (net.Conn, error) {
+	dialer := net.Dialer{Timeout: dialTimeout}
+
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, errors.Ctx().Str("addr", addr).Wrap(err, "dial broker")
+	}
+
+	deadline := time.Now().Add(brokerTimeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+
+	_ = conn.SetDeadline(deadline)
+
+	return conn, nil
+}
+
+// kafkaMetadataRequest - MetadataRequest v0 с заголовком запроса
+func kafkaMetadataRequest(topics []string) []byte {
+	var body bytes.Buffer
+
+	_ = binary.Write(&body, binary.BigEndian, int16(kafkaMetadataKey))
+	_ = binary.Write(&body, binary.BigEndian, int16(0))
+	_ = binary.Write(&body, binary.BigEndian, int32(1))
+	writeKafkaString(&body, kafkaClientID)
+	_ = binary.Write(&body, binary.BigEndian, int32(len(topics)))
+
+	for _, topic := range topics {
+		writeKafkaString(&body, topic)
+	}
+
+	req := make([]byte, 4, 4+body.Len())
+	binary.BigEndian.PutUint32(req, uint32(body.Len()))
+
+	return append(req, body.Bytes()...)
+}
+
+func writeKafkaString(buf *bytes.Buffer, s string) {
+	_ = binary.Write(buf, binary.BigEndian, int16(len(s)))
+	buf.WriteString(s)
+}
+
+// checkKafkaMetadata - разбирает MetadataResponse v0
+func checkKafkaMetadata(body []byte, topics []string) error {
+	r := kafkaReader{r: bufio.NewReader(bytes.NewReader(body))}
+
+	r.int32() // correlation id
+
+	brokers := r.int32()
+
+	for i := int32(0); i < brokers && r.err == nil; i++ {
+		r.int32()
+		r.string()
+		r.int32()
+	}
+
+	if r.err == nil && brokers == 0 {
+		return ErrKafkaNoBrokers
+	}
+
+	wanted := make(map[string]struct{}, len(topics))
+
+	for _, topic := range topics {
+		wanted[topic] = struct{}{}
+	}
+
+	count := r.int32()
+
+	for i := int32(0); i < count && r.err == nil; i++ {
+		code := r.int16()
+		name := r.string()
+		available := code == 0
+
+		partitions := r.int32()
+
+		for p := int32(0); p < partitions && r.err == nil; p++ {
+			r.int16()
+			r.int32()
+
+			if leader := r.int32(); leader < 0 {
+				available = false
+			}
+
+			r.skipInt32Array()
+			r.skipInt32Array()
+		}
+
+		if _, ok := wanted[name]; ok && r.err == nil {
+			if !available {
+				return errors.Ctx().Str("topic", name).Int("error-code", int(code)).Just(ErrKafkaTopicUnavailable)
+			}
+
+			delete(wanted, name)
+		}
+	}
+
+	if r.err != nil {
+		return errors.Wrap(r.err, "decode kafka metadata")
+	}
+
+	if len(wanted) != 0 {
+		missing := make([]string, 0, len(wanted))
+
+		for name := range wanted {
+			missing = append(missing, name)
+		}
+
+		return errors.Ctx().Strings("topics", missing).Just(ErrKafkaTopicUnavailable)
+	}
+
+	return nil
+}
+
+// kafkaBrokerCount - число брокеров в MetadataResponse v0
+func kafkaBrokerCount(body []byte) (int, error) {
+	r := kafkaReader{r: bufio.NewReader(bytes.NewReader(body))}
+
+	r.int32() // correlation id
+
+	brokers := r.int32()
+	if r.err != nil {
+		return 0, errors.Wrap(r.err, "decode kafka metadata")
+	}
+
+	return int(brokers), nil
+}
+
+// kafkaReader - последовательное чтение полей ответа, первая ошибка запоминается
+type kafkaReader struct {
+	r   *bufio.Reader
+	err error
+}
+
+func (kr *kafkaReader) read(v any) {
+	if kr.err == nil {
+		kr.err = binary.Read(kr.r, binary.BigEndian, v)
+	}
+}
+
+func (kr *kafkaReader) int16() int16 {
+	var v int16
+
+	kr.read(&v)
+
+	return v
+}
+
+func (kr *kafkaReader) int32() int32 {
+	var v int32
+
+	kr.read(&v)
+
+	return v
+}
+
+func (kr *kafkaReader) string() string {
+	n := kr.int16()
+	if kr.err != nil || n < 0 {
+		return ""
+	}
+
+	buf := make([]byte, n)
+
+	if _, err := io.ReadFull(kr.r, buf); err != nil {
+		kr.err = err
+	}
+
+	return string(buf)
+}
+
+func (kr *kafkaReader) skipInt32Array() {
+	n := kr.int32()
+
+	for i := int32(0); i < n && kr.err == nil; i++ {
+		kr.int32()
+	}
+}
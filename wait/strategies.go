@@ -0,0 +1,204 @@
+package wait
+
+import (
+	"context"
+	"database/sql"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/gomisc/errors.v1"
+)
+
+const (
+	// ErrLogMessageNotFound - ошибка "сообщение в логах не найдено"
+	ErrLogMessageNotFound = errors.Const("log message not found")
+	// ErrPortNotListening - в сетевом пространстве контейнера нет сокета порта
+	ErrPortNotListening = errors.Const("port is not listening")
+
+	// DefaultSQLQuery - проверочный запрос ForSQL по умолчанию
+	DefaultSQLQuery = "SELECT 1"
+
+	logMaxDelay  = 500 * time.Millisecond
+	portMaxDelay = time.Second
+	sqlMaxDelay  = time.Second
+	dialTimeout  = time.Second
+
+	// tcpListen, udpBound - состояния сокетов в таблицах /proc/net
+	tcpListen = "0A"
+	udpBound  = "07"
+)
+
+// Socket - порт протокола tcp или udp в сетевом пространстве контейнера
+type Socket struct {
+	Proto string
+	Port  uint16
+}
+
+// Immediately - готовность сразу после запуска процесса, для узлов кластера,
+// которые не могут стать готовыми до запуска остальных узлов
+func Immediately() func(ctx context.Context) <-chan error {
+	return func(context.Context) <-chan error {
+		readyCh := make(chan error)
+		close(readyCh)
+
+		return readyCh
+	}
+}
+
+// ForListeningPorts - готовность по успешному tcp подключению ко всем адресам
+func ForListeningPorts(addrs func() []string) func(ctx context.Context) <-chan error {
+	return For(
+		DefaultBackoff.WithMax(portMaxDelay), func(ctx context.Context) error {
+			return dialAll(ctx, addrs())
+		},
+	)
+}
+
+// ForListeningSockets - готовность ForListeningPorts, дополнительно
+// требующая сокетов sockets в сетевом пространстве контейнера: прокси
+// демона принимает подключение к опубликованному порту и до запуска
+// сервиса, а udp порты подключением не проверить. procNet возвращает
+// таблицы сокетов протокола (/proc/net/tcp и /proc/net/tcp6 для tcp),
+// пустой ответ - таблицы недоступны, и проверка внутри пропускается
+func ForListeningSockets(
+	addrs func() []string,
+	sockets func() []Socket,
+	procNet func(ctx context.Context, proto string) (string, error),
+) func(ctx context.Context) <-chan error {
+	return For(
+		DefaultBackoff.WithMax(portMaxDelay), func(ctx context.Context) error {
+			if err := dialAll(ctx, addrs()); err != nil {
+				return err
+			}
+
+			tables := make(map[string]map[uint16]bool)
+
+			for _, s := range sockets() {
+				listening, ok := tables[s.Proto]
+				if !ok {
+					table, err := procNet(ctx, s.Proto)
+					if err != nil {
+						return errors.Ctx().Str("proto", s.Proto).Wrap(err, "read socket table")
+					}
+
+					if table == "" {
+						return nil
+					}
+
+					listening = ListeningPorts(table, s.Proto)
+					tables[s.Proto] = listening
+				}
+
+				if !listening[s.Port] {
+					return errors.Ctx().Str("proto", s.Proto).Int("port", int(s.Port)).Just(ErrPortNotListening)
+				}
+			}
+
+			return nil
+		},
+	)
+}
+
+// ListeningPorts - порты сокетов протокола proto, ожидающих подключений
+// (tcp) или привязанных к порту (udp), по таблицам формата /proc/net/tcp
+func ListeningPorts(table, proto string) map[uint16]bool {
+	state := tcpListen
+	if proto == "udp" {
+		state = udpBound
+	}
+
+	listening := make(map[uint16]bool)
+
+	for _, line := range strings.Split(table, "\n") {
+		// sl local_address rem_address st ...
+		fields := strings.Fields(line)
+		if len(fields) < 4 || fields[3] != state {
+			continue
+		}
+
+		_, hexPort, ok := strings.Cut(fields[1], ":")
+		if !ok {
+			continue
+		}
+
+		if port, err := strconv.ParseUint(hexPort, 16, 16); err == nil {
+			listening[uint16(port)] = true
+		}
+	}
+
+	return listening
+}
+
+// dialAll - tcp подключение ко всем адресам addrs
+func dialAll(ctx context.Context, addrs []string) error {
+	dialer := net.Dialer{Timeout: dialTimeout}
+
+	for _, addr := range addrs {
+		conn, err := dialer.DialContext(ctx, "tcp", addr)
+		if err != nil {
+			return errors.Ctx().Str("addr", addr).Wrap(err, "dial port")
+		}
+
+		_ = conn.Close()
+	}
+
+	return nil
+}
+
+// ForLog - готовность по появлению подстроки в накопленном выводе контейнера
+func ForLog(logs func() string, substr string) func(ctx context.Context) <-chan error {
+	return For(
+		DefaultBackoff.WithMax(logMaxDelay), func(context.Context) error {
+			if strings.Contains(logs(), substr) {
+				return nil
+			}
+
+			return errors.Ctx().Str("message", substr).Just(ErrLogMessageNotFound)
+		},
+	)
+}
+
+// ForSQL - готовность по успешному выполнению запроса query (по умолчанию
+// DefaultSQLQuery) через database/sql. Драйвер driver должен быть
+// зарегистрирован вызывающим (импортом пакета драйвера), строка подключения
+// вычисляется dsn при каждой попытке, так как порты известны только после старта
+func ForSQL(driver string, dsn func() string, query string) func(ctx context.Context) <-chan error {
+	if query == "" {
+		query = DefaultSQLQuery
+	}
+
+	return For(
+		DefaultBackoff.WithMax(sqlMaxDelay), func(ctx context.Context) error {
+			db, err := sql.Open(driver, dsn())
+			if err != nil {
+				return errors.Ctx().Str("driver", driver).Wrap(err, "open database")
+			}
+
+			defer func() {
+				_ = db.Close()
+			}()
+
+			rows, err := db.QueryContext(ctx, query)
+			if err != nil {
+				return errors.Ctx().Str("driver", driver).Str("query", query).Wrap(err, "probe query")
+			}
+
+			defer func() {
+				_ = rows.Close()
+			}()
+
+			// значения не важны, строки вычитываются ради ошибок выполнения запроса
+			for rows.Next() {
+				continue
+			}
+
+			if err = rows.Err(); err != nil {
+				return errors.Ctx().Str("driver", driver).Str("query", query).Wrap(err, "read probe result")
+			}
+
+			return nil
+		},
+	)
+}
@@ -0,0 +1,207 @@
+package containers
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"gopkg.in/gomisc/errors.v1"
+)
+
+const (
+	ErrDirNotMounted = errors.Const("directory is not bind-mounted into container")
+
+	defaultWatchInterval = time.Second
+)
+
+type (
+	// ReloadAction - действие в контейнере при изменении отслеживаемого каталога
+	ReloadAction func(ctx context.Context, c Container) error
+
+	// WatchOption - опция отслеживания изменений каталога
+	WatchOption func(o *WatchOptions)
+
+	// WatchOptions - параметры отслеживания изменений каталога
+	WatchOptions struct {
+		// Interval - период опроса файловой системы
+		Interval time.Duration
+		// Ignore - шаблоны (filepath.Match) имен файлов, изменения которых игнорируются
+		Ignore []string
+	}
+
+	fileStamp struct {
+		size    int64
+		modTime int64
+	}
+)
+
+// WithWatchInterval - задает период опроса файловой системы
+func WithWatchInterval(interval time.Duration) WatchOption {
+	return func(o *WatchOptions) {
+		o.Interval = interval
+	}
+}
+
+// WithWatchIgnore - задает шаблоны игнорируемых файлов
+func WithWatchIgnore(patterns ...string) WatchOption {
+	return func(o *WatchOptions) {
+		o.Ignore = append(o.Ignore, patterns...)
+	}
+}
+
+// ExecReload - выполняет команду перезагрузки внутри контейнера
+func ExecReload(cmd ...string) ReloadAction {
+	return func(ctx context.Context, c Container) error {
+		code, err := ExtendClient(c.GetClient()).ContainerExec(ctx, c.GetID(), cmd, nil, nil)
+		if err != nil {
+			return errors.Wrap(err, "exec reload command")
+		}
+
+		if code != 0 {
+			return errors.Ctx().
+				Strings("cmd", cmd).
+				Int("exit-code", code).
+				New("reload command failed")
+		}
+
+		return nil
+	}
+}
+
+// RestartReload - перезапускает контейнер
+func RestartReload(timeout time.Duration) ReloadAction {
+	return func(ctx context.Context, c Container) error {
+		return ExtendClient(c.GetClient()).ContainerRestart(ctx, c.GetID(), timeout)
+	}
+}
+
+// Watch - отслеживает изменения в примонтированном в контейнер каталоге и
+// выполняет действие перезагрузки. Блокируется до отмены контекста,
+// ошибки действия пишутся в поток ошибок контейнера
+func Watch(ctx context.Context, c Container, dir string, action ReloadAction, opts ...WatchOption) error {
+	options := &WatchOptions{Interval: defaultWatchInterval}
+
+	for _, apply := range opts {
+		apply(options)
+	}
+
+	root, err := filepath.Abs(dir)
+	if err != nil {
+		return errors.Wrap(err, "resolve watch directory")
+	}
+
+	if !isMounted(c, root) {
+		return errors.Ctx().Str("dir", root).Just(ErrDirNotMounted)
+	}
+
+	prev, err := scanDir(root, options.Ignore)
+	if err != nil {
+		return errors.Wrap(err, "scan watch directory")
+	}
+
+	ticker := time.NewTicker(options.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+
+		curr, scanErr := scanDir(root, options.Ignore)
+		if scanErr != nil {
+			c.LogError(scanErr, "scan watch directory")
+
+			continue
+		}
+
+		if !changed(prev, curr) {
+			continue
+		}
+
+		prev = curr
+
+		c.LogStdout("%s changed, reloading %s", root, c.GetName())
+
+		if actErr := action(ctx, c); actErr != nil {
+			c.LogError(actErr, "reload container")
+		}
+	}
+}
+
+func isMounted(c Container, root string) bool {
+	for _, m := range ExtendContainer(c).GetMountSpecs() {
+		if m.MountType() != MountBind {
+			continue
+		}
+
+		if abs, err := filepath.Abs(m.Source); err == nil && abs == root {
+			return true
+		}
+	}
+
+	return false
+}
+
+func scanDir(root string, ignore []string) (map[string]fileStamp, error) {
+	stamps := make(map[string]fileStamp)
+
+	err := filepath.WalkDir(
+		root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				// файл удален во время обхода
+				if os.IsNotExist(err) {
+					return nil
+				}
+
+				return err
+			}
+
+			for _, pattern := range ignore {
+				if ok, _ := filepath.Match(pattern, d.Name()); ok {
+					if d.IsDir() {
+						return filepath.SkipDir
+					}
+
+					return nil
+				}
+			}
+
+			if d.IsDir() {
+				return nil
+			}
+
+			info, err := d.Info()
+			if err != nil {
+				if os.IsNotExist(err) {
+					return nil
+				}
+
+				return err
+			}
+
+			stamps[path] = fileStamp{size: info.Size(), modTime: info.ModTime().UnixNano()}
+
+			return nil
+		},
+	)
+
+	return stamps, err
+}
+
+func changed(prev, curr map[string]fileStamp) bool {
+	if len(prev) != len(curr) {
+		return true
+	}
+
+	for path, stamp := range curr {
+		if p, ok := prev[path]; !ok || p != stamp {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,94 @@
+package containers
+
+import (
+	"bufio"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// plainKey - ключи, которые пишутся без кавычек
+var plainKey = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_./-]*$`)
+
+// yamlWriter - минимальный построчный писатель YAML для экспорта топологий;
+// все скаляры записываются в двойных кавычках (JSON-совместимая форма), ключи -
+// в кавычках, если без них они не читаются как строка, первая ошибка записи запоминается и возвращается из flush
+type yamlWriter struct {
+	w   *bufio.Writer
+	err error
+}
+
+func newYAMLWriter(w io.Writer) *yamlWriter {
+	return &yamlWriter{w: bufio.NewWriter(w)}
+}
+
+func (y *yamlWriter) line(indent int, s string) {
+	if y.err != nil {
+		return
+	}
+
+	if _, err := y.w.WriteString(strings.Repeat("  ", indent) + s + "\n"); err != nil {
+		y.err = err
+	}
+}
+
+// key - ключ вложенного объекта или списка
+func (y *yamlWriter) key(indent int, key string) {
+	y.line(indent, quoteKey(key)+":")
+}
+
+// value - пара ключ-значение
+func (y *yamlWriter) value(indent int, key, value string) {
+	y.line(indent, quoteKey(key)+": "+quote(value))
+}
+
+// list - список строк под ключом, пустой список не пишется
+func (y *yamlWriter) list(indent int, key string, values []string) {
+	if len(values) == 0 {
+		return
+	}
+
+	y.key(indent, key)
+
+	for _, v := range values {
+		y.line(indent+1, "- "+quote(v))
+	}
+}
+
+// separator - разделитель документов
+func (y *yamlWriter) separator() {
+	y.line(0, "---")
+}
+
+func quote(s string) string {
+	return strconv.Quote(s)
+}
+
+// quoteKey - ключ как есть, если это простое имя, иначе в кавычках: ':', '#',
+// начальные '-', '&', '*' и слова вроде true или null меняют смысл ключа
+func quoteKey(key string) string {
+	if plainKey.MatchString(key) && !yamlReserved(key) {
+		return key
+	}
+
+	return quote(key)
+}
+
+// yamlReserved - слова, которые YAML читает как bool или null
+func yamlReserved(s string) bool {
+	switch strings.ToLower(s) {
+	case "true", "false", "yes", "no", "on", "off", "y", "n", "null":
+		return true
+	}
+
+	return false
+}
+
+func (y *yamlWriter) flush() error {
+	if y.err != nil {
+		return y.err
+	}
+
+	return y.w.Flush()
+}